@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	ipcgen "github.com/sandeepkv93/googlysync/internal/ipc/gen"
+)
+
+func runAccounts(args []string) {
+	if len(args) == 0 {
+		accountsUsage()
+	}
+	switch args[0] {
+	case "list":
+		runAccountsList(args[1:])
+	case "switch":
+		runAccountsSwitch(args[1:])
+	case "set-primary":
+		runAccountsSetPrimary(args[1:])
+	case "doctor":
+		runAccountsDoctor(args[1:])
+	default:
+		accountsUsage()
+	}
+}
+
+// runAccountsList prints the signed-in accounts, marking the one the
+// daemon syncs.
+func runAccountsList(args []string) {
+	fs := flag.NewFlagSet("accounts list", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	_ = fs.Parse(args)
+
+	ctx := context.Background()
+	svc, store := openAuth(ctx, *configPath)
+	defer store.Close()
+
+	accounts, err := svc.Accounts(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "list failed: %v\n", err)
+		os.Exit(1)
+	}
+	if len(accounts) == 0 {
+		fmt.Println("no accounts signed in; run googlysync login")
+		return
+	}
+	active := svc.State().Account.ID
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\tEMAIL\tID\tSYNC ROOT\tSIGN-IN")
+	for _, acct := range accounts {
+		mark := ""
+		if acct.ID == active {
+			mark = "*"
+		}
+		root := acct.SyncRoot
+		if root == "" {
+			root = "(sync_root)"
+		}
+		signIn := "ok"
+		if acct.ReauthReason != "" {
+			signIn = "expired; run googlysync login"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", mark, acct.Email, acct.ID, root, signIn)
+	}
+	_ = w.Flush()
+}
+
+// runAccountsSwitch makes another signed-in account the one the daemon
+// syncs.
+func runAccountsSwitch(args []string) {
+	fs := flag.NewFlagSet("accounts switch", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: googlysync accounts switch ACCOUNT")
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	svc, store := openAuth(ctx, *configPath)
+	defer store.Close()
+
+	previous := svc.State().Account.ID
+	acct, err := svc.Switch(ctx, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "switch failed: %v\n", err)
+		os.Exit(1)
+	}
+	if acct.ID == previous {
+		fmt.Printf("already syncing %s\n", acct.Email)
+		return
+	}
+	forgetSyncedAccount(ctx, store, previous)
+	fmt.Printf("switched to %s; restart the daemon and run googlysync resync\n", acct.Email)
+}
+
+// runAccountsSetPrimary makes another account the primary one through the
+// running daemon.
+func runAccountsSetPrimary(args []string) {
+	fs := flag.NewFlagSet("accounts set-primary", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	socketPath := fs.String("socket", "", "unix socket path")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout for request")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: googlysync accounts set-primary ACCOUNT")
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	conn := dialDaemon(ctx, *configPath, *socketPath)
+	defer conn.Close()
+
+	resp, err := ipcgen.NewAuthServiceClient(conn).SetPrimaryAccount(ctx, &ipcgen.SetPrimaryAccountRequest{Account: fs.Arg(0)})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "set-primary failed: %v\n", err)
+		os.Exit(1)
+	}
+	if resp.GetRestartRequired() {
+		fmt.Printf("%s is now primary; syncing is paused, restart the daemon and run googlysync resync\n", resp.GetEmail())
+		return
+	}
+	fmt.Printf("%s is now primary\n", resp.GetEmail())
+}
+
+// runAccountsDoctor prints token diagnostics from the daemon and exits
+// non-zero when any account has a problem.
+func runAccountsDoctor(args []string) {
+	fs := flag.NewFlagSet("accounts doctor", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	socketPath := fs.String("socket", "", "unix socket path")
+	account := fs.String("account", "", "diagnose only this account id")
+	timeout := fs.Duration("timeout", 5*time.Second, "timeout for request")
+	_ = fs.Parse(args)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	conn := dialDaemon(ctx, *configPath, *socketPath)
+	defer conn.Close()
+
+	resp, err := ipcgen.NewAuthServiceClient(conn).DiagnoseAccounts(ctx, &ipcgen.DiagnoseAccountsRequest{AccountId: *account})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "doctor failed: %v\n", err)
+		os.Exit(1)
+	}
+	if len(resp.GetAccounts()) == 0 {
+		fmt.Println("no accounts signed in")
+		return
+	}
+
+	now := time.Now()
+	unhealthy := false
+	for i, d := range resp.GetAccounts() {
+		if i > 0 {
+			fmt.Println()
+		}
+		label := d.GetAccountId()
+		if d.GetIsPrimary() {
+			label += ", primary"
+		}
+		fmt.Printf("%s (%s)\n", d.GetEmail(), label)
+		fmt.Printf("  scopes:        %s\n", strings.Join(d.GetScopes(), " "))
+		fmt.Printf("  token expiry:  %s\n", describeTime(d.GetTokenExpiry(), now))
+		fmt.Printf("  last refresh:  %s\n", describeTime(d.GetLastRefresh(), now))
+		if d.GetLastRefreshError() != "" {
+			fmt.Printf("  refresh error: %s (%s)\n", d.GetLastRefreshError(), describeTime(d.GetLastRefreshErrorAt(), now))
+		}
+		// The keyring is named by its backend when keyring_backend picks one.
+		store := d.GetTokenStore()
+		switch store {
+		case "":
+			store = "keyring"
+		case "file":
+			store = "token file"
+		}
+		store = fmt.Sprintf("%-14s", store+":")
+		switch {
+		case d.GetServiceAccount() != "":
+			fmt.Printf("  credentials:   service account %s\n", d.GetServiceAccount())
+		case !d.GetKeyringReachable():
+			fmt.Printf("  %s unreachable: %s\n", store, d.GetKeyringError())
+		case !d.GetRefreshTokenStored():
+			fmt.Printf("  %s refresh token missing\n", store)
+		default:
+			fmt.Printf("  %s ok\n", store)
+		}
+		if len(d.GetProblems()) == 0 {
+			fmt.Println("  status:        healthy")
+			continue
+		}
+		unhealthy = true
+		fmt.Println("  problems:")
+		for _, p := range d.GetProblems() {
+			fmt.Printf("    - %s\n", p)
+		}
+	}
+	if unhealthy {
+		os.Exit(1)
+	}
+}
+
+// describeTime formats a timestamp with a relative hint, e.g.
+// "2024-01-31T10:00:00Z (in 42m)".
+func describeTime(ts *timestamppb.Timestamp, now time.Time) string {
+	if ts == nil {
+		return "never"
+	}
+	t := ts.AsTime()
+	d := t.Sub(now).Round(time.Second)
+	hint := "in " + d.String()
+	if d < 0 {
+		hint = (-d).String() + " ago"
+	}
+	return fmt.Sprintf("%s (%s)", t.Local().Format(time.RFC3339), hint)
+}
+
+func accountsUsage() {
+	fmt.Println("Usage: googlysync accounts list")
+	fmt.Println("       googlysync accounts switch ACCOUNT")
+	fmt.Println("       googlysync accounts set-primary ACCOUNT")
+	fmt.Println("       googlysync accounts doctor [--account ID]")
+	os.Exit(2)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	syncer "github.com/sandeepkv93/googlysync/internal/sync"
+)
+
+func runAdopt(args []string) {
+	fs := flag.NewFlagSet("adopt", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: googlysync adopt <existing-folder>")
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	cfg, store, svc := openOfflineAuth(ctx, *configPath)
+	defer store.Close()
+	client := driveapi.NewClient(svc.Client(svc.State().Account.ID))
+
+	rootID, err := syncer.DriveRootID(ctx, cfg, store, svc.State().Account.ID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "drive root: %v\n", err)
+		os.Exit(1)
+	}
+
+	engine := offlineEngine(cfg, store, svc)
+	report, err := engine.Adopt(ctx, client, rootID, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "adopt failed: %v\n", err)
+		os.Exit(1)
+	}
+	for _, skipped := range report.Skipped {
+		fmt.Printf("skipped %s\n", skipped)
+	}
+	fmt.Printf("adopted %d file(s); queued %d upload(s) and %d download(s)\n", report.Adopted, report.Uploads, report.Downloads)
+}
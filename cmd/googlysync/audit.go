@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// runAudit prints what the daemon would have done in audit mode.
+func runAudit(args []string) {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	account := fs.String("account", "", "account id (default: the synced account)")
+	since := fs.Duration("since", 0, "only entries newer than this (e.g. 24h; 0 for all)")
+	limit := fs.Int("limit", 100, "maximum number of entries")
+	_ = fs.Parse(args)
+
+	_, store, accountID := openOfflineAccount(*configPath, *account)
+	defer store.Close()
+
+	var from time.Time
+	if *since > 0 {
+		from = time.Now().Add(-*since)
+	}
+	entries, err := store.ListAuditEntries(context.Background(), accountID, from, *limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audit failed: %v\n", err)
+		os.Exit(1)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tACTION\tPATH\tDETAIL")
+	for _, entry := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", entry.RecordedAt.Local().Format(time.DateTime), entry.Action, entry.Path, entry.Detail)
+	}
+	_ = tw.Flush()
+}
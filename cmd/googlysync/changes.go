@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// runChanges prints the journal of changes read from the Drive changes
+// feed, newest first, to answer why a file changed.
+func runChanges(args []string) {
+	fs := flag.NewFlagSet("changes", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	account := fs.String("account", "", "account id (default: the synced account)")
+	driveID := fs.String("drive-id", "", "only changes to this Drive file id")
+	path := fs.String("path", "", "only changes to the file or folder indexed at this path, relative to the sync root")
+	outcome := fs.String("outcome", "", "only this outcome (applied, failed, duplicate or superseded)")
+	before := fs.Int64("before", 0, "continue from this entry id (from the previous page)")
+	limit := fs.Int("limit", 50, "maximum number of entries")
+	_ = fs.Parse(args)
+
+	_, store, accountID := openOfflineAccount(*configPath, *account)
+	defer store.Close()
+	ctx := context.Background()
+
+	filter := storage.ChangeFilter{AccountID: accountID, DriveID: *driveID, Outcome: *outcome, BeforeID: *before, Limit: *limit}
+	if *path != "" {
+		id, err := indexedDriveID(ctx, store, accountID, *path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "changes failed: %v\n", err)
+			os.Exit(1)
+		}
+		filter.DriveID = id
+	}
+	entries, err := store.ListJournaledChanges(ctx, filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "changes failed: %v\n", err)
+		os.Exit(1)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tRECEIVED\tCHANGED\tKIND\tDRIVE ID\tNAME\tVERSION\tOUTCOME\tERROR")
+	for _, c := range entries {
+		changed := "-"
+		if !c.ChangedAt.IsZero() {
+			changed = c.ChangedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", c.ID, c.ReceivedAt.Local().Format(time.DateTime), changed,
+			c.Kind, c.DriveID, orDash(c.Name), c.Version, c.Outcome, orDash(c.Error))
+	}
+	_ = tw.Flush()
+	if *limit > 0 && len(entries) == *limit {
+		fmt.Printf("more entries: --before %d\n", entries[len(entries)-1].ID)
+	}
+}
+
+// indexedDriveID returns the Drive id of the file or folder indexed at rel.
+func indexedDriveID(ctx context.Context, store storage.Store, accountID, rel string) (string, error) {
+	rec, err := store.GetFileByPath(ctx, accountID, rel)
+	if err != nil {
+		return "", err
+	}
+	if rec != nil && rec.DriveID != "" {
+		return rec.DriveID, nil
+	}
+	folder, err := store.GetFolderByPath(ctx, accountID, rel)
+	if err != nil {
+		return "", err
+	}
+	if folder != nil && folder.DriveID != "" {
+		return folder.DriveID, nil
+	}
+	return "", fmt.Errorf("%s is not indexed with a Drive id", rel)
+}
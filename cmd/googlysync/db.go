@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// runDB inspects, rolls back, maintains, backs up or restores the local
+// database. status and rollback do not migrate, so both are safe to run
+// against a database written by a newer or older build.
+func runDB(args []string) {
+	if len(args) == 0 {
+		dbUsage()
+	}
+	switch args[0] {
+	case "status":
+		runDBStatus(args[1:])
+	case "rollback":
+		runDBRollback(args[1:])
+	case "maintain":
+		runDBMaintain(args[1:])
+	case "backup":
+		runDBBackup(args[1:])
+	case "restore":
+		runDBRestore(args[1:])
+	default:
+		dbUsage()
+	}
+}
+
+func loadConfig(configPath string) *config.Config {
+	cfg, err := config.NewConfigWithOptions(config.Options{ConfigPath: configPath})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+func runDBStatus(args []string) {
+	fs := flag.NewFlagSet("db status", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	_ = fs.Parse(args)
+
+	cfg := loadConfig(*configPath)
+	migrations, err := storage.SchemaStatus(context.Background(), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "status failed: %v\n", err)
+		os.Exit(1)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tMIGRATION\tAPPLIED")
+	for _, m := range migrations {
+		applied := "pending"
+		if m.Applied {
+			applied = m.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", m.Version, m.Name, applied)
+	}
+	_ = tw.Flush()
+
+	backups, err := storage.SchemaBackups(cfg.DatabasePath)
+	if err == nil && len(backups) > 0 {
+		fmt.Println("\nbackups:")
+		for _, b := range backups {
+			fmt.Printf("  %s\n", b)
+		}
+	}
+}
+
+func runDBRollback(args []string) {
+	fs := flag.NewFlagSet("db rollback", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	to := fs.Int64("to", -1, "schema version to roll back to")
+	_ = fs.Parse(args)
+	if *to < 0 || fs.NArg() != 0 {
+		dbUsage()
+	}
+
+	cfg := loadConfig(*configPath)
+	if err := storage.RollBack(context.Background(), cfg, zap.NewNop(), *to); err != nil {
+		fmt.Fprintf(os.Stderr, "rollback failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("rolled the database back to version %d; starting the daemon migrates it forward again\n", *to)
+}
+
+func runDBMaintain(args []string) {
+	fs := flag.NewFlagSet("db maintain", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	full := fs.Bool("full", false, "run the full integrity check, which reads every index")
+	_ = fs.Parse(args)
+
+	_, store := openOffline(*configPath)
+	defer store.Close()
+	report, err := store.Maintain(context.Background(), !*full)
+	if report != nil && len(report.Problems) > 0 {
+		fmt.Fprintln(os.Stderr, "integrity check failed:")
+		for _, problem := range report.Problems {
+			fmt.Fprintf(os.Stderr, "  %s\n", problem)
+		}
+		fmt.Fprintln(os.Stderr, "stop the daemon and restore a backup, or run googlysync resync to rebuild the index")
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "maintain failed: %v\n", err)
+		os.Exit(1)
+	}
+	if report.Converted {
+		fmt.Println("rebuilt the database for incremental vacuuming")
+	}
+	fmt.Printf("integrity ok; freed %d pages and refreshed statistics in %s\n", report.FreedPages, report.Took.Round(time.Millisecond))
+}
+
+func runDBBackup(args []string) {
+	fs := flag.NewFlagSet("db backup", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		dbUsage()
+	}
+
+	_, store := openOffline(*configPath)
+	defer store.Close()
+	if err := store.Backup(context.Background(), fs.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "backup failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("backed up the database to %s\n", fs.Arg(0))
+}
+
+func runDBRestore(args []string) {
+	fs := flag.NewFlagSet("db restore", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		dbUsage()
+	}
+
+	cfg := loadConfig(*configPath)
+	saved, err := storage.Restore(context.Background(), cfg, zap.NewNop(), fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore failed: %v\n", err)
+		os.Exit(1)
+	}
+	if saved != "" {
+		fmt.Printf("saved the replaced database as %s\n", saved)
+	}
+	fmt.Printf("restored the database from %s; starting the daemon migrates it if needed\n", fs.Arg(0))
+}
+
+func dbUsage() {
+	fmt.Println("Usage: googlysync db status")
+	fmt.Println("       googlysync db rollback --to VERSION")
+	fmt.Println("       googlysync db maintain [--full]")
+	fmt.Println("       googlysync db backup <path> | restore <path>")
+	os.Exit(2)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	syncer "github.com/sandeepkv93/googlysync/internal/sync"
+)
+
+func runDetach(args []string) {
+	fs := flag.NewFlagSet("detach", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	deleteLocal := fs.Bool("delete", false, "delete the local copy instead of keeping it")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: googlysync detach [--delete] <path>")
+		os.Exit(2)
+	}
+
+	cfg, store, accountID := openOfflineAccount(*configPath, "")
+	defer store.Close()
+
+	dest, err := syncer.Detach(context.Background(), cfg, store, accountID, fs.Arg(0), *deleteLocal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "detach failed: %v\n", err)
+		os.Exit(1)
+	}
+	switch {
+	case *deleteLocal:
+		fmt.Printf("detached %s; local copy deleted\n", fs.Arg(0))
+	case dest != "":
+		fmt.Printf("detached %s; local copy kept at %s\n", fs.Arg(0), dest)
+	default:
+		fmt.Printf("detached %s\n", fs.Arg(0))
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/auth"
+	"github.com/sandeepkv93/googlysync/internal/device"
+)
+
+func runDevice(args []string) {
+	fs := flag.NewFlagSet("device", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	rename := fs.String("rename", "", "rename this device in Drive")
+	_ = fs.Parse(args)
+
+	cfg, store := openOffline(*configPath)
+	defer store.Close()
+
+	ctx := context.Background()
+	svc, err := auth.NewService(ctx, zap.NewNop(), cfg, store)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "auth error: %v\n", err)
+		os.Exit(1)
+	}
+	reg := device.NewRegistrar(zap.NewNop(), cfg, store, deviceClients(svc))
+
+	if *rename != "" {
+		dev, err := reg.Rename(ctx, *rename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rename failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("device renamed to %s\n", dev.Name)
+		return
+	}
+
+	dev, err := store.GetDevice(ctx, svc.State().Account.ID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "device lookup failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("sync target: %s\n", cfg.SyncTarget)
+	if dev == nil {
+		fmt.Printf("not registered; the daemon creates %q on first run with sync_target %s\n", reg.Name(), device.TargetComputers)
+		return
+	}
+	fmt.Printf("device:      %s (%s)\n", dev.Name, dev.DriveID)
+	fmt.Printf("root folder: %s\n", dev.RootDriveID)
+	fmt.Printf("registered:  %s\n", dev.CreatedAt.Format("2006-01-02 15:04:05"))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sandeepkv93/googlysync/internal/encryption"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// runEncryption creates and moves the per-account keys used to encrypt
+// content before upload.
+func runEncryption(args []string) {
+	if len(args) == 0 {
+		encryptionUsage()
+	}
+	switch args[0] {
+	case "init":
+		runEncryptionInit(args[1:])
+	case "export":
+		runEncryptionExport(args[1:])
+	case "import":
+		runEncryptionImport(args[1:])
+	default:
+		encryptionUsage()
+	}
+}
+
+func encryptionKeys(fs *flag.FlagSet, args []string) (*encryption.Keys, string) {
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	account := fs.String("account", "", "account id the key belongs to (default: the synced account)")
+	_ = fs.Parse(args)
+	cfg, store, accountID := openOfflineAccount(*configPath, *account)
+	store.Close()
+	return encryption.NewKeys(cfg), accountID
+}
+
+func runEncryptionInit(args []string) {
+	fs := flag.NewFlagSet("encryption init", flag.ExitOnError)
+	keys, account := encryptionKeys(fs, args)
+
+	_, err := keys.Key(account)
+	if err == nil {
+		fmt.Fprintf(os.Stderr, "account %q already has a key; content sealed under it would be unreadable if it were replaced\n", account)
+		os.Exit(1)
+	}
+	if errs.KindOf(err) != errs.ErrNotFound {
+		fmt.Fprintf(os.Stderr, "keyring error: %v\n", err)
+		os.Exit(1)
+	}
+	key, err := encryption.NewKey()
+	if err == nil {
+		err = keys.Set(account, key)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "init failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("created an encryption key for %s\n", account)
+	fmt.Println("Back it up with `googlysync encryption export`. Encrypted files cannot be recovered without it.")
+}
+
+func runEncryptionExport(args []string) {
+	fs := flag.NewFlagSet("encryption export", flag.ExitOnError)
+	keys, account := encryptionKeys(fs, args)
+
+	key, err := keys.Key(account)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(encryption.EncodeKey(key))
+}
+
+func runEncryptionImport(args []string) {
+	fs := flag.NewFlagSet("encryption import", flag.ExitOnError)
+	keys, account := encryptionKeys(fs, args)
+	if fs.NArg() > 1 {
+		encryptionUsage()
+	}
+
+	text := fs.Arg(0)
+	if text == "" || text == "-" {
+		scanner := bufio.NewScanner(os.Stdin)
+		if !scanner.Scan() {
+			fmt.Fprintln(os.Stderr, "import failed: no key on stdin")
+			os.Exit(1)
+		}
+		text = scanner.Text()
+	}
+	key, err := encryption.DecodeKey(text)
+	if err == nil {
+		err = keys.Set(account, key)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("imported the encryption key for %s\n", account)
+}
+
+func encryptionUsage() {
+	fmt.Println("Usage: googlysync encryption init [--account ID] | export [--account ID] | import [--account ID] [KEY|-]")
+	os.Exit(2)
+}
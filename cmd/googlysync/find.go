@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/auth"
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/search"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+func runFind(args []string) {
+	fs := flag.NewFlagSet("find", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	remote := fs.Bool("remote", false, "also run a Drive full-text search per signed-in account")
+	account := fs.String("account", "", "limit remote search to one account id")
+	limit := fs.Int("limit", 50, "maximum results per source")
+	_ = fs.Parse(args)
+
+	query := strings.Join(fs.Args(), " ")
+	if query == "" {
+		fmt.Println("Usage: googlysync find [--remote] [--account ID] [--limit N] <query>")
+		os.Exit(2)
+	}
+
+	cfg, err := config.NewConfigWithOptions(config.Options{ConfigPath: *configPath})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+		os.Exit(1)
+	}
+	store, err := storage.Open(cfg, zap.NewNop())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "storage error: %v\n", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	opts := search.Options{Limit: *limit}
+	if *remote {
+		opts.Remote, err = remoteSearchers(ctx, cfg, store, *account)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "remote search unavailable: %v\n", err)
+		}
+	}
+
+	results, remoteErrs, err := search.NewSearcher(store).Find(ctx, query, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "search failed: %v\n", err)
+		os.Exit(1)
+	}
+	for accountID, err := range remoteErrs {
+		fmt.Fprintf(os.Stderr, "remote search for %s failed: %v\n", accountID, err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tSTATE\tACCOUNT\tPATH")
+	for _, r := range results {
+		name := r.Path
+		if name == "" {
+			name = r.Name
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Source, r.State, r.AccountID, name)
+	}
+	_ = tw.Flush()
+}
+
+// remoteSearchers builds a Drive client for each account with stored tokens.
+func remoteSearchers(ctx context.Context, cfg *config.Config, store storage.Store, only string) (map[string]search.RemoteSearcher, error) {
+	svc, err := auth.NewService(ctx, zap.NewNop(), cfg, store)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := store.ListAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]search.RemoteSearcher)
+	for _, acct := range accounts {
+		if only != "" && acct.ID != only {
+			continue
+		}
+		ref, err := store.GetTokenRef(ctx, acct.ID)
+		if err != nil || ref == nil {
+			continue
+		}
+		out[acct.ID] = driveapi.NewClient(svc.Client(acct.ID))
+	}
+	return out, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// runHistory prints finished sync operations from the sync history, newest
+// first, a page at a time.
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	account := fs.String("account", "", "account id (default: the synced account)")
+	op := fs.String("op", "", "only this operation (upload, download, delete, move, conflict, ...)")
+	outcome := fs.String("outcome", "", "only this outcome (succeeded, failed or detected)")
+	path := fs.String("path", "", "only this path and everything below it, relative to the sync root")
+	since := fs.Duration("since", 0, "only entries newer than this (e.g. 24h; 0 for all)")
+	before := fs.Int64("before", 0, "continue from this entry id (from the previous page)")
+	limit := fs.Int("limit", 50, "maximum number of entries")
+	_ = fs.Parse(args)
+
+	_, store, accountID := openOfflineAccount(*configPath, *account)
+	defer store.Close()
+
+	filter := storage.HistoryFilter{
+		AccountID: accountID,
+		Op:        *op,
+		Outcome:   *outcome,
+		Path:      *path,
+		BeforeID:  *before,
+		Limit:     *limit,
+	}
+	if *since > 0 {
+		filter.Since = time.Now().Add(-*since)
+	}
+	entries, err := store.ListHistory(context.Background(), filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "history failed: %v\n", err)
+		os.Exit(1)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tFINISHED\tOP\tOUTCOME\tBYTES\tTOOK\tPATH\tDETAIL")
+	for _, entry := range entries {
+		detail := entry.Detail
+		if entry.Error != "" {
+			detail = entry.Error
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", entry.ID, entry.FinishedAt.Local().Format(time.DateTime),
+			entry.Op, entry.Outcome, entry.Bytes, entry.FinishedAt.Sub(entry.StartedAt), entry.Path, orDash(detail))
+	}
+	_ = tw.Flush()
+	if *limit > 0 && len(entries) == *limit {
+		fmt.Printf("more entries: --before %d\n", entries[len(entries)-1].ID)
+	}
+}
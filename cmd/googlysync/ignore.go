@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/sandeepkv93/googlysync/internal/storage"
+	syncer "github.com/sandeepkv93/googlysync/internal/sync"
+)
+
+// runIgnore manages the ignore rules kept in the database for the sync
+// root, which apply alongside ignore_patterns from the config.
+func runIgnore(args []string) {
+	if len(args) == 0 {
+		ignoreUsage()
+	}
+	switch args[0] {
+	case "add":
+		runIgnoreAdd(args[1:])
+	case "list":
+		runIgnoreList(args[1:])
+	case "remove":
+		runIgnoreChange(args[1:], "remove")
+	case "enable":
+		runIgnoreChange(args[1:], "enable")
+	case "disable":
+		runIgnoreChange(args[1:], "disable")
+	default:
+		ignoreUsage()
+	}
+}
+
+func runIgnoreAdd(args []string) {
+	fs := flag.NewFlagSet("ignore add", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	account := fs.String("account", "", "account id the rule belongs to (default: the synced account)")
+	anyRoot := fs.Bool("any-root", false, "apply to every sync root, not just the configured one")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		ignoreUsage()
+	}
+
+	cfg, store, accountID := openOfflineAccount(*configPath, *account)
+	defer store.Close()
+	pattern := fs.Arg(0)
+	if err := syncer.ValidateIgnorePattern(pattern); err != nil {
+		fmt.Fprintf(os.Stderr, "add failed: %v\n", err)
+		os.Exit(1)
+	}
+	rule := &storage.IgnoreRule{AccountID: accountID, Root: cfg.SyncRoot, Pattern: pattern, Source: "cli"}
+	if *anyRoot {
+		rule.Root = ""
+	}
+	if err := store.AddIgnoreRule(context.Background(), rule); err != nil {
+		fmt.Fprintf(os.Stderr, "add failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("ignoring %q (rule %d)\n", pattern, rule.ID)
+}
+
+func runIgnoreList(args []string) {
+	fs := flag.NewFlagSet("ignore list", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	account := fs.String("account", "", "account id whose rules to list (default: the synced account)")
+	_ = fs.Parse(args)
+
+	cfg, store, accountID := openOfflineAccount(*configPath, *account)
+	defer store.Close()
+	rules, err := store.ListIgnoreRules(context.Background(), accountID, cfg.SyncRoot, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "list failed: %v\n", err)
+		os.Exit(1)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tPATTERN\tSOURCE\tENABLED\tROOT")
+	for _, pattern := range cfg.IgnorePatterns {
+		fmt.Fprintf(tw, "-\t%s\tconfig\ttrue\t-\n", pattern)
+	}
+	for _, rule := range rules {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%t\t%s\n", rule.ID, rule.Pattern, orDash(rule.Source), rule.Enabled, orDash(rule.Root))
+	}
+	_ = tw.Flush()
+}
+
+func runIgnoreChange(args []string, action string) {
+	fs := flag.NewFlagSet("ignore "+action, flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	account := fs.String("account", "", "account id the rule belongs to (default: the synced account)")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		ignoreUsage()
+	}
+	id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		ignoreUsage()
+	}
+
+	_, store, accountID := openOfflineAccount(*configPath, *account)
+	defer store.Close()
+	ctx := context.Background()
+	switch action {
+	case "remove":
+		err = store.RemoveIgnoreRule(ctx, accountID, id)
+	default:
+		err = store.SetIgnoreRuleEnabled(ctx, accountID, id, action == "enable")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed: %v\n", action, err)
+		os.Exit(1)
+	}
+	fmt.Printf("%sd ignore rule %d\n", action, id)
+}
+
+func ignoreUsage() {
+	fmt.Println("Usage: googlysync ignore add [--any-root] <pattern>")
+	fmt.Println("       googlysync ignore list | remove <id> | enable <id> | disable <id>")
+	os.Exit(2)
+}
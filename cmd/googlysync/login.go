@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/sandeepkv93/googlysync/internal/auth"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+	syncer "github.com/sandeepkv93/googlysync/internal/sync"
+)
+
+// runLogin signs a Google account in through the browser.
+func runLogin(args []string) {
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	add := fs.Bool("add", false, "sign in another account next to the signed-in ones")
+	syncRoot := fs.String("sync-root", "", "directory to mirror this account into (default: sync_root)")
+	account := fs.String("account", "", "signed-in account (id or email) to grant the Drive access drive_access needs")
+	noBrowser := fs.Bool("no-browser", false, "print the sign-in URL and read the code back instead of opening a browser")
+	timeout := fs.Duration("timeout", 0, "how long to wait for the browser sign-in (default: sign_in_timeout_seconds)")
+	_ = fs.Parse(args)
+
+	root := *syncRoot
+	if root != "" {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sync root: %v\n", err)
+			os.Exit(1)
+		}
+		root = abs
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	cfg, store, svc := openOfflineAuth(ctx, *configPath)
+	defer store.Close()
+	if *timeout > 0 {
+		cfg.SignInTimeoutSeconds = max(1, int(timeout.Seconds()))
+	}
+
+	opts := auth.SignInOptions{Add: *add, SyncRoot: root, Account: *account}
+	if *noBrowser {
+		opts.Prompt = pasteCode
+	}
+	acct, err := svc.SignIn(ctx, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "login failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("signed in as %s (%s)\n", acct.Email, acct.ID)
+	if !acct.IsPrimary {
+		fmt.Printf("the daemon keeps syncing %s; switch with: googlysync accounts switch %s\n", svc.State().Account.Email, acct.Email)
+	}
+}
+
+// pasteCode shows the consent URL and reads back the address the browser
+// ended up on, for machines without a browser.
+func pasteCode(authURL string) (string, error) {
+	fmt.Println("Open this URL in a browser on any machine and sign in:")
+	fmt.Println()
+	fmt.Println("  " + authURL)
+	fmt.Println()
+	fmt.Println("The browser is then sent to a 127.0.0.1 address that will not load there.")
+	fmt.Print("Paste that address from the address bar here: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return line, nil
+}
+
+// runLogout signs one account out and removes its keyring entry.
+func runLogout(args []string) {
+	fs := flag.NewFlagSet("logout", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	account := fs.String("account", "", "account id or email (default: the active account)")
+	_ = fs.Parse(args)
+
+	ctx := context.Background()
+	svc, store := openAuth(ctx, *configPath)
+	defer store.Close()
+
+	active := svc.State().Account
+	acct := active
+	if *account != "" {
+		found, err := svc.Account(ctx, *account)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "logout failed: %v\n", err)
+			os.Exit(1)
+		}
+		acct = *found
+	}
+	if acct.ID == "" {
+		fmt.Println("no accounts signed in")
+		return
+	}
+	if err := svc.SignOut(ctx, acct.ID); err != nil {
+		fmt.Fprintf(os.Stderr, "logout failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("signed out %s\n", acct.Email)
+	if acct.ID == active.ID {
+		if next := svc.State(); next.SignedIn {
+			forgetSyncedAccount(ctx, store, acct.ID)
+			fmt.Printf("the daemon now syncs %s; run googlysync resync once it is restarted\n", next.Account.Email)
+		}
+	}
+}
+
+// forgetSyncedAccount drops the index and changes-feed position the daemon
+// kept for the account it synced, so they are not applied when the sync
+// root is next handed back to it.
+func forgetSyncedAccount(ctx context.Context, store storage.Store, accountID string) {
+	if err := syncer.DropIndex(ctx, store, accountID); err != nil {
+		fmt.Fprintf(os.Stderr, "index reset failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// openAuth opens the store and the auth service over it, for commands
+// that manage accounts while the daemon is stopped.
+func openAuth(ctx context.Context, configPath string) (*auth.Service, storage.Store) {
+	_, store, svc := openOfflineAuth(ctx, configPath)
+	return svc, store
+}
@@ -12,27 +12,91 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/sandeepkv93/googlysync/internal/config"
-	"github.com/sandeepkv93/googlysync/internal/ipc"
 	ipcgen "github.com/sandeepkv93/googlysync/internal/ipc/gen"
 )
 
 var version = "dev"
 
 func main() {
-	if len(os.Args) < 2 {
-		runStatus(os.Args[1:])
+	args := parseGlobalFlags(os.Args[1:])
+	if len(args) == 0 {
+		runStatus(args)
 		return
 	}
 
-	switch os.Args[1] {
+	switch args[0] {
 	case "daemon":
-		runDaemon(os.Args[2:])
+		runDaemon(args[1:])
 	case "ping":
-		runPing(os.Args[2:])
+		runPing(args[1:])
 	case "status":
-		runStatus(os.Args[2:])
+		runStatus(args[1:])
 	case "fuse":
-		runFuse(os.Args[2:])
+		runFuse(args[1:])
+	case "stats":
+		runStats(args[1:])
+	case "detach":
+		runDetach(args[1:])
+	case "support-bundle":
+		runSupportBundle(args[1:])
+	case "versions":
+		runVersions(args[1:])
+	case "find":
+		runFind(args[1:])
+	case "notify-on-change":
+		runNotifyOnChange(args[1:])
+	case "hydrate":
+		runHydrate(args[1:])
+	case "pin":
+		runPin(args[1:])
+	case "snapshots":
+		runSnapshots(args[1:])
+	case "adopt":
+		runAdopt(args[1:])
+	case "resync":
+		runResync(args[1:])
+	case "verify":
+		runVerify(args[1:])
+	case "device":
+		runDevice(args[1:])
+	case "login":
+		runLogin(args[1:])
+	case "logout":
+		runLogout(args[1:])
+	case "accounts":
+		runAccounts(args[1:])
+	case "transfers":
+		runTransfers(args[1:])
+	case "export-index":
+		runExportIndex(args[1:])
+	case "import-index":
+		runImportIndex(args[1:])
+	case "ops":
+		runOps(args[1:])
+	case "audit":
+		runAudit(args[1:])
+	case "history":
+		runHistory(args[1:])
+	case "changes":
+		runChanges(args[1:])
+	case "pause":
+		runPause(args[1:])
+	case "resume":
+		runResume(args[1:])
+	case "remote":
+		runRemote(args[1:])
+	case "webhooks":
+		runWebhooks(args[1:])
+	case "encryption":
+		runEncryption(args[1:])
+	case "policy":
+		runPolicy(args[1:])
+	case "ignore":
+		runIgnore(args[1:])
+	case "db":
+		runDB(args[1:])
+	case "search":
+		runSearch(args[1:])
 	case "version":
 		fmt.Println(version)
 	case "help":
@@ -44,15 +108,48 @@ func main() {
 }
 
 func usage() {
-	fmt.Println("Usage: googlysync <command> [options]")
+	fmt.Println("Usage: googlysync [--remote HOST:PORT] <command> [options]")
 	fmt.Println("Commands:")
 	fmt.Println("  daemon   Start the sync daemon")
 	fmt.Println("  ping     Ping the daemon and print version")
 	fmt.Println("  status   Launch status TUI")
 	fmt.Println("  fuse     Placeholder for streaming mode")
+	fmt.Println("  stats    Export history (stats export), show buffer usage (stats buffers) or account totals (stats summary)")
+	fmt.Println("  detach   Release a folder whose remote access was lost")
+	fmt.Println("  support-bundle  Collect redacted logs and diagnostics")
+	fmt.Println("  versions List or restore local versions kept before overwrites")
+	fmt.Println("  find     Search synced files, optionally Drive too (--remote)")
+	fmt.Println("  search   Search the local index by name and path through the daemon")
+	fmt.Println("  notify-on-change  Alert when someone else edits a synced file")
+	fmt.Println("  hydrate  Download the content of on-demand placeholders")
+	fmt.Println("  pin      Keep a folder always available offline")
+	fmt.Println("  snapshots  List, take, or restore backup-mode snapshots")
+	fmt.Println("  adopt    Index an existing local copy of Drive, transferring only differences")
+	fmt.Println("  resync   Rebuild the index from Drive and the sync root without re-transferring matches")
+	fmt.Println("  verify   Re-hash synced files and compare them with the index and Drive")
+	fmt.Println("  device   Show or rename this machine's Drive device folder")
+	fmt.Println("  login    Sign in a Google account (--add for another one, --account to grant more access)")
+	fmt.Println("  logout   Sign an account out")
+	fmt.Println("  accounts List, switch or set the primary signed-in account, or diagnose tokens")
+	fmt.Println("  transfers  List, export, or import resumable transfers")
+	fmt.Println("  export-index  Write accounts, folders, files and sync state as JSON")
+	fmt.Println("  import-index  Load an index written by export-index")
+	fmt.Println("  ops      List pending operations or retry failed ones")
+	fmt.Println("  audit    Show what audit mode would have synced")
+	fmt.Println("  history  List finished uploads, downloads, moves, deletes and conflicts")
+	fmt.Println("  changes  List the changes read from the Drive changes feed")
+	fmt.Println("  pause    Pause syncing for an account")
+	fmt.Println("  resume   Resume a paused account")
+	fmt.Println("  remote   Manage tokens for remote clients (remote token)")
+	fmt.Println("  webhooks Add, list, or remove webhooks for sync milestones")
+	fmt.Println("  encryption  Create, export, or import the key content is encrypted with")
+	fmt.Println("  policy   Set, list, show, or clear per-folder sync policies")
+	fmt.Println("  ignore   Add, list, enable, disable, or remove stored ignore rules")
+	fmt.Println("  db       Show or roll back schema migrations, check and compact, or back up and restore the database")
 	fmt.Println("  version  Print CLI version")
 	fmt.Println("  help     Show this help")
 	fmt.Println("(No command opens the status TUI)")
+	fmt.Println("--remote manages a daemon on another machine over TLS; see remote_* config")
 }
 
 func runDaemon(args []string) {
@@ -104,7 +201,7 @@ func runPing(args []string) {
 		fmt.Printf("config error: %v\n", err)
 		return
 	}
-	conn, err := ipc.Dial(ctx, cfg.SocketPath)
+	conn, err := dialIPC(ctx, cfg)
 	if err != nil {
 		fmt.Printf("dial error: %v\n", err)
 		return
@@ -126,6 +223,7 @@ func runStatus(args []string) {
 	socketPath := fs.String("socket", "", "unix socket path")
 	interval := fs.Duration("interval", 2*time.Second, "refresh interval")
 	once := fs.Bool("once", false, "print status once and exit")
+	events := fs.Int("events", maxEventLines, "number of recent events to show")
 	_ = fs.Parse(args)
 
 	cfg, err := config.NewConfigWithOptions(config.Options{ConfigPath: *configPath, SocketPath: *socketPath})
@@ -135,21 +233,21 @@ func runStatus(args []string) {
 	}
 
 	if *once {
-		printStatusOnce(cfg.SocketPath)
+		printStatusOnce(cfg, *events)
 		return
 	}
 
-	m := newModel(cfg.SocketPath, *interval)
+	m := newModel(cfg, *interval, *events)
 	if _, err := tea.NewProgram(m).Run(); err != nil {
 		fmt.Printf("ui error: %v\n", err)
 	}
 }
 
-func printStatusOnce(socketPath string) {
+func printStatusOnce(cfg *config.Config, maxEvents int) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	conn, err := ipc.Dial(ctx, socketPath)
+	conn, err := dialIPC(ctx, cfg)
 	if err != nil {
 		fmt.Printf("dial error: %v\n", err)
 		return
@@ -157,7 +255,7 @@ func printStatusOnce(socketPath string) {
 	defer conn.Close()
 
 	client := ipcgen.NewSyncStatusServiceClient(conn)
-	resp, err := client.GetStatus(ctx, &ipcgen.GetStatusRequest{})
+	resp, err := client.GetStatus(ctx, &ipcgen.GetStatusRequest{MaxEvents: int32(maxEvents)})
 	if err != nil {
 		fmt.Printf("status error: %v\n", err)
 		return
@@ -167,6 +265,21 @@ func printStatusOnce(socketPath string) {
 		return
 	}
 	fmt.Printf("%s: %s\n", resp.Status.State.String(), resp.Status.Message)
+	if n := resp.Status.GetFailedOps(); n > 0 {
+		fmt.Printf("%d failed operations; see googlysync ops list\n", n)
+	}
+	if q := formatQuota(resp.Status.GetQuotaLimitBytes(), resp.Status.GetQuotaUsageBytes()); q != "" {
+		fmt.Printf("drive storage: %s\n", q)
+	}
+	for _, tr := range toTransferMsgs(resp.Status.GetTransfers()) {
+		fmt.Print(formatTransferLine(tr))
+	}
+	if maxEvents <= 0 {
+		return
+	}
+	for _, evt := range toEventMsgs(resp.Status.RecentEvents) {
+		fmt.Print(formatEventLine(evt))
+	}
 }
 
 func runFuse(args []string) {
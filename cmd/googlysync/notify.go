@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+func runNotifyOnChange(args []string) {
+	fs := flag.NewFlagSet("notify-on-change", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	account := fs.String("account", "", "account id the file is synced under (default: the synced account)")
+	remove := fs.Bool("remove", false, "stop watching the path")
+	list := fs.Bool("list", false, "list watched files")
+	_ = fs.Parse(args)
+
+	if !*list && fs.NArg() != 1 {
+		fmt.Println("Usage: googlysync notify-on-change [--account ID] [--remove] <path>")
+		fmt.Println("       googlysync notify-on-change --list")
+		os.Exit(2)
+	}
+
+	cfg, store, accountID := openOfflineAccount(*configPath, *account)
+	defer store.Close()
+
+	ctx := context.Background()
+	if *list {
+		watches, err := store.ListFileWatches(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "list failed: %v\n", err)
+			os.Exit(1)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ACCOUNT\tPATH\tVERSION\tCHECKED")
+		for _, w := range watches {
+			checked := "never"
+			if !w.CheckedAt.IsZero() {
+				checked = w.CheckedAt.Local().Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", w.AccountID, w.Path, w.LastVersion, checked)
+		}
+		_ = tw.Flush()
+		return
+	}
+
+	rel, err := syncRootRel(cfg, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	if *remove {
+		removed, err := store.DeleteFileWatch(ctx, accountID, rel)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "remove failed: %v\n", err)
+			os.Exit(1)
+		}
+		if !removed {
+			fmt.Printf("%s was not watched\n", rel)
+			return
+		}
+		fmt.Printf("stopped watching %s\n", rel)
+		return
+	}
+
+	rec, err := store.GetFileByPath(ctx, accountID, rel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lookup failed: %v\n", err)
+		os.Exit(1)
+	}
+	if rec == nil || rec.DriveID == "" {
+		fmt.Fprintf(os.Stderr, "%s is not synced to Drive yet\n", rel)
+		os.Exit(1)
+	}
+	if err := store.AddFileWatch(ctx, &storage.FileWatch{AccountID: accountID, Path: rel, DriveID: rec.DriveID}); err != nil {
+		fmt.Fprintf(os.Stderr, "watch failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("watching %s; the daemon will alert when someone else changes it\n", rel)
+}
+
+// syncRootRel converts a path given on the command line, absolute or relative
+// to the sync root, into a root-relative slash path.
+func syncRootRel(cfg *config.Config, arg string) (string, error) {
+	if filepath.IsAbs(arg) {
+		rel, err := filepath.Rel(cfg.SyncRoot, arg)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			return "", fmt.Errorf("%s is not inside the sync root %s", arg, cfg.SyncRoot)
+		}
+		arg = rel
+	}
+	return filepath.ToSlash(filepath.Clean(arg)), nil
+}
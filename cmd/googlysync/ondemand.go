@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/auth"
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/encryption"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+	syncer "github.com/sandeepkv93/googlysync/internal/sync"
+	"github.com/sandeepkv93/googlysync/internal/transfer"
+)
+
+func runHydrate(args []string) {
+	fs := flag.NewFlagSet("hydrate", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: googlysync hydrate <path>")
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	cfg, store, svc := openOfflineAuth(ctx, *configPath)
+	defer store.Close()
+	rel, err := syncRootRel(cfg, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	state := svc.State()
+	if !state.SignedIn {
+		fmt.Fprintln(os.Stderr, "hydrate failed: not signed in")
+		os.Exit(1)
+	}
+	client := driveapi.NewClient(svc.Client(state.Account.ID))
+	downloads, err := transfer.NewDownloader(zap.NewNop(), cfg, nil, nil, transfer.NewBufferPool(cfg), nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "downloader error: %v\n", err)
+		os.Exit(1)
+	}
+
+	done, err := syncer.Hydrate(ctx, cfg, store, downloads, client, encryption.NewKeys(cfg), state.Account.ID, rel)
+	for _, p := range done {
+		fmt.Printf("hydrated %s\n", p)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hydrate failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runPin(args []string) {
+	fs := flag.NewFlagSet("pin", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	remove := fs.Bool("remove", false, "unpin the folder")
+	list := fs.Bool("list", false, "list pinned folders")
+	_ = fs.Parse(args)
+
+	if !*list && fs.NArg() != 1 {
+		fmt.Println("Usage: googlysync pin [--remove] <folder>")
+		fmt.Println("       googlysync pin --list")
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	cfg, store, svc := openOfflineAuth(ctx, *configPath)
+	defer store.Close()
+	accountID := syncedAccount(svc, "")
+
+	if *list {
+		pins, err := store.ListPinnedFolders(ctx, accountID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "list failed: %v\n", err)
+			os.Exit(1)
+		}
+		for _, p := range pins {
+			fmt.Println(p)
+		}
+		return
+	}
+
+	rel, err := syncRootRel(cfg, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	if *remove {
+		removed, err := store.UnpinFolder(ctx, accountID, rel)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unpin failed: %v\n", err)
+			os.Exit(1)
+		}
+		if !removed {
+			fmt.Printf("%s was not pinned\n", rel)
+			return
+		}
+		fmt.Printf("unpinned %s; new files under it arrive as placeholders\n", rel)
+		return
+	}
+	queued, err := syncer.Pin(ctx, store, accountID, rel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pin failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("pinned %s; %d placeholder(s) queued for download\n", rel, queued)
+}
+
+// openOffline loads config and opens the database for commands that work
+// without the daemon. It exits on failure.
+func openOffline(configPath string) (*config.Config, storage.Store) {
+	cfg, store, _ := openOfflineAuth(context.Background(), configPath)
+	return cfg, store
+}
+
+// syncedAccount returns account when it is set, and otherwise the account
+// the daemon syncs: the active signed-in one, or the placeholder account
+// before anyone has signed in.
+func syncedAccount(svc *auth.Service, account string) string {
+	if account != "" {
+		return account
+	}
+	if state := svc.State(); state.SignedIn && state.Account.ID != "" {
+		return state.Account.ID
+	}
+	return syncer.DefaultAccountID
+}
+
+// offlineEngine returns an engine for an offline command. It syncs the same
+// account the daemon would. It exits on failure.
+func offlineEngine(cfg *config.Config, store storage.Store, svc *auth.Service) *syncer.Engine {
+	engine, err := syncer.NewEngine(zap.NewNop(), cfg, store, nil, nil, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sync error: %v\n", err)
+		os.Exit(1)
+	}
+	engine.SetAccount(syncedAccount(svc, ""))
+	return engine
+}
+
+// openOfflineAccount is openOffline that also resolves an --account flag
+// with syncedAccount.
+func openOfflineAccount(configPath, account string) (*config.Config, storage.Store, string) {
+	cfg, store, svc := openOfflineAuth(context.Background(), configPath)
+	return cfg, store, syncedAccount(svc, account)
+}
+
+// openOfflineAuth is openOffline that also returns the auth service. The
+// active account's own sync root replaces sync_root in the config.
+func openOfflineAuth(ctx context.Context, configPath string) (*config.Config, storage.Store, *auth.Service) {
+	cfg, err := config.NewConfigWithOptions(config.Options{ConfigPath: configPath})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+		os.Exit(1)
+	}
+	store, err := storage.Open(cfg, zap.NewNop())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "storage error: %v\n", err)
+		os.Exit(1)
+	}
+	svc, err := auth.NewService(ctx, zap.NewNop(), cfg, store)
+	if err != nil {
+		store.Close()
+		fmt.Fprintf(os.Stderr, "auth error: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.SyncRoot = svc.SyncRoot()
+	return cfg, store, svc
+}
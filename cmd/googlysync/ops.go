@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	ipcgen "github.com/sandeepkv93/googlysync/internal/ipc/gen"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// runOps lists journaled operations and retries the ones that failed.
+func runOps(args []string) {
+	if len(args) == 0 {
+		opsUsage()
+	}
+	switch args[0] {
+	case "list":
+		runOpsList(args[1:])
+	case "retry":
+		runOpsRetry(args[1:])
+	default:
+		opsUsage()
+	}
+}
+
+func runOpsList(args []string) {
+	fs := flag.NewFlagSet("ops list", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	account := fs.String("account", "", "account id (default: the synced account)")
+	state := fs.String("state", storage.OpFailed, "only ops in this state (queued, in_progress, failed; empty for all)")
+	_ = fs.Parse(args)
+
+	_, store, accountID := openOfflineAccount(*configPath, *account)
+	defer store.Close()
+
+	ops, err := store.ListPendingOps(context.Background(), accountID, *state, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "list failed: %v\n", err)
+		os.Exit(1)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEQ\tID\tOP\tSTATE\tRETRIES\tPATH\tWAITS FOR\tLAST ERROR")
+	for _, op := range ops {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", op.Seq, op.ID, op.OpType, op.State, op.RetryCount, op.Path, orDash(op.DependsOn), op.LastError)
+	}
+	_ = tw.Flush()
+}
+
+// runOpsRetry asks the daemon to queue failed ops again.
+func runOpsRetry(args []string) {
+	fs := flag.NewFlagSet("ops retry", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	socketPath := fs.String("socket", "", "unix socket path")
+	account := fs.String("account", "", "account id (default: the daemon's account)")
+	timeout := fs.Duration("timeout", 3*time.Second, "timeout for request")
+	_ = fs.Parse(args)
+	if fs.NArg() > 1 {
+		opsUsage()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	conn := dialDaemon(ctx, *configPath, *socketPath)
+	defer conn.Close()
+	client := ipcgen.NewDaemonControlServiceClient(conn)
+
+	resp, err := client.RetryFailedOps(ctx, &ipcgen.RetryFailedOpsRequest{AccountId: *account, OpId: fs.Arg(0)})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "retry failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("queued %d failed ops\n", resp.GetRetried())
+}
+
+func opsUsage() {
+	fmt.Println("Usage: googlysync ops list [--state STATE] | retry [OP_ID]")
+	os.Exit(2)
+}
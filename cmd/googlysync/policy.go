@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+	syncer "github.com/sandeepkv93/googlysync/internal/sync"
+)
+
+// runPolicy manages the sync policies attached to folders under the sync
+// root.
+func runPolicy(args []string) {
+	if len(args) == 0 {
+		policyUsage()
+	}
+	switch args[0] {
+	case "set":
+		runPolicySet(args[1:])
+	case "list":
+		runPolicyList(args[1:])
+	case "show":
+		runPolicyShow(args[1:])
+	case "clear":
+		runPolicyClear(args[1:])
+	default:
+		policyUsage()
+	}
+}
+
+// policyFolder resolves the folder argument; "." is the sync root itself.
+func policyFolder(cfg *config.Config, fs *flag.FlagSet) string {
+	if fs.NArg() != 1 {
+		policyUsage()
+	}
+	rel, err := syncRootRel(cfg, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if rel == "." {
+		return ""
+	}
+	return rel
+}
+
+func displayFolder(rel string) string {
+	if rel == "" {
+		return "."
+	}
+	return rel
+}
+
+func orDash(val string) string {
+	if val == "" {
+		return "-"
+	}
+	return val
+}
+
+func runPolicySet(args []string) {
+	fs := flag.NewFlagSet("policy set", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	account := fs.String("account", "", "account id the folder belongs to (default: the synced account)")
+	direction := fs.String("direction", "", "sync direction for the folder (empty inherits)")
+	var ignore []string
+	fs.Func("ignore", "glob pattern to leave unsynced; repeat for more (replaces the folder's list)", func(val string) error {
+		ignore = append(ignore, val)
+		return nil
+	})
+	exportFormat := fs.String("export-format", "", "file extension native Google files are exported as (empty inherits)")
+	bandwidth := fs.String("bandwidth", "", "bandwidth class: low, normal or high (empty inherits)")
+	_ = fs.Parse(args)
+
+	cfg, store, accountID := openOfflineAccount(*configPath, *account)
+	defer store.Close()
+	ctx := context.Background()
+	rel := policyFolder(cfg, fs)
+
+	policy, err := store.GetFolderPolicy(ctx, accountID, rel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "set failed: %v\n", err)
+		os.Exit(1)
+	}
+	if policy == nil {
+		policy = &storage.FolderPolicy{AccountID: accountID, Path: rel}
+	}
+	// Only the flags given change; the rest of the policy is kept.
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "direction":
+			policy.Direction = *direction
+		case "ignore":
+			policy.Ignore = ignore
+		case "export-format":
+			policy.ExportFormat = *exportFormat
+		case "bandwidth":
+			policy.BandwidthClass = *bandwidth
+		}
+	})
+	policy.UpdatedAt = time.Now()
+	if err := syncer.ValidateFolderPolicy(policy); err != nil {
+		fmt.Fprintf(os.Stderr, "set failed: %v\n", err)
+		os.Exit(1)
+	}
+	if err := store.SaveFolderPolicy(ctx, policy); err != nil {
+		fmt.Fprintf(os.Stderr, "set failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("updated the policy for %s\n", displayFolder(rel))
+}
+
+func runPolicyList(args []string) {
+	fs := flag.NewFlagSet("policy list", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	account := fs.String("account", "", "account id whose policies to list (default: the synced account)")
+	_ = fs.Parse(args)
+
+	_, store, accountID := openOfflineAccount(*configPath, *account)
+	defer store.Close()
+
+	policies, err := store.ListFolderPolicies(context.Background(), accountID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "list failed: %v\n", err)
+		os.Exit(1)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FOLDER\tDIRECTION\tIGNORE\tEXPORT\tBANDWIDTH")
+	for _, p := range policies {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", displayFolder(p.Path), orDash(p.Direction), orDash(strings.Join(p.Ignore, " ")), orDash(p.ExportFormat), orDash(p.BandwidthClass))
+	}
+	_ = tw.Flush()
+}
+
+func runPolicyShow(args []string) {
+	fs := flag.NewFlagSet("policy show", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	account := fs.String("account", "", "account id the path belongs to (default: the synced account)")
+	_ = fs.Parse(args)
+
+	cfg, store, accountID := openOfflineAccount(*configPath, *account)
+	defer store.Close()
+	rel := policyFolder(cfg, fs)
+
+	direction, err := syncer.ParseDirection(cfg.SyncDirection)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+		os.Exit(1)
+	}
+	policies, err := store.ListFolderPolicies(context.Background(), accountID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "show failed: %v\n", err)
+		os.Exit(1)
+	}
+	base := syncer.FolderSettings{Direction: direction, BandwidthClass: syncer.BandwidthNormal}
+	settings, err := syncer.ResolveFolderSettings(base, policies, rel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "show failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("path:       %s\n", displayFolder(rel))
+	fmt.Printf("direction:  %s\n", settings.Direction)
+	fmt.Printf("export:     %s\n", orDash(settings.ExportFormat))
+	fmt.Printf("bandwidth:  %s\n", settings.BandwidthClass)
+	for _, rule := range settings.Ignore {
+		fmt.Printf("ignore:     %s (from %s)\n", rule.Pattern, displayFolder(rule.Dir))
+	}
+	for _, rule := range settings.Invalid {
+		fmt.Fprintf(os.Stderr, "ignore pattern %q from %s no longer parses and is skipped\n", rule.Pattern, displayFolder(rule.Dir))
+	}
+	info, err := os.Stat(filepath.Join(cfg.SyncRoot, filepath.FromSlash(rel)))
+	if pattern := settings.Ignores(rel, err == nil && info.IsDir()); pattern != "" {
+		fmt.Printf("%s is ignored by %q\n", displayFolder(rel), pattern)
+	}
+}
+
+func runPolicyClear(args []string) {
+	fs := flag.NewFlagSet("policy clear", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	account := fs.String("account", "", "account id the folder belongs to (default: the synced account)")
+	_ = fs.Parse(args)
+
+	cfg, store, accountID := openOfflineAccount(*configPath, *account)
+	defer store.Close()
+	rel := policyFolder(cfg, fs)
+	if err := store.DeleteFolderPolicy(context.Background(), accountID, rel); err != nil {
+		fmt.Fprintf(os.Stderr, "clear failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("cleared the policy for %s\n", displayFolder(rel))
+}
+
+func policyUsage() {
+	fmt.Println("Usage: googlysync policy set [--direction D] [--ignore PATTERN]... [--export-format EXT] [--bandwidth CLASS] <folder>")
+	fmt.Println("       googlysync policy list | show <path> | clear <folder>")
+	os.Exit(2)
+}
@@ -6,22 +6,235 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/sandeepkv93/googlysync/internal/auth"
+	"github.com/sandeepkv93/googlysync/internal/cache"
 	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/device"
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/encryption"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/ipc"
+	"github.com/sandeepkv93/googlysync/internal/notify"
 	"github.com/sandeepkv93/googlysync/internal/status"
 	"github.com/sandeepkv93/googlysync/internal/storage"
 	syncer "github.com/sandeepkv93/googlysync/internal/sync"
+	"github.com/sandeepkv93/googlysync/internal/transfer"
+	"github.com/sandeepkv93/googlysync/internal/versions"
 )
 
-func newStatusStore(cfg *config.Config) *status.Store {
+func newStatusStore(cfg *config.Config, logger *zap.Logger, db storage.Store) *status.Store {
 	store := status.NewStore()
 	store.SetMaxEvents(cfg.EventLogSize)
+	if !cfg.PersistEvents {
+		return store
+	}
+
+	persisted, err := db.ListStatusEvents(context.Background(), cfg.EventLogSize)
+	if err != nil {
+		logger.Warn("load persisted events failed", zap.Error(err))
+	}
+	events := make([]status.Event, 0, len(persisted))
+	for _, evt := range persisted {
+		events = append(events, status.Event{Seq: evt.Seq, Op: evt.Op, Path: evt.Path, Root: evt.Root, When: evt.OccurredAt})
+	}
+	store.RestoreEvents(events)
+	store.SetEventLog(&persistedEventLog{logger: logger, db: db, keep: cfg.EventLogSize})
 	return store
 }
 
+// persistedEventLog writes status events to the bounded status_events table.
+type persistedEventLog struct {
+	logger *zap.Logger
+	db     storage.Store
+	keep   int
+}
+
+func (l *persistedEventLog) AppendEvent(evt status.Event) {
+	rec := &storage.StatusEvent{Seq: evt.Seq, Op: evt.Op, Path: evt.Path, Root: evt.Root, OccurredAt: evt.When}
+	if err := l.db.AddStatusEvent(context.Background(), rec, l.keep); err != nil {
+		l.logger.Warn("persist event failed", zap.Error(err))
+	}
+}
+
+func newOpExecutor(logger *zap.Logger, engine *syncer.Engine, authSvc *auth.Service) *syncer.Executor {
+	return syncer.NewExecutor(logger, engine, syncRemotes(authSvc, engine))
+}
+
+func newGarbageCollector(logger *zap.Logger, engine *syncer.Engine, authSvc *auth.Service, versionStore *versions.Store, contentCache *cache.Store) *syncer.GarbageCollector {
+	return syncer.NewGarbageCollector(logger, engine, syncRemotes(authSvc, engine), versionStore, contentCache)
+}
+
+func newChangePoller(logger *zap.Logger, engine *syncer.Engine, authSvc *auth.Service) *syncer.ChangePoller {
+	return syncer.NewChangePoller(logger, engine, syncRemotes(authSvc, engine))
+}
+
+func newChangePush(logger *zap.Logger, engine *syncer.Engine, poller *syncer.ChangePoller, authSvc *auth.Service) *syncer.ChangePush {
+	return syncer.NewChangePush(logger, poller, syncRemotes(authSvc, engine))
+}
+
+func newTokenPreRefresher(logger *zap.Logger, engine *syncer.Engine, store storage.Store, authSvc *auth.Service) *auth.PreRefresher {
+	return auth.NewPreRefresher(logger, authSvc, busyAccounts(engine, store, authSvc))
+}
+
+// busyAccounts reports the synced account while it has ops queued or in
+// progress.
+func busyAccounts(engine *syncer.Engine, store storage.Store, authSvc *auth.Service) auth.BusyFunc {
+	return func(ctx context.Context) []string {
+		if !syncsSignedIn(authSvc, engine) {
+			return nil
+		}
+		for _, opState := range []string{storage.OpQueued, storage.OpInProgress} {
+			if n, err := store.CountPendingOps(ctx, engine.AccountID(), opState); err == nil && n > 0 {
+				return []string{engine.AccountID()}
+			}
+		}
+		return nil
+	}
+}
+
+// syncRemotes returns a Drive client for the account the engine syncs, or
+// nil once another account has become the active one; that one is synced
+// after a restart.
+func syncRemotes(authSvc *auth.Service, engine *syncer.Engine) syncer.RemoteFunc {
+	return func(ctx context.Context) syncer.RemoteFiles {
+		if !syncsSignedIn(authSvc, engine) {
+			return nil
+		}
+		return driveapi.NewClient(authSvc.Client(engine.AccountID()))
+	}
+}
+
+// syncsSignedIn reports whether the engine syncs the active signed-in
+// account.
+func syncsSignedIn(authSvc *auth.Service, engine *syncer.Engine) bool {
+	state := authSvc.State()
+	return state.SignedIn && state.Account.ID != "" && state.Account.ID == engine.AccountID()
+}
+
 func newSyncQueue(logger *zap.Logger, cfg *config.Config) *syncer.Queue {
 	return syncer.NewQueue(logger, cfg.SyncQueueSize)
 }
 
-func newAuthService(logger *zap.Logger, cfg *config.Config, store *storage.Storage) (*auth.Service, error) {
-	return auth.NewService(context.Background(), logger, cfg, store)
+func newAuthService(logger *zap.Logger, cfg *config.Config, store storage.Store, webhooks *notify.Webhooks) (*auth.Service, error) {
+	svc, err := auth.NewService(context.Background(), logger, cfg, store)
+	if err != nil {
+		return nil, err
+	}
+	svc.OnAuthExpired(func(accountID string, err error) {
+		webhooks.Fire(accountID, notify.EventAuthExpired, map[string]any{"error": err.Error()})
+	})
+	// The engine, watchers and transfers are built after this and mirror the
+	// active account into its own sync root.
+	cfg.SyncRoot = svc.SyncRoot()
+	return svc, nil
+}
+
+// newSyncEngine constructs the engine for the active account, points its
+// milestones at the webhooks, reports its uploads in the progress registry
+// and gives it the keyring's encryption keys.
+func newSyncEngine(
+	logger *zap.Logger,
+	cfg *config.Config,
+	store storage.Store,
+	statusStore *status.Store,
+	queue *syncer.Queue,
+	downloads *transfer.Downloader,
+	progress *transfer.Progress,
+	webhooks *notify.Webhooks,
+	authSvc *auth.Service,
+) (*syncer.Engine, error) {
+	engine, err := syncer.NewEngine(logger, cfg, store, statusStore, queue, downloads)
+	if err != nil {
+		return nil, err
+	}
+	// Accounts are keyed by their OAuth subject; the engine keeps syncing
+	// this one until the daemon restarts.
+	if state := authSvc.State(); state.SignedIn {
+		engine.SetAccount(state.Account.ID)
+	}
+	engine.Webhooks = webhooks
+	engine.Progress = progress
+	engine.Keys = encryption.NewKeys(cfg)
+	if cfg.EncryptContent || cfg.EncryptNames {
+		if _, err := engine.Keys.Key(engine.AccountID()); err != nil {
+			logger.Warn("encryption is on but the account has no usable key; run googlysync encryption init or import", zap.Error(err))
+		}
+	}
+	return engine, nil
+}
+
+// syncController serves IPC sync control from the engine, adding the Drive
+// client and root folder a resync crawls.
+type syncController struct {
+	*syncer.Engine
+	cfg   *config.Config
+	store storage.Store
+	auth  *auth.Service
+}
+
+func newSyncController(engine *syncer.Engine, cfg *config.Config, store storage.Store, authSvc *auth.Service) ipc.SyncController {
+	return &syncController{Engine: engine, cfg: cfg, store: store, auth: authSvc}
+}
+
+func (c *syncController) Resync(ctx context.Context, accountID string) (*ipc.ResyncResult, error) {
+	if accountID == "" {
+		accountID = c.AccountID()
+	}
+	if accountID != c.AccountID() {
+		return nil, errs.New(errs.ErrInvalidArgument, "the daemon syncs account %q, not %q", c.AccountID(), accountID)
+	}
+	if !syncsSignedIn(c.auth, c.Engine) {
+		return nil, errs.New(errs.ErrAuthExpired, "%s is not the signed-in account", accountID)
+	}
+	rootID, err := syncer.DriveRootID(ctx, c.cfg, c.store, accountID)
+	if err != nil {
+		return nil, err
+	}
+	client := driveapi.NewClient(c.auth.Client(accountID))
+	report, err := c.Engine.Resync(ctx, client, rootID)
+	if err != nil {
+		return nil, err
+	}
+	return &ipc.ResyncResult{
+		AccountID: accountID,
+		Adopted:   report.Adopted,
+		Uploads:   report.Uploads,
+		Downloads: report.Downloads,
+		Skipped:   report.Skipped,
+	}, nil
+}
+
+// DropIndex pauses the engine so nothing is synced for the account that
+// replaces the signed-out one before the daemon restarts, then drops the
+// index.
+func (c *syncController) DropIndex(ctx context.Context) error {
+	if _, err := c.Pause(ctx, ""); err != nil {
+		return err
+	}
+	return syncer.DropIndex(ctx, c.store, c.AccountID())
+}
+
+func newFileWatcher(logger *zap.Logger, cfg *config.Config, store storage.Store, authSvc *auth.Service) *notify.FileWatcher {
+	clients := func(ctx context.Context, accountID string) notify.FileGetter {
+		ref, err := store.GetTokenRef(ctx, accountID)
+		if err != nil || ref == nil {
+			return nil
+		}
+		return driveapi.NewClient(authSvc.Client(accountID))
+	}
+	return notify.NewFileWatcher(logger, cfg, store, clients, notify.NewDesktop())
+}
+
+func newDeviceRegistrar(logger *zap.Logger, cfg *config.Config, store storage.Store, authSvc *auth.Service) *device.Registrar {
+	return device.NewRegistrar(logger, cfg, store, deviceClients(authSvc))
+}
+
+// deviceClients registers the device under the signed-in account.
+func deviceClients(authSvc *auth.Service) device.ClientFunc {
+	return func(ctx context.Context) (string, device.FolderClient) {
+		state := authSvc.State()
+		if !state.SignedIn || state.Account.ID == "" {
+			return "", nil
+		}
+		return state.Account.ID, driveapi.NewClient(authSvc.Client(state.Account.ID))
+	}
 }
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/ipc"
+)
+
+// remoteAddr is the daemon address given with the global --remote flag.
+// When set, commands that talk to the daemon dial it over TLS instead of the
+// local socket.
+var remoteAddr string
+
+// parseGlobalFlags consumes the flags that may precede the command.
+func parseGlobalFlags(args []string) []string {
+	for len(args) > 0 {
+		switch {
+		case args[0] == "--remote" && len(args) > 1:
+			remoteAddr, args = args[1], args[2:]
+		case strings.HasPrefix(args[0], "--remote="):
+			remoteAddr, args = strings.TrimPrefix(args[0], "--remote="), args[1:]
+		default:
+			return args
+		}
+	}
+	return args
+}
+
+// dialIPC connects to the local daemon, or to the one named by --remote.
+func dialIPC(ctx context.Context, cfg *config.Config) (*grpc.ClientConn, error) {
+	if remoteAddr != "" {
+		return ipc.DialRemote(remoteAddr, cfg)
+	}
+	return ipc.Dial(ctx, cfg.SocketPath)
+}
+
+// runRemote manages the tokens remote clients use to reach this machine's
+// daemon.
+func runRemote(args []string) {
+	if len(args) < 2 || args[0] != "token" {
+		remoteUsage()
+	}
+	switch args[1] {
+	case "create":
+		runRemoteTokenCreate(args[2:])
+	case "list":
+		runRemoteTokenList(args[2:])
+	case "revoke":
+		runRemoteTokenRevoke(args[2:])
+	default:
+		remoteUsage()
+	}
+}
+
+func runRemoteTokenCreate(args []string) {
+	fs := flag.NewFlagSet("remote token create", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	name := fs.String("name", "", "label for the client, e.g. laptop")
+	role := fs.String("role", ipc.RoleRead, "role granted: read or admin")
+	_ = fs.Parse(args)
+
+	_, store := openOffline(*configPath)
+	defer store.Close()
+
+	id, token, err := ipc.IssueRemoteToken(context.Background(), store, *name, *role)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "create failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("created %s token %s\n", *role, id)
+	fmt.Println("Set this as remote_token on the client. It is not shown again:")
+	fmt.Println(token)
+}
+
+func runRemoteTokenList(args []string) {
+	fs := flag.NewFlagSet("remote token list", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	_ = fs.Parse(args)
+
+	_, store := openOffline(*configPath)
+	defer store.Close()
+
+	tokens, err := store.ListRemoteTokens(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "list failed: %v\n", err)
+		os.Exit(1)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tROLE\tCREATED")
+	for _, tok := range tokens {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", tok.ID, tok.Name, tok.Role, tok.CreatedAt.Local().Format(time.RFC3339))
+	}
+	_ = tw.Flush()
+}
+
+func runRemoteTokenRevoke(args []string) {
+	fs := flag.NewFlagSet("remote token revoke", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		remoteUsage()
+	}
+
+	_, store := openOffline(*configPath)
+	defer store.Close()
+
+	if err := store.DeleteRemoteToken(context.Background(), fs.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "revoke failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("revoked %s\n", fs.Arg(0))
+}
+
+func remoteUsage() {
+	fmt.Println("Usage: googlysync remote token create [--name NAME] [--role read|admin] | list | revoke TOKEN_ID")
+	os.Exit(2)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	ipcgen "github.com/sandeepkv93/googlysync/internal/ipc/gen"
+	syncer "github.com/sandeepkv93/googlysync/internal/sync"
+)
+
+// runResync rebuilds the index from a fresh crawl, through the daemon or,
+// with --offline, in this process while the daemon is stopped.
+func runResync(args []string) {
+	fs := flag.NewFlagSet("resync", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	socketPath := fs.String("socket", "", "unix socket path")
+	account := fs.String("account", "", "account id (default: the daemon's account)")
+	offline := fs.Bool("offline", false, "rebuild in this process; the daemon must not be running")
+	timeout := fs.Duration("timeout", 30*time.Minute, "how long to wait for the crawl")
+	_ = fs.Parse(args)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var resp *ipcgen.ResyncResponse
+	if *offline {
+		resp = resyncOffline(ctx, *configPath)
+	} else {
+		conn := dialDaemon(ctx, *configPath, *socketPath)
+		defer conn.Close()
+		var err error
+		resp, err = ipcgen.NewDaemonControlServiceClient(conn).Resync(ctx, &ipcgen.ResyncRequest{AccountId: *account})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "resync failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	for _, skipped := range resp.GetSkipped() {
+		fmt.Printf("skipped %s\n", skipped)
+	}
+	fmt.Printf("rebuilt index for %s: %d file(s) already in sync; queued %d upload(s) and %d download(s)\n",
+		resp.GetAccountId(), resp.GetAdopted(), resp.GetUploads(), resp.GetDownloads())
+}
+
+func resyncOffline(ctx context.Context, configPath string) *ipcgen.ResyncResponse {
+	cfg, store, svc := openOfflineAuth(ctx, configPath)
+	defer store.Close()
+
+	state := svc.State()
+	if !state.SignedIn || state.Account.ID == "" {
+		fmt.Fprintln(os.Stderr, "not signed in")
+		os.Exit(1)
+	}
+	rootID, err := syncer.DriveRootID(ctx, cfg, store, state.Account.ID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "drive root: %v\n", err)
+		os.Exit(1)
+	}
+	client := driveapi.NewClient(svc.Client(state.Account.ID))
+
+	engine := offlineEngine(cfg, store, svc)
+	report, err := engine.Resync(ctx, client, rootID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "resync failed: %v\n", err)
+		os.Exit(1)
+	}
+	return &ipcgen.ResyncResponse{
+		AccountId: engine.AccountID(),
+		Adopted:   int32(report.Adopted),
+		Uploads:   int32(report.Uploads),
+		Downloads: int32(report.Downloads),
+		Skipped:   report.Skipped,
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	ipcgen "github.com/sandeepkv93/googlysync/internal/ipc/gen"
+	"github.com/sandeepkv93/googlysync/internal/search"
+)
+
+// runSearch looks names and paths up in the local index through the daemon,
+// or with --offline straight from the database.
+func runSearch(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	socketPath := fs.String("socket", "", "unix socket path")
+	account := fs.String("account", "", "limit results to one account id")
+	limit := fs.Int("limit", 50, "maximum results")
+	offline := fs.Bool("offline", false, "read the database directly instead of asking the daemon")
+	timeout := fs.Duration("timeout", 5*time.Second, "timeout for request")
+	_ = fs.Parse(args)
+
+	query := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(query) == "" {
+		fmt.Println("Usage: googlysync search [--account ID] [--limit N] [--offline] <terms>")
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var hits []*ipcgen.SearchHit
+	if *offline {
+		hits = searchOffline(ctx, *configPath, query, *account, *limit)
+	} else {
+		conn := dialDaemon(ctx, *configPath, *socketPath)
+		defer conn.Close()
+		resp, err := ipcgen.NewSearchServiceClient(conn).Search(ctx, &ipcgen.SearchRequest{
+			AccountId: *account,
+			Query:     query,
+			Limit:     int32(*limit),
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "search failed: %v\n", err)
+			os.Exit(1)
+		}
+		hits = resp.GetHits()
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATE\tACCOUNT\tSIZE\tPATH")
+	for _, h := range hits {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", h.GetState(), h.GetAccountId(), h.GetSize(), h.GetPath())
+	}
+	_ = tw.Flush()
+}
+
+func searchOffline(ctx context.Context, configPath, query, account string, limit int) []*ipcgen.SearchHit {
+	_, store := openOffline(configPath)
+	defer store.Close()
+
+	results, _, err := search.NewSearcher(store).Find(ctx, query, search.Options{Limit: limit, AccountID: account})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "search failed: %v\n", err)
+		os.Exit(1)
+	}
+	hits := make([]*ipcgen.SearchHit, 0, len(results))
+	for _, r := range results {
+		hits = append(hits, &ipcgen.SearchHit{AccountId: r.AccountID, Path: r.Path, DriveId: r.DriveID, Size: r.Size, State: r.State})
+	}
+	return hits
+}
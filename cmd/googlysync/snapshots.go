@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	syncer "github.com/sandeepkv93/googlysync/internal/sync"
+)
+
+func runSnapshots(args []string) {
+	if len(args) == 0 {
+		snapshotsUsage()
+	}
+	sub := args[0]
+
+	fs := flag.NewFlagSet("snapshots "+sub, flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	dest := fs.String("to", "", "restore under this folder instead of the original paths (restore only)")
+	_ = fs.Parse(args[1:])
+
+	ctx := context.Background()
+	cfg, store, svc := openOfflineAuth(ctx, *configPath)
+	defer store.Close()
+	accountID := syncedAccount(svc, "")
+
+	switch sub {
+	case "list":
+		snaps, err := store.ListSnapshots(ctx, accountID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "list failed: %v\n", err)
+			os.Exit(1)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tCREATED\tSTATE")
+		for _, snap := range snaps {
+			state := "uploading"
+			if !snap.CompletedAt.IsZero() {
+				state = "complete"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", snap.Name, snap.CreatedAt.Local().Format(time.RFC3339), state)
+		}
+		_ = tw.Flush()
+	case "take":
+		snap, err := offlineEngine(cfg, store, svc).Snapshot(ctx, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "snapshot failed: %v\n", err)
+			os.Exit(1)
+		}
+		if snap == nil {
+			fmt.Println("nothing changed since the last snapshot")
+			return
+		}
+		fmt.Printf("planned snapshot %s\n", snap.Name)
+	case "show":
+		if fs.NArg() < 1 {
+			snapshotsUsage()
+		}
+		snap, err := store.GetSnapshot(ctx, accountID, fs.Arg(0))
+		if err != nil || snap == nil {
+			fmt.Fprintf(os.Stderr, "snapshot %s not found\n", fs.Arg(0))
+			os.Exit(1)
+		}
+		entries, err := store.ListSnapshotEntries(ctx, snap.ID, fs.Arg(1))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "show failed: %v\n", err)
+			os.Exit(1)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "PATH\tSIZE\tACTION\tMODIFIED")
+		for _, entry := range entries {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", entry.Path, entry.Size, entry.Action, entry.ModifiedAt.Local().Format(time.RFC3339))
+		}
+		_ = tw.Flush()
+	case "restore":
+		if fs.NArg() < 1 {
+			snapshotsUsage()
+		}
+		n, err := syncer.RestoreSnapshot(ctx, store, accountID, fs.Arg(0), fs.Arg(1), *dest)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "restore failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("queued %d file(s) from %s for restore\n", n, fs.Arg(0))
+	default:
+		snapshotsUsage()
+	}
+}
+
+func snapshotsUsage() {
+	fmt.Println("Usage: googlysync snapshots list | take | show <name> [path] | restore [--to folder] <name> [path]")
+	os.Exit(2)
+}
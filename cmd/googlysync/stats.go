@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	ipcgen "github.com/sandeepkv93/googlysync/internal/ipc/gen"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+type statsExport struct {
+	Transfers []transferRow `json:"transfers"`
+	Errors    []errorRow    `json:"errors"`
+}
+
+type transferRow struct {
+	AccountID  string `json:"account_id"`
+	Path       string `json:"path"`
+	Direction  string `json:"direction"`
+	Bytes      int64  `json:"bytes"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	StartedAt  string `json:"started_at,omitempty"`
+	FinishedAt string `json:"finished_at"`
+}
+
+type errorRow struct {
+	AccountID  string `json:"account_id"`
+	Path       string `json:"path"`
+	Op         string `json:"op"`
+	Message    string `json:"message"`
+	OccurredAt string `json:"occurred_at"`
+}
+
+func runStats(args []string) {
+	if len(args) > 0 && args[0] == "buffers" {
+		runStatsBuffers(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "summary" {
+		runStatsSummary(args[1:])
+		return
+	}
+	if len(args) == 0 || args[0] != "export" {
+		fmt.Println("Usage: googlysync stats export [--from T] [--to T] [--format csv|json] [--out FILE]")
+		fmt.Println("       googlysync stats buffers [--socket PATH]")
+		fmt.Println("       googlysync stats summary [--account ID]")
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("stats export", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	fromStr := fs.String("from", "", "start time (RFC3339 or YYYY-MM-DD)")
+	toStr := fs.String("to", "", "end time (RFC3339 or YYYY-MM-DD)")
+	format := fs.String("format", "csv", "output format: csv or json")
+	outPath := fs.String("out", "", "output file (default stdout)")
+	_ = fs.Parse(args[1:])
+
+	from, err := parseTimeFlag(*fromStr, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --from: %v\n", err)
+		os.Exit(2)
+	}
+	to, err := parseTimeFlag(*toStr, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --to: %v\n", err)
+		os.Exit(2)
+	}
+	if *format != "csv" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "unsupported format: %s\n", *format)
+		os.Exit(2)
+	}
+
+	cfg, err := config.NewConfigWithOptions(config.Options{ConfigPath: *configPath})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+		os.Exit(1)
+	}
+	store, err := storage.Open(cfg, zap.NewNop())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "storage error: %v\n", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	export, err := loadStatsExport(context.Background(), store, from, to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var out io.Writer = os.Stdout
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "open output: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if *format == "json" {
+		err = writeStatsJSON(out, export)
+	} else {
+		err = writeStatsCSV(out, export)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "write error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runStatsBuffers prints the daemon's transfer buffer pool usage.
+func runStatsBuffers(args []string) {
+	fs := flag.NewFlagSet("stats buffers", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	socketPath := fs.String("socket", "", "unix socket path")
+	timeout := fs.Duration("timeout", 3*time.Second, "timeout for request")
+	_ = fs.Parse(args)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	cfg, err := config.NewConfigWithOptions(config.Options{ConfigPath: *configPath, SocketPath: *socketPath})
+	if err != nil {
+		fmt.Printf("config error: %v\n", err)
+		return
+	}
+	conn, err := dialIPC(ctx, cfg)
+	if err != nil {
+		fmt.Printf("dial error: %v\n", err)
+		return
+	}
+	defer conn.Close()
+
+	resp, err := ipcgen.NewStatsServiceClient(conn).GetStats(ctx, &ipcgen.GetStatsRequest{})
+	if err != nil {
+		fmt.Printf("stats error: %v\n", err)
+		return
+	}
+	b := resp.GetBuffers()
+	fmt.Printf("buffer size:  %d bytes\n", b.GetBufferSize())
+	fmt.Printf("budget:       %d bytes\n", b.GetBudgetBytes())
+	fmt.Printf("in use:       %d (peak %d)\n", b.GetInUse(), b.GetPeakInUse())
+	fmt.Printf("acquired:     %d\n", b.GetAcquired())
+	fmt.Printf("waits:        %d\n", b.GetWaits())
+}
+
+// runStatsSummary prints each account's index and queue totals.
+func runStatsSummary(args []string) {
+	fs := flag.NewFlagSet("stats summary", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	account := fs.String("account", "", "only summarize this account id")
+	_ = fs.Parse(args)
+
+	_, store := openOffline(*configPath)
+	defer store.Close()
+	ctx := context.Background()
+
+	ids := []string{*account}
+	if *account == "" {
+		accounts, err := store.ListAccounts(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "summary failed: %v\n", err)
+			os.Exit(1)
+		}
+		ids = ids[:0]
+		for _, acct := range accounts {
+			ids = append(ids, acct.ID)
+		}
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCOUNT\tFILES\tFOLDERS\tBYTES\tQUEUED\tRUNNING\tFAILED\tLAST SYNC\tLAST ERROR")
+	for _, id := range ids {
+		sum, err := store.AccountSummary(ctx, id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "summary failed: %v\n", err)
+			os.Exit(1)
+		}
+		lastSync := "-"
+		if !sum.LastSyncAt.IsZero() {
+			lastSync = sum.LastSyncAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n", sum.AccountID, sum.Files, sum.Folders, sum.Bytes,
+			sum.QueuedOps, sum.RunningOps, sum.FailedOps, lastSync, orDash(sum.LastError))
+	}
+	_ = tw.Flush()
+}
+
+func loadStatsExport(ctx context.Context, store storage.Store, from, to time.Time) (statsExport, error) {
+	transfers, err := store.ListTransfers(ctx, from, to)
+	if err != nil {
+		return statsExport{}, err
+	}
+	errs, err := store.ListErrors(ctx, from, to)
+	if err != nil {
+		return statsExport{}, err
+	}
+
+	export := statsExport{
+		Transfers: make([]transferRow, 0, len(transfers)),
+		Errors:    make([]errorRow, 0, len(errs)),
+	}
+	for _, rec := range transfers {
+		export.Transfers = append(export.Transfers, transferRow{
+			AccountID:  rec.AccountID,
+			Path:       rec.Path,
+			Direction:  rec.Direction,
+			Bytes:      rec.Bytes,
+			Status:     rec.Status,
+			Error:      rec.Error,
+			StartedAt:  formatExportTime(rec.StartedAt),
+			FinishedAt: formatExportTime(rec.FinishedAt),
+		})
+	}
+	for _, rec := range errs {
+		export.Errors = append(export.Errors, errorRow{
+			AccountID:  rec.AccountID,
+			Path:       rec.Path,
+			Op:         rec.Op,
+			Message:    rec.Message,
+			OccurredAt: formatExportTime(rec.OccurredAt),
+		})
+	}
+	return export, nil
+}
+
+func writeStatsJSON(w io.Writer, export statsExport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(export)
+}
+
+// writeStatsCSV flattens both tables into one sheet keyed by the kind column.
+func writeStatsCSV(w io.Writer, export statsExport) error {
+	cw := csv.NewWriter(w)
+	header := []string{"kind", "account_id", "path", "op", "bytes", "status", "message", "started_at", "finished_at"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range export.Transfers {
+		record := []string{"transfer", row.AccountID, row.Path, row.Direction, strconv.FormatInt(row.Bytes, 10), row.Status, row.Error, row.StartedAt, row.FinishedAt}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	for _, row := range export.Errors {
+		record := []string{"error", row.AccountID, row.Path, row.Op, "", "error", row.Message, "", row.OccurredAt}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// parseTimeFlag accepts RFC3339 or a bare date; bare end dates cover the whole day.
+func parseTimeFlag(val string, endOfDay bool) (time.Time, error) {
+	if val == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, val); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", val, time.Local)
+	if err != nil {
+		return time.Time{}, errors.New("expected RFC3339 or YYYY-MM-DD")
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t, nil
+}
+
+func formatExportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+	"github.com/sandeepkv93/googlysync/internal/support"
+)
+
+func runSupportBundle(args []string) {
+	fs := flag.NewFlagSet("support-bundle", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	outPath := fs.String("out", "", "output tarball (default googlysync-support-<time>.tar.gz)")
+	hashPaths := fs.Bool("hash-paths", false, "replace file paths with stable hashes")
+	logFiles := fs.Int("log-files", 3, "number of recent log files to include")
+	_ = fs.Parse(args)
+
+	cfg, err := config.NewConfigWithOptions(config.Options{ConfigPath: *configPath})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+		os.Exit(1)
+	}
+
+	store, err := storage.Open(cfg, zap.NewNop())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "storage unavailable, bundling without database: %v\n", err)
+		store = nil
+	} else {
+		defer store.Close()
+	}
+
+	if *outPath == "" {
+		*outPath = fmt.Sprintf("googlysync-support-%s.tar.gz", time.Now().Format("20060102-150405"))
+	}
+	f, err := os.OpenFile(*outPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open output: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	opts := support.Options{Version: version, HashPaths: *hashPaths, MaxLogFiles: *logFiles}
+	if err := support.WriteBundle(context.Background(), f, cfg, store, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "bundle failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("support bundle written to %s\n", *outPath)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/transfer"
+)
+
+// runTransfers lists resumable transfers and moves them between machines.
+func runTransfers(args []string) {
+	if len(args) == 0 {
+		transfersUsage()
+	}
+	sub := args[0]
+
+	fs := flag.NewFlagSet("transfers "+sub, flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	out := fs.String("out", "", "write the manifest to this file instead of stdout (export only)")
+	_ = fs.Parse(args[1:])
+
+	cfg, store := openOffline(*configPath)
+	defer store.Close()
+
+	ctx := context.Background()
+	switch sub {
+	case "list":
+		sessions, err := store.ListTransferSessions(ctx, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "list failed: %v\n", err)
+			os.Exit(1)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ACCOUNT\tDIRECTION\tPATH\tDONE\tSIZE")
+		for _, s := range sessions {
+			done := s.Offset
+			if s.ChunkSize > 0 {
+				done = min(int64(len(s.Chunks))*s.ChunkSize, s.Size)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\n", s.AccountID, s.Direction, s.Path, done, s.Size)
+		}
+		_ = tw.Flush()
+	case "export":
+		var w io.Writer = os.Stdout
+		if *out != "" {
+			f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			w = f
+		}
+		n, err := transfer.ExportManifest(ctx, store, w, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+			os.Exit(1)
+		}
+		if *out != "" {
+			fmt.Printf("exported %d transfers to %s\n", n, *out)
+		}
+	case "import":
+		if fs.NArg() != 1 {
+			transfersUsage()
+		}
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		report, err := transfer.ImportManifest(ctx, cfg, store, f, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("imported %d transfers\n", report.Imported)
+		for _, s := range report.Skipped {
+			fmt.Printf("skipped %s\n", s)
+		}
+	default:
+		transfersUsage()
+	}
+}
+
+func transfersUsage() {
+	fmt.Println("Usage: googlysync transfers list | export [--out FILE] | import FILE")
+	os.Exit(2)
+}
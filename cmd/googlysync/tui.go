@@ -9,22 +9,34 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/sandeepkv93/googlysync/internal/config"
-	"github.com/sandeepkv93/googlysync/internal/ipc"
 	ipcgen "github.com/sandeepkv93/googlysync/internal/ipc/gen"
 )
 
 const maxEventLines = 10
 
 type statusMsg struct {
-	state   string
-	message string
-	at      time.Time
-	events  []eventMsg
+	state     string
+	message   string
+	at        time.Time
+	failedOps int
+	quota     string
+	transfers []transferMsg
+	events    []eventMsg
+}
+
+type transferMsg struct {
+	direction string
+	path      string
+	done      int64
+	total     int64
+	rate      float64
+	eta       time.Duration
 }
 
 type eventMsg struct {
 	op   string
 	path string
+	root string
 	at   time.Time
 }
 
@@ -33,24 +45,29 @@ type errMsg struct {
 }
 
 type model struct {
-	socketPath string
+	cfg        *config.Config
 	interval   time.Duration
 	status     statusMsg
 	err        error
 	quitting   bool
 	showEvents bool
+	maxEvents  int
 }
 
-func newModel(socketPath string, interval time.Duration) model {
+func newModel(cfg *config.Config, interval time.Duration, maxEvents int) model {
+	if maxEvents <= 0 {
+		maxEvents = maxEventLines
+	}
 	return model{
-		socketPath: socketPath,
+		cfg:        cfg,
 		interval:   interval,
 		showEvents: true,
+		maxEvents:  maxEvents,
 	}
 }
 
 func (m model) Init() tea.Cmd {
-	return pollStatusCmd(m.socketPath, m.interval)
+	return pollStatusCmd(m.cfg, m.interval, m.maxEvents)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -58,21 +75,21 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case statusMsg:
 		m.status = msg
 		m.err = nil
-		return m, pollStatusCmd(m.socketPath, m.interval)
+		return m, pollStatusCmd(m.cfg, m.interval, m.maxEvents)
 	case errMsg:
 		m.err = msg.err
 		return m, tea.Tick(m.interval, func(time.Time) tea.Msg {
 			return pollNowMsg{}
 		})
 	case pollNowMsg:
-		return m, pollStatusCmd(m.socketPath, m.interval)
+		return m, pollStatusCmd(m.cfg, m.interval, m.maxEvents)
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "ctrl+c", "q":
 			m.quitting = true
 			return m, tea.Quit
 		case "r":
-			return m, pollStatusCmd(m.socketPath, 0)
+			return m, pollStatusCmd(m.cfg, 0, m.maxEvents)
 		case "e":
 			m.showEvents = !m.showEvents
 		}
@@ -95,6 +112,18 @@ func (m model) View() string {
 	b.WriteString("googlysync status\n\n")
 	b.WriteString(fmt.Sprintf("%s: %s\n", m.status.state, m.status.message))
 	b.WriteString(fmt.Sprintf("updated: %s\n", m.status.at.Format(time.RFC3339)))
+	if m.status.failedOps > 0 {
+		b.WriteString(fmt.Sprintf("failed operations: %d (googlysync ops retry)\n", m.status.failedOps))
+	}
+	if m.status.quota != "" {
+		b.WriteString("drive storage: " + m.status.quota + "\n")
+	}
+	if len(m.status.transfers) > 0 {
+		b.WriteString("\ntransfers:\n")
+		for _, tr := range m.status.transfers {
+			b.WriteString(formatTransferLine(tr))
+		}
+	}
 
 	if m.showEvents {
 		b.WriteString("\nrecent events:\n")
@@ -102,7 +131,7 @@ func (m model) View() string {
 			b.WriteString("- (none)\n")
 		} else {
 			for i, evt := range m.status.events {
-				if i >= maxEventLines {
+				if i >= m.maxEvents {
 					break
 				}
 				b.WriteString(formatEventLine(evt))
@@ -118,23 +147,19 @@ func (m model) View() string {
 
 type pollNowMsg struct{}
 
-func pollStatusCmd(socketPath string, interval time.Duration) tea.Cmd {
+func pollStatusCmd(cfg *config.Config, interval time.Duration, maxEvents int) tea.Cmd {
 	return func() tea.Msg {
-		cfg, err := config.NewConfigWithOptions(config.Options{SocketPath: socketPath})
-		if err != nil {
-			return errMsg{err: err}
-		}
 		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 		defer cancel()
 
-		conn, err := ipc.Dial(ctx, cfg.SocketPath)
+		conn, err := dialIPC(ctx, cfg)
 		if err != nil {
 			return errMsg{err: err}
 		}
 		defer conn.Close()
 
 		client := ipcgen.NewSyncStatusServiceClient(conn)
-		resp, err := client.GetStatus(ctx, &ipcgen.GetStatusRequest{})
+		resp, err := client.GetStatus(ctx, &ipcgen.GetStatusRequest{MaxEvents: int32(maxEvents)})
 		if err != nil {
 			return errMsg{err: err}
 		}
@@ -143,13 +168,16 @@ func pollStatusCmd(socketPath string, interval time.Duration) tea.Cmd {
 		}
 
 		msg := statusMsg{
-			state:   resp.Status.State.String(),
-			message: resp.Status.Message,
-			at:      time.Now(),
+			state:     resp.Status.State.String(),
+			message:   resp.Status.Message,
+			at:        time.Now(),
+			failedOps: int(resp.Status.GetFailedOps()),
+			quota:     formatQuota(resp.Status.GetQuotaLimitBytes(), resp.Status.GetQuotaUsageBytes()),
 		}
 		if resp.Status.UpdatedAt != nil {
 			msg.at = resp.Status.UpdatedAt.AsTime()
 		}
+		msg.transfers = toTransferMsgs(resp.Status.GetTransfers())
 		msg.events = toEventMsgs(resp.Status.RecentEvents)
 		return msg
 	}
@@ -164,6 +192,7 @@ func toEventMsgs(events []*ipcgen.StatusEvent) []eventMsg {
 		item := eventMsg{
 			op:   evt.Op,
 			path: evt.Path,
+			root: evt.Root,
 		}
 		if evt.OccurredAt != nil {
 			item.at = evt.OccurredAt.AsTime()
@@ -173,10 +202,61 @@ func toEventMsgs(events []*ipcgen.StatusEvent) []eventMsg {
 	return out
 }
 
+func toTransferMsgs(transfers []*ipcgen.TransferProgress) []transferMsg {
+	out := make([]transferMsg, 0, len(transfers))
+	for _, tr := range transfers {
+		out = append(out, transferMsg{
+			direction: tr.GetDirection(),
+			path:      tr.GetPath(),
+			done:      tr.GetBytesDone(),
+			total:     tr.GetBytesTotal(),
+			rate:      tr.GetBytesPerSecond(),
+			eta:       time.Duration(tr.GetEtaSeconds()) * time.Second,
+		})
+	}
+	return out
+}
+
+// formatTransferLine renders one transfer with a progress bar when its size
+// is known, e.g. "[#####-----]  50% 1.2 MiB/s eta 4s download a.bin".
+func formatTransferLine(tr transferMsg) string {
+	const width = 20
+	const mib = 1 << 20
+	bar := strings.Repeat("?", width)
+	pct := "  ?%"
+	if tr.total > 0 {
+		filled := int(min(tr.done, tr.total) * width / tr.total)
+		bar = strings.Repeat("#", filled) + strings.Repeat("-", width-filled)
+		pct = fmt.Sprintf("%3d%%", min(tr.done, tr.total)*100/tr.total)
+	}
+	eta := "-"
+	if tr.eta > 0 {
+		eta = tr.eta.String()
+	}
+	return fmt.Sprintf("[%s] %s %.1f MiB/s eta %s %s %s\n", bar, pct, tr.rate/mib, eta, tr.direction, tr.path)
+}
+
+// formatQuota describes Drive storage use, or returns "" before the first
+// quota check.
+func formatQuota(limit, usage int64) string {
+	const gib = 1 << 30
+	switch {
+	case limit > 0:
+		return fmt.Sprintf("%.1f of %.1f GiB used (%d%%)", float64(usage)/gib, float64(limit)/gib, usage*100/limit)
+	case usage > 0:
+		return fmt.Sprintf("%.1f GiB used (unlimited)", float64(usage)/gib)
+	default:
+		return ""
+	}
+}
+
 func formatEventLine(evt eventMsg) string {
 	when := "-"
 	if !evt.at.IsZero() {
 		when = evt.at.Format("15:04:05")
 	}
+	if evt.root != "" {
+		return fmt.Sprintf("- %s %s in %s (%s)\n", evt.op, evt.path, evt.root, when)
+	}
 	return fmt.Sprintf("- %s %s (%s)\n", evt.op, evt.path, when)
 }
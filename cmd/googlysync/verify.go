@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	syncer "github.com/sandeepkv93/googlysync/internal/sync"
+)
+
+func runVerify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	localOnly := fs.Bool("local-only", false, "only re-hash local files; do not contact Drive")
+	repair := fs.Bool("repair", false, "queue uploads and downloads to fix what was found")
+	_ = fs.Parse(args)
+
+	if fs.NArg() > 1 {
+		fmt.Println("Usage: googlysync verify [--local-only] [--repair] [folder]")
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	cfg, store, svc := openOfflineAuth(ctx, *configPath)
+	defer store.Close()
+
+	var remote syncer.FileLookup
+	if !*localOnly {
+		remote = driveapi.NewClient(svc.Client(svc.State().Account.ID))
+	}
+
+	engine := offlineEngine(cfg, store, svc)
+	report, err := engine.Verify(ctx, remote, fs.Arg(0), *repair)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "verify failed: %v\n", err)
+		os.Exit(1)
+	}
+	repaired := 0
+	for _, issue := range report.Issues {
+		note := ""
+		if issue.Repaired {
+			note = " (queued)"
+			repaired++
+		}
+		fmt.Printf("%-15s %s: %s%s\n", issue.Kind, issue.Path, issue.Detail, note)
+	}
+	fmt.Printf("checked %d file(s), skipped %d with pending ops; %d issue(s)", report.Checked, report.Skipped, len(report.Issues))
+	if *repair {
+		fmt.Printf(", %d repair(s) queued", repaired)
+	}
+	fmt.Println()
+	if len(report.Issues) > 0 {
+		os.Exit(1)
+	}
+}
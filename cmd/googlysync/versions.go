@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+	"github.com/sandeepkv93/googlysync/internal/versions"
+)
+
+func runVersions(args []string) {
+	if len(args) == 0 {
+		versionsUsage()
+	}
+	sub := args[0]
+
+	fs := flag.NewFlagSet("versions "+sub, flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	dest := fs.String("to", "", "restore to this path instead of the original (restore only)")
+	_ = fs.Parse(args[1:])
+
+	cfg, err := config.NewConfigWithOptions(config.Options{ConfigPath: *configPath})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+		os.Exit(1)
+	}
+	store, err := storage.Open(cfg, zap.NewNop())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "storage error: %v\n", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	vs, err := versions.NewStore(zap.NewNop(), cfg, store)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "versions error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	switch sub {
+	case "list":
+		list, err := vs.List(ctx, fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "list failed: %v\n", err)
+			os.Exit(1)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tPATH\tSIZE\tSTASHED\tCHECKSUM")
+		for _, v := range list {
+			fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\n", v.ID, v.Path, v.Size, v.StashedAt.Local().Format(time.RFC3339), v.Checksum[:12])
+		}
+		_ = tw.Flush()
+	case "restore":
+		if fs.NArg() != 1 {
+			versionsUsage()
+		}
+		id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid version id: %s\n", fs.Arg(0))
+			os.Exit(2)
+		}
+		path, err := vs.Restore(ctx, id, *dest)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "restore failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("restored version %d to %s\n", id, path)
+	case "prune":
+		if err := vs.Prune(ctx, ""); err != nil {
+			fmt.Fprintf(os.Stderr, "prune failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("pruned versions")
+	default:
+		versionsUsage()
+	}
+}
+
+func versionsUsage() {
+	fmt.Println("Usage: googlysync versions list [path] | restore [--to path] <id> | prune")
+	os.Exit(2)
+}
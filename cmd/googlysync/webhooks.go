@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/notify"
+)
+
+// runWebhooks manages the outbound webhooks the daemon notifies of sync
+// milestones.
+func runWebhooks(args []string) {
+	if len(args) == 0 {
+		webhooksUsage()
+	}
+	switch args[0] {
+	case "add":
+		runWebhooksAdd(args[1:])
+	case "list":
+		runWebhooksList(args[1:])
+	case "remove":
+		runWebhooksRemove(args[1:])
+	default:
+		webhooksUsage()
+	}
+}
+
+func runWebhooksAdd(args []string) {
+	fs := flag.NewFlagSet("webhooks add", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	account := fs.String("account", "", "account id whose events are sent (default: the synced account)")
+	events := fs.String("events", "", "comma-separated events to send (default: all of "+strings.Join(notify.WebhookEvents, ", ")+")")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		webhooksUsage()
+	}
+
+	_, store, accountID := openOfflineAccount(*configPath, *account)
+	defer store.Close()
+
+	var names []string
+	for _, name := range strings.Split(*events, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	hook, err := notify.RegisterWebhook(context.Background(), store, accountID, fs.Arg(0), names)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "add failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("added webhook %s\n", hook.ID)
+	fmt.Printf("Verify deliveries with this secret; the %s header is its HMAC-SHA256 of the body. It is not shown again:\n", notify.HeaderSignature)
+	fmt.Println(hook.Secret)
+}
+
+func runWebhooksList(args []string) {
+	fs := flag.NewFlagSet("webhooks list", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	account := fs.String("account", "", "only this account's webhooks")
+	_ = fs.Parse(args)
+
+	_, store := openOffline(*configPath)
+	defer store.Close()
+
+	hooks, err := store.ListWebhooks(context.Background(), *account)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "list failed: %v\n", err)
+		os.Exit(1)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tACCOUNT\tEVENTS\tURL\tCREATED")
+	for _, hook := range hooks {
+		events := "all"
+		if len(hook.Events) > 0 {
+			events = strings.Join(hook.Events, ",")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", hook.ID, hook.AccountID, events, hook.URL, hook.CreatedAt.Local().Format(time.RFC3339))
+	}
+	_ = tw.Flush()
+}
+
+func runWebhooksRemove(args []string) {
+	fs := flag.NewFlagSet("webhooks remove", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (JSON)")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		webhooksUsage()
+	}
+
+	_, store := openOffline(*configPath)
+	defer store.Close()
+
+	if err := store.DeleteWebhook(context.Background(), fs.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "remove failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("removed %s\n", fs.Arg(0))
+}
+
+func webhooksUsage() {
+	fmt.Println("Usage: googlysync webhooks add [--account ID] [--events LIST] URL | list [--account ID] | remove WEBHOOK_ID")
+	os.Exit(2)
+}
@@ -8,25 +8,44 @@ package main
 import (
 	"github.com/google/wire"
 
+	"github.com/sandeepkv93/googlysync/internal/cache"
 	"github.com/sandeepkv93/googlysync/internal/config"
 	"github.com/sandeepkv93/googlysync/internal/daemon"
 	"github.com/sandeepkv93/googlysync/internal/fswatch"
 	"github.com/sandeepkv93/googlysync/internal/ipc"
 	"github.com/sandeepkv93/googlysync/internal/logging"
+	"github.com/sandeepkv93/googlysync/internal/notify"
 	"github.com/sandeepkv93/googlysync/internal/storage"
 	syncer "github.com/sandeepkv93/googlysync/internal/sync"
+	"github.com/sandeepkv93/googlysync/internal/transfer"
+	"github.com/sandeepkv93/googlysync/internal/versions"
 )
 
 func InitializeDaemon(opts config.Options) (*daemon.Daemon, error) {
 	wire.Build(
 		config.NewConfigWithOptions,
 		logging.NewLogger,
-		storage.NewStorage,
+		storage.Open,
 		newStatusStore,
+		notify.NewWebhooks,
 		newAuthService,
+		newFileWatcher,
+		newDeviceRegistrar,
 		fswatch.NewWatcher,
 		newSyncQueue,
-		syncer.NewEngine,
+		versions.NewStore,
+		cache.NewStore,
+		transfer.NewBufferPool,
+		transfer.NewProgress,
+		transfer.NewDownloader,
+		newSyncEngine,
+		newOpExecutor,
+		newGarbageCollector,
+		syncer.NewMaintainer,
+		newTokenPreRefresher,
+		newChangePoller,
+		newChangePush,
+		newSyncController,
 		ipc.NewServer,
 		daemon.NewDaemon,
 	)
@@ -7,13 +7,17 @@
 package main
 
 import (
+	"github.com/sandeepkv93/googlysync/internal/cache"
 	"github.com/sandeepkv93/googlysync/internal/config"
 	"github.com/sandeepkv93/googlysync/internal/daemon"
 	"github.com/sandeepkv93/googlysync/internal/fswatch"
 	"github.com/sandeepkv93/googlysync/internal/ipc"
 	"github.com/sandeepkv93/googlysync/internal/logging"
+	"github.com/sandeepkv93/googlysync/internal/notify"
 	"github.com/sandeepkv93/googlysync/internal/storage"
 	"github.com/sandeepkv93/googlysync/internal/sync"
+	"github.com/sandeepkv93/googlysync/internal/transfer"
+	"github.com/sandeepkv93/googlysync/internal/versions"
 )
 
 // Injectors from wire.go:
@@ -27,29 +31,53 @@ func InitializeDaemon(opts config.Options) (*daemon.Daemon, error) {
 	if err != nil {
 		return nil, err
 	}
-	storageStorage, err := storage.NewStorage(configConfig, logger)
+	store, err := storage.Open(configConfig, logger)
 	if err != nil {
 		return nil, err
 	}
-	service, err := newAuthService(logger, configConfig, storageStorage)
+	webhooks := notify.NewWebhooks(logger, configConfig, store)
+	service, err := newAuthService(logger, configConfig, store, webhooks)
 	if err != nil {
 		return nil, err
 	}
-	store := newStatusStore(configConfig)
+	statusStore := newStatusStore(configConfig, logger, store)
 	queue := newSyncQueue(logger, configConfig)
-	engine, err := sync.NewEngine(logger, storageStorage, store, queue)
+	versionsStore, err := versions.NewStore(logger, configConfig, store)
 	if err != nil {
 		return nil, err
 	}
-	watcher, err := fswatch.NewWatcher(logger, configConfig, store)
+	bufferPool := transfer.NewBufferPool(configConfig)
+	progress := transfer.NewProgress()
+	downloader, err := transfer.NewDownloader(logger, configConfig, statusStore, versionsStore, bufferPool, progress)
 	if err != nil {
 		return nil, err
 	}
-	server, err := ipc.NewServer(configConfig, logger, store, service)
+	engine, err := newSyncEngine(logger, configConfig, store, statusStore, queue, downloader, progress, webhooks, service)
 	if err != nil {
 		return nil, err
 	}
-	daemonDaemon, err := daemon.NewDaemon(logger, configConfig, storageStorage, service, engine, watcher, server, queue)
+	executor := newOpExecutor(logger, engine, service)
+	watcher, err := fswatch.NewWatcher(logger, configConfig, statusStore)
+	if err != nil {
+		return nil, err
+	}
+	ipcSyncController := newSyncController(engine, configConfig, store, service)
+	server, err := ipc.NewServer(configConfig, logger, statusStore, service, bufferPool, progress, ipcSyncController, store)
+	if err != nil {
+		return nil, err
+	}
+	fileWatcher := newFileWatcher(logger, configConfig, store, service)
+	registrar := newDeviceRegistrar(logger, configConfig, store, service)
+	cacheStore, err := cache.NewStore(logger, configConfig, store)
+	if err != nil {
+		return nil, err
+	}
+	garbageCollector := newGarbageCollector(logger, engine, service, versionsStore, cacheStore)
+	changePoller := newChangePoller(logger, engine, service)
+	changePush := newChangePush(logger, engine, changePoller, service)
+	maintainer := sync.NewMaintainer(logger, engine)
+	preRefresher := newTokenPreRefresher(logger, engine, store, service)
+	daemonDaemon, err := daemon.NewDaemon(logger, configConfig, store, service, engine, executor, watcher, server, queue, fileWatcher, registrar, webhooks, garbageCollector, changePoller, changePush, maintainer, preRefresher)
 	if err != nil {
 		return nil, err
 	}
@@ -5,7 +5,10 @@ go 1.24.0
 toolchain go1.24.11
 
 require (
+	github.com/cespare/xxhash/v2 v2.3.0
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/google/wire v0.7.0
+	github.com/klauspost/compress v1.17.11
 	github.com/zalando/go-keyring v0.2.6
 	go.uber.org/zap v1.27.1
 	golang.org/x/oauth2 v0.24.0
@@ -23,7 +26,6 @@ require (
 	github.com/charmbracelet/x/windows v0.1.0 // indirect
 	github.com/danieljoos/wincred v1.2.2 // indirect
 	github.com/erikgeiser/coninput v0.0.0-20211004153227-1c3628e74d0f // indirect
-	github.com/godbus/dbus/v5 v5.1.0 // indirect
 	github.com/mattn/go-localereader v0.0.1 // indirect
 	github.com/mattn/go-runewidth v0.0.15 // indirect
 	github.com/mfridman/interpolate v0.0.2 // indirect
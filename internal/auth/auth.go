@@ -3,6 +3,8 @@ package auth
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -10,11 +12,11 @@ import (
 
 	"go.uber.org/zap"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 
 	"github.com/zalando/go-keyring"
 
 	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
 	"github.com/sandeepkv93/googlysync/internal/storage"
 )
 
@@ -28,15 +30,33 @@ type State struct {
 type Service struct {
 	logger *zap.Logger
 	cfg    *config.Config
-	store  *storage.Storage
+	store  storage.Store
 	krSvc  string
+	// sa is set when tokens come from a service-account key rather than
+	// from a signed-in user; secrets holds the users' refresh tokens
+	// otherwise.
+	sa      *serviceAccount
+	secrets tokenStore
+	// client is the OAuth client users sign in and refresh through.
+	client *oauth2.Config
 
-	mu    sync.Mutex
-	state State
+	mu        sync.Mutex
+	state     State
+	refreshes map[string]refreshResult
+	tokens    map[string]*tokenCache
+	onExpired func(accountID string, err error)
+	watchers  map[chan Event]struct{}
+	// signIns cancels the sign-in flows in progress, by a sequence number.
+	signIns    map[int]context.CancelCauseFunc
+	nextSignIn int
+
+	// refresh fetches a new access token; RefreshAccessToken outside tests.
+	refresh func(ctx context.Context, accountID string) (*oauth2.Token, error)
+	now     func() time.Time
 }
 
 // NewService constructs the auth service.
-func NewService(ctx context.Context, logger *zap.Logger, cfg *config.Config, store *storage.Storage) (*Service, error) {
+func NewService(ctx context.Context, logger *zap.Logger, cfg *config.Config, store storage.Store) (*Service, error) {
 	if logger == nil {
 		return nil, errors.New("auth: logger is required")
 	}
@@ -51,7 +71,47 @@ func NewService(ctx context.Context, logger *zap.Logger, cfg *config.Config, sto
 	if krSvc == "" {
 		krSvc = "googlysync"
 	}
-	svc := &Service{logger: logger, cfg: cfg, store: store, krSvc: krSvc}
+	switch cfg.DriveAccess {
+	case "", driveAccessFull, driveAccessApp:
+	default:
+		return nil, errs.New(errs.ErrInvalidArgument, "unknown drive_access %q", cfg.DriveAccess)
+	}
+	sa, err := loadServiceAccount(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var secrets tokenStore
+	var client *oauth2.Config
+	if sa == nil {
+		if secrets, err = newTokenStore(cfg, krSvc, logger); err != nil {
+			return nil, err
+		}
+		if client, err = loadOAuthClient(cfg); err != nil {
+			return nil, err
+		}
+	}
+	svc := &Service{
+		logger:    logger,
+		cfg:       cfg,
+		store:     store,
+		krSvc:     krSvc,
+		sa:        sa,
+		secrets:   secrets,
+		client:    client,
+		refreshes: make(map[string]refreshResult),
+		tokens:    make(map[string]*tokenCache),
+		watchers:  make(map[chan Event]struct{}),
+		signIns:   make(map[int]context.CancelCauseFunc),
+		now:       time.Now,
+	}
+	svc.refresh = svc.RefreshAccessToken
+	if sa != nil {
+		if err := svc.bootstrapServiceAccount(ctx); err != nil {
+			return nil, err
+		}
+		logger.Info("auth service initialized with a service account", zap.String("client", sa.client), zap.String("subject", sa.email))
+		return svc, nil
+	}
 	svc.bootstrapState(ctx)
 	logger.Info("auth service initialized")
 	return svc, nil
@@ -64,76 +124,344 @@ func (s *Service) State() State {
 	return s.state
 }
 
+// SignInOptions adjusts SignIn.
+type SignInOptions struct {
+	Scopes []string
+	// Add signs in another account next to the ones already signed in.
+	// Without it, SignIn only signs in the first account or signs an
+	// already signed-in account in again.
+	Add bool
+	// SyncRoot is where the account's files are mirrored. Empty keeps the
+	// account's current root, or the configured sync_root for a new account.
+	SyncRoot string
+	// Prompt replaces opening a browser, for machines without one. It is
+	// given the consent page URL to open elsewhere and returns what the user
+	// pasted back: the address the browser was redirected to, or the code.
+	Prompt func(authURL string) (string, error)
+	// OpenURL replaces opening a browser with handing the consent page URL
+	// to someone who opens it on this machine, such as a UI talking to the
+	// daemon. The flow still waits for the browser's redirect.
+	OpenURL func(authURL string) error
+	// Account names a signed-in account, by id or email, to ask for the
+	// scopes drive_access needs and it has not granted yet. The consent page
+	// only asks for those; the ones granted before are kept.
+	Account string
+}
+
+// errSignInCanceled ends a flow CancelSignIn stopped.
+var errSignInCanceled = fmt.Errorf("sign-in canceled: %w", context.Canceled)
+
 // SignIn runs the OAuth flow and persists account metadata + refresh token.
-func (s *Service) SignIn(ctx context.Context, scopes []string) error {
-	if s.cfg.OAuthClientID == "" {
-		return errors.New("oauth client id not configured")
+// The first account signed in becomes the primary one. The flow gives up
+// after sign_in_timeout_seconds, when ctx is done, or on CancelSignIn.
+func (s *Service) SignIn(ctx context.Context, opts SignInOptions) (*storage.Account, error) {
+	if s.sa != nil {
+		return nil, errs.New(errs.ErrInvalidArgument, "authenticating with service account key %s; sign-in is not used", s.cfg.ServiceAccountKey)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	s.mu.Lock()
+	id := s.nextSignIn
+	s.nextSignIn++
+	s.signIns[id] = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.signIns, id)
+		s.mu.Unlock()
+		cancel(nil)
+	}()
+	client, err := s.oauthClient()
+	if err != nil {
+		return nil, err
 	}
-	if s.cfg.OAuthClientSecret == "" {
-		return errors.New("oauth client secret not configured")
+	var want *storage.Account
+	if opts.Account != "" {
+		if want, err = s.Account(ctx, opts.Account); err != nil {
+			return nil, err
+		}
+		if len(opts.Scopes) == 0 {
+			missing, err := s.MissingScopes(ctx, want.ID)
+			if err != nil {
+				return nil, err
+			}
+			opts.Scopes = append(slices.Clone(identityScopes), missing...)
+		}
 	}
-	if len(scopes) == 0 {
-		scopes = defaultScopes()
+	if len(opts.Scopes) == 0 {
+		opts.Scopes = defaultScopes(s.cfg.DriveAccess)
 	}
 
-	token, claims, err := runOAuthFlow(ctx, s.cfg, scopes, s.logger)
+	hint := ""
+	if want != nil {
+		hint = want.Email
+	}
+	token, claims, err := runOAuthFlow(ctx, s.cfg, client, opts.Scopes, hint, s.logger, opts.OpenURL, opts.Prompt)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if token == nil {
-		return errors.New("oauth token missing")
+		return nil, errors.New("oauth token missing")
 	}
+	if want != nil && claims.Sub != want.ID {
+		return nil, errs.New(errs.ErrConflict, "signed in as %s instead of %s; pick %s on the consent page", claims.Email, want.Email, want.Email)
+	}
+	return s.addAccount(ctx, token, claims, opts)
+}
 
+// CancelSignIn stops the sign-in flows in progress and returns how many it
+// stopped. Their SignIn calls fail with context.Canceled.
+func (s *Service) CancelSignIn() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cancel := range s.signIns {
+		cancel(errSignInCanceled)
+	}
+	return len(s.signIns)
+}
+
+// MissingScopes returns the scopes drive_access needs that the account has
+// not granted. Sign in with SignInOptions.Account to grant them.
+func (s *Service) MissingScopes(ctx context.Context, accountID string) ([]string, error) {
+	if s.sa != nil {
+		return nil, nil
+	}
+	ref, err := s.store.GetTokenRef(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if ref == nil {
+		return nil, errs.New(errs.ErrNotFound, "account %s is not signed in", accountID)
+	}
+	return missingScopes(strings.Fields(ref.Scope), requiredScopes(s.cfg.DriveAccess)), nil
+}
+
+// addAccount stores the account and refresh token an OAuth flow returned.
+func (s *Service) addAccount(ctx context.Context, token *oauth2.Token, claims idTokenClaims, opts SignInOptions) (*storage.Account, error) {
 	accountID := claims.Sub
 	if accountID == "" {
-		return errors.New("oauth sub claim missing")
+		return nil, errors.New("oauth sub claim missing")
+	}
+	if err := checkDomain(s.cfg.AllowedDomains, claims); err != nil {
+		return nil, err
 	}
+	refreshToken := token.RefreshToken
+	if refreshToken == "" {
+		return nil, errs.New(errs.ErrAuthExpired, "refresh token missing; re-auth with consent")
+	}
+	signedIn, err := s.Accounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.store.GetAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
 	account := storage.Account{
 		ID:          accountID,
 		Email:       claims.Email,
 		DisplayName: claims.Name,
-		IsPrimary:   s.isFirstAccount(ctx),
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		IsPrimary:   len(signedIn) == 0,
+		SyncRoot:    opts.SyncRoot,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	known := false
+	var others []storage.Account
+	for _, acct := range signedIn {
+		if acct.ID == accountID {
+			known = true
+			account.IsPrimary = acct.IsPrimary
+			continue
+		}
+		others = append(others, acct)
 	}
-	if err := s.store.UpsertAccount(ctx, &account); err != nil {
-		return err
+	if existing != nil {
+		account.CreatedAt = existing.CreatedAt
+		if account.SyncRoot == "" {
+			account.SyncRoot = existing.SyncRoot
+		}
+	}
+	if !known && len(signedIn) > 0 && !opts.Add {
+		return nil, errs.New(errs.ErrConflict, "already signed in as %s; add another account with login --add", signedIn[0].Email)
+	}
+	root := s.syncRoot(account)
+	for _, acct := range others {
+		if s.syncRoot(acct) == root {
+			return nil, errs.New(errs.ErrConflict, "%s already syncs into %s; give this account its own sync root", acct.Email, root)
+		}
 	}
 
-	refreshToken := token.RefreshToken
-	if refreshToken == "" {
-		return errors.New("refresh token missing; re-auth with consent")
+	if err := s.store.UpsertAccount(ctx, &account); err != nil {
+		return nil, err
+	}
+	if account.IsPrimary {
+		if err := s.store.SetPrimaryAccount(ctx, accountID); err != nil {
+			return nil, err
+		}
+	}
+	var granted []string
+	var prev *storage.TokenRef
+	if known {
+		if prev, err = s.store.GetTokenRef(ctx, accountID); err != nil {
+			return nil, err
+		}
+		if prev != nil {
+			granted = strings.Fields(prev.Scope)
+		}
 	}
 	ref := storage.TokenRef{
 		AccountID: accountID,
 		KeyID:     accountID,
 		TokenType: "refresh",
-		Scope:     scopeString(scopes),
+		Scope:     scopeString(grantedScopes(token, opts.Scopes, granted)),
 		Expiry:    token.Expiry,
-		UpdatedAt: time.Now(),
+		UpdatedAt: now,
 	}
 	if err := s.store.UpsertTokenRef(ctx, &ref); err != nil {
-		return err
+		return nil, err
 	}
-	if err := keyring.Set(s.krSvc, accountID, refreshToken); err != nil {
-		_ = s.store.DeleteTokenRef(ctx, accountID)
-		return err
+	if err := s.secrets.Set(accountID, refreshToken); err != nil {
+		// The keyring still holds the previous token, if any; leave its
+		// metadata as it was.
+		if prev != nil {
+			_ = s.store.UpsertTokenRef(ctx, prev)
+		} else {
+			_ = s.store.DeleteTokenRef(ctx, accountID)
+		}
+		return nil, err
+	}
+	s.forgetToken(accountID)
+	if existing != nil && existing.ReauthReason != "" {
+		if err := s.store.SetAccountReauth(ctx, accountID, ""); err != nil {
+			return nil, err
+		}
 	}
 
 	s.mu.Lock()
-	s.state = State{SignedIn: true, Account: account}
+	if r, ok := s.refreshes[accountID]; ok {
+		r.expired = false
+		s.refreshes[accountID] = r
+	}
+	before := s.state.Account.ID
+	if !s.state.SignedIn || s.state.Account.ID == accountID {
+		s.state = State{SignedIn: true, Account: account}
+	}
 	s.mu.Unlock()
+	kind := EventAccountAdded
+	if known {
+		kind = EventAccountRenewed
+	}
+	s.publish(Event{Kind: kind, AccountID: accountID, Email: account.Email})
+	s.publishActive(before)
+	return &account, nil
+}
 
-	return nil
+// Accounts lists the signed-in accounts, oldest first. The storage's
+// placeholder account has no token and is not listed.
+func (s *Service) Accounts(ctx context.Context) ([]storage.Account, error) {
+	if s.sa != nil {
+		return []storage.Account{s.State().Account}, nil
+	}
+	accounts, err := s.store.ListAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []storage.Account
+	for _, acct := range accounts {
+		ref, err := s.store.GetTokenRef(ctx, acct.ID)
+		if err != nil {
+			return nil, err
+		}
+		if ref != nil {
+			out = append(out, acct)
+		}
+	}
+	return out, nil
 }
 
-// RefreshAccessToken exchanges the stored refresh token for a new access token.
+// SetPrimary makes a signed-in account, named by id or email, the primary
+// one: the account a daemon without the account setting syncs from its next
+// start, and the one single-account UIs default to. State keeps naming the
+// account this process uses.
+func (s *Service) SetPrimary(ctx context.Context, account string) (*storage.Account, error) {
+	acct, err := s.Account(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	if acct.IsPrimary {
+		return acct, nil
+	}
+	if err := s.store.SetPrimaryAccount(ctx, acct.ID); err != nil {
+		return nil, err
+	}
+	acct.IsPrimary = true
+	s.mu.Lock()
+	s.state.Account.IsPrimary = s.state.Account.ID == acct.ID
+	s.mu.Unlock()
+	s.publish(Event{Kind: EventPrimaryChanged, AccountID: acct.ID, Email: acct.Email})
+	return acct, nil
+}
+
+// Switch makes a signed-in account, named by id or email, the primary one
+// and the one State reports.
+func (s *Service) Switch(ctx context.Context, account string) (*storage.Account, error) {
+	acct, err := s.SetPrimary(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	before := s.state.Account.ID
+	s.state = State{SignedIn: true, Account: *acct}
+	s.mu.Unlock()
+	s.publishActive(before)
+	return acct, nil
+}
+
+// SyncRoot returns the directory the active account is mirrored to.
+func (s *Service) SyncRoot() string {
+	return s.syncRoot(s.State().Account)
+}
+
+func (s *Service) syncRoot(acct storage.Account) string {
+	if acct.SyncRoot != "" {
+		return acct.SyncRoot
+	}
+	return s.cfg.SyncRoot
+}
+
+// Account finds a signed-in account by id or email.
+func (s *Service) Account(ctx context.Context, account string) (*storage.Account, error) {
+	accounts, err := s.Accounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range accounts {
+		if accounts[i].ID == account || strings.EqualFold(accounts[i].Email, account) {
+			return &accounts[i], nil
+		}
+	}
+	return nil, errs.New(errs.ErrNotFound, "no signed-in account %s", account)
+}
+
+// RefreshAccessToken exchanges the stored refresh token for a new access
+// token. It always goes to the network; use TokenSource for a cached token.
 func (s *Service) RefreshAccessToken(ctx context.Context, accountID string) (*oauth2.Token, error) {
 	if accountID == "" {
-		return nil, errors.New("account id is required")
+		return nil, errs.New(errs.ErrInvalidArgument, "account id is required")
 	}
-	if s.cfg.OAuthClientID == "" || s.cfg.OAuthClientSecret == "" {
-		return nil, errors.New("oauth client not configured")
+	if s.sa != nil {
+		if accountID != s.sa.email {
+			return nil, errs.New(errs.ErrNotFound, "no token source for account %s", accountID)
+		}
+		token, err := s.sa.token(ctx)
+		s.noteRefresh(accountID, err)
+		return token, err
+	}
+	client, err := s.oauthClient()
+	if err != nil {
+		return nil, err
 	}
 
 	ref, err := s.store.GetTokenRef(ctx, accountID)
@@ -141,81 +469,158 @@ func (s *Service) RefreshAccessToken(ctx context.Context, accountID string) (*oa
 		return nil, err
 	}
 	if ref == nil {
-		return nil, errors.New("no token reference found")
+		return nil, errs.New(errs.ErrNotFound, "no token reference found")
 	}
-
-	refreshToken, err := keyring.Get(s.krSvc, accountID)
+	// A revoked or expired token stays that way; asking again only gets
+	// the same answer until the account signs in again.
+	acct, err := s.store.GetAccount(ctx, accountID)
 	if err != nil {
 		return nil, err
 	}
+	if acct != nil && acct.ReauthReason != "" {
+		return nil, errs.New(errs.ErrAuthExpired, "%s has to sign in again (run googlysync login): %s", acct.Email, acct.ReauthReason)
+	}
 
-	oauthCfg := &oauth2.Config{
-		ClientID:     s.cfg.OAuthClientID,
-		ClientSecret: s.cfg.OAuthClientSecret,
-		Endpoint:     google.Endpoint,
+	refreshToken, err := s.secrets.Get(accountID)
+	if errors.Is(err, keyring.ErrNotFound) {
+		err = errs.New(errs.ErrAuthExpired, "refresh token missing from the %s; sign in again", s.secrets.Name())
+	}
+	if err != nil {
+		s.noteRefresh(accountID, err)
+		return nil, err
 	}
-	tokenSource := oauthCfg.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshToken})
+
+	tokenSource := client.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshToken})
 	newToken, err := tokenSource.Token()
 	if err != nil {
+		err = refreshErr(err)
+		s.noteRefresh(accountID, err)
 		return nil, err
 	}
+	s.noteRefresh(accountID, nil)
 
 	ref.Expiry = newToken.Expiry
 	ref.UpdatedAt = time.Now()
+	// The grant can shrink when the user takes back a scope from their
+	// Google account settings.
+	if scope, ok := newToken.Extra("scope").(string); ok && strings.TrimSpace(scope) != "" {
+		ref.Scope = scopeString(strings.Fields(scope))
+	}
 	if err := s.store.UpsertTokenRef(ctx, ref); err != nil {
 		s.logger.Warn("token ref update failed", zap.Error(err))
 	}
 	return newToken, nil
 }
 
-// SignOut removes stored token reference and resets auth state.
+// OnAuthExpired registers fn to be called when an account's sign-in expires
+// or is revoked. It is called once per expiry, not on every failed refresh.
+func (s *Service) OnAuthExpired(fn func(accountID string, err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onExpired = fn
+}
+
+// refreshErr tags a revoked or expired refresh token as errs.ErrAuthExpired so
+// callers know to prompt for sign-in instead of retrying.
+func refreshErr(err error) error {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant" {
+		return errs.Wrap(errs.ErrAuthExpired, err)
+	}
+	return err
+}
+
+// SignOut removes one account and its keyring entry. Other accounts stay
+// signed in; when the removed account was the active one, the next
+// account takes over and becomes primary.
 func (s *Service) SignOut(ctx context.Context, accountID string) error {
 	if accountID == "" {
-		return errors.New("account id is required")
+		return errs.New(errs.ErrInvalidArgument, "account id is required")
+	}
+	if s.sa != nil {
+		return errs.New(errs.ErrInvalidArgument, "authenticating with service account key %s; remove service_account_key to stop", s.cfg.ServiceAccountKey)
+	}
+	acct, err := s.store.GetAccount(ctx, accountID)
+	if err != nil {
+		return err
 	}
-	_ = keyring.Delete(s.krSvc, accountID)
+	_ = s.secrets.Delete(accountID)
+	s.forgetToken(accountID)
 	if err := s.store.DeleteAccount(ctx, accountID); err != nil {
 		return err
 	}
+	removed := Event{Kind: EventAccountRemoved, AccountID: accountID}
+	if acct != nil {
+		removed.Email = acct.Email
+	}
 	s.mu.Lock()
-	s.state = State{}
+	active := s.state.Account.ID == accountID
 	s.mu.Unlock()
+	if !active {
+		s.publish(removed)
+		return nil
+	}
+	s.bootstrapState(ctx)
+	next := s.State()
+	if next.SignedIn && !next.Account.IsPrimary {
+		if _, err := s.Switch(ctx, next.Account.ID); err != nil {
+			return err
+		}
+	}
+	// Watchers read State on an event; publish once it names the next
+	// account.
+	s.publish(removed)
+	s.publishActive(accountID)
 	return nil
 }
 
-func (s *Service) isFirstAccount(ctx context.Context) bool {
-	accounts, err := s.store.ListAccounts(ctx)
+// bootstrapServiceAccount records the account the service account acts as
+// and makes it the active one.
+func (s *Service) bootstrapServiceAccount(ctx context.Context) error {
+	account, err := s.store.GetAccount(ctx, s.sa.email)
 	if err != nil {
-		return false
+		return err
+	}
+	if account == nil {
+		account = &storage.Account{ID: s.sa.email, Email: s.sa.email}
+	}
+	account.IsPrimary = true
+	account.UpdatedAt = time.Now()
+	if err := s.store.UpsertAccount(ctx, account); err != nil {
+		return err
+	}
+	if err := s.store.SetPrimaryAccount(ctx, account.ID); err != nil {
+		return err
 	}
-	return len(accounts) == 0
+	s.mu.Lock()
+	s.state = State{SignedIn: true, Account: *account}
+	s.mu.Unlock()
+	return nil
 }
 
+// bootstrapState picks the active account: the one the account setting
+// names, else the primary one.
 func (s *Service) bootstrapState(ctx context.Context) {
 	account := s.findActiveAccount(ctx)
-	if account == nil {
-		return
+	state := State{}
+	if account != nil {
+		state = State{SignedIn: true, Account: *account}
 	}
 	s.mu.Lock()
-	s.state = State{SignedIn: true, Account: *account}
+	s.state = state
 	s.mu.Unlock()
 }
 
 func (s *Service) findActiveAccount(ctx context.Context) *storage.Account {
-	accounts, err := s.store.ListAccounts(ctx)
-	if err != nil || len(accounts) == 0 {
-		return nil
-	}
-
-	var candidates []storage.Account
-	for i := range accounts {
-		ref, err := s.store.GetTokenRef(ctx, accounts[i].ID)
-		if err != nil || ref == nil {
-			continue
+	if s.cfg.Account != "" {
+		acct, err := s.Account(ctx, s.cfg.Account)
+		if err == nil {
+			return acct
 		}
-		candidates = append(candidates, accounts[i])
+		s.logger.Warn("configured account is not signed in; using the primary account", zap.String("account", s.cfg.Account))
 	}
-	if len(candidates) == 0 {
+	candidates, err := s.Accounts(ctx)
+	if err != nil || len(candidates) == 0 {
 		return nil
 	}
 	for i := range candidates {
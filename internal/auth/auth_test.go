@@ -1,15 +1,32 @@
 package auth
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/zalando/go-keyring"
 	"go.uber.org/zap"
+	"golang.org/x/oauth2"
 
 	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
 	"github.com/sandeepkv93/googlysync/internal/storage"
 )
 
@@ -132,6 +149,342 @@ func TestBootstrapSelectsPrimaryWithToken(t *testing.T) {
 	}
 }
 
+func TestSignInSeveralAccounts(t *testing.T) {
+	keyring.MockInit()
+	store := newTestStore(t)
+	ctx := t.Context()
+	cfg := &config.Config{AppName: "googlysync-test", SyncRoot: "/home/u/Drive"}
+	svc, err := NewService(ctx, zap.NewNop(), cfg, store)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	token := func(refresh string) *oauth2.Token {
+		return &oauth2.Token{RefreshToken: refresh, Expiry: time.Now().Add(time.Hour)}
+	}
+	work := idTokenClaims{Sub: "sub-work", Email: "work@example.com"}
+	home := idTokenClaims{Sub: "sub-home", Email: "home@example.com"}
+
+	first, err := svc.addAccount(ctx, token("r1"), work, SignInOptions{})
+	if err != nil {
+		t.Fatalf("first sign-in: %v", err)
+	}
+	if !first.IsPrimary || svc.State().Account.ID != work.Sub {
+		t.Fatalf("first account should be primary and active: %#v", svc.State())
+	}
+	if _, err := svc.addAccount(ctx, token("r2"), home, SignInOptions{}); !errors.Is(err, errs.ErrConflict) {
+		t.Fatalf("second account without add: expected conflict, got %v", err)
+	}
+	if _, err := svc.addAccount(ctx, token("r2"), home, SignInOptions{Add: true}); !errors.Is(err, errs.ErrConflict) {
+		t.Fatalf("second account into the same sync root: expected conflict, got %v", err)
+	}
+	second, err := svc.addAccount(ctx, token("r2"), home, SignInOptions{Add: true, SyncRoot: "/home/u/Home Drive"})
+	if err != nil {
+		t.Fatalf("add second account: %v", err)
+	}
+	if second.IsPrimary || svc.State().Account.ID != work.Sub {
+		t.Fatalf("adding an account should not change the active one: %#v", svc.State())
+	}
+	if _, err := svc.addAccount(ctx, token("r3"), work, SignInOptions{}); err != nil {
+		t.Fatalf("signing the active account in again: %v", err)
+	}
+	if got, _ := keyring.Get(cfg.AppName, work.Sub); got != "r3" {
+		t.Fatalf("keyring entry for %s = %q, want r3", work.Sub, got)
+	}
+	if got, _ := keyring.Get(cfg.AppName, home.Sub); got != "r2" {
+		t.Fatalf("keyring entry for %s = %q, want r2", home.Sub, got)
+	}
+
+	accounts, err := svc.Accounts(ctx)
+	if err != nil || len(accounts) != 2 {
+		t.Fatalf("Accounts = %v, %v; want the two signed-in accounts", accounts, err)
+	}
+	if _, err := svc.Switch(ctx, "HOME@example.com"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if svc.State().Account.ID != home.Sub || svc.SyncRoot() != "/home/u/Home Drive" {
+		t.Fatalf("after switch: state %#v, sync root %q", svc.State(), svc.SyncRoot())
+	}
+	if acct, _ := store.GetAccount(ctx, work.Sub); acct == nil || acct.IsPrimary {
+		t.Fatalf("switching should clear the old primary: %#v", acct)
+	}
+
+	if err := svc.SignOut(ctx, home.Sub); err != nil {
+		t.Fatalf("SignOut: %v", err)
+	}
+	state := svc.State()
+	if !state.SignedIn || state.Account.ID != work.Sub || !state.Account.IsPrimary {
+		t.Fatalf("signing out the active account should fall back to the other: %#v", state)
+	}
+	if svc.SyncRoot() != cfg.SyncRoot {
+		t.Fatalf("sync root = %q, want %q", svc.SyncRoot(), cfg.SyncRoot)
+	}
+	if _, err := keyring.Get(cfg.AppName, home.Sub); !errors.Is(err, keyring.ErrNotFound) {
+		t.Fatalf("keyring entry should be gone after sign-out, got %v", err)
+	}
+
+	reopened, err := NewService(ctx, zap.NewNop(), &config.Config{AppName: cfg.AppName, Account: "work@example.com"}, store)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	if reopened.State().Account.ID != work.Sub {
+		t.Fatalf("account setting should select %s, got %#v", work.Sub, reopened.State())
+	}
+}
+
+func TestServiceAccountTokens(t *testing.T) {
+	var assertion string
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertion = r.FormValue("assertion")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"sa-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	keyJSON, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "sync@project.iam.gserviceaccount.com",
+		"private_key":  string(pemKey),
+		"token_uri":    tokenServer.URL,
+	})
+	keyPath := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(keyPath, keyJSON, 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	store := newTestStore(t)
+	ctx := t.Context()
+	cfg := &config.Config{ServiceAccountKey: keyPath, ServiceAccountSubject: "user@example.com"}
+	svc, err := NewService(ctx, zap.NewNop(), cfg, store)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	state := svc.State()
+	if !state.SignedIn || state.Account.ID != "user@example.com" {
+		t.Fatalf("expected the subject to be the active account, got %#v", state)
+	}
+	token, err := svc.RefreshAccessToken(ctx, "user@example.com")
+	if err != nil {
+		t.Fatalf("RefreshAccessToken: %v", err)
+	}
+	if token.AccessToken != "sa-token" {
+		t.Fatalf("access token = %q", token.AccessToken)
+	}
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected a signed JWT assertion, got %q", assertion)
+	}
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var jwtClaims struct {
+		Iss   string `json:"iss"`
+		Sub   string `json:"sub"`
+		Scope string `json:"scope"`
+	}
+	_ = json.Unmarshal(claims, &jwtClaims)
+	if jwtClaims.Iss != "sync@project.iam.gserviceaccount.com" || jwtClaims.Sub != "user@example.com" || jwtClaims.Scope != driveScope {
+		t.Fatalf("unexpected assertion claims %+v", jwtClaims)
+	}
+
+	if _, err := svc.SignIn(ctx, SignInOptions{}); !errors.Is(err, errs.ErrInvalidArgument) {
+		t.Fatalf("SignIn with a service account: expected invalid argument, got %v", err)
+	}
+	diags, err := svc.Diagnose(ctx, "user@example.com")
+	if err != nil || len(diags) != 1 {
+		t.Fatalf("Diagnose = %v, %v", diags, err)
+	}
+	if diags[0].ServiceAccount == "" || len(diags[0].Problems()) != 0 {
+		t.Fatalf("expected a healthy service-account diagnosis, got %#v (%v)", diags[0], diags[0].Problems())
+	}
+
+	cfg.ServiceAccountKey = filepath.Join(t.TempDir(), "missing.json")
+	if _, err := NewService(ctx, zap.NewNop(), cfg, store); !errors.Is(err, errs.ErrInvalidArgument) {
+		t.Fatalf("missing key: expected invalid argument, got %v", err)
+	}
+}
+
+func TestTokenSourceCachesAndSharesRefreshes(t *testing.T) {
+	store := newTestStore(t)
+	svc, err := NewService(t.Context(), zap.NewNop(), &config.Config{}, store)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	var calls atomic.Int32
+	release := make(chan struct{})
+	svc.refresh = func(ctx context.Context, accountID string) (*oauth2.Token, error) {
+		n := calls.Add(1)
+		<-release
+		return &oauth2.Token{AccessToken: fmt.Sprintf("%s-%d", accountID, n), Expiry: now.Add(time.Hour)}, nil
+	}
+
+	// Concurrent callers without a token share one refresh.
+	src := svc.TokenSource("acct")
+	var wg sync.WaitGroup
+	tokens := make([]string, 8)
+	for i := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tok, err := src.Token()
+			if err != nil {
+				t.Errorf("Token: %v", err)
+				return
+			}
+			tokens[i] = tok.AccessToken
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Fatalf("expected one shared refresh, got %d", calls.Load())
+	}
+	for _, tok := range tokens {
+		if tok != "acct-1" {
+			t.Fatalf("tokens = %v, want all acct-1", tokens)
+		}
+	}
+
+	// A valid token is served from the cache, also through a new source.
+	if tok, _ := svc.TokenSource("acct").Token(); tok.AccessToken != "acct-1" || calls.Load() != 1 {
+		t.Fatalf("expected the cached token without a refresh, got %s after %d refreshes", tok.AccessToken, calls.Load())
+	}
+
+	// Inside the refresh margin the old token is still served while a new
+	// one is fetched in the background.
+	now = now.Add(time.Hour - tokenRefreshMargin + time.Second)
+	if tok, _ := src.Token(); tok.AccessToken != "acct-1" {
+		t.Fatalf("expected the still-valid token during the background refresh, got %s", tok.AccessToken)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		tok, _ := src.Token()
+		if tok.AccessToken == "acct-2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("background refresh never landed; last token %s", tok.AccessToken)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Signing out drops the cache.
+	svc.forgetToken("acct")
+	if tok, _ := src.Token(); tok.AccessToken != "acct-3" {
+		t.Fatalf("expected a fresh token after forgetting the cache, got %s", tok.AccessToken)
+	}
+}
+
+func TestPreRefresherRenewsBusyAccountsAhead(t *testing.T) {
+	store := newTestStore(t)
+	svc, err := NewService(t.Context(), zap.NewNop(), &config.Config{}, store)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	var calls atomic.Int32
+	svc.refresh = func(ctx context.Context, accountID string) (*oauth2.Token, error) {
+		n := calls.Add(1)
+		return &oauth2.Token{AccessToken: fmt.Sprintf("%s-%d", accountID, n), Expiry: now.Add(time.Hour)}, nil
+	}
+	busy := []string{"acct"}
+	pre := NewPreRefresher(zap.NewNop(), svc, func(context.Context) []string { return busy })
+
+	// A busy account without a token gets one.
+	pre.refreshDue(t.Context())
+	if calls.Load() != 1 {
+		t.Fatalf("expected a refresh for the busy account, got %d", calls.Load())
+	}
+
+	// A token well before expiry is left alone.
+	now = now.Add(time.Hour - preRefreshLead - time.Minute)
+	pre.refreshDue(t.Context())
+	if calls.Load() != 1 {
+		t.Fatalf("expected no refresh ahead of the lead, got %d", calls.Load())
+	}
+
+	// Within the lead, and before the lazy refresh margin, it is renewed so
+	// requests never wait.
+	now = now.Add(2 * time.Minute)
+	pre.refreshDue(t.Context())
+	if calls.Load() != 2 {
+		t.Fatalf("expected a refresh within the lead, got %d", calls.Load())
+	}
+	if tok, _ := svc.TokenSource("acct").Token(); tok.AccessToken != "acct-2" || calls.Load() != 2 {
+		t.Fatalf("expected the renewed token from the cache, got %s after %d refreshes", tok.AccessToken, calls.Load())
+	}
+
+	// Idle accounts are not refreshed.
+	busy = nil
+	now = now.Add(time.Hour)
+	pre.refreshDue(t.Context())
+	if calls.Load() != 2 {
+		t.Fatalf("expected no refresh for an idle account, got %d", calls.Load())
+	}
+
+	// A failed renewal waits out the retry delay.
+	busy = []string{"acct"}
+	svc.refresh = func(ctx context.Context, accountID string) (*oauth2.Token, error) {
+		calls.Add(1)
+		return nil, errors.New("network down")
+	}
+	pre.refreshDue(t.Context())
+	pre.refreshDue(t.Context())
+	if calls.Load() != 3 {
+		t.Fatalf("expected one failed refresh before the retry delay, got %d", calls.Load())
+	}
+}
+
+func TestSignInCancelAndTimeout(t *testing.T) {
+	keyring.MockInit()
+	ctx := t.Context()
+	cfg := &config.Config{AppName: "googlysync-test", OAuthClientID: "id", OAuthClientSecret: "secret", OAuthRedirectHost: "127.0.0.1", SignInTimeoutSeconds: 60}
+	svc, err := NewService(ctx, zap.NewNop(), cfg, newTestStore(t))
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	urls := make(chan string, 1)
+	opts := SignInOptions{OpenURL: func(authURL string) error {
+		urls <- authURL
+		return nil
+	}}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := svc.SignIn(ctx, opts)
+		done <- err
+	}()
+	authURL, err := neturl.Parse(<-urls)
+	if err != nil {
+		t.Fatalf("parse consent url: %v", err)
+	}
+	if n := svc.CancelSignIn(); n != 1 {
+		t.Fatalf("CancelSignIn = %d, want 1", n)
+	}
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled sign-in: expected context.Canceled, got %v", err)
+	}
+	// The callback server is gone with the flow.
+	if resp, err := http.Get(authURL.Query().Get("redirect_uri")); err == nil {
+		resp.Body.Close()
+		t.Fatalf("callback server still answers after the flow was canceled")
+	}
+	if n := svc.CancelSignIn(); n != 0 {
+		t.Fatalf("CancelSignIn with no flow = %d, want 0", n)
+	}
+
+	cfg.SignInTimeoutSeconds = 1
+	if _, err := svc.SignIn(ctx, opts); !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "within 1s") {
+		t.Fatalf("abandoned sign-in: expected a timeout, got %v", err)
+	}
+}
+
 func TestScopeStringDedupes(t *testing.T) {
 	got := scopeString([]string{"b", "a", "b", "", "a"})
 	if got != "a b" {
@@ -174,3 +527,420 @@ func TestPKCEPair(t *testing.T) {
 		t.Fatal("expected verifier and challenge to differ")
 	}
 }
+
+func TestPastedCode(t *testing.T) {
+	cases := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "4/0AbCdEf\n", want: "4/0AbCdEf"},
+		{input: "http://127.0.0.1:43121/oauth/callback?state=st&code=4%2F0Ab&scope=email", want: "4/0Ab"},
+		{input: "http://127.0.0.1:43121/oauth/callback?state=other&code=4%2F0Ab", wantErr: true},
+		{input: "http://127.0.0.1:43121/oauth/callback?state=st&error=access_denied", wantErr: true},
+		{input: "http://127.0.0.1:43121/oauth/callback?state=st", wantErr: true},
+		{input: "  ", wantErr: true},
+	}
+	for _, tc := range cases {
+		got, err := pastedCode(tc.input, "st")
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("pastedCode(%q) = %q, want error", tc.input, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("pastedCode(%q) = %q, %v; want %q", tc.input, got, err, tc.want)
+		}
+	}
+}
+
+func TestRefreshErrInvalidGrant(t *testing.T) {
+	revoked := &oauth2.RetrieveError{ErrorCode: "invalid_grant"}
+	if err := refreshErr(revoked); !errors.Is(err, errs.ErrAuthExpired) {
+		t.Fatalf("expected invalid_grant to be auth expired, got %v", err)
+	}
+	transient := &oauth2.RetrieveError{ErrorCode: "temporarily_unavailable"}
+	if err := refreshErr(transient); errors.Is(err, errs.ErrAuthExpired) {
+		t.Fatalf("expected other errors untagged, got %v", err)
+	}
+}
+
+func TestDiagnoseReportsTokenHealth(t *testing.T) {
+	keyring.MockInit()
+	store := newTestStore(t)
+	ctx := t.Context()
+
+	for _, id := range []string{"healthy", "no-token"} {
+		account := storage.Account{ID: id, Email: id + "@example.com", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+		if err := store.UpsertAccount(ctx, &account); err != nil {
+			t.Fatalf("UpsertAccount: %v", err)
+		}
+	}
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	ref := storage.TokenRef{AccountID: "healthy", KeyID: "healthy", TokenType: "refresh", Scope: "email " + driveScope, Expiry: expiry, UpdatedAt: time.Now()}
+	if err := store.UpsertTokenRef(ctx, &ref); err != nil {
+		t.Fatalf("UpsertTokenRef: %v", err)
+	}
+	svc, err := NewService(ctx, zap.NewNop(), &config.Config{}, store)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	if err := keyring.Set(svc.krSvc, "healthy", "refresh-token"); err != nil {
+		t.Fatalf("keyring.Set: %v", err)
+	}
+
+	diags, err := svc.Diagnose(ctx, "")
+	if err != nil {
+		t.Fatalf("Diagnose: %v", err)
+	}
+	byID := make(map[string]Diagnosis)
+	for _, d := range diags {
+		byID[d.Account.ID] = d
+	}
+	healthy := byID["healthy"]
+	if !healthy.KeyringReachable || !healthy.RefreshTokenStored || !healthy.TokenExpiry.Equal(expiry) {
+		t.Fatalf("unexpected healthy diagnosis: %#v", healthy)
+	}
+	if problems := healthy.Problems(); len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+	if problems := byID["no-token"].Problems(); len(problems) != 2 {
+		t.Fatalf("expected missing token and keyring entry, got %v", problems)
+	}
+
+	svc.noteRefresh("healthy", errs.New(errs.ErrAuthExpired, "invalid_grant"))
+	diags, err = svc.Diagnose(ctx, "healthy")
+	if err != nil || len(diags) != 1 {
+		t.Fatalf("Diagnose healthy: %v, %v", diags, err)
+	}
+	if problems := diags[0].Problems(); len(problems) != 1 || !strings.Contains(problems[0], "invalid_grant") {
+		t.Fatalf("expected refresh failure reported, got %v", problems)
+	}
+	if _, err := svc.Diagnose(ctx, "missing"); !errors.Is(err, errs.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestOnAuthExpiredFiresOncePerExpiry(t *testing.T) {
+	svc, err := NewService(t.Context(), zap.NewNop(), &config.Config{}, newTestStore(t))
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	var fired []string
+	svc.OnAuthExpired(func(accountID string, _ error) { fired = append(fired, accountID) })
+
+	expired := errs.New(errs.ErrAuthExpired, "invalid_grant")
+	svc.noteRefresh("acct-1", errors.New("network down"))
+	svc.noteRefresh("acct-1", expired)
+	svc.noteRefresh("acct-1", expired)
+	if len(fired) != 1 || fired[0] != "acct-1" {
+		t.Fatalf("expected one expiry callback, got %v", fired)
+	}
+	svc.noteRefresh("acct-1", nil)
+	svc.noteRefresh("acct-1", expired)
+	if len(fired) != 2 {
+		t.Fatalf("expected a new expiry after recovery to fire, got %v", fired)
+	}
+}
+
+func TestIncrementalConsentMergesScopes(t *testing.T) {
+	keyring.MockInit()
+	ctx := t.Context()
+	cfg := &config.Config{AppName: "googlysync-test", SyncRoot: "/home/u/Drive", DriveAccess: driveAccessApp}
+	svc, err := NewService(ctx, zap.NewNop(), cfg, newTestStore(t))
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	work := idTokenClaims{Sub: "sub-work", Email: "work@example.com"}
+	token := &oauth2.Token{RefreshToken: "r1", Expiry: time.Now().Add(time.Hour)}
+	if _, err := svc.addAccount(ctx, token, work, SignInOptions{Scopes: defaultScopes(driveAccessApp)}); err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if missing, err := svc.MissingScopes(ctx, work.Sub); err != nil || len(missing) != 0 {
+		t.Fatalf("MissingScopes under app access = %v, %v; want none", missing, err)
+	}
+
+	// Turning on full access needs the drive scope.
+	cfg.DriveAccess = driveAccessFull
+	if missing, _ := svc.MissingScopes(ctx, work.Sub); len(missing) != 1 || missing[0] != driveScope {
+		t.Fatalf("MissingScopes under full access = %v; want %s", missing, driveScope)
+	}
+	diags, err := svc.Diagnose(ctx, work.Sub)
+	if err != nil || len(diags) != 1 || !strings.Contains(strings.Join(diags[0].Problems(), "\n"), "login --account work@example.com") {
+		t.Fatalf("expected a problem naming the consent command, got %v, %v", diags, err)
+	}
+
+	// Granting just the missing scope keeps the ones granted before.
+	token = &oauth2.Token{RefreshToken: "r2", Expiry: time.Now().Add(time.Hour)}
+	if _, err := svc.addAccount(ctx, token, work, SignInOptions{Scopes: []string{"openid", "email", driveScope}}); err != nil {
+		t.Fatalf("consent: %v", err)
+	}
+	ref, err := svc.store.GetTokenRef(ctx, work.Sub)
+	if err != nil || !hasScope(strings.Fields(ref.Scope), driveFileScope) || !hasScope(strings.Fields(ref.Scope), driveScope) {
+		t.Fatalf("expected drive.file and drive merged, got %#v, %v", ref, err)
+	}
+	if missing, _ := svc.MissingScopes(ctx, work.Sub); len(missing) != 0 {
+		t.Fatalf("MissingScopes after consent = %v; want none", missing)
+	}
+
+	// The scopes in the token response are what was granted.
+	token = (&oauth2.Token{RefreshToken: "r3", Expiry: time.Now().Add(time.Hour)}).WithExtra(map[string]any{"scope": "openid " + driveFileScope})
+	if _, err := svc.addAccount(ctx, token, work, SignInOptions{Scopes: defaultScopes(driveAccessFull)}); err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if missing, _ := svc.MissingScopes(ctx, work.Sub); len(missing) != 1 || missing[0] != driveScope {
+		t.Fatalf("MissingScopes after a narrower grant = %v; want %s", missing, driveScope)
+	}
+}
+
+func TestFailedSignInKeepsPreviousTokenRef(t *testing.T) {
+	keyring.MockInit()
+	ctx := t.Context()
+	svc, err := NewService(ctx, zap.NewNop(), &config.Config{AppName: "googlysync-test", SyncRoot: "/home/u/Drive"}, newTestStore(t))
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	work := idTokenClaims{Sub: "sub-work", Email: "work@example.com"}
+	token := &oauth2.Token{RefreshToken: "r1", Expiry: time.Now().Add(time.Hour)}
+	if _, err := svc.addAccount(ctx, token, work, SignInOptions{}); err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	before, err := svc.store.GetTokenRef(ctx, work.Sub)
+	if err != nil || before == nil {
+		t.Fatalf("GetTokenRef: %#v, %v", before, err)
+	}
+
+	// A re-sign-in the keyring refuses leaves the working token in place.
+	keyring.MockInitWithError(errors.New("keyring locked"))
+	t.Cleanup(keyring.MockInit)
+	token = &oauth2.Token{RefreshToken: "r2", Expiry: time.Now().Add(2 * time.Hour)}
+	if _, err := svc.addAccount(ctx, token, work, SignInOptions{}); err == nil {
+		t.Fatal("expected the keyring failure")
+	}
+	if ref, err := svc.store.GetTokenRef(ctx, work.Sub); err != nil || ref == nil || !ref.Expiry.Equal(before.Expiry) {
+		t.Fatalf("expected the previous token ref kept, got %#v, %v", ref, err)
+	}
+
+	// A new account whose token cannot be stored is left without one.
+	other := idTokenClaims{Sub: "sub-home", Email: "home@example.com"}
+	if _, err := svc.addAccount(ctx, token, other, SignInOptions{Add: true}); err == nil {
+		t.Fatal("expected the keyring failure")
+	}
+	if ref, err := svc.store.GetTokenRef(ctx, other.Sub); err != nil || ref != nil {
+		t.Fatalf("expected no token ref for the new account, got %#v, %v", ref, err)
+	}
+}
+
+func TestWatchReportsAccountAndTokenChanges(t *testing.T) {
+	keyring.MockInit()
+	ctx := t.Context()
+	svc, err := NewService(ctx, zap.NewNop(), &config.Config{AppName: "googlysync-test", SyncRoot: "/home/u/Drive"}, newTestStore(t))
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	events, stop := svc.Watch()
+	next := func() string {
+		t.Helper()
+		select {
+		case evt := <-events:
+			return string(evt.Kind) + " " + evt.Email
+		default:
+			return ""
+		}
+	}
+	token := &oauth2.Token{RefreshToken: "r1", Expiry: time.Now().Add(time.Hour)}
+	work := idTokenClaims{Sub: "sub-work", Email: "work@example.com"}
+	home := idTokenClaims{Sub: "sub-home", Email: "home@example.com"}
+
+	if _, err := svc.addAccount(ctx, token, work, SignInOptions{}); err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if _, err := svc.addAccount(ctx, token, home, SignInOptions{Add: true, SyncRoot: "/home/u/Home"}); err != nil {
+		t.Fatalf("add account: %v", err)
+	}
+	svc.noteRefresh(work.Sub, errs.New(errs.ErrAuthExpired, "invalid_grant"))
+	svc.noteRefresh(work.Sub, errs.New(errs.ErrAuthExpired, "invalid_grant"))
+	svc.noteRefresh(work.Sub, nil)
+	if err := svc.SignOut(ctx, work.Sub); err != nil {
+		t.Fatalf("SignOut: %v", err)
+	}
+
+	want := []string{
+		"account_added work@example.com",
+		"active_changed work@example.com",
+		"account_added home@example.com",
+		"token_invalid work@example.com",
+		"token_valid work@example.com",
+		"primary_changed home@example.com",
+		"account_removed work@example.com",
+		"active_changed home@example.com",
+		"",
+	}
+	for _, w := range want {
+		if got := next(); got != w {
+			t.Fatalf("event = %q, want %q", got, w)
+		}
+	}
+
+	stop()
+	stop()
+	if _, ok := <-events; ok {
+		t.Fatal("expected the channel closed after stop")
+	}
+	if err := svc.SignOut(ctx, home.Sub); err != nil {
+		t.Fatalf("SignOut after stop: %v", err)
+	}
+}
+
+func TestRevokedTokenNeedsSignIn(t *testing.T) {
+	keyring.MockInit()
+	ctx := t.Context()
+	store := newTestStore(t)
+	svc, err := NewService(ctx, zap.NewNop(), &config.Config{AppName: "googlysync-test", OAuthClientID: "id", OAuthClientSecret: "secret"}, store)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	token := &oauth2.Token{RefreshToken: "r1", Expiry: time.Now().Add(time.Hour)}
+	work := idTokenClaims{Sub: "sub-work", Email: "work@example.com"}
+	if _, err := svc.addAccount(ctx, token, work, SignInOptions{}); err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+
+	svc.noteRefresh(work.Sub, errs.New(errs.ErrAuthExpired, "invalid_grant"))
+	if acct, _ := store.GetAccount(ctx, work.Sub); acct == nil || acct.ReauthReason == "" || acct.ReauthAt.IsZero() {
+		t.Fatalf("expected the account marked for sign-in, got %#v", acct)
+	}
+	if svc.State().Account.ReauthReason == "" {
+		t.Fatalf("expected State to carry the mark: %#v", svc.State())
+	}
+	// The token endpoint is not asked again.
+	if _, err := svc.RefreshAccessToken(ctx, work.Sub); !errors.Is(err, errs.ErrAuthExpired) || !strings.Contains(err.Error(), "sign in again") {
+		t.Fatalf("expected auth expired without a refresh, got %v", err)
+	}
+
+	if _, err := svc.addAccount(ctx, token, work, SignInOptions{}); err != nil {
+		t.Fatalf("sign in again: %v", err)
+	}
+	if acct, _ := store.GetAccount(ctx, work.Sub); acct == nil || acct.ReauthReason != "" {
+		t.Fatalf("expected the mark cleared by signing in, got %#v", acct)
+	}
+	if svc.State().Account.ReauthReason != "" {
+		t.Fatalf("expected State cleared: %#v", svc.State())
+	}
+}
+
+func TestAllowedDomains(t *testing.T) {
+	keyring.MockInit()
+	ctx := t.Context()
+	store := newTestStore(t)
+	cfg := &config.Config{AppName: "googlysync-test", AllowedDomains: []string{"example.com", "corp.example.org"}}
+	svc, err := NewService(ctx, zap.NewNop(), cfg, store)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	token := &oauth2.Token{RefreshToken: "r1", Expiry: time.Now().Add(time.Hour)}
+	for _, claims := range []idTokenClaims{
+		{Sub: "sub-home", Email: "me@gmail.com"},
+		{Sub: "sub-other", Email: "me@other.com", HD: "other.com"},
+	} {
+		if _, err := svc.addAccount(ctx, token, claims, SignInOptions{}); !errors.Is(err, errs.ErrInvalidArgument) {
+			t.Fatalf("%s: expected refused, got %v", claims.Email, err)
+		}
+		if acct, _ := store.GetAccount(ctx, claims.Sub); acct != nil {
+			t.Fatalf("%s: refused account was stored", claims.Email)
+		}
+	}
+	if _, err := svc.addAccount(ctx, token, idTokenClaims{Sub: "sub-work", Email: "me@corp.example.org", HD: "Corp.Example.org"}, SignInOptions{}); err != nil {
+		t.Fatalf("allowed domain: %v", err)
+	}
+
+	if got := hostedDomainHint(cfg.AllowedDomains); got != "*" {
+		t.Fatalf("hd for several domains = %q, want *", got)
+	}
+	if got := hostedDomainHint([]string{"example.com"}); got != "example.com" {
+		t.Fatalf("hd for one domain = %q", got)
+	}
+	if got := hostedDomainHint(nil); got != "" {
+		t.Fatalf("hd without allowed_domains = %q", got)
+	}
+}
+
+func TestOAuthClientFromCredentialsFile(t *testing.T) {
+	var form atomic.Value
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		form.Store(r.Form.Encode())
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"user-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	dir := t.TempDir()
+	creds := `{"installed":{"client_id":"file-id","client_secret":"file-secret","auth_uri":"https://auth.example.com/o","token_uri":"https://token.example.com/t","redirect_uris":["http://localhost"]}}`
+	if err := os.WriteFile(filepath.Join(dir, credentialsFileName), []byte(creds), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{ConfigDir: dir}
+	client, err := loadOAuthClient(cfg)
+	if err != nil {
+		t.Fatalf("loadOAuthClient: %v", err)
+	}
+	if client.ClientID != "file-id" || client.ClientSecret != "file-secret" || client.Endpoint.AuthURL != "https://auth.example.com/o" {
+		t.Fatalf("credentials file not applied: %+v", client)
+	}
+
+	keyring.MockInit()
+	store := newTestStore(t)
+	ctx := t.Context()
+	cfg.OAuthClientSecret = "config-secret"
+	cfg.OAuthTokenURL = tokenServer.URL
+	svc, err := NewService(ctx, zap.NewNop(), cfg, store)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	if _, err := svc.addAccount(ctx, &oauth2.Token{RefreshToken: "refresh-1"}, idTokenClaims{Sub: "acct-1", Email: "user@example.com"}, SignInOptions{}); err != nil {
+		t.Fatalf("addAccount: %v", err)
+	}
+	token, err := svc.RefreshAccessToken(ctx, "acct-1")
+	if err != nil {
+		t.Fatalf("RefreshAccessToken: %v", err)
+	}
+	if token.AccessToken != "user-token" {
+		t.Fatalf("access token = %q", token.AccessToken)
+	}
+	sent, _ := form.Load().(string)
+	if !strings.Contains(sent, "refresh_token=refresh-1") {
+		t.Fatalf("token request form = %q", sent)
+	}
+
+	cfg.OAuthCredentialsFile = filepath.Join(dir, "missing.json")
+	if _, err := loadOAuthClient(cfg); !errors.Is(err, errs.ErrInvalidArgument) {
+		t.Fatalf("missing credentials file: expected invalid argument, got %v", err)
+	}
+}
+
+func TestAppDriveAccessScopes(t *testing.T) {
+	scopes := defaultScopes(driveAccessApp)
+	if hasScope(scopes, driveScope) || !hasScope(scopes, driveFileScope) || !hasScope(scopes, driveAppDataScope) {
+		t.Fatalf("app access scopes = %v", scopes)
+	}
+
+	d := Diagnosis{HasTokenRef: true, KeyringReachable: true, RefreshTokenStored: true, Scopes: []string{"email", driveFileScope}, DriveScope: driveScopeFor(driveAccessApp)}
+	if problems := d.Problems(); len(problems) != 0 {
+		t.Fatalf("drive.file under app access: expected healthy, got %v", problems)
+	}
+	d.DriveScope = driveScopeFor(driveAccessFull)
+	if problems := d.Problems(); len(problems) != 1 {
+		t.Fatalf("drive.file under full access: expected a scope problem, got %v", problems)
+	}
+	d.Scopes = []string{driveScope}
+	d.DriveScope = driveScopeFor(driveAccessApp)
+	if problems := d.Problems(); len(problems) != 0 {
+		t.Fatalf("full drive under app access: expected healthy, got %v", problems)
+	}
+
+	if _, err := NewService(t.Context(), zap.NewNop(), &config.Config{DriveAccess: "everything"}, newTestStore(t)); !errors.Is(err, errs.ErrInvalidArgument) {
+		t.Fatalf("unknown drive_access: expected invalid argument, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// credentialsFileName is the client file looked for in the config dir when
+// oauth_credentials_file is not set.
+const credentialsFileName = "credentials.json"
+
+// loadOAuthClient builds the OAuth client users sign in and refresh through.
+// A credentials.json downloaded from the Google Cloud console gives the
+// client and its endpoints; oauth_client_id, oauth_client_secret and the
+// endpoint URLs in the config or environment override what it says. A
+// missing default file is not an error, a missing configured one is.
+func loadOAuthClient(cfg *config.Config) (*oauth2.Config, error) {
+	client := &oauth2.Config{Endpoint: google.Endpoint}
+	path := cfg.OAuthCredentialsFile
+	if path == "" && cfg.ConfigDir != "" {
+		path = filepath.Join(cfg.ConfigDir, credentialsFileName)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			path = ""
+		}
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errs.New(errs.ErrInvalidArgument, "read oauth credentials: %v", err)
+		}
+		if client, err = google.ConfigFromJSON(data); err != nil {
+			return nil, errs.New(errs.ErrInvalidArgument, "oauth credentials %s: %v", path, err)
+		}
+	}
+
+	if cfg.OAuthClientID != "" {
+		client.ClientID = cfg.OAuthClientID
+	}
+	if cfg.OAuthClientSecret != "" {
+		client.ClientSecret = cfg.OAuthClientSecret
+	}
+	if cfg.OAuthAuthURL != "" {
+		client.Endpoint.AuthURL = cfg.OAuthAuthURL
+	}
+	if cfg.OAuthTokenURL != "" {
+		client.Endpoint.TokenURL = cfg.OAuthTokenURL
+	}
+	// The flow picks its own loopback redirect and scopes.
+	client.RedirectURL = ""
+	client.Scopes = nil
+	return client, nil
+}
+
+// oauthClient returns a copy of the service's client for one flow or refresh.
+func (s *Service) oauthClient() (*oauth2.Config, error) {
+	if s.client.ClientID == "" {
+		return nil, errors.New("oauth client id not configured")
+	}
+	if s.client.ClientSecret == "" {
+		return nil, errors.New("oauth client secret not configured")
+	}
+	client := *s.client
+	return &client, nil
+}
@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zalando/go-keyring"
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// Drive scopes. driveScope lets sync read and write all of the user's files;
+// drive_access "app" asks only for driveFileScope, which covers the files
+// googlysync created, and driveAppDataScope.
+const (
+	driveScope        = "https://www.googleapis.com/auth/drive"
+	driveFileScope    = "https://www.googleapis.com/auth/drive.file"
+	driveAppDataScope = "https://www.googleapis.com/auth/drive.appdata"
+)
+
+// Values of drive_access.
+const (
+	driveAccessFull = "full"
+	driveAccessApp  = "app"
+)
+
+// driveScopeFor returns the Drive scope the drive_access value access calls
+// for.
+func driveScopeFor(access string) string {
+	if access == driveAccessApp {
+		return driveFileScope
+	}
+	return driveScope
+}
+
+// Diagnosis is a snapshot of one account's token health.
+type Diagnosis struct {
+	Account storage.Account
+	// HasTokenRef is false when the account has no stored token metadata,
+	// e.g. after an interrupted sign-in.
+	HasTokenRef bool
+	Scopes      []string
+	// DriveScope is the Drive scope drive_access calls for. The full drive
+	// scope satisfies either.
+	DriveScope string
+	// TokenExpiry is the expiry of the most recent access token.
+	TokenExpiry time.Time
+	// LastRefresh and LastRefreshError cover refreshes made by this process.
+	LastRefresh        time.Time
+	LastRefreshError   string
+	LastRefreshErrorAt time.Time
+	// TokenStore is where the refresh token is kept: "keyring", "file", or
+	// the keyring_backend in use; the Keyring fields describe that store.
+	TokenStore         string
+	KeyringReachable   bool
+	RefreshTokenStored bool
+	KeyringError       string
+	// ServiceAccount is the service account whose key mints the account's
+	// tokens; the keyring is not used then.
+	ServiceAccount string
+}
+
+// refreshResult records the outcome of the latest refreshes for an account.
+type refreshResult struct {
+	success time.Time
+	err     string
+	errAt   time.Time
+	// expired is set from an ErrAuthExpired failure until the next success.
+	expired bool
+}
+
+// Problems lists what is wrong with the account in plain words. It is empty
+// for a healthy account.
+func (d Diagnosis) Problems() []string {
+	var out []string
+	if d.ServiceAccount != "" {
+		if d.LastRefreshError != "" && d.LastRefreshErrorAt.After(d.LastRefresh) {
+			out = append(out, "last token request failed: "+d.LastRefreshError+"; check the key and its domain-wide delegation")
+		}
+		return out
+	}
+	if d.Account.ReauthReason != "" {
+		out = append(out, "sign-in revoked or expired; run googlysync login: "+d.Account.ReauthReason)
+	}
+	if !d.HasTokenRef {
+		out = append(out, "no token stored; sign in again")
+	}
+	store := d.TokenStore
+	switch store {
+	case "":
+		store = TokenStoreKeyring
+	case TokenStoreFile:
+		store = "token file"
+	}
+	switch {
+	case !d.KeyringReachable:
+		out = append(out, store+" unreachable: "+d.KeyringError)
+	case !d.RefreshTokenStored:
+		out = append(out, "refresh token missing from "+store+"; sign in again")
+	}
+	if d.HasTokenRef {
+		if missing := missingScopes(d.Scopes, []string{d.DriveScope}); len(missing) > 0 {
+			out = append(out, fmt.Sprintf("%s not granted; run googlysync login --account %s and allow Drive access", strings.Join(missing, " "), d.Account.Email))
+		}
+	}
+	// A reauth mark already names the failure that set it.
+	if d.Account.ReauthReason == "" && d.LastRefreshError != "" && d.LastRefreshErrorAt.After(d.LastRefresh) {
+		out = append(out, "last token refresh failed: "+d.LastRefreshError)
+	}
+	return out
+}
+
+// Diagnose reports token health for one account, or for every account when
+// accountID is empty. It reads the keyring but never refreshes a token.
+func (s *Service) Diagnose(ctx context.Context, accountID string) ([]Diagnosis, error) {
+	var accounts []storage.Account
+	if accountID != "" {
+		account, err := s.store.GetAccount(ctx, accountID)
+		if err != nil {
+			return nil, err
+		}
+		if account == nil {
+			return nil, errs.New(errs.ErrNotFound, "account %s not found", accountID)
+		}
+		accounts = append(accounts, *account)
+	} else {
+		var err error
+		if accounts, err = s.store.ListAccounts(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([]Diagnosis, 0, len(accounts))
+	for _, account := range accounts {
+		d := Diagnosis{Account: account, DriveScope: driveScopeFor(s.cfg.DriveAccess)}
+		if s.sa != nil && account.ID == s.sa.email {
+			d.ServiceAccount = s.sa.client
+			d.Scopes = []string{d.DriveScope}
+			s.mu.Lock()
+			r := s.refreshes[account.ID]
+			s.mu.Unlock()
+			d.LastRefresh, d.LastRefreshError, d.LastRefreshErrorAt = r.success, r.err, r.errAt
+			out = append(out, d)
+			continue
+		}
+		ref, err := s.store.GetTokenRef(ctx, account.ID)
+		if err != nil {
+			return nil, err
+		}
+		if ref != nil {
+			d.HasTokenRef = true
+			d.Scopes = strings.Fields(ref.Scope)
+			d.TokenExpiry = ref.Expiry
+		}
+
+		d.TokenStore = s.secrets.Name()
+		_, err = s.secrets.Get(account.ID)
+		switch {
+		case err == nil:
+			d.KeyringReachable = true
+			d.RefreshTokenStored = true
+		case errors.Is(err, keyring.ErrNotFound):
+			d.KeyringReachable = true
+		default:
+			d.KeyringError = err.Error()
+		}
+
+		s.mu.Lock()
+		if r, ok := s.refreshes[account.ID]; ok {
+			d.LastRefresh = r.success
+			d.LastRefreshError = r.err
+			d.LastRefreshErrorAt = r.errAt
+		}
+		s.mu.Unlock()
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+func (s *Service) noteRefresh(accountID string, err error) {
+	s.mu.Lock()
+	r := s.refreshes[accountID]
+	wasExpired := r.expired
+	if err != nil {
+		r.err = err.Error()
+		r.errAt = time.Now()
+		r.expired = errors.Is(err, errs.ErrAuthExpired)
+	} else {
+		r.success = time.Now()
+		r.expired = false
+	}
+	s.refreshes[accountID] = r
+	onExpired := s.onExpired
+	s.mu.Unlock()
+
+	switch {
+	case r.expired && !wasExpired:
+		s.markReauth(accountID, r.err)
+		s.publish(Event{Kind: EventTokenInvalid, AccountID: accountID, Email: s.accountEmail(accountID), Err: r.err})
+		if onExpired != nil {
+			onExpired(accountID, err)
+		}
+	case wasExpired && err == nil:
+		s.publish(Event{Kind: EventTokenValid, AccountID: accountID, Email: s.accountEmail(accountID)})
+	}
+}
+
+func hasScope(scopes []string, want string) bool {
+	for _, scope := range scopes {
+		if scope == want {
+			return true
+		}
+	}
+	return false
+}
+
+// markReauth records in storage, and in State for the active account, that
+// an account has to sign in again, so refreshes stop and the mark survives
+// restarts.
+func (s *Service) markReauth(accountID, reason string) {
+	if err := s.store.SetAccountReauth(context.Background(), accountID, reason); err != nil {
+		s.logger.Warn("recording that the account has to sign in again failed", zap.String("account", accountID), zap.Error(err))
+	}
+	s.mu.Lock()
+	if s.state.Account.ID == accountID {
+		s.state.Account.ReauthReason = reason
+		s.state.Account.ReauthAt = s.now()
+	}
+	s.mu.Unlock()
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// EventKind says what changed in an Event.
+type EventKind string
+
+const (
+	// EventAccountAdded reports a newly signed-in account.
+	EventAccountAdded EventKind = "account_added"
+	// EventAccountRenewed reports a signed-in account signing in again.
+	EventAccountRenewed EventKind = "account_renewed"
+	// EventAccountRemoved reports a signed-out account.
+	EventAccountRemoved EventKind = "account_removed"
+	// EventActiveChanged reports that State names another account, or none.
+	EventActiveChanged EventKind = "active_changed"
+	// EventPrimaryChanged reports a new primary account.
+	EventPrimaryChanged EventKind = "primary_changed"
+	// EventTokenInvalid reports a refresh token that was revoked or expired;
+	// the account has to sign in again.
+	EventTokenInvalid EventKind = "token_invalid"
+	// EventTokenValid reports a successful refresh after EventTokenInvalid.
+	EventTokenValid EventKind = "token_valid"
+)
+
+// watchBuffer is how many events a watcher may fall behind before it misses
+// some.
+const watchBuffer = 32
+
+// Event is one change to the signed-in accounts or their tokens.
+type Event struct {
+	Kind      EventKind
+	AccountID string
+	Email     string
+	// Err is the refresh failure for EventTokenInvalid.
+	Err string
+	At  time.Time
+}
+
+// Watch returns a channel of auth events and a function that stops the
+// watch and closes the channel. Events are dropped for a watcher that does
+// not keep up, so it should re-read State when it needs to be sure.
+func (s *Service) Watch() (<-chan Event, func()) {
+	ch := make(chan Event, watchBuffer)
+	s.mu.Lock()
+	s.watchers[ch] = struct{}{}
+	s.mu.Unlock()
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.watchers[ch]; ok {
+			delete(s.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish hands evt to every watcher without blocking.
+func (s *Service) publish(evt Event) {
+	evt.At = s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.watchers {
+		select {
+		case ch <- evt:
+		default:
+			s.logger.Debug("auth watcher fell behind; event dropped", zap.String("kind", string(evt.Kind)))
+		}
+	}
+}
+
+// publishActive reports a change of the active account.
+func (s *Service) publishActive(before string) {
+	state := s.State()
+	if state.Account.ID != before {
+		s.publish(Event{Kind: EventActiveChanged, AccountID: state.Account.ID, Email: state.Account.Email})
+	}
+}
+
+// accountEmail looks up the email of an account for an event; it is empty
+// when the account is unknown.
+func (s *Service) accountEmail(accountID string) string {
+	acct, err := s.store.GetAccount(context.Background(), accountID)
+	if err != nil || acct == nil {
+		return ""
+	}
+	return acct.Email
+}
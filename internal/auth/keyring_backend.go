@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/zalando/go-keyring"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Keyrings selectable with keyring_backend. The empty value is the one the
+// OS ships: Secret Service on Linux and the BSDs, the Keychain on macOS and
+// the Credential Manager on Windows.
+const (
+	KeyringSecretService = "secret-service"
+	KeyringKWallet       = "kwallet"
+	KeyringKeychain      = "keychain"
+	KeyringWinCred       = "wincred"
+	KeyringPass          = "pass"
+	KeyringEncryptedFile = "encrypted-file"
+)
+
+// newKeyring returns the keyring backend names. KeyringEncryptedFile is the
+// token file and is handled by newTokenStore.
+func newKeyring(backend, service string) (tokenStore, error) {
+	switch backend {
+	case "":
+		return keyringTokens{service: service}, nil
+	case KeyringSecretService:
+		if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+			return nil, errs.New(errs.ErrInvalidArgument, "keyring_backend %s is not available on %s", backend, runtime.GOOS)
+		}
+		return keyringTokens{service: service, backend: backend}, nil
+	case KeyringKeychain:
+		if runtime.GOOS != "darwin" {
+			return nil, errs.New(errs.ErrInvalidArgument, "keyring_backend %s is only available on macOS", backend)
+		}
+		return keyringTokens{service: service, backend: backend}, nil
+	case KeyringWinCred:
+		if runtime.GOOS != "windows" {
+			return nil, errs.New(errs.ErrInvalidArgument, "keyring_backend %s is only available on Windows", backend)
+		}
+		return keyringTokens{service: service, backend: backend}, nil
+	case KeyringKWallet:
+		return &kwalletTokens{folder: service}, nil
+	case KeyringPass:
+		if _, err := exec.LookPath("pass"); err != nil {
+			return nil, errs.New(errs.ErrInvalidArgument, "keyring_backend %s: pass is not installed", backend)
+		}
+		return &passTokens{prefix: service}, nil
+	default:
+		return nil, errs.New(errs.ErrInvalidArgument, "unknown keyring_backend %q (want %s, %s, %s, %s, %s or %s)", backend,
+			KeyringSecretService, KeyringKWallet, KeyringKeychain, KeyringWinCred, KeyringPass, KeyringEncryptedFile)
+	}
+}
+
+// KWallet's D-Bus names, as kwalletd5 registers them.
+const (
+	kwalletService   = "org.kde.kwalletd5"
+	kwalletPath      = "/modules/kwalletd5"
+	kwalletInterface = "org.kde.KWallet"
+	kwalletAppID     = "googlysync"
+)
+
+// kwalletTokens keeps refresh tokens in KDE Wallet over D-Bus, in a folder
+// of the network wallet named after the keyring service.
+type kwalletTokens struct {
+	folder string
+}
+
+func (k *kwalletTokens) Name() string { return KeyringKWallet }
+
+func (k *kwalletTokens) Get(accountID string) (string, error) {
+	var token string
+	err := k.call(func(obj dbus.BusObject, handle int32) error {
+		var found bool
+		if err := obj.Call(kwalletInterface+".hasEntry", 0, handle, k.folder, accountID, kwalletAppID).Store(&found); err != nil {
+			return err
+		}
+		if !found {
+			return keyring.ErrNotFound
+		}
+		return obj.Call(kwalletInterface+".readPassword", 0, handle, k.folder, accountID, kwalletAppID).Store(&token)
+	})
+	return token, err
+}
+
+func (k *kwalletTokens) Set(accountID, token string) error {
+	return k.call(func(obj dbus.BusObject, handle int32) error {
+		var rc int32
+		if err := obj.Call(kwalletInterface+".writePassword", 0, handle, k.folder, accountID, token, kwalletAppID).Store(&rc); err != nil {
+			return err
+		}
+		if rc != 0 {
+			return fmt.Errorf("kwallet: write failed with code %d", rc)
+		}
+		return nil
+	})
+}
+
+func (k *kwalletTokens) Delete(accountID string) error {
+	return k.call(func(obj dbus.BusObject, handle int32) error {
+		var rc int32
+		if err := obj.Call(kwalletInterface+".removeEntry", 0, handle, k.folder, accountID, kwalletAppID).Store(&rc); err != nil {
+			return err
+		}
+		if rc != 0 {
+			return keyring.ErrNotFound
+		}
+		return nil
+	})
+}
+
+// call opens the network wallet, which may ask the user to unlock it, runs
+// fn with its handle and closes the handle again. The wallet itself stays
+// open while other applications hold handles to it.
+func (k *kwalletTokens) call(fn func(obj dbus.BusObject, handle int32) error) error {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return fmt.Errorf("kwallet: %w", err)
+	}
+	obj := conn.Object(kwalletService, kwalletPath)
+	var wallet string
+	if err := obj.Call(kwalletInterface+".networkWallet", 0).Store(&wallet); err != nil {
+		return fmt.Errorf("kwallet: %w", err)
+	}
+	var handle int32
+	if err := obj.Call(kwalletInterface+".open", 0, wallet, int64(0), kwalletAppID).Store(&handle); err != nil {
+		return fmt.Errorf("kwallet: %w", err)
+	}
+	if handle < 0 {
+		return fmt.Errorf("kwallet: wallet %q could not be opened", wallet)
+	}
+	defer obj.Call(kwalletInterface+".close", 0, handle, false, kwalletAppID)
+	return fn(obj, handle)
+}
+
+// passTokens keeps refresh tokens in the pass password store, one entry per
+// account under a directory named after the keyring service. pass must be
+// set up with a GPG key.
+type passTokens struct {
+	prefix string
+}
+
+func (p *passTokens) Name() string { return KeyringPass }
+
+func (p *passTokens) Get(accountID string) (string, error) {
+	if err := p.exists(accountID); err != nil {
+		return "", err
+	}
+	out, err := p.run(nil, "show", p.entry(accountID))
+	if err != nil {
+		return "", err
+	}
+	token, _, _ := strings.Cut(string(out), "\n")
+	return token, nil
+}
+
+func (p *passTokens) Set(accountID, token string) error {
+	_, err := p.run(strings.NewReader(token+"\n"), "insert", "--multiline", "--force", p.entry(accountID))
+	return err
+}
+
+func (p *passTokens) Delete(accountID string) error {
+	if err := p.exists(accountID); err != nil {
+		return err
+	}
+	_, err := p.run(nil, "rm", "--force", p.entry(accountID))
+	return err
+}
+
+func (p *passTokens) entry(accountID string) string {
+	return p.prefix + "/" + accountID
+}
+
+// exists looks for the entry's file, since pass reports a missing entry
+// only in its error text.
+func (p *passTokens) exists(accountID string) error {
+	dir := os.Getenv("PASSWORD_STORE_DIR")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		dir = filepath.Join(home, ".password-store")
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(p.entry(accountID))+".gpg")); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return keyring.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (p *passTokens) run(stdin *strings.Reader, args ...string) ([]byte, error) {
+	cmd := exec.Command("pass", args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("pass %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("pass %s: %w", args[0], err)
+	}
+	return out, nil
+}
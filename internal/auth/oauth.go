@@ -13,32 +13,87 @@ import (
 	neturl "net/url"
 	"os/exec"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 
 	"go.uber.org/zap"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 
 	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
 )
 
 type idTokenClaims struct {
 	Sub   string `json:"sub"`
 	Email string `json:"email"`
 	Name  string `json:"name"`
+	// HD is the Workspace domain of the account; personal accounts have
+	// none.
+	HD string `json:"hd"`
 }
 
-func defaultScopes() []string {
-	return []string{
-		"openid",
-		"email",
-		"profile",
-		"https://www.googleapis.com/auth/drive",
+// identityScopes identify the account signing in; every flow asks for them.
+var identityScopes = []string{"openid", "email", "profile"}
+
+// defaultScopes are the scopes sign-in asks for under drive_access.
+func defaultScopes(access string) []string {
+	scopes := slices.Clone(identityScopes)
+	if access == driveAccessApp {
+		return append(scopes, driveFileScope, driveAppDataScope)
+	}
+	return append(scopes, driveScope)
+}
+
+// requiredScopes are the scopes syncing under drive_access cannot do
+// without.
+func requiredScopes(access string) []string {
+	return []string{driveScopeFor(access)}
+}
+
+// missingScopes returns the scopes in want that granted does not cover. The
+// full drive scope covers drive.file.
+func missingScopes(granted, want []string) []string {
+	var missing []string
+	for _, scope := range want {
+		if hasScope(granted, scope) || (scope == driveFileScope && hasScope(granted, driveScope)) {
+			continue
+		}
+		missing = append(missing, scope)
+	}
+	return missing
+}
+
+// grantedScopes returns the scopes token was granted. Google lists them in
+// the token response, including ones granted earlier when the flow asked
+// for include_granted_scopes; without that list, the requested scopes are
+// taken as granted on top of the ones granted before.
+func grantedScopes(token *oauth2.Token, requested, before []string) []string {
+	if raw, ok := token.Extra("scope").(string); ok && strings.TrimSpace(raw) != "" {
+		return strings.Fields(raw)
 	}
+	return append(slices.Clone(before), requested...)
 }
 
-func runOAuthFlow(ctx context.Context, cfg *config.Config, scopes []string, logger *zap.Logger) (*oauth2.Token, idTokenClaims, error) {
+// runOAuthFlow signs in through a loopback redirect, which it sets on client
+// along with scopes. Scopes the account granted before are kept, so a flow
+// can ask for just the ones it lacks. loginHint, when set, preselects the
+// account on the consent page. The consent page is opened in a browser, or
+// by open when it is set. When prompt is set the page is handed to it
+// instead, and it returns what the user pasted back from a browser on
+// another machine.
+//
+// The flow gives up after sign_in_timeout_seconds or when ctx is done, with
+// ctx's cause. The callback server is shut down on every return, so
+// abandoned flows do not keep a port open; a prompt still waiting for input
+// is left to its caller.
+func runOAuthFlow(ctx context.Context, cfg *config.Config, client *oauth2.Config, scopes []string, loginHint string, logger *zap.Logger, open func(authURL string) error, prompt func(authURL string) (string, error)) (*oauth2.Token, idTokenClaims, error) {
+	if cfg.SignInTimeoutSeconds > 0 {
+		timeout := time.Duration(cfg.SignInTimeoutSeconds) * time.Second
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("sign-in not completed within %s: %w", timeout, context.DeadlineExceeded))
+		defer cancel()
+	}
 	state, err := randomToken(16)
 	if err != nil {
 		return nil, idTokenClaims{}, err
@@ -55,16 +110,27 @@ func runOAuthFlow(ctx context.Context, cfg *config.Config, scopes []string, logg
 	defer listener.Close()
 
 	redirectURL := fmt.Sprintf("http://%s/oauth/callback", listener.Addr().String())
-	oauthCfg := &oauth2.Config{
-		ClientID:     cfg.OAuthClientID,
-		ClientSecret: cfg.OAuthClientSecret,
-		Endpoint:     google.Endpoint,
-		RedirectURL:  redirectURL,
-		Scopes:       scopes,
-	}
+	client.RedirectURL = redirectURL
+	client.Scopes = scopes
 
+	// The callback and the prompt may both answer, and a browser may load
+	// the callback more than once. The first answer wins; later ones are
+	// dropped rather than block a handler the shutdown would wait for.
 	codeCh := make(chan string, 1)
 	errCh := make(chan error, 1)
+	answer := func(code string, err error) {
+		if err != nil {
+			select {
+			case errCh <- err:
+			default:
+			}
+			return
+		}
+		select {
+		case codeCh <- code:
+		default:
+		}
+	}
 	mux := http.NewServeMux()
 	server := &http.Server{
 		Handler:           mux,
@@ -75,56 +141,76 @@ func runOAuthFlow(ctx context.Context, cfg *config.Config, scopes []string, logg
 	}
 	mux.HandleFunc("/oauth/callback", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Query().Get("state") != state {
-			errCh <- errors.New("oauth state mismatch")
+			answer("", errors.New("oauth state mismatch"))
 			http.Error(w, "state mismatch", http.StatusBadRequest)
 			return
 		}
 		if errStr := r.URL.Query().Get("error"); errStr != "" {
-			errCh <- fmt.Errorf("oauth error: %s", errStr)
+			answer("", fmt.Errorf("oauth error: %s", errStr))
 			http.Error(w, "oauth error", http.StatusBadRequest)
 			return
 		}
 		code := r.URL.Query().Get("code")
 		if code == "" {
-			errCh <- errors.New("oauth code missing")
+			answer("", errors.New("oauth code missing"))
 			http.Error(w, "missing code", http.StatusBadRequest)
 			return
 		}
 		_, _ = w.Write([]byte("Authentication complete. You can close this window."))
-		codeCh <- code
+		answer(code, nil)
 	})
 
 	go func() {
 		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			errCh <- err
+			answer("", err)
 		}
 	}()
+	defer shutdownCallback(server, logger)
 
-	authURL := oauthCfg.AuthCodeURL(
-		state,
+	authOpts := []oauth2.AuthCodeOption{
 		oauth2.AccessTypeOffline,
 		oauth2.SetAuthURLParam("prompt", "consent"),
 		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
 		oauth2.SetAuthURLParam("code_challenge", challenge),
-	)
-	if err := openBrowser(authURL); err != nil {
-		_ = server.Shutdown(context.Background())
-		return nil, idTokenClaims{}, err
+		oauth2.SetAuthURLParam("include_granted_scopes", "true"),
+	}
+	if loginHint != "" {
+		authOpts = append(authOpts, oauth2.SetAuthURLParam("login_hint", loginHint))
+	}
+	if hd := hostedDomainHint(cfg.AllowedDomains); hd != "" {
+		authOpts = append(authOpts, oauth2.SetAuthURLParam("hd", hd))
+	}
+	authURL := client.AuthCodeURL(state, authOpts...)
+	switch {
+	case prompt != nil:
+		go func() {
+			input, err := prompt(authURL)
+			if err == nil {
+				input, err = pastedCode(input, state)
+			}
+			answer(input, err)
+		}()
+	case open != nil:
+		if err := open(authURL); err != nil {
+			return nil, idTokenClaims{}, err
+		}
+	default:
+		if err := openBrowser(authURL); err != nil {
+			return nil, idTokenClaims{}, fmt.Errorf("%w; sign in with login --no-browser instead", err)
+		}
 	}
 
 	var code string
 	select {
 	case <-ctx.Done():
-		_ = server.Shutdown(context.Background())
-		return nil, idTokenClaims{}, ctx.Err()
+		return nil, idTokenClaims{}, context.Cause(ctx)
 	case err := <-errCh:
-		_ = server.Shutdown(context.Background())
 		return nil, idTokenClaims{}, err
 	case code = <-codeCh:
 	}
-	_ = server.Shutdown(context.Background())
+	shutdownCallback(server, logger)
 
-	token, err := oauthCfg.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", verifier))
+	token, err := client.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", verifier))
 	if err != nil {
 		return nil, idTokenClaims{}, err
 	}
@@ -136,15 +222,90 @@ func runOAuthFlow(ctx context.Context, cfg *config.Config, scopes []string, logg
 			logger.Warn("id_token parse failed", zap.Error(err))
 		} else {
 			claims = decoded
-			// NOTE: We do not validate ID token signatures here because the claims
-			// are used only for display metadata (email/name). Do not use these
-			// fields for authorization decisions without signature verification.
+			// NOTE: We do not validate ID token signatures here. The token
+			// comes straight from the token endpoint over TLS, which OpenID
+			// Connect accepts in place of a signature check, so sub and hd
+			// can be trusted. Do not reuse decodeJWTClaims for ID tokens
+			// received any other way.
 		}
 	}
 
 	return token, claims, nil
 }
 
+// callbackShutdownTimeout bounds how long shutting the callback server down
+// waits for a request in progress before closing it.
+const callbackShutdownTimeout = 2 * time.Second
+
+// shutdownCallback stops the callback server and closes its listener. It is
+// safe to call more than once.
+func shutdownCallback(server *http.Server, logger *zap.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), callbackShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Debug("oauth callback server shutdown timed out; closing it", zap.Error(err))
+		_ = server.Close()
+	}
+}
+
+// hostedDomainHint is the hd parameter for the consent page: the one allowed
+// domain, or "*" for any Workspace domain when several are allowed. Google
+// only uses it to pick the account; checkDomain enforces it.
+func hostedDomainHint(domains []string) string {
+	switch len(domains) {
+	case 0:
+		return ""
+	case 1:
+		return domains[0]
+	default:
+		return "*"
+	}
+}
+
+// checkDomain refuses an account outside allowed_domains.
+func checkDomain(domains []string, claims idTokenClaims) error {
+	if len(domains) == 0 {
+		return nil
+	}
+	for _, domain := range domains {
+		if claims.HD != "" && strings.EqualFold(claims.HD, domain) {
+			return nil
+		}
+	}
+	if claims.HD == "" {
+		return errs.New(errs.ErrInvalidArgument, "%s is not a Workspace account; allowed_domains only admits %s", claims.Email, strings.Join(domains, ", "))
+	}
+	return errs.New(errs.ErrInvalidArgument, "%s belongs to %s; allowed_domains only admits %s", claims.Email, claims.HD, strings.Join(domains, ", "))
+}
+
+// pastedCode takes the authorization code out of what the user pasted: the
+// whole redirect URL, whose state must match, or just the code.
+func pastedCode(input, state string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", errors.New("no code pasted")
+	}
+	if !strings.Contains(input, "://") {
+		return input, nil
+	}
+	u, err := neturl.Parse(input)
+	if err != nil {
+		return "", fmt.Errorf("invalid redirect url: %w", err)
+	}
+	q := u.Query()
+	if errStr := q.Get("error"); errStr != "" {
+		return "", fmt.Errorf("oauth error: %s", errStr)
+	}
+	if q.Get("state") != state {
+		return "", errors.New("oauth state mismatch")
+	}
+	code := q.Get("code")
+	if code == "" {
+		return "", errors.New("oauth code missing")
+	}
+	return code, nil
+}
+
 func openBrowser(url string) error {
 	parsed, err := neturl.Parse(url)
 	if err != nil {
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+const (
+	// preRefreshLead is how long before expiry the pre-refresher renews an
+	// access token. It is ahead of tokenRefreshMargin, so a busy account's
+	// requests never find their token due.
+	preRefreshLead = 10 * time.Minute
+	// preRefreshInterval is how often the pre-refresher looks for accounts
+	// with work waiting.
+	preRefreshInterval = time.Minute
+)
+
+// BusyFunc lists the accounts that have sync work waiting.
+type BusyFunc func(ctx context.Context) []string
+
+// PreRefresher renews the access tokens of busy accounts before they are
+// due, so a burst of transfers does not stall on a token exchange. Idle
+// accounts are left to renew on their next request.
+type PreRefresher struct {
+	logger   *zap.Logger
+	svc      *Service
+	busy     BusyFunc
+	interval time.Duration
+}
+
+// NewPreRefresher constructs a pre-refresher for the accounts busy lists.
+func NewPreRefresher(logger *zap.Logger, svc *Service, busy BusyFunc) *PreRefresher {
+	return &PreRefresher{logger: logger, svc: svc, busy: busy, interval: preRefreshInterval}
+}
+
+// Run renews due tokens once per interval until ctx is done.
+func (p *PreRefresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.refreshDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshDue renews the tokens of busy accounts that expire within
+// preRefreshLead, one account at a time.
+func (p *PreRefresher) refreshDue(ctx context.Context) {
+	for _, accountID := range p.busy(ctx) {
+		if !p.svc.tokenDue(accountID, preRefreshLead) {
+			continue
+		}
+		err := p.svc.prefetchToken(ctx, accountID)
+		switch {
+		case err == nil:
+			p.logger.Debug("access token renewed ahead of expiry", zap.String("account", accountID))
+		case errors.Is(err, errs.ErrAuthExpired), ctx.Err() != nil:
+			// The account has to sign in again, or we are stopping.
+		default:
+			p.logger.Warn("renewing access token ahead of expiry failed", zap.String("account", accountID), zap.Error(err))
+		}
+	}
+}
+
+// tokenDue reports whether the account's cached token is missing or
+// expires within lead. A token whose last renewal failed is not due again
+// until tokenRetryDelay has passed.
+func (s *Service) tokenDue(accountID string, lead time.Duration) bool {
+	c := s.tokenCache(accountID)
+	now := s.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Before(c.retryAt) {
+		return false
+	}
+	if c.token == nil {
+		return true
+	}
+	return !c.token.Expiry.IsZero() && !now.Add(lead).Before(c.token.Expiry)
+}
+
+// prefetchToken refreshes the account's cached token, joining a refresh
+// already in flight, and waits for it.
+func (s *Service) prefetchToken(ctx context.Context, accountID string) error {
+	c := s.tokenCache(accountID)
+	c.mu.Lock()
+	flight := s.startRefresh(c, accountID)
+	c.mu.Unlock()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-flight.done:
+		return flight.err
+	}
+}
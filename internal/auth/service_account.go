@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"os"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// serviceAccount mints tokens from a service-account key instead of a
+// user's refresh token. With a subject it impersonates that Workspace user
+// through domain-wide delegation.
+type serviceAccount struct {
+	// email is the account the tokens act as: the subject, or the service
+	// account itself when there is none.
+	email  string
+	client string
+	jwt    func(ctx context.Context) oauth2.TokenSource
+}
+
+// loadServiceAccount reads the key cfg names. It returns nil when no key is
+// configured.
+func loadServiceAccount(cfg *config.Config) (*serviceAccount, error) {
+	if cfg.ServiceAccountKey == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(cfg.ServiceAccountKey)
+	if err != nil {
+		return nil, errs.New(errs.ErrInvalidArgument, "read service account key: %v", err)
+	}
+	jwtCfg, err := google.JWTConfigFromJSON(data, driveScopeFor(cfg.DriveAccess))
+	if err != nil {
+		return nil, errs.New(errs.ErrInvalidArgument, "service account key %s: %v", cfg.ServiceAccountKey, err)
+	}
+	jwtCfg.Subject = cfg.ServiceAccountSubject
+	sa := &serviceAccount{email: jwtCfg.Subject, client: jwtCfg.Email, jwt: jwtCfg.TokenSource}
+	if sa.email == "" {
+		sa.email = jwtCfg.Email
+	}
+	return sa, nil
+}
+
+// token fetches a new access token.
+func (sa *serviceAccount) token(ctx context.Context) (*oauth2.Token, error) {
+	tok, err := sa.jwt(ctx).Token()
+	if err != nil {
+		return nil, refreshErr(err)
+	}
+	return tok, nil
+}
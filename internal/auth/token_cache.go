@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// tokenRefreshMargin is how long before expiry a cached access token is
+	// renewed. The old token keeps being handed out while that happens.
+	tokenRefreshMargin = 5 * time.Minute
+	// tokenRefreshTimeout bounds one refresh, which every waiting caller
+	// shares.
+	tokenRefreshTimeout = 30 * time.Second
+	// tokenRetryDelay spaces out background refreshes after one failed
+	// while the cached token is still valid.
+	tokenRetryDelay = 30 * time.Second
+)
+
+// tokenCache holds an account's access token and the refresh in flight.
+type tokenCache struct {
+	mu      sync.Mutex
+	token   *oauth2.Token
+	flight  *tokenFlight
+	retryAt time.Time
+}
+
+// tokenFlight is one refresh; callers that arrive while it runs wait for
+// its result instead of starting their own.
+type tokenFlight struct {
+	done  chan struct{}
+	token *oauth2.Token
+	err   error
+}
+
+// TokenSource returns the account's access token source. Tokens are cached
+// per account and shared by every caller, renewed shortly before they
+// expire, and concurrent callers share a single refresh.
+func (s *Service) TokenSource(accountID string) oauth2.TokenSource {
+	return accountTokenSource{svc: s, accountID: accountID}
+}
+
+// Client returns an HTTP client that authorizes requests as the account.
+// It is how Drive clients are built.
+func (s *Service) Client(accountID string) *http.Client {
+	return &http.Client{Transport: &oauth2.Transport{Source: s.TokenSource(accountID)}}
+}
+
+type accountTokenSource struct {
+	svc       *Service
+	accountID string
+}
+
+func (ts accountTokenSource) Token() (*oauth2.Token, error) {
+	return ts.svc.cachedToken(ts.accountID)
+}
+
+// cachedToken returns the account's cached access token. A token inside
+// its refresh margin is still returned while a refresh runs in the
+// background; a missing or expired one waits for the refresh.
+func (s *Service) cachedToken(accountID string) (*oauth2.Token, error) {
+	c := s.tokenCache(accountID)
+	now := s.now()
+
+	c.mu.Lock()
+	tok := c.token
+	valid := tok != nil && (tok.Expiry.IsZero() || now.Before(tok.Expiry))
+	if valid && (tok.Expiry.IsZero() || now.Add(tokenRefreshMargin).Before(tok.Expiry) || now.Before(c.retryAt)) {
+		c.mu.Unlock()
+		return tok, nil
+	}
+	flight := s.startRefresh(c, accountID)
+	c.mu.Unlock()
+
+	if valid {
+		return tok, nil
+	}
+	<-flight.done
+	return flight.token, flight.err
+}
+
+// startRefresh returns the refresh in flight for c, starting one if there
+// is none. c.mu must be held.
+func (s *Service) startRefresh(c *tokenCache, accountID string) *tokenFlight {
+	if c.flight == nil {
+		c.flight = &tokenFlight{done: make(chan struct{})}
+		go s.runRefresh(c, accountID, c.flight)
+	}
+	return c.flight
+}
+
+func (s *Service) runRefresh(c *tokenCache, accountID string, flight *tokenFlight) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenRefreshTimeout)
+	defer cancel()
+	flight.token, flight.err = s.refresh(ctx, accountID)
+
+	c.mu.Lock()
+	if flight.err == nil {
+		c.token = flight.token
+		c.retryAt = time.Time{}
+	} else {
+		c.retryAt = s.now().Add(tokenRetryDelay)
+	}
+	c.flight = nil
+	c.mu.Unlock()
+	close(flight.done)
+}
+
+func (s *Service) tokenCache(accountID string) *tokenCache {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.tokens[accountID]
+	if !ok {
+		c = &tokenCache{}
+		s.tokens[accountID] = c
+	}
+	return c
+}
+
+// forgetToken drops an account's cached token, after it signs in again or
+// out. A refresh still in flight finishes into the dropped cache.
+func (s *Service) forgetToken(accountID string) {
+	s.mu.Lock()
+	delete(s.tokens, accountID)
+	s.mu.Unlock()
+}
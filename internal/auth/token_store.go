@@ -0,0 +1,300 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/zalando/go-keyring"
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/encryption"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Token stores selectable with token_store.
+const (
+	TokenStoreAuto    = "auto"
+	TokenStoreKeyring = "keyring"
+	TokenStoreFile    = "file"
+)
+
+// tokenStore keeps refresh tokens by account id. Get returns
+// keyring.ErrNotFound for an account without a token, whichever store it is.
+type tokenStore interface {
+	Name() string
+	Get(accountID string) (string, error)
+	Set(accountID, token string) error
+	Delete(accountID string) error
+}
+
+// newTokenStore returns the store cfg selects. The keyring is the one
+// keyring_backend names; in auto mode it is used when it answers and the
+// token file otherwise.
+func newTokenStore(cfg *config.Config, service string, logger *zap.Logger) (tokenStore, error) {
+	file := &fileTokens{path: filepath.Join(filepath.Dir(cfg.DatabasePath), "tokens.json"), passphraseFile: cfg.TokenPassphraseFile}
+	switch cfg.TokenStore {
+	case TokenStoreFile:
+		return file, nil
+	case TokenStoreKeyring, TokenStoreAuto, "":
+	default:
+		return nil, errs.New(errs.ErrInvalidArgument, "unknown token_store %q (want %s, %s or %s)", cfg.TokenStore, TokenStoreAuto, TokenStoreKeyring, TokenStoreFile)
+	}
+	if cfg.KeyringBackend == KeyringEncryptedFile {
+		if cfg.TokenStore == TokenStoreKeyring {
+			return nil, errs.New(errs.ErrInvalidArgument, "token_store %s conflicts with keyring_backend %s", TokenStoreKeyring, KeyringEncryptedFile)
+		}
+		return file, nil
+	}
+	kr, err := newKeyring(cfg.KeyringBackend, service)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TokenStore == TokenStoreKeyring {
+		return kr, nil
+	}
+	_, err = kr.Get("probe")
+	if err == nil || errors.Is(err, keyring.ErrNotFound) {
+		return kr, nil
+	}
+	logger.Info("keyring unavailable; keeping refresh tokens in an encrypted file", zap.String("keyring", kr.Name()), zap.String("path", file.path), zap.Error(err))
+	return file, nil
+}
+
+// keyringTokens keeps refresh tokens in the OS keyring through go-keyring.
+// backend is the keyring_backend that picked it, if any.
+type keyringTokens struct {
+	service string
+	backend string
+}
+
+func (k keyringTokens) Name() string {
+	if k.backend != "" {
+		return k.backend
+	}
+	return TokenStoreKeyring
+}
+
+func (k keyringTokens) Get(accountID string) (string, error) {
+	return keyring.Get(k.service, accountID)
+}
+
+func (k keyringTokens) Set(accountID, token string) error {
+	return keyring.Set(k.service, accountID, token)
+}
+
+func (k keyringTokens) Delete(accountID string) error {
+	return keyring.Delete(k.service, accountID)
+}
+
+// Key derivations a token file can be sealed with.
+const (
+	kdfMachine    = "machine-id"
+	kdfPassphrase = "passphrase"
+)
+
+// pbkdf2Iterations is the work factor for passphrase-derived keys.
+const pbkdf2Iterations = 600_000
+
+// tokenFile is the on-disk form of the token file. Data is the JSON map of
+// account id to refresh token, encrypted with a key derived as KDF says.
+type tokenFile struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+	Data    []byte `json:"data"`
+}
+
+// fileTokens keeps refresh tokens in an encrypted file in the data dir, for
+// machines without a keyring. The key comes from a passphrase file when
+// token_passphrase_file is set, and from the machine id otherwise. The
+// latter only keeps the tokens from being read off a copied disk image or
+// backup by someone without the machine; anyone who can run as the user on
+// it can derive the key too.
+type fileTokens struct {
+	path           string
+	passphraseFile string
+
+	mu sync.Mutex
+}
+
+func (f *fileTokens) Name() string { return TokenStoreFile }
+
+func (f *fileTokens) Get(accountID string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tokens, err := f.load()
+	if err != nil {
+		return "", err
+	}
+	token, ok := tokens[accountID]
+	if !ok {
+		return "", keyring.ErrNotFound
+	}
+	return token, nil
+}
+
+func (f *fileTokens) Set(accountID, token string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tokens, err := f.load()
+	if err != nil {
+		return err
+	}
+	tokens[accountID] = token
+	return f.save(tokens)
+}
+
+func (f *fileTokens) Delete(accountID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tokens, err := f.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := tokens[accountID]; !ok {
+		return keyring.ErrNotFound
+	}
+	delete(tokens, accountID)
+	return f.save(tokens)
+}
+
+func (f *fileTokens) load() (map[string]string, error) {
+	tokens := make(map[string]string)
+	raw, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tf tokenFile
+	if err := json.Unmarshal(raw, &tf); err != nil {
+		return nil, fmt.Errorf("token file %s: %w", f.path, err)
+	}
+	if tf.Version != 1 {
+		return nil, errs.New(errs.ErrInvalidArgument, "token file %s has unsupported version %d", f.path, tf.Version)
+	}
+	key, err := f.key(tf.KDF, tf.Salt)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := encryption.NewDecrypter(bytes.NewReader(tf.Data), key)
+	if err != nil {
+		return nil, fmt.Errorf("token file %s: %w", f.path, err)
+	}
+	plain, err := io.ReadAll(dec)
+	if err != nil {
+		return nil, errs.New(errs.ErrAuthExpired, "token file %s cannot be decrypted with this passphrase or on this machine; fix token_passphrase_file, or remove the file and sign in again", f.path)
+	}
+	if err := json.Unmarshal(plain, &tokens); err != nil {
+		return nil, fmt.Errorf("token file %s: %w", f.path, err)
+	}
+	return tokens, nil
+}
+
+// save rewrites the file with a fresh salt, sealed the way the config asks
+// for now.
+func (f *fileTokens) save(tokens map[string]string) error {
+	plain, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	tf := tokenFile{Version: 1, KDF: kdfMachine, Salt: make([]byte, 32)}
+	if f.passphraseFile != "" {
+		tf.KDF = kdfPassphrase
+	}
+	if _, err := rand.Read(tf.Salt); err != nil {
+		return err
+	}
+	key, err := f.key(tf.KDF, tf.Salt)
+	if err != nil {
+		return err
+	}
+	var sealed bytes.Buffer
+	enc, err := encryption.NewEncrypter(&sealed, key)
+	if err != nil {
+		return err
+	}
+	if _, err := enc.Write(plain); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	tf.Data = sealed.Bytes()
+	out, err := json.Marshal(tf)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".tokens-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(out); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+func (f *fileTokens) key(kdf string, salt []byte) ([]byte, error) {
+	switch kdf {
+	case kdfPassphrase:
+		if f.passphraseFile == "" {
+			return nil, errs.New(errs.ErrInvalidArgument, "token file %s is sealed with a passphrase; set token_passphrase_file", f.path)
+		}
+		raw, err := os.ReadFile(f.passphraseFile)
+		if err != nil {
+			return nil, errs.New(errs.ErrInvalidArgument, "read token passphrase: %v", err)
+		}
+		passphrase := strings.TrimRight(string(raw), "\r\n")
+		if passphrase == "" {
+			return nil, errs.New(errs.ErrInvalidArgument, "token passphrase file %s is empty", f.passphraseFile)
+		}
+		return pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, encryption.KeySize)
+	case kdfMachine:
+		id, err := machineID()
+		if err != nil {
+			return nil, err
+		}
+		return hkdf.Key(sha256.New, []byte(fmt.Sprintf("%s:%d", id, os.Getuid())), salt, "googlysync token file", encryption.KeySize)
+	default:
+		return nil, errs.New(errs.ErrInvalidArgument, "token file %s uses unknown key derivation %q", f.path, kdf)
+	}
+}
+
+// machineIDPaths are where systemd and D-Bus keep the machine id.
+var machineIDPaths = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+func machineID() (string, error) {
+	for _, path := range machineIDPaths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if id := strings.TrimSpace(string(raw)); id != "" {
+			return id, nil
+		}
+	}
+	return "", errs.New(errs.ErrNotFound, "no machine id to derive the token file key from; set token_passphrase_file")
+}
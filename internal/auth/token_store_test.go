@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+func TestFileTokensRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	idPath := filepath.Join(dir, "machine-id")
+	if err := os.WriteFile(idPath, []byte("0123456789abcdef\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	saved := machineIDPaths
+	machineIDPaths = []string{idPath}
+	t.Cleanup(func() { machineIDPaths = saved })
+
+	path := filepath.Join(dir, "tokens.json")
+	f := &fileTokens{path: path}
+	if _, err := f.Get("acct"); !errors.Is(err, keyring.ErrNotFound) {
+		t.Fatalf("Get before any Set: expected keyring.ErrNotFound, got %v", err)
+	}
+	if err := f.Set("acct", "refresh-1"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := f.Set("other", "refresh-2"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	raw, _ := os.ReadFile(path)
+	if strings.Contains(string(raw), "refresh-1") {
+		t.Fatalf("token file holds the token in the clear: %s", raw)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Fatalf("token file mode = %v, want 0600", info.Mode().Perm())
+	}
+	if got, err := (&fileTokens{path: path}).Get("acct"); err != nil || got != "refresh-1" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+	if err := f.Delete("acct"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := f.Get("acct"); !errors.Is(err, keyring.ErrNotFound) {
+		t.Fatalf("Get after Delete: expected keyring.ErrNotFound, got %v", err)
+	}
+
+	// Another machine cannot open the file.
+	if err := os.WriteFile(idPath, []byte("fedcba9876543210\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Get("other"); !errors.Is(err, errs.ErrAuthExpired) {
+		t.Fatalf("Get on another machine: expected ErrAuthExpired, got %v", err)
+	}
+}
+
+func TestFileTokensPassphrase(t *testing.T) {
+	dir := t.TempDir()
+	passPath := filepath.Join(dir, "passphrase")
+	if err := os.WriteFile(passPath, []byte("correct horse\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "tokens.json")
+	f := &fileTokens{path: path, passphraseFile: passPath}
+	if err := f.Set("acct", "refresh-1"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got, err := f.Get("acct"); err != nil || got != "refresh-1" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+	if _, err := (&fileTokens{path: path}).Get("acct"); !errors.Is(err, errs.ErrInvalidArgument) {
+		t.Fatalf("Get without the passphrase: expected ErrInvalidArgument, got %v", err)
+	}
+	if err := os.WriteFile(passPath, []byte("wrong"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Get("acct"); !errors.Is(err, errs.ErrAuthExpired) {
+		t.Fatalf("Get with the wrong passphrase: expected ErrAuthExpired, got %v", err)
+	}
+}
+
+func TestNewTokenStoreSelection(t *testing.T) {
+	cfg := &config.Config{DatabasePath: filepath.Join(t.TempDir(), "googlysync.db")}
+	t.Cleanup(keyring.MockInit)
+
+	keyring.MockInitWithError(errors.New("no dbus session"))
+	store, err := newTokenStore(cfg, "googlysync-test", zap.NewNop())
+	if err != nil || store.Name() != TokenStoreFile {
+		t.Fatalf("auto without a keyring = %v, %v; want the file store", store, err)
+	}
+
+	keyring.MockInit()
+	if store, _ = newTokenStore(cfg, "googlysync-test", zap.NewNop()); store.Name() != TokenStoreKeyring {
+		t.Fatalf("auto with a keyring chose %s", store.Name())
+	}
+	cfg.TokenStore = TokenStoreFile
+	if store, _ = newTokenStore(cfg, "googlysync-test", zap.NewNop()); store.Name() != TokenStoreFile {
+		t.Fatalf("token_store file chose %s", store.Name())
+	}
+	cfg.TokenStore = "vault"
+	if _, err := newTokenStore(cfg, "googlysync-test", zap.NewNop()); !errors.Is(err, errs.ErrInvalidArgument) {
+		t.Fatalf("unknown token_store: expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+func TestKeyringBackendSelection(t *testing.T) {
+	cfg := &config.Config{DatabasePath: filepath.Join(t.TempDir(), "googlysync.db")}
+	t.Cleanup(keyring.MockInit)
+	keyring.MockInit()
+
+	cfg.KeyringBackend = KeyringEncryptedFile
+	if store, err := newTokenStore(cfg, "googlysync-test", zap.NewNop()); err != nil || store.Name() != TokenStoreFile {
+		t.Fatalf("encrypted-file = %v, %v; want the file store", store, err)
+	}
+	cfg.TokenStore = TokenStoreKeyring
+	if _, err := newTokenStore(cfg, "googlysync-test", zap.NewNop()); !errors.Is(err, errs.ErrInvalidArgument) {
+		t.Fatalf("token_store keyring with encrypted-file: expected ErrInvalidArgument, got %v", err)
+	}
+	cfg.TokenStore = ""
+
+	other := map[string]string{"darwin": KeyringWinCred, "windows": KeyringKeychain}[runtime.GOOS]
+	if other == "" {
+		other = KeyringKeychain
+	}
+	for _, backend := range []string{other, "gnome-keyring"} {
+		cfg.KeyringBackend = backend
+		if _, err := newTokenStore(cfg, "googlysync-test", zap.NewNop()); !errors.Is(err, errs.ErrInvalidArgument) {
+			t.Fatalf("keyring_backend %s: expected ErrInvalidArgument, got %v", backend, err)
+		}
+	}
+}
+
+// fakePass is a pass stand-in that keeps entries as plain files.
+const fakePass = `#!/bin/sh
+case "$1" in
+show) cat "$PASSWORD_STORE_DIR/$2.gpg" ;;
+insert) mkdir -p "$(dirname "$PASSWORD_STORE_DIR/$4.gpg")" && cat > "$PASSWORD_STORE_DIR/$4.gpg" ;;
+rm) rm "$PASSWORD_STORE_DIR/$3.gpg" ;;
+esac
+`
+
+func TestPassTokensRoundTrip(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pass is a shell script")
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "pass"), []byte(fakePass), 0o755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("PASSWORD_STORE_DIR", t.TempDir())
+
+	cfg := &config.Config{DatabasePath: filepath.Join(t.TempDir(), "googlysync.db"), TokenStore: TokenStoreKeyring, KeyringBackend: KeyringPass}
+	store, err := newTokenStore(cfg, "googlysync-test", zap.NewNop())
+	if err != nil || store.Name() != KeyringPass {
+		t.Fatalf("newTokenStore = %v, %v; want pass", store, err)
+	}
+	if _, err := store.Get("acct-1"); !errors.Is(err, keyring.ErrNotFound) {
+		t.Fatalf("Get before Set: expected ErrNotFound, got %v", err)
+	}
+	if err := store.Set("acct-1", "refresh-1"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got, err := store.Get("acct-1"); err != nil || got != "refresh-1" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+	if err := store.Delete("acct-1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := store.Delete("acct-1"); !errors.Is(err, keyring.ErrNotFound) {
+		t.Fatalf("Delete twice: expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,266 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// evictBatch is how many ranges eviction reads from the LRU order at a time.
+const evictBatch = 64
+
+// Store keeps byte ranges of Drive content on disk for streaming reads, up to
+// a size budget. Ranges are indexed in the content_cache table and their
+// bytes are stored once per SHA-256 under <data dir>/cache; the least
+// recently read ranges are evicted first when the budget is exceeded.
+type Store struct {
+	logger  *zap.Logger
+	store   storage.Store
+	dir     string
+	budget  int64
+	nowFunc func() time.Time
+
+	// mu serializes Put and eviction, so a blob is never removed between
+	// being written and its range being recorded.
+	mu sync.Mutex
+}
+
+// Range is an open cached range. Reads are relative to Offset.
+type Range struct {
+	*io.SectionReader
+	Offset int64
+	Length int64
+	file   *os.File
+}
+
+// Close releases the blob.
+func (r *Range) Close() error {
+	return r.file.Close()
+}
+
+// NewStore constructs a content cache rooted at <data dir>/cache.
+func NewStore(logger *zap.Logger, cfg *config.Config, store storage.Store) (*Store, error) {
+	return &Store{
+		logger:  logger,
+		store:   store,
+		dir:     filepath.Join(cfg.DataDir, "cache"),
+		budget:  int64(cfg.ContentCacheMB) << 20,
+		nowFunc: time.Now,
+	}, nil
+}
+
+// Put stores the range of a file revision that starts at offset, read from
+// r, then evicts ranges until the cache fits its budget again. A range
+// larger than the whole budget is not cached and Put returns nil.
+func (s *Store) Put(ctx context.Context, accountID, driveID, revision string, offset int64, r io.Reader) (*storage.CacheEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checksum, size, err := s.writeBlob(r)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, errs.New(errs.ErrInvalidArgument, "cannot cache an empty range")
+	}
+	if size > s.budget {
+		s.removeBlobIfUnused(ctx, checksum)
+		return nil, nil
+	}
+	entry := &storage.CacheEntry{
+		AccountID:  accountID,
+		DriveID:    driveID,
+		Revision:   revision,
+		Offset:     offset,
+		Length:     size,
+		Checksum:   checksum,
+		LastAccess: s.nowFunc(),
+	}
+	if err := s.store.PutCacheEntry(ctx, entry); err != nil {
+		s.removeBlobIfUnused(ctx, checksum)
+		return nil, err
+	}
+	if _, err := s.evict(ctx, s.budget); err != nil {
+		s.logger.Warn("content cache eviction failed", zap.Error(err))
+	}
+	return entry, nil
+}
+
+// Open returns the cached bytes of [offset, offset+length) of a file
+// revision, or nil when no single cached range covers them. The range
+// returned may extend beyond what was asked for.
+func (s *Store) Open(ctx context.Context, accountID, driveID, revision string, offset, length int64) (*Range, error) {
+	entry, err := s.store.FindCacheEntry(ctx, accountID, driveID, revision, offset, length)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	f, err := os.Open(s.blobPath(entry.Checksum))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// The blob went missing behind our back; forget the range.
+			_, err = s.store.DeleteCacheEntry(ctx, *entry)
+			return nil, err
+		}
+		return nil, err
+	}
+	if err := s.store.TouchCacheEntry(ctx, entry, s.nowFunc()); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &Range{
+		SectionReader: io.NewSectionReader(f, 0, entry.Length),
+		Offset:        entry.Offset,
+		Length:        entry.Length,
+		file:          f,
+	}, nil
+}
+
+// Usage returns the number of cached ranges, the bytes they take and the
+// budget.
+func (s *Store) Usage(ctx context.Context) (entries int, bytes, budget int64, err error) {
+	entries, bytes, err = s.store.CacheUsage(ctx)
+	return entries, bytes, s.budget, err
+}
+
+// Evict removes least recently read ranges until the cache holds at most
+// target bytes, and returns how many ranges it removed. A target of zero
+// empties the cache.
+func (s *Store) Evict(ctx context.Context, target int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.evict(ctx, target)
+}
+
+func (s *Store) evict(ctx context.Context, target int64) (int, error) {
+	_, used, err := s.store.CacheUsage(ctx)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for used > target {
+		batch, err := s.store.ListCacheLRU(ctx, evictBatch)
+		if err != nil || len(batch) == 0 {
+			return removed, err
+		}
+		for _, entry := range batch {
+			refs, err := s.store.DeleteCacheEntry(ctx, entry)
+			if err != nil {
+				return removed, err
+			}
+			removed++
+			if refs == 0 {
+				if err := os.Remove(s.blobPath(entry.Checksum)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					return removed, err
+				}
+				used -= entry.Length
+			}
+			if used <= target {
+				break
+			}
+		}
+	}
+	if removed > 0 {
+		s.logger.Debug("evicted cached content", zap.Int("ranges", removed), zap.Int64("bytes_left", used))
+	}
+	return removed, nil
+}
+
+// gcGrace is how old an unreferenced blob or temp file must be before
+// CollectGarbage removes it, so a Put in progress is left alone.
+const gcGrace = time.Hour
+
+// CollectGarbage removes blobs no cached range references, such as ones
+// left behind by a crash between writing a blob and recording its range.
+// It returns how many files it removed.
+func (s *Store) CollectGarbage(ctx context.Context) (int, error) {
+	cutoff := s.nowFunc().Add(-gcGrace)
+	removed := 0
+	err := filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			return err
+		}
+		if name := d.Name(); !strings.HasPrefix(name, ".put-") {
+			refs, err := s.store.CountCacheBlobRefs(ctx, name)
+			if err != nil || refs > 0 {
+				return err
+			}
+		}
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if removed > 0 {
+		s.logger.Info("removed unreferenced cached content", zap.Int("files", removed))
+	}
+	return removed, err
+}
+
+func (s *Store) blobPath(checksum string) string {
+	return filepath.Join(s.dir, checksum[:2], checksum)
+}
+
+// writeBlob copies r into the blob store, keyed by its SHA-256.
+func (s *Store) writeBlob(r io.Reader) (string, int64, error) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return "", 0, err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".put-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || size == 0 {
+		return "", size, err
+	}
+
+	checksum := hex.EncodeToString(h.Sum(nil))
+	dst := s.blobPath(checksum)
+	if _, err := os.Stat(dst); err == nil {
+		return checksum, size, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", 0, err
+	}
+	return checksum, size, nil
+}
+
+func (s *Store) removeBlobIfUnused(ctx context.Context, checksum string) {
+	if refs, err := s.store.CountCacheBlobRefs(ctx, checksum); err == nil && refs == 0 {
+		_ = os.Remove(s.blobPath(checksum))
+	}
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+func newTestStore(t *testing.T, budgetMB int) *Store {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &config.Config{
+		DataDir:        dir,
+		DatabasePath:   filepath.Join(dir, "googlysync.db"),
+		ContentCacheMB: budgetMB,
+	}
+	db, err := storage.NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	s, err := NewStore(zap.NewNop(), cfg, db)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	return s
+}
+
+// clock returns a nowFunc that advances a second per call.
+func clock() func() time.Time {
+	now := time.Unix(1_700_000_000, 0)
+	return func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+}
+
+func TestPutAndOpenCoveringRange(t *testing.T) {
+	s := newTestStore(t, 1)
+	ctx := context.Background()
+
+	if _, err := s.Put(ctx, "acct", "d-1", "rev-1", 100, strings.NewReader("0123456789")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	r, err := s.Open(ctx, "acct", "d-1", "rev-1", 103, 4)
+	if err != nil || r == nil {
+		t.Fatalf("Open: %v, %v", r, err)
+	}
+	defer r.Close()
+	buf := make([]byte, 4)
+	if _, err := r.ReadAt(buf, 103-r.Offset); err != nil || string(buf) != "3456" {
+		t.Fatalf("ReadAt = %q, %v", buf, err)
+	}
+
+	for _, miss := range []struct {
+		revision       string
+		offset, length int64
+	}{
+		{"rev-1", 105, 10}, // runs past the cached range
+		{"rev-1", 90, 5},   // starts before it
+		{"rev-2", 100, 1},  // content changed since
+	} {
+		if r, err := s.Open(ctx, "acct", "d-1", miss.revision, miss.offset, miss.length); err != nil || r != nil {
+			t.Fatalf("expected a miss for %+v, got %v, %v", miss, r, err)
+		}
+	}
+}
+
+func TestPutEvictsLeastRecentlyRead(t *testing.T) {
+	s := newTestStore(t, 1)
+	s.nowFunc = clock()
+	ctx := context.Background()
+	chunk := func(b byte) io.Reader { return bytes.NewReader(bytes.Repeat([]byte{b}, 400<<10)) }
+
+	for i, id := range []string{"d-a", "d-b"} {
+		if _, err := s.Put(ctx, "acct", id, "rev", 0, chunk(byte('a'+i))); err != nil {
+			t.Fatalf("Put %s: %v", id, err)
+		}
+	}
+	// Reading d-a makes d-b the least recently read.
+	r, err := s.Open(ctx, "acct", "d-a", "rev", 0, 1)
+	if err != nil || r == nil {
+		t.Fatalf("Open: %v, %v", r, err)
+	}
+	_ = r.Close()
+	if _, err := s.Put(ctx, "acct", "d-c", "rev", 0, chunk('c')); err != nil {
+		t.Fatalf("Put d-c: %v", err)
+	}
+
+	for id, want := range map[string]bool{"d-a": true, "d-b": false, "d-c": true} {
+		r, err := s.Open(ctx, "acct", id, "rev", 0, 1)
+		if err != nil {
+			t.Fatalf("Open %s: %v", id, err)
+		}
+		if (r != nil) != want {
+			t.Fatalf("%s cached = %v, want %v", id, r != nil, want)
+		}
+		if r != nil {
+			_ = r.Close()
+		}
+	}
+	entries, used, budget, err := s.Usage(ctx)
+	if err != nil || entries != 2 || used != 800<<10 || budget != 1<<20 {
+		t.Fatalf("Usage = %d, %d, %d, %v", entries, used, budget, err)
+	}
+
+	// A range bigger than the budget is not cached at all.
+	if entry, err := s.Put(ctx, "acct", "d-big", "rev", 0, bytes.NewReader(make([]byte, 2<<20))); err != nil || entry != nil {
+		t.Fatalf("expected an oversized range skipped, got %v, %v", entry, err)
+	}
+	if n, err := s.Evict(ctx, 0); err != nil || n != 2 {
+		t.Fatalf("Evict(0) = %d, %v", n, err)
+	}
+	blobs, _ := filepath.Glob(filepath.Join(s.dir, "*", "*"))
+	if len(blobs) != 0 {
+		t.Fatalf("expected every blob removed, found %v", blobs)
+	}
+}
+
+func TestSharedBlobOutlivesOneRange(t *testing.T) {
+	s := newTestStore(t, 1)
+	ctx := context.Background()
+	for _, id := range []string{"d-1", "d-2"} {
+		if _, err := s.Put(ctx, "acct", id, "rev", 0, strings.NewReader("same bytes")); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	lru, err := s.store.ListCacheLRU(ctx, 1)
+	if err != nil || len(lru) != 1 {
+		t.Fatalf("ListCacheLRU: %v, %v", lru, err)
+	}
+	if refs, err := s.store.DeleteCacheEntry(ctx, lru[0]); err != nil || refs != 1 {
+		t.Fatalf("DeleteCacheEntry refs = %d, %v", refs, err)
+	}
+	if _, err := os.Stat(s.blobPath(lru[0].Checksum)); err != nil {
+		t.Fatalf("expected the shared blob kept: %v", err)
+	}
+
+	// A blob with no range left is collected once it is old enough.
+	orphan := filepath.Join(s.dir, "ab", "ab00")
+	if err := os.MkdirAll(filepath.Dir(orphan), 0o700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(orphan, []byte("x"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	old := time.Now().Add(-2 * gcGrace)
+	_ = os.Chtimes(orphan, old, old)
+	if n, err := s.CollectGarbage(ctx); err != nil || n != 1 {
+		t.Fatalf("CollectGarbage = %d, %v", n, err)
+	}
+}
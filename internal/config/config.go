@@ -12,25 +12,103 @@ const appDirName = "drive-client"
 
 // Config holds basic runtime configuration.
 type Config struct {
-	AppName           string
-	ConfigDir         string
-	DataDir           string
-	RuntimeDir        string
-	SocketPath        string
-	SyncRoot          string
-	IgnorePatterns    []string
-	EventLogSize      int
-	SyncQueueSize     int
-	LogLevel          string
-	DatabasePath      string
-	ConfigFile        string
-	LogFilePath       string
-	LogFileMaxMB      int
-	LogFileMaxBackups int
-	LogFileMaxAgeDays int
-	OAuthClientID     string
-	OAuthClientSecret string
-	OAuthRedirectHost string
+	AppName               string
+	ConfigDir             string
+	DataDir               string
+	RuntimeDir            string
+	SocketPath            string
+	SyncRoot              string
+	IgnorePatterns        []string
+	WatchRoots            []WatchRoot
+	EventLogSize          int
+	SyncQueueSize         int
+	LogLevel              string
+	DatabasePath          string
+	ConfigFile            string
+	LogFilePath           string
+	LogFileMaxMB          int
+	LogFileMaxBackups     int
+	LogFileMaxAgeDays     int
+	OAuthClientID         string
+	OAuthClientSecret     string
+	OAuthRedirectHost     string
+	OAuthCredentialsFile  string
+	OAuthAuthURL          string
+	OAuthTokenURL         string
+	AllowedDomains        []string
+	SignInTimeoutSeconds  int
+	PreallocateMinMB      int
+	SyncDirection         string
+	VersionsKeep          int
+	VersionsMaxAgeDays    int
+	WatchMode             string
+	PollIntervalSec       int
+	PersistEvents         bool
+	TransferBufferKB      int
+	TransferMemoryMB      int
+	SymlinkPolicy         string
+	IPCRateLimit          int
+	IPCRateBurst          int
+	IPCMaxConcurrent      int
+	FileWatchIntervalSec  int
+	OnDemand              bool
+	BackupIntervalMin     int
+	SyncTarget            string
+	DeviceName            string
+	DebounceMs            int
+	DebounceMaxMs         int
+	SharedWithMe          string
+	SharedWithMeDir       string
+	DriveAccess           string
+	MaxFileSize           string
+	ExcludeExtensions     []string
+	ExcludeMimeTypes      []string
+	IPCCompression        string
+	IPCCompressMinKB      int
+	EmptyFolders          string
+	SkipEmptyFiles        bool
+	OpMaxRetries          int
+	AuditOnly             bool
+	MinFreeSpaceMB        int
+	RemoteListen          string
+	RemoteTLSCert         string
+	RemoteTLSKey          string
+	RemoteTLSCA           string
+	RemoteToken           string
+	QuotaPollSeconds      int
+	WebhookMaxAttempts    int
+	WebhookTimeoutSeconds int
+	QuotaAlertPercent     int
+	GCIntervalHours       int
+	CaseSensitivity       string
+	NameEncoding          string
+	EncryptContent        bool
+	EncryptNames          bool
+	CompressUploads       bool
+	CompressSkipTypes     []string
+	ChangesPollMinSeconds int
+	ChangesPollMaxSeconds int
+	ChangesPushURL        string
+	ChangesPushListen     string
+	UnsyncableTypes       string
+	ContentCacheMB        int
+	DBMaintenanceHours    int
+	EncryptDatabase       bool
+	ChangeJournalSize     int
+	StorageBackend        string
+	Account               string
+	ServiceAccountKey     string
+	ServiceAccountSubject string
+	TokenStore            string
+	KeyringBackend        string
+	TokenPassphraseFile   string
+}
+
+// WatchRoot is a folder watched besides SyncRoot. Its IgnorePatterns apply
+// on top of the global ones, to that root only.
+type WatchRoot struct {
+	Path           string   `json:"path"`
+	IgnorePatterns []string `json:"ignore_patterns"`
 }
 
 // NewConfig builds a default config from XDG paths and environment.
@@ -71,22 +149,65 @@ func NewConfig() (*Config, error) {
 	socketPath := filepath.Join(runtimeDir, "googlysync", "daemon.sock")
 
 	return &Config{
-		AppName:           "googlysync",
-		ConfigDir:         configDir,
-		DataDir:           dataDir,
-		RuntimeDir:        runtimeDir,
-		SocketPath:        socketPath,
-		SyncRoot:          filepath.Join(dataDir, "sync"),
-		IgnorePatterns:    []string{"*.swp", "*.tmp", "*~", ".DS_Store"},
-		EventLogSize:      20,
-		SyncQueueSize:     1024,
-		LogLevel:          "info",
-		DatabasePath:      filepath.Join(dataDir, "googlysync.db"),
-		LogFilePath:       filepath.Join(dataDir, "logs", "daemon.jsonl"),
-		LogFileMaxMB:      10,
-		LogFileMaxBackups: 5,
-		LogFileMaxAgeDays: 7,
-		OAuthRedirectHost: "127.0.0.1",
+		AppName:               "googlysync",
+		ConfigDir:             configDir,
+		DataDir:               dataDir,
+		RuntimeDir:            runtimeDir,
+		SocketPath:            socketPath,
+		SyncRoot:              filepath.Join(dataDir, "sync"),
+		IgnorePatterns:        []string{"*.swp", "*.tmp", "*~", ".DS_Store"},
+		EventLogSize:          20,
+		SyncQueueSize:         1024,
+		LogLevel:              "info",
+		DatabasePath:          filepath.Join(dataDir, "googlysync.db"),
+		LogFilePath:           filepath.Join(dataDir, "logs", "daemon.jsonl"),
+		LogFileMaxMB:          10,
+		LogFileMaxBackups:     5,
+		LogFileMaxAgeDays:     7,
+		OAuthRedirectHost:     "127.0.0.1",
+		SignInTimeoutSeconds:  300,
+		PreallocateMinMB:      16,
+		SyncDirection:         "bidirectional",
+		VersionsKeep:          10,
+		VersionsMaxAgeDays:    30,
+		WatchMode:             "fsnotify",
+		PollIntervalSec:       30,
+		PersistEvents:         true,
+		TransferBufferKB:      256,
+		TransferMemoryMB:      64,
+		SymlinkPolicy:         "skip",
+		IPCRateLimit:          20,
+		IPCRateBurst:          40,
+		IPCMaxConcurrent:      8,
+		FileWatchIntervalSec:  60,
+		BackupIntervalMin:     60,
+		SyncTarget:            "my-drive",
+		DebounceMs:            300,
+		DebounceMaxMs:         10000,
+		SharedWithMe:          "off",
+		SharedWithMeDir:       "Shared with me",
+		DriveAccess:           "full",
+		IPCCompression:        "off",
+		IPCCompressMinKB:      64,
+		EmptyFolders:          "create",
+		OpMaxRetries:          5,
+		MinFreeSpaceMB:        256,
+		QuotaPollSeconds:      900,
+		WebhookMaxAttempts:    4,
+		WebhookTimeoutSeconds: 10,
+		QuotaAlertPercent:     90,
+		GCIntervalHours:       24,
+		CaseSensitivity:       "auto",
+		NameEncoding:          "portable",
+		CompressSkipTypes:     []string{"image/*", "video/*", "audio/*", "application/zip", "application/gzip", "application/x-7z-compressed", "application/x-rar-compressed", "application/x-xz", "application/zstd", "application/pdf", "application/vnd.openxmlformats-officedocument.*"},
+		ChangesPollMinSeconds: 15,
+		ChangesPollMaxSeconds: 300,
+		UnsyncableTypes:       "link",
+		ContentCacheMB:        2048,
+		DBMaintenanceHours:    168,
+		ChangeJournalSize:     10000,
+		StorageBackend:        "sqlite",
+		TokenStore:            "auto",
 	}, nil
 }
 
@@ -98,24 +219,96 @@ type Options struct {
 }
 
 type fileConfig struct {
-	AppName           string   `json:"app_name"`
-	ConfigDir         string   `json:"config_dir"`
-	DataDir           string   `json:"data_dir"`
-	RuntimeDir        string   `json:"runtime_dir"`
-	SocketPath        string   `json:"socket_path"`
-	SyncRoot          string   `json:"sync_root"`
-	IgnorePatterns    []string `json:"ignore_patterns"`
-	EventLogSize      int      `json:"event_log_size"`
-	SyncQueueSize     int      `json:"sync_queue_size"`
-	LogLevel          string   `json:"log_level"`
-	DatabasePath      string   `json:"database_path"`
-	LogFilePath       string   `json:"log_file_path"`
-	LogFileMaxMB      int      `json:"log_file_max_mb"`
-	LogFileMaxBackups int      `json:"log_file_max_backups"`
-	LogFileMaxAgeDays int      `json:"log_file_max_age_days"`
-	OAuthClientID     string   `json:"oauth_client_id"`
-	OAuthClientSecret string   `json:"oauth_client_secret"`
-	OAuthRedirectHost string   `json:"oauth_redirect_host"`
+	AppName               string   `json:"app_name"`
+	ConfigDir             string   `json:"config_dir"`
+	DataDir               string   `json:"data_dir"`
+	RuntimeDir            string   `json:"runtime_dir"`
+	SocketPath            string   `json:"socket_path"`
+	SyncRoot              string   `json:"sync_root"`
+	IgnorePatterns        []string `json:"ignore_patterns"`
+	EventLogSize          int      `json:"event_log_size"`
+	SyncQueueSize         int      `json:"sync_queue_size"`
+	LogLevel              string   `json:"log_level"`
+	DatabasePath          string   `json:"database_path"`
+	LogFilePath           string   `json:"log_file_path"`
+	LogFileMaxMB          int      `json:"log_file_max_mb"`
+	LogFileMaxBackups     int      `json:"log_file_max_backups"`
+	LogFileMaxAgeDays     int      `json:"log_file_max_age_days"`
+	OAuthClientID         string   `json:"oauth_client_id"`
+	OAuthClientSecret     string   `json:"oauth_client_secret"`
+	OAuthRedirectHost     string   `json:"oauth_redirect_host"`
+	OAuthCredentialsFile  string   `json:"oauth_credentials_file"`
+	OAuthAuthURL          string   `json:"oauth_auth_url"`
+	OAuthTokenURL         string   `json:"oauth_token_url"`
+	AllowedDomains        []string `json:"allowed_domains"`
+	SignInTimeoutSeconds  int      `json:"sign_in_timeout_seconds"`
+	PreallocateMinMB      int      `json:"preallocate_min_mb"`
+	SyncDirection         string   `json:"sync_direction"`
+	VersionsKeep          int      `json:"versions_keep"`
+	VersionsMaxAgeDays    int      `json:"versions_max_age_days"`
+	WatchMode             string   `json:"watch_mode"`
+	PollIntervalSec       int      `json:"poll_interval_sec"`
+	PersistEvents         *bool    `json:"persist_events"`
+	TransferBufferKB      int      `json:"transfer_buffer_kb"`
+	TransferMemoryMB      int      `json:"transfer_memory_mb"`
+	SymlinkPolicy         string   `json:"symlink_policy"`
+	IPCRateLimit          int      `json:"ipc_rate_limit"`
+	IPCRateBurst          int      `json:"ipc_rate_burst"`
+	IPCMaxConcurrent      int      `json:"ipc_max_concurrent"`
+	FileWatchIntervalSec  int      `json:"file_watch_interval_sec"`
+	OnDemand              *bool    `json:"on_demand"`
+	BackupIntervalMin     int      `json:"backup_interval_min"`
+	SyncTarget            string   `json:"sync_target"`
+	DeviceName            string   `json:"device_name"`
+	DebounceMs            int      `json:"debounce_ms"`
+	DebounceMaxMs         int      `json:"debounce_max_ms"`
+	SharedWithMe          string   `json:"shared_with_me"`
+	SharedWithMeDir       string   `json:"shared_with_me_dir"`
+	DriveAccess           string   `json:"drive_access"`
+	MaxFileSize           string   `json:"max_file_size"`
+	ExcludeExtensions     []string `json:"exclude_extensions"`
+	ExcludeMimeTypes      []string `json:"exclude_mime_types"`
+	IPCCompression        string   `json:"ipc_compression"`
+	IPCCompressMinKB      int      `json:"ipc_compress_min_kb"`
+	EmptyFolders          string   `json:"empty_folders"`
+	SkipEmptyFiles        *bool    `json:"skip_empty_files"`
+	OpMaxRetries          int      `json:"op_max_retries"`
+	AuditOnly             *bool    `json:"audit_only"`
+	MinFreeSpaceMB        int      `json:"min_free_space_mb"`
+	RemoteListen          string   `json:"remote_listen"`
+	RemoteTLSCert         string   `json:"remote_tls_cert"`
+	RemoteTLSKey          string   `json:"remote_tls_key"`
+	RemoteTLSCA           string   `json:"remote_tls_ca"`
+	RemoteToken           string   `json:"remote_token"`
+	QuotaPollSeconds      int      `json:"quota_poll_seconds"`
+	WebhookMaxAttempts    int      `json:"webhook_max_attempts"`
+	WebhookTimeoutSeconds int      `json:"webhook_timeout_seconds"`
+	QuotaAlertPercent     int      `json:"quota_alert_percent"`
+	GCIntervalHours       int      `json:"gc_interval_hours"`
+	CaseSensitivity       string   `json:"case_sensitivity"`
+	NameEncoding          string   `json:"name_encoding"`
+	EncryptContent        *bool    `json:"encrypt_content"`
+	EncryptNames          *bool    `json:"encrypt_names"`
+	CompressUploads       *bool    `json:"compress_uploads"`
+	CompressSkipTypes     []string `json:"compress_skip_types"`
+	ChangesPollMinSeconds int      `json:"changes_poll_min_seconds"`
+	ChangesPollMaxSeconds int      `json:"changes_poll_max_seconds"`
+	ChangesPushURL        string   `json:"changes_push_url"`
+	ChangesPushListen     string   `json:"changes_push_listen"`
+	UnsyncableTypes       string   `json:"unsyncable_types"`
+	ContentCacheMB        int      `json:"content_cache_mb"`
+	DBMaintenanceHours    int      `json:"db_maintenance_hours"`
+	EncryptDatabase       *bool    `json:"encrypt_database"`
+	ChangeJournalSize     int      `json:"change_journal_size"`
+	StorageBackend        string   `json:"storage_backend"`
+	Account               string   `json:"account"`
+	ServiceAccountKey     string   `json:"service_account_key"`
+	ServiceAccountSubject string   `json:"service_account_subject"`
+	TokenStore            string   `json:"token_store"`
+	KeyringBackend        string   `json:"keyring_backend"`
+	TokenPassphraseFile   string   `json:"token_passphrase_file"`
+
+	WatchRoots []WatchRoot `json:"watch_roots"`
 }
 
 // NewConfigWithOptions resolves config and applies overrides from options and environment.
@@ -176,6 +369,9 @@ func applyConfigFile(cfg *Config, path string) error {
 	if len(fc.IgnorePatterns) > 0 {
 		cfg.IgnorePatterns = fc.IgnorePatterns
 	}
+	if len(fc.WatchRoots) > 0 {
+		cfg.WatchRoots = fc.WatchRoots
+	}
 	if fc.EventLogSize > 0 {
 		cfg.EventLogSize = fc.EventLogSize
 	}
@@ -209,6 +405,216 @@ func applyConfigFile(cfg *Config, path string) error {
 	if fc.OAuthRedirectHost != "" {
 		cfg.OAuthRedirectHost = fc.OAuthRedirectHost
 	}
+	if fc.OAuthCredentialsFile != "" {
+		cfg.OAuthCredentialsFile = fc.OAuthCredentialsFile
+	}
+	if fc.OAuthAuthURL != "" {
+		cfg.OAuthAuthURL = fc.OAuthAuthURL
+	}
+	if fc.OAuthTokenURL != "" {
+		cfg.OAuthTokenURL = fc.OAuthTokenURL
+	}
+	if len(fc.AllowedDomains) > 0 {
+		cfg.AllowedDomains = fc.AllowedDomains
+	}
+	if fc.SignInTimeoutSeconds > 0 {
+		cfg.SignInTimeoutSeconds = fc.SignInTimeoutSeconds
+	}
+	if fc.PreallocateMinMB > 0 {
+		cfg.PreallocateMinMB = fc.PreallocateMinMB
+	}
+	if fc.SyncDirection != "" {
+		cfg.SyncDirection = fc.SyncDirection
+	}
+	if fc.VersionsKeep > 0 {
+		cfg.VersionsKeep = fc.VersionsKeep
+	}
+	if fc.VersionsMaxAgeDays > 0 {
+		cfg.VersionsMaxAgeDays = fc.VersionsMaxAgeDays
+	}
+	if fc.WatchMode != "" {
+		cfg.WatchMode = fc.WatchMode
+	}
+	if fc.PollIntervalSec > 0 {
+		cfg.PollIntervalSec = fc.PollIntervalSec
+	}
+	if fc.PersistEvents != nil {
+		cfg.PersistEvents = *fc.PersistEvents
+	}
+	if fc.TransferBufferKB > 0 {
+		cfg.TransferBufferKB = fc.TransferBufferKB
+	}
+	if fc.TransferMemoryMB > 0 {
+		cfg.TransferMemoryMB = fc.TransferMemoryMB
+	}
+	if fc.SymlinkPolicy != "" {
+		cfg.SymlinkPolicy = fc.SymlinkPolicy
+	}
+	if fc.IPCRateLimit > 0 {
+		cfg.IPCRateLimit = fc.IPCRateLimit
+	}
+	if fc.IPCRateBurst > 0 {
+		cfg.IPCRateBurst = fc.IPCRateBurst
+	}
+	if fc.IPCMaxConcurrent > 0 {
+		cfg.IPCMaxConcurrent = fc.IPCMaxConcurrent
+	}
+	if fc.FileWatchIntervalSec > 0 {
+		cfg.FileWatchIntervalSec = fc.FileWatchIntervalSec
+	}
+	if fc.OnDemand != nil {
+		cfg.OnDemand = *fc.OnDemand
+	}
+	if fc.BackupIntervalMin > 0 {
+		cfg.BackupIntervalMin = fc.BackupIntervalMin
+	}
+	if fc.SyncTarget != "" {
+		cfg.SyncTarget = fc.SyncTarget
+	}
+	if fc.DeviceName != "" {
+		cfg.DeviceName = fc.DeviceName
+	}
+	if fc.DebounceMs > 0 {
+		cfg.DebounceMs = fc.DebounceMs
+	}
+	if fc.DebounceMaxMs > 0 {
+		cfg.DebounceMaxMs = fc.DebounceMaxMs
+	}
+	if fc.SharedWithMe != "" {
+		cfg.SharedWithMe = fc.SharedWithMe
+	}
+	if fc.SharedWithMeDir != "" {
+		cfg.SharedWithMeDir = fc.SharedWithMeDir
+	}
+	if fc.DriveAccess != "" {
+		cfg.DriveAccess = fc.DriveAccess
+	}
+	if fc.MaxFileSize != "" {
+		cfg.MaxFileSize = fc.MaxFileSize
+	}
+	if len(fc.ExcludeExtensions) > 0 {
+		cfg.ExcludeExtensions = fc.ExcludeExtensions
+	}
+	if len(fc.ExcludeMimeTypes) > 0 {
+		cfg.ExcludeMimeTypes = fc.ExcludeMimeTypes
+	}
+	if fc.IPCCompression != "" {
+		cfg.IPCCompression = fc.IPCCompression
+	}
+	if fc.IPCCompressMinKB > 0 {
+		cfg.IPCCompressMinKB = fc.IPCCompressMinKB
+	}
+	if fc.EmptyFolders != "" {
+		cfg.EmptyFolders = fc.EmptyFolders
+	}
+	if fc.SkipEmptyFiles != nil {
+		cfg.SkipEmptyFiles = *fc.SkipEmptyFiles
+	}
+	if fc.OpMaxRetries > 0 {
+		cfg.OpMaxRetries = fc.OpMaxRetries
+	}
+	if fc.AuditOnly != nil {
+		cfg.AuditOnly = *fc.AuditOnly
+	}
+	if fc.MinFreeSpaceMB > 0 {
+		cfg.MinFreeSpaceMB = fc.MinFreeSpaceMB
+	}
+	if fc.RemoteListen != "" {
+		cfg.RemoteListen = fc.RemoteListen
+	}
+	if fc.RemoteTLSCert != "" {
+		cfg.RemoteTLSCert = fc.RemoteTLSCert
+	}
+	if fc.RemoteTLSKey != "" {
+		cfg.RemoteTLSKey = fc.RemoteTLSKey
+	}
+	if fc.RemoteTLSCA != "" {
+		cfg.RemoteTLSCA = fc.RemoteTLSCA
+	}
+	if fc.RemoteToken != "" {
+		cfg.RemoteToken = fc.RemoteToken
+	}
+	if fc.QuotaPollSeconds > 0 {
+		cfg.QuotaPollSeconds = fc.QuotaPollSeconds
+	}
+	if fc.WebhookMaxAttempts > 0 {
+		cfg.WebhookMaxAttempts = fc.WebhookMaxAttempts
+	}
+	if fc.WebhookTimeoutSeconds > 0 {
+		cfg.WebhookTimeoutSeconds = fc.WebhookTimeoutSeconds
+	}
+	if fc.QuotaAlertPercent > 0 {
+		cfg.QuotaAlertPercent = fc.QuotaAlertPercent
+	}
+	if fc.GCIntervalHours > 0 {
+		cfg.GCIntervalHours = fc.GCIntervalHours
+	}
+	if fc.CaseSensitivity != "" {
+		cfg.CaseSensitivity = fc.CaseSensitivity
+	}
+	if fc.NameEncoding != "" {
+		cfg.NameEncoding = fc.NameEncoding
+	}
+	if fc.EncryptContent != nil {
+		cfg.EncryptContent = *fc.EncryptContent
+	}
+	if fc.EncryptNames != nil {
+		cfg.EncryptNames = *fc.EncryptNames
+	}
+	if fc.CompressUploads != nil {
+		cfg.CompressUploads = *fc.CompressUploads
+	}
+	if len(fc.CompressSkipTypes) > 0 {
+		cfg.CompressSkipTypes = fc.CompressSkipTypes
+	}
+	if fc.ChangesPollMinSeconds > 0 {
+		cfg.ChangesPollMinSeconds = fc.ChangesPollMinSeconds
+	}
+	if fc.ChangesPollMaxSeconds > 0 {
+		cfg.ChangesPollMaxSeconds = fc.ChangesPollMaxSeconds
+	}
+	if fc.ChangesPushURL != "" {
+		cfg.ChangesPushURL = fc.ChangesPushURL
+	}
+	if fc.ChangesPushListen != "" {
+		cfg.ChangesPushListen = fc.ChangesPushListen
+	}
+	if fc.UnsyncableTypes != "" {
+		cfg.UnsyncableTypes = fc.UnsyncableTypes
+	}
+	if fc.ContentCacheMB > 0 {
+		cfg.ContentCacheMB = fc.ContentCacheMB
+	}
+	if fc.DBMaintenanceHours > 0 {
+		cfg.DBMaintenanceHours = fc.DBMaintenanceHours
+	}
+	if fc.EncryptDatabase != nil {
+		cfg.EncryptDatabase = *fc.EncryptDatabase
+	}
+	if fc.ChangeJournalSize > 0 {
+		cfg.ChangeJournalSize = fc.ChangeJournalSize
+	}
+	if fc.StorageBackend != "" {
+		cfg.StorageBackend = fc.StorageBackend
+	}
+	if fc.Account != "" {
+		cfg.Account = fc.Account
+	}
+	if fc.ServiceAccountKey != "" {
+		cfg.ServiceAccountKey = fc.ServiceAccountKey
+	}
+	if fc.ServiceAccountSubject != "" {
+		cfg.ServiceAccountSubject = fc.ServiceAccountSubject
+	}
+	if fc.TokenStore != "" {
+		cfg.TokenStore = fc.TokenStore
+	}
+	if fc.KeyringBackend != "" {
+		cfg.KeyringBackend = fc.KeyringBackend
+	}
+	if fc.TokenPassphraseFile != "" {
+		cfg.TokenPassphraseFile = fc.TokenPassphraseFile
+	}
 
 	return nil
 }
@@ -244,6 +650,12 @@ func applyEnv(cfg *Config) {
 	if v := os.Getenv("GOOGLYSYNC_IGNORE_PATTERNS"); v != "" {
 		cfg.IgnorePatterns = splitList(v)
 	}
+	if v := os.Getenv("GOOGLYSYNC_WATCH_ROOTS"); v != "" {
+		cfg.WatchRoots = nil
+		for _, path := range splitList(v) {
+			cfg.WatchRoots = append(cfg.WatchRoots, WatchRoot{Path: path})
+		}
+	}
 	if v := os.Getenv("GOOGLYSYNC_EVENT_LOG_SIZE"); v != "" {
 		if i, err := strconv.Atoi(v); err == nil && i > 0 {
 			cfg.EventLogSize = i
@@ -263,6 +675,286 @@ func applyEnv(cfg *Config) {
 	if v := os.Getenv("GOOGLYSYNC_OAUTH_REDIRECT_HOST"); v != "" {
 		cfg.OAuthRedirectHost = v
 	}
+	if v := os.Getenv("GOOGLYSYNC_OAUTH_CREDENTIALS_FILE"); v != "" {
+		cfg.OAuthCredentialsFile = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_OAUTH_AUTH_URL"); v != "" {
+		cfg.OAuthAuthURL = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_OAUTH_TOKEN_URL"); v != "" {
+		cfg.OAuthTokenURL = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_ALLOWED_DOMAINS"); v != "" {
+		cfg.AllowedDomains = splitList(v)
+	}
+	if v := os.Getenv("GOOGLYSYNC_SIGN_IN_TIMEOUT_SECONDS"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.SignInTimeoutSeconds = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_PREALLOCATE_MIN_MB"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.PreallocateMinMB = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_SYNC_DIRECTION"); v != "" {
+		cfg.SyncDirection = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_VERSIONS_KEEP"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.VersionsKeep = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_VERSIONS_MAX_AGE_DAYS"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.VersionsMaxAgeDays = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_WATCH_MODE"); v != "" {
+		cfg.WatchMode = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_POLL_INTERVAL_SEC"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.PollIntervalSec = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_PERSIST_EVENTS"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.PersistEvents = b
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_TRANSFER_BUFFER_KB"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.TransferBufferKB = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_TRANSFER_MEMORY_MB"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.TransferMemoryMB = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_SYMLINK_POLICY"); v != "" {
+		cfg.SymlinkPolicy = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_IPC_RATE_LIMIT"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.IPCRateLimit = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_IPC_RATE_BURST"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.IPCRateBurst = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_IPC_MAX_CONCURRENT"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.IPCMaxConcurrent = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_FILE_WATCH_INTERVAL_SEC"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.FileWatchIntervalSec = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_ON_DEMAND"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.OnDemand = b
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_BACKUP_INTERVAL_MIN"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.BackupIntervalMin = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_SYNC_TARGET"); v != "" {
+		cfg.SyncTarget = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_DEVICE_NAME"); v != "" {
+		cfg.DeviceName = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_DEBOUNCE_MS"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.DebounceMs = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_DEBOUNCE_MAX_MS"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.DebounceMaxMs = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_SHARED_WITH_ME"); v != "" {
+		cfg.SharedWithMe = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_SHARED_WITH_ME_DIR"); v != "" {
+		cfg.SharedWithMeDir = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_DRIVE_ACCESS"); v != "" {
+		cfg.DriveAccess = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_MAX_FILE_SIZE"); v != "" {
+		cfg.MaxFileSize = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_EXCLUDE_EXTENSIONS"); v != "" {
+		cfg.ExcludeExtensions = splitList(v)
+	}
+	if v := os.Getenv("GOOGLYSYNC_EXCLUDE_MIME_TYPES"); v != "" {
+		cfg.ExcludeMimeTypes = splitList(v)
+	}
+	if v := os.Getenv("GOOGLYSYNC_IPC_COMPRESSION"); v != "" {
+		cfg.IPCCompression = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_IPC_COMPRESS_MIN_KB"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.IPCCompressMinKB = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_EMPTY_FOLDERS"); v != "" {
+		cfg.EmptyFolders = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_SKIP_EMPTY_FILES"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.SkipEmptyFiles = b
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_OP_MAX_RETRIES"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.OpMaxRetries = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_AUDIT_ONLY"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.AuditOnly = b
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_MIN_FREE_SPACE_MB"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.MinFreeSpaceMB = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_REMOTE_LISTEN"); v != "" {
+		cfg.RemoteListen = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_REMOTE_TLS_CERT"); v != "" {
+		cfg.RemoteTLSCert = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_REMOTE_TLS_KEY"); v != "" {
+		cfg.RemoteTLSKey = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_REMOTE_TLS_CA"); v != "" {
+		cfg.RemoteTLSCA = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_REMOTE_TOKEN"); v != "" {
+		cfg.RemoteToken = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_QUOTA_POLL_SECONDS"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.QuotaPollSeconds = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_WEBHOOK_MAX_ATTEMPTS"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.WebhookMaxAttempts = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_WEBHOOK_TIMEOUT_SECONDS"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.WebhookTimeoutSeconds = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_QUOTA_ALERT_PERCENT"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.QuotaAlertPercent = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_GC_INTERVAL_HOURS"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.GCIntervalHours = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_CASE_SENSITIVITY"); v != "" {
+		cfg.CaseSensitivity = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_NAME_ENCODING"); v != "" {
+		cfg.NameEncoding = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_ENCRYPT_CONTENT"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.EncryptContent = b
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_ENCRYPT_NAMES"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.EncryptNames = b
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_COMPRESS_UPLOADS"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.CompressUploads = b
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_COMPRESS_SKIP_TYPES"); v != "" {
+		cfg.CompressSkipTypes = splitList(v)
+	}
+	if v := os.Getenv("GOOGLYSYNC_CHANGES_POLL_MIN_SECONDS"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.ChangesPollMinSeconds = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_CHANGES_POLL_MAX_SECONDS"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.ChangesPollMaxSeconds = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_CHANGES_PUSH_URL"); v != "" {
+		cfg.ChangesPushURL = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_CHANGES_PUSH_LISTEN"); v != "" {
+		cfg.ChangesPushListen = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_UNSYNCABLE_TYPES"); v != "" {
+		cfg.UnsyncableTypes = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_CONTENT_CACHE_MB"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.ContentCacheMB = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_DB_MAINTENANCE_HOURS"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.DBMaintenanceHours = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_ENCRYPT_DATABASE"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.EncryptDatabase = b
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_CHANGE_JOURNAL_SIZE"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			cfg.ChangeJournalSize = i
+		}
+	}
+	if v := os.Getenv("GOOGLYSYNC_STORAGE_BACKEND"); v != "" {
+		cfg.StorageBackend = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_ACCOUNT"); v != "" {
+		cfg.Account = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_SERVICE_ACCOUNT_KEY"); v != "" {
+		cfg.ServiceAccountKey = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_SERVICE_ACCOUNT_SUBJECT"); v != "" {
+		cfg.ServiceAccountSubject = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_TOKEN_STORE"); v != "" {
+		cfg.TokenStore = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_KEYRING_BACKEND"); v != "" {
+		cfg.KeyringBackend = v
+	}
+	if v := os.Getenv("GOOGLYSYNC_TOKEN_PASSPHRASE_FILE"); v != "" {
+		cfg.TokenPassphraseFile = v
+	}
 }
 
 func splitList(val string) []string {
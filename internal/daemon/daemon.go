@@ -2,50 +2,81 @@ package daemon
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"go.uber.org/zap"
 
 	"github.com/sandeepkv93/googlysync/internal/auth"
 	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/device"
 	"github.com/sandeepkv93/googlysync/internal/fswatch"
 	"github.com/sandeepkv93/googlysync/internal/ipc"
-	syncer "github.com/sandeepkv93/googlysync/internal/sync"
+	"github.com/sandeepkv93/googlysync/internal/notify"
 	"github.com/sandeepkv93/googlysync/internal/storage"
+	syncer "github.com/sandeepkv93/googlysync/internal/sync"
 )
 
 // Daemon wires together core services.
 type Daemon struct {
-	Logger  *zap.Logger
-	Config  *config.Config
-	Storage *storage.Storage
-	Auth    *auth.Service
-	Sync    *syncer.Engine
-	Watcher *fswatch.Watcher
-	IPC     *ipc.Server
-	Queue   *syncer.Queue
+	Logger   *zap.Logger
+	Config   *config.Config
+	Storage  storage.Store
+	Auth     *auth.Service
+	Sync     *syncer.Engine
+	Ops      *syncer.Executor
+	Watcher  *fswatch.Watcher
+	IPC      *ipc.Server
+	Queue    *syncer.Queue
+	Watches  *notify.FileWatcher
+	Device   *device.Registrar
+	Webhooks *notify.Webhooks
+	GC       *syncer.GarbageCollector
+	Changes  *syncer.ChangePoller
+	Push     *syncer.ChangePush
+	Maintain *syncer.Maintainer
+	Tokens   *auth.PreRefresher
 }
 
 // NewDaemon constructs a daemon.
 func NewDaemon(
 	logger *zap.Logger,
 	cfg *config.Config,
-	store *storage.Storage,
+	store storage.Store,
 	authSvc *auth.Service,
 	syncEngine *syncer.Engine,
+	ops *syncer.Executor,
 	watcher *fswatch.Watcher,
 	ipcServer *ipc.Server,
 	queue *syncer.Queue,
+	watches *notify.FileWatcher,
+	registrar *device.Registrar,
+	webhooks *notify.Webhooks,
+	gc *syncer.GarbageCollector,
+	changes *syncer.ChangePoller,
+	push *syncer.ChangePush,
+	maintainer *syncer.Maintainer,
+	tokens *auth.PreRefresher,
 ) (*Daemon, error) {
 	logger.Info("daemon initialized")
 	return &Daemon{
-		Logger:  logger,
-		Config:  cfg,
-		Storage: store,
-		Auth:    authSvc,
-		Sync:    syncEngine,
-		Watcher: watcher,
-		IPC:     ipcServer,
-		Queue:   queue,
+		Logger:   logger,
+		Config:   cfg,
+		Storage:  store,
+		Auth:     authSvc,
+		Sync:     syncEngine,
+		Ops:      ops,
+		Watcher:  watcher,
+		IPC:      ipcServer,
+		Queue:    queue,
+		Watches:  watches,
+		Device:   registrar,
+		Webhooks: webhooks,
+		GC:       gc,
+		Changes:  changes,
+		Push:     push,
+		Maintain: maintainer,
+		Tokens:   tokens,
 	}, nil
 }
 
@@ -53,14 +84,73 @@ func NewDaemon(
 func (d *Daemon) Run(ctx context.Context) error {
 	d.Logger.Info("daemon running")
 
+	target, err := device.ParseTarget(d.Config.SyncTarget)
+	if err != nil {
+		return err
+	}
+	if d.Device != nil && target == device.TargetComputers {
+		if _, err := d.Device.Ensure(ctx); err != nil {
+			d.Logger.Warn("device registration failed", zap.Error(err))
+		}
+	}
+
 	syncCtx, syncCancel := context.WithCancel(ctx)
 	if d.Sync != nil {
 		go d.Sync.Run(syncCtx)
 	}
 
+	if d.Sync != nil && d.Auth != nil {
+		events, stop := d.Auth.Watch()
+		go func() {
+			defer stop()
+			d.holdForReauth(syncCtx, events)
+		}()
+	}
+
+	if d.Ops != nil {
+		// Run recovers ops a crash left in progress before taking new ones.
+		go d.Ops.Run(syncCtx)
+	}
+
+	if d.Watches != nil {
+		go d.Watches.Run(syncCtx)
+	}
+
+	if d.Webhooks != nil {
+		go d.Webhooks.Run(syncCtx)
+	}
+
+	if d.GC != nil {
+		go d.GC.Run(syncCtx)
+	}
+
+	if d.Changes != nil {
+		go d.Changes.Run(syncCtx)
+	}
+
+	if d.Maintain != nil {
+		go d.Maintain.Run(syncCtx)
+	}
+
+	if d.Push != nil {
+		go d.Push.Run(syncCtx)
+	}
+
+	if d.Tokens != nil {
+		go d.Tokens.Run(syncCtx)
+	}
+
 	if d.Watcher != nil {
 		if err := d.Watcher.Start(syncCtx); err != nil {
 			d.Logger.Warn("fswatch start failed", zap.Error(err))
+		} else if d.Sync != nil && d.Queue != nil {
+			// The watcher is up, so whatever changes during the scan is
+			// reported by it as well.
+			go func() {
+				if _, err := d.Sync.ScanOffline(syncCtx); err != nil && syncCtx.Err() == nil {
+					d.Logger.Warn("scanning for changes made while offline failed", zap.Error(err))
+				}
+			}()
 		}
 	}
 
@@ -98,6 +188,54 @@ func (d *Daemon) Run(ctx context.Context) error {
 	}
 }
 
+// holdForReauth keeps syncing paused while the synced account has to sign in
+// again, from a revoked or expired refresh token or for a scope drive_access
+// needs and it has not granted, and lets it run once the account has signed
+// in again.
+func (d *Daemon) holdForReauth(ctx context.Context, events <-chan auth.Event) {
+	if acct := d.Auth.State().Account; acct.ReauthReason != "" {
+		d.Sync.HoldForReauth(acct.ReauthReason)
+	} else if acct.ID != "" {
+		d.holdForConsent(ctx, acct)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt := <-events:
+			if evt.AccountID != d.Auth.State().Account.ID {
+				continue
+			}
+			switch evt.Kind {
+			case auth.EventTokenInvalid:
+				d.Sync.HoldForReauth(evt.Err)
+			case auth.EventAccountRenewed, auth.EventTokenValid:
+				if evt.Kind == auth.EventAccountRenewed && d.holdForConsent(ctx, d.Auth.State().Account) {
+					continue
+				}
+				if err := d.Sync.ReleaseReauth(ctx); err != nil {
+					d.Logger.Warn("resuming sync after sign-in failed", zap.Error(err))
+				}
+			}
+		}
+	}
+}
+
+// holdForConsent holds syncing when acct has not granted the scopes
+// drive_access needs, and reports whether it did.
+func (d *Daemon) holdForConsent(ctx context.Context, acct storage.Account) bool {
+	missing, err := d.Auth.MissingScopes(ctx, acct.ID)
+	if err != nil {
+		d.Logger.Warn("checking granted scopes failed", zap.String("account", acct.ID), zap.Error(err))
+		return false
+	}
+	if len(missing) == 0 {
+		return false
+	}
+	d.Sync.HoldForConsent(fmt.Sprintf("%s has not granted %s", acct.Email, strings.Join(missing, " ")))
+	return true
+}
+
 // Close releases resources owned by the daemon.
 func (d *Daemon) Close() error {
 	if d.Watcher != nil {
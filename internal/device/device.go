@@ -0,0 +1,157 @@
+package device
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// Sync targets select where the sync root lives in Drive.
+const (
+	TargetMyDrive   = "my-drive"
+	TargetComputers = "computers"
+)
+
+// App property keys tag the folders this client creates so they can be found
+// again after the local database is lost.
+const (
+	propDevice = "googlysyncDevice"
+	propRoot   = "googlysyncRoot"
+)
+
+// ParseTarget validates a sync target name; empty means My Drive.
+func ParseTarget(val string) (string, error) {
+	switch val {
+	case "", TargetMyDrive:
+		return TargetMyDrive, nil
+	case TargetComputers:
+		return TargetComputers, nil
+	}
+	return "", errs.New(errs.ErrInvalidArgument, "unknown sync target %q (want %s or %s)", val, TargetMyDrive, TargetComputers)
+}
+
+// FolderClient is the subset of the Drive API used to manage device folders.
+type FolderClient interface {
+	CreateFolder(ctx context.Context, name string, parents []string, appProperties map[string]string) (*driveapi.File, error)
+	FindByAppProperty(ctx context.Context, key, value string) ([]driveapi.File, error)
+	Rename(ctx context.Context, id, name string) (*driveapi.File, error)
+}
+
+// ClientFunc returns the account to register under and a Drive client for
+// it. A nil client means no account is signed in.
+type ClientFunc func(ctx context.Context) (string, FolderClient)
+
+// Registrar creates and names the Drive folder that represents this machine.
+type Registrar struct {
+	logger  *zap.Logger
+	cfg     *config.Config
+	store   storage.Store
+	clients ClientFunc
+}
+
+// NewRegistrar constructs a device registrar.
+func NewRegistrar(logger *zap.Logger, cfg *config.Config, store storage.Store, clients ClientFunc) *Registrar {
+	return &Registrar{logger: logger, cfg: cfg, store: store, clients: clients}
+}
+
+// Name returns the configured device name, falling back to the hostname.
+func (r *Registrar) Name() string {
+	if name := strings.TrimSpace(r.cfg.DeviceName); name != "" {
+		return name
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "googlysync"
+}
+
+// Ensure returns the device registration, creating the device node and the
+// folder for the sync root on first run. Folders left behind by an earlier
+// install are reused when the local database no longer knows about them.
+func (r *Registrar) Ensure(ctx context.Context) (*storage.Device, error) {
+	accountID, client := r.clients(ctx)
+	if client == nil {
+		return nil, errs.New(errs.ErrAuthExpired, "no signed-in account for device registration")
+	}
+	existing, err := r.store.GetDevice(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.RootDriveID != "" {
+		return existing, nil
+	}
+
+	name := r.Name()
+	dev := &storage.Device{AccountID: accountID, Name: name}
+	if existing != nil {
+		dev = existing
+	}
+	if dev.DriveID == "" {
+		node, err := r.findOrCreate(ctx, client, propDevice, name, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		dev.DriveID = node.ID
+		dev.Name = node.Name
+	}
+
+	rootName := filepath.Base(filepath.Clean(r.cfg.SyncRoot))
+	if rootName == "" || rootName == "." || rootName == string(filepath.Separator) {
+		rootName = "Sync"
+	}
+	root, err := r.findOrCreate(ctx, client, propRoot, dev.DriveID, rootName, []string{dev.DriveID})
+	if err != nil {
+		return nil, err
+	}
+	dev.RootDriveID = root.ID
+	if err := r.store.SaveDevice(ctx, dev); err != nil {
+		return nil, err
+	}
+	r.logger.Info("device registered", zap.String("name", dev.Name), zap.String("drive_id", dev.DriveID))
+	return dev, nil
+}
+
+// Rename changes the device node's name in Drive and locally.
+func (r *Registrar) Rename(ctx context.Context, name string) (*storage.Device, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errs.New(errs.ErrInvalidArgument, "device name cannot be empty")
+	}
+	dev, err := r.Ensure(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, client := r.clients(ctx)
+	if client == nil {
+		return nil, errs.New(errs.ErrAuthExpired, "no signed-in account for device registration")
+	}
+	if _, err := client.Rename(ctx, dev.DriveID, name); err != nil {
+		return nil, err
+	}
+	dev.Name = name
+	if err := r.store.SaveDevice(ctx, dev); err != nil {
+		return nil, err
+	}
+	return dev, nil
+}
+
+func (r *Registrar) findOrCreate(ctx context.Context, client FolderClient, key, value, name string, parents []string) (*driveapi.File, error) {
+	found, err := client.FindByAppProperty(ctx, key, value)
+	if err != nil {
+		return nil, err
+	}
+	for i := range found {
+		if found[i].MimeType == driveapi.FolderMimeType {
+			return &found[i], nil
+		}
+	}
+	return client.CreateFolder(ctx, name, parents, map[string]string{key: value})
+}
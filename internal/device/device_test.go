@@ -0,0 +1,129 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+type fakeDrive struct {
+	files   []driveapi.File
+	tags    map[string]string
+	created int
+}
+
+func (f *fakeDrive) CreateFolder(_ context.Context, name string, parents []string, props map[string]string) (*driveapi.File, error) {
+	f.created++
+	file := driveapi.File{ID: fmt.Sprintf("folder-%d", f.created), Name: name, MimeType: driveapi.FolderMimeType, Parents: parents}
+	if f.tags == nil {
+		f.tags = make(map[string]string)
+	}
+	for k, v := range props {
+		f.tags[file.ID] = k + "=" + v
+	}
+	f.files = append(f.files, file)
+	return &file, nil
+}
+
+func (f *fakeDrive) FindByAppProperty(_ context.Context, key, value string) ([]driveapi.File, error) {
+	var out []driveapi.File
+	for _, file := range f.files {
+		if f.tags[file.ID] == key+"="+value {
+			out = append(out, file)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeDrive) Rename(_ context.Context, id, name string) (*driveapi.File, error) {
+	for i := range f.files {
+		if f.files[i].ID == id {
+			f.files[i].Name = name
+			return &f.files[i], nil
+		}
+	}
+	return nil, errs.New(errs.ErrNotFound, "no file %s", id)
+}
+
+func newTestRegistrar(t *testing.T, drive FolderClient) (*Registrar, *storage.Storage) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &config.Config{
+		DataDir:      dir,
+		DatabasePath: filepath.Join(dir, "googlysync.db"),
+		SyncRoot:     filepath.Join(dir, "Documents"),
+		DeviceName:   "laptop",
+	}
+	store, err := storage.NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	clients := func(context.Context) (string, FolderClient) { return "default", drive }
+	return NewRegistrar(zap.NewNop(), cfg, store, clients), store
+}
+
+func TestEnsureCreatesOnceAndReuses(t *testing.T) {
+	drive := &fakeDrive{}
+	r, store := newTestRegistrar(t, drive)
+	ctx := context.Background()
+
+	dev, err := r.Ensure(ctx)
+	if err != nil {
+		t.Fatalf("Ensure: %v", err)
+	}
+	if dev.Name != "laptop" || dev.DriveID != "folder-1" || dev.RootDriveID != "folder-2" {
+		t.Fatalf("unexpected device: %#v", dev)
+	}
+	if drive.files[1].Name != "Documents" || drive.files[1].Parents[0] != "folder-1" {
+		t.Fatalf("expected root folder under the device node, got %#v", drive.files[1])
+	}
+	if _, err := r.Ensure(ctx); err != nil || drive.created != 2 {
+		t.Fatalf("expected no new folders, created %d, err %v", drive.created, err)
+	}
+
+	// A lost database finds the tagged folders instead of duplicating them.
+	if _, err := store.DB.Exec(`DELETE FROM devices`); err != nil {
+		t.Fatalf("reset devices: %v", err)
+	}
+	dev, err = r.Ensure(ctx)
+	if err != nil || drive.created != 2 || dev.RootDriveID != "folder-2" {
+		t.Fatalf("expected reuse, got %#v, created %d, err %v", dev, drive.created, err)
+	}
+}
+
+func TestRename(t *testing.T) {
+	drive := &fakeDrive{}
+	r, store := newTestRegistrar(t, drive)
+	ctx := context.Background()
+
+	if _, err := r.Rename(ctx, "workstation"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if drive.files[0].Name != "workstation" {
+		t.Fatalf("expected remote rename, got %#v", drive.files[0])
+	}
+	if dev, _ := store.GetDevice(ctx, "default"); dev == nil || dev.Name != "workstation" {
+		t.Fatalf("expected stored rename, got %#v", dev)
+	}
+	if _, err := r.Rename(ctx, " "); !errors.Is(err, errs.ErrInvalidArgument) {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+}
+
+func TestEnsureWithoutAccount(t *testing.T) {
+	r, _ := newTestRegistrar(t, nil)
+	r.clients = func(context.Context) (string, FolderClient) { return "", nil }
+	if _, err := r.Ensure(context.Background()); !errors.Is(err, errs.ErrAuthExpired) {
+		t.Fatalf("expected auth expired, got %v", err)
+	}
+}
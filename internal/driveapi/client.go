@@ -0,0 +1,771 @@
+package driveapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// DefaultBaseURL is the Drive v3 REST endpoint.
+const DefaultBaseURL = "https://www.googleapis.com/drive/v3"
+
+// DefaultUploadURL is the Drive v3 endpoint for requests that carry content.
+const DefaultUploadURL = "https://www.googleapis.com/upload/drive/v3"
+
+// fileFields lists the metadata requested for every file.
+const fileFields = "id,name,mimeType,md5Checksum,size,modifiedTime,parents,trashed,version,lastModifyingUser(displayName,emailAddress,me),capabilities(canEdit),appProperties,headRevisionId,starred,shared,webViewLink"
+
+// File is the subset of Drive file metadata the client uses.
+type File struct {
+	ID           string
+	Name         string
+	MimeType     string
+	MD5Checksum  string
+	Size         int64
+	ModifiedTime time.Time
+	Parents      []string
+	Trashed      bool
+	// Version increases on every change to the file.
+	Version           int64
+	LastModifyingUser User
+	// ReadOnly is set when Drive reports the account cannot edit the file,
+	// as with files shared view- or comment-only.
+	ReadOnly bool
+	// AppProperties are private key-value pairs set on the file by this app.
+	AppProperties map[string]string
+	// HeadRevisionID identifies the current content revision. Drive only
+	// reports it for files with binary content.
+	HeadRevisionID string
+	Starred        bool
+	// Shared is set when the file has been shared with anyone.
+	Shared      bool
+	WebViewLink string
+}
+
+// User identifies the Drive user behind a change.
+type User struct {
+	DisplayName string
+	Email       string
+	// Me is set when the user is the authenticated account.
+	Me bool
+}
+
+// Client is a minimal Drive v3 REST client. Authentication is the caller's
+// responsibility: pass an HTTP client that attaches OAuth tokens.
+type Client struct {
+	http      *http.Client
+	baseURL   string
+	uploadURL string
+}
+
+// NewClient constructs a client using the given authorized HTTP client.
+func NewClient(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{http: httpClient, baseURL: DefaultBaseURL, uploadURL: DefaultUploadURL}
+}
+
+// WithBaseURL overrides the API endpoint, e.g. for tests. Content is then
+// uploaded under its /upload path.
+func (c *Client) WithBaseURL(base string) *Client {
+	c.baseURL = strings.TrimSuffix(base, "/")
+	c.uploadURL = c.baseURL + "/upload"
+	return c
+}
+
+// SearchFullText lists non-trashed files whose name or content matches query.
+func (c *Client) SearchFullText(ctx context.Context, query string, limit int) ([]File, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	q := fmt.Sprintf("fullText contains '%s' and trashed = false", escapeQuery(query))
+	return c.listFiles(ctx, q, limit)
+}
+
+// GetFile fetches metadata for a single file by id.
+func (c *Client) GetFile(ctx context.Context, id string) (*File, error) {
+	if id == "" {
+		return nil, errs.New(errs.ErrInvalidArgument, "file id cannot be empty")
+	}
+	params := url.Values{}
+	params.Set("fields", fileFields)
+	params.Set("supportsAllDrives", "true")
+	var f fileJSON
+	if err := c.get(ctx, "/files/"+url.PathEscape(id), params, &f); err != nil {
+		return nil, err
+	}
+	file := f.toFile()
+	return &file, nil
+}
+
+// Download streams a file's content. The caller must close the body.
+func (c *Client) Download(ctx context.Context, id string) (io.ReadCloser, error) {
+	if id == "" {
+		return nil, errs.New(errs.ErrInvalidArgument, "file id cannot be empty")
+	}
+	params := url.Values{}
+	params.Set("alt", "media")
+	params.Set("supportsAllDrives", "true")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/files/"+url.PathEscape(id)+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, readAPIError(resp)
+	}
+	return resp.Body, nil
+}
+
+// Quota is the account's storage usage in bytes. Limit is zero when the
+// account has unlimited storage.
+type Quota struct {
+	Limit        int64
+	Usage        int64
+	UsageInDrive int64
+	UsageInTrash int64
+}
+
+// Full reports whether usage has reached a finite limit.
+func (q Quota) Full() bool {
+	return q.Limit > 0 && q.Usage >= q.Limit
+}
+
+// GetQuota fetches the account's storage quota from about.get.
+func (c *Client) GetQuota(ctx context.Context) (*Quota, error) {
+	params := url.Values{}
+	params.Set("fields", "storageQuota")
+	var about struct {
+		StorageQuota struct {
+			Limit             string `json:"limit"`
+			Usage             string `json:"usage"`
+			UsageInDrive      string `json:"usageInDrive"`
+			UsageInDriveTrash string `json:"usageInDriveTrash"`
+		} `json:"storageQuota"`
+	}
+	if err := c.get(ctx, "/about", params, &about); err != nil {
+		return nil, err
+	}
+	q := about.StorageQuota
+	// Drive encodes int64 values as strings and omits limit when unlimited.
+	limit, _ := strconv.ParseInt(q.Limit, 10, 64)
+	usage, _ := strconv.ParseInt(q.Usage, 10, 64)
+	inDrive, _ := strconv.ParseInt(q.UsageInDrive, 10, 64)
+	inTrash, _ := strconv.ParseInt(q.UsageInDriveTrash, 10, 64)
+	return &Quota{Limit: limit, Usage: usage, UsageInDrive: inDrive, UsageInTrash: inTrash}, nil
+}
+
+// Mime types with special meaning in Drive.
+const (
+	FolderMimeType   = "application/vnd.google-apps.folder"
+	ShortcutMimeType = "application/vnd.google-apps.shortcut"
+	// googleAppsPrefix marks native Docs, Sheets, Slides, etc., which have
+	// no binary content to download.
+	googleAppsPrefix = "application/vnd.google-apps."
+)
+
+// IsGoogleNative reports whether a mime type is a native Google Workspace
+// type other than a folder or shortcut.
+func IsGoogleNative(mimeType string) bool {
+	return strings.HasPrefix(mimeType, googleAppsPrefix) && mimeType != FolderMimeType && mimeType != ShortcutMimeType
+}
+
+// exportableTypes are the native types Drive can export to a regular file
+// format. Forms, Sites, My Maps and third-party app files have none.
+var exportableTypes = map[string]bool{
+	googleAppsPrefix + "document":     true,
+	googleAppsPrefix + "spreadsheet":  true,
+	googleAppsPrefix + "presentation": true,
+	googleAppsPrefix + "drawing":      true,
+	googleAppsPrefix + "script":       true,
+	googleAppsPrefix + "jam":          true,
+	googleAppsPrefix + "vid":          true,
+}
+
+// IsExportable reports whether a native type can be exported to a file.
+func IsExportable(mimeType string) bool {
+	return exportableTypes[mimeType]
+}
+
+// OpenURL returns the address that opens a file in the browser, whatever
+// its type.
+func OpenURL(id string) string {
+	return "https://drive.google.com/open?id=" + url.QueryEscape(id)
+}
+
+// ListChildren lists every non-trashed item directly inside a folder. Use
+// "root" for the top of My Drive.
+func (c *Client) ListChildren(ctx context.Context, folderID string) ([]File, error) {
+	if folderID == "" {
+		return nil, errs.New(errs.ErrInvalidArgument, "folder id cannot be empty")
+	}
+	q := fmt.Sprintf("'%s' in parents and trashed = false", escapeQuery(folderID))
+	return c.listFiles(ctx, q, math.MaxInt32)
+}
+
+// CreateFolder creates a folder under parents, or at the top of My Drive when
+// parents is empty. App properties are private to this OAuth client.
+func (c *Client) CreateFolder(ctx context.Context, name string, parents []string, appProperties map[string]string) (*File, error) {
+	if name == "" {
+		return nil, errs.New(errs.ErrInvalidArgument, "folder name cannot be empty")
+	}
+	params := url.Values{}
+	params.Set("fields", fileFields)
+	params.Set("supportsAllDrives", "true")
+	body := map[string]any{"name": name, "mimeType": FolderMimeType}
+	if len(parents) > 0 {
+		body["parents"] = parents
+	}
+	if len(appProperties) > 0 {
+		body["appProperties"] = appProperties
+	}
+	var f fileJSON
+	if err := c.send(ctx, http.MethodPost, "/files", params, body, &f); err != nil {
+		return nil, err
+	}
+	file := f.toFile()
+	return &file, nil
+}
+
+// CreateShortcut creates a shortcut named name under parents that points at
+// the file or folder targetID.
+func (c *Client) CreateShortcut(ctx context.Context, name string, parents []string, targetID string) (*File, error) {
+	if name == "" || targetID == "" {
+		return nil, errs.New(errs.ErrInvalidArgument, "shortcut name and target cannot be empty")
+	}
+	params := url.Values{}
+	params.Set("fields", fileFields)
+	params.Set("supportsAllDrives", "true")
+	body := map[string]any{
+		"name":            name,
+		"mimeType":        ShortcutMimeType,
+		"shortcutDetails": map[string]string{"targetId": targetID},
+	}
+	if len(parents) > 0 {
+		body["parents"] = parents
+	}
+	var f fileJSON
+	if err := c.send(ctx, http.MethodPost, "/files", params, body, &f); err != nil {
+		return nil, err
+	}
+	file := f.toFile()
+	return &file, nil
+}
+
+// FileMeta is the metadata written along with a file.
+type FileMeta struct {
+	Name    string
+	Parents []string
+	// AppProperties are private to this OAuth client. An empty value
+	// clears a property set before.
+	AppProperties map[string]string
+}
+
+// Upload sends content to Drive as a new file named meta.Name under
+// meta.Parents, or as the new content of id when it is set; an update keeps
+// the file's name and parents. The content is streamed in one multipart
+// request, so its length need not be known ahead of time.
+func (c *Client) Upload(ctx context.Context, id string, meta FileMeta, content io.Reader) (*File, error) {
+	method, path := http.MethodPost, "/files"
+	body := map[string]any{}
+	if id == "" {
+		if meta.Name == "" {
+			return nil, errs.New(errs.ErrInvalidArgument, "file name cannot be empty")
+		}
+		body["name"] = meta.Name
+		if len(meta.Parents) > 0 {
+			body["parents"] = meta.Parents
+		}
+	} else {
+		method, path = http.MethodPatch, "/files/"+url.PathEscape(id)
+	}
+	if len(meta.AppProperties) > 0 {
+		body["appProperties"] = meta.AppProperties
+	}
+	metadata, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		_ = pw.CloseWithError(writeMultipart(mw, metadata, content))
+	}()
+	params := url.Values{}
+	params.Set("uploadType", "multipart")
+	params.Set("fields", fileFields)
+	params.Set("supportsAllDrives", "true")
+	req, err := http.NewRequestWithContext(ctx, method, c.uploadURL+path+"?"+params.Encode(), pr)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "multipart/related; boundary="+mw.Boundary())
+	var f fileJSON
+	if err := c.do(req, &f); err != nil {
+		return nil, err
+	}
+	file := f.toFile()
+	return &file, nil
+}
+
+// Copy makes a copy of file id on Drive named meta.Name under meta.Parents,
+// without sending its content again.
+func (c *Client) Copy(ctx context.Context, id string, meta FileMeta) (*File, error) {
+	if id == "" || meta.Name == "" {
+		return nil, errs.New(errs.ErrInvalidArgument, "file id and name cannot be empty")
+	}
+	params := url.Values{}
+	params.Set("fields", fileFields)
+	params.Set("supportsAllDrives", "true")
+	body := map[string]any{"name": meta.Name}
+	if len(meta.Parents) > 0 {
+		body["parents"] = meta.Parents
+	}
+	if len(meta.AppProperties) > 0 {
+		body["appProperties"] = meta.AppProperties
+	}
+	var f fileJSON
+	if err := c.send(ctx, http.MethodPost, "/files/"+url.PathEscape(id)+"/copy", params, body, &f); err != nil {
+		return nil, err
+	}
+	file := f.toFile()
+	return &file, nil
+}
+
+// writeMultipart writes the metadata part and then the content part of a
+// multipart upload.
+func writeMultipart(mw *multipart.Writer, metadata []byte, content io.Reader) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", "application/json; charset=UTF-8")
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(metadata); err != nil {
+		return err
+	}
+	header = textproto.MIMEHeader{}
+	header.Set("Content-Type", "application/octet-stream")
+	if part, err = mw.CreatePart(header); err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, content); err != nil {
+		return err
+	}
+	return mw.Close()
+}
+
+// Rename changes a file's name.
+func (c *Client) Rename(ctx context.Context, id, name string) (*File, error) {
+	if id == "" || name == "" {
+		return nil, errs.New(errs.ErrInvalidArgument, "file id and name cannot be empty")
+	}
+	params := url.Values{}
+	params.Set("fields", fileFields)
+	params.Set("supportsAllDrives", "true")
+	var f fileJSON
+	if err := c.send(ctx, http.MethodPatch, "/files/"+url.PathEscape(id), params, map[string]any{"name": name}, &f); err != nil {
+		return nil, err
+	}
+	file := f.toFile()
+	return &file, nil
+}
+
+// Move renames a file and moves it from one parent folder to another in a
+// single call. An empty name keeps the current one, and an empty or
+// unchanged toParent leaves the file where it is.
+func (c *Client) Move(ctx context.Context, id, name, fromParent, toParent string) (*File, error) {
+	if id == "" {
+		return nil, errs.New(errs.ErrInvalidArgument, "file id cannot be empty")
+	}
+	params := url.Values{}
+	params.Set("fields", fileFields)
+	params.Set("supportsAllDrives", "true")
+	if toParent != "" && toParent != fromParent {
+		params.Set("addParents", toParent)
+		if fromParent != "" {
+			params.Set("removeParents", fromParent)
+		}
+	}
+	body := map[string]any{}
+	if name != "" {
+		body["name"] = name
+	}
+	var f fileJSON
+	if err := c.send(ctx, http.MethodPatch, "/files/"+url.PathEscape(id), params, body, &f); err != nil {
+		return nil, err
+	}
+	file := f.toFile()
+	return &file, nil
+}
+
+// RemoveParent takes a file out of one of its parent folders. The file stays
+// in its other parents.
+func (c *Client) RemoveParent(ctx context.Context, id, parent string) (*File, error) {
+	if id == "" || parent == "" {
+		return nil, errs.New(errs.ErrInvalidArgument, "file id and parent cannot be empty")
+	}
+	params := url.Values{}
+	params.Set("fields", fileFields)
+	params.Set("supportsAllDrives", "true")
+	params.Set("removeParents", parent)
+	var f fileJSON
+	if err := c.send(ctx, http.MethodPatch, "/files/"+url.PathEscape(id), params, map[string]any{}, &f); err != nil {
+		return nil, err
+	}
+	file := f.toFile()
+	return &file, nil
+}
+
+// Trash moves a file, or a folder with everything in it, to the Drive trash.
+func (c *Client) Trash(ctx context.Context, id string) (*File, error) {
+	if id == "" {
+		return nil, errs.New(errs.ErrInvalidArgument, "file id cannot be empty")
+	}
+	params := url.Values{}
+	params.Set("fields", fileFields)
+	params.Set("supportsAllDrives", "true")
+	var f fileJSON
+	if err := c.send(ctx, http.MethodPatch, "/files/"+url.PathEscape(id), params, map[string]any{"trashed": true}, &f); err != nil {
+		return nil, err
+	}
+	file := f.toFile()
+	return &file, nil
+}
+
+// FindByAppProperty lists non-trashed files tagged with an app property.
+func (c *Client) FindByAppProperty(ctx context.Context, key, value string) ([]File, error) {
+	q := fmt.Sprintf("appProperties has { key='%s' and value='%s' } and trashed = false", escapeQuery(key), escapeQuery(value))
+	return c.listFiles(ctx, q, 100)
+}
+
+// Change is one entry of the Drive changes feed.
+type Change struct {
+	FileID string
+	// Removed is set when the file was deleted or the account lost access
+	// to it. File is nil then.
+	Removed bool
+	File    *File
+	// Time is when Drive recorded the change.
+	Time time.Time
+}
+
+// ChangePage is one page of the changes feed. Exactly one of NextPageToken
+// and NewStartPageToken is set: the first when more pages follow, the second
+// on the last page, to be used for the next poll.
+type ChangePage struct {
+	Changes           []Change
+	NextPageToken     string
+	NewStartPageToken string
+}
+
+// GetStartPageToken returns the token for changes made from now on.
+func (c *Client) GetStartPageToken(ctx context.Context) (string, error) {
+	params := url.Values{}
+	params.Set("supportsAllDrives", "true")
+	var resp struct {
+		StartPageToken string `json:"startPageToken"`
+	}
+	if err := c.get(ctx, "/changes/startPageToken", params, &resp); err != nil {
+		return "", err
+	}
+	return resp.StartPageToken, nil
+}
+
+// ListChanges returns the page of changes at pageToken.
+func (c *Client) ListChanges(ctx context.Context, pageToken string) (*ChangePage, error) {
+	if pageToken == "" {
+		return nil, errs.New(errs.ErrInvalidArgument, "page token cannot be empty")
+	}
+	params := url.Values{}
+	params.Set("pageToken", pageToken)
+	params.Set("pageSize", "1000")
+	params.Set("fields", "nextPageToken,newStartPageToken,changes(fileId,removed,time,file("+fileFields+"))")
+	params.Set("supportsAllDrives", "true")
+	params.Set("includeItemsFromAllDrives", "true")
+	var resp struct {
+		NextPageToken     string `json:"nextPageToken"`
+		NewStartPageToken string `json:"newStartPageToken"`
+		Changes           []struct {
+			FileID  string    `json:"fileId"`
+			Removed bool      `json:"removed"`
+			Time    time.Time `json:"time"`
+			File    *fileJSON `json:"file"`
+		} `json:"changes"`
+	}
+	if err := c.get(ctx, "/changes", params, &resp); err != nil {
+		return nil, err
+	}
+	page := &ChangePage{NextPageToken: resp.NextPageToken, NewStartPageToken: resp.NewStartPageToken}
+	for _, ch := range resp.Changes {
+		change := Change{FileID: ch.FileID, Removed: ch.Removed, Time: ch.Time}
+		if ch.File != nil && !ch.Removed {
+			file := ch.File.toFile()
+			change.File = &file
+		}
+		page.Changes = append(page.Changes, change)
+	}
+	return page, nil
+}
+
+// Channel is a push notification channel Drive posts to when something
+// it watches changes.
+type Channel struct {
+	ID string
+	// ResourceID identifies the watched resource; stopping the channel
+	// needs it.
+	ResourceID string
+	// Token is echoed in the X-Goog-Channel-Token header of every
+	// notification, so the receiver can tell them from forged ones.
+	Token      string
+	Address    string
+	Expiration time.Time
+}
+
+// WatchChanges opens a channel that notifies ch.Address of changes after
+// pageToken. Drive only posts to HTTPS addresses with a valid certificate.
+// A zero Expiration leaves the lifetime to Drive.
+func (c *Client) WatchChanges(ctx context.Context, pageToken string, ch Channel) (*Channel, error) {
+	if pageToken == "" || ch.ID == "" || ch.Address == "" {
+		return nil, errs.New(errs.ErrInvalidArgument, "page token, channel id and address cannot be empty")
+	}
+	params := url.Values{}
+	params.Set("pageToken", pageToken)
+	params.Set("supportsAllDrives", "true")
+	params.Set("includeItemsFromAllDrives", "true")
+	body := map[string]any{"id": ch.ID, "type": "web_hook", "address": ch.Address}
+	if ch.Token != "" {
+		body["token"] = ch.Token
+	}
+	if !ch.Expiration.IsZero() {
+		body["expiration"] = strconv.FormatInt(ch.Expiration.UnixMilli(), 10)
+	}
+	var resp struct {
+		ID         string `json:"id"`
+		ResourceID string `json:"resourceId"`
+		Token      string `json:"token"`
+		Address    string `json:"address"`
+		Expiration string `json:"expiration"`
+	}
+	if err := c.send(ctx, http.MethodPost, "/changes/watch", params, body, &resp); err != nil {
+		return nil, err
+	}
+	out := &Channel{ID: resp.ID, ResourceID: resp.ResourceID, Token: resp.Token, Address: resp.Address}
+	if ms, err := strconv.ParseInt(resp.Expiration, 10, 64); err == nil {
+		out.Expiration = time.UnixMilli(ms)
+	}
+	return out, nil
+}
+
+// StopChannel stops notifications on ch.
+func (c *Client) StopChannel(ctx context.Context, ch Channel) error {
+	if ch.ID == "" || ch.ResourceID == "" {
+		return errs.New(errs.ErrInvalidArgument, "channel id and resource id cannot be empty")
+	}
+	return c.send(ctx, http.MethodPost, "/channels/stop", url.Values{}, map[string]any{"id": ch.ID, "resourceId": ch.ResourceID}, nil)
+}
+
+func (c *Client) listFiles(ctx context.Context, q string, limit int) ([]File, error) {
+	var out []File
+	pageToken := ""
+	for len(out) < limit {
+		params := url.Values{}
+		params.Set("q", q)
+		params.Set("pageSize", strconv.Itoa(min(limit-len(out), 1000)))
+		params.Set("fields", "nextPageToken,files("+fileFields+")")
+		params.Set("supportsAllDrives", "true")
+		params.Set("includeItemsFromAllDrives", "true")
+		if pageToken != "" {
+			params.Set("pageToken", pageToken)
+		}
+
+		var page struct {
+			NextPageToken string     `json:"nextPageToken"`
+			Files         []fileJSON `json:"files"`
+		}
+		if err := c.get(ctx, "/files", params, &page); err != nil {
+			return nil, err
+		}
+		for _, f := range page.Files {
+			out = append(out, f.toFile())
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, params url.Values, dst any) error {
+	return c.send(ctx, http.MethodGet, path, params, nil, dst)
+}
+
+// send issues a request with an optional JSON body and decodes the response.
+func (c *Client) send(ctx context.Context, method, path string, params url.Values, body, dst any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path+"?"+params.Encode(), reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.do(req, dst)
+}
+
+// do sends req and decodes the JSON response into dst.
+func (c *Client) do(req *http.Request, dst any) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return readAPIError(resp)
+	}
+	if dst == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
+
+func readAPIError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	apiErr := &APIError{Status: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	var parsed struct {
+		Error struct {
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &parsed) == nil && len(parsed.Error.Errors) > 0 {
+		apiErr.Reason = parsed.Error.Errors[0].Reason
+	}
+	return apiErr
+}
+
+// APIError is a non-success response from the Drive API. It matches the
+// errs kind implied by its status and reason with errors.Is.
+type APIError struct {
+	Status int
+	// Reason is the first error reason Drive reported, e.g.
+	// "storageQuotaExceeded".
+	Reason string
+	Body   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("drive api: status %d: %s", e.Status, e.Body)
+}
+
+// Unwrap returns the errs kind for the response, or nil.
+func (e *APIError) Unwrap() error {
+	switch e.Status {
+	case http.StatusUnauthorized:
+		return errs.ErrAuthExpired
+	case http.StatusNotFound:
+		return errs.ErrNotFound
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return errs.ErrConflict
+	case http.StatusForbidden:
+		switch e.Reason {
+		case "storageQuotaExceeded", "quotaExceeded", "teamDriveFileLimitExceeded":
+			return errs.ErrQuotaExceeded
+		case "insufficientPermissions":
+			return errs.ErrScopeMissing
+		}
+	}
+	return nil
+}
+
+type fileJSON struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	MimeType     string    `json:"mimeType"`
+	MD5Checksum  string    `json:"md5Checksum"`
+	Size         string    `json:"size"`
+	ModifiedTime time.Time `json:"modifiedTime"`
+	Parents      []string  `json:"parents"`
+	Trashed      bool      `json:"trashed"`
+	Version      string    `json:"version"`
+
+	LastModifyingUser struct {
+		DisplayName  string `json:"displayName"`
+		EmailAddress string `json:"emailAddress"`
+		Me           bool   `json:"me"`
+	} `json:"lastModifyingUser"`
+
+	Capabilities *struct {
+		CanEdit *bool `json:"canEdit"`
+	} `json:"capabilities"`
+	AppProperties map[string]string `json:"appProperties"`
+
+	HeadRevisionID string `json:"headRevisionId"`
+	Starred        bool   `json:"starred"`
+	Shared         bool   `json:"shared"`
+	WebViewLink    string `json:"webViewLink"`
+}
+
+func (f fileJSON) toFile() File {
+	size, _ := strconv.ParseInt(f.Size, 10, 64)
+	version, _ := strconv.ParseInt(f.Version, 10, 64)
+	return File{
+		ID:           f.ID,
+		Name:         f.Name,
+		MimeType:     f.MimeType,
+		MD5Checksum:  f.MD5Checksum,
+		Size:         size,
+		ModifiedTime: f.ModifiedTime,
+		Parents:      f.Parents,
+		Trashed:      f.Trashed,
+		Version:      version,
+		LastModifyingUser: User{
+			DisplayName: f.LastModifyingUser.DisplayName,
+			Email:       f.LastModifyingUser.EmailAddress,
+			Me:          f.LastModifyingUser.Me,
+		},
+		ReadOnly:       f.Capabilities != nil && f.Capabilities.CanEdit != nil && !*f.Capabilities.CanEdit,
+		AppProperties:  f.AppProperties,
+		HeadRevisionID: f.HeadRevisionID,
+		Starred:        f.Starred,
+		Shared:         f.Shared,
+		WebViewLink:    f.WebViewLink,
+	}
+}
+
+// escapeQuery quotes a value for use inside a Drive query string literal.
+func escapeQuery(val string) string {
+	val = strings.ReplaceAll(val, `\`, `\\`)
+	return strings.ReplaceAll(val, `'`, `\'`)
+}
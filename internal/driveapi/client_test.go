@@ -0,0 +1,480 @@
+package driveapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+func TestSearchFullText(t *testing.T) {
+	var gotQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query().Get("q")
+		if r.URL.Query().Get("pageToken") == "" {
+			_, _ = w.Write([]byte(`{"nextPageToken":"p2","files":[{"id":"1","name":"a.txt","size":"12","modifiedTime":"2024-01-02T03:04:05Z"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"files":[{"id":"2","name":"b.txt","md5Checksum":"abc"}]}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.Client()).WithBaseURL(srv.URL)
+	files, err := c.SearchFullText(context.Background(), "it's", 10)
+	if err != nil {
+		t.Fatalf("SearchFullText: %v", err)
+	}
+	if gotQuery != `fullText contains 'it\'s' and trashed = false` {
+		t.Fatalf("unexpected query: %s", gotQuery)
+	}
+	if len(files) != 2 || files[0].Size != 12 || files[1].MD5Checksum != "abc" {
+		t.Fatalf("unexpected files: %#v", files)
+	}
+	if files[0].ModifiedTime.IsZero() {
+		t.Fatalf("expected modified time parsed: %#v", files[0])
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "denied", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(srv.Client()).WithBaseURL(srv.URL).SearchFullText(context.Background(), "x", 1)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusForbidden {
+		t.Fatalf("expected 403 APIError, got %v", err)
+	}
+	if errs.KindOf(err) != nil {
+		t.Fatalf("expected plain 403 to carry no kind, got %v", errs.KindOf(err))
+	}
+}
+
+func TestAPIErrorKinds(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{http.StatusUnauthorized, `{}`, errs.ErrAuthExpired},
+		{http.StatusNotFound, `{}`, errs.ErrNotFound},
+		{http.StatusPreconditionFailed, `{}`, errs.ErrConflict},
+		{http.StatusForbidden, `{"error":{"errors":[{"reason":"storageQuotaExceeded"}]}}`, errs.ErrQuotaExceeded},
+		{http.StatusForbidden, `{"error":{"errors":[{"reason":"insufficientPermissions"}]}}`, errs.ErrScopeMissing},
+	}
+	for _, tc := range cases {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+			_, _ = w.Write([]byte(tc.body))
+		}))
+		_, err := NewClient(srv.Client()).WithBaseURL(srv.URL).GetFile(context.Background(), "abc")
+		srv.Close()
+		if !errors.Is(err, tc.want) {
+			t.Fatalf("status %d: expected %v, got %v", tc.status, tc.want, err)
+		}
+	}
+}
+
+func TestGetFile(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_, _ = w.Write([]byte(`{"id":"abc","name":"plan.doc","version":"42","lastModifyingUser":{"displayName":"Ana","emailAddress":"ana@example.com"},"capabilities":{"canEdit":false},"appProperties":{"googlysync_compression":"gzip"},"headRevisionId":"rev-7","starred":true,"shared":true,"webViewLink":"https://drive.google.com/file/d/abc/view"}`))
+	}))
+	defer srv.Close()
+
+	f, err := NewClient(srv.Client()).WithBaseURL(srv.URL).GetFile(context.Background(), "abc")
+	if err != nil {
+		t.Fatalf("GetFile: %v", err)
+	}
+	if gotPath != "/files/abc" {
+		t.Fatalf("unexpected path: %s", gotPath)
+	}
+	if f.Version != 42 || f.LastModifyingUser.DisplayName != "Ana" || f.LastModifyingUser.Me || !f.ReadOnly || f.AppProperties["googlysync_compression"] != "gzip" {
+		t.Fatalf("unexpected file: %#v", f)
+	}
+	if f.HeadRevisionID != "rev-7" || !f.Starred || !f.Shared || f.WebViewLink == "" {
+		t.Fatalf("unexpected file: %#v", f)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("alt") != "media" {
+			http.Error(w, "want media", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte("payload"))
+	}))
+	defer srv.Close()
+
+	body, err := NewClient(srv.Client()).WithBaseURL(srv.URL).Download(context.Background(), "abc")
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil || string(data) != "payload" {
+		t.Fatalf("unexpected body %q, %v", data, err)
+	}
+}
+
+func TestCreateFolder(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"id":"folder-1","name":"laptop","mimeType":"application/vnd.google-apps.folder"}`))
+	}))
+	defer srv.Close()
+
+	f, err := NewClient(srv.Client()).WithBaseURL(srv.URL).CreateFolder(context.Background(), "laptop", nil, map[string]string{"k": "v"})
+	if err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+	if f.ID != "folder-1" || got["mimeType"] != FolderMimeType || got["parents"] != nil {
+		t.Fatalf("unexpected folder %#v from body %#v", f, got)
+	}
+	if props, _ := got["appProperties"].(map[string]any); props["k"] != "v" {
+		t.Fatalf("expected app properties sent, got %#v", got)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	var method, path string
+	var meta map[string]any
+	var content string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "multipart/related" || r.URL.Query().Get("uploadType") != "multipart" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		part, err := mr.NextPart()
+		if err != nil {
+			http.Error(w, "no metadata", http.StatusBadRequest)
+			return
+		}
+		meta = nil
+		_ = json.NewDecoder(part).Decode(&meta)
+		if part, err = mr.NextPart(); err != nil {
+			http.Error(w, "no content", http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(part)
+		content = string(data)
+		_, _ = w.Write([]byte(`{"id":"file-1","name":"a.txt","md5Checksum":"abc","size":"5"}`))
+	}))
+	defer srv.Close()
+	client := NewClient(srv.Client()).WithBaseURL(srv.URL)
+	ctx := context.Background()
+
+	f, err := client.Upload(ctx, "", FileMeta{Name: "a.txt", Parents: []string{"folder-1"}}, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if method != http.MethodPost || path != "/upload/files" || content != "hello" || meta["name"] != "a.txt" {
+		t.Fatalf("unexpected create %s %s with %#v and %q", method, path, meta, content)
+	}
+	if f.ID != "file-1" || f.Size != 5 {
+		t.Fatalf("unexpected file %#v", f)
+	}
+
+	// An update sends only the app properties with the new content.
+	if _, err := client.Upload(ctx, "file-1", FileMeta{Name: "ignored", AppProperties: map[string]string{"k": "v"}}, strings.NewReader("world")); err != nil {
+		t.Fatalf("Upload update: %v", err)
+	}
+	if method != http.MethodPatch || path != "/upload/files/file-1" || content != "world" || meta["name"] != nil {
+		t.Fatalf("unexpected update %s %s with %#v and %q", method, path, meta, content)
+	}
+	if props, _ := meta["appProperties"].(map[string]any); props["k"] != "v" {
+		t.Fatalf("expected app properties sent, got %#v", meta)
+	}
+	if _, err := client.Upload(ctx, "", FileMeta{}, strings.NewReader("x")); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected a nameless create refused, got %v", err)
+	}
+}
+
+func TestMove(t *testing.T) {
+	var method, path string
+	var query url.Values
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, query = r.Method, r.URL.Path, r.URL.Query()
+		body = nil
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"id":"file-1","name":"b.txt","parents":["folder-2"]}`))
+	}))
+	defer srv.Close()
+	client := NewClient(srv.Client()).WithBaseURL(srv.URL)
+	ctx := context.Background()
+
+	f, err := client.Move(ctx, "file-1", "b.txt", "folder-1", "folder-2")
+	if err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if method != http.MethodPatch || path != "/files/file-1" || body["name"] != "b.txt" {
+		t.Fatalf("unexpected request %s %s with %#v", method, path, body)
+	}
+	if query.Get("addParents") != "folder-2" || query.Get("removeParents") != "folder-1" {
+		t.Fatalf("expected the parents swapped, got %v", query)
+	}
+	if f.Name != "b.txt" || len(f.Parents) != 1 || f.Parents[0] != "folder-2" {
+		t.Fatalf("unexpected file %#v", f)
+	}
+
+	// A rename in place sends no parents.
+	if _, err := client.Move(ctx, "file-1", "c.txt", "folder-2", "folder-2"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if query.Has("addParents") || query.Has("removeParents") || body["name"] != "c.txt" {
+		t.Fatalf("expected only a rename, got %v with %#v", query, body)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	var method, path string
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"id":"file-2","name":"b.txt","md5Checksum":"abc"}`))
+	}))
+	defer srv.Close()
+
+	f, err := NewClient(srv.Client()).WithBaseURL(srv.URL).Copy(context.Background(), "file-1", FileMeta{Name: "b.txt", Parents: []string{"folder-1"}})
+	if err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if method != http.MethodPost || path != "/files/file-1/copy" || body["name"] != "b.txt" {
+		t.Fatalf("unexpected request %s %s with %#v", method, path, body)
+	}
+	if parents, _ := body["parents"].([]any); len(parents) != 1 || parents[0] != "folder-1" {
+		t.Fatalf("expected the parent sent, got %#v", body)
+	}
+	if f.ID != "file-2" || f.MD5Checksum != "abc" {
+		t.Fatalf("unexpected file %#v", f)
+	}
+}
+
+func TestRemoveParent(t *testing.T) {
+	var method, path string
+	var query url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, query = r.Method, r.URL.Path, r.URL.Query()
+		_, _ = w.Write([]byte(`{"id":"file-1","parents":["folder-1"]}`))
+	}))
+	defer srv.Close()
+
+	f, err := NewClient(srv.Client()).WithBaseURL(srv.URL).RemoveParent(context.Background(), "file-1", "folder-2")
+	if err != nil {
+		t.Fatalf("RemoveParent: %v", err)
+	}
+	if method != http.MethodPatch || path != "/files/file-1" || query.Get("removeParents") != "folder-2" || query.Has("addParents") {
+		t.Fatalf("unexpected request %s %s with %v", method, path, query)
+	}
+	if len(f.Parents) != 1 || f.Parents[0] != "folder-1" {
+		t.Fatalf("unexpected file %#v", f)
+	}
+}
+
+func TestCreateShortcut(t *testing.T) {
+	var method, path string
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"id":"shortcut-1","name":"latest","mimeType":"application/vnd.google-apps.shortcut"}`))
+	}))
+	defer srv.Close()
+
+	f, err := NewClient(srv.Client()).WithBaseURL(srv.URL).CreateShortcut(context.Background(), "latest", []string{"folder-1"}, "file-1")
+	if err != nil {
+		t.Fatalf("CreateShortcut: %v", err)
+	}
+	details, _ := body["shortcutDetails"].(map[string]any)
+	if method != http.MethodPost || path != "/files" || body["mimeType"] != ShortcutMimeType || details["targetId"] != "file-1" {
+		t.Fatalf("unexpected request %s %s with %#v", method, path, body)
+	}
+	if f.ID != "shortcut-1" {
+		t.Fatalf("unexpected file %#v", f)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	var method, path string
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"id":"file-1","trashed":true}`))
+	}))
+	defer srv.Close()
+
+	f, err := NewClient(srv.Client()).WithBaseURL(srv.URL).Trash(context.Background(), "file-1")
+	if err != nil {
+		t.Fatalf("Trash: %v", err)
+	}
+	if method != http.MethodPatch || path != "/files/file-1" || body["trashed"] != true {
+		t.Fatalf("unexpected request %s %s with %#v", method, path, body)
+	}
+	if !f.Trashed {
+		t.Fatalf("expected the file trashed, got %#v", f)
+	}
+}
+
+func TestListChildrenQuery(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("q")
+		_, _ = w.Write([]byte(`{"files":[{"id":"a","name":"a.txt","size":"3"}]}`))
+	}))
+	defer srv.Close()
+
+	files, err := NewClient(srv.Client()).WithBaseURL(srv.URL).ListChildren(context.Background(), "root")
+	if err != nil {
+		t.Fatalf("ListChildren: %v", err)
+	}
+	if query != "'root' in parents and trashed = false" || len(files) != 1 || files[0].Size != 3 {
+		t.Fatalf("unexpected query %q or files %#v", query, files)
+	}
+	if !IsGoogleNative("application/vnd.google-apps.document") || IsGoogleNative(FolderMimeType) {
+		t.Fatal("unexpected native type classification")
+	}
+}
+
+func TestListChanges(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/changes/startPageToken":
+			_, _ = w.Write([]byte(`{"startPageToken":"100"}`))
+		case r.URL.Query().Get("pageToken") == "100":
+			_, _ = w.Write([]byte(`{"nextPageToken":"101","changes":[{"fileId":"a","file":{"id":"a","name":"a.txt","parents":["root-id"]}}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"newStartPageToken":"102","changes":[{"fileId":"b","removed":true,"time":"2024-05-01T10:00:00.000Z"}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.Client()).WithBaseURL(srv.URL)
+	ctx := context.Background()
+	token, err := c.GetStartPageToken(ctx)
+	if err != nil || token != "100" {
+		t.Fatalf("GetStartPageToken: %q, %v", token, err)
+	}
+	page, err := c.ListChanges(ctx, token)
+	if err != nil {
+		t.Fatalf("ListChanges: %v", err)
+	}
+	if page.NextPageToken != "101" || len(page.Changes) != 1 || page.Changes[0].File == nil || page.Changes[0].File.Name != "a.txt" {
+		t.Fatalf("unexpected first page: %#v", page)
+	}
+	page, err = c.ListChanges(ctx, page.NextPageToken)
+	if err != nil {
+		t.Fatalf("ListChanges: %v", err)
+	}
+	if page.NewStartPageToken != "102" || len(page.Changes) != 1 || !page.Changes[0].Removed || page.Changes[0].File != nil ||
+		!page.Changes[0].Time.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected last page: %#v", page)
+	}
+	if _, err := c.ListChanges(ctx, ""); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected empty token rejected, got %v", err)
+	}
+}
+
+func TestWatchChanges(t *testing.T) {
+	var watched, stopped map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+		switch r.URL.Path {
+		case "/changes/watch":
+			if r.URL.Query().Get("pageToken") != "42" {
+				t.Fatalf("unexpected query %s", r.URL.RawQuery)
+			}
+			_ = json.NewDecoder(r.Body).Decode(&watched)
+			_, _ = w.Write([]byte(`{"id":"ch-1","resourceId":"res-1","token":"secret","address":"https://relay.example/hook","expiration":"1700000000000"}`))
+		case "/channels/stop":
+			_ = json.NewDecoder(r.Body).Decode(&stopped)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.Client()).WithBaseURL(srv.URL)
+	ctx := context.Background()
+	ch, err := c.WatchChanges(ctx, "42", Channel{ID: "ch-1", Token: "secret", Address: "https://relay.example/hook"})
+	if err != nil {
+		t.Fatalf("WatchChanges: %v", err)
+	}
+	if watched["type"] != "web_hook" || watched["token"] != "secret" || watched["expiration"] != nil {
+		t.Fatalf("unexpected watch body %#v", watched)
+	}
+	if ch.ResourceID != "res-1" || !ch.Expiration.Equal(time.UnixMilli(1700000000000)) {
+		t.Fatalf("unexpected channel %#v", ch)
+	}
+	if err := c.StopChannel(ctx, *ch); err != nil {
+		t.Fatalf("StopChannel: %v", err)
+	}
+	if stopped["id"] != "ch-1" || stopped["resourceId"] != "res-1" {
+		t.Fatalf("unexpected stop body %#v", stopped)
+	}
+	if _, err := c.WatchChanges(ctx, "42", Channel{ID: "ch-2"}); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected missing address rejected, got %v", err)
+	}
+}
+
+func TestIsExportable(t *testing.T) {
+	for mime, want := range map[string]bool{
+		"application/vnd.google-apps.document": true,
+		"application/vnd.google-apps.form":     false,
+		"application/vnd.google-apps.site":     false,
+		"application/vnd.google-apps.map":      false,
+		FolderMimeType:                         false,
+		"text/plain":                           false,
+	} {
+		if got := IsExportable(mime); got != want {
+			t.Fatalf("IsExportable(%q) = %v, want %v", mime, got, want)
+		}
+	}
+}
+
+func TestGetQuota(t *testing.T) {
+	var gotPath, gotFields string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotFields = r.URL.Path, r.URL.Query().Get("fields")
+		_, _ = w.Write([]byte(`{"storageQuota":{"limit":"100","usage":"100","usageInDrive":"80","usageInDriveTrash":"5"}}`))
+	}))
+	defer srv.Close()
+
+	q, err := NewClient(srv.Client()).WithBaseURL(srv.URL).GetQuota(context.Background())
+	if err != nil {
+		t.Fatalf("GetQuota: %v", err)
+	}
+	if gotPath != "/about" || gotFields != "storageQuota" {
+		t.Fatalf("unexpected request: %s fields=%s", gotPath, gotFields)
+	}
+	if *q != (Quota{Limit: 100, Usage: 100, UsageInDrive: 80, UsageInTrash: 5}) || !q.Full() {
+		t.Fatalf("unexpected quota: %#v", q)
+	}
+	if (Quota{Usage: 1 << 40}).Full() {
+		t.Fatal("expected unlimited quota never full")
+	}
+}
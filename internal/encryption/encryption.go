@@ -0,0 +1,316 @@
+// Package encryption seals file content and names with a per-account key
+// before they are sent to Drive.
+//
+// Content is split into fixed-size chunks, each sealed with AES-256-GCM under
+// a key derived for the file from the account key and a random salt. A chunk's
+// nonce carries its index and whether it is the last one, so chunks cannot be
+// reordered, dropped or truncated without failing authentication. The
+// encrypted form starts with Magic, so it can be recognized without a key.
+//
+// Names are sealed deterministically, so the same name always maps to the
+// same Drive name and lookups by name keep working.
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+const (
+	// KeySize is the length of an account key in bytes.
+	KeySize = 32
+	// ChunkSize is the plaintext length of every chunk but the last.
+	ChunkSize = 64 << 10
+	// HeaderSize is the length of Magic plus the per-file salt.
+	HeaderSize = len(Magic) + saltSize
+
+	saltSize  = 32
+	tagSize   = 16
+	nonceSize = 12
+)
+
+// Magic opens every encrypted file.
+const Magic = "GSYNCE1\x00"
+
+// IsEncrypted reports whether header, the first bytes of a file, starts with
+// Magic.
+func IsEncrypted(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(Magic))
+}
+
+// NewKey returns a random account key.
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// CipherSize returns the encrypted length of plain bytes of content.
+func CipherSize(plain int64) int64 {
+	// Empty content still has one, empty, final chunk.
+	chunks := max((plain+ChunkSize-1)/ChunkSize, 1)
+	return int64(HeaderSize) + plain + chunks*tagSize
+}
+
+// PlainSize returns the content length of an encrypted file of cipher bytes.
+func PlainSize(cipherLen int64) (int64, error) {
+	body := cipherLen - int64(HeaderSize)
+	if body < tagSize {
+		return 0, errs.New(errs.ErrInvalidArgument, "encrypted size %d is too short", cipherLen)
+	}
+	full := body / (ChunkSize + tagSize)
+	last := body % (ChunkSize + tagSize)
+	if last == 0 {
+		// The final chunk is a full one.
+		return full * ChunkSize, nil
+	}
+	if last < tagSize {
+		return 0, errs.New(errs.ErrInvalidArgument, "encrypted size %d is not a valid length", cipherLen)
+	}
+	return full*ChunkSize + last - tagSize, nil
+}
+
+// subKey derives a key for one purpose from the account key.
+func subKey(key []byte, label string, salt []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(label))
+	mac.Write(salt)
+	return mac.Sum(nil)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func checkKey(key []byte) error {
+	if len(key) != KeySize {
+		return errs.New(errs.ErrInvalidArgument, "encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return nil
+}
+
+// chunkNonce encodes a chunk's index and whether it is the last.
+func chunkNonce(index uint64, final bool) []byte {
+	nonce := make([]byte, nonceSize)
+	binary.BigEndian.PutUint64(nonce, index)
+	if final {
+		nonce[nonceSize-1] = 1
+	}
+	return nonce
+}
+
+// errClosed is returned by writes to a closed Encrypter.
+var errClosed = errs.New(errs.ErrInvalidArgument, "encrypter is closed")
+
+// Encrypter seals content written to it. Close must be called to write the
+// final chunk; it does not close the underlying writer.
+type Encrypter struct {
+	dst   io.Writer
+	aead  cipher.AEAD
+	buf   []byte
+	index uint64
+	err   error
+}
+
+// NewEncrypter writes the header to dst and returns a writer that encrypts
+// everything written to it under key.
+func NewEncrypter(dst io.Writer, key []byte) (*Encrypter, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(subKey(key, "content", salt))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(dst, Magic); err != nil {
+		return nil, err
+	}
+	if _, err := dst.Write(salt); err != nil {
+		return nil, err
+	}
+	return &Encrypter{dst: dst, aead: aead, buf: make([]byte, 0, ChunkSize+tagSize)}, nil
+}
+
+// Write buffers p and writes out every chunk it completes.
+func (w *Encrypter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := 0
+	for len(p) > 0 {
+		// A full buffer is only sealed once more data arrives, so the last
+		// chunk can be marked final on Close.
+		if len(w.buf) == ChunkSize {
+			if w.err = w.seal(false); w.err != nil {
+				return n, w.err
+			}
+		}
+		take := min(ChunkSize-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:take]...)
+		p = p[take:]
+		n += take
+	}
+	return n, nil
+}
+
+// Close seals and writes the final chunk.
+func (w *Encrypter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.seal(true); err != nil {
+		w.err = err
+		return err
+	}
+	w.err = errClosed
+	return nil
+}
+
+func (w *Encrypter) seal(final bool) error {
+	sealed := w.aead.Seal(w.buf[:0], chunkNonce(w.index, final), w.buf, nil)
+	w.index++
+	_, err := w.dst.Write(sealed)
+	w.buf = w.buf[:0]
+	return err
+}
+
+// Decrypter opens content sealed by an Encrypter. Read returns an error
+// wrapping errs.ErrInvalidArgument if the content was altered or cut short.
+type Decrypter struct {
+	src   io.Reader
+	aead  cipher.AEAD
+	chunk []byte
+	plain []byte
+	index uint64
+	done  bool
+}
+
+// NewDecrypter reads the header from src and returns a reader of the
+// decrypted content.
+func NewDecrypter(src io.Reader, key []byte) (*Decrypter, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	header := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(src, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errs.New(errs.ErrInvalidArgument, "content is not encrypted")
+		}
+		return nil, err
+	}
+	if !IsEncrypted(header) {
+		return nil, errs.New(errs.ErrInvalidArgument, "content is not encrypted")
+	}
+	aead, err := newAEAD(subKey(key, "content", header[len(Magic):]))
+	if err != nil {
+		return nil, err
+	}
+	// One spare byte tells a full final chunk from one with more to follow.
+	return &Decrypter{src: src, aead: aead, chunk: make([]byte, ChunkSize+tagSize+1)}, nil
+}
+
+// Read returns decrypted content.
+func (r *Decrypter) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+func (r *Decrypter) next() error {
+	// The spare byte read ahead for the previous chunk, if any, starts this
+	// one.
+	have := 0
+	if r.index > 0 {
+		have = 1
+	}
+	n, err := io.ReadFull(r.src, r.chunk[have:])
+	n += have
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		r.done = true
+	case err != nil:
+		return err
+	}
+	size := n
+	if !r.done {
+		size = ChunkSize + tagSize
+	}
+	if size < tagSize {
+		return errs.New(errs.ErrInvalidArgument, "encrypted content is truncated")
+	}
+	plain, err := r.aead.Open(nil, chunkNonce(r.index, r.done), r.chunk[:size], nil)
+	if err != nil {
+		return errs.New(errs.ErrInvalidArgument, "encrypted content failed authentication at chunk %d", r.index)
+	}
+	r.plain = plain
+	r.index++
+	if !r.done {
+		r.chunk[0] = r.chunk[size]
+	}
+	return nil
+}
+
+// EncryptName seals a Drive name. The result is URL-safe base64 and is the
+// same every time for the same key and name.
+func EncryptName(key []byte, name string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(subKey(key, "name", nil))
+	if err != nil {
+		return "", err
+	}
+	// The nonce is a MAC of the name itself, which keeps the result stable
+	// without reusing a nonce for different names.
+	nonce := subKey(key, "name-nonce", []byte(name))[:nonceSize]
+	sealed := aead.Seal(append([]byte(nil), nonce...), nonce, []byte(name), nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptName opens a name sealed by EncryptName. Names that were not sealed
+// under key return an error wrapping errs.ErrInvalidArgument.
+func DecryptName(key []byte, sealed string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < nonceSize+tagSize {
+		return "", errs.New(errs.ErrInvalidArgument, "%q is not an encrypted name", sealed)
+	}
+	aead, err := newAEAD(subKey(key, "name", nil))
+	if err != nil {
+		return "", err
+	}
+	name, err := aead.Open(nil, raw[:nonceSize], raw[nonceSize:], nil)
+	if err != nil {
+		return "", errs.New(errs.ErrInvalidArgument, "%q is not an encrypted name", sealed)
+	}
+	return string(name), nil
+}
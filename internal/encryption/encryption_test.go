@@ -0,0 +1,151 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+func testKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := NewKey()
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+	return key
+}
+
+func seal(t *testing.T, key, plain []byte) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := NewEncrypter(&out, key)
+	if err != nil {
+		t.Fatalf("NewEncrypter: %v", err)
+	}
+	// Odd-sized writes cross chunk boundaries.
+	for len(plain) > 0 {
+		n := min(len(plain), 10_000)
+		if _, err := w.Write(plain[:n]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		plain = plain[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return out.Bytes()
+}
+
+func TestContentRoundTrip(t *testing.T) {
+	key := testKey(t)
+	for _, size := range []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3*ChunkSize + 17} {
+		plain := make([]byte, size)
+		_, _ = rand.Read(plain)
+		sealed := seal(t, key, plain)
+		if !IsEncrypted(sealed) {
+			t.Fatalf("size %d: expected magic header", size)
+		}
+		if int64(len(sealed)) != CipherSize(int64(size)) {
+			t.Fatalf("size %d: expected cipher size %d, got %d", size, CipherSize(int64(size)), len(sealed))
+		}
+		if got, err := PlainSize(int64(len(sealed))); err != nil || got != int64(size) {
+			t.Fatalf("size %d: PlainSize returned %d, %v", size, got, err)
+		}
+		r, err := NewDecrypter(bytes.NewReader(sealed), key)
+		if err != nil {
+			t.Fatalf("NewDecrypter: %v", err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("size %d: ReadAll: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("size %d: content differs after round trip", size)
+		}
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	key := testKey(t)
+	plain := bytes.Repeat([]byte("x"), 2*ChunkSize+5)
+	sealed := seal(t, key, plain)
+
+	cases := map[string][]byte{
+		"flipped":   append(append([]byte(nil), sealed[:HeaderSize+3]...), append([]byte{sealed[HeaderSize+3] ^ 1}, sealed[HeaderSize+4:]...)...),
+		"truncated": sealed[:HeaderSize+ChunkSize+tagSize],
+		"appended":  append(append([]byte(nil), sealed...), 0),
+	}
+	for name, data := range cases {
+		r, err := NewDecrypter(bytes.NewReader(data), key)
+		if err != nil {
+			t.Fatalf("%s: NewDecrypter: %v", name, err)
+		}
+		if _, err := io.ReadAll(r); errs.KindOf(err) != errs.ErrInvalidArgument {
+			t.Fatalf("%s: expected authentication failure, got %v", name, err)
+		}
+	}
+	r, err := NewDecrypter(bytes.NewReader(sealed), testKey(t))
+	if err != nil {
+		t.Fatalf("NewDecrypter: %v", err)
+	}
+	if _, err := io.ReadAll(r); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected wrong key to fail, got %v", err)
+	}
+	if _, err := NewDecrypter(bytes.NewReader([]byte("plain text")), key); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected plain content to be rejected, got %v", err)
+	}
+}
+
+func TestNameRoundTrip(t *testing.T) {
+	key := testKey(t)
+	sealed, err := EncryptName(key, "tax return 2024.pdf")
+	if err != nil {
+		t.Fatalf("EncryptName: %v", err)
+	}
+	again, _ := EncryptName(key, "tax return 2024.pdf")
+	if sealed != again {
+		t.Fatalf("expected deterministic names, got %q and %q", sealed, again)
+	}
+	if other, _ := EncryptName(key, "tax return 2025.pdf"); other == sealed {
+		t.Fatal("expected different names to seal differently")
+	}
+	if got, err := DecryptName(key, sealed); err != nil || got != "tax return 2024.pdf" {
+		t.Fatalf("DecryptName: %q %v", got, err)
+	}
+	if _, err := DecryptName(testKey(t), sealed); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected wrong key to fail, got %v", err)
+	}
+	if _, err := DecryptName(key, "notes.txt"); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected plain name to fail, got %v", err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	keyring.MockInit()
+	keys := NewKeys(&config.Config{})
+	if _, err := keys.Key("acct"); errs.KindOf(err) != errs.ErrNotFound {
+		t.Fatalf("expected missing key, got %v", err)
+	}
+	key := testKey(t)
+	if err := keys.Set("acct", key); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	// A fresh store reads the key back from the keyring.
+	got, err := NewKeys(&config.Config{}).Key("acct")
+	if err != nil || !bytes.Equal(got, key) {
+		t.Fatalf("Key: %v", err)
+	}
+	decoded, err := DecodeKey(EncodeKey(key) + "\n")
+	if err != nil || !bytes.Equal(decoded, key) {
+		t.Fatalf("DecodeKey: %v", err)
+	}
+	if _, err := DecodeKey("c2hvcnQ="); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected short key rejected, got %v", err)
+	}
+}
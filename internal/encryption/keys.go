@@ -0,0 +1,122 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	gosync "sync"
+
+	"github.com/zalando/go-keyring"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Keys keeps account keys, and the database key, in the OS keyring next to
+// the refresh tokens. Keys are read once and then cached by keyring entry.
+type Keys struct {
+	service string
+
+	mu    gosync.Mutex
+	cache map[string][]byte
+}
+
+// NewKeys returns the key store for the app's keyring service.
+func NewKeys(cfg *config.Config) *Keys {
+	service := "googlysync"
+	if cfg != nil && cfg.AppName != "" {
+		service = cfg.AppName
+	}
+	return &Keys{service: service, cache: make(map[string][]byte)}
+}
+
+func keyringUser(accountID string) string {
+	return "encryption-key:" + accountID
+}
+
+// databaseKeyUser is the keyring entry of the metadata database key.
+const databaseKeyUser = "database-key"
+
+// Key returns the account's key. A missing key is an error wrapping
+// errs.ErrNotFound: keys are never created implicitly, since content sealed
+// under a key another machine does not have could not be read there.
+func (k *Keys) Key(accountID string) ([]byte, error) {
+	key, err := k.get(keyringUser(accountID))
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, errs.New(errs.ErrNotFound, "no encryption key for account %q; create or import one with googlysync encryption", accountID)
+	}
+	return key, err
+}
+
+// Set stores key for the account, replacing any existing one.
+func (k *Keys) Set(accountID string, key []byte) error {
+	return k.set(keyringUser(accountID), key)
+}
+
+// DatabaseKey returns the key the metadata database is encrypted with. A
+// missing key is an error wrapping errs.ErrNotFound.
+func (k *Keys) DatabaseKey() ([]byte, error) {
+	key, err := k.get(databaseKeyUser)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, errs.New(errs.ErrNotFound, "no database key in the keyring")
+	}
+	return key, err
+}
+
+// NewDatabaseKey creates a database key and stores it in the keyring,
+// replacing any existing one.
+func (k *Keys) NewDatabaseKey() ([]byte, error) {
+	key, err := NewKey()
+	if err != nil {
+		return nil, err
+	}
+	return key, k.set(databaseKeyUser, key)
+}
+
+func (k *Keys) get(user string) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if key, ok := k.cache[user]; ok {
+		return key, nil
+	}
+	encoded, err := keyring.Get(k.service, user)
+	if err != nil {
+		return nil, err
+	}
+	key, err := DecodeKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	k.cache[user] = key
+	return key, nil
+}
+
+func (k *Keys) set(user string, key []byte) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := keyring.Set(k.service, user, EncodeKey(key)); err != nil {
+		return err
+	}
+	k.cache[user] = append([]byte(nil), key...)
+	return nil
+}
+
+// EncodeKey returns the text form of a key used for export and storage.
+func EncodeKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// DecodeKey parses a key written by EncodeKey.
+func DecodeKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, errs.New(errs.ErrInvalidArgument, "encryption key is not valid base64")
+	}
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,60 @@
+// Package errs defines the error kinds shared across packages so callers can
+// branch with errors.Is instead of matching message strings.
+package errs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds. Match them with errors.Is.
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrQuotaExceeded   = errors.New("quota exceeded")
+	ErrAuthExpired     = errors.New("authorization expired")
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrScopeMissing is a request the account's sign-in has no scope for.
+	ErrScopeMissing = errors.New("scope not granted")
+)
+
+var kinds = []error{ErrNotFound, ErrConflict, ErrQuotaExceeded, ErrAuthExpired, ErrInvalidArgument, ErrScopeMissing}
+
+// Error is an error tagged with one of the kinds above. Its message is the
+// wrapped error's message alone, so tagging does not change what users see.
+type Error struct {
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap exposes both the kind and the underlying cause to errors.Is/As.
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// New formats a message like fmt.Errorf, including %w, and tags it with kind.
+func New(kind error, format string, args ...any) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// Wrap tags err with kind. It returns nil for a nil err.
+func Wrap(kind error, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// KindOf returns the kind err was tagged with, or nil when it has none.
+func KindOf(err error) error {
+	for _, kind := range kinds {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	return nil
+}
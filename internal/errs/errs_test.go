@@ -0,0 +1,36 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestNewKeepsMessageAndKind(t *testing.T) {
+	err := New(ErrNotFound, "version %d not found", 7)
+	if err.Error() != "version 7 not found" {
+		t.Fatalf("unexpected message: %q", err.Error())
+	}
+	wrapped := fmt.Errorf("restore: %w", err)
+	if !errors.Is(wrapped, ErrNotFound) || errors.Is(wrapped, ErrConflict) {
+		t.Fatalf("kind not matched through wrapping: %v", wrapped)
+	}
+	var tagged *Error
+	if !errors.As(wrapped, &tagged) || tagged.Kind != ErrNotFound {
+		t.Fatalf("expected *Error via errors.As, got %#v", tagged)
+	}
+	if KindOf(wrapped) != ErrNotFound || KindOf(io.EOF) != nil {
+		t.Fatalf("unexpected KindOf results")
+	}
+}
+
+func TestWrapKeepsCause(t *testing.T) {
+	err := Wrap(ErrAuthExpired, io.ErrUnexpectedEOF)
+	if !errors.Is(err, io.ErrUnexpectedEOF) || !errors.Is(err, ErrAuthExpired) {
+		t.Fatalf("expected both cause and kind: %v", err)
+	}
+	if Wrap(ErrConflict, nil) != nil {
+		t.Fatal("expected nil for nil error")
+	}
+}
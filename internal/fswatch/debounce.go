@@ -0,0 +1,97 @@
+package fswatch
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultDebounce and defaultMaxDebounce apply when the config leaves
+	// them unset.
+	defaultDebounce    = 300 * time.Millisecond
+	defaultMaxDebounce = 10 * time.Second
+
+	// smallFileSize is the size below which a file with no recent churn is
+	// flushed on the next tick instead of waiting out the debounce.
+	smallFileSize = 1 << 20
+	// largeFileStep adds one base debounce per step of file size, so large
+	// files being written out by an export are not uploaded half-finished.
+	largeFileStep = 64 << 20
+	// churnWindow is how long a path's previous events count towards its
+	// churn. Each repeated event within the window doubles the delay.
+	churnWindow   = 10 * time.Second
+	maxChurnShift = 6
+)
+
+// debouncer picks a per-path delay before an event is emitted.
+type debouncer struct {
+	base time.Duration
+	max  time.Duration
+
+	mu    sync.Mutex
+	churn map[string]churnState
+}
+
+type churnState struct {
+	hits int
+	last time.Time
+}
+
+func newDebouncer(base, ceiling time.Duration) *debouncer {
+	if base <= 0 {
+		base = defaultDebounce
+	}
+	if ceiling <= 0 {
+		ceiling = defaultMaxDebounce
+	}
+	return &debouncer{base: base, max: max(base, ceiling), churn: make(map[string]churnState)}
+}
+
+// delay records an event for path and returns how long to hold it. size is
+// the file size, or a negative value when unknown (e.g. the path is gone).
+func (d *debouncer) delay(path string, op Op, size int64, now time.Time) time.Duration {
+	d.mu.Lock()
+	state := d.churn[path]
+	if now.Sub(state.last) > churnWindow {
+		state.hits = 0
+	}
+	state.hits++
+	state.last = now
+	d.churn[path] = state
+	d.mu.Unlock()
+
+	if op == OpRemove || size < 0 {
+		return d.base
+	}
+	if state.hits == 1 && size < smallFileSize {
+		return 0
+	}
+	wait := d.base + time.Duration(size/largeFileStep)*d.base
+	wait <<= min(state.hits-1, maxChurnShift)
+	return min(wait, d.max)
+}
+
+// prune forgets paths with no events inside the churn window.
+func (d *debouncer) prune(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for path, state := range d.churn {
+		if now.Sub(state.last) > churnWindow {
+			delete(d.churn, path)
+		}
+	}
+}
+
+// statSize returns the size of a regular file, zero for other entries, or -1
+// when the path cannot be read.
+func statSize(path string) int64 {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return -1
+	}
+	if !info.Mode().IsRegular() {
+		return 0
+	}
+	return info.Size()
+}
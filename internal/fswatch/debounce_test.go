@@ -0,0 +1,53 @@
+package fswatch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDebounceDelay(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	base := 300 * time.Millisecond
+
+	cases := []struct {
+		name string
+		op   Op
+		size int64
+		want time.Duration
+	}{
+		{"small stable file", OpCreate, 10 << 10, 0},
+		{"remove", OpRemove, -1, base},
+		{"unreadable", OpWrite, -1, base},
+		{"large file", OpWrite, 256 << 20, 5 * base},
+		{"huge file capped", OpWrite, 64 << 30, 10 * time.Second},
+	}
+	for _, tc := range cases {
+		d := newDebouncer(base, 10*time.Second)
+		if got := d.delay("/root/"+tc.name, tc.op, tc.size, now); got != tc.want {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestDebounceChurnBacksOff(t *testing.T) {
+	d := newDebouncer(100*time.Millisecond, 2*time.Second)
+	now := time.Unix(1_700_000_000, 0)
+	path := "/root/build/out.o"
+
+	want := []time.Duration{0, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, 1600 * time.Millisecond, 2 * time.Second}
+	for i, w := range want {
+		if got := d.delay(path, OpWrite, 512, now.Add(time.Duration(i)*time.Second)); got != w {
+			t.Fatalf("event %d: expected %v, got %v", i, w, got)
+		}
+	}
+
+	// After a quiet window the path counts as stable again.
+	later := now.Add(time.Minute)
+	d.prune(later)
+	if len(d.churn) != 0 {
+		t.Fatalf("expected churn pruned, got %#v", d.churn)
+	}
+	if got := d.delay(path, OpWrite, 512, later); got != 0 {
+		t.Fatalf("expected immediate flush after quiet period, got %v", got)
+	}
+}
@@ -18,7 +18,20 @@ func OpString(op Op) string {
 	}
 }
 
-func mergeOp(current, next Op) Op {
+// mergeOp coalesces an event for a path with the one still pending for it.
+// ok is false when the two cancel out: a file created and gone again before
+// its delay passed never needs syncing. A file removed or renamed away and
+// then created again at the same path was replaced, which is a write.
+// Otherwise the more significant op wins.
+func mergeOp(current, next Op) (op Op, ok bool) {
+	switch {
+	case current == OpCreate && (next == OpRemove || next == OpRename):
+		return OpUnknown, false
+	case current == OpCreate:
+		return OpCreate, true
+	case (current == OpRemove || current == OpRename) && next == OpCreate:
+		return OpWrite, true
+	}
 	priority := map[Op]int{
 		OpRemove:  5,
 		OpRename:  4,
@@ -28,7 +41,7 @@ func mergeOp(current, next Op) Op {
 		OpUnknown: 0,
 	}
 	if priority[next] >= priority[current] {
-		return next
+		return next, true
 	}
-	return current
+	return current, true
 }
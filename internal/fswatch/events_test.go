@@ -0,0 +1,97 @@
+package fswatch
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/status"
+)
+
+func TestMergeOp(t *testing.T) {
+	cases := []struct {
+		current, next Op
+		want          Op
+		keep          bool
+	}{
+		{OpCreate, OpWrite, OpCreate, true},
+		{OpCreate, OpChmod, OpCreate, true},
+		{OpCreate, OpRemove, OpUnknown, false},
+		{OpCreate, OpRename, OpUnknown, false},
+		{OpWrite, OpWrite, OpWrite, true},
+		{OpWrite, OpChmod, OpWrite, true},
+		{OpWrite, OpRemove, OpRemove, true},
+		{OpWrite, OpRename, OpRename, true},
+		{OpChmod, OpWrite, OpWrite, true},
+		{OpRemove, OpCreate, OpWrite, true},
+		{OpRename, OpCreate, OpWrite, true},
+		{OpRemove, OpChmod, OpRemove, true},
+	}
+	for _, tc := range cases {
+		got, keep := mergeOp(tc.current, tc.next)
+		if got != tc.want || keep != tc.keep {
+			t.Errorf("%s then %s: got %s, %v; want %s, %v", OpString(tc.current), OpString(tc.next), OpString(got), keep, OpString(tc.want), tc.keep)
+		}
+	}
+}
+
+func TestEnqueueCoalescesPendingEvents(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcher(zap.NewNop(), &config.Config{SyncRoot: root, WatchMode: ModePoll}, status.NewStore())
+	if err != nil {
+		t.Fatalf("NewWatcher: %v", err)
+	}
+	scratch := filepath.Join(root, "scratch.txt")
+	saved := filepath.Join(root, "saved.txt")
+	edited := filepath.Join(root, "edited.txt")
+
+	// A file created and removed within the delay never leaves the watcher.
+	w.enqueue(scratch, OpCreate)
+	w.enqueue(scratch, OpWrite)
+	w.enqueue(scratch, OpRemove)
+	// An editor saving by replacing the file is one write.
+	w.enqueue(saved, OpRename)
+	w.enqueue(saved, OpCreate)
+	w.enqueue(saved, OpWrite)
+	// A file written and then removed is a removal.
+	w.enqueue(edited, OpWrite)
+	w.enqueue(edited, OpRemove)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	want := map[string]Op{saved: OpWrite, edited: OpRemove}
+	if len(w.pending) != len(want) {
+		t.Fatalf("expected %d pending events, got %#v", len(want), w.pending)
+	}
+	for path, op := range want {
+		if got := w.pending[path].Op; got != op {
+			t.Fatalf("%s: expected %s, got %s", path, OpString(op), OpString(got))
+		}
+	}
+}
+
+func TestRemovedIgnoredFolderIsDropped(t *testing.T) {
+	root := t.TempDir()
+	cfg := &config.Config{SyncRoot: root, WatchMode: ModePoll, IgnorePatterns: []string{"build/"}}
+	w, err := NewWatcher(zap.NewNop(), cfg, status.NewStore())
+	if err != nil {
+		t.Fatalf("NewWatcher: %v", err)
+	}
+	build := filepath.Join(root, "build")
+	src := filepath.Join(root, "src")
+
+	// Neither path exists any more, so the watcher cannot tell they were
+	// folders.
+	w.handleEvent(fsnotify.Event{Name: build, Op: fsnotify.Remove})
+	w.handleEvent(fsnotify.Event{Name: filepath.Join(build, "out.o"), Op: fsnotify.Remove})
+	w.handleEvent(fsnotify.Event{Name: src, Op: fsnotify.Rename})
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) != 1 || w.pending[src].Op != OpRename {
+		t.Fatalf("expected only the rename of src pending, got %#v", w.pending)
+	}
+}
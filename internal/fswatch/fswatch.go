@@ -2,6 +2,7 @@ package fswatch
 
 import (
 	"context"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sync"
@@ -11,7 +12,9 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
 	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/transfer"
 )
 
 // Op describes a normalized filesystem operation.
@@ -26,11 +29,20 @@ const (
 	OpChmod
 )
 
+// Watch modes select how local changes are detected.
+const (
+	ModeFsnotify = "fsnotify"
+	ModePoll     = "poll"
+)
+
 // Event is a normalized file event.
 type Event struct {
 	Path string
 	Op   Op
 	When time.Time
+	// Root is the watched root Path lies in: the sync root or one of
+	// watch_roots.
+	Root string
 }
 
 // Watcher observes local filesystem changes.
@@ -38,32 +50,74 @@ type Watcher struct {
 	logger *zap.Logger
 	cfg    *config.Config
 	status *status.Store
+	// roots is the sync root followed by the configured watch_roots.
+	roots []root
 
 	watcher *fsnotify.Watcher
-	out     chan Event
+	// addWatch adds a watch to watcher; tests replace it.
+	addWatch func(path string) error
+	out      chan Event
 
 	mu      sync.Mutex
 	pending map[string]Event
 
-	debounce time.Duration
+	debounce *debouncer
+
+	// Polling mode replaces fsnotify with periodic scans of the sync root.
+	mode         string
+	pollInterval time.Duration
+	snapshot     map[string]fileState
+
+	// polled holds the folders that could not be watched once inotify ran
+	// out of watches; their subtrees are rescanned every poll interval and
+	// polledSnap is what the last scan saw.
+	polled     map[string]bool
+	polledSnap map[string]fileState
+
+	symlinks SymlinkPolicy
 }
 
-// NewWatcher constructs a filesystem watcher.
+// NewWatcher constructs a filesystem watcher. In poll mode no fsnotify
+// watches are created and the root is rescanned every poll interval.
 func NewWatcher(logger *zap.Logger, cfg *config.Config, statusStore *status.Store) (*Watcher, error) {
-	w, err := fsnotify.NewWatcher()
+	mode := cfg.WatchMode
+	if mode == "" {
+		mode = ModeFsnotify
+	}
+	if mode != ModeFsnotify && mode != ModePoll {
+		return nil, errs.New(errs.ErrInvalidArgument, "unknown watch mode %q (want %s or %s)", mode, ModeFsnotify, ModePoll)
+	}
+	symlinks, err := ParseSymlinkPolicy(cfg.SymlinkPolicy)
+	if err != nil {
+		return nil, err
+	}
+	roots, err := watchRoots(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Watcher{
-		logger:   logger,
-		cfg:      cfg,
-		status:   statusStore,
-		watcher:  w,
-		out:      make(chan Event, 256),
-		pending:  make(map[string]Event),
-		debounce: 300 * time.Millisecond,
-	}, nil
+	w := &Watcher{
+		logger:       logger,
+		cfg:          cfg,
+		status:       statusStore,
+		roots:        roots,
+		out:          make(chan Event, 256),
+		pending:      make(map[string]Event),
+		debounce:     newDebouncer(time.Duration(cfg.DebounceMs)*time.Millisecond, time.Duration(cfg.DebounceMaxMs)*time.Millisecond),
+		mode:         mode,
+		pollInterval: time.Duration(cfg.PollIntervalSec) * time.Second,
+		polled:       make(map[string]bool),
+		symlinks:     symlinks,
+	}
+	if mode == ModeFsnotify {
+		fw, err := fsnotify.NewWatcher()
+		if err != nil {
+			return nil, err
+		}
+		w.watcher = fw
+		w.addWatch = fw.Add
+	}
+	return w, nil
 }
 
 // Events returns the channel of normalized events.
@@ -71,20 +125,40 @@ func (w *Watcher) Events() <-chan Event {
 	return w.out
 }
 
-// Start begins watching and processing events.
+// Start begins watching and processing events. The sync root is created if
+// missing; a watch root that does not exist is skipped with a warning.
 func (w *Watcher) Start(ctx context.Context) error {
-	if w.cfg.SyncRoot == "" {
+	if len(w.roots) == 0 {
 		return nil
 	}
 
-	if err := os.MkdirAll(w.cfg.SyncRoot, 0o700); err != nil {
-		return err
+	if w.cfg.SyncRoot != "" {
+		if err := os.MkdirAll(w.cfg.SyncRoot, 0o700); err != nil {
+			return err
+		}
+	}
+	roots := w.roots[:0]
+	for _, r := range w.roots {
+		if info, err := os.Stat(r.path); err != nil || !info.IsDir() {
+			w.logger.Warn("watch root not watched", zap.String("root", r.path), zap.Error(err))
+			continue
+		}
+		roots = append(roots, r)
+	}
+	w.roots = roots
+	if w.mode == ModePoll {
+		return w.startPolling(ctx)
 	}
-	if err := w.addRecursive(w.cfg.SyncRoot); err != nil {
-		return err
+	for _, r := range w.roots {
+		if err := w.addRecursive(r.path); err != nil {
+			return err
+		}
 	}
 
-	w.status.Update(status.Snapshot{State: status.StateIdle, Message: "watching"})
+	if len(w.polled) == 0 {
+		w.status.Update(status.Snapshot{State: status.StateIdle, Message: "watching"})
+	}
+	w.pollDegraded(false)
 
 	go w.run(ctx)
 	return nil
@@ -101,6 +175,8 @@ func (w *Watcher) Close() error {
 func (w *Watcher) run(ctx context.Context) {
 	ticker := time.NewTicker(200 * time.Millisecond)
 	defer ticker.Stop()
+	poll := time.NewTicker(w.pollEvery())
+	defer poll.Stop()
 
 	for {
 		select {
@@ -119,17 +195,26 @@ func (w *Watcher) run(ctx context.Context) {
 			w.status.Update(status.Snapshot{State: status.StateError, Message: "fswatch error"})
 		case <-ticker.C:
 			w.flushPending()
+		case <-poll.C:
+			if len(w.polled) > 0 {
+				w.pollDegraded(true)
+			}
 		}
 	}
 }
 
 func (w *Watcher) handleEvent(evt fsnotify.Event) {
 	path := evt.Name
-	if w.shouldIgnore(path) {
+	info, err := os.Lstat(path)
+	isDir := err == nil && info.IsDir()
+	// A removed or renamed path can no longer be inspected, so it is ignored
+	// if it would be as either a file or a folder.
+	ignored := w.shouldIgnore(path, isDir) || (err != nil && w.shouldIgnore(path, true))
+	if ignored || w.isSkippedLink(path) {
 		return
 	}
 
-	if evt.Op&fsnotify.Create == fsnotify.Create {
+	if evt.Op&fsnotify.Create == fsnotify.Create && err == nil && (isDir || w.symlinks == SymlinkFollow) {
 		if info, err := os.Stat(path); err == nil && info.IsDir() {
 			_ = w.addRecursive(path)
 		}
@@ -139,13 +224,27 @@ func (w *Watcher) handleEvent(evt fsnotify.Event) {
 	if op == OpUnknown {
 		return
 	}
+	w.enqueue(path, op)
+}
 
+// enqueue holds an event for an adaptive delay. Small files seen for the
+// first time flush on the next tick; large or rapidly rewritten files wait
+// longer so a half-written file is not picked up. Every new event for a path
+// restarts its wait and is coalesced with the pending one by mergeOp.
+func (w *Watcher) enqueue(path string, op Op) {
+	now := time.Now()
+	wait := w.debounce.delay(path, op, statSize(path), now)
 	w.mu.Lock()
+	defer w.mu.Unlock()
 	if existing, ok := w.pending[path]; ok {
-		op = mergeOp(existing.Op, op)
+		merged, keep := mergeOp(existing.Op, op)
+		if !keep {
+			delete(w.pending, path)
+			return
+		}
+		op = merged
 	}
-	w.pending[path] = Event{Path: path, Op: op, When: time.Now().Add(w.debounce)}
-	w.mu.Unlock()
+	w.pending[path] = Event{Path: path, Op: op, When: now.Add(wait)}
 }
 
 func (w *Watcher) flushPending() {
@@ -160,38 +259,53 @@ func (w *Watcher) flushPending() {
 		}
 	}
 	w.mu.Unlock()
-
-	for _, evt := range ready {
-		w.status.AddEvent(status.Event{Op: OpString(evt.Op), Path: pathRel(evt.Path, w.cfg.SyncRoot), When: evt.When})
+	w.debounce.prune(now)
+
+	for i := range ready {
+		evt := &ready[i]
+		rel, label := evt.Path, ""
+		if r := w.rootOf(evt.Path); r != nil {
+			evt.Root = r.path
+			rel = pathRel(evt.Path, r.path)
+			if r.extra {
+				label = r.path
+			}
+		}
+		w.status.AddEvent(status.Event{Op: OpString(evt.Op), Path: rel, Root: label, When: evt.When})
 		select {
-		case w.out <- evt:
+		case w.out <- *evt:
 		default:
 			w.logger.Warn("fswatch event dropped", zap.String("path", evt.Path))
 		}
 	}
 }
 
+// addRecursive watches root and the folders below it. Subtrees that hit the
+// inotify watch limit are polled instead of failing the watcher.
 func (w *Watcher) addRecursive(root string) error {
-	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if w.shouldIgnore(path) {
-			if d.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		if d.IsDir() {
-			if err := w.watcher.Add(path); err != nil {
-				return err
-			}
+	return w.walkTree(root, func(path string, info fs.FileInfo) error {
+		if info.IsDir() {
+			return w.watchDir(path)
 		}
 		return nil
 	})
 }
 
-func (w *Watcher) shouldIgnore(path string) bool {
+// isDatabaseFile reports whether path is the database or one of the
+// write-ahead log and shared-memory files SQLite keeps beside it, or the
+// lock and temporary files of an encrypted database.
+func isDatabaseFile(path, dbPath string) bool {
+	switch path {
+	case dbPath, dbPath + "-wal", dbPath + "-shm", dbPath + "-journal", dbPath + ".lock", dbPath + ".tmp":
+		return true
+	}
+	return false
+}
+
+// shouldIgnore reports whether path is one of the daemon's own files or is
+// left alone by the ignore patterns of its root. isDir tells whether path
+// is a folder, for patterns that end in a slash.
+func (w *Watcher) shouldIgnore(path string, isDir bool) bool {
 	base := filepath.Base(path)
 	if base == "." || base == ".." {
 		return true
@@ -200,20 +314,20 @@ func (w *Watcher) shouldIgnore(path string) bool {
 	if w.cfg.LogFilePath != "" && path == w.cfg.LogFilePath {
 		return true
 	}
-	if w.cfg.DatabasePath != "" && path == w.cfg.DatabasePath {
+	if w.cfg.DatabasePath != "" && isDatabaseFile(path, w.cfg.DatabasePath) {
 		return true
 	}
 	if w.cfg.SocketPath != "" && path == w.cfg.SocketPath {
 		return true
 	}
 
-	for _, pat := range w.cfg.IgnorePatterns {
-		if ok, _ := filepath.Match(pat, base); ok {
-			return true
-		}
+	if r := w.rootOf(path); r != nil && r.ignore.Match(filepath.ToSlash(pathRel(path, r.path)), isDir) {
+		return true
 	}
 
-	suffixes := []string{".swp", ".tmp", "~", ".DS_Store"}
+	// Partial downloads are renamed into place when complete; only the
+	// final rename is of interest.
+	suffixes := []string{".swp", ".tmp", "~", ".DS_Store", transfer.PartialSuffix}
 	for _, suf := range suffixes {
 		if len(base) >= len(suf) && base[len(base)-len(suf):] == suf {
 			return true
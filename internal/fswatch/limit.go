@@ -0,0 +1,80 @@
+package fswatch
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/status"
+)
+
+// ErrWatchLimit means inotify ran out of watches for this user. Folders the
+// watcher could not watch are polled instead.
+var ErrWatchLimit = errors.New("inotify watch limit reached; raise it with sysctl fs.inotify.max_user_watches=524288 and restart the daemon")
+
+// isWatchLimit reports whether err from adding a watch is the ENOSPC inotify
+// returns once max_user_watches is used up.
+func isWatchLimit(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
+
+// watchDir adds a watch for dir. When the watch limit is reached, dir and
+// everything below it are polled instead and fs.SkipDir is returned: no
+// folder below it could be watched either.
+func (w *Watcher) watchDir(dir string) error {
+	err := w.addWatch(dir)
+	if err == nil || !isWatchLimit(err) {
+		return err
+	}
+	w.degrade(dir)
+	return fs.SkipDir
+}
+
+// degrade moves the subtree at dir to polling. Events for dir itself still
+// come from its parent's watch.
+func (w *Watcher) degrade(dir string) {
+	if w.polled[dir] {
+		return
+	}
+	w.polled[dir] = true
+	if len(w.polled) == 1 {
+		w.logger.Warn("fswatch falling back to polling", zap.String("path", dir), zap.Error(ErrWatchLimit))
+	}
+	w.status.Update(status.Snapshot{State: status.StateIdle, Message: fmt.Sprintf("watching; %d folders polled every %s: %v", len(w.polled), w.pollEvery(), ErrWatchLimit)})
+}
+
+// pollDegraded rescans the polled subtrees and, when report is set, queues
+// events for what changed since the last scan. A subtree that was polled
+// for the first time is compared with nothing, so files created in it
+// before it fell back to polling are reported too.
+func (w *Watcher) pollDegraded(report bool) {
+	next := make(map[string]fileState)
+	for dir := range w.polled {
+		if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+			delete(w.polled, dir)
+			continue
+		}
+		if err := w.scanTree(dir, next); err != nil {
+			w.logger.Warn("fswatch poll failed", zap.String("path", dir), zap.Error(err))
+			// Keep what the last scan saw, so nothing is reported removed.
+			prefix := dir + string(filepath.Separator)
+			for path, st := range w.polledSnap {
+				if strings.HasPrefix(path, prefix) {
+					next[path] = st
+				}
+			}
+		}
+	}
+	if report {
+		for path, op := range diffSnapshots(w.polledSnap, next) {
+			w.enqueue(path, op)
+		}
+	}
+	w.polledSnap = next
+}
@@ -0,0 +1,79 @@
+package fswatch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/status"
+)
+
+func TestWatchLimitFallsBackToPolling(t *testing.T) {
+	root := t.TempDir()
+	big := filepath.Join(root, "big")
+	old := filepath.Join(big, "deep", "old.txt")
+	if err := os.MkdirAll(filepath.Dir(old), 0o700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(old, []byte("a"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "small"), 0o700); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+
+	statusStore := status.NewStore()
+	w, err := NewWatcher(zap.NewNop(), &config.Config{SyncRoot: root}, statusStore)
+	if err != nil {
+		t.Fatalf("NewWatcher: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	var watched []string
+	w.addWatch = func(path string) error {
+		if strings.HasPrefix(path, big) {
+			return syscall.ENOSPC
+		}
+		watched = append(watched, path)
+		return nil
+	}
+
+	if err := w.addRecursive(root); err != nil {
+		t.Fatalf("addRecursive: %v", err)
+	}
+	if len(watched) != 2 || !w.polled[big] || len(w.polled) != 1 {
+		t.Fatalf("expected root and small watched and big polled, got %v and %v", watched, w.polled)
+	}
+	if msg := statusStore.Current().Message; !strings.Contains(msg, "fs.inotify.max_user_watches") {
+		t.Fatalf("expected the status to name the sysctl, got %q", msg)
+	}
+
+	// The first scan is the baseline; later ones report changes.
+	w.pollDegraded(false)
+	added := filepath.Join(big, "deep", "new.txt")
+	if err := os.WriteFile(added, []byte("b"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Remove(old); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	w.pollDegraded(true)
+	w.mu.Lock()
+	if len(w.pending) != 2 || w.pending[added].Op != OpCreate || w.pending[old].Op != OpRemove {
+		t.Fatalf("expected new.txt created and old.txt removed, got %#v", w.pending)
+	}
+	w.mu.Unlock()
+
+	// A polled folder that is removed stops being polled.
+	if err := os.RemoveAll(big); err != nil {
+		t.Fatalf("RemoveAll: %v", err)
+	}
+	w.pollDegraded(true)
+	if len(w.polled) != 0 {
+		t.Fatalf("expected the removed folder dropped, got %v", w.polled)
+	}
+}
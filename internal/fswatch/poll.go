@@ -0,0 +1,123 @@
+package fswatch
+
+import (
+	"context"
+	"io/fs"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/status"
+)
+
+// defaultPollInterval applies when poll mode is enabled without an interval.
+const defaultPollInterval = 30 * time.Second
+
+// fileState is the per-path data compared between polling scans.
+type fileState struct {
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (w *Watcher) startPolling(ctx context.Context) error {
+	snap, err := w.scan()
+	if err != nil {
+		return err
+	}
+	w.snapshot = snap
+
+	interval := w.pollEvery()
+	for _, r := range w.roots {
+		w.logger.Info("fswatch polling", zap.String("root", r.path), zap.Duration("interval", interval))
+	}
+	w.status.Update(status.Snapshot{State: status.StateIdle, Message: "polling"})
+
+	go w.runPoll(ctx, interval)
+	return nil
+}
+
+// pollEvery is the interval between polling scans.
+func (w *Watcher) pollEvery() time.Duration {
+	if w.pollInterval <= 0 {
+		return defaultPollInterval
+	}
+	return w.pollInterval
+}
+
+func (w *Watcher) runPoll(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	flush := time.NewTicker(200 * time.Millisecond)
+	defer flush.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.pollOnce()
+		case <-flush.C:
+			w.flushPending()
+		}
+	}
+}
+
+// pollOnce rescans the root and queues events for paths whose size or mtime
+// changed since the previous scan.
+func (w *Watcher) pollOnce() {
+	snap, err := w.scan()
+	if err != nil {
+		w.logger.Warn("fswatch poll failed", zap.Error(err))
+		w.status.Update(status.Snapshot{State: status.StateError, Message: "fswatch poll error"})
+		return
+	}
+	for path, op := range diffSnapshots(w.snapshot, snap) {
+		w.enqueue(path, op)
+	}
+	w.snapshot = snap
+}
+
+// scan records every watched root.
+func (w *Watcher) scan() (map[string]fileState, error) {
+	snap := make(map[string]fileState)
+	for _, r := range w.roots {
+		if err := w.scanTree(r.path, snap); err != nil {
+			return nil, err
+		}
+	}
+	return snap, nil
+}
+
+// scanTree records everything below root, but not root itself, in snap.
+func (w *Watcher) scanTree(root string, snap map[string]fileState) error {
+	return w.walkTree(root, func(path string, info fs.FileInfo) error {
+		if path == root {
+			return nil
+		}
+		snap[path] = fileState{size: info.Size(), modTime: info.ModTime(), dir: info.IsDir()}
+		return nil
+	})
+}
+
+// diffSnapshots compares two scans by mtime and size.
+func diffSnapshots(prev, next map[string]fileState) map[string]Op {
+	changes := make(map[string]Op)
+	for path, cur := range next {
+		old, ok := prev[path]
+		switch {
+		case !ok:
+			changes[path] = OpCreate
+		case old.dir != cur.dir:
+			changes[path] = OpCreate
+		case !cur.dir && (old.size != cur.size || !old.modTime.Equal(cur.modTime)):
+			changes[path] = OpWrite
+		}
+	}
+	for path := range prev {
+		if _, ok := next[path]; !ok {
+			changes[path] = OpRemove
+		}
+	}
+	return changes
+}
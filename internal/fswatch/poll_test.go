@@ -0,0 +1,86 @@
+package fswatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/status"
+)
+
+func TestPollDetectsChanges(t *testing.T) {
+	root := t.TempDir()
+	cfg := &config.Config{SyncRoot: root, WatchMode: ModePoll}
+	w, err := NewWatcher(zap.NewNop(), cfg, status.NewStore())
+	if err != nil {
+		t.Fatalf("NewWatcher: %v", err)
+	}
+	if w.watcher != nil {
+		t.Fatalf("expected no fsnotify watcher in poll mode")
+	}
+
+	keep := filepath.Join(root, "keep.txt")
+	gone := filepath.Join(root, "gone.txt")
+	for _, p := range []string{keep, gone} {
+		if err := os.WriteFile(p, []byte("a"), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	snap, err := w.scan()
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	w.snapshot = snap
+
+	added := filepath.Join(root, "dir", "new.txt")
+	if err := os.MkdirAll(filepath.Dir(added), 0o700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(added, []byte("b"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(keep, []byte("changed"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Remove(gone); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	w.pollOnce()
+
+	want := map[string]Op{
+		keep:                OpWrite,
+		gone:                OpRemove,
+		added:               OpCreate,
+		filepath.Dir(added): OpCreate,
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) != len(want) {
+		t.Fatalf("expected %d pending events, got %#v", len(want), w.pending)
+	}
+	for path, op := range want {
+		if got := w.pending[path].Op; got != op {
+			t.Fatalf("%s: expected %s, got %s", path, OpString(op), OpString(got))
+		}
+	}
+}
+
+func TestDiffSnapshotsIgnoresUnchanged(t *testing.T) {
+	now := time.Now()
+	prev := map[string]fileState{"a": {size: 1, modTime: now}, "d": {dir: true, modTime: now}}
+	next := map[string]fileState{"a": {size: 1, modTime: now}, "d": {dir: true, modTime: now.Add(time.Second)}}
+	if changes := diffSnapshots(prev, next); len(changes) != 0 {
+		t.Fatalf("expected no changes, got %#v", changes)
+	}
+}
+
+func TestNewWatcherRejectsUnknownMode(t *testing.T) {
+	if _, err := NewWatcher(zap.NewNop(), &config.Config{WatchMode: "inotify"}, status.NewStore()); err == nil {
+		t.Fatalf("expected error for unknown watch mode")
+	}
+}
@@ -0,0 +1,70 @@
+package fswatch
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/ignore"
+)
+
+// root is one watched tree and the ignore patterns in force in it: the
+// global ones followed by its own.
+type root struct {
+	path   string
+	ignore *ignore.Matcher
+	// extra is set for watch_roots; events in the sync root are attributed
+	// to no root in status.
+	extra bool
+}
+
+// watchRoots returns the sync root followed by cfg.WatchRoots. Roots must be
+// distinct and may not contain one another, so every path belongs to at
+// most one root.
+func watchRoots(cfg *config.Config) ([]root, error) {
+	var roots []root
+	if cfg.SyncRoot != "" {
+		matcher, err := ignore.New(cfg.IgnorePatterns...)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, root{path: filepath.Clean(cfg.SyncRoot), ignore: matcher})
+	}
+	for _, wr := range cfg.WatchRoots {
+		if wr.Path == "" {
+			return nil, errs.New(errs.ErrInvalidArgument, "watch root path cannot be empty")
+		}
+		path, err := filepath.Abs(wr.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range roots {
+			if within(path, r.path) || within(r.path, path) {
+				return nil, errs.New(errs.ErrInvalidArgument, "watch root %s overlaps %s", path, r.path)
+			}
+		}
+		matcher, err := ignore.New(append(slices.Clone(cfg.IgnorePatterns), wr.IgnorePatterns...)...)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, root{path: path, ignore: matcher, extra: true})
+	}
+	return roots, nil
+}
+
+// within reports whether path is dir or lies below it.
+func within(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// rootOf returns the root path belongs to, or nil.
+func (w *Watcher) rootOf(path string) *root {
+	for i := range w.roots {
+		if within(path, w.roots[i].path) {
+			return &w.roots[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package fswatch
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/status"
+)
+
+func TestWatchRootsKeepTheirOwnIgnoreRules(t *testing.T) {
+	syncRoot := t.TempDir()
+	photos := t.TempDir()
+	cfg := &config.Config{
+		SyncRoot:   syncRoot,
+		WatchRoots: []config.WatchRoot{{Path: photos, IgnorePatterns: []string{"*.log", "!keep.log", "cache/"}}},
+	}
+	statusStore := status.NewStore()
+	w, err := NewWatcher(zap.NewNop(), cfg, statusStore)
+	if err != nil {
+		t.Fatalf("NewWatcher: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+
+	if !w.shouldIgnore(filepath.Join(photos, "import.log"), false) {
+		t.Fatal("expected the watch root's pattern to apply in it")
+	}
+	if w.shouldIgnore(filepath.Join(syncRoot, "import.log"), false) {
+		t.Fatal("expected the watch root's pattern not to apply in the sync root")
+	}
+	if w.shouldIgnore(filepath.Join(photos, "2024", "keep.log"), false) {
+		t.Fatal("expected a negated pattern to re-include the file")
+	}
+	if !w.shouldIgnore(filepath.Join(photos, "cache"), true) || w.shouldIgnore(filepath.Join(photos, "cache"), false) {
+		t.Fatal("expected a trailing slash to match folders only")
+	}
+
+	inSync := filepath.Join(syncRoot, "notes.txt")
+	inPhotos := filepath.Join(photos, "2024", "beach.jpg")
+	past := time.Now().Add(-time.Second)
+	w.pending[inSync] = Event{Path: inSync, Op: OpCreate, When: past}
+	w.pending[inPhotos] = Event{Path: inPhotos, Op: OpWrite, When: past}
+	w.flushPending()
+
+	roots := make(map[string]string)
+	for range 2 {
+		evt := <-w.Events()
+		roots[evt.Path] = evt.Root
+	}
+	if roots[inSync] != syncRoot || roots[inPhotos] != photos {
+		t.Fatalf("expected events tagged with their roots, got %v", roots)
+	}
+	attributed := make(map[string]string)
+	for _, evt := range statusStore.Current().RecentEvents {
+		attributed[evt.Path] = evt.Root
+	}
+	want := map[string]string{"notes.txt": "", filepath.Join("2024", "beach.jpg"): photos}
+	if len(attributed) != len(want) {
+		t.Fatalf("expected status events %v, got %v", want, attributed)
+	}
+	for path, root := range want {
+		if got, ok := attributed[path]; !ok || got != root {
+			t.Fatalf("expected %s attributed to %q, got %v", path, root, attributed)
+		}
+	}
+}
+
+func TestWatchRootsMayNotOverlap(t *testing.T) {
+	syncRoot := t.TempDir()
+	cfg := &config.Config{
+		SyncRoot:   syncRoot,
+		WatchRoots: []config.WatchRoot{{Path: filepath.Join(syncRoot, "inner")}},
+	}
+	if _, err := NewWatcher(zap.NewNop(), cfg, status.NewStore()); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected ErrInvalidArgument for a nested root, got %v", err)
+	}
+}
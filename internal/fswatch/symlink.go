@@ -0,0 +1,120 @@
+package fswatch
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// SymlinkPolicy controls how symbolic links inside the sync root are treated.
+type SymlinkPolicy string
+
+const (
+	// SymlinkSkip ignores links entirely.
+	SymlinkSkip SymlinkPolicy = "skip"
+	// SymlinkFollow syncs link targets as if they lived at the link path.
+	SymlinkFollow SymlinkPolicy = "follow"
+	// SymlinkShortcut keeps the link itself, mapped to a Drive shortcut.
+	SymlinkShortcut SymlinkPolicy = "shortcut"
+)
+
+// ParseSymlinkPolicy validates a configured policy. Empty means skip.
+func ParseSymlinkPolicy(val string) (SymlinkPolicy, error) {
+	switch p := SymlinkPolicy(val); p {
+	case "":
+		return SymlinkSkip, nil
+	case SymlinkSkip, SymlinkFollow, SymlinkShortcut:
+		return p, nil
+	default:
+		return "", errs.New(errs.ErrInvalidArgument, "unknown symlink policy %q", val)
+	}
+}
+
+// walkFunc receives each entry under the root by its path through any
+// followed links. info is the link itself under the shortcut policy and the
+// target otherwise.
+type walkFunc func(path string, info fs.FileInfo) error
+
+// walkTree walks root applying the symlink policy. Followed directory links
+// are descended once per target so link cycles terminate.
+func (w *Watcher) walkTree(root string, fn walkFunc) error {
+	seen := make(map[string]bool)
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		seen[real] = true
+	}
+	return w.walkFrom(root, root, seen, fn)
+}
+
+func (w *Watcher) walkFrom(logical, real string, seen map[string]bool, fn walkFunc) error {
+	return filepath.WalkDir(real, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Entries can vanish mid-walk; skip them.
+			if errors.Is(err, fs.ErrNotExist) && path != real {
+				return nil
+			}
+			return err
+		}
+		lp := logical
+		if path != real {
+			rel, err := filepath.Rel(real, path)
+			if err != nil {
+				return err
+			}
+			lp = filepath.Join(logical, rel)
+		}
+		if w.shouldIgnore(lp, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink == 0 {
+			info, err := d.Info()
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			return fn(lp, info)
+		}
+
+		switch w.symlinks {
+		case SymlinkShortcut:
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			return fn(lp, info)
+		case SymlinkFollow:
+			info, err := os.Stat(path)
+			if err != nil {
+				// Dangling link.
+				return nil
+			}
+			if !info.IsDir() {
+				return fn(lp, info)
+			}
+			target, err := filepath.EvalSymlinks(path)
+			if err != nil || seen[target] {
+				return nil
+			}
+			seen[target] = true
+			return w.walkFrom(lp, target, seen, fn)
+		default:
+			return nil
+		}
+	})
+}
+
+// isSkippedLink reports whether path is a symlink the policy ignores.
+func (w *Watcher) isSkippedLink(path string) bool {
+	if w.symlinks != SymlinkSkip {
+		return false
+	}
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&fs.ModeSymlink != 0
+}
@@ -0,0 +1,76 @@
+package fswatch
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/status"
+)
+
+func TestWalkTreeSymlinkPolicies(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "target.txt"), []byte("x"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "plain.txt"), []byte("x"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "linked")); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	// A link back to the root must not loop forever under follow.
+	if err := os.Symlink(root, filepath.Join(root, "loop")); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	cases := map[SymlinkPolicy][]string{
+		SymlinkSkip:     {"plain.txt"},
+		SymlinkFollow:   {"linked", "linked/target.txt", "plain.txt"},
+		SymlinkShortcut: {"linked", "loop", "plain.txt"},
+	}
+	for policy, want := range cases {
+		cfg := &config.Config{SyncRoot: root, WatchMode: ModePoll, SymlinkPolicy: string(policy)}
+		w, err := NewWatcher(zap.NewNop(), cfg, status.NewStore())
+		if err != nil {
+			t.Fatalf("NewWatcher(%s): %v", policy, err)
+		}
+		var got []string
+		err = w.walkTree(root, func(path string, info fs.FileInfo) error {
+			if path != root {
+				got = append(got, pathRel(path, root))
+			}
+			if policy == SymlinkShortcut && filepath.Base(path) == "linked" && info.Mode()&fs.ModeSymlink == 0 {
+				t.Errorf("shortcut policy should report the link itself")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("walkTree(%s): %v", policy, err)
+		}
+		sort.Strings(got)
+		if len(got) != len(want) {
+			t.Fatalf("%s: got %v, want %v", policy, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s: got %v, want %v", policy, got, want)
+			}
+		}
+	}
+}
+
+func TestParseSymlinkPolicy(t *testing.T) {
+	if p, err := ParseSymlinkPolicy(""); err != nil || p != SymlinkSkip {
+		t.Fatalf("expected empty to default to skip, got %q %v", p, err)
+	}
+	if _, err := ParseSymlinkPolicy("dereference"); err == nil {
+		t.Fatal("expected unknown policy to fail")
+	}
+}
@@ -0,0 +1,110 @@
+// Package hashing computes content digests in a single streaming pass.
+//
+// Change detection uses XXH64, a fast non-cryptographic digest. MD5, the
+// checksum Drive reports, and SHA-256 are only computed when content is
+// uploaded or verified against Drive. Callers name the algorithms they need,
+// so a stronger digest can be added without changing them.
+package hashing
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Algorithm names a digest.
+type Algorithm string
+
+const (
+	// XXH64 is the fast digest used to tell whether local content changed.
+	XXH64 Algorithm = "xxh64"
+	// MD5 matches the md5Checksum Drive reports.
+	MD5 Algorithm = "md5"
+	// SHA256 is available for verification that needs a cryptographic digest.
+	SHA256 Algorithm = "sha256"
+)
+
+// Sums maps each requested algorithm to its lowercase hex digest.
+type Sums map[Algorithm]string
+
+// New returns a hash for alg.
+func New(alg Algorithm) (hash.Hash, error) {
+	switch alg {
+	case XXH64:
+		return xxhash.New(), nil
+	case MD5:
+		return md5.New(), nil
+	case SHA256:
+		return sha256.New(), nil
+	default:
+		return nil, errs.New(errs.ErrInvalidArgument, "unknown hash algorithm %q", alg)
+	}
+}
+
+// Hasher feeds written bytes to every requested algorithm at once.
+type Hasher struct {
+	hashes map[Algorithm]hash.Hash
+	w      io.Writer
+}
+
+// NewHasher returns a Hasher for algs.
+func NewHasher(algs ...Algorithm) (*Hasher, error) {
+	h := &Hasher{hashes: make(map[Algorithm]hash.Hash, len(algs))}
+	writers := make([]io.Writer, 0, len(algs))
+	for _, alg := range algs {
+		if _, ok := h.hashes[alg]; ok {
+			continue
+		}
+		hh, err := New(alg)
+		if err != nil {
+			return nil, err
+		}
+		h.hashes[alg] = hh
+		writers = append(writers, hh)
+	}
+	h.w = io.MultiWriter(writers...)
+	return h, nil
+}
+
+func (h *Hasher) Write(p []byte) (int, error) {
+	return h.w.Write(p)
+}
+
+// Sums returns the digests of everything written so far.
+func (h *Hasher) Sums() Sums {
+	out := make(Sums, len(h.hashes))
+	for alg, hh := range h.hashes {
+		out[alg] = hex.EncodeToString(hh.Sum(nil))
+	}
+	return out
+}
+
+// Sum reads r to the end and returns its digests and length.
+func Sum(r io.Reader, algs ...Algorithm) (Sums, int64, error) {
+	h, err := NewHasher(algs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return nil, n, err
+	}
+	return h.Sums(), n, nil
+}
+
+// SumFile returns the digests and size of the file at path.
+func SumFile(path string, algs ...Algorithm) (Sums, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	return Sum(f, algs...)
+}
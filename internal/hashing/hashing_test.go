@@ -0,0 +1,44 @@
+package hashing
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+func TestSumComputesRequestedDigestsInOnePass(t *testing.T) {
+	sums, n, err := Sum(strings.NewReader("hello"), XXH64, MD5, SHA256, MD5)
+	if err != nil || n != 5 {
+		t.Fatalf("Sum: %d, %v", n, err)
+	}
+	want := Sums{
+		XXH64:  "26c7827d889f6da3",
+		MD5:    "5d41402abc4b2a76b9719d911017c592",
+		SHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+	}
+	if len(sums) != len(want) {
+		t.Fatalf("expected %d digests, got %v", len(want), sums)
+	}
+	for alg, sum := range want {
+		if sums[alg] != sum {
+			t.Errorf("%s = %s, want %s", alg, sums[alg], sum)
+		}
+	}
+}
+
+func TestSumFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	sums, n, err := SumFile(path, XXH64)
+	if err != nil || n != 5 || sums[XXH64] != "26c7827d889f6da3" || sums[MD5] != "" {
+		t.Fatalf("SumFile: %v, %d, %v", sums, n, err)
+	}
+	if _, _, err := SumFile(path, "crc32"); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected invalid argument for unknown algorithm, got %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+// Package ignore matches paths against ignore patterns with the semantics of
+// a .gitignore file, so the watcher, the scanners and the sync planner agree
+// on what is left alone.
+//
+// A pattern without a slash matches a name at any depth; one with a slash
+// anywhere but the end is anchored to the folder it was added for. A
+// trailing slash matches folders only. "*", "?" and "[...]" match within a
+// name, "**" matches any number of folders, and a leading "!" re-includes
+// what an earlier pattern ignored. As in git, nothing inside an ignored
+// folder can be re-included.
+package ignore
+
+import (
+	"path"
+	"strings"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Matcher holds patterns in the order they were added. When several match
+// a path, the last one decides.
+type Matcher struct {
+	rules []rule
+}
+
+type rule struct {
+	pattern string
+	// dir is the folder the pattern was added for, "" for the root.
+	dir      string
+	segments []string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// New returns a matcher for patterns relative to the root.
+func New(patterns ...string) (*Matcher, error) {
+	m := &Matcher{}
+	if err := m.Add("", patterns...); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Add appends patterns relative to dir, a slash-separated path from the
+// root or "" for the root itself. Blank patterns and comments starting with
+// "#" are skipped. Nothing is added when a pattern is invalid.
+func (m *Matcher) Add(dir string, patterns ...string) error {
+	dir = strings.Trim(dir, "/")
+	rules := make([]rule, 0, len(patterns))
+	for _, pattern := range patterns {
+		r, ok, err := compile(pattern)
+		if err != nil {
+			return err
+		}
+		if ok {
+			r.dir = dir
+			rules = append(rules, r)
+		}
+	}
+	m.rules = append(m.rules, rules...)
+	return nil
+}
+
+// Validate checks that pattern is a usable ignore pattern.
+func Validate(pattern string) error {
+	if _, ok, err := compile(pattern); err != nil || !ok {
+		return errs.New(errs.ErrInvalidArgument, "invalid ignore pattern %q", pattern)
+	}
+	return nil
+}
+
+// Match reports whether rel, a slash-separated path from the root, is
+// ignored. isDir tells whether rel is a folder; the folders above it always
+// are.
+func (m *Matcher) Match(rel string, isDir bool) bool {
+	return m.Matching(rel, isDir) != ""
+}
+
+// Matching returns the pattern that leaves rel ignored, which may be one
+// that ignores a folder above it, or "" when rel is not ignored.
+func (m *Matcher) Matching(rel string, isDir bool) string {
+	if m == nil || len(m.rules) == 0 {
+		return ""
+	}
+	rel = strings.Trim(rel, "/")
+	if rel == "" || rel == "." {
+		return ""
+	}
+	for i := strings.IndexByte(rel, '/'); i >= 0; {
+		if pattern := m.decide(rel[:i], true); pattern != "" {
+			return pattern
+		}
+		next := strings.IndexByte(rel[i+1:], '/')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return m.decide(rel, isDir)
+}
+
+// decide applies the rules to rel alone, without looking at its folders.
+func (m *Matcher) decide(rel string, isDir bool) string {
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		r := &m.rules[i]
+		if r.matches(rel, isDir) {
+			if r.negate {
+				return ""
+			}
+			return r.pattern
+		}
+	}
+	return ""
+}
+
+func (r *rule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.dir != "" {
+		if !strings.HasPrefix(rel, r.dir+"/") {
+			return false
+		}
+		rel = rel[len(r.dir)+1:]
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.segments[0], path.Base(rel))
+		return ok
+	}
+	return matchSegments(r.segments, strings.Split(rel, "/"))
+}
+
+// compile parses one pattern. ok is false for blanks and comments.
+func compile(pattern string) (r rule, ok bool, err error) {
+	p := trimTrailingSpace(pattern)
+	if p == "" || strings.HasPrefix(p, "#") {
+		return r, false, nil
+	}
+	r.pattern = pattern
+	if strings.HasPrefix(p, "!") {
+		r.negate = true
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") {
+		r.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	if strings.Contains(p, "/") {
+		r.anchored = true
+		p = strings.TrimPrefix(p, "/")
+	}
+	if p == "" {
+		return r, false, errs.New(errs.ErrInvalidArgument, "invalid ignore pattern %q", pattern)
+	}
+	r.segments = strings.Split(p, "/")
+	for _, seg := range r.segments {
+		if seg == "" {
+			return r, false, errs.New(errs.ErrInvalidArgument, "invalid ignore pattern %q", pattern)
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return r, false, errs.New(errs.ErrInvalidArgument, "invalid ignore pattern %q", pattern)
+		}
+	}
+	return r, true, nil
+}
+
+// trimTrailingSpace drops trailing spaces unless they are escaped with a
+// backslash.
+func trimTrailingSpace(p string) string {
+	for strings.HasSuffix(p, " ") && !strings.HasSuffix(p, `\ `) {
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// stands for any number of segments. A trailing "**" matches everything
+// inside a folder but not the folder itself.
+func matchSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			if len(pat) == 1 {
+				return len(name) > 0
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pat[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
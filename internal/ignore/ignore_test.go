@@ -0,0 +1,98 @@
+package ignore
+
+import (
+	"testing"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+func TestMatchFollowsGitignoreRules(t *testing.T) {
+	m, err := New(
+		"*.log",
+		"!keep.log",
+		"build/",
+		"/top.txt",
+		"docs/**/*.tmp",
+		"cache/**",
+		"# a comment",
+		"",
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	cases := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"deep/nested/app.log", false, true},
+		{"keep.log", false, false},
+		{"deep/keep.log", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"src/build/out.o", false, true},
+		{"top.txt", false, true},
+		{"sub/top.txt", false, false},
+		{"docs/a.tmp", false, true},
+		{"docs/x/y/a.tmp", false, true},
+		{"other/docs/a.tmp", false, false},
+		{"cache", true, false},
+		{"cache/blob", false, true},
+		{"cache/sub/blob", false, true},
+		{"notes.txt", false, false},
+	}
+	for _, tc := range cases {
+		if got := m.Match(tc.rel, tc.isDir); got != tc.want {
+			t.Errorf("Match(%q, %t) = %t, want %t", tc.rel, tc.isDir, got, tc.want)
+		}
+	}
+}
+
+func TestNothingInsideAnIgnoredFolderIsReincluded(t *testing.T) {
+	m, err := New("build/", "!build/keep.me", "!keep.me")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := m.Matching("build/keep.me", false); got != "build/" {
+		t.Fatalf("expected the folder's pattern to decide, got %q", got)
+	}
+	if m.Match("keep.me", false) {
+		t.Fatal("expected keep.me outside build to be kept")
+	}
+}
+
+func TestAddScopesPatternsToAFolder(t *testing.T) {
+	m, err := New("*.bak")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := m.Add("photos", "raw/", "!important.bak"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	cases := map[string]bool{
+		"photos/raw/a.cr2":      true,
+		"raw/a.cr2":             false,
+		"photos/important.bak":  false,
+		"important.bak":         true,
+		"photos/2024/other.bak": true,
+	}
+	for rel, want := range cases {
+		if got := m.Match(rel, false); got != want {
+			t.Errorf("Match(%q) = %t, want %t", rel, got, want)
+		}
+	}
+}
+
+func TestValidateRejectsBrokenPatterns(t *testing.T) {
+	for _, pattern := range []string{"", "[", "a//b", "!", "/"} {
+		if err := Validate(pattern); errs.KindOf(err) != errs.ErrInvalidArgument {
+			t.Errorf("Validate(%q) = %v, want ErrInvalidArgument", pattern, err)
+		}
+	}
+	for _, pattern := range []string{"*.tmp", "build/**", "!keep.me", "/anchored.txt", `\#literal`} {
+		if err := Validate(pattern); err != nil {
+			t.Errorf("Validate(%q) = %v", pattern, err)
+		}
+	}
+}
@@ -5,7 +5,11 @@ import (
 	"net"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
 )
 
 // Dial returns a gRPC client connection over a Unix domain socket.
@@ -15,9 +19,28 @@ func Dial(ctx context.Context, socketPath string) (*grpc.ClientConn, error) {
 		return d.DialContext(ctx, "unix", socketPath)
 	}
 
+	// passthrough hands the path to the dialer untouched; the default dns
+	// resolver cannot resolve a filesystem path.
 	return grpc.NewClient(
-		socketPath,
+		"passthrough:///"+socketPath,
 		grpc.WithContextDialer(dialer),
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 	)
 }
+
+// DialRemote returns a gRPC client connection to a daemon's remote listener
+// at addr ("host:port"). The client presents the certificate and token from
+// cfg and checks the daemon's certificate against cfg's CA.
+func DialRemote(addr string, cfg *config.Config) (*grpc.ClientConn, error) {
+	if cfg.RemoteToken == "" {
+		return nil, errs.New(errs.ErrInvalidArgument, "remote access requires remote_token")
+	}
+	tlsCfg, err := remoteTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return grpc.NewClient(addr,
+		grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)),
+		grpc.WithPerRPCCredentials(tokenCredentials(cfg.RemoteToken)),
+	)
+}
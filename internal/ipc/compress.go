@@ -0,0 +1,129 @@
+package ipc
+
+import (
+	"context"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Response compression names accepted by ipc_compression.
+const (
+	CompressionOff  = "off"
+	CompressionGzip = gzip.Name
+	CompressionZstd = "zstd"
+)
+
+func init() {
+	encoding.RegisterCompressor(&zstdCompressor{})
+}
+
+// parseCompression validates a configured compression name. Empty means off.
+func parseCompression(val string) (string, error) {
+	switch val {
+	case "", CompressionOff:
+		return CompressionOff, nil
+	case CompressionGzip, CompressionZstd:
+		return val, nil
+	default:
+		return "", errs.New(errs.ErrInvalidArgument, "unknown ipc compression %q (want %s, %s or %s)", val, CompressionOff, CompressionGzip, CompressionZstd)
+	}
+}
+
+// compressor compresses unary responses of at least minSize bytes when the
+// client advertises support for the configured algorithm. Small responses
+// are sent as is, since compressing them costs more than it saves on a local
+// socket.
+type compressor struct {
+	name    string
+	minSize int
+}
+
+func (c *compressor) unaryInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if err != nil || c.name == CompressionOff {
+		return resp, err
+	}
+	msg, ok := resp.(proto.Message)
+	if !ok || proto.Size(msg) < c.minSize {
+		return resp, nil
+	}
+	supported, _ := grpc.ClientSupportedCompressors(ctx)
+	if slices.Contains(supported, c.name) {
+		_ = grpc.SetSendCompressor(ctx, c.name)
+	}
+	return resp, nil
+}
+
+// zstdCompressor implements the gRPC compressor interface with pooled
+// zstd encoders and decoders.
+type zstdCompressor struct {
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+func (z *zstdCompressor) Name() string { return CompressionZstd }
+
+func (z *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	if enc, ok := z.encoders.Get().(*zstd.Encoder); ok {
+		enc.Reset(w)
+		return &zstdWriter{Encoder: enc, pool: &z.encoders}, nil
+	}
+	enc, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedFastest))
+	if err != nil {
+		return nil, err
+	}
+	return &zstdWriter{Encoder: enc, pool: &z.encoders}, nil
+}
+
+func (z *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	if dec, ok := z.decoders.Get().(*zstd.Decoder); ok {
+		if err := dec.Reset(r); err != nil {
+			z.decoders.Put(dec)
+			return nil, err
+		}
+		return &zstdReader{Decoder: dec, pool: &z.decoders}, nil
+	}
+	dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &zstdReader{Decoder: dec, pool: &z.decoders}, nil
+}
+
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+func (w *zstdWriter) Close() error {
+	err := w.Encoder.Close()
+	w.pool.Put(w.Encoder)
+	return err
+}
+
+// zstdReader returns its decoder to the pool once the message is drained.
+type zstdReader struct {
+	*zstd.Decoder
+	pool *sync.Pool
+}
+
+func (r *zstdReader) Read(p []byte) (int, error) {
+	if r.Decoder == nil {
+		return 0, io.EOF
+	}
+	n, err := r.Decoder.Read(p)
+	if err == io.EOF {
+		r.pool.Put(r.Decoder)
+		r.Decoder = nil
+	}
+	return n, err
+}
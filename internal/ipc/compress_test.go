@@ -0,0 +1,131 @@
+package ipc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/stats"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	ipcgen "github.com/sandeepkv93/googlysync/internal/ipc/gen"
+	"github.com/sandeepkv93/googlysync/internal/status"
+)
+
+func TestZstdRoundTrip(t *testing.T) {
+	z := &zstdCompressor{}
+	payload := bytes.Repeat([]byte("googlysync "), 10_000)
+	for i := 0; i < 3; i++ { // exercise pooled encoders and decoders
+		var buf bytes.Buffer
+		w, err := z.Compress(&buf)
+		if err != nil {
+			t.Fatalf("Compress: %v", err)
+		}
+		if _, err := w.Write(payload); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		if buf.Len() >= len(payload)/10 {
+			t.Fatalf("expected compression, got %d bytes", buf.Len())
+		}
+		r, err := z.Decompress(&buf)
+		if err != nil {
+			t.Fatalf("Decompress: %v", err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(got, payload) {
+			t.Fatalf("round trip mismatch: %d bytes, %v", len(got), err)
+		}
+	}
+}
+
+func TestLargeResponsesCompressed(t *testing.T) {
+	store := status.NewStore()
+	store.SetMaxEvents(5000)
+	for i := 0; i < 5000; i++ {
+		store.AddEvent(status.Event{Op: "WRITE", Path: fmt.Sprintf("projects/tree/file-%05d.txt", i)})
+	}
+	cfg := &config.Config{
+		SocketPath:       filepath.Join(t.TempDir(), "ipc.sock"),
+		IPCCompression:   CompressionZstd,
+		IPCCompressMinKB: 1,
+	}
+	srv, err := NewServer(cfg, zap.NewNop(), store, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = srv.Start(ctx) }()
+
+	seen := &encodingRecorder{}
+	conn, err := grpc.NewClient("passthrough:///"+cfg.SocketPath,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", cfg.SocketPath)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(seen),
+	)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	client := ipcgen.NewSyncStatusServiceClient(conn)
+
+	encoding := func(max int32) string {
+		t.Helper()
+		callCtx, callCancel := context.WithTimeout(ctx, 5*time.Second)
+		defer callCancel()
+		if _, err := client.GetStatus(callCtx, &ipcgen.GetStatusRequest{MaxEvents: max}, grpc.WaitForReady(true)); err != nil {
+			t.Fatalf("GetStatus: %v", err)
+		}
+		return seen.last
+	}
+	if got := encoding(5000); got != CompressionZstd {
+		t.Fatalf("expected large response compressed with zstd, got %q", got)
+	}
+	if got := encoding(1); got != "" {
+		t.Fatalf("expected small response uncompressed, got %q", got)
+	}
+}
+
+// encodingRecorder notes the compression of the last response header.
+type encodingRecorder struct {
+	last string
+}
+
+func (r *encodingRecorder) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (r *encodingRecorder) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if h, ok := s.(*stats.InHeader); ok && h.Client {
+		r.last = h.Compression
+	}
+}
+
+func (r *encodingRecorder) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (r *encodingRecorder) HandleConn(context.Context, stats.ConnStats) {}
+
+func TestParseCompression(t *testing.T) {
+	if got, err := parseCompression(""); err != nil || got != CompressionOff {
+		t.Fatalf("expected off default, got %q, %v", got, err)
+	}
+	if _, err := parseCompression("brotli"); err == nil {
+		t.Fatal("expected unknown compression to fail")
+	}
+}
@@ -1,8 +1,12 @@
 package ipc
 
 import (
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/auth"
 	ipcgen "github.com/sandeepkv93/googlysync/internal/ipc/gen"
 	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/transfer"
 )
 
 func toProtoEvents(events []status.Event) []*ipcgen.StatusEvent {
@@ -12,7 +16,56 @@ func toProtoEvents(events []status.Event) []*ipcgen.StatusEvent {
 			Op:         evt.Op,
 			Path:       evt.Path,
 			OccurredAt: toProtoTimestamp(evt.When),
+			Seq:        evt.Seq,
+			Root:       evt.Root,
+		})
+	}
+	return out
+}
+
+func toProtoTransfers(transfers []transfer.TransferProgress) []*ipcgen.TransferProgress {
+	out := make([]*ipcgen.TransferProgress, 0, len(transfers))
+	for _, t := range transfers {
+		out = append(out, &ipcgen.TransferProgress{
+			Path:           t.Path,
+			Direction:      t.Direction,
+			BytesDone:      t.Done,
+			BytesTotal:     t.Total,
+			BytesPerSecond: t.BytesPerSecond,
+			EtaSeconds:     int64(t.ETA.Round(time.Second) / time.Second),
+			StartedAt:      toProtoTimestamp(t.StartedAt),
 		})
 	}
 	return out
 }
+
+func toProtoAuthEvent(evt auth.Event) *ipcgen.AuthEvent {
+	return &ipcgen.AuthEvent{
+		Kind:       toProtoAuthEventKind(evt.Kind),
+		AccountId:  evt.AccountID,
+		Email:      evt.Email,
+		Error:      evt.Err,
+		OccurredAt: toProtoTimestamp(evt.At),
+	}
+}
+
+func toProtoAuthEventKind(kind auth.EventKind) ipcgen.AuthEvent_Kind {
+	switch kind {
+	case auth.EventAccountAdded:
+		return ipcgen.AuthEvent_KIND_ACCOUNT_ADDED
+	case auth.EventAccountRenewed:
+		return ipcgen.AuthEvent_KIND_ACCOUNT_RENEWED
+	case auth.EventAccountRemoved:
+		return ipcgen.AuthEvent_KIND_ACCOUNT_REMOVED
+	case auth.EventActiveChanged:
+		return ipcgen.AuthEvent_KIND_ACTIVE_CHANGED
+	case auth.EventTokenInvalid:
+		return ipcgen.AuthEvent_KIND_TOKEN_INVALID
+	case auth.EventTokenValid:
+		return ipcgen.AuthEvent_KIND_TOKEN_VALID
+	case auth.EventPrimaryChanged:
+		return ipcgen.AuthEvent_KIND_PRIMARY_CHANGED
+	default:
+		return ipcgen.AuthEvent_KIND_UNSPECIFIED
+	}
+}
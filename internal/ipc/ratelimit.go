@@ -0,0 +1,139 @@
+package ipc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// connKey carries the per-connection id assigned by the limiter.
+type connKey struct{}
+
+// rateLimiter enforces a token-bucket request rate and a cap on concurrent
+// requests for each client connection. Zero limits disable the check.
+type rateLimiter struct {
+	rate        float64
+	burst       float64
+	maxInFlight int
+
+	nextID atomic.Uint64
+	now    func() time.Time
+
+	mu    sync.Mutex
+	conns map[uint64]*connLimit
+}
+
+type connLimit struct {
+	tokens   float64
+	last     time.Time
+	inFlight int
+}
+
+func newRateLimiter(rate, burst, maxInFlight int) *rateLimiter {
+	if burst < rate {
+		burst = rate
+	}
+	return &rateLimiter{
+		rate:        float64(rate),
+		burst:       float64(burst),
+		maxInFlight: maxInFlight,
+		now:         time.Now,
+		conns:       make(map[uint64]*connLimit),
+	}
+}
+
+// acquire admits one request on conn, returning a release func.
+func (l *rateLimiter) acquire(conn uint64) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, ok := l.conns[conn]
+	if !ok {
+		c = &connLimit{tokens: l.burst, last: l.now()}
+		l.conns[conn] = c
+	}
+	if l.rate > 0 {
+		now := l.now()
+		c.tokens += now.Sub(c.last).Seconds() * l.rate
+		if c.tokens > l.burst {
+			c.tokens = l.burst
+		}
+		c.last = now
+		if c.tokens < 1 {
+			return nil, grpcstatus.Errorf(codes.ResourceExhausted,
+				"rate limit exceeded: %d requests/sec per connection", int(l.rate))
+		}
+	}
+	if l.maxInFlight > 0 && c.inFlight >= l.maxInFlight {
+		return nil, grpcstatus.Errorf(codes.ResourceExhausted,
+			"too many concurrent requests: limit is %d per connection", l.maxInFlight)
+	}
+	if l.rate > 0 {
+		c.tokens--
+	}
+	c.inFlight++
+	return func() {
+		l.mu.Lock()
+		c.inFlight--
+		l.mu.Unlock()
+	}, nil
+}
+
+func (l *rateLimiter) admit(ctx context.Context) (func(), error) {
+	conn, ok := ctx.Value(connKey{}).(uint64)
+	if !ok {
+		return func() {}, nil
+	}
+	return l.acquire(conn)
+}
+
+// unaryInterceptor rejects unary calls over the connection's limits.
+func (l *rateLimiter) unaryInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	release, err := l.admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return handler(ctx, req)
+}
+
+// streamInterceptor counts a stream as one request for its whole lifetime.
+func (l *rateLimiter) streamInterceptor(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	release, err := l.admit(ss.Context())
+	if err != nil {
+		return err
+	}
+	defer release()
+	return handler(srv, ss)
+}
+
+// TagConn assigns each accepted connection its own limiter bucket.
+func (l *rateLimiter) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return context.WithValue(ctx, connKey{}, l.nextID.Add(1))
+}
+
+// HandleConn drops the bucket once the connection closes.
+func (l *rateLimiter) HandleConn(ctx context.Context, s stats.ConnStats) {
+	if _, ok := s.(*stats.ConnEnd); !ok {
+		return
+	}
+	if conn, ok := ctx.Value(connKey{}).(uint64); ok {
+		l.mu.Lock()
+		delete(l.conns, conn)
+		l.mu.Unlock()
+	}
+}
+
+// TagRPC implements stats.Handler.
+func (l *rateLimiter) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+// HandleRPC implements stats.Handler.
+func (l *rateLimiter) HandleRPC(context.Context, stats.RPCStats) {}
@@ -0,0 +1,50 @@
+package ipc
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+func TestRateLimiterRefillsPerConnection(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newRateLimiter(2, 2, 0)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		release, err := l.acquire(1)
+		if err != nil {
+			t.Fatalf("acquire %d: %v", i, err)
+		}
+		release()
+	}
+	if _, err := l.acquire(1); grpcstatus.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected RESOURCE_EXHAUSTED, got %v", err)
+	}
+	// Another connection has its own bucket.
+	if _, err := l.acquire(2); err != nil {
+		t.Fatalf("expected second connection admitted, got %v", err)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if _, err := l.acquire(1); err != nil {
+		t.Fatalf("expected refill after 500ms, got %v", err)
+	}
+}
+
+func TestRateLimiterCapsConcurrency(t *testing.T) {
+	l := newRateLimiter(0, 0, 1)
+	release, err := l.acquire(1)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := l.acquire(1); grpcstatus.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected RESOURCE_EXHAUSTED, got %v", err)
+	}
+	release()
+	if _, err := l.acquire(1); err != nil {
+		t.Fatalf("expected slot freed, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package ipc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	ipcgen "github.com/sandeepkv93/googlysync/internal/ipc/gen"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// Roles a remote token can grant. A read-only client can watch status but
+// cannot change anything.
+const (
+	RoleRead  = "read"
+	RoleAdmin = "admin"
+)
+
+// readOnlyMethods are the RPCs the read role may call. Every other RPC,
+// including ones added later, needs the admin role.
+var readOnlyMethods = map[string]bool{
+	ipcgen.DaemonControlService_Ping_FullMethodName:     true,
+	ipcgen.SyncStatusService_GetStatus_FullMethodName:   true,
+	ipcgen.SyncStatusService_WatchStatus_FullMethodName: true,
+	ipcgen.AuthService_GetAuthState_FullMethodName:      true,
+	ipcgen.StatsService_GetStats_FullMethodName:         true,
+}
+
+// tokenPrefix makes remote tokens recognizable, e.g. in a leaked config file.
+const tokenPrefix = "gsr_"
+
+// ValidRole reports whether role can be granted to a remote token.
+func ValidRole(role string) bool {
+	return role == RoleRead || role == RoleAdmin
+}
+
+// IssueRemoteToken creates a token granting role and returns its id and the
+// token itself. Only a hash is stored, so the token cannot be shown again.
+func IssueRemoteToken(ctx context.Context, store storage.Store, name, role string) (string, string, error) {
+	if !ValidRole(role) {
+		return "", "", errs.New(errs.ErrInvalidArgument, "unknown role %q: use %s or %s", role, RoleRead, RoleAdmin)
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := tokenPrefix + hex.EncodeToString(buf)
+	id := "tok-" + hex.EncodeToString(buf[:4])
+	if err := store.AddRemoteToken(ctx, &storage.RemoteToken{ID: id, Name: name, Role: role, TokenHash: hashToken(token)}); err != nil {
+		return "", "", err
+	}
+	return id, token, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// remoteAuth admits remote calls by bearer token and role. The TLS layer has
+// already verified the client certificate.
+type remoteAuth struct {
+	store storage.Store
+}
+
+func (a *remoteAuth) authorize(ctx context.Context, method string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	vals := md.Get("authorization")
+	if len(vals) == 0 {
+		return grpcstatus.Error(codes.Unauthenticated, "remote token required")
+	}
+	tok, err := a.store.GetRemoteTokenByHash(ctx, hashToken(strings.TrimPrefix(vals[0], "Bearer ")))
+	if err != nil {
+		return statusError(err)
+	}
+	if tok == nil {
+		return grpcstatus.Error(codes.Unauthenticated, "unknown remote token")
+	}
+	if tok.Role != RoleAdmin && !readOnlyMethods[method] {
+		return grpcstatus.Errorf(codes.PermissionDenied, "token %s has the %s role", tok.ID, tok.Role)
+	}
+	return nil
+}
+
+func (a *remoteAuth) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := a.authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *remoteAuth) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := a.authorize(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// listenRemote opens the TLS listener for remote clients and builds the
+// server that serves it.
+func (s *Server) listenRemote(limiter *rateLimiter, compress *compressor) (net.Listener, error) {
+	if s.store == nil {
+		return nil, errors.New("remote ipc needs token storage")
+	}
+	tlsCfg, err := remoteTLSConfig(s.cfg)
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg.ClientCAs = tlsCfg.RootCAs
+	tlsCfg.RootCAs = nil
+	tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	ln, err := net.Listen("tcp", s.cfg.RemoteListen)
+	if err != nil {
+		return nil, err
+	}
+	s.remoteServer = s.newGRPCServer(limiter, compress, &remoteAuth{store: s.store}, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	return ln, nil
+}
+
+// remoteTLSConfig loads this side's certificate and the CA that signs the
+// peer's, which the caller installs as client or root CAs.
+func remoteTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	if cfg.RemoteTLSCert == "" || cfg.RemoteTLSKey == "" || cfg.RemoteTLSCA == "" {
+		return nil, errs.New(errs.ErrInvalidArgument, "remote access requires remote_tls_cert, remote_tls_key and remote_tls_ca")
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.RemoteTLSCert, cfg.RemoteTLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("load remote certificate: %w", err)
+	}
+	pem, err := os.ReadFile(cfg.RemoteTLSCA)
+	if err != nil {
+		return nil, fmt.Errorf("load remote ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errs.New(errs.ErrInvalidArgument, "no certificates in %s", cfg.RemoteTLSCA)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, RootCAs: pool, MinVersion: tls.VersionTLS13}, nil
+}
+
+// tokenCredentials attaches a remote token to every call.
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (tokenCredentials) RequireTransportSecurity() bool {
+	return true
+}
@@ -0,0 +1,201 @@
+package ipc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	ipcgen "github.com/sandeepkv93/googlysync/internal/ipc/gen"
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+type fakeSync struct{}
+
+func (fakeSync) Pause(_ context.Context, accountID string) (string, error)  { return accountID, nil }
+func (fakeSync) Resume(_ context.Context, accountID string) (string, error) { return accountID, nil }
+func (fakeSync) RetryFailed(context.Context, string, string) (int, error)   { return 0, nil }
+func (fakeSync) DropIndex(context.Context) error                            { return nil }
+func (fakeSync) Resync(_ context.Context, accountID string) (*ResyncResult, error) {
+	return &ResyncResult{AccountID: accountID}, nil
+}
+
+// testPKI writes a CA and certificates signed by it into dir.
+type testPKI struct {
+	dir    string
+	caCert *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	p := &testPKI{dir: t.TempDir()}
+	p.caCert, p.caKey = p.issue(t, "ca", nil, nil)
+	return p
+}
+
+// issue creates a certificate signed by the CA, or a self-signed CA when
+// parent is nil, and returns it with its key.
+func (p *testPKI) issue(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %v", err)
+	}
+	p.write(t, name+".crt", "CERTIFICATE", der)
+	p.write(t, name+".key", "EC PRIVATE KEY", keyDER)
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	return cert, key
+}
+
+func (p *testPKI) write(t *testing.T, name, kind string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(p.dir, name), pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+// config returns TLS settings presenting the named certificate and trusting
+// the CA.
+func (p *testPKI) config(name string) config.Config {
+	return config.Config{
+		RemoteTLSCert: filepath.Join(p.dir, name+".crt"),
+		RemoteTLSKey:  filepath.Join(p.dir, name+".key"),
+		RemoteTLSCA:   filepath.Join(p.dir, "ca.crt"),
+	}
+}
+
+func TestRemoteAccessRequiresCertificateAndToken(t *testing.T) {
+	pki := newTestPKI(t)
+	pki.issue(t, "server", pki.caCert, pki.caKey)
+	pki.issue(t, "laptop", pki.caCert, pki.caKey)
+	// A client whose certificate the daemon's CA did not sign.
+	rogue := newTestPKI(t)
+	rogue.issue(t, "rogue", rogue.caCert, rogue.caKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	dir := t.TempDir()
+	cfg := pki.config("server")
+	cfg.SocketPath = filepath.Join(dir, "ipc.sock")
+	cfg.DatabasePath = filepath.Join(dir, "googlysync.db")
+	cfg.RemoteListen = addr
+	store, err := storage.NewStorage(&cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, readToken, err := IssueRemoteToken(ctx, store, "laptop", RoleRead)
+	if err != nil {
+		t.Fatalf("IssueRemoteToken: %v", err)
+	}
+	_, adminToken, err := IssueRemoteToken(ctx, store, "desktop", RoleAdmin)
+	if err != nil {
+		t.Fatalf("IssueRemoteToken: %v", err)
+	}
+	if _, _, err := IssueRemoteToken(ctx, store, "x", "owner"); err == nil {
+		t.Fatal("expected unknown role to be rejected")
+	}
+
+	srv, err := NewServer(&cfg, zap.NewNop(), status.NewStore(), nil, nil, nil, fakeSync{}, store)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	go func() { _ = srv.Start(ctx) }()
+
+	call := func(pki *testPKI, cert, token string, pause bool) error {
+		t.Helper()
+		clientCfg := pki.config(cert)
+		clientCfg.RemoteToken = token
+		conn, err := DialRemote(addr, &clientCfg)
+		if err != nil {
+			t.Fatalf("DialRemote: %v", err)
+		}
+		defer conn.Close()
+		callCtx, callCancel := context.WithTimeout(ctx, 5*time.Second)
+		defer callCancel()
+		if pause {
+			_, err = ipcgen.NewDaemonControlServiceClient(conn).PauseSync(callCtx, &ipcgen.PauseSyncRequest{}, grpc.WaitForReady(true))
+		} else {
+			_, err = ipcgen.NewSyncStatusServiceClient(conn).GetStatus(callCtx, &ipcgen.GetStatusRequest{}, grpc.WaitForReady(true))
+		}
+		return err
+	}
+
+	if err := call(pki, "laptop", readToken, false); err != nil {
+		t.Fatalf("read-only GetStatus: %v", err)
+	}
+	if err := call(pki, "laptop", readToken, true); grpcstatus.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected read-only token denied PauseSync, got %v", err)
+	}
+	if err := call(pki, "laptop", adminToken, true); err != nil {
+		t.Fatalf("admin PauseSync: %v", err)
+	}
+	if err := call(pki, "laptop", "gsr_bogus", false); grpcstatus.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected unknown token rejected, got %v", err)
+	}
+
+	rogueCfg := rogue.config("rogue")
+	rogueCfg.RemoteTLSCA = cfg.RemoteTLSCA
+	rogueCfg.RemoteToken = adminToken
+	conn, err := DialRemote(addr, &rogueCfg)
+	if err != nil {
+		t.Fatalf("DialRemote: %v", err)
+	}
+	defer conn.Close()
+	callCtx, callCancel := context.WithTimeout(ctx, 2*time.Second)
+	defer callCancel()
+	if _, err := ipcgen.NewSyncStatusServiceClient(conn).GetStatus(callCtx, &ipcgen.GetStatusRequest{}); err == nil {
+		t.Fatal("expected a certificate from another CA to be refused")
+	}
+}
@@ -6,16 +6,22 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	grpcstatus "google.golang.org/grpc/status"
 
 	"github.com/sandeepkv93/googlysync/internal/auth"
 	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
 	ipcgen "github.com/sandeepkv93/googlysync/internal/ipc/gen"
+	"github.com/sandeepkv93/googlysync/internal/search"
 	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+	"github.com/sandeepkv93/googlysync/internal/transfer"
 )
 
 // Server wraps the gRPC server for daemon IPC.
@@ -23,25 +29,68 @@ type Server struct {
 	ipcgen.UnimplementedDaemonControlServiceServer
 	ipcgen.UnimplementedSyncStatusServiceServer
 	ipcgen.UnimplementedAuthServiceServer
+	ipcgen.UnimplementedStatsServiceServer
+	ipcgen.UnimplementedSearchServiceServer
 
 	cfg    *config.Config
 	logger *zap.Logger
 	ver    string
 	status *status.Store
 	auth   *auth.Service
+	bufs   *transfer.BufferPool
+	prog   *transfer.Progress
+	sync   SyncController
+	store  storage.Store
 
-	grpcServer *grpc.Server
-	listener   net.Listener
+	grpcServer   *grpc.Server
+	listener     net.Listener
+	remoteServer *grpc.Server
+}
+
+// SyncController pauses and resumes syncing for an account, retries its
+// failed operations and rebuilds its index. An empty account id selects the
+// account the daemon syncs, and other accounts are refused; Pause and
+// Resume return the resolved id.
+type SyncController interface {
+	Pause(ctx context.Context, accountID string) (string, error)
+	Resume(ctx context.Context, accountID string) (string, error)
+	RetryFailed(ctx context.Context, accountID, opID string) (int, error)
+	Resync(ctx context.Context, accountID string) (*ResyncResult, error)
+	// DropIndex pauses syncing and forgets the index of the synced account
+	// after it was signed out, so none of it is applied to the next one.
+	DropIndex(ctx context.Context) error
+}
+
+// ResyncResult summarizes an index rebuild.
+type ResyncResult struct {
+	AccountID string
+	Adopted   int
+	Uploads   int
+	Downloads int
+	Skipped   []string
 }
 
 // NewServer constructs a gRPC IPC server.
-func NewServer(cfg *config.Config, logger *zap.Logger, statusStore *status.Store, authSvc *auth.Service) (*Server, error) {
+func NewServer(
+	cfg *config.Config,
+	logger *zap.Logger,
+	statusStore *status.Store,
+	authSvc *auth.Service,
+	buffers *transfer.BufferPool,
+	progress *transfer.Progress,
+	syncCtl SyncController,
+	store storage.Store,
+) (*Server, error) {
 	return &Server{
 		cfg:    cfg,
 		logger: logger,
 		ver:    "dev",
 		status: statusStore,
 		auth:   authSvc,
+		bufs:   buffers,
+		prog:   progress,
+		sync:   syncCtl,
+		store:  store,
 	}, nil
 }
 
@@ -52,11 +101,16 @@ func (s *Server) WithVersion(version string) {
 	}
 }
 
-// Start begins serving over a Unix domain socket and blocks until ctx is done.
+// Start begins serving over a Unix domain socket, and over TLS when a remote
+// listen address is configured, and blocks until ctx is done.
 func (s *Server) Start(ctx context.Context) error {
 	if s.cfg.SocketPath == "" {
 		return errors.New("socket path not configured")
 	}
+	compression, err := parseCompression(s.cfg.IPCCompression)
+	if err != nil {
+		return err
+	}
 
 	if err := os.MkdirAll(filepath.Dir(s.cfg.SocketPath), 0o700); err != nil {
 		return err
@@ -69,12 +123,23 @@ func (s *Server) Start(ctx context.Context) error {
 	}
 	s.listener = ln
 
-	s.grpcServer = grpc.NewServer()
-	ipcgen.RegisterDaemonControlServiceServer(s.grpcServer, s)
-	ipcgen.RegisterSyncStatusServiceServer(s.grpcServer, s)
-	ipcgen.RegisterAuthServiceServer(s.grpcServer, s)
+	compress := &compressor{name: compression, minSize: s.cfg.IPCCompressMinKB << 10}
+
+	limiter := newRateLimiter(s.cfg.IPCRateLimit, s.cfg.IPCRateBurst, s.cfg.IPCMaxConcurrent)
+	s.grpcServer = s.newGRPCServer(limiter, compress, nil)
 
-	errCh := make(chan error, 1)
+	errCh := make(chan error, 2)
+	if s.cfg.RemoteListen != "" {
+		remoteLn, err := s.listenRemote(limiter, compress)
+		if err != nil {
+			_ = ln.Close()
+			return err
+		}
+		go func() {
+			s.logger.Info("remote ipc listening", zap.String("addr", remoteLn.Addr().String()))
+			errCh <- s.remoteServer.Serve(remoteLn)
+		}()
+	}
 	go func() {
 		s.logger.Info("ipc server listening", zap.String("socket", s.cfg.SocketPath))
 		errCh <- s.grpcServer.Serve(ln)
@@ -82,6 +147,9 @@ func (s *Server) Start(ctx context.Context) error {
 
 	select {
 	case <-ctx.Done():
+		if s.remoteServer != nil {
+			s.remoteServer.GracefulStop()
+		}
 		s.grpcServer.GracefulStop()
 		_ = ln.Close()
 		return nil
@@ -90,8 +158,34 @@ func (s *Server) Start(ctx context.Context) error {
 	}
 }
 
-// Stop forces the gRPC server to stop.
+// newGRPCServer builds a server with every IPC service registered. auth, when
+// set, checks remote clients' tokens before the other interceptors run.
+func (s *Server) newGRPCServer(limiter *rateLimiter, compress *compressor, auth *remoteAuth, opts ...grpc.ServerOption) *grpc.Server {
+	unary := []grpc.UnaryServerInterceptor{limiter.unaryInterceptor, compress.unaryInterceptor}
+	stream := []grpc.StreamServerInterceptor{limiter.streamInterceptor}
+	if auth != nil {
+		unary = append([]grpc.UnaryServerInterceptor{auth.unaryInterceptor}, unary...)
+		stream = append([]grpc.StreamServerInterceptor{auth.streamInterceptor}, stream...)
+	}
+	opts = append(opts,
+		grpc.StatsHandler(limiter),
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	)
+	srv := grpc.NewServer(opts...)
+	ipcgen.RegisterDaemonControlServiceServer(srv, s)
+	ipcgen.RegisterSyncStatusServiceServer(srv, s)
+	ipcgen.RegisterAuthServiceServer(srv, s)
+	ipcgen.RegisterStatsServiceServer(srv, s)
+	ipcgen.RegisterSearchServiceServer(srv, s)
+	return srv
+}
+
+// Stop forces the gRPC servers to stop.
 func (s *Server) Stop() {
+	if s.remoteServer != nil {
+		s.remoteServer.Stop()
+	}
 	if s.grpcServer != nil {
 		s.grpcServer.Stop()
 	}
@@ -112,21 +206,89 @@ func (s *Server) Shutdown(ctx context.Context, _ *ipcgen.ShutdownRequest) (*ipcg
 	return &ipcgen.ShutdownResponse{RequestId: "req-0"}, nil
 }
 
-// GetStatus returns a basic status snapshot.
-func (s *Server) GetStatus(ctx context.Context, _ *ipcgen.GetStatusRequest) (*ipcgen.GetStatusResponse, error) {
+// PauseSync pauses syncing for an account. It returns once in-flight
+// transfers have drained.
+func (s *Server) PauseSync(ctx context.Context, req *ipcgen.PauseSyncRequest) (*ipcgen.PauseSyncResponse, error) {
+	if s.sync == nil {
+		return nil, grpcstatus.Error(codes.Unavailable, "sync engine not running")
+	}
+	accountID, err := s.sync.Pause(ctx, req.GetAccountId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &ipcgen.PauseSyncResponse{AccountId: accountID, RequestId: "req-0"}, nil
+}
+
+// ResumeSync resumes syncing for an account.
+func (s *Server) ResumeSync(ctx context.Context, req *ipcgen.ResumeSyncRequest) (*ipcgen.ResumeSyncResponse, error) {
+	if s.sync == nil {
+		return nil, grpcstatus.Error(codes.Unavailable, "sync engine not running")
+	}
+	accountID, err := s.sync.Resume(ctx, req.GetAccountId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &ipcgen.ResumeSyncResponse{AccountId: accountID, RequestId: "req-0"}, nil
+}
+
+// RetryFailedOps queues failed operations to run again.
+func (s *Server) RetryFailedOps(ctx context.Context, req *ipcgen.RetryFailedOpsRequest) (*ipcgen.RetryFailedOpsResponse, error) {
+	if s.sync == nil {
+		return nil, grpcstatus.Error(codes.Unavailable, "sync engine not running")
+	}
+	n, err := s.sync.RetryFailed(ctx, req.GetAccountId(), req.GetOpId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &ipcgen.RetryFailedOpsResponse{Retried: int32(n), RequestId: "req-0"}, nil
+}
+
+// Resync rebuilds an account's index. It returns when the crawl is done; the
+// transfers it plans run afterwards like any other pending operation.
+func (s *Server) Resync(ctx context.Context, req *ipcgen.ResyncRequest) (*ipcgen.ResyncResponse, error) {
+	if s.sync == nil {
+		return nil, grpcstatus.Error(codes.Unavailable, "sync engine not running")
+	}
+	res, err := s.sync.Resync(ctx, req.GetAccountId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &ipcgen.ResyncResponse{
+		AccountId: res.AccountID,
+		Adopted:   int32(res.Adopted),
+		Uploads:   int32(res.Uploads),
+		Downloads: int32(res.Downloads),
+		Skipped:   res.Skipped,
+		RequestId: "req-0",
+	}, nil
+}
+
+// GetStatus returns a basic status snapshot, limited to the requested number
+// of most recent events.
+func (s *Server) GetStatus(ctx context.Context, req *ipcgen.GetStatusRequest) (*ipcgen.GetStatusResponse, error) {
 	_ = ctx
 	statusSnapshot := s.status.Current()
-	return &ipcgen.GetStatusResponse{Status: toProtoStatus(statusSnapshot), RequestId: "req-0"}, nil
+	statusSnapshot.RecentEvents = status.EventsAfter(statusSnapshot.RecentEvents, req.GetAfterSeq())
+	if n := int(req.GetMaxEvents()); n > 0 && len(statusSnapshot.RecentEvents) > n {
+		statusSnapshot.RecentEvents = statusSnapshot.RecentEvents[len(statusSnapshot.RecentEvents)-n:]
+	}
+	return &ipcgen.GetStatusResponse{Status: s.protoStatus(statusSnapshot), RequestId: "req-0"}, nil
 }
 
 // WatchStatus streams periodic status updates until the client disconnects.
-func (s *Server) WatchStatus(_ *ipcgen.WatchStatusRequest, stream ipcgen.SyncStatusService_WatchStatusServer) error {
+// Each update carries only events newer than those already sent.
+func (s *Server) WatchStatus(req *ipcgen.WatchStatusRequest, stream ipcgen.SyncStatusService_WatchStatusServer) error {
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
+	after := req.GetAfterSeq()
 	for {
 		statusSnapshot := s.status.Current()
-		if err := stream.Send(&ipcgen.WatchStatusResponse{Status: toProtoStatus(statusSnapshot), RequestId: "req-0"}); err != nil {
+		statusSnapshot.RecentEvents = status.EventsAfter(statusSnapshot.RecentEvents, after)
+		if n := len(statusSnapshot.RecentEvents); n > 0 {
+			after = statusSnapshot.RecentEvents[n-1].Seq
+		}
+		if err := stream.Send(&ipcgen.WatchStatusResponse{Status: s.protoStatus(statusSnapshot), RequestId: "req-0"}); err != nil {
 			return err
 		}
 		select {
@@ -151,12 +313,103 @@ func (s *Server) GetAuthState(ctx context.Context, _ *ipcgen.GetAuthStateRequest
 	}, nil
 }
 
+// DiagnoseAccounts reports token health for one or all accounts.
+func (s *Server) DiagnoseAccounts(ctx context.Context, req *ipcgen.DiagnoseAccountsRequest) (*ipcgen.DiagnoseAccountsResponse, error) {
+	if s.auth == nil {
+		return nil, grpcstatus.Error(codes.Unavailable, "auth service not running")
+	}
+	diags, err := s.auth.Diagnose(ctx, req.GetAccountId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &ipcgen.DiagnoseAccountsResponse{RequestId: "req-0"}
+	for _, d := range diags {
+		resp.Accounts = append(resp.Accounts, &ipcgen.AccountDiagnosis{
+			AccountId:          d.Account.ID,
+			Email:              d.Account.Email,
+			IsPrimary:          d.Account.IsPrimary,
+			Scopes:             d.Scopes,
+			TokenExpiry:        toProtoTimestamp(d.TokenExpiry),
+			LastRefresh:        toProtoTimestamp(d.LastRefresh),
+			LastRefreshError:   d.LastRefreshError,
+			LastRefreshErrorAt: toProtoTimestamp(d.LastRefreshErrorAt),
+			KeyringReachable:   d.KeyringReachable,
+			RefreshTokenStored: d.RefreshTokenStored,
+			KeyringError:       d.KeyringError,
+			Problems:           d.Problems(),
+			ServiceAccount:     d.ServiceAccount,
+			TokenStore:         d.TokenStore,
+			ReauthReason:       d.Account.ReauthReason,
+		})
+	}
+	return resp, nil
+}
+
+// GetStats returns transfer buffer pool usage.
+func (s *Server) GetStats(ctx context.Context, _ *ipcgen.GetStatsRequest) (*ipcgen.GetStatsResponse, error) {
+	_ = ctx
+	resp := &ipcgen.GetStatsResponse{RequestId: "req-0"}
+	if s.bufs != nil {
+		stats := s.bufs.Stats()
+		resp.Buffers = &ipcgen.BufferPoolStats{
+			BufferSize:  int32(stats.BufferSize),
+			BudgetBytes: stats.BudgetBytes,
+			InUse:       int32(stats.InUse),
+			PeakInUse:   int32(stats.PeakInUse),
+			Acquired:    stats.Acquired,
+			Waits:       stats.Waits,
+		}
+	}
+	return resp, nil
+}
+
+// Search looks up files in the local index. Drive is not queried; clients
+// that want remote hits search it themselves with their own credentials.
+func (s *Server) Search(ctx context.Context, req *ipcgen.SearchRequest) (*ipcgen.SearchResponse, error) {
+	if s.store == nil {
+		return nil, grpcstatus.Error(codes.Unavailable, "storage not available")
+	}
+	if strings.TrimSpace(req.GetQuery()) == "" {
+		return nil, grpcstatus.Error(codes.InvalidArgument, "query is required")
+	}
+	results, _, err := search.NewSearcher(s.store).Find(ctx, req.GetQuery(), search.Options{
+		Limit:     int(req.GetLimit()),
+		AccountID: req.GetAccountId(),
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &ipcgen.SearchResponse{RequestId: "req-0"}
+	for _, r := range results {
+		resp.Hits = append(resp.Hits, &ipcgen.SearchHit{
+			AccountId:  r.AccountID,
+			Path:       r.Path,
+			DriveId:    r.DriveID,
+			Size:       r.Size,
+			ModifiedAt: toProtoTimestamp(r.Modified),
+			State:      r.State,
+		})
+	}
+	return resp, nil
+}
+
+// protoStatus converts the snapshot and adds the transfers in progress.
+func (s *Server) protoStatus(snapshot status.Snapshot) *ipcgen.Status {
+	out := toProtoStatus(snapshot)
+	out.Transfers = toProtoTransfers(s.prog.Active())
+	return out
+}
+
 func toProtoStatus(snapshot status.Snapshot) *ipcgen.Status {
 	return &ipcgen.Status{
 		State:        mapState(snapshot.State),
 		Message:      snapshot.Message,
 		UpdatedAt:    toProtoTimestamp(snapshot.UpdatedAt),
 		RecentEvents: toProtoEvents(snapshot.RecentEvents),
+		FailedOps:    int32(snapshot.FailedOps),
+
+		QuotaLimitBytes: snapshot.QuotaLimit,
+		QuotaUsageBytes: snapshot.QuotaUsage,
 	}
 }
 
@@ -170,14 +423,192 @@ func mapState(state status.State) ipcgen.Status_SyncState {
 		return ipcgen.Status_SYNC_STATE_ERROR
 	case status.StatePaused:
 		return ipcgen.Status_SYNC_STATE_PAUSED
+	case status.StateLowDiskSpace:
+		return ipcgen.Status_SYNC_STATE_LOW_DISK_SPACE
+	case status.StateQuotaExceeded:
+		return ipcgen.Status_SYNC_STATE_QUOTA_EXCEEDED
+	case status.StateAuthRequired:
+		return ipcgen.Status_SYNC_STATE_AUTH_REQUIRED
 	default:
 		return ipcgen.Status_SYNC_STATE_UNSPECIFIED
 	}
 }
 
+// SignIn runs the browser sign-in from the daemon, streaming the consent page
+// for the client to open and then the signed-in account.
+func (s *Server) SignIn(req *ipcgen.SignInRequest, stream ipcgen.AuthService_SignInServer) error {
+	if s.auth == nil {
+		return grpcstatus.Error(codes.Unavailable, "auth service not running")
+	}
+	if root := req.GetSyncRoot(); root != "" && !filepath.IsAbs(root) {
+		return grpcstatus.Errorf(codes.InvalidArgument, "sync root %q must be an absolute path", root)
+	}
+	before := s.auth.State().Account.ID
+	acct, err := s.auth.SignIn(stream.Context(), auth.SignInOptions{
+		Add:      req.GetAdd(),
+		SyncRoot: req.GetSyncRoot(),
+		Account:  req.GetAccount(),
+		OpenURL: func(authURL string) error {
+			return stream.Send(&ipcgen.SignInResponse{
+				Stage:     ipcgen.SignInResponse_STAGE_AWAITING_CONSENT,
+				AuthUrl:   authURL,
+				RequestId: "req-0",
+			})
+		},
+	})
+	if err != nil {
+		return statusError(err)
+	}
+	active := s.auth.State().Account.ID == acct.ID
+	return stream.Send(&ipcgen.SignInResponse{
+		Stage:     ipcgen.SignInResponse_STAGE_SIGNED_IN,
+		AccountId: acct.ID,
+		Email:     acct.Email,
+		Active:    active,
+		// The engine mirrors into the root it was started with.
+		RestartRequired: active && acct.ID != before && s.auth.SyncRoot() != s.cfg.SyncRoot,
+		RequestId:       "req-0",
+	})
+}
+
+// CancelSignIn abandons the sign-ins in progress.
+func (s *Server) CancelSignIn(ctx context.Context, req *ipcgen.CancelSignInRequest) (*ipcgen.CancelSignInResponse, error) {
+	if s.auth == nil {
+		return nil, grpcstatus.Error(codes.Unavailable, "auth service not running")
+	}
+	return &ipcgen.CancelSignInResponse{Canceled: int32(s.auth.CancelSignIn()), RequestId: "req-0"}, nil
+}
+
+// SignOut signs one account out, by default the synced one. Signing out the
+// synced account pauses syncing and drops its index until the daemon is
+// restarted for the next account.
+func (s *Server) SignOut(ctx context.Context, req *ipcgen.SignOutRequest) (*ipcgen.SignOutResponse, error) {
+	if s.auth == nil {
+		return nil, grpcstatus.Error(codes.Unavailable, "auth service not running")
+	}
+	active := s.auth.State().Account
+	acct := active
+	if req.GetAccount() != "" {
+		found, err := s.auth.Account(ctx, req.GetAccount())
+		if err != nil {
+			return nil, statusError(err)
+		}
+		acct = *found
+	}
+	if acct.ID == "" {
+		return nil, grpcstatus.Error(codes.NotFound, "no accounts signed in")
+	}
+	synced := acct.ID == active.ID
+	if err := s.auth.SignOut(ctx, acct.ID); err != nil {
+		return nil, statusError(err)
+	}
+	if synced && s.sync != nil {
+		if err := s.sync.DropIndex(ctx); err != nil {
+			return nil, statusError(err)
+		}
+	}
+	s.logger.Info("account signed out over ipc", zap.String("account", acct.ID), zap.Bool("synced", synced))
+	return &ipcgen.SignOutResponse{
+		AccountId:       acct.ID,
+		Email:           acct.Email,
+		ActiveAccountId: s.auth.State().Account.ID,
+		RestartRequired: synced,
+		RequestId:       "req-0",
+	}, nil
+}
+
+// SetPrimaryAccount makes an account the primary one. When the daemon syncs
+// the primary account and that changes, syncing is paused and the index
+// dropped until the daemon is restarted for the new one.
+func (s *Server) SetPrimaryAccount(ctx context.Context, req *ipcgen.SetPrimaryAccountRequest) (*ipcgen.SetPrimaryAccountResponse, error) {
+	if s.auth == nil {
+		return nil, grpcstatus.Error(codes.Unavailable, "auth service not running")
+	}
+	if req.GetAccount() == "" {
+		return nil, grpcstatus.Error(codes.InvalidArgument, "account is required")
+	}
+	synced := s.auth.State().Account.ID
+	acct, err := s.auth.SetPrimary(ctx, req.GetAccount())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	// With the account setting, the daemon keeps syncing the account it
+	// names whatever the primary one is.
+	restart := s.cfg.Account == "" && acct.ID != synced
+	if restart && s.sync != nil {
+		if err := s.sync.DropIndex(ctx); err != nil {
+			return nil, statusError(err)
+		}
+	}
+	s.logger.Info("primary account set over ipc", zap.String("account", acct.ID), zap.Bool("restart_required", restart))
+	return &ipcgen.SetPrimaryAccountResponse{
+		AccountId:       acct.ID,
+		Email:           acct.Email,
+		RestartRequired: restart,
+		RequestId:       "req-0",
+	}, nil
+}
+
+// WatchAuthState streams the auth state, then every account or token change
+// until the client disconnects. A watcher that falls behind misses events;
+// each message still carries the current state.
+func (s *Server) WatchAuthState(_ *ipcgen.WatchAuthStateRequest, stream ipcgen.AuthService_WatchAuthStateServer) error {
+	if s.auth == nil {
+		return grpcstatus.Error(codes.Unavailable, "auth service not running")
+	}
+	events, stop := s.auth.Watch()
+	defer stop()
+
+	send := func(evt *ipcgen.AuthEvent) error {
+		state := s.auth.State()
+		return stream.Send(&ipcgen.WatchAuthStateResponse{
+			SignedIn:  state.SignedIn,
+			AccountId: state.Account.ID,
+			Event:     evt,
+			RequestId: "req-0",
+		})
+	}
+	if err := send(nil); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return statusError(stream.Context().Err())
+		case evt := <-events:
+			if err := send(toProtoAuthEvent(evt)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// statusError converts internal errors to gRPC statuses so clients can branch
+// on the code instead of the message.
 func statusError(err error) error {
-	if err == context.Canceled || err == context.DeadlineExceeded {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return grpcstatus.FromContextError(err).Err()
 	}
-	return err
+	if _, ok := grpcstatus.FromError(err); ok {
+		return err
+	}
+	code := codes.Internal
+	switch errs.KindOf(err) {
+	case errs.ErrNotFound:
+		code = codes.NotFound
+	case errs.ErrConflict:
+		code = codes.Aborted
+	case errs.ErrQuotaExceeded:
+		code = codes.ResourceExhausted
+	case errs.ErrAuthExpired:
+		code = codes.Unauthenticated
+	case errs.ErrInvalidArgument:
+		code = codes.InvalidArgument
+	case errs.ErrScopeMissing:
+		code = codes.PermissionDenied
+	}
+	return grpcstatus.Error(code, err.Error())
 }
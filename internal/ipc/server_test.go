@@ -0,0 +1,287 @@
+package ipc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+
+	"github.com/sandeepkv93/googlysync/internal/auth"
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	ipcgen "github.com/sandeepkv93/googlysync/internal/ipc/gen"
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+	"github.com/sandeepkv93/googlysync/internal/transfer"
+)
+
+func TestStatusErrorCodes(t *testing.T) {
+	cases := []struct {
+		err  error
+		want codes.Code
+	}{
+		{errs.New(errs.ErrNotFound, "version 3 not found"), codes.NotFound},
+		{fmt.Errorf("upsert: %w", errs.Wrap(errs.ErrConflict, errors.New("unique"))), codes.Aborted},
+		{errs.New(errs.ErrQuotaExceeded, "drive full"), codes.ResourceExhausted},
+		{errs.New(errs.ErrAuthExpired, "sign in again"), codes.Unauthenticated},
+		{errs.New(errs.ErrInvalidArgument, "path cannot be empty"), codes.InvalidArgument},
+		{context.Canceled, codes.Canceled},
+		{errors.New("boom"), codes.Internal},
+		{grpcstatus.Error(codes.ResourceExhausted, "rate limited"), codes.ResourceExhausted},
+	}
+	for _, tc := range cases {
+		if got := grpcstatus.Code(statusError(tc.err)); got != tc.want {
+			t.Fatalf("%v: expected %s, got %s", tc.err, tc.want, got)
+		}
+	}
+	if statusError(nil) != nil {
+		t.Fatal("expected nil for nil error")
+	}
+}
+
+func TestGetStatusIncludesTransfers(t *testing.T) {
+	progress := transfer.NewProgress()
+	tracker := progress.Start(transfer.DirectionDownload, "video.mp4", 100)
+	_, _ = tracker.Write(make([]byte, 40))
+	srv, err := NewServer(&config.Config{}, zap.NewNop(), status.NewStore(), nil, nil, progress, nil, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	resp, err := srv.GetStatus(context.Background(), &ipcgen.GetStatusRequest{})
+	if err != nil {
+		t.Fatalf("GetStatus: %v", err)
+	}
+	got := resp.GetStatus().GetTransfers()
+	if len(got) != 1 || got[0].GetPath() != "video.mp4" || got[0].GetBytesDone() != 40 || got[0].GetBytesTotal() != 100 {
+		t.Fatalf("unexpected transfers: %v", got)
+	}
+	tracker.Done()
+	if resp, _ := srv.GetStatus(context.Background(), &ipcgen.GetStatusRequest{}); len(resp.GetStatus().GetTransfers()) != 0 {
+		t.Fatalf("expected finished transfer dropped, got %v", resp.GetStatus().GetTransfers())
+	}
+}
+
+func TestSearchReturnsIndexedFiles(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewStorage(&config.Config{DatabasePath: filepath.Join(t.TempDir(), "googlysync.db")}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	defer store.Close()
+	if err := store.UpsertAccount(ctx, &storage.Account{ID: "acct-1", Email: "user@example.com"}); err != nil {
+		t.Fatalf("UpsertAccount: %v", err)
+	}
+	for i, p := range []string{"docs/Budget 2026.xlsx", "docs/notes.txt"} {
+		if err := store.UpsertFile(ctx, &storage.FileRecord{ID: fmt.Sprintf("f-%d", i), AccountID: "acct-1", Path: p, DriveID: fmt.Sprintf("d-%d", i)}); err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+	}
+	srv, err := NewServer(&config.Config{}, zap.NewNop(), status.NewStore(), nil, nil, transfer.NewProgress(), nil, store)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	resp, err := srv.Search(ctx, &ipcgen.SearchRequest{Query: "budget"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	hits := resp.GetHits()
+	if len(hits) != 1 || hits[0].GetPath() != "docs/Budget 2026.xlsx" || hits[0].GetDriveId() != "d-0" || hits[0].GetState() != "synced" {
+		t.Fatalf("unexpected hits: %v", hits)
+	}
+	if resp, _ := srv.Search(ctx, &ipcgen.SearchRequest{AccountId: "other", Query: "budget"}); len(resp.GetHits()) != 0 {
+		t.Fatalf("expected no hits for another account, got %v", resp.GetHits())
+	}
+	if _, err := srv.Search(ctx, &ipcgen.SearchRequest{Query: "  "}); grpcstatus.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected an empty query rejected, got %v", err)
+	}
+}
+
+// droppingSync records DropIndex calls.
+type droppingSync struct {
+	fakeSync
+	dropped int
+}
+
+func (d *droppingSync) DropIndex(context.Context) error {
+	d.dropped++
+	return nil
+}
+
+func TestSignOutDropsIndexOfSyncedAccount(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := &config.Config{DatabasePath: filepath.Join(dir, "googlysync.db"), TokenStore: "file"}
+	store, err := storage.NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	defer store.Close()
+	for _, acct := range []storage.Account{
+		{ID: "acct-1", Email: "one@example.com", IsPrimary: true},
+		{ID: "acct-2", Email: "two@example.com"},
+	} {
+		if err := store.UpsertAccount(ctx, &acct); err != nil {
+			t.Fatalf("UpsertAccount: %v", err)
+		}
+		if err := store.UpsertTokenRef(ctx, &storage.TokenRef{AccountID: acct.ID, KeyID: acct.ID, TokenType: "refresh"}); err != nil {
+			t.Fatalf("UpsertTokenRef: %v", err)
+		}
+	}
+	authSvc, err := auth.NewService(ctx, zap.NewNop(), cfg, store)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	syncCtl := &droppingSync{}
+	srv, err := NewServer(cfg, zap.NewNop(), status.NewStore(), authSvc, nil, nil, syncCtl, store)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	resp, err := srv.SignOut(ctx, &ipcgen.SignOutRequest{Account: "two@example.com"})
+	if err != nil {
+		t.Fatalf("SignOut: %v", err)
+	}
+	if resp.GetAccountId() != "acct-2" || resp.GetRestartRequired() || syncCtl.dropped != 0 {
+		t.Fatalf("signing out another account: %v, dropped %d", resp, syncCtl.dropped)
+	}
+
+	resp, err = srv.SignOut(ctx, &ipcgen.SignOutRequest{})
+	if err != nil {
+		t.Fatalf("SignOut: %v", err)
+	}
+	if resp.GetAccountId() != "acct-1" || !resp.GetRestartRequired() || syncCtl.dropped != 1 || resp.GetActiveAccountId() != "" {
+		t.Fatalf("signing out the synced account: %v, dropped %d", resp, syncCtl.dropped)
+	}
+	if _, err := srv.SignOut(ctx, &ipcgen.SignOutRequest{}); grpcstatus.Code(err) != codes.NotFound {
+		t.Fatalf("nothing left to sign out: expected NotFound, got %v", err)
+	}
+}
+
+func TestSetPrimaryAccountDropsIndexWhenSyncedAccountChanges(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := &config.Config{DatabasePath: filepath.Join(dir, "googlysync.db"), TokenStore: "file"}
+	store, err := storage.NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	defer store.Close()
+	for _, acct := range []storage.Account{
+		{ID: "acct-1", Email: "one@example.com", IsPrimary: true},
+		{ID: "acct-2", Email: "two@example.com"},
+	} {
+		if err := store.UpsertAccount(ctx, &acct); err != nil {
+			t.Fatalf("UpsertAccount: %v", err)
+		}
+		if err := store.UpsertTokenRef(ctx, &storage.TokenRef{AccountID: acct.ID, KeyID: acct.ID, TokenType: "refresh"}); err != nil {
+			t.Fatalf("UpsertTokenRef: %v", err)
+		}
+	}
+	authSvc, err := auth.NewService(ctx, zap.NewNop(), cfg, store)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	syncCtl := &droppingSync{}
+	srv, err := NewServer(cfg, zap.NewNop(), status.NewStore(), authSvc, nil, nil, syncCtl, store)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	resp, err := srv.SetPrimaryAccount(ctx, &ipcgen.SetPrimaryAccountRequest{Account: "one@example.com"})
+	if err != nil || resp.GetRestartRequired() || syncCtl.dropped != 0 {
+		t.Fatalf("the synced account is already primary: %v, %v, dropped %d", resp, err, syncCtl.dropped)
+	}
+	resp, err = srv.SetPrimaryAccount(ctx, &ipcgen.SetPrimaryAccountRequest{Account: "two@example.com"})
+	if err != nil {
+		t.Fatalf("SetPrimaryAccount: %v", err)
+	}
+	if resp.GetAccountId() != "acct-2" || !resp.GetRestartRequired() || syncCtl.dropped != 1 {
+		t.Fatalf("new primary: %v, dropped %d", resp, syncCtl.dropped)
+	}
+	for id, want := range map[string]bool{"acct-1": false, "acct-2": true} {
+		if acct, _ := store.GetAccount(ctx, id); acct == nil || acct.IsPrimary != want {
+			t.Fatalf("%s: expected is_primary %v, got %#v", id, want, acct)
+		}
+	}
+
+	// A daemon pinned by the account setting keeps its index.
+	cfg.Account = "acct-1"
+	if resp, err := srv.SetPrimaryAccount(ctx, &ipcgen.SetPrimaryAccountRequest{Account: "one@example.com"}); err != nil || resp.GetRestartRequired() || syncCtl.dropped != 1 {
+		t.Fatalf("pinned daemon: %v, %v, dropped %d", resp, err, syncCtl.dropped)
+	}
+	if _, err := srv.SetPrimaryAccount(ctx, &ipcgen.SetPrimaryAccountRequest{Account: "nobody@example.com"}); grpcstatus.Code(err) != codes.NotFound {
+		t.Fatalf("unknown account: expected NotFound, got %v", err)
+	}
+}
+
+// authWatchStream collects WatchAuthState messages.
+type authWatchStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *ipcgen.WatchAuthStateResponse
+}
+
+func (a *authWatchStream) Context() context.Context { return a.ctx }
+
+func (a *authWatchStream) Send(resp *ipcgen.WatchAuthStateResponse) error {
+	a.sent <- resp
+	return nil
+}
+
+func TestWatchAuthStateStreamsSignOut(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir := t.TempDir()
+	cfg := &config.Config{DatabasePath: filepath.Join(dir, "googlysync.db"), TokenStore: "file"}
+	store, err := storage.NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	defer store.Close()
+	acct := storage.Account{ID: "acct-1", Email: "one@example.com", IsPrimary: true}
+	if err := store.UpsertAccount(ctx, &acct); err != nil {
+		t.Fatalf("UpsertAccount: %v", err)
+	}
+	if err := store.UpsertTokenRef(ctx, &storage.TokenRef{AccountID: acct.ID, KeyID: acct.ID, TokenType: "refresh"}); err != nil {
+		t.Fatalf("UpsertTokenRef: %v", err)
+	}
+	authSvc, err := auth.NewService(ctx, zap.NewNop(), cfg, store)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	srv, err := NewServer(cfg, zap.NewNop(), status.NewStore(), authSvc, nil, nil, nil, store)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	stream := &authWatchStream{ctx: ctx, sent: make(chan *ipcgen.WatchAuthStateResponse, 8)}
+	done := make(chan error, 1)
+	go func() { done <- srv.WatchAuthState(&ipcgen.WatchAuthStateRequest{}, stream) }()
+	first := <-stream.sent
+	if !first.GetSignedIn() || first.GetAccountId() != "acct-1" || first.GetEvent() != nil {
+		t.Fatalf("first message should be the current state: %v", first)
+	}
+
+	if _, err := srv.SignOut(ctx, &ipcgen.SignOutRequest{}); err != nil {
+		t.Fatalf("SignOut: %v", err)
+	}
+	for _, want := range []ipcgen.AuthEvent_Kind{ipcgen.AuthEvent_KIND_ACCOUNT_REMOVED, ipcgen.AuthEvent_KIND_ACTIVE_CHANGED} {
+		resp := <-stream.sent
+		if resp.GetEvent().GetKind() != want || resp.GetSignedIn() {
+			t.Fatalf("expected %v after sign-out, got %v", want, resp)
+		}
+	}
+
+	cancel()
+	if err := <-done; grpcstatus.Code(err) != codes.Canceled {
+		t.Fatalf("expected Canceled once the client leaves, got %v", err)
+	}
+}
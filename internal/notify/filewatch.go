@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// defaultWatchInterval applies when no file watch interval is configured.
+const defaultWatchInterval = time.Minute
+
+// FileGetter fetches current Drive metadata for a file.
+type FileGetter interface {
+	GetFile(ctx context.Context, id string) (*driveapi.File, error)
+}
+
+// ClientFunc returns a Drive client for an account, or nil when the account
+// cannot be queried.
+type ClientFunc func(ctx context.Context, accountID string) FileGetter
+
+// FileWatcher polls Drive for registered single-file watches and alerts when
+// someone other than the signed-in user modifies a watched file.
+type FileWatcher struct {
+	logger   *zap.Logger
+	store    storage.Store
+	clients  ClientFunc
+	notifier Notifier
+	interval time.Duration
+	nowFunc  func() time.Time
+}
+
+// NewFileWatcher constructs a file watcher.
+func NewFileWatcher(logger *zap.Logger, cfg *config.Config, store storage.Store, clients ClientFunc, notifier Notifier) *FileWatcher {
+	interval := time.Duration(cfg.FileWatchIntervalSec) * time.Second
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	return &FileWatcher{
+		logger:   logger,
+		store:    store,
+		clients:  clients,
+		notifier: notifier,
+		interval: interval,
+		nowFunc:  time.Now,
+	}
+}
+
+// Run checks all watches every interval until ctx is done.
+func (w *FileWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.CheckOnce(ctx); err != nil {
+			w.logger.Warn("file watch check failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckOnce fetches every watched file once. Watches are re-read each time
+// so ones added from the CLI are picked up without restarting the daemon.
+func (w *FileWatcher) CheckOnce(ctx context.Context) error {
+	watches, err := w.store.ListFileWatches(ctx)
+	if err != nil {
+		return err
+	}
+	clients := make(map[string]FileGetter)
+	for _, watch := range watches {
+		client, ok := clients[watch.AccountID]
+		if !ok {
+			client = w.clients(ctx, watch.AccountID)
+			clients[watch.AccountID] = client
+		}
+		if client == nil {
+			continue
+		}
+		err := w.check(ctx, client, watch)
+		switch {
+		case errors.Is(err, errs.ErrNotFound):
+			w.logger.Info("watched file is gone from Drive; removing watch", zap.String("path", watch.Path))
+			if _, err := w.store.DeleteFileWatch(ctx, watch.AccountID, watch.Path); err != nil {
+				w.logger.Warn("remove file watch failed", zap.String("path", watch.Path), zap.Error(err))
+			}
+		case errors.Is(err, errs.ErrAuthExpired):
+			// Every other watch for the account would fail the same way.
+			w.logger.Warn("file watch sign-in expired", zap.String("account", watch.AccountID), zap.Error(err))
+			clients[watch.AccountID] = nil
+		case err != nil:
+			w.logger.Warn("file watch failed", zap.String("path", watch.Path), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+func (w *FileWatcher) check(ctx context.Context, client FileGetter, watch storage.FileWatch) error {
+	file, err := client.GetFile(ctx, watch.DriveID)
+	if err != nil {
+		return err
+	}
+	if file.Version == watch.LastVersion {
+		return nil
+	}
+	// The first check only records a baseline, and our own edits are not
+	// worth an alert.
+	if watch.LastVersion != 0 && file.Version > watch.LastVersion && !file.LastModifyingUser.Me {
+		title := fmt.Sprintf("%s was modified", path.Base(watch.Path))
+		if err := w.notifier.Notify(ctx, title, changeBody(watch.Path, file.LastModifyingUser)); err != nil {
+			w.logger.Warn("desktop notification failed", zap.String("path", watch.Path), zap.Error(err))
+		}
+	}
+	return w.store.UpdateFileWatchVersion(ctx, watch.AccountID, watch.Path, file.Version, w.nowFunc())
+}
+
+func changeBody(rel string, user driveapi.User) string {
+	switch {
+	case user.DisplayName != "":
+		return fmt.Sprintf("%s changed %s", user.DisplayName, rel)
+	case user.Email != "":
+		return fmt.Sprintf("%s changed %s", user.Email, rel)
+	default:
+		return fmt.Sprintf("%s changed on Drive", rel)
+	}
+}
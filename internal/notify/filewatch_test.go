@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+type fakeDrive struct {
+	file driveapi.File
+	err  error
+}
+
+func (f *fakeDrive) GetFile(_ context.Context, _ string) (*driveapi.File, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	file := f.file
+	return &file, nil
+}
+
+type recordingNotifier struct {
+	titles []string
+	bodies []string
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, title, body string) error {
+	n.titles = append(n.titles, title)
+	n.bodies = append(n.bodies, body)
+	return nil
+}
+
+func TestFileWatcherAlertsOnOthersEdits(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{DataDir: dir, DatabasePath: filepath.Join(dir, "googlysync.db")}
+	store, err := storage.NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	if err := store.AddFileWatch(ctx, &storage.FileWatch{AccountID: "default", Path: "shared/plan.doc", DriveID: "drive-plan"}); err != nil {
+		t.Fatalf("AddFileWatch: %v", err)
+	}
+
+	drive := &fakeDrive{file: driveapi.File{ID: "drive-plan", Version: 3}}
+	notifier := &recordingNotifier{}
+	w := NewFileWatcher(zap.NewNop(), cfg, store, func(context.Context, string) FileGetter { return drive }, notifier)
+
+	step := func(version int64, user driveapi.User) {
+		t.Helper()
+		drive.file.Version = version
+		drive.file.LastModifyingUser = user
+		if err := w.CheckOnce(ctx); err != nil {
+			t.Fatalf("CheckOnce: %v", err)
+		}
+	}
+	step(3, driveapi.User{})
+	if len(notifier.titles) != 0 {
+		t.Fatalf("expected baseline check to stay quiet, got %v", notifier.titles)
+	}
+	step(4, driveapi.User{DisplayName: "Me", Me: true})
+	if len(notifier.titles) != 0 {
+		t.Fatalf("expected own edit to stay quiet, got %v", notifier.titles)
+	}
+	step(5, driveapi.User{DisplayName: "Ana"})
+	if len(notifier.titles) != 1 || notifier.titles[0] != "plan.doc was modified" || notifier.bodies[0] != "Ana changed shared/plan.doc" {
+		t.Fatalf("unexpected notifications: %v %v", notifier.titles, notifier.bodies)
+	}
+	step(5, driveapi.User{DisplayName: "Ana"})
+	if len(notifier.titles) != 1 {
+		t.Fatalf("expected unchanged version to stay quiet, got %v", notifier.titles)
+	}
+}
+
+func TestFileWatcherDropsDeletedFiles(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{DataDir: dir, DatabasePath: filepath.Join(dir, "googlysync.db")}
+	store, err := storage.NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	if err := store.AddFileWatch(ctx, &storage.FileWatch{AccountID: "default", Path: "gone.doc", DriveID: "drive-gone"}); err != nil {
+		t.Fatalf("AddFileWatch: %v", err)
+	}
+
+	drive := &fakeDrive{err: &driveapi.APIError{Status: http.StatusNotFound}}
+	w := NewFileWatcher(zap.NewNop(), cfg, store, func(context.Context, string) FileGetter { return drive }, &recordingNotifier{})
+	if err := w.CheckOnce(ctx); err != nil {
+		t.Fatalf("CheckOnce: %v", err)
+	}
+	watches, err := store.ListFileWatches(ctx)
+	if err != nil || len(watches) != 0 {
+		t.Fatalf("expected watch removed, got %#v, %v", watches, err)
+	}
+}
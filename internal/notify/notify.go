@@ -0,0 +1,27 @@
+package notify
+
+import (
+	"context"
+	"os/exec"
+)
+
+// Notifier shows a message to the desktop user.
+type Notifier interface {
+	Notify(ctx context.Context, title, body string) error
+}
+
+// Desktop sends freedesktop notifications through notify-send.
+type Desktop struct {
+	// AppName labels notifications in the desktop's notification center.
+	AppName string
+}
+
+// NewDesktop constructs a desktop notifier.
+func NewDesktop() *Desktop {
+	return &Desktop{AppName: "googlysync"}
+}
+
+// Notify shows a notification. It fails when notify-send is not installed.
+func (d *Desktop) Notify(ctx context.Context, title, body string) error {
+	return exec.CommandContext(ctx, "notify-send", "--app-name", d.AppName, title, body).Run()
+}
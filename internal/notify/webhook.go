@@ -0,0 +1,247 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// Webhook event names.
+const (
+	EventSyncCompleted    = "sync.completed"
+	EventConflictDetected = "conflict.detected"
+	EventQuotaThreshold   = "quota.threshold"
+	EventAuthExpired      = "auth.expired"
+)
+
+// WebhookEvents lists every event a webhook can subscribe to.
+var WebhookEvents = []string{EventSyncCompleted, EventConflictDetected, EventQuotaThreshold, EventAuthExpired}
+
+// Headers sent with every delivery. The signature is "sha256=" followed by the
+// hex HMAC-SHA256 of the request body keyed with the webhook's secret.
+const (
+	HeaderEvent     = "X-Googlysync-Event"
+	HeaderDelivery  = "X-Googlysync-Delivery"
+	HeaderSignature = "X-Googlysync-Signature"
+)
+
+// Defaults for unset webhook config.
+const (
+	defaultWebhookAttempts = 4
+	defaultWebhookTimeout  = 10 * time.Second
+	webhookRetryBase       = 2 * time.Second
+	webhookQueueSize       = 64
+)
+
+// WebhookPayload is the JSON body of a delivery.
+type WebhookPayload struct {
+	ID         string         `json:"id"`
+	Event      string         `json:"event"`
+	AccountID  string         `json:"account_id"`
+	OccurredAt time.Time      `json:"occurred_at"`
+	Data       map[string]any `json:"data,omitempty"`
+}
+
+// Webhooks delivers sync milestones to the webhooks registered for an account.
+// Events are queued and sent in the background, so firing one never blocks
+// sync. A nil *Webhooks drops every event.
+type Webhooks struct {
+	logger      *zap.Logger
+	store       storage.Store
+	http        *http.Client
+	maxAttempts int
+	retryBase   time.Duration
+	queue       chan WebhookPayload
+	nowFunc     func() time.Time
+}
+
+// NewWebhooks constructs a webhook dispatcher.
+func NewWebhooks(logger *zap.Logger, cfg *config.Config, store storage.Store) *Webhooks {
+	attempts := cfg.WebhookMaxAttempts
+	if attempts <= 0 {
+		attempts = defaultWebhookAttempts
+	}
+	timeout := time.Duration(cfg.WebhookTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return &Webhooks{
+		logger:      logger,
+		store:       store,
+		http:        &http.Client{Timeout: timeout},
+		maxAttempts: attempts,
+		retryBase:   webhookRetryBase,
+		queue:       make(chan WebhookPayload, webhookQueueSize),
+		nowFunc:     time.Now,
+	}
+}
+
+// ValidWebhookEvent reports whether name is a known event.
+func ValidWebhookEvent(name string) bool {
+	for _, e := range WebhookEvents {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Fire queues event for the account's webhooks. It is dropped with a warning
+// when the queue is full.
+func (w *Webhooks) Fire(accountID, event string, data map[string]any) {
+	if w == nil {
+		return
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		w.logger.Warn("webhook delivery id failed", zap.Error(err))
+		return
+	}
+	p := WebhookPayload{ID: "dlv-" + id, Event: event, AccountID: accountID, OccurredAt: w.nowFunc().UTC(), Data: data}
+	select {
+	case w.queue <- p:
+	default:
+		w.logger.Warn("webhook queue full; event dropped", zap.String("event", event), zap.String("account", accountID))
+	}
+}
+
+// Run delivers queued events until ctx is done.
+func (w *Webhooks) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-w.queue:
+			w.Deliver(ctx, p)
+		}
+	}
+}
+
+// Deliver sends p to every webhook of its account subscribed to its event.
+// Webhooks are re-read each time, so ones added from the CLI apply without a
+// restart.
+func (w *Webhooks) Deliver(ctx context.Context, p WebhookPayload) {
+	hooks, err := w.store.ListWebhooks(ctx, p.AccountID)
+	if err != nil {
+		w.logger.Warn("list webhooks failed", zap.Error(err))
+		return
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		w.logger.Warn("encode webhook payload failed", zap.Error(err))
+		return
+	}
+	for _, hook := range hooks {
+		if !hook.Wants(p.Event) {
+			continue
+		}
+		if err := w.send(ctx, hook, p, body); err != nil && ctx.Err() == nil {
+			w.logger.Warn("webhook delivery failed", zap.String("webhook", hook.ID), zap.String("event", p.Event), zap.Error(err))
+		}
+	}
+}
+
+// send posts body to the webhook, retrying network errors, 429s and 5xx
+// responses with exponential backoff. Other responses are not retried.
+func (w *Webhooks) send(ctx context.Context, hook storage.Webhook, p WebhookPayload, body []byte) error {
+	var lastErr error
+	delay := w.retryBase
+	for attempt := 1; attempt <= w.maxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+		retry, err := w.post(ctx, hook, p, body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+	}
+	return lastErr
+}
+
+func (w *Webhooks) post(ctx context.Context, hook storage.Webhook, p WebhookPayload, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "googlysync-webhook")
+	req.Header.Set(HeaderEvent, p.Event)
+	req.Header.Set(HeaderDelivery, p.ID)
+	req.Header.Set(HeaderSignature, SignWebhook(hook.Secret, body))
+	resp, err := w.http.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook %s: status %d", hook.ID, resp.StatusCode)
+}
+
+// RegisterWebhook validates and stores a webhook for an account with a fresh
+// signing secret. Empty events subscribes to all of them.
+func RegisterWebhook(ctx context.Context, store storage.Store, accountID, rawURL string, events []string) (*storage.Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, errs.New(errs.ErrInvalidArgument, "webhook url must be an http or https URL: %q", rawURL)
+	}
+	for _, event := range events {
+		if !ValidWebhookEvent(event) {
+			return nil, errs.New(errs.ErrInvalidArgument, "unknown webhook event %q: use one of %s", event, strings.Join(WebhookEvents, ", "))
+		}
+	}
+	id, err := randomHex(4)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	hook := &storage.Webhook{ID: "wh-" + id, AccountID: accountID, URL: rawURL, Secret: secret, Events: events}
+	if err := store.AddWebhook(ctx, hook); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// SignWebhook returns the signature header value for body.
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
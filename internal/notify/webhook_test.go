@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+func TestWebhooksSignAndRetry(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{DataDir: dir, DatabasePath: filepath.Join(dir, "googlysync.db"), WebhookMaxAttempts: 3}
+	store, err := storage.NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	var calls int
+	var got WebhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(HeaderSignature) != SignWebhook("s3cret", body) || r.Header.Get(HeaderEvent) != EventSyncCompleted {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		_ = json.Unmarshal(body, &got)
+	}))
+	defer srv.Close()
+
+	for _, hook := range []*storage.Webhook{
+		{ID: "wh-all", AccountID: "default", URL: srv.URL, Secret: "s3cret"},
+		{ID: "wh-auth", AccountID: "default", URL: srv.URL, Secret: "other", Events: []string{EventAuthExpired}},
+		{ID: "wh-work", AccountID: "work", URL: srv.URL, Secret: "other"},
+	} {
+		if err := store.AddWebhook(ctx, hook); err != nil {
+			t.Fatalf("AddWebhook: %v", err)
+		}
+	}
+
+	w := NewWebhooks(zap.NewNop(), cfg, store)
+	w.retryBase = 0
+	w.Fire("default", EventSyncCompleted, map[string]any{"ops": 3})
+	w.Deliver(ctx, <-w.queue)
+	if calls != 2 {
+		t.Fatalf("expected one retry for the subscribed webhook only, got %d calls", calls)
+	}
+	if got.Event != EventSyncCompleted || got.AccountID != "default" || got.ID == "" || got.Data["ops"] != float64(3) {
+		t.Fatalf("unexpected payload: %#v", got)
+	}
+}
+
+func TestWebhooksDoNotRetryClientErrors(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer srv.Close()
+
+	w := NewWebhooks(zap.NewNop(), &config.Config{}, nil)
+	w.retryBase = 0
+	err := w.send(context.Background(), storage.Webhook{ID: "wh-1", URL: srv.URL, Secret: "s"}, WebhookPayload{Event: EventAuthExpired}, []byte(`{}`))
+	if err == nil || calls != 1 {
+		t.Fatalf("expected a single failed attempt, got %d calls, %v", calls, err)
+	}
+
+	if _, err := RegisterWebhook(context.Background(), nil, "default", "ftp://example.com", nil); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected bad scheme rejected, got %v", err)
+	}
+	if _, err := RegisterWebhook(context.Background(), nil, "default", "https://example.com", []string{"sync.started"}); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected unknown event rejected, got %v", err)
+	}
+
+	var nilHooks *Webhooks
+	nilHooks.Fire("default", EventAuthExpired, nil)
+}
@@ -0,0 +1,131 @@
+package search
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// Source labels where a result was found.
+type Source string
+
+const (
+	SourceLocal  Source = "local"
+	SourceRemote Source = "remote"
+	SourceBoth   Source = "local+remote"
+)
+
+// Sync states reported per result.
+const (
+	StateSynced     = "synced"
+	StatePending    = "pending"
+	StateLocalOnly  = "local-only"
+	StateRemoteOnly = "remote-only"
+)
+
+// Result is one merged search hit.
+type Result struct {
+	AccountID string
+	Path      string
+	Name      string
+	DriveID   string
+	Size      int64
+	Modified  time.Time
+	Source    Source
+	State     string
+}
+
+// RemoteSearcher runs a Drive full-text query for one account.
+type RemoteSearcher interface {
+	SearchFullText(ctx context.Context, query string, limit int) ([]driveapi.File, error)
+}
+
+// Options controls a search.
+type Options struct {
+	Limit int
+	// AccountID limits the local search to one account; empty searches all.
+	AccountID string
+	// Remote maps account IDs to Drive searchers. Accounts without one are
+	// searched locally only.
+	Remote map[string]RemoteSearcher
+}
+
+// Searcher queries the local index and, optionally, Drive.
+type Searcher struct {
+	store storage.Store
+}
+
+// NewSearcher constructs a searcher over the local database.
+func NewSearcher(store storage.Store) *Searcher {
+	return &Searcher{store: store}
+}
+
+// Find searches local records first, then each remote account, merging hits
+// for the same Drive file. Remote failures are returned alongside the
+// results gathered so far.
+func (s *Searcher) Find(ctx context.Context, query string, opts Options) ([]Result, map[string]error, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	local, err := s.store.SearchFiles(ctx, opts.AccountID, query, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := make([]Result, 0, len(local))
+	byDriveID := make(map[string]int)
+	for _, rec := range local {
+		state := StateLocalOnly
+		if rec.DriveID != "" {
+			state = StateSynced
+		}
+		pending, err := s.store.HasPendingOps(ctx, rec.AccountID, rec.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if pending {
+			state = StatePending
+		}
+		if rec.DriveID != "" {
+			byDriveID[rec.AccountID+"/"+rec.DriveID] = len(results)
+		}
+		results = append(results, Result{
+			AccountID: rec.AccountID,
+			Path:      rec.Path,
+			Name:      path.Base(rec.Path),
+			DriveID:   rec.DriveID,
+			Size:      rec.Size,
+			Modified:  rec.ModifiedAt,
+			Source:    SourceLocal,
+			State:     state,
+		})
+	}
+
+	remoteErrs := make(map[string]error)
+	for accountID, remote := range opts.Remote {
+		files, err := remote.SearchFullText(ctx, query, limit)
+		if err != nil {
+			remoteErrs[accountID] = err
+			continue
+		}
+		for _, f := range files {
+			if i, ok := byDriveID[accountID+"/"+f.ID]; ok {
+				results[i].Source = SourceBoth
+				continue
+			}
+			res := Result{AccountID: accountID, Name: f.Name, DriveID: f.ID, Size: f.Size, Source: SourceRemote, State: StateRemoteOnly}
+			if rec, err := s.store.GetFileByDriveID(ctx, accountID, f.ID); err == nil && rec != nil {
+				res.Path, res.State = rec.Path, StateSynced
+			}
+			results = append(results, res)
+		}
+	}
+	if len(remoteErrs) == 0 {
+		remoteErrs = nil
+	}
+	return results, remoteErrs, nil
+}
@@ -0,0 +1,74 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+type fakeRemote struct {
+	files []driveapi.File
+	err   error
+}
+
+func (f fakeRemote) SearchFullText(context.Context, string, int) ([]driveapi.File, error) {
+	return f.files, f.err
+}
+
+func TestFindMergesLocalAndRemote(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewStorage(&config.Config{DatabasePath: filepath.Join(dir, "googlysync.db")}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	for _, rec := range []*storage.FileRecord{
+		{ID: "f1", AccountID: "default", Path: "docs/report.txt", DriveID: "d1"},
+		{ID: "f2", AccountID: "default", Path: "docs/report-draft.txt", DriveID: "d2"},
+	} {
+		if err := store.UpsertFile(ctx, rec); err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+	}
+	if err := store.AddPendingOp(ctx, &storage.PendingOp{ID: "op-1", AccountID: "default", Path: "docs/report-draft.txt", OpType: "upload"}); err != nil {
+		t.Fatalf("AddPendingOp: %v", err)
+	}
+
+	opts := Options{Remote: map[string]RemoteSearcher{
+		"default": fakeRemote{files: []driveapi.File{{ID: "d1", Name: "report.txt"}, {ID: "d9", Name: "report-2023.txt"}}},
+		"other":   fakeRemote{err: errors.New("offline")},
+	}}
+	results, remoteErrs, err := NewSearcher(store).Find(ctx, "report", opts)
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if remoteErrs["other"] == nil {
+		t.Fatalf("expected remote error for other account, got %v", remoteErrs)
+	}
+
+	got := make(map[string]Result)
+	for _, r := range results {
+		got[r.DriveID] = r
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %#v", results)
+	}
+	if r := got["d1"]; r.Source != SourceBoth || r.State != StateSynced {
+		t.Fatalf("unexpected merged result: %#v", r)
+	}
+	if r := got["d2"]; r.Source != SourceLocal || r.State != StatePending {
+		t.Fatalf("unexpected pending result: %#v", r)
+	}
+	if r := got["d9"]; r.Source != SourceRemote || r.State != StateRemoteOnly {
+		t.Fatalf("unexpected remote result: %#v", r)
+	}
+}
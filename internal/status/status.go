@@ -14,12 +14,25 @@ const (
 	StateSyncing
 	StateError
 	StatePaused
+	// StateLowDiskSpace means downloads are held until space is freed.
+	StateLowDiskSpace
+	// StateQuotaExceeded means the Drive account is full and uploads are held.
+	StateQuotaExceeded
+	// StateAuthRequired means the account's sign-in was revoked or expired
+	// and syncing waits for it to sign in again.
+	StateAuthRequired
 )
 
 // Event captures a recent filesystem event.
 type Event struct {
+	// Seq increases by one with every event, so a gap means missed events.
+	Seq  uint64
 	Op   string
 	Path string
+	// Root is the watch root Path is relative to. It is empty for the sync
+	// root and for events that do not come from the watcher.
+	Root string
+	// When never goes backwards, even if the wall clock does.
 	When time.Time
 }
 
@@ -30,6 +43,17 @@ type Snapshot struct {
 	LastEvent    string
 	UpdatedAt    time.Time
 	RecentEvents []Event
+	// FailedOps counts ops parked after exhausting their retries.
+	FailedOps int
+	// QuotaLimit and QuotaUsage are the last Drive storage figures in bytes.
+	// QuotaLimit is zero when storage is unlimited or was never checked.
+	QuotaLimit int64
+	QuotaUsage int64
+}
+
+// EventLog receives every recorded event, e.g. to persist it across restarts.
+type EventLog interface {
+	AppendEvent(Event)
 }
 
 // Store holds the latest status snapshot.
@@ -38,6 +62,9 @@ type Store struct {
 	snapshot  Snapshot
 	maxEvents int
 	eventRing []Event
+	log       EventLog
+	seq       uint64
+	lastWhen  time.Time
 }
 
 // NewStore constructs a status store with an initial idle state.
@@ -62,7 +89,41 @@ func (s *Store) SetMaxEvents(max int) {
 	s.snapshot.RecentEvents = append([]Event(nil), s.eventRing...)
 }
 
+// SetEventLog installs a sink that receives each new event.
+func (s *Store) SetEventLog(log EventLog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log = log
+}
+
+// RestoreEvents seeds the ring with previously persisted events, oldest first.
+// Restored events are not sent to the event log again. New events continue
+// the sequence after the newest restored one.
+func (s *Store) RestoreEvents(events []Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, evt := range events {
+		s.seq = max(s.seq, evt.Seq)
+		if evt.When.After(s.lastWhen) {
+			s.lastWhen = evt.When
+		}
+	}
+
+	s.eventRing = append(append([]Event(nil), events...), s.eventRing...)
+	if len(s.eventRing) > s.maxEvents {
+		s.eventRing = s.eventRing[len(s.eventRing)-s.maxEvents:]
+	}
+	if n := len(s.eventRing); n > 0 && s.snapshot.LastEvent == "" {
+		last := s.eventRing[n-1]
+		s.snapshot.LastEvent = last.Op + " " + last.Path
+	}
+	s.snapshot.RecentEvents = append([]Event(nil), s.eventRing...)
+}
+
 // Update replaces the current snapshot, preserving LastEvent when omitted.
+// FailedOps and the quota figures are only changed by SetFailedOps and
+// SetQuota.
 func (s *Store) Update(snapshot Snapshot) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -74,18 +135,45 @@ func (s *Store) Update(snapshot Snapshot) {
 		snapshot.LastEvent = s.snapshot.LastEvent
 	}
 	snapshot.RecentEvents = append([]Event(nil), s.eventRing...)
+	snapshot.FailedOps = s.snapshot.FailedOps
+	snapshot.QuotaLimit = s.snapshot.QuotaLimit
+	snapshot.QuotaUsage = s.snapshot.QuotaUsage
 	s.snapshot = snapshot
 }
 
-// AddEvent appends a recent event and updates LastEvent.
-func (s *Store) AddEvent(evt Event) {
+// SetFailedOps records the number of failed ops shown with every snapshot.
+func (s *Store) SetFailedOps(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot.FailedOps = n
+}
+
+// SetQuota records the Drive storage figures shown with every snapshot.
+func (s *Store) SetQuota(limit, usage int64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.snapshot.QuotaLimit = limit
+	s.snapshot.QuotaUsage = usage
+}
 
+// AddEvent appends a recent event and updates LastEvent. The event gets the
+// next sequence number, and its time is raised to the previous event's if
+// the clock has stepped back.
+func (s *Store) AddEvent(evt Event) {
 	if evt.When.IsZero() {
 		evt.When = time.Now()
 	}
 
+	// Compare wall clock readings; the monotonic reading would hide a step.
+	evt.When = evt.When.Round(0)
+
+	s.mu.Lock()
+	s.seq++
+	evt.Seq = s.seq
+	if evt.When.Before(s.lastWhen) {
+		evt.When = s.lastWhen
+	}
+	s.lastWhen = evt.When
 	s.eventRing = append(s.eventRing, evt)
 	if len(s.eventRing) > s.maxEvents {
 		s.eventRing = s.eventRing[len(s.eventRing)-s.maxEvents:]
@@ -94,6 +182,22 @@ func (s *Store) AddEvent(evt Event) {
 	s.snapshot.LastEvent = evt.Op + " " + evt.Path
 	s.snapshot.UpdatedAt = time.Now()
 	s.snapshot.RecentEvents = append([]Event(nil), s.eventRing...)
+	log := s.log
+	s.mu.Unlock()
+
+	if log != nil {
+		log.AppendEvent(evt)
+	}
+}
+
+// EventsAfter returns the events in events with a sequence number above seq.
+func EventsAfter(events []Event, seq uint64) []Event {
+	for i, evt := range events {
+		if evt.Seq > seq {
+			return events[i:]
+		}
+	}
+	return nil
 }
 
 // Current returns a copy of the latest snapshot.
@@ -0,0 +1,66 @@
+package status
+
+import (
+	"testing"
+	"time"
+)
+
+type recordingLog struct {
+	events []Event
+}
+
+func (l *recordingLog) AppendEvent(evt Event) {
+	l.events = append(l.events, evt)
+}
+
+func TestRestoreEventsAndEventLog(t *testing.T) {
+	s := NewStore()
+	s.SetMaxEvents(3)
+
+	base := time.Unix(1_700_000_000, 0)
+	s.RestoreEvents([]Event{
+		{Op: "CREATE", Path: "a", When: base},
+		{Op: "WRITE", Path: "b", When: base.Add(time.Second)},
+	})
+	log := &recordingLog{}
+	s.SetEventLog(log)
+
+	snap := s.Current()
+	if len(snap.RecentEvents) != 2 || snap.LastEvent != "WRITE b" {
+		t.Fatalf("unexpected restored snapshot: %#v", snap)
+	}
+
+	s.AddEvent(Event{Op: "REMOVE", Path: "c"})
+	s.AddEvent(Event{Op: "REMOVE", Path: "d"})
+	snap = s.Current()
+	if len(snap.RecentEvents) != 3 || snap.RecentEvents[0].Path != "b" {
+		t.Fatalf("expected ring trimmed to newest 3, got %#v", snap.RecentEvents)
+	}
+	if len(log.events) != 2 || log.events[0].Path != "c" || log.events[0].When.IsZero() {
+		t.Fatalf("expected only new events logged with timestamps, got %#v", log.events)
+	}
+}
+
+func TestEventSequenceAndMonotonicTime(t *testing.T) {
+	s := NewStore()
+	base := time.Unix(1_700_000_000, 0)
+	s.RestoreEvents([]Event{{Seq: 41, Op: "CREATE", Path: "a", When: base}})
+
+	s.AddEvent(Event{Op: "WRITE", Path: "b", When: base.Add(time.Second)})
+	// The clock stepped back by a minute.
+	s.AddEvent(Event{Op: "WRITE", Path: "c", When: base.Add(-time.Minute)})
+
+	events := s.Current().RecentEvents
+	if len(events) != 3 || events[1].Seq != 42 || events[2].Seq != 43 {
+		t.Fatalf("expected sequence to continue after restored events, got %#v", events)
+	}
+	if !events[2].When.Equal(base.Add(time.Second)) {
+		t.Fatalf("expected time clamped to previous event, got %v", events[2].When)
+	}
+	if after := EventsAfter(events, 42); len(after) != 1 || after[0].Path != "c" {
+		t.Fatalf("EventsAfter(42) = %#v", after)
+	}
+	if after := EventsAfter(events, 43); len(after) != 0 {
+		t.Fatalf("expected nothing after the newest event, got %#v", after)
+	}
+}
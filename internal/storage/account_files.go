@@ -0,0 +1,1300 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+)
+
+// BackendSQLitePerAccount names the backend that keeps each account's index
+// in its own SQLite file.
+const BackendSQLitePerAccount = "sqlite-per-account"
+
+const (
+	// settingAccountSlots counts the id ranges handed out to account files.
+	settingAccountSlots = "storage.account_slots"
+	// settingAccountSlotPrefix prefixes the setting holding an account
+	// file's id range.
+	settingAccountSlotPrefix = "storage.account_slot."
+	// accountIDSpace is how many row ids each account file allocates from,
+	// so conflicts, journaled changes, snapshots and ignore rules keep ids
+	// that are unique across files.
+	accountIDSpace int64 = 1 << 40
+)
+
+// accountTables are the tables with AUTOINCREMENT ids that live in account
+// files and are looked up by id alone.
+var accountTables = []string{"change_journal", "conflicts", "ignore_rules", "snapshots"}
+
+// accountFiles is the sqlite-per-account backend. The embedded Storage is
+// the shared database at database_path; it holds accounts, settings and
+// everything not tied to one account's index. Each account's files,
+// folders, pending ops, sync state and the rest of its index live in a
+// file next to it, opened on first use. Every account file has the full
+// schema and a copy of its account row, which its foreign keys need.
+type accountFiles struct {
+	*Storage
+
+	cfg    *config.Config
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	accounts map[string]*Storage
+	// opening has a channel for each account file being opened, closed
+	// once it is open or has failed to.
+	opening map[string]chan struct{}
+	// slots maps an id range to the account whose file allocates from it.
+	slots map[int64]string
+	// ops maps pending op ids to the account that holds them.
+	ops map[string]string
+}
+
+func openAccountFiles(cfg *config.Config, logger *zap.Logger) (Store, error) {
+	shared, err := NewStorage(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &accountFiles{Storage: shared, cfg: cfg, logger: logger, accounts: make(map[string]*Storage),
+		opening: make(map[string]chan struct{}), slots: make(map[int64]string), ops: make(map[string]string)}, nil
+}
+
+// accountFile returns the path of accountID's file beside the database at
+// path: googlysync.db holds alice's index in googlysync.alice.db.
+func accountFile(path, accountID string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + url.PathEscape(accountID) + ext
+}
+
+// account returns the store holding accountID's index, opening or creating
+// its file. The file is opened and migrated without holding a.mu, so other
+// accounts are not held up; callers wanting the same file wait for it.
+func (a *accountFiles) account(ctx context.Context, accountID string) (*Storage, error) {
+	for {
+		a.mu.Lock()
+		if st, ok := a.accounts[accountID]; ok {
+			a.mu.Unlock()
+			return st, nil
+		}
+		wait, ok := a.opening[accountID]
+		if !ok {
+			break
+		}
+		a.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	done := make(chan struct{})
+	a.opening[accountID] = done
+	a.mu.Unlock()
+
+	st, slot, err := a.open(ctx, accountID)
+
+	a.mu.Lock()
+	delete(a.opening, accountID)
+	if err == nil {
+		a.accounts[accountID] = st
+		a.slots[slot] = accountID
+	}
+	a.mu.Unlock()
+	close(done)
+	return st, err
+}
+
+// open opens accountID's file, creating and migrating it if needed, and
+// returns it with the file's id range.
+func (a *accountFiles) open(ctx context.Context, accountID string) (*Storage, int64, error) {
+	slot, err := a.slot(ctx, accountID)
+	if err != nil {
+		return nil, 0, err
+	}
+	cfg := *a.cfg
+	cfg.DatabasePath = accountFile(a.cfg.DatabasePath, accountID)
+	st, err := NewStorage(&cfg, a.logger)
+	if err != nil {
+		return nil, 0, fmt.Errorf("account %s: %w", accountID, err)
+	}
+	if err := seedAccountIDs(ctx, st.DB, slot); err != nil {
+		_ = st.Close()
+		return nil, 0, err
+	}
+	acct, err := a.Storage.GetAccount(ctx, accountID)
+	if err == nil && acct != nil {
+		err = st.UpsertAccount(ctx, acct)
+	}
+	if err != nil {
+		_ = st.Close()
+		return nil, 0, err
+	}
+	return st, slot, nil
+}
+
+// slot returns the id range of accountID's file, handing out the next one
+// the first time the account is seen.
+func (a *accountFiles) slot(ctx context.Context, accountID string) (int64, error) {
+	key := settingAccountSlotPrefix + accountID
+	if slot, err := a.Storage.GetSettingInt(ctx, key, 0); err != nil || slot > 0 {
+		return slot, err
+	}
+	tx, err := a.Storage.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var slots int64
+	err = tx.QueryRowContext(ctx, `SELECT CAST(value AS INTEGER) FROM settings WHERE key = ?`, settingAccountSlots).Scan(&slots)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	slots++
+	now := unixTime(time.Now())
+	for _, k := range []string{settingAccountSlots, key} {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+		`, k, strconv.FormatInt(slots, 10), now); err != nil {
+			return 0, err
+		}
+	}
+	return slots, tx.Commit()
+}
+
+// seedAccountIDs starts the id sequences of a new account file at the
+// bottom of its slot's range.
+func seedAccountIDs(ctx context.Context, db *sql.DB, slot int64) error {
+	for _, table := range accountTables {
+		if _, err := db.ExecContext(ctx, `
+			INSERT INTO sqlite_sequence (name, seq)
+			SELECT ?1, ?2 WHERE NOT EXISTS (SELECT 1 FROM sqlite_sequence WHERE name = ?1)
+		`, table, slot*accountIDSpace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// accountIDs lists the accounts that may have a file: every stored account
+// and any opened without one.
+func (a *accountFiles) accountIDs(ctx context.Context) ([]string, error) {
+	accts, err := a.Storage.ListAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var ids []string
+	for _, acct := range accts {
+		seen[acct.ID] = true
+		ids = append(ids, acct.ID)
+	}
+	a.mu.Lock()
+	for id := range a.accounts {
+		if !seen[id] {
+			ids = append(ids, id)
+		}
+	}
+	a.mu.Unlock()
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// owner returns the account store holding the row of an accountTables
+// table with id. The id's range names the account file; ids outside every
+// file's range are looked up in the shared database, which has the same
+// tables, so a missing id fails the way it does with one file.
+func (a *accountFiles) owner(ctx context.Context, id int64) (*Storage, error) {
+	slot := id / accountIDSpace
+	a.mu.Lock()
+	accountID, ok := a.slots[slot]
+	a.mu.Unlock()
+	if !ok && slot > 0 {
+		// Not opened yet by this process; the settings record every range.
+		ids, err := a.accountIDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, acct := range ids {
+			n, err := a.Storage.GetSettingInt(ctx, settingAccountSlotPrefix+acct, 0)
+			if err != nil {
+				return nil, err
+			}
+			if n == slot {
+				accountID, ok = acct, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return a.Storage, nil
+	}
+	return a.account(ctx, accountID)
+}
+
+// opOwner returns the account store holding the pending op with id. Ops
+// this store has added, listed or claimed are indexed; any other is looked
+// for in each account file and then in the shared database.
+func (a *accountFiles) opOwner(ctx context.Context, id string) (*Storage, error) {
+	a.mu.Lock()
+	accountID, ok := a.ops[id]
+	a.mu.Unlock()
+	if ok {
+		return a.account(ctx, accountID)
+	}
+	ids, err := a.accountIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, accountID := range ids {
+		st, err := a.account(ctx, accountID)
+		if err != nil {
+			return nil, err
+		}
+		var one int
+		err = st.DB.QueryRowContext(ctx, `SELECT 1 FROM pending_ops WHERE id = ?`, id).Scan(&one)
+		if err == nil {
+			a.noteOps(accountID, PendingOp{ID: id})
+			return st, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+	return a.Storage, nil
+}
+
+// noteOps indexes pending ops under accountID.
+func (a *accountFiles) noteOps(accountID string, ops ...PendingOp) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, op := range ops {
+		a.ops[op.ID] = accountID
+	}
+}
+
+// Close closes every account file and the shared database.
+func (a *accountFiles) Close() error {
+	a.mu.Lock()
+	var errList []error
+	for id, st := range a.accounts {
+		errList = append(errList, st.Close())
+		delete(a.accounts, id)
+	}
+	a.mu.Unlock()
+	errList = append(errList, a.Storage.Close())
+	return errors.Join(errList...)
+}
+
+// UpsertAccount stores the account in the shared database and refreshes
+// the copy in its file.
+func (a *accountFiles) UpsertAccount(ctx context.Context, acct *Account) error {
+	if err := a.Storage.UpsertAccount(ctx, acct); err != nil || acct == nil {
+		return err
+	}
+	st, err := a.account(ctx, acct.ID)
+	if err != nil {
+		return err
+	}
+	mirror := *acct
+	return st.UpsertAccount(ctx, &mirror)
+}
+
+// DeleteAccount removes the account from the shared database and deletes
+// its file.
+func (a *accountFiles) DeleteAccount(ctx context.Context, id string) error {
+	if err := a.Storage.DeleteAccount(ctx, id); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	st, ok := a.accounts[id]
+	delete(a.accounts, id)
+	for slot, acct := range a.slots {
+		if acct == id {
+			delete(a.slots, slot)
+		}
+	}
+	for op, acct := range a.ops {
+		if acct == id {
+			delete(a.ops, op)
+		}
+	}
+	a.mu.Unlock()
+	if ok {
+		if err := st.Close(); err != nil {
+			return err
+		}
+	}
+	path := accountFile(a.cfg.DatabasePath, id)
+	for _, p := range []string{path, path + "-wal", path + "-shm", path + ".lock"} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return a.Storage.DeleteSetting(ctx, settingAccountSlotPrefix+id)
+}
+
+// Backup copies the shared database to dst and each account file beside
+// it, named the way accountFile names them.
+func (a *accountFiles) Backup(ctx context.Context, dst string) error {
+	ids, err := a.accountIDs(ctx)
+	if err != nil {
+		return err
+	}
+	if err := a.Storage.Backup(ctx, dst); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		st, err := a.account(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := st.Backup(ctx, accountFile(dst, id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats adds the sizes and row counts of the account files to the shared
+// database's.
+func (a *accountFiles) Stats(ctx context.Context) (DBStats, error) {
+	stats, err := a.Storage.Stats(ctx)
+	if err != nil {
+		return stats, err
+	}
+	err = a.eachAccount(ctx, func(_ string, st *Storage) error {
+		more, err := st.Stats(ctx)
+		if err != nil {
+			return err
+		}
+		stats.SizeBytes += more.SizeBytes
+		for table, n := range more.TableRows {
+			stats.TableRows[table] += n
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// Maintain maintains the shared database and then each account file,
+// stopping at the first that fails its integrity check.
+func (a *accountFiles) Maintain(ctx context.Context, quick bool) (*MaintenanceReport, error) {
+	report, err := a.Storage.Maintain(ctx, quick)
+	if err != nil {
+		return report, err
+	}
+	err = a.eachAccount(ctx, func(id string, st *Storage) error {
+		more, err := st.Maintain(ctx, quick)
+		if more != nil {
+			for _, problem := range more.Problems {
+				report.Problems = append(report.Problems, "account "+id+": "+problem)
+			}
+			report.Converted = report.Converted || more.Converted
+			report.FreedPages += more.FreedPages
+			report.Took += more.Took
+		}
+		return err
+	})
+	return report, err
+}
+
+// CheckIntegrity checks the shared database and every account file,
+// prefixing problems in an account file with its account.
+func (a *accountFiles) CheckIntegrity(ctx context.Context, quick bool) ([]string, error) {
+	problems, err := a.Storage.CheckIntegrity(ctx, quick)
+	if err != nil {
+		return nil, err
+	}
+	err = a.eachAccount(ctx, func(id string, st *Storage) error {
+		more, err := st.CheckIntegrity(ctx, quick)
+		for _, problem := range more {
+			problems = append(problems, "account "+id+": "+problem)
+		}
+		return err
+	})
+	return problems, err
+}
+
+func (a *accountFiles) eachAccount(ctx context.Context, fn func(id string, st *Storage) error) error {
+	ids, err := a.accountIDs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		st, err := a.account(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := fn(id, st); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The methods below route a call to the file of the account it names.
+
+func (a *accountFiles) AddJournaledChange(ctx context.Context, change *JournaledChange, keep int) error {
+	if change == nil {
+		return nil
+	}
+	st, err := a.account(ctx, change.AccountID)
+	if err != nil {
+		return err
+	}
+	return st.AddJournaledChange(ctx, change, keep)
+}
+
+func (a *accountFiles) UpdateJournaledChange(ctx context.Context, id int64, outcome, errMsg string) error {
+	st, err := a.owner(ctx, id)
+	if err != nil {
+		return err
+	}
+	return st.UpdateJournaledChange(ctx, id, outcome, errMsg)
+}
+
+func (a *accountFiles) HasAppliedChange(ctx context.Context, accountID, driveID string, version int64) (bool, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return false, err
+	}
+	return st.HasAppliedChange(ctx, accountID, driveID, version)
+}
+
+func (a *accountFiles) ListReplayableChanges(ctx context.Context, accountID string, maxAttempts, limit int) ([]JournaledChange, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListReplayableChanges(ctx, accountID, maxAttempts, limit)
+}
+
+// ListJournaledChanges lists one account's file, or merges every account's
+// newest first when filter names none.
+func (a *accountFiles) ListJournaledChanges(ctx context.Context, filter ChangeFilter) ([]JournaledChange, error) {
+	if filter.AccountID != "" {
+		st, err := a.account(ctx, filter.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		return st.ListJournaledChanges(ctx, filter)
+	}
+	var out []JournaledChange
+	err := a.eachAccount(ctx, func(_ string, st *Storage) error {
+		changes, err := st.ListJournaledChanges(ctx, filter)
+		out = append(out, changes...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	if len(out) > filter.Limit {
+		out = out[:filter.Limit]
+	}
+	return out, nil
+}
+
+func (a *accountFiles) AddConflict(ctx context.Context, rec *ConflictRecord) error {
+	if rec == nil {
+		return nil
+	}
+	st, err := a.account(ctx, rec.AccountID)
+	if err != nil {
+		return err
+	}
+	return st.AddConflict(ctx, rec)
+}
+
+func (a *accountFiles) GetConflict(ctx context.Context, id int64) (*ConflictRecord, error) {
+	st, err := a.owner(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetConflict(ctx, id)
+}
+
+func (a *accountFiles) ListConflicts(ctx context.Context, accountID, state string) ([]ConflictRecord, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListConflicts(ctx, accountID, state)
+}
+
+func (a *accountFiles) ResolveConflict(ctx context.Context, id int64, resolution string) error {
+	st, err := a.owner(ctx, id)
+	if err != nil {
+		return err
+	}
+	return st.ResolveConflict(ctx, id, resolution)
+}
+
+func (a *accountFiles) DeleteConflict(ctx context.Context, id int64) error {
+	st, err := a.owner(ctx, id)
+	if err != nil {
+		return err
+	}
+	return st.DeleteConflict(ctx, id)
+}
+
+func (a *accountFiles) GetDevice(ctx context.Context, accountID string) (*Device, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetDevice(ctx, accountID)
+}
+
+func (a *accountFiles) SaveDevice(ctx context.Context, device *Device) error {
+	if device == nil {
+		return nil
+	}
+	st, err := a.account(ctx, device.AccountID)
+	if err != nil {
+		return err
+	}
+	return st.SaveDevice(ctx, device)
+}
+
+func (a *accountFiles) SaveEncryptedFile(ctx context.Context, file *EncryptedFile) error {
+	if file == nil {
+		return nil
+	}
+	st, err := a.account(ctx, file.AccountID)
+	if err != nil {
+		return err
+	}
+	return st.SaveEncryptedFile(ctx, file)
+}
+
+func (a *accountFiles) GetEncryptedFile(ctx context.Context, accountID, driveID string) (*EncryptedFile, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetEncryptedFile(ctx, accountID, driveID)
+}
+
+func (a *accountFiles) DeleteEncryptedFile(ctx context.Context, accountID, driveID string) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.DeleteEncryptedFile(ctx, accountID, driveID)
+}
+
+func (a *accountFiles) AddIgnoreRule(ctx context.Context, rule *IgnoreRule) error {
+	if rule == nil {
+		return nil
+	}
+	st, err := a.account(ctx, rule.AccountID)
+	if err != nil {
+		return err
+	}
+	return st.AddIgnoreRule(ctx, rule)
+}
+
+func (a *accountFiles) ListIgnoreRules(ctx context.Context, accountID, root string, enabledOnly bool) ([]IgnoreRule, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListIgnoreRules(ctx, accountID, root, enabledOnly)
+}
+
+func (a *accountFiles) SetIgnoreRuleEnabled(ctx context.Context, accountID string, id int64, enabled bool) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.SetIgnoreRuleEnabled(ctx, accountID, id, enabled)
+}
+
+func (a *accountFiles) RemoveIgnoreRule(ctx context.Context, accountID string, id int64) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.RemoveIgnoreRule(ctx, accountID, id)
+}
+
+func (a *accountFiles) MarkPlaceholder(ctx context.Context, accountID, path string) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.MarkPlaceholder(ctx, accountID, path)
+}
+
+func (a *accountFiles) IsPlaceholder(ctx context.Context, accountID, path string) (bool, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return false, err
+	}
+	return st.IsPlaceholder(ctx, accountID, path)
+}
+
+func (a *accountFiles) ClearPlaceholder(ctx context.Context, accountID, path string) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.ClearPlaceholder(ctx, accountID, path)
+}
+
+func (a *accountFiles) ListPlaceholders(ctx context.Context, accountID, prefix string) ([]FileRecord, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListPlaceholders(ctx, accountID, prefix)
+}
+
+func (a *accountFiles) PinFolder(ctx context.Context, accountID, path string) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.PinFolder(ctx, accountID, path)
+}
+
+func (a *accountFiles) UnpinFolder(ctx context.Context, accountID, path string) (bool, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return false, err
+	}
+	return st.UnpinFolder(ctx, accountID, path)
+}
+
+func (a *accountFiles) ListPinnedFolders(ctx context.Context, accountID string) ([]string, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListPinnedFolders(ctx, accountID)
+}
+
+func (a *accountFiles) IsPinned(ctx context.Context, accountID, path string) (bool, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return false, err
+	}
+	return st.IsPinned(ctx, accountID, path)
+}
+
+func (a *accountFiles) SavePathAlias(ctx context.Context, alias *PathAlias) error {
+	if alias == nil {
+		return nil
+	}
+	st, err := a.account(ctx, alias.AccountID)
+	if err != nil {
+		return err
+	}
+	return st.SavePathAlias(ctx, alias)
+}
+
+func (a *accountFiles) GetPathAlias(ctx context.Context, accountID, driveID string) (*PathAlias, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetPathAlias(ctx, accountID, driveID)
+}
+
+func (a *accountFiles) ListPathAliases(ctx context.Context, accountID string) ([]PathAlias, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListPathAliases(ctx, accountID)
+}
+
+func (a *accountFiles) DeletePathAlias(ctx context.Context, accountID, driveID string) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.DeletePathAlias(ctx, accountID, driveID)
+}
+
+func (a *accountFiles) FindFilesFoldingCase(ctx context.Context, accountID, path string) ([]FileRecord, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.FindFilesFoldingCase(ctx, accountID, path)
+}
+
+func (a *accountFiles) SaveFolderPolicy(ctx context.Context, policy *FolderPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	st, err := a.account(ctx, policy.AccountID)
+	if err != nil {
+		return err
+	}
+	return st.SaveFolderPolicy(ctx, policy)
+}
+
+func (a *accountFiles) GetFolderPolicy(ctx context.Context, accountID, path string) (*FolderPolicy, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetFolderPolicy(ctx, accountID, path)
+}
+
+func (a *accountFiles) ListFolderPolicies(ctx context.Context, accountID string) ([]FolderPolicy, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListFolderPolicies(ctx, accountID)
+}
+
+func (a *accountFiles) DeleteFolderPolicy(ctx context.Context, accountID, path string) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.DeleteFolderPolicy(ctx, accountID, path)
+}
+
+func (a *accountFiles) GetQuota(ctx context.Context, accountID string) (*Quota, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetQuota(ctx, accountID)
+}
+
+func (a *accountFiles) SaveQuota(ctx context.Context, quota *Quota) error {
+	if quota == nil {
+		return nil
+	}
+	st, err := a.account(ctx, quota.AccountID)
+	if err != nil {
+		return err
+	}
+	return st.SaveQuota(ctx, quota)
+}
+
+func (a *accountFiles) CreateSnapshot(ctx context.Context, snap *Snapshot, entries []SnapshotEntry) error {
+	if snap == nil {
+		return nil
+	}
+	st, err := a.account(ctx, snap.AccountID)
+	if err != nil {
+		return err
+	}
+	return st.CreateSnapshot(ctx, snap, entries)
+}
+
+func (a *accountFiles) GetSnapshot(ctx context.Context, accountID, name string) (*Snapshot, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetSnapshot(ctx, accountID, name)
+}
+
+func (a *accountFiles) LatestSnapshot(ctx context.Context, accountID string) (*Snapshot, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.LatestSnapshot(ctx, accountID)
+}
+
+func (a *accountFiles) ListSnapshots(ctx context.Context, accountID string) ([]Snapshot, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListSnapshots(ctx, accountID)
+}
+
+func (a *accountFiles) ListSnapshotEntries(ctx context.Context, snapshotID int64, prefix string) ([]SnapshotEntry, error) {
+	st, err := a.owner(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListSnapshotEntries(ctx, snapshotID, prefix)
+}
+
+func (a *accountFiles) ListPendingSnapshotEntries(ctx context.Context, accountID string, limit int) ([]SnapshotEntry, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListPendingSnapshotEntries(ctx, accountID, limit)
+}
+
+func (a *accountFiles) CompleteSnapshotEntry(ctx context.Context, snapshotID int64, path, driveID string) error {
+	st, err := a.owner(ctx, snapshotID)
+	if err != nil {
+		return err
+	}
+	return st.CompleteSnapshotEntry(ctx, snapshotID, path, driveID)
+}
+
+func (a *accountFiles) UpsertTokenRef(ctx context.Context, ref *TokenRef) error {
+	if ref == nil {
+		return nil
+	}
+	st, err := a.account(ctx, ref.AccountID)
+	if err != nil {
+		return err
+	}
+	return st.UpsertTokenRef(ctx, ref)
+}
+
+func (a *accountFiles) GetTokenRef(ctx context.Context, accountID string) (*TokenRef, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetTokenRef(ctx, accountID)
+}
+
+func (a *accountFiles) DeleteTokenRef(ctx context.Context, accountID string) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.DeleteTokenRef(ctx, accountID)
+}
+
+func (a *accountFiles) UpsertSyncState(ctx context.Context, state *SyncState) error {
+	if state == nil {
+		return nil
+	}
+	st, err := a.account(ctx, state.AccountID)
+	if err != nil {
+		return err
+	}
+	return st.UpsertSyncState(ctx, state)
+}
+
+func (a *accountFiles) GetSyncState(ctx context.Context, accountID string) (*SyncState, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetSyncState(ctx, accountID)
+}
+
+func (a *accountFiles) SetSyncPaused(ctx context.Context, accountID string, paused bool) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.SetSyncPaused(ctx, accountID, paused)
+}
+
+func (a *accountFiles) SetSyncWatchedAt(ctx context.Context, accountID string, at time.Time) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.SetSyncWatchedAt(ctx, accountID, at)
+}
+
+func (a *accountFiles) UpsertFile(ctx context.Context, file *FileRecord) error {
+	if file == nil {
+		return nil
+	}
+	st, err := a.account(ctx, file.AccountID)
+	if err != nil {
+		return err
+	}
+	return st.UpsertFile(ctx, file)
+}
+
+func (a *accountFiles) GetFileByPath(ctx context.Context, accountID, path string) (*FileRecord, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetFileByPath(ctx, accountID, path)
+}
+
+func (a *accountFiles) GetFileByDriveID(ctx context.Context, accountID, driveID string) (*FileRecord, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetFileByDriveID(ctx, accountID, driveID)
+}
+
+func (a *accountFiles) GetFileByInode(ctx context.Context, accountID string, device, inode uint64) (*FileRecord, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetFileByInode(ctx, accountID, device, inode)
+}
+
+func (a *accountFiles) ListFileProjections(ctx context.Context, accountID, driveID string) ([]FileRecord, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListFileProjections(ctx, accountID, driveID)
+}
+
+func (a *accountFiles) ListFilesByChecksum(ctx context.Context, accountID, checksum string, size int64) ([]FileRecord, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListFilesByChecksum(ctx, accountID, checksum, size)
+}
+
+func (a *accountFiles) SearchFiles(ctx context.Context, accountID, query string, limit int) ([]FileRecord, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.SearchFiles(ctx, accountID, query, limit)
+}
+
+func (a *accountFiles) SetFileTrashed(ctx context.Context, accountID, driveID string, trashed bool) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.SetFileTrashed(ctx, accountID, driveID, trashed)
+}
+
+func (a *accountFiles) SetFileFastHash(ctx context.Context, accountID, path, fastHash string) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.SetFileFastHash(ctx, accountID, path, fastHash)
+}
+
+func (a *accountFiles) DeleteFile(ctx context.Context, accountID, path string) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.DeleteFile(ctx, accountID, path)
+}
+
+func (a *accountFiles) ListFilesByPrefix(ctx context.Context, accountID, prefix string, limit int) ([]FileRecord, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListFilesByPrefix(ctx, accountID, prefix, limit)
+}
+
+func (a *accountFiles) ListFilesByPrefixAfter(ctx context.Context, accountID, prefix, after string, limit int) ([]FileRecord, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListFilesByPrefixAfter(ctx, accountID, prefix, after, limit)
+}
+
+func (a *accountFiles) CountFilesByPrefix(ctx context.Context, accountID, prefix string) (int, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return 0, err
+	}
+	return st.CountFilesByPrefix(ctx, accountID, prefix)
+}
+
+func (a *accountFiles) ListFilesAfter(ctx context.Context, accountID, after string, limit int) ([]FileRecord, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListFilesAfter(ctx, accountID, after, limit)
+}
+
+func (a *accountFiles) UpsertFolder(ctx context.Context, folder *Folder) error {
+	if folder == nil {
+		return nil
+	}
+	st, err := a.account(ctx, folder.AccountID)
+	if err != nil {
+		return err
+	}
+	return st.UpsertFolder(ctx, folder)
+}
+
+func (a *accountFiles) GetFolderByPath(ctx context.Context, accountID, path string) (*Folder, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetFolderByPath(ctx, accountID, path)
+}
+
+func (a *accountFiles) GetFolderByDriveID(ctx context.Context, accountID, driveID string) (*Folder, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetFolderByDriveID(ctx, accountID, driveID)
+}
+
+func (a *accountFiles) DeleteFolder(ctx context.Context, accountID, path string) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.DeleteFolder(ctx, accountID, path)
+}
+
+func (a *accountFiles) ListOrphanedFolders(ctx context.Context, accountID string) ([]Folder, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListOrphanedFolders(ctx, accountID)
+}
+
+func (a *accountFiles) DeleteSubtree(ctx context.Context, accountID, path string) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.DeleteSubtree(ctx, accountID, path)
+}
+
+func (a *accountFiles) RenamePathPrefix(ctx context.Context, accountID, oldPrefix, newPrefix string) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.RenamePathPrefix(ctx, accountID, oldPrefix, newPrefix)
+}
+
+func (a *accountFiles) ResetIndex(ctx context.Context, accountID string) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.ResetIndex(ctx, accountID)
+}
+
+func (a *accountFiles) ListFoldersByPrefix(ctx context.Context, accountID, prefix string, limit int) ([]Folder, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListFoldersByPrefix(ctx, accountID, prefix, limit)
+}
+
+func (a *accountFiles) ListFoldersByPrefixAfter(ctx context.Context, accountID, prefix, after string, limit int) ([]Folder, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListFoldersByPrefixAfter(ctx, accountID, prefix, after, limit)
+}
+
+func (a *accountFiles) CountFoldersByPrefix(ctx context.Context, accountID, prefix string) (int, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return 0, err
+	}
+	return st.CountFoldersByPrefix(ctx, accountID, prefix)
+}
+
+func (a *accountFiles) AddPendingOp(ctx context.Context, op *PendingOp) error {
+	if op == nil {
+		return nil
+	}
+	st, err := a.account(ctx, op.AccountID)
+	if err != nil {
+		return err
+	}
+	if err := st.AddPendingOp(ctx, op); err != nil {
+		return err
+	}
+	a.noteOps(op.AccountID, *op)
+	return nil
+}
+
+func (a *accountFiles) ListPendingOps(ctx context.Context, accountID, state string, limit int) ([]PendingOp, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := st.ListPendingOps(ctx, accountID, state, limit)
+	a.noteOps(accountID, ops...)
+	return ops, err
+}
+
+func (a *accountFiles) ListPendingOpsByType(ctx context.Context, accountID, state string, opTypes []string, limit int) ([]PendingOp, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := st.ListPendingOpsByType(ctx, accountID, state, opTypes, limit)
+	a.noteOps(accountID, ops...)
+	return ops, err
+}
+
+func (a *accountFiles) UpdatePendingOp(ctx context.Context, id, state string, retryCount int, lastError string) error {
+	st, err := a.opOwner(ctx, id)
+	if err != nil {
+		return err
+	}
+	return st.UpdatePendingOp(ctx, id, state, retryCount, lastError)
+}
+
+func (a *accountFiles) ClaimPendingOp(ctx context.Context, id string) (bool, error) {
+	st, err := a.opOwner(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return st.ClaimPendingOp(ctx, id)
+}
+
+func (a *accountFiles) ClaimNextReady(ctx context.Context, accountID string, filter ReadyFilter) (*PendingOp, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	op, err := st.ClaimNextReady(ctx, accountID, filter)
+	if op != nil {
+		a.noteOps(accountID, *op)
+	}
+	return op, err
+}
+
+func (a *accountFiles) RequeuePendingOp(ctx context.Context, id string, retryCount int, lastError string, notBefore time.Time) error {
+	st, err := a.opOwner(ctx, id)
+	if err != nil {
+		return err
+	}
+	return st.RequeuePendingOp(ctx, id, retryCount, lastError, notBefore)
+}
+
+func (a *accountFiles) LastPendingOp(ctx context.Context, accountID, opType string, paths []string) (*PendingOp, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.LastPendingOp(ctx, accountID, opType, paths)
+}
+
+func (a *accountFiles) RetryFailedOps(ctx context.Context, accountID, id string) (int64, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return 0, err
+	}
+	return st.RetryFailedOps(ctx, accountID, id)
+}
+
+func (a *accountFiles) CountPendingOps(ctx context.Context, accountID, state string) (int, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return 0, err
+	}
+	return st.CountPendingOps(ctx, accountID, state)
+}
+
+func (a *accountFiles) PendingOpBytes(ctx context.Context, accountID, opType string) (int64, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return 0, err
+	}
+	return st.PendingOpBytes(ctx, accountID, opType)
+}
+
+func (a *accountFiles) HasPendingOps(ctx context.Context, accountID, path string, opTypes ...string) (bool, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return false, err
+	}
+	return st.HasPendingOps(ctx, accountID, path, opTypes...)
+}
+
+func (a *accountFiles) DeletePendingOpsForPath(ctx context.Context, accountID, path string, opTypes ...string) (int64, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return 0, err
+	}
+	return st.DeletePendingOpsForPath(ctx, accountID, path, opTypes...)
+}
+
+func (a *accountFiles) DeletePendingOp(ctx context.Context, id string) error {
+	st, err := a.opOwner(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := st.DeletePendingOp(ctx, id); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	delete(a.ops, id)
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *accountFiles) AccountSummary(ctx context.Context, accountID string) (*AccountSummary, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.AccountSummary(ctx, accountID)
+}
+
+func (a *accountFiles) UpsertSymlink(ctx context.Context, link *Symlink) error {
+	if link == nil {
+		return nil
+	}
+	st, err := a.account(ctx, link.AccountID)
+	if err != nil {
+		return err
+	}
+	return st.UpsertSymlink(ctx, link)
+}
+
+func (a *accountFiles) GetSymlink(ctx context.Context, accountID, path string) (*Symlink, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetSymlink(ctx, accountID, path)
+}
+
+func (a *accountFiles) ListSymlinks(ctx context.Context, accountID string) ([]Symlink, error) {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return st.ListSymlinks(ctx, accountID)
+}
+
+func (a *accountFiles) DeleteSymlink(ctx context.Context, accountID, path string) error {
+	st, err := a.account(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return st.DeleteSymlink(ctx, accountID, path)
+}
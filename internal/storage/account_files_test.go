@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+func openPerAccount(t *testing.T, dbPath string) Store {
+	t.Helper()
+	store, err := Open(&config.Config{DatabasePath: dbPath, StorageBackend: BackendSQLitePerAccount}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return store
+}
+
+func TestPerAccountFilesSplitTheIndex(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "googlysync.db")
+	store := openPerAccount(t, dbPath)
+	ctx := context.Background()
+
+	conflicts := make(map[string]int64)
+	for _, acct := range []string{"alice", "bob"} {
+		if err := store.UpsertAccount(ctx, &Account{ID: acct, Email: acct + "@example.com"}); err != nil {
+			t.Fatalf("UpsertAccount: %v", err)
+		}
+		if err := store.UpsertFile(ctx, &FileRecord{ID: "file-" + acct, AccountID: acct, Path: "notes.txt", DriveID: "d-" + acct}); err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		if err := store.AddPendingOp(ctx, &PendingOp{ID: "op-" + acct, AccountID: acct, Path: "notes.txt", OpType: "upload"}); err != nil {
+			t.Fatalf("AddPendingOp: %v", err)
+		}
+		rec := &ConflictRecord{AccountID: acct, Path: "notes.txt"}
+		if err := store.AddConflict(ctx, rec); err != nil {
+			t.Fatalf("AddConflict: %v", err)
+		}
+		conflicts[acct] = rec.ID
+		if _, err := os.Stat(accountFile(dbPath, acct)); err != nil {
+			t.Fatalf("expected a file for %s: %v", acct, err)
+		}
+	}
+	if conflicts["alice"] == conflicts["bob"] {
+		t.Fatalf("expected conflict ids unique across files, got %v", conflicts)
+	}
+
+	// Calls that name only an id find the file holding it.
+	if err := store.ResolveConflict(ctx, conflicts["bob"], "kept_local"); err != nil {
+		t.Fatalf("ResolveConflict: %v", err)
+	}
+	if rec, err := store.GetConflict(ctx, conflicts["alice"]); err != nil || rec == nil || rec.AccountID != "alice" || rec.State != ConflictUnresolved {
+		t.Fatalf("expected alice's conflict untouched, got %#v, %v", rec, err)
+	}
+	if err := store.ResolveConflict(ctx, conflicts["bob"]+1000, "kept_local"); errs.KindOf(err) != errs.ErrNotFound {
+		t.Fatalf("expected an unknown conflict not found, got %v", err)
+	}
+	if err := store.UpdatePendingOp(ctx, "op-bob", OpFailed, 1, "boom"); err != nil {
+		t.Fatalf("UpdatePendingOp: %v", err)
+	}
+	if n, err := store.CountPendingOps(ctx, "bob", OpFailed); err != nil || n != 1 {
+		t.Fatalf("expected bob's op failed, got %d, %v", n, err)
+	}
+
+	// Removing an account deletes its file and leaves the others alone.
+	if err := store.DeleteAccount(ctx, "bob"); err != nil {
+		t.Fatalf("DeleteAccount: %v", err)
+	}
+	if _, err := os.Stat(accountFile(dbPath, "bob")); !os.IsNotExist(err) {
+		t.Fatalf("expected bob's file removed, got %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	store = openPerAccount(t, dbPath)
+	t.Cleanup(func() { _ = store.Close() })
+	if rec, err := store.GetFileByPath(ctx, "alice", "notes.txt"); err != nil || rec == nil || rec.DriveID != "d-alice" {
+		t.Fatalf("expected alice's index kept, got %#v, %v", rec, err)
+	}
+	if n, err := store.(*accountFiles).Storage.CountFilesByPrefix(ctx, "alice", ""); err != nil || n != 0 {
+		t.Fatalf("expected nothing indexed in the shared database, got %d, %v", n, err)
+	}
+	if rec, err := store.GetFileByPath(ctx, "bob", "notes.txt"); err != nil || rec != nil {
+		t.Fatalf("expected bob's index gone, got %#v, %v", rec, err)
+	}
+	// An id finds its file by its range before the file has been opened.
+	if got, err := store.GetConflict(ctx, conflicts["alice"]); err != nil || got == nil || got.AccountID != "alice" {
+		t.Fatalf("expected alice's conflict found after reopening, got %#v, %v", got, err)
+	}
+	rec := &ConflictRecord{AccountID: "alice", Path: "other.txt"}
+	if err := store.AddConflict(ctx, rec); err != nil || rec.ID != conflicts["alice"]+1 {
+		t.Fatalf("expected alice's ids to continue after reopening, got %d, %v", rec.ID, err)
+	}
+}
+
+func TestPerAccountFilesOpenOnce(t *testing.T) {
+	store := openPerAccount(t, filepath.Join(t.TempDir(), "googlysync.db"))
+	t.Cleanup(func() { _ = store.Close() })
+	files := store.(*accountFiles)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	opened := make([]*Storage, 8)
+	for i := range opened {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			st, err := files.account(ctx, "carol")
+			if err != nil {
+				t.Errorf("account: %v", err)
+			}
+			opened[i] = st
+		}()
+	}
+	wg.Wait()
+	for _, st := range opened {
+		if st != opened[0] {
+			t.Fatalf("expected one store for the account, got %v", opened)
+		}
+	}
+}
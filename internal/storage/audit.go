@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// AuditEntry is an action the engine would have taken in audit mode.
+type AuditEntry struct {
+	ID        int64
+	AccountID string
+	Action    string
+	Path      string
+	DriveID   string
+	// Detail adds context such as the source of a move.
+	Detail     string
+	RecordedAt time.Time
+}
+
+// AddAuditEntry appends an entry to the audit log.
+func (s *Storage) AddAuditEntry(ctx context.Context, entry *AuditEntry) error {
+	if entry == nil {
+		return nil
+	}
+	if entry.AccountID == "" || entry.Action == "" || entry.Path == "" {
+		return errs.New(errs.ErrInvalidArgument, "audit entry account_id, action and path cannot be empty")
+	}
+	if entry.RecordedAt.IsZero() {
+		entry.RecordedAt = time.Now()
+	}
+	res, err := s.DB.ExecContext(ctx, `
+		INSERT INTO audit_log (account_id, action, path, drive_id, detail, recorded_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, entry.AccountID, entry.Action, entry.Path, entry.DriveID, entry.Detail, unixTime(entry.RecordedAt))
+	if err != nil {
+		return err
+	}
+	entry.ID, err = res.LastInsertId()
+	return err
+}
+
+// ListAuditEntries returns the newest limit entries recorded at or after
+// since, oldest first. A zero since lists from the beginning.
+func (s *Storage) ListAuditEntries(ctx context.Context, accountID string, since time.Time, limit int) ([]AuditEntry, error) {
+	if limit <= 0 {
+		limit = 500
+	}
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, account_id, action, path, drive_id, detail, recorded_at FROM (
+			SELECT id, account_id, action, path, drive_id, detail, recorded_at FROM audit_log
+			WHERE account_id = ? AND recorded_at >= ?
+			ORDER BY id DESC LIMIT ?
+		) ORDER BY id ASC
+	`, accountID, unixTime(since), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		var recordedAt int64
+		if err := rows.Scan(&entry.ID, &entry.AccountID, &entry.Action, &entry.Path, &entry.DriveID, &entry.Detail, &recordedAt); err != nil {
+			return nil, err
+		}
+		entry.RecordedAt = fromUnix(recordedAt)
+		out = append(out, entry)
+	}
+	return out, rows.Err()
+}
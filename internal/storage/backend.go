@@ -0,0 +1,292 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Store is the metadata store the daemon and its services run against.
+// *Storage, the SQLite backend, is the only full implementation. Tests that
+// need a fake embed Store in a struct and override the methods they
+// exercise; other backends register themselves with RegisterBackend.
+type Store interface {
+	Close() error
+
+	// Audit log.
+	AddAuditEntry(ctx context.Context, entry *AuditEntry) error
+	ListAuditEntries(ctx context.Context, accountID string, since time.Time, limit int) ([]AuditEntry, error)
+
+	// Backups.
+	Backup(ctx context.Context, dst string) error
+
+	// Content cache.
+	PutCacheEntry(ctx context.Context, entry *CacheEntry) error
+	FindCacheEntry(ctx context.Context, accountID, driveID, revision string, offset, length int64) (*CacheEntry, error)
+	TouchCacheEntry(ctx context.Context, entry *CacheEntry, at time.Time) error
+	CacheUsage(ctx context.Context) (int, int64, error)
+	ListCacheLRU(ctx context.Context, limit int) ([]CacheEntry, error)
+	DeleteCacheEntry(ctx context.Context, entry CacheEntry) (int, error)
+	CountCacheBlobRefs(ctx context.Context, checksum string) (int, error)
+
+	// Remote change journal.
+	AddJournaledChange(ctx context.Context, change *JournaledChange, keep int) error
+	UpdateJournaledChange(ctx context.Context, id int64, outcome, errMsg string) error
+	HasAppliedChange(ctx context.Context, accountID, driveID string, version int64) (bool, error)
+	ListReplayableChanges(ctx context.Context, accountID string, maxAttempts, limit int) ([]JournaledChange, error)
+	ListJournaledChanges(ctx context.Context, filter ChangeFilter) ([]JournaledChange, error)
+
+	// Conflicts.
+	AddConflict(ctx context.Context, rec *ConflictRecord) error
+	GetConflict(ctx context.Context, id int64) (*ConflictRecord, error)
+	ListConflicts(ctx context.Context, accountID, state string) ([]ConflictRecord, error)
+	ResolveConflict(ctx context.Context, id int64, resolution string) error
+	DeleteConflict(ctx context.Context, id int64) error
+
+	// Devices.
+	GetDevice(ctx context.Context, accountID string) (*Device, error)
+	SaveDevice(ctx context.Context, device *Device) error
+
+	// Diagnostics.
+	Stats(ctx context.Context) (DBStats, error)
+
+	// Encrypted content.
+	SaveEncryptedFile(ctx context.Context, file *EncryptedFile) error
+	GetEncryptedFile(ctx context.Context, accountID, driveID string) (*EncryptedFile, error)
+	DeleteEncryptedFile(ctx context.Context, accountID, driveID string) error
+
+	// Status events.
+	AddStatusEvent(ctx context.Context, evt *StatusEvent, keep int) error
+	ListStatusEvents(ctx context.Context, limit int) ([]StatusEvent, error)
+
+	// Transfer and error history.
+	AddTransfer(ctx context.Context, rec *TransferRecord) error
+	ListTransfers(ctx context.Context, from, to time.Time) ([]TransferRecord, error)
+	AddError(ctx context.Context, rec *ErrorRecord) error
+	ListErrors(ctx context.Context, from, to time.Time) ([]ErrorRecord, error)
+	ListRecentErrors(ctx context.Context, limit int) ([]ErrorRecord, error)
+
+	// Ignore rules.
+	AddIgnoreRule(ctx context.Context, rule *IgnoreRule) error
+	ListIgnoreRules(ctx context.Context, accountID, root string, enabledOnly bool) ([]IgnoreRule, error)
+	SetIgnoreRuleEnabled(ctx context.Context, accountID string, id int64, enabled bool) error
+	RemoveIgnoreRule(ctx context.Context, accountID string, id int64) error
+
+	// Maintenance.
+	Maintain(ctx context.Context, quick bool) (*MaintenanceReport, error)
+	CheckIntegrity(ctx context.Context, quick bool) ([]string, error)
+
+	// Placeholders and pins.
+	MarkPlaceholder(ctx context.Context, accountID, path string) error
+	IsPlaceholder(ctx context.Context, accountID, path string) (bool, error)
+	ClearPlaceholder(ctx context.Context, accountID, path string) error
+	ListPlaceholders(ctx context.Context, accountID, prefix string) ([]FileRecord, error)
+	PinFolder(ctx context.Context, accountID, path string) error
+	UnpinFolder(ctx context.Context, accountID, path string) (bool, error)
+	ListPinnedFolders(ctx context.Context, accountID string) ([]string, error)
+	IsPinned(ctx context.Context, accountID, path string) (bool, error)
+
+	// Path and case aliases.
+	SavePathAlias(ctx context.Context, alias *PathAlias) error
+	GetPathAlias(ctx context.Context, accountID, driveID string) (*PathAlias, error)
+	ListPathAliases(ctx context.Context, accountID string) ([]PathAlias, error)
+	DeletePathAlias(ctx context.Context, accountID, driveID string) error
+	FindFilesFoldingCase(ctx context.Context, accountID, path string) ([]FileRecord, error)
+
+	// Folder policies.
+	SaveFolderPolicy(ctx context.Context, policy *FolderPolicy) error
+	GetFolderPolicy(ctx context.Context, accountID, path string) (*FolderPolicy, error)
+	ListFolderPolicies(ctx context.Context, accountID string) ([]FolderPolicy, error)
+	DeleteFolderPolicy(ctx context.Context, accountID, path string) error
+
+	// Drive quota.
+	GetQuota(ctx context.Context, accountID string) (*Quota, error)
+	SaveQuota(ctx context.Context, quota *Quota) error
+
+	// Remote access tokens.
+	AddRemoteToken(ctx context.Context, tok *RemoteToken) error
+	GetRemoteTokenByHash(ctx context.Context, hash string) (*RemoteToken, error)
+	ListRemoteTokens(ctx context.Context) ([]RemoteToken, error)
+	DeleteRemoteToken(ctx context.Context, id string) error
+
+	// Settings.
+	GetSetting(ctx context.Context, key string) (string, bool, error)
+	SetSetting(ctx context.Context, key, value string) error
+	DeleteSetting(ctx context.Context, key string) error
+	GetSettingInt(ctx context.Context, key string, def int64) (int64, error)
+	SetSettingInt(ctx context.Context, key string, value int64) error
+	GetSettingBool(ctx context.Context, key string, def bool) (bool, error)
+	SetSettingBool(ctx context.Context, key string, value bool) error
+	GetSettingTime(ctx context.Context, key string) (time.Time, error)
+	SetSettingTime(ctx context.Context, key string, value time.Time) error
+
+	// Snapshots.
+	CreateSnapshot(ctx context.Context, snap *Snapshot, entries []SnapshotEntry) error
+	GetSnapshot(ctx context.Context, accountID, name string) (*Snapshot, error)
+	LatestSnapshot(ctx context.Context, accountID string) (*Snapshot, error)
+	ListSnapshots(ctx context.Context, accountID string) ([]Snapshot, error)
+	ListSnapshotEntries(ctx context.Context, snapshotID int64, prefix string) ([]SnapshotEntry, error)
+	ListPendingSnapshotEntries(ctx context.Context, accountID string, limit int) ([]SnapshotEntry, error)
+	CompleteSnapshotEntry(ctx context.Context, snapshotID int64, path, driveID string) error
+
+	// Daily stats.
+	AddDailyStats(ctx context.Context, accountID string, at time.Time, delta DailyStats) error
+	ListDailyStats(ctx context.Context, accountID string, from, to time.Time) ([]DailyStats, error)
+	SumDailyStats(ctx context.Context, accountID string, from, to time.Time) (DailyStats, error)
+
+	// Accounts, files, folders, shared drives and pending ops.
+	UpsertAccount(ctx context.Context, acct *Account) error
+	GetAccount(ctx context.Context, id string) (*Account, error)
+	SetPrimaryAccount(ctx context.Context, id string) error
+	SetAccountReauth(ctx context.Context, id, reason string) error
+	DeleteAccount(ctx context.Context, id string) error
+	ListAccounts(ctx context.Context) ([]Account, error)
+	UpsertTokenRef(ctx context.Context, ref *TokenRef) error
+	GetTokenRef(ctx context.Context, accountID string) (*TokenRef, error)
+	DeleteTokenRef(ctx context.Context, accountID string) error
+	UpsertSyncState(ctx context.Context, state *SyncState) error
+	GetSyncState(ctx context.Context, accountID string) (*SyncState, error)
+	SetSyncPaused(ctx context.Context, accountID string, paused bool) error
+	SetSyncWatchedAt(ctx context.Context, accountID string, at time.Time) error
+	UpsertFile(ctx context.Context, file *FileRecord) error
+	GetFileByPath(ctx context.Context, accountID, path string) (*FileRecord, error)
+	GetFileByDriveID(ctx context.Context, accountID, driveID string) (*FileRecord, error)
+	GetFileByInode(ctx context.Context, accountID string, device, inode uint64) (*FileRecord, error)
+	ListFileProjections(ctx context.Context, accountID, driveID string) ([]FileRecord, error)
+	ListFilesByChecksum(ctx context.Context, accountID, checksum string, size int64) ([]FileRecord, error)
+	SearchFiles(ctx context.Context, accountID, query string, limit int) ([]FileRecord, error)
+	SetFileTrashed(ctx context.Context, accountID, driveID string, trashed bool) error
+	SetFileFastHash(ctx context.Context, accountID, path, fastHash string) error
+	DeleteFile(ctx context.Context, accountID, path string) error
+	ListFilesByPrefix(ctx context.Context, accountID, prefix string, limit int) ([]FileRecord, error)
+	ListFilesByPrefixAfter(ctx context.Context, accountID, prefix, after string, limit int) ([]FileRecord, error)
+	CountFilesByPrefix(ctx context.Context, accountID, prefix string) (int, error)
+	ListFilesAfter(ctx context.Context, accountID, after string, limit int) ([]FileRecord, error)
+	UpsertFolder(ctx context.Context, folder *Folder) error
+	GetFolderByPath(ctx context.Context, accountID, path string) (*Folder, error)
+	GetFolderByDriveID(ctx context.Context, accountID, driveID string) (*Folder, error)
+	DeleteFolder(ctx context.Context, accountID, path string) error
+	ListOrphanedFolders(ctx context.Context, accountID string) ([]Folder, error)
+	DeleteSubtree(ctx context.Context, accountID, path string) error
+	RenamePathPrefix(ctx context.Context, accountID, oldPrefix, newPrefix string) error
+	ResetIndex(ctx context.Context, accountID string) error
+	ListFoldersByPrefix(ctx context.Context, accountID, prefix string, limit int) ([]Folder, error)
+	ListFoldersByPrefixAfter(ctx context.Context, accountID, prefix, after string, limit int) ([]Folder, error)
+	CountFoldersByPrefix(ctx context.Context, accountID, prefix string) (int, error)
+	UpsertSharedDrive(ctx context.Context, drive *SharedDrive) error
+	ListSharedDrives(ctx context.Context) ([]SharedDrive, error)
+	AddPendingOp(ctx context.Context, op *PendingOp) error
+	ListPendingOps(ctx context.Context, accountID, state string, limit int) ([]PendingOp, error)
+	ListPendingOpsByType(ctx context.Context, accountID, state string, opTypes []string, limit int) ([]PendingOp, error)
+	UpdatePendingOp(ctx context.Context, id, state string, retryCount int, lastError string) error
+	ClaimPendingOp(ctx context.Context, id string) (bool, error)
+	ClaimNextReady(ctx context.Context, accountID string, filter ReadyFilter) (*PendingOp, error)
+	RequeuePendingOp(ctx context.Context, id string, retryCount int, lastError string, notBefore time.Time) error
+	LastPendingOp(ctx context.Context, accountID, opType string, paths []string) (*PendingOp, error)
+	RetryFailedOps(ctx context.Context, accountID, id string) (int64, error)
+	CountPendingOps(ctx context.Context, accountID, state string) (int, error)
+	PendingOpBytes(ctx context.Context, accountID, opType string) (int64, error)
+	HasPendingOps(ctx context.Context, accountID, path string, opTypes ...string) (bool, error)
+	DeletePendingOpsForPath(ctx context.Context, accountID, path string, opTypes ...string) (int64, error)
+	DeletePendingOp(ctx context.Context, id string) error
+	AccountSummary(ctx context.Context, accountID string) (*AccountSummary, error)
+
+	// Symlinks.
+	UpsertSymlink(ctx context.Context, link *Symlink) error
+	GetSymlink(ctx context.Context, accountID, path string) (*Symlink, error)
+	ListSymlinks(ctx context.Context, accountID string) ([]Symlink, error)
+	DeleteSymlink(ctx context.Context, accountID, path string) error
+
+	// Sync history.
+	AddHistory(ctx context.Context, entry *HistoryEntry) error
+	ListHistory(ctx context.Context, filter HistoryFilter) ([]HistoryEntry, error)
+
+	// Resumable transfer sessions.
+	SaveTransferSession(ctx context.Context, session *TransferSession) error
+	ListTransferSessions(ctx context.Context, accountID string) ([]TransferSession, error)
+	DeleteTransferSession(ctx context.Context, id string) error
+
+	// File versions.
+	AddFileVersion(ctx context.Context, v *FileVersion) error
+	GetFileVersion(ctx context.Context, id int64) (*FileVersion, error)
+	ListFileVersions(ctx context.Context, path string) ([]FileVersion, error)
+	DeleteFileVersion(ctx context.Context, id int64) error
+	CountFileVersionsByChecksum(ctx context.Context, checksum string) (int, error)
+
+	// File watches.
+	AddFileWatch(ctx context.Context, watch *FileWatch) error
+	ListFileWatches(ctx context.Context) ([]FileWatch, error)
+	UpdateFileWatchVersion(ctx context.Context, accountID, path string, version int64, checkedAt time.Time) error
+	DeleteFileWatch(ctx context.Context, accountID, path string) (bool, error)
+
+	// Webhooks.
+	AddWebhook(ctx context.Context, hook *Webhook) error
+	ListWebhooks(ctx context.Context, accountID string) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+}
+
+var _ Store = (*Storage)(nil)
+
+// BackendSQLite names the built-in SQLite backend.
+const BackendSQLite = "sqlite"
+
+// OpenFunc opens a storage backend.
+type OpenFunc func(cfg *config.Config, logger *zap.Logger) (Store, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]OpenFunc{
+		BackendSQLite: func(cfg *config.Config, logger *zap.Logger) (Store, error) {
+			return NewStorage(cfg, logger)
+		},
+		BackendSQLitePerAccount: openAccountFiles,
+	}
+)
+
+// RegisterBackend makes a storage backend available under name, for
+// storage_backend to select. It is meant to be called from an init function
+// and panics if name is taken.
+func RegisterBackend(name string, open OpenFunc) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if _, ok := backends[name]; ok {
+		panic(fmt.Sprintf("storage backend %q registered twice", name))
+	}
+	backends[name] = open
+}
+
+// Backends lists the registered backend names in order.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open opens the backend cfg selects, SQLite unless storage_backend names
+// another.
+func Open(cfg *config.Config, logger *zap.Logger) (Store, error) {
+	name := cfg.StorageBackend
+	if name == "" {
+		name = BackendSQLite
+	}
+	backendsMu.RLock()
+	open, ok := backends[name]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, errs.New(errs.ErrInvalidArgument, "unknown storage backend %q (have %s)", name, strings.Join(Backends(), ", "))
+	}
+	return open(cfg, logger)
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/encryption"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Backup writes a consistent copy of the database to dst with SQLite's
+// online backup API, so it can run while the daemon is syncing. The copy
+// of an encrypted database stays sealed with the same key. dst must not
+// exist yet.
+func (s *Storage) Backup(ctx context.Context, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return errs.New(errs.ErrConflict, "backup %s already exists", dst)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if s.sealed != nil {
+		if err := s.sealed.flush(s.DB); err != nil {
+			return err
+		}
+		return s.sealed.backup(dst)
+	}
+
+	// Copy to a temporary name first, so an interrupted backup is never
+	// mistaken for a complete one.
+	tmp := dst + ".tmp"
+	err := withConn(ctx, s.DB, func(c driverConn) error {
+		b, err := c.NewBackup(backupURI(tmp, false))
+		if err != nil {
+			return err
+		}
+		return copyPages(b)
+	})
+	if err == nil {
+		err = leaveWAL(ctx, tmp)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// leaveWAL switches the copy at path out of WAL mode, which it inherits
+// from the live database, so it is a single self-contained file.
+func leaveWAL(ctx context.Context, path string) error {
+	db, err := sql.Open("sqlite", backupURI(path, false))
+	if err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `PRAGMA journal_mode = DELETE`); err != nil {
+		_ = db.Close()
+		return err
+	}
+	return db.Close()
+}
+
+// Restore replaces the database cfg points at with the backup at src, after
+// checking that src is intact and no newer than this build understands. The
+// current database is first copied to "<db>.v<version>.bak", as before a
+// migration, and the path of that copy is returned. The daemon must not be
+// running.
+func Restore(ctx context.Context, cfg *config.Config, logger *zap.Logger, src string) (saved string, err error) {
+	_, sealedSrc, err := databaseSealed(src)
+	if err != nil {
+		return "", err
+	}
+	var image []byte
+	if sealedSrc {
+		key, err := encryption.NewKeys(cfg).DatabaseKey()
+		if err != nil {
+			return "", fmt.Errorf("backup is encrypted: %w", err)
+		}
+		if image, err = readSealed(src, key); err != nil {
+			return "", err
+		}
+	}
+	if err := checkBackup(ctx, src, image); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.DatabasePath), 0o700); err != nil {
+		return "", err
+	}
+	db, sealed, err := openDatabase(cfg, logger)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if cerr := closeDatabase(db, sealed); err == nil {
+			err = cerr
+		}
+	}()
+	migrator, err := newMigrator(db)
+	if err != nil {
+		return "", err
+	}
+	current, err := migrator.GetDBVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	if current > 0 {
+		if saved, err = backupDatabase(ctx, db, sealed, cfg.DatabasePath, current); err != nil {
+			return "", fmt.Errorf("back up database before restoring: %w", err)
+		}
+		logger.Info("database backed up before restoring", zap.String("backup", saved), zap.Int64("version", current))
+	}
+
+	err = withConn(ctx, db, func(c driverConn) error {
+		if image != nil {
+			return restoreImage(c, image)
+		}
+		b, err := c.NewRestore(backupURI(src, true))
+		if err != nil {
+			return err
+		}
+		return copyPages(b)
+	})
+	if err != nil {
+		return saved, err
+	}
+	if sealed != nil {
+		// The commit hook does not see pages written by a restore.
+		sealed.dirty.Store(true)
+	}
+	logger.Info("database restored", zap.String("from", src))
+	return saved, nil
+}
+
+// checkBackup opens the backup at src, or its decrypted image, and makes
+// sure it is a googlysync database this build can open.
+func checkBackup(ctx context.Context, src string, image []byte) error {
+	var db *sql.DB
+	var err error
+	if image != nil {
+		db, err = openMemory(image)
+	} else {
+		db, err = sql.Open("sqlite", backupURI(src, true))
+	}
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	problems, err := checkIntegrity(ctx, db, true)
+	if err != nil {
+		return errs.New(errs.ErrInvalidArgument, "%s is not a readable database: %w", src, err)
+	}
+	if len(problems) > 0 {
+		return errs.New(errs.ErrInvalidArgument, "%s failed its integrity check: %s", src, strings.Join(problems, "; "))
+	}
+	migrator, err := newMigrator(db)
+	if err != nil {
+		return err
+	}
+	version, target, err := migrator.GetVersions(ctx)
+	if err != nil {
+		return errs.New(errs.ErrInvalidArgument, "%s is not a googlysync database: %w", src, err)
+	}
+	if version == 0 {
+		return errs.New(errs.ErrInvalidArgument, "%s is not a googlysync database", src)
+	}
+	if version > target {
+		return errs.New(errs.ErrInvalidArgument, "%s has schema version %d; this build only knows up to %d", src, version, target)
+	}
+	return nil
+}
+
+// backupURI names a database file for the driver, read-only when asked.
+func backupURI(path string, readOnly bool) string {
+	uri := "file:" + path
+	if readOnly {
+		uri += "?mode=ro"
+	}
+	return uri
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// CacheEntry is a cached byte range of a Drive file's content.
+type CacheEntry struct {
+	AccountID string
+	DriveID   string
+	// Revision identifies the content the range was read from, such as the
+	// file's md5Checksum or head revision.
+	Revision string
+	Offset   int64
+	Length   int64
+	// Checksum is the SHA-256 of the range and names its blob.
+	Checksum   string
+	LastAccess time.Time
+	CreatedAt  time.Time
+}
+
+const cacheColumns = `account_id, drive_id, revision, offset, length, checksum, last_access, created_at`
+
+// PutCacheEntry records a cached range, replacing one at the same offset.
+func (s *Storage) PutCacheEntry(ctx context.Context, entry *CacheEntry) error {
+	if entry == nil {
+		return nil
+	}
+	if entry.AccountID == "" || entry.DriveID == "" || entry.Revision == "" {
+		return errs.New(errs.ErrInvalidArgument, "cache entry account_id, drive_id and revision are required")
+	}
+	if entry.Offset < 0 || entry.Length <= 0 || entry.Checksum == "" {
+		return errs.New(errs.ErrInvalidArgument, "cache entry needs a non-empty range and checksum")
+	}
+	now := time.Now()
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = now
+	}
+	if entry.LastAccess.IsZero() {
+		entry.LastAccess = now
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO content_cache (`+cacheColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account_id, drive_id, revision, offset) DO UPDATE SET
+			length = excluded.length,
+			checksum = excluded.checksum,
+			last_access = excluded.last_access,
+			created_at = excluded.created_at
+	`, entry.AccountID, entry.DriveID, entry.Revision, entry.Offset, entry.Length, entry.Checksum, entry.LastAccess.UnixNano(), unixTime(entry.CreatedAt))
+	return err
+}
+
+// FindCacheEntry returns a cached range of the given revision that covers
+// [offset, offset+length), or nil. The one starting closest to offset wins.
+func (s *Storage) FindCacheEntry(ctx context.Context, accountID, driveID, revision string, offset, length int64) (*CacheEntry, error) {
+	row := s.queryRowPrepared(ctx, `
+		SELECT `+cacheColumns+`
+		FROM content_cache
+		WHERE account_id = ? AND drive_id = ? AND revision = ? AND offset <= ? AND offset + length >= ?
+		ORDER BY offset DESC
+		LIMIT 1
+	`, accountID, driveID, revision, offset, offset+length)
+	entry, err := scanCacheEntry(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return entry, err
+}
+
+// TouchCacheEntry marks a range as just read, moving it to the back of the
+// eviction order.
+func (s *Storage) TouchCacheEntry(ctx context.Context, entry *CacheEntry, at time.Time) error {
+	entry.LastAccess = at
+	return s.execPrepared(ctx, `
+		UPDATE content_cache SET last_access = ?
+		WHERE account_id = ? AND drive_id = ? AND revision = ? AND offset = ?
+	`, at.UnixNano(), entry.AccountID, entry.DriveID, entry.Revision, entry.Offset)
+}
+
+// CacheUsage returns the number of cached ranges and the bytes their blobs
+// take. Ranges sharing a blob count it once.
+func (s *Storage) CacheUsage(ctx context.Context) (int, int64, error) {
+	var entries int
+	var bytes int64
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM content_cache),
+			COALESCE((SELECT SUM(length) FROM (SELECT checksum, MAX(length) AS length FROM content_cache GROUP BY checksum)), 0)
+	`).Scan(&entries, &bytes)
+	return entries, bytes, err
+}
+
+// ListCacheLRU returns up to limit ranges, least recently read first.
+func (s *Storage) ListCacheLRU(ctx context.Context, limit int) ([]CacheEntry, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+cacheColumns+`
+		FROM content_cache
+		ORDER BY last_access ASC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []CacheEntry
+	for rows.Next() {
+		entry, err := scanCacheEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *entry)
+	}
+	return out, rows.Err()
+}
+
+// DeleteCacheEntry removes one cached range and reports how many ranges
+// still reference its blob.
+func (s *Storage) DeleteCacheEntry(ctx context.Context, entry CacheEntry) (int, error) {
+	if _, err := s.DB.ExecContext(ctx, `
+		DELETE FROM content_cache
+		WHERE account_id = ? AND drive_id = ? AND revision = ? AND offset = ?
+	`, entry.AccountID, entry.DriveID, entry.Revision, entry.Offset); err != nil {
+		return 0, err
+	}
+	return s.CountCacheBlobRefs(ctx, entry.Checksum)
+}
+
+// CountCacheBlobRefs returns how many cached ranges use the blob checksum.
+func (s *Storage) CountCacheBlobRefs(ctx context.Context, checksum string) (int, error) {
+	var n int
+	err := s.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM content_cache WHERE checksum = ?`, checksum).Scan(&n)
+	return n, err
+}
+
+func scanCacheEntry(row rowScanner) (*CacheEntry, error) {
+	var entry CacheEntry
+	var lastAccess, createdAt int64
+	if err := row.Scan(&entry.AccountID, &entry.DriveID, &entry.Revision, &entry.Offset, &entry.Length, &entry.Checksum, &lastAccess, &createdAt); err != nil {
+		return nil, err
+	}
+	// Access times keep nanoseconds so reads within the same second still
+	// order correctly for eviction.
+	entry.LastAccess = time.Unix(0, lastAccess)
+	entry.CreatedAt = fromUnix(createdAt)
+	return &entry, nil
+}
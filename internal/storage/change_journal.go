@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Outcomes of a journaled remote change. A failed change is superseded once
+// a newer change to the same file is journaled, since replaying it would
+// only apply stale metadata.
+const (
+	ChangeApplied    = "applied"
+	ChangeFailed     = "failed"
+	ChangeDuplicate  = "duplicate"
+	ChangeSuperseded = "superseded"
+)
+
+// JournaledChange is one change read from the Drive changes feed.
+type JournaledChange struct {
+	ID        int64
+	AccountID string
+	DriveID   string
+	// Kind is "file", "trashed" or "removed".
+	Kind string
+	Name string
+	// Version is the file's Drive version; zero when the change carries no
+	// file.
+	Version    int64
+	ChangedAt  time.Time
+	ReceivedAt time.Time
+	Outcome    string
+	Error      string
+	Attempts   int
+	// Payload is the change as received, for replaying it.
+	Payload []byte
+}
+
+// ChangeFilter narrows ListJournaledChanges. Zero fields match everything.
+type ChangeFilter struct {
+	AccountID string
+	DriveID   string
+	Outcome   string
+	// BeforeID continues a listing from the last entry of the previous page.
+	BeforeID int64
+	Limit    int
+}
+
+const changeColumns = `id, account_id, drive_id, kind, name, version, changed_at, received_at, outcome, error, attempts, payload`
+
+// AddJournaledChange appends a change to the journal, marks failed entries
+// for the same file superseded, and trims the journal to the newest keep
+// rows.
+func (s *Storage) AddJournaledChange(ctx context.Context, change *JournaledChange, keep int) error {
+	if change == nil {
+		return nil
+	}
+	if change.AccountID == "" || change.DriveID == "" {
+		return errs.New(errs.ErrInvalidArgument, "journaled change account_id and drive_id are required")
+	}
+	if change.Kind == "" || change.Outcome == "" {
+		return errs.New(errs.ErrInvalidArgument, "journaled change kind and outcome are required")
+	}
+	if change.ReceivedAt.IsZero() {
+		change.ReceivedAt = time.Now()
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE change_journal SET outcome = ?
+		WHERE account_id = ? AND drive_id = ? AND outcome = ?
+	`, ChangeSuperseded, change.AccountID, change.DriveID, ChangeFailed); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO change_journal (account_id, drive_id, kind, name, version, changed_at, received_at, outcome, error, attempts, payload)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, change.AccountID, change.DriveID, change.Kind, change.Name, change.Version, unixTime(change.ChangedAt),
+		unixTime(change.ReceivedAt), change.Outcome, change.Error, change.Attempts, change.Payload)
+	if err != nil {
+		return err
+	}
+	if change.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	if keep > 0 {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM change_journal WHERE id <= ?`, change.ID-int64(keep)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UpdateJournaledChange records another attempt at applying a change.
+func (s *Storage) UpdateJournaledChange(ctx context.Context, id int64, outcome, errMsg string) error {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE change_journal SET outcome = ?, error = ?, attempts = attempts + 1 WHERE id = ?
+	`, outcome, errMsg, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errs.New(errs.ErrNotFound, "journaled change %d not found", id)
+	}
+	return nil
+}
+
+// HasAppliedChange reports whether version of a file was already applied,
+// which makes a change carrying it again a duplicate delivery.
+func (s *Storage) HasAppliedChange(ctx context.Context, accountID, driveID string, version int64) (bool, error) {
+	var n int
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT COUNT(1) FROM change_journal
+		WHERE account_id = ? AND drive_id = ? AND version = ? AND outcome IN (?, ?)
+	`, accountID, driveID, version, ChangeApplied, ChangeDuplicate).Scan(&n)
+	return n > 0, err
+}
+
+// ListReplayableChanges returns up to limit failed changes of an account
+// that have been tried fewer than maxAttempts times, oldest first.
+func (s *Storage) ListReplayableChanges(ctx context.Context, accountID string, maxAttempts, limit int) ([]JournaledChange, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	return s.queryChanges(ctx, `
+		SELECT `+changeColumns+` FROM change_journal
+		WHERE account_id = ? AND outcome = ? AND attempts < ?
+		ORDER BY id ASC LIMIT ?
+	`, accountID, ChangeFailed, maxAttempts, limit)
+}
+
+// ListJournaledChanges returns the changes matching filter, newest first.
+func (s *Storage) ListJournaledChanges(ctx context.Context, filter ChangeFilter) ([]JournaledChange, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	var where []string
+	var args []any
+	if filter.AccountID != "" {
+		where = append(where, "account_id = ?")
+		args = append(args, filter.AccountID)
+	}
+	if filter.DriveID != "" {
+		where = append(where, "drive_id = ?")
+		args = append(args, filter.DriveID)
+	}
+	if filter.Outcome != "" {
+		where = append(where, "outcome = ?")
+		args = append(args, filter.Outcome)
+	}
+	if filter.BeforeID > 0 {
+		where = append(where, "id < ?")
+		args = append(args, filter.BeforeID)
+	}
+	query := `SELECT ` + changeColumns + ` FROM change_journal`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+	return s.queryChanges(ctx, query, args...)
+}
+
+func (s *Storage) queryChanges(ctx context.Context, query string, args ...any) ([]JournaledChange, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []JournaledChange
+	for rows.Next() {
+		var c JournaledChange
+		var changedAt, receivedAt int64
+		if err := rows.Scan(&c.ID, &c.AccountID, &c.DriveID, &c.Kind, &c.Name, &c.Version, &changedAt, &receivedAt,
+			&c.Outcome, &c.Error, &c.Attempts, &c.Payload); err != nil {
+			return nil, err
+		}
+		c.ChangedAt = fromUnix(changedAt)
+		c.ReceivedAt = fromUnix(receivedAt)
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
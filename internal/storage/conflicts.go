@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Conflict states.
+const (
+	ConflictUnresolved = "unresolved"
+	ConflictResolved   = "resolved"
+)
+
+// ConflictRecord is a local edit that collided with a change on Drive.
+type ConflictRecord struct {
+	ID        int64
+	AccountID string
+	Path      string
+	DriveID   string
+	// BaseRevision is the revision (or md5) the local edit started from, and
+	// RemoteRevision the Drive content it collided with.
+	BaseRevision   string
+	RemoteRevision string
+	// CopyPath is where the local edit was saved aside, if it was.
+	CopyPath   string
+	State      string
+	Resolution string
+	DetectedAt time.Time
+	ResolvedAt time.Time
+}
+
+const conflictColumns = `id, account_id, path, drive_id, base_revision, remote_revision, copy_path, state, resolution, detected_at, resolved_at`
+
+// AddConflict records a detected conflict. A path has at most one
+// unresolved conflict: detecting another one updates it with the newer
+// remote revision and detection time instead of adding a row.
+func (s *Storage) AddConflict(ctx context.Context, rec *ConflictRecord) error {
+	if rec == nil {
+		return nil
+	}
+	if rec.AccountID == "" || rec.Path == "" {
+		return errs.New(errs.ErrInvalidArgument, "conflict account_id and path are required")
+	}
+	if rec.DetectedAt.IsZero() {
+		rec.DetectedAt = time.Now()
+	}
+	rec.State = ConflictUnresolved
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.QueryRowContext(ctx, `
+		SELECT id FROM conflicts WHERE account_id = ? AND path = ? AND state = ?
+	`, rec.AccountID, rec.Path, ConflictUnresolved).Scan(&rec.ID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO conflicts (account_id, path, drive_id, base_revision, remote_revision, copy_path, state, detected_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, rec.AccountID, rec.Path, rec.DriveID, rec.BaseRevision, rec.RemoteRevision, rec.CopyPath, ConflictUnresolved, unixTime(rec.DetectedAt))
+		if err != nil {
+			return err
+		}
+		if rec.ID, err = res.LastInsertId(); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE conflicts SET
+				drive_id = CASE WHEN ? = '' THEN drive_id ELSE ? END,
+				remote_revision = ?,
+				copy_path = CASE WHEN ? = '' THEN copy_path ELSE ? END,
+				detected_at = ?
+			WHERE id = ?
+		`, rec.DriveID, rec.DriveID, rec.RemoteRevision, rec.CopyPath, rec.CopyPath, unixTime(rec.DetectedAt), rec.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetConflict returns a conflict by id, or nil.
+func (s *Storage) GetConflict(ctx context.Context, id int64) (*ConflictRecord, error) {
+	row := s.DB.QueryRowContext(ctx, `SELECT `+conflictColumns+` FROM conflicts WHERE id = ?`, id)
+	rec, err := scanConflict(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return rec, err
+}
+
+// ListConflicts returns an account's conflicts in state, or in any state
+// when state is empty, newest first.
+func (s *Storage) ListConflicts(ctx context.Context, accountID, state string) ([]ConflictRecord, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+conflictColumns+` FROM conflicts
+		WHERE account_id = ? AND (? = '' OR state = ?)
+		ORDER BY detected_at DESC, id DESC
+	`, accountID, state, state)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ConflictRecord
+	for rows.Next() {
+		rec, err := scanConflict(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *rec)
+	}
+	return out, rows.Err()
+}
+
+// ResolveConflict marks an unresolved conflict resolved, noting how, for
+// example "kept_local" or "kept_both".
+func (s *Storage) ResolveConflict(ctx context.Context, id int64, resolution string) error {
+	if resolution == "" {
+		return errs.New(errs.ErrInvalidArgument, "conflict resolution is required")
+	}
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE conflicts SET state = ?, resolution = ?, resolved_at = ?
+		WHERE id = ? AND state = ?
+	`, ConflictResolved, resolution, unixTime(time.Now()), id, ConflictUnresolved)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errs.New(errs.ErrNotFound, "no unresolved conflict %d", id)
+	}
+	return nil
+}
+
+// DeleteConflict removes a conflict record.
+func (s *Storage) DeleteConflict(ctx context.Context, id int64) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM conflicts WHERE id = ?`, id)
+	return err
+}
+
+func scanConflict(row rowScanner) (*ConflictRecord, error) {
+	var rec ConflictRecord
+	var detectedAt, resolvedAt int64
+	if err := row.Scan(&rec.ID, &rec.AccountID, &rec.Path, &rec.DriveID, &rec.BaseRevision, &rec.RemoteRevision,
+		&rec.CopyPath, &rec.State, &rec.Resolution, &detectedAt, &resolvedAt); err != nil {
+		return nil, err
+	}
+	rec.DetectedAt = fromUnix(detectedAt)
+	rec.ResolvedAt = fromUnix(resolvedAt)
+	return &rec, nil
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Device is the Drive folder that stands in for this machine when syncing to
+// a device-scoped target instead of My Drive.
+type Device struct {
+	AccountID string
+	Name      string
+	// DriveID is the device node; RootDriveID is the folder under it that
+	// mirrors the local sync root.
+	DriveID     string
+	RootDriveID string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// GetDevice returns the registered device for an account, or nil.
+func (s *Storage) GetDevice(ctx context.Context, accountID string) (*Device, error) {
+	var d Device
+	var createdAt, updatedAt int64
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT account_id, name, drive_id, root_drive_id, created_at, updated_at
+		FROM devices WHERE account_id = ?
+	`, accountID).Scan(&d.AccountID, &d.Name, &d.DriveID, &d.RootDriveID, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.CreatedAt = fromUnix(createdAt)
+	d.UpdatedAt = fromUnix(updatedAt)
+	return &d, nil
+}
+
+// SaveDevice inserts or replaces the device registration for an account.
+func (s *Storage) SaveDevice(ctx context.Context, device *Device) error {
+	if device == nil {
+		return nil
+	}
+	if device.AccountID == "" {
+		return errs.New(errs.ErrInvalidArgument, "device account_id cannot be empty")
+	}
+	if device.Name == "" || device.DriveID == "" {
+		return errs.New(errs.ErrInvalidArgument, "device name and drive_id cannot be empty")
+	}
+	now := time.Now()
+	if device.CreatedAt.IsZero() {
+		device.CreatedAt = now
+	}
+	device.UpdatedAt = now
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO devices (account_id, name, drive_id, root_drive_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account_id) DO UPDATE SET
+			name = excluded.name,
+			drive_id = excluded.drive_id,
+			root_drive_id = excluded.root_drive_id,
+			updated_at = excluded.updated_at
+	`, device.AccountID, device.Name, device.DriveID, device.RootDriveID, unixTime(device.CreatedAt), unixTime(device.UpdatedAt))
+	return err
+}
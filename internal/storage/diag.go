@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+)
+
+// DBStats summarizes the metadata database for diagnostics.
+type DBStats struct {
+	SizeBytes     int64
+	SchemaVersion int64
+	TableRows     map[string]int64
+}
+
+// Stats reports row counts per table and the on-disk database size.
+func (s *Storage) Stats(ctx context.Context) (DBStats, error) {
+	stats := DBStats{TableRows: make(map[string]int64)}
+
+	var pageCount, pageSize int64
+	if err := s.DB.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pageCount); err != nil {
+		return stats, err
+	}
+	if err := s.DB.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return stats, err
+	}
+	stats.SizeBytes = pageCount * pageSize
+
+	if err := s.DB.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version WHERE is_applied = 1
+	`).Scan(&stats.SchemaVersion); err != nil {
+		return stats, err
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		ORDER BY name ASC
+	`)
+	if err != nil {
+		return stats, err
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			_ = rows.Close()
+			return stats, err
+		}
+		tables = append(tables, name)
+	}
+	if err := rows.Close(); err != nil {
+		return stats, err
+	}
+
+	for _, table := range tables {
+		var count int64
+		// Table names come from sqlite_master, not user input.
+		if err := s.DB.QueryRowContext(ctx, `SELECT COUNT(1) FROM "`+table+`"`).Scan(&count); err != nil {
+			return stats, err
+		}
+		stats.TableRows[table] = count
+	}
+	return stats, nil
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// EncryptedFile describes a Drive file whose content is stored encrypted.
+// Drive only knows the ciphertext, so the plaintext checksum and size the
+// index compares against are kept here, along with the ciphertext checksum
+// they belong to.
+type EncryptedFile struct {
+	AccountID string
+	DriveID   string
+	// PlainName is the file's name before it was sealed, when names are
+	// encrypted too.
+	PlainName string
+	PlainMD5  string
+	PlainSize int64
+	CipherMD5 string
+	UpdatedAt time.Time
+}
+
+// SaveEncryptedFile adds or replaces the metadata for an encrypted file.
+func (s *Storage) SaveEncryptedFile(ctx context.Context, file *EncryptedFile) error {
+	if file == nil {
+		return nil
+	}
+	if file.AccountID == "" || file.DriveID == "" {
+		return errs.New(errs.ErrInvalidArgument, "encrypted file account_id and drive_id are required")
+	}
+	if file.UpdatedAt.IsZero() {
+		file.UpdatedAt = time.Now()
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO encrypted_files (account_id, drive_id, plain_name, plain_md5, plain_size, cipher_md5, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account_id, drive_id) DO UPDATE SET
+			plain_name = excluded.plain_name,
+			plain_md5 = excluded.plain_md5,
+			plain_size = excluded.plain_size,
+			cipher_md5 = excluded.cipher_md5,
+			updated_at = excluded.updated_at
+	`, file.AccountID, file.DriveID, file.PlainName, file.PlainMD5, file.PlainSize, file.CipherMD5, unixTime(file.UpdatedAt))
+	return err
+}
+
+// GetEncryptedFile returns the metadata for an encrypted file, or nil.
+func (s *Storage) GetEncryptedFile(ctx context.Context, accountID, driveID string) (*EncryptedFile, error) {
+	var file EncryptedFile
+	var updatedAt int64
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT account_id, drive_id, plain_name, plain_md5, plain_size, cipher_md5, updated_at
+		FROM encrypted_files WHERE account_id = ? AND drive_id = ?
+	`, accountID, driveID).Scan(&file.AccountID, &file.DriveID, &file.PlainName, &file.PlainMD5, &file.PlainSize, &file.CipherMD5, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	file.UpdatedAt = fromUnix(updatedAt)
+	return &file, nil
+}
+
+// DeleteEncryptedFile forgets the metadata for a Drive file.
+func (s *Storage) DeleteEncryptedFile(ctx context.Context, accountID, driveID string) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM encrypted_files WHERE account_id = ? AND drive_id = ?`, accountID, driveID)
+	return err
+}
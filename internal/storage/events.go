@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// StatusEvent is a persisted entry of the rolling status event log.
+type StatusEvent struct {
+	ID   int64
+	Seq  uint64 // assigned by the status store
+	Op   string
+	Path string
+	// Root is the watch root Path is relative to; empty for the sync root.
+	Root       string
+	OccurredAt time.Time
+}
+
+// AddStatusEvent appends an event and trims the log to the newest keep rows.
+func (s *Storage) AddStatusEvent(ctx context.Context, evt *StatusEvent, keep int) error {
+	if evt == nil {
+		return nil
+	}
+	if evt.Op == "" {
+		return errs.New(errs.ErrInvalidArgument, "status_event op cannot be empty")
+	}
+	if evt.OccurredAt.IsZero() {
+		evt.OccurredAt = time.Now()
+	}
+	res, err := s.DB.ExecContext(ctx, `
+		INSERT INTO status_events (seq, op, path, root, occurred_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, evt.Seq, evt.Op, evt.Path, evt.Root, unixTime(evt.OccurredAt))
+	if err != nil {
+		return err
+	}
+	if evt.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	if keep <= 0 {
+		return nil
+	}
+	_, err = s.DB.ExecContext(ctx, `
+		DELETE FROM status_events WHERE id <= ?
+	`, evt.ID-int64(keep))
+	return err
+}
+
+// ListStatusEvents returns the newest limit events, oldest first.
+func (s *Storage) ListStatusEvents(ctx context.Context, limit int) ([]StatusEvent, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, seq, op, path, root, occurred_at FROM (
+			SELECT id, seq, op, path, root, occurred_at FROM status_events
+			ORDER BY id DESC LIMIT ?
+		) ORDER BY id ASC
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []StatusEvent
+	for rows.Next() {
+		var evt StatusEvent
+		var occurredAt int64
+		if err := rows.Scan(&evt.ID, &evt.Seq, &evt.Op, &evt.Path, &evt.Root, &occurredAt); err != nil {
+			return nil, err
+		}
+		evt.OccurredAt = fromUnix(occurredAt)
+		out = append(out, evt)
+	}
+	return out, rows.Err()
+}
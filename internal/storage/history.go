@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// TransferRecord captures a completed or failed upload/download.
+type TransferRecord struct {
+	ID         int64
+	AccountID  string
+	Path       string
+	Direction  string
+	Bytes      int64
+	Status     string
+	Error      string
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// ErrorRecord captures a sync failure for later inspection.
+type ErrorRecord struct {
+	ID         int64
+	AccountID  string
+	Path       string
+	Op         string
+	Message    string
+	OccurredAt time.Time
+}
+
+// AddTransfer appends a transfer to the history table.
+func (s *Storage) AddTransfer(ctx context.Context, rec *TransferRecord) error {
+	if rec == nil {
+		return nil
+	}
+	if rec.AccountID == "" {
+		return errs.New(errs.ErrInvalidArgument, "transfer account_id cannot be empty")
+	}
+	if rec.Path == "" {
+		return errs.New(errs.ErrInvalidArgument, "transfer path cannot be empty")
+	}
+	if rec.Direction == "" {
+		return errs.New(errs.ErrInvalidArgument, "transfer direction cannot be empty")
+	}
+	if rec.FinishedAt.IsZero() {
+		rec.FinishedAt = time.Now()
+	}
+	res, err := s.DB.ExecContext(ctx, `
+		INSERT INTO transfer_history (account_id, path, direction, bytes, status, error, started_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, rec.AccountID, rec.Path, rec.Direction, rec.Bytes, rec.Status, rec.Error, unixTime(rec.StartedAt), unixTime(rec.FinishedAt))
+	if err != nil {
+		return err
+	}
+	rec.ID, err = res.LastInsertId()
+	return err
+}
+
+// ListTransfers returns transfers that finished within [from, to]. Zero bounds are open.
+func (s *Storage) ListTransfers(ctx context.Context, from, to time.Time) ([]TransferRecord, error) {
+	lo, hi := timeBounds(from, to)
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, account_id, path, direction, bytes, status, error, started_at, finished_at
+		FROM transfer_history
+		WHERE finished_at >= ? AND finished_at <= ?
+		ORDER BY finished_at ASC, id ASC
+	`, lo, hi)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []TransferRecord
+	for rows.Next() {
+		var rec TransferRecord
+		var startedAt, finishedAt int64
+		if err := rows.Scan(&rec.ID, &rec.AccountID, &rec.Path, &rec.Direction, &rec.Bytes, &rec.Status, &rec.Error, &startedAt, &finishedAt); err != nil {
+			return nil, err
+		}
+		rec.StartedAt = fromUnix(startedAt)
+		rec.FinishedAt = fromUnix(finishedAt)
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// AddError appends a sync failure to the error history table.
+func (s *Storage) AddError(ctx context.Context, rec *ErrorRecord) error {
+	if rec == nil {
+		return nil
+	}
+	if rec.AccountID == "" {
+		return errs.New(errs.ErrInvalidArgument, "error account_id cannot be empty")
+	}
+	if rec.Message == "" {
+		return errs.New(errs.ErrInvalidArgument, "error message cannot be empty")
+	}
+	if rec.OccurredAt.IsZero() {
+		rec.OccurredAt = time.Now()
+	}
+	res, err := s.DB.ExecContext(ctx, `
+		INSERT INTO error_history (account_id, path, op, message, occurred_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, rec.AccountID, rec.Path, rec.Op, rec.Message, unixTime(rec.OccurredAt))
+	if err != nil {
+		return err
+	}
+	rec.ID, err = res.LastInsertId()
+	return err
+}
+
+// ListErrors returns errors that occurred within [from, to]. Zero bounds are open.
+func (s *Storage) ListErrors(ctx context.Context, from, to time.Time) ([]ErrorRecord, error) {
+	lo, hi := timeBounds(from, to)
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, account_id, path, op, message, occurred_at
+		FROM error_history
+		WHERE occurred_at >= ? AND occurred_at <= ?
+		ORDER BY occurred_at ASC, id ASC
+	`, lo, hi)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ErrorRecord
+	for rows.Next() {
+		var rec ErrorRecord
+		var occurredAt int64
+		if err := rows.Scan(&rec.ID, &rec.AccountID, &rec.Path, &rec.Op, &rec.Message, &occurredAt); err != nil {
+			return nil, err
+		}
+		rec.OccurredAt = fromUnix(occurredAt)
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+func timeBounds(from, to time.Time) (int64, int64) {
+	lo := unixTime(from)
+	hi := int64(1<<63 - 1)
+	if !to.IsZero() {
+		hi = to.Unix()
+	}
+	return lo, hi
+}
+
+// ListRecentErrors returns the newest errors first, up to limit.
+func (s *Storage) ListRecentErrors(ctx context.Context, limit int) ([]ErrorRecord, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, account_id, path, op, message, occurred_at
+		FROM error_history
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ErrorRecord
+	for rows.Next() {
+		var rec ErrorRecord
+		var occurredAt int64
+		if err := rows.Scan(&rec.ID, &rec.AccountID, &rec.Path, &rec.Op, &rec.Message, &occurredAt); err != nil {
+			return nil, err
+		}
+		rec.OccurredAt = fromUnix(occurredAt)
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+func TestTransferAndErrorHistory(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	if err := store.UpsertAccount(ctx, &Account{ID: "acct-1", Email: "user@example.com"}); err != nil {
+		t.Fatalf("UpsertAccount: %v", err)
+	}
+
+	early := time.Unix(1_700_000_000, 0)
+	late := time.Unix(1_700_100_000, 0)
+	for i, finished := range []time.Time{early, late} {
+		rec := &TransferRecord{
+			AccountID:  "acct-1",
+			Path:       "docs/report.txt",
+			Direction:  "upload",
+			Bytes:      int64(100 * (i + 1)),
+			Status:     "done",
+			FinishedAt: finished,
+		}
+		if err := store.AddTransfer(ctx, rec); err != nil {
+			t.Fatalf("AddTransfer: %v", err)
+		}
+		if rec.ID == 0 {
+			t.Fatal("expected transfer id assigned")
+		}
+	}
+	if err := store.AddError(ctx, &ErrorRecord{AccountID: "acct-1", Path: "a", Op: "WRITE", Message: "boom", OccurredAt: late}); err != nil {
+		t.Fatalf("AddError: %v", err)
+	}
+	if err := store.AddError(ctx, &ErrorRecord{AccountID: "acct-1"}); err == nil {
+		t.Fatal("expected error for empty message")
+	}
+
+	all, err := store.ListTransfers(ctx, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("ListTransfers: %v", err)
+	}
+	if len(all) != 2 || all[0].Bytes != 100 {
+		t.Fatalf("ListTransfers mismatch: %#v", all)
+	}
+	windowed, err := store.ListTransfers(ctx, early.Add(time.Hour), time.Time{})
+	if err != nil {
+		t.Fatalf("ListTransfers windowed: %v", err)
+	}
+	if len(windowed) != 1 || !windowed[0].FinishedAt.Equal(late) {
+		t.Fatalf("ListTransfers windowed mismatch: %#v", windowed)
+	}
+
+	errs, err := store.ListErrors(ctx, time.Time{}, early)
+	if err != nil {
+		t.Fatalf("ListErrors: %v", err)
+	}
+	if len(errs) != 0 {
+		t.Fatalf("expected no errors before window, got %#v", errs)
+	}
+	errs, err = store.ListErrors(ctx, early, late)
+	if err != nil {
+		t.Fatalf("ListErrors: %v", err)
+	}
+	if len(errs) != 1 || errs[0].Message != "boom" {
+		t.Fatalf("ListErrors mismatch: %#v", errs)
+	}
+}
+
+func TestStatusEventLogIsBounded(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	base := time.Unix(1_700_000_000, 0)
+	for i := 0; i < 5; i++ {
+		evt := &StatusEvent{Seq: uint64(i + 1), Op: "WRITE", Path: fmt.Sprintf("f%d.txt", i), OccurredAt: base.Add(time.Duration(i) * time.Second)}
+		if err := store.AddStatusEvent(ctx, evt, 3); err != nil {
+			t.Fatalf("AddStatusEvent: %v", err)
+		}
+	}
+	if n := countRows(t, store, "SELECT COUNT(1) FROM status_events"); n != 3 {
+		t.Fatalf("expected 3 retained events, got %d", n)
+	}
+
+	events, err := store.ListStatusEvents(ctx, 2)
+	if err != nil {
+		t.Fatalf("ListStatusEvents: %v", err)
+	}
+	if len(events) != 2 || events[0].Path != "f3.txt" || events[1].Path != "f4.txt" {
+		t.Fatalf("expected newest two oldest first, got %#v", events)
+	}
+	if !events[1].OccurredAt.Equal(base.Add(4*time.Second)) || events[1].Seq != 5 {
+		t.Fatalf("time mismatch: %#v", events[1])
+	}
+}
+
+func TestSyncHistoryFiltersAndPages(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	base := time.Unix(1_700_000_000, 0)
+	for i, entry := range []HistoryEntry{
+		{AccountID: "acct-1", Op: "upload", Path: "docs/a.txt", Bytes: 10, Outcome: HistorySucceeded},
+		{AccountID: "acct-1", Op: "download", Path: "docs/sub/b.txt", Bytes: 20, Outcome: HistorySucceeded},
+		{AccountID: "acct-1", Op: "upload", Path: "docs2/c.txt", Outcome: HistoryFailed, Error: "boom"},
+		{AccountID: "acct-2", Op: "upload", Path: "docs/d.txt", Outcome: HistorySucceeded},
+		{AccountID: "acct-1", Op: "conflict", Path: "docs/a.txt", Detail: "docs/a (conflicted copy).txt", Outcome: HistoryDetected},
+	} {
+		entry.FinishedAt = base.Add(time.Duration(i) * time.Minute)
+		if err := store.AddHistory(ctx, &entry); err != nil {
+			t.Fatalf("AddHistory: %v", err)
+		}
+		if entry.ID == 0 || !entry.StartedAt.Equal(entry.FinishedAt) {
+			t.Fatalf("expected id and start time filled in, got %#v", entry)
+		}
+	}
+	if err := store.AddHistory(ctx, &HistoryEntry{AccountID: "acct-1", Path: "x"}); err == nil {
+		t.Fatal("expected an entry without op rejected")
+	}
+
+	paths := func(entries []HistoryEntry) []string {
+		var out []string
+		for _, e := range entries {
+			out = append(out, e.Op+" "+e.Path)
+		}
+		return out
+	}
+	got, err := store.ListHistory(ctx, HistoryFilter{AccountID: "acct-1", Path: "docs"})
+	if err != nil {
+		t.Fatalf("ListHistory: %v", err)
+	}
+	if want := []string{"conflict docs/a.txt", "download docs/sub/b.txt", "upload docs/a.txt"}; fmt.Sprint(paths(got)) != fmt.Sprint(want) {
+		t.Fatalf("path filter got %v, want %v", paths(got), want)
+	}
+	got, _ = store.ListHistory(ctx, HistoryFilter{Op: "upload", Outcome: HistoryFailed})
+	if len(got) != 1 || got[0].Error != "boom" {
+		t.Fatalf("expected the failed upload, got %#v", got)
+	}
+	got, _ = store.ListHistory(ctx, HistoryFilter{AccountID: "acct-1", Since: base.Add(time.Minute), Until: base.Add(2 * time.Minute)})
+	if len(got) != 2 {
+		t.Fatalf("expected two entries in the window, got %v", paths(got))
+	}
+
+	page, _ := store.ListHistory(ctx, HistoryFilter{AccountID: "acct-1", Limit: 3})
+	next, _ := store.ListHistory(ctx, HistoryFilter{AccountID: "acct-1", Limit: 3, BeforeID: page[len(page)-1].ID})
+	if len(page) != 3 || len(next) != 1 || next[0].Path != "docs/a.txt" || next[0].Op != "upload" {
+		t.Fatalf("unexpected pages %v then %v", paths(page), paths(next))
+	}
+}
+
+func TestDailyStatsAccumulate(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	day1 := time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local)
+	day2 := day1.Add(24 * time.Hour)
+	for _, add := range []struct {
+		account string
+		at      time.Time
+		delta   DailyStats
+	}{
+		{"acct-1", day1, DailyStats{BytesUp: 100, FilesSynced: 1}},
+		{"acct-1", day1.Add(3 * time.Hour), DailyStats{BytesDown: 50, FilesSynced: 1}},
+		{"acct-1", day2, DailyStats{Errors: 1}},
+		{"acct-2", day1, DailyStats{BytesUp: 7, FilesSynced: 1}},
+	} {
+		if err := store.AddDailyStats(ctx, add.account, add.at, add.delta); err != nil {
+			t.Fatalf("AddDailyStats: %v", err)
+		}
+	}
+	if err := store.AddDailyStats(ctx, "", day1, DailyStats{}); err == nil {
+		t.Fatal("expected an empty account rejected")
+	}
+
+	days, err := store.ListDailyStats(ctx, "acct-1", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("ListDailyStats: %v", err)
+	}
+	if len(days) != 2 || !days[0].Day.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("expected two days oldest first, got %#v", days)
+	}
+	if d := days[0]; d.BytesUp != 100 || d.BytesDown != 50 || d.FilesSynced != 2 || d.Errors != 0 {
+		t.Fatalf("expected the first day's counters summed, got %#v", d)
+	}
+	if only, _ := store.ListDailyStats(ctx, "acct-1", day2, day2); len(only) != 1 || only[0].Errors != 1 {
+		t.Fatalf("expected only the second day, got %#v", only)
+	}
+	total, err := store.SumDailyStats(ctx, "", day1, day1)
+	if err != nil || total.BytesUp != 107 || total.FilesSynced != 3 {
+		t.Fatalf("expected both accounts' first day totalled, got %#v, %v", total, err)
+	}
+}
+
+func TestChangeJournal(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	add := func(driveID string, version int64, outcome string) *JournaledChange {
+		t.Helper()
+		c := &JournaledChange{AccountID: "acct-1", DriveID: driveID, Kind: "file", Version: version, Outcome: outcome, Attempts: 1}
+		if err := store.AddJournaledChange(ctx, c, 4); err != nil {
+			t.Fatalf("AddJournaledChange: %v", err)
+		}
+		return c
+	}
+	add("d-a", 1, ChangeApplied)
+	failed := add("d-b", 1, ChangeFailed)
+	if dup, err := store.HasAppliedChange(ctx, "acct-1", "d-a", 1); err != nil || !dup {
+		t.Fatalf("HasAppliedChange = %v, %v", dup, err)
+	}
+	if dup, _ := store.HasAppliedChange(ctx, "acct-1", "d-b", 1); dup {
+		t.Fatal("expected a failed change not to count as applied")
+	}
+	replayable, err := store.ListReplayableChanges(ctx, "acct-1", 2, 0)
+	if err != nil || len(replayable) != 1 || replayable[0].ID != failed.ID {
+		t.Fatalf("ListReplayableChanges: %#v, %v", replayable, err)
+	}
+	if err := store.UpdateJournaledChange(ctx, failed.ID, ChangeFailed, "still broken"); err != nil {
+		t.Fatalf("UpdateJournaledChange: %v", err)
+	}
+	if replayable, _ := store.ListReplayableChanges(ctx, "acct-1", 2, 0); len(replayable) != 0 {
+		t.Fatalf("expected a change out of attempts skipped, got %#v", replayable)
+	}
+
+	// A newer change to the same file supersedes the failed one.
+	add("d-b", 2, ChangeApplied)
+	entries, err := store.ListJournaledChanges(ctx, ChangeFilter{AccountID: "acct-1", DriveID: "d-b"})
+	if err != nil || len(entries) != 2 || entries[1].Outcome != ChangeSuperseded || entries[1].Error != "still broken" {
+		t.Fatalf("expected the failed change superseded, got %#v, %v", entries, err)
+	}
+
+	for i := 0; i < 3; i++ {
+		add(fmt.Sprintf("d-%d", i), 1, ChangeApplied)
+	}
+	all, err := store.ListJournaledChanges(ctx, ChangeFilter{})
+	if err != nil || len(all) != 4 || all[0].DriveID != "d-2" {
+		t.Fatalf("expected the journal trimmed to 4 rows, got %#v, %v", all, err)
+	}
+	if err := store.UpdateJournaledChange(ctx, 9999, ChangeApplied, ""); errs.KindOf(err) != errs.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
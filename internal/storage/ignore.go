@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// IgnoreRule is an ignore pattern kept in the database, so one added from
+// the CLI outlives the process that added it.
+type IgnoreRule struct {
+	ID        int64
+	AccountID string
+	// Root is the sync root the rule applies to; "" applies to any root.
+	Root    string
+	Pattern string
+	// Source notes where the rule came from, such as "cli".
+	Source    string
+	Enabled   bool
+	CreatedAt time.Time
+}
+
+// AddIgnoreRule stores a rule. Adding a pattern the root already has
+// re-enables it and keeps its id.
+func (s *Storage) AddIgnoreRule(ctx context.Context, rule *IgnoreRule) error {
+	if rule == nil {
+		return nil
+	}
+	if rule.AccountID == "" {
+		return errs.New(errs.ErrInvalidArgument, "ignore rule account_id is required")
+	}
+	if rule.Pattern == "" || strings.Contains(rule.Pattern, "\n") {
+		return errs.New(errs.ErrInvalidArgument, "invalid ignore pattern %q", rule.Pattern)
+	}
+	if rule.CreatedAt.IsZero() {
+		rule.CreatedAt = time.Now()
+	}
+	rule.Enabled = true
+	err := s.DB.QueryRowContext(ctx, `
+		INSERT INTO ignore_rules (account_id, root, pattern, source, enabled, created_at)
+		VALUES (?, ?, ?, ?, 1, ?)
+		ON CONFLICT(account_id, root, pattern) DO UPDATE SET enabled = 1
+		RETURNING id
+	`, rule.AccountID, rule.Root, rule.Pattern, rule.Source, unixTime(rule.CreatedAt)).Scan(&rule.ID)
+	return err
+}
+
+// ListIgnoreRules returns an account's rules for root, including those for
+// any root, oldest first. With enabledOnly, disabled rules are left out.
+func (s *Storage) ListIgnoreRules(ctx context.Context, accountID, root string, enabledOnly bool) ([]IgnoreRule, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, account_id, root, pattern, source, enabled, created_at
+		FROM ignore_rules
+		WHERE account_id = ? AND root IN ('', ?) AND (enabled = 1 OR ? = 0)
+		ORDER BY id ASC
+	`, accountID, root, boolToInt(enabledOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []IgnoreRule
+	for rows.Next() {
+		var rule IgnoreRule
+		var enabled int
+		var createdAt int64
+		if err := rows.Scan(&rule.ID, &rule.AccountID, &rule.Root, &rule.Pattern, &rule.Source, &enabled, &createdAt); err != nil {
+			return nil, err
+		}
+		rule.Enabled = intToBool(enabled)
+		rule.CreatedAt = fromUnix(createdAt)
+		out = append(out, rule)
+	}
+	return out, rows.Err()
+}
+
+// SetIgnoreRuleEnabled turns a rule on or off without forgetting it.
+func (s *Storage) SetIgnoreRuleEnabled(ctx context.Context, accountID string, id int64, enabled bool) error {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE ignore_rules SET enabled = ? WHERE account_id = ? AND id = ?
+	`, boolToInt(enabled), accountID, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errs.New(errs.ErrNotFound, "ignore rule %d not found", id)
+	}
+	return nil
+}
+
+// RemoveIgnoreRule deletes a rule.
+func (s *Storage) RemoveIgnoreRule(ctx context.Context, accountID string, id int64) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM ignore_rules WHERE account_id = ? AND id = ?`, accountID, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errs.New(errs.ErrNotFound, "ignore rule %d not found", id)
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// IndexDumpVersion is the format version written by ExportIndex.
+const IndexDumpVersion = 1
+
+// indexDumpPage is how many records ExportIndex reads at a time.
+const indexDumpPage = 1000
+
+// IndexDump is a portable copy of the sync index: accounts with their sync
+// state, folders and files. Paths are relative to the sync root. Device and
+// inode numbers only mean something on the machine that recorded them, so
+// they are left out; tokens stay in the keyring and are not exported.
+type IndexDump struct {
+	Version    int           `json:"version"`
+	ExportedAt time.Time     `json:"exported_at"`
+	Accounts   []DumpAccount `json:"accounts"`
+}
+
+// DumpAccount is one account in an index dump.
+type DumpAccount struct {
+	ID          string         `json:"id"`
+	Email       string         `json:"email"`
+	DisplayName string         `json:"display_name,omitempty"`
+	IsPrimary   bool           `json:"is_primary,omitempty"`
+	SyncState   *DumpSyncState `json:"sync_state,omitempty"`
+	Folders     []DumpFolder   `json:"folders"`
+	Files       []DumpFile     `json:"files"`
+}
+
+// DumpSyncState is where an account's changes feed had got to. Importing it
+// lets the new machine continue the feed instead of crawling Drive again.
+type DumpSyncState struct {
+	StartPageToken string    `json:"start_page_token,omitempty"`
+	LastSyncAt     time.Time `json:"last_sync_at,omitzero"`
+	Paused         bool      `json:"paused,omitempty"`
+}
+
+// DumpFolder is one folder record in an index dump.
+type DumpFolder struct {
+	ID         string    `json:"id"`
+	Path       string    `json:"path"`
+	DriveID    string    `json:"drive_id"`
+	ParentID   string    `json:"parent_id,omitempty"`
+	OrphanedAt time.Time `json:"orphaned_at,omitzero"`
+	ModifiedAt time.Time `json:"modified_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DumpFile is one file record in an index dump.
+type DumpFile struct {
+	ID          string    `json:"id"`
+	Path        string    `json:"path"`
+	DriveID     string    `json:"drive_id"`
+	ParentID    string    `json:"parent_id,omitempty"`
+	ShortcutID  string    `json:"shortcut_id,omitempty"`
+	ETag        string    `json:"etag,omitempty"`
+	Checksum    string    `json:"md5,omitempty"`
+	FastHash    string    `json:"fast_hash,omitempty"`
+	RemoteName  string    `json:"remote_name,omitempty"`
+	ReadOnly    bool      `json:"read_only,omitempty"`
+	MimeType    string    `json:"mime_type,omitempty"`
+	RevisionID  string    `json:"revision_id,omitempty"`
+	Trashed     bool      `json:"trashed,omitempty"`
+	Starred     bool      `json:"starred,omitempty"`
+	Shared      bool      `json:"shared,omitempty"`
+	WebViewLink string    `json:"web_view_link,omitempty"`
+	Size        int64     `json:"size"`
+	ModifiedAt  time.Time `json:"modified_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// IndexDumpCounts totals what an index dump held.
+type IndexDumpCounts struct {
+	Accounts int
+	Folders  int
+	Files    int
+}
+
+// ExportIndex writes the index of one account, or of every account when
+// accountID is empty, to w as an IndexDump.
+func ExportIndex(ctx context.Context, store Store, w io.Writer, accountID string, now time.Time) (*IndexDumpCounts, error) {
+	accounts, err := store.ListAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dump := IndexDump{Version: IndexDumpVersion, ExportedAt: now.UTC(), Accounts: []DumpAccount{}}
+	counts := &IndexDumpCounts{}
+	for _, acct := range accounts {
+		if accountID != "" && acct.ID != accountID {
+			continue
+		}
+		da, err := dumpAccount(ctx, store, acct)
+		if err != nil {
+			return nil, err
+		}
+		dump.Accounts = append(dump.Accounts, *da)
+		counts.Accounts++
+		counts.Folders += len(da.Folders)
+		counts.Files += len(da.Files)
+	}
+	if accountID != "" && counts.Accounts == 0 {
+		return nil, errs.New(errs.ErrNotFound, "account %s not found", accountID)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(dump); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func dumpAccount(ctx context.Context, store Store, acct Account) (*DumpAccount, error) {
+	da := &DumpAccount{
+		ID:          acct.ID,
+		Email:       acct.Email,
+		DisplayName: acct.DisplayName,
+		IsPrimary:   acct.IsPrimary,
+		Folders:     []DumpFolder{},
+		Files:       []DumpFile{},
+	}
+	state, err := store.GetSyncState(ctx, acct.ID)
+	if err != nil {
+		return nil, err
+	}
+	if state != nil {
+		da.SyncState = &DumpSyncState{StartPageToken: state.StartPageToken, LastSyncAt: state.LastSyncAt.UTC(), Paused: state.Paused}
+	}
+
+	after := ""
+	for {
+		folders, err := store.ListFoldersByPrefixAfter(ctx, acct.ID, "", after, indexDumpPage)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range folders {
+			da.Folders = append(da.Folders, DumpFolder{
+				ID:         f.ID,
+				Path:       f.Path,
+				DriveID:    f.DriveID,
+				ParentID:   f.ParentID,
+				OrphanedAt: f.OrphanedAt.UTC(),
+				ModifiedAt: f.ModifiedAt.UTC(),
+				CreatedAt:  f.CreatedAt.UTC(),
+			})
+		}
+		if len(folders) < indexDumpPage {
+			break
+		}
+		after = folders[len(folders)-1].Path
+	}
+
+	after = ""
+	for {
+		files, err := store.ListFilesAfter(ctx, acct.ID, after, indexDumpPage)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			da.Files = append(da.Files, DumpFile{
+				ID:          f.ID,
+				Path:        f.Path,
+				DriveID:     f.DriveID,
+				ParentID:    f.ParentID,
+				ShortcutID:  f.ShortcutID,
+				ETag:        f.ETag,
+				Checksum:    f.Checksum,
+				FastHash:    f.FastHash,
+				RemoteName:  f.RemoteName,
+				ReadOnly:    f.ReadOnly,
+				MimeType:    f.MimeType,
+				RevisionID:  f.RevisionID,
+				Trashed:     f.Trashed,
+				Starred:     f.Starred,
+				Shared:      f.Shared,
+				WebViewLink: f.WebViewLink,
+				Size:        f.Size,
+				ModifiedAt:  f.ModifiedAt.UTC(),
+				CreatedAt:   f.CreatedAt.UTC(),
+			})
+		}
+		if len(files) < indexDumpPage {
+			break
+		}
+		after = files[len(files)-1].Path
+	}
+	return da, nil
+}
+
+// ImportIndex loads an IndexDump written by ExportIndex. An account that
+// already has files or folders indexed is refused with errs.ErrConflict
+// unless replace is set, in which case its index and pending ops are dropped
+// first, as by ResetIndex. The daemon must not be running.
+func ImportIndex(ctx context.Context, store Store, r io.Reader, replace bool) (*IndexDumpCounts, error) {
+	var dump IndexDump
+	if err := json.NewDecoder(r).Decode(&dump); err != nil {
+		return nil, errs.New(errs.ErrInvalidArgument, "decode index dump: %v", err)
+	}
+	if dump.Version != IndexDumpVersion {
+		return nil, errs.New(errs.ErrInvalidArgument, "unsupported index dump version %d", dump.Version)
+	}
+
+	for _, da := range dump.Accounts {
+		files, err := store.CountFilesByPrefix(ctx, da.ID, "")
+		if err != nil {
+			return nil, err
+		}
+		folders, err := store.CountFoldersByPrefix(ctx, da.ID, "")
+		if err != nil {
+			return nil, err
+		}
+		if files+folders > 0 && !replace {
+			return nil, errs.New(errs.ErrConflict, "account %s already has %d files and %d folders indexed", da.ID, files, folders)
+		}
+	}
+
+	counts := &IndexDumpCounts{}
+	for _, da := range dump.Accounts {
+		if err := importAccount(ctx, store, da, replace); err != nil {
+			return counts, err
+		}
+		counts.Accounts++
+		counts.Folders += len(da.Folders)
+		counts.Files += len(da.Files)
+	}
+	return counts, nil
+}
+
+func importAccount(ctx context.Context, store Store, da DumpAccount, replace bool) error {
+	if err := store.UpsertAccount(ctx, &Account{ID: da.ID, Email: da.Email, DisplayName: da.DisplayName, IsPrimary: da.IsPrimary}); err != nil {
+		return err
+	}
+	if replace {
+		if err := store.ResetIndex(ctx, da.ID); err != nil {
+			return err
+		}
+	}
+	if st := da.SyncState; st != nil {
+		if err := store.UpsertSyncState(ctx, &SyncState{AccountID: da.ID, StartPageToken: st.StartPageToken,
+			LastSyncAt: st.LastSyncAt, Paused: st.Paused}); err != nil {
+			return err
+		}
+	}
+	for _, f := range da.Folders {
+		if err := store.UpsertFolder(ctx, &Folder{
+			ID:         f.ID,
+			AccountID:  da.ID,
+			Path:       f.Path,
+			DriveID:    f.DriveID,
+			ParentID:   f.ParentID,
+			OrphanedAt: f.OrphanedAt,
+			ModifiedAt: f.ModifiedAt,
+			CreatedAt:  f.CreatedAt,
+		}); err != nil {
+			return err
+		}
+	}
+	for _, f := range da.Files {
+		if err := store.UpsertFile(ctx, &FileRecord{
+			ID:          f.ID,
+			AccountID:   da.ID,
+			Path:        f.Path,
+			DriveID:     f.DriveID,
+			ParentID:    f.ParentID,
+			ShortcutID:  f.ShortcutID,
+			ETag:        f.ETag,
+			Checksum:    f.Checksum,
+			FastHash:    f.FastHash,
+			RemoteName:  f.RemoteName,
+			ReadOnly:    f.ReadOnly,
+			MimeType:    f.MimeType,
+			RevisionID:  f.RevisionID,
+			Trashed:     f.Trashed,
+			Starred:     f.Starred,
+			Shared:      f.Shared,
+			WebViewLink: f.WebViewLink,
+			Size:        f.Size,
+			ModifiedAt:  f.ModifiedAt,
+			CreatedAt:   f.CreatedAt,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
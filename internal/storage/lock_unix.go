@@ -0,0 +1,28 @@
+//go:build linux || darwin
+
+package storage
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// lockFile takes an exclusive lock on path, creating it if needed. The lock
+// is released when the returned file is closed, or when the process exits.
+func lockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errs.New(errs.ErrConflict, "encrypted database is open in another process (is the daemon running?)")
+		}
+		return nil, err
+	}
+	return f, nil
+}
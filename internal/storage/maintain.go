@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// autoVacuumIncremental is the PRAGMA auto_vacuum value for incremental mode.
+const autoVacuumIncremental = 2
+
+// MaintenanceReport describes one maintenance pass over the database.
+type MaintenanceReport struct {
+	// Problems lists what the integrity check found; empty means it passed.
+	Problems []string
+	// Converted is set when the database was rebuilt to allow incremental
+	// vacuuming, which happens once for databases created before it.
+	Converted bool
+	// FreedPages counts the free pages returned to the filesystem.
+	FreedPages int64
+	Took       time.Duration
+}
+
+// Maintain checks the database's integrity, returns its free pages to the
+// filesystem and refreshes the query planner's statistics. quick trades the
+// full integrity_check, which reads every index, for quick_check. When the
+// check finds problems nothing is written and an error is returned along
+// with the report.
+func (s *Storage) Maintain(ctx context.Context, quick bool) (*MaintenanceReport, error) {
+	started := time.Now()
+	report := &MaintenanceReport{}
+	problems, err := s.CheckIntegrity(ctx, quick)
+	if err != nil {
+		return nil, err
+	}
+	if len(problems) > 0 {
+		report.Problems = problems
+		report.Took = time.Since(started)
+		return report, fmt.Errorf("database integrity check failed: %s", strings.Join(problems, "; "))
+	}
+
+	var mode int
+	if err := s.DB.QueryRowContext(ctx, `PRAGMA auto_vacuum`).Scan(&mode); err != nil {
+		return nil, err
+	}
+	if mode != autoVacuumIncremental {
+		// The mode of an existing database only changes on a full VACUUM.
+		if _, err := s.DB.ExecContext(ctx, `PRAGMA auto_vacuum = INCREMENTAL`); err != nil {
+			return nil, err
+		}
+		if _, err := s.DB.ExecContext(ctx, `VACUUM`); err != nil {
+			return nil, err
+		}
+		report.Converted = true
+	}
+	before, err := s.freePages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The pragma frees one page per step, so every row must be read.
+	rows, err := s.DB.QueryContext(ctx, `PRAGMA incremental_vacuum`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	after, err := s.freePages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.FreedPages = before - after
+
+	if _, err := s.DB.ExecContext(ctx, `ANALYZE`); err != nil {
+		return nil, err
+	}
+	report.Took = time.Since(started)
+	return report, s.SetSettingTime(ctx, SettingLastMaintenance, time.Now())
+}
+
+// CheckIntegrity runs SQLite's integrity_check, or quick_check when quick is
+// set, and returns the problems it reports.
+func (s *Storage) CheckIntegrity(ctx context.Context, quick bool) ([]string, error) {
+	return checkIntegrity(ctx, s.DB, quick)
+}
+
+func checkIntegrity(ctx context.Context, db *sql.DB, quick bool) ([]string, error) {
+	pragma := `PRAGMA integrity_check`
+	if quick {
+		pragma = `PRAGMA quick_check`
+	}
+	rows, err := db.QueryContext(ctx, pragma)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	return problems, rows.Err()
+}
+
+func (s *Storage) freePages(ctx context.Context) (int64, error) {
+	var n int64
+	err := s.DB.QueryRowContext(ctx, `PRAGMA freelist_count`).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// keepSchemaBackups is how many pre-migration copies of the database are
+// kept; older ones are removed after each new backup.
+const keepSchemaBackups = 3
+
+// Migration describes one schema migration and whether it has run.
+type Migration struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// newMigrator returns a goose provider over the embedded migrations. Each
+// migration file has an Up and a Down section and runs in a transaction;
+// applied versions are recorded in the goose_db_version table.
+func newMigrator(db *sql.DB) (*goose.Provider, error) {
+	fsys, err := fs.Sub(migrationsFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider(goose.DialectSQLite3, db, fsys)
+}
+
+// migrate brings the schema up to date. A database that already holds data
+// is copied next to itself first, so a failed or unwanted migration can be
+// undone by restoring the copy.
+func migrate(ctx context.Context, db *sql.DB, sealed *sealedDB, dbPath string, logger *zap.Logger) error {
+	migrator, err := newMigrator(db)
+	if err != nil {
+		return err
+	}
+	current, target, err := migrator.GetVersions(ctx)
+	if err != nil {
+		return err
+	}
+	if current >= target {
+		return nil
+	}
+	if current > 0 {
+		backup, err := backupDatabase(ctx, db, sealed, dbPath, current)
+		if err != nil {
+			return fmt.Errorf("back up database before migrating: %w", err)
+		}
+		logger.Info("database backed up before migrating", zap.String("backup", backup), zap.Int64("version", current))
+	}
+
+	logger.Info("applying migrations", zap.Int64("from", current), zap.Int64("to", target))
+	results, err := migrator.Up(ctx)
+	for _, res := range results {
+		logger.Debug("migration applied", zap.String("file", filepath.Base(res.Source.Path)), zap.Duration("took", res.Duration))
+	}
+	if err != nil {
+		return err
+	}
+	logger.Info("migrations complete")
+	return nil
+}
+
+// backupDatabase writes a consistent copy of the database to
+// "<db>.v<version>.bak" and prunes older copies. An encrypted database is
+// backed up by copying its file, which stays sealed.
+func backupDatabase(ctx context.Context, db *sql.DB, sealed *sealedDB, dbPath string, version int64) (string, error) {
+	backup := fmt.Sprintf("%s.v%d.bak", dbPath, version)
+	if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if sealed != nil {
+		if err := sealed.flush(db); err != nil {
+			return "", err
+		}
+		if err := sealed.backup(backup); err != nil {
+			return "", err
+		}
+	} else if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, backup); err != nil {
+		return "", err
+	}
+	return backup, pruneSchemaBackups(dbPath)
+}
+
+// SchemaBackups lists the pre-migration copies of the database at dbPath,
+// newest schema version first.
+func SchemaBackups(dbPath string) ([]string, error) {
+	matches, err := filepath.Glob(dbPath + ".v*.bak")
+	if err != nil {
+		return nil, err
+	}
+	version := func(p string) int64 {
+		v, _ := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(p, dbPath+".v"), ".bak"), 10, 64)
+		return v
+	}
+	sort.Slice(matches, func(i, j int) bool { return version(matches[i]) > version(matches[j]) })
+	return matches, nil
+}
+
+func pruneSchemaBackups(dbPath string) error {
+	backups, err := SchemaBackups(dbPath)
+	if err != nil || len(backups) <= keepSchemaBackups {
+		return err
+	}
+	for _, old := range backups[keepSchemaBackups:] {
+		if err := os.Remove(old); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// openUnmigrated opens the database cfg points at without migrating it, for
+// inspecting or rolling back its schema.
+func openUnmigrated(cfg *config.Config) (*sql.DB, *sealedDB, error) {
+	if _, err := os.Stat(cfg.DatabasePath); err != nil {
+		return nil, nil, err
+	}
+	return openDatabase(cfg, zap.NewNop())
+}
+
+// SchemaStatus lists every known migration and whether it has been applied
+// to the database cfg points at. Unlike NewStorage it does not migrate, so
+// pending migrations show as such.
+func SchemaStatus(ctx context.Context, cfg *config.Config) ([]Migration, error) {
+	db, sealed, err := openUnmigrated(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer closeDatabase(db, sealed)
+	migrator, err := newMigrator(db)
+	if err != nil {
+		return nil, err
+	}
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Migration, 0, len(status))
+	for _, st := range status {
+		out = append(out, Migration{
+			Version:   st.Source.Version,
+			Name:      filepath.Base(st.Source.Path),
+			Applied:   st.State == goose.StateApplied,
+			AppliedAt: st.AppliedAt,
+		})
+	}
+	return out, nil
+}
+
+// RollBack undoes migrations above version on the database cfg points at,
+// after backing it up. The daemon must not be running, and the next start
+// applies the migrations again unless an older build is run instead.
+func RollBack(ctx context.Context, cfg *config.Config, logger *zap.Logger, version int64) (err error) {
+	if version < 0 {
+		return errs.New(errs.ErrInvalidArgument, "schema version cannot be negative")
+	}
+	db, sealed, err := openUnmigrated(cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := closeDatabase(db, sealed); err == nil {
+			err = cerr
+		}
+	}()
+
+	migrator, err := newMigrator(db)
+	if err != nil {
+		return err
+	}
+	current, err := migrator.GetDBVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version >= current {
+		return errs.New(errs.ErrInvalidArgument, "database is at version %d; nothing to roll back to %d", current, version)
+	}
+	backup, err := backupDatabase(ctx, db, sealed, cfg.DatabasePath, current)
+	if err != nil {
+		return fmt.Errorf("back up database before rolling back: %w", err)
+	}
+	logger.Info("database backed up before rolling back", zap.String("backup", backup), zap.Int64("version", current))
+	results, err := migrator.DownTo(ctx, version)
+	for _, res := range results {
+		logger.Info("migration rolled back", zap.String("file", filepath.Base(res.Source.Path)))
+	}
+	return err
+}
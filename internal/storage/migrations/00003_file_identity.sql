@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE files ADD COLUMN device INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN inode INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_files_account_inode ON files(account_id, device, inode);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_files_account_inode;
+ALTER TABLE files DROP COLUMN inode;
+ALTER TABLE files DROP COLUMN device;
@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS transfer_history (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  account_id TEXT NOT NULL,
+  path TEXT NOT NULL,
+  direction TEXT NOT NULL,
+  bytes INTEGER NOT NULL DEFAULT 0,
+  status TEXT NOT NULL DEFAULT '',
+  error TEXT NOT NULL DEFAULT '',
+  started_at INTEGER NOT NULL DEFAULT 0,
+  finished_at INTEGER NOT NULL DEFAULT 0,
+  FOREIGN KEY(account_id) REFERENCES accounts(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_transfer_history_finished ON transfer_history(finished_at);
+
+CREATE TABLE IF NOT EXISTS error_history (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  account_id TEXT NOT NULL,
+  path TEXT NOT NULL DEFAULT '',
+  op TEXT NOT NULL DEFAULT '',
+  message TEXT NOT NULL,
+  occurred_at INTEGER NOT NULL DEFAULT 0,
+  FOREIGN KEY(account_id) REFERENCES accounts(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_error_history_occurred ON error_history(occurred_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS error_history;
+DROP TABLE IF EXISTS transfer_history;
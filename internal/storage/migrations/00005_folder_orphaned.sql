@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE folders ADD COLUMN orphaned_at INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE folders DROP COLUMN orphaned_at;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS file_versions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  path TEXT NOT NULL,
+  checksum TEXT NOT NULL,
+  size INTEGER NOT NULL DEFAULT 0,
+  stashed_at INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_file_versions_path ON file_versions(path, stashed_at);
+CREATE INDEX IF NOT EXISTS idx_file_versions_checksum ON file_versions(checksum);
+
+-- +goose Down
+DROP TABLE IF EXISTS file_versions;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS file_blocks (
+  account_id TEXT NOT NULL,
+  path TEXT NOT NULL,
+  block_index INTEGER NOT NULL,
+  block_size INTEGER NOT NULL,
+  size INTEGER NOT NULL,
+  checksum TEXT NOT NULL,
+  PRIMARY KEY (account_id, path, block_index)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS file_blocks;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE files ADD COLUMN parent_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE files ADD COLUMN shortcut_id TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE files DROP COLUMN shortcut_id;
+ALTER TABLE files DROP COLUMN parent_id;
@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX IF NOT EXISTS idx_files_account_checksum ON files(account_id, checksum, size);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_files_account_checksum;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS symlinks (
+  account_id TEXT NOT NULL,
+  path TEXT NOT NULL,
+  target TEXT NOT NULL,
+  updated_at INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (account_id, path)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS symlinks;
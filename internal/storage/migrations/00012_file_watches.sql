@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS file_watches (
+  account_id TEXT NOT NULL,
+  path TEXT NOT NULL,
+  drive_id TEXT NOT NULL,
+  last_version INTEGER NOT NULL DEFAULT 0,
+  checked_at INTEGER NOT NULL DEFAULT 0,
+  created_at INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (account_id, path)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS file_watches;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS placeholders (
+  account_id TEXT NOT NULL,
+  path TEXT NOT NULL,
+  created_at INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (account_id, path)
+);
+
+CREATE TABLE IF NOT EXISTS pinned_folders (
+  account_id TEXT NOT NULL,
+  path TEXT NOT NULL,
+  pinned_at INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (account_id, path)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS pinned_folders;
+DROP TABLE IF EXISTS placeholders;
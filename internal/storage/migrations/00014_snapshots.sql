@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS snapshots (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  account_id TEXT NOT NULL,
+  name TEXT NOT NULL,
+  created_at INTEGER NOT NULL DEFAULT 0,
+  completed_at INTEGER NOT NULL DEFAULT 0,
+  UNIQUE (account_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS snapshot_entries (
+  snapshot_id INTEGER NOT NULL REFERENCES snapshots(id) ON DELETE CASCADE,
+  path TEXT NOT NULL,
+  size INTEGER NOT NULL DEFAULT 0,
+  modified_at INTEGER NOT NULL DEFAULT 0,
+  checksum TEXT NOT NULL,
+  action TEXT NOT NULL,
+  base_snapshot_id INTEGER NOT NULL,
+  drive_id TEXT NOT NULL DEFAULT '',
+  PRIMARY KEY (snapshot_id, path)
+);
+CREATE INDEX IF NOT EXISTS idx_snapshot_entries_pending ON snapshot_entries(drive_id, snapshot_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS snapshot_entries;
+DROP TABLE IF EXISTS snapshots;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS devices (
+  account_id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  drive_id TEXT NOT NULL,
+  root_drive_id TEXT NOT NULL DEFAULT '',
+  created_at INTEGER NOT NULL DEFAULT 0,
+  updated_at INTEGER NOT NULL DEFAULT 0
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS devices;
@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS transfer_sessions (
+  id TEXT PRIMARY KEY,
+  account_id TEXT NOT NULL,
+  direction TEXT NOT NULL,
+  path TEXT NOT NULL,
+  drive_id TEXT NOT NULL DEFAULT '',
+  session_uri TEXT NOT NULL DEFAULT '',
+  size INTEGER NOT NULL DEFAULT 0,
+  checksum TEXT NOT NULL DEFAULT '',
+  offset INTEGER NOT NULL DEFAULT 0,
+  chunk_size INTEGER NOT NULL DEFAULT 0,
+  created_at INTEGER NOT NULL DEFAULT 0,
+  updated_at INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfer_sessions_path ON transfer_sessions(account_id, direction, path);
+
+CREATE TABLE IF NOT EXISTS transfer_chunks (
+  session_id TEXT NOT NULL,
+  chunk_index INTEGER NOT NULL,
+  checksum TEXT NOT NULL,
+  PRIMARY KEY (session_id, chunk_index)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS transfer_chunks;
+DROP INDEX IF EXISTS idx_transfer_sessions_path;
+DROP TABLE IF EXISTS transfer_sessions;
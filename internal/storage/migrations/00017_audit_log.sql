@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS audit_log (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  account_id TEXT NOT NULL,
+  action TEXT NOT NULL,
+  path TEXT NOT NULL,
+  drive_id TEXT NOT NULL DEFAULT '',
+  detail TEXT NOT NULL DEFAULT '',
+  recorded_at INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_account ON audit_log(account_id, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_audit_log_account;
+DROP TABLE IF EXISTS audit_log;
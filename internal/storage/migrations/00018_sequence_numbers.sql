@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE status_events ADD COLUMN seq INTEGER NOT NULL DEFAULT 0;
+UPDATE status_events SET seq = id;
+ALTER TABLE pending_ops ADD COLUMN seq INTEGER NOT NULL DEFAULT 0;
+UPDATE pending_ops SET seq = rowid;
+CREATE INDEX IF NOT EXISTS idx_pending_ops_seq ON pending_ops(account_id, seq);
+CREATE TABLE IF NOT EXISTS op_sequences (
+  account_id TEXT PRIMARY KEY,
+  last_seq INTEGER NOT NULL
+);
+INSERT INTO op_sequences (account_id, last_seq)
+SELECT account_id, MAX(seq) FROM pending_ops GROUP BY account_id;
+
+-- +goose Down
+DROP TABLE IF EXISTS op_sequences;
+DROP INDEX IF EXISTS idx_pending_ops_seq;
+ALTER TABLE pending_ops DROP COLUMN seq;
+ALTER TABLE status_events DROP COLUMN seq;
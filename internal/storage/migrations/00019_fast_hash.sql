@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE files ADD COLUMN fast_hash TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE files DROP COLUMN fast_hash;
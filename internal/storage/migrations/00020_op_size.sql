@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE pending_ops ADD COLUMN size INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE pending_ops DROP COLUMN size;
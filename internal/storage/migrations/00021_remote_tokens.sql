@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS remote_tokens (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL DEFAULT '',
+  role TEXT NOT NULL,
+  token_hash TEXT NOT NULL,
+  created_at INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_remote_tokens_hash ON remote_tokens(token_hash);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_remote_tokens_hash;
+DROP TABLE IF EXISTS remote_tokens;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS account_quota (
+  account_id TEXT PRIMARY KEY,
+  limit_bytes INTEGER NOT NULL DEFAULT 0,
+  usage_bytes INTEGER NOT NULL DEFAULT 0,
+  drive_bytes INTEGER NOT NULL DEFAULT 0,
+  trash_bytes INTEGER NOT NULL DEFAULT 0,
+  checked_at INTEGER NOT NULL DEFAULT 0
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS account_quota;
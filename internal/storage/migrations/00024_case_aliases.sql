@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS case_aliases (
+  account_id TEXT NOT NULL,
+  drive_id TEXT NOT NULL,
+  remote_path TEXT NOT NULL,
+  local_path TEXT NOT NULL,
+  created_at INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (account_id, drive_id)
+);
+CREATE INDEX IF NOT EXISTS idx_case_aliases_local ON case_aliases(account_id, local_path);
+
+-- +goose Down
+DROP TABLE IF EXISTS case_aliases;
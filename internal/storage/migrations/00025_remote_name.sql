@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE files ADD COLUMN remote_name TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE files DROP COLUMN remote_name;
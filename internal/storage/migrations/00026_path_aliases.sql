@@ -0,0 +1,10 @@
+-- +goose Up
+-- Aliases now also cover Drive siblings with identical names.
+ALTER TABLE case_aliases RENAME TO path_aliases;
+DROP INDEX IF EXISTS idx_case_aliases_local;
+CREATE INDEX IF NOT EXISTS idx_path_aliases_local ON path_aliases(account_id, local_path);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_path_aliases_local;
+ALTER TABLE path_aliases RENAME TO case_aliases;
+CREATE INDEX IF NOT EXISTS idx_case_aliases_local ON case_aliases(account_id, local_path);
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE files ADD COLUMN read_only INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE files DROP COLUMN read_only;
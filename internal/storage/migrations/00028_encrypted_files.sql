@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS encrypted_files (
+  account_id TEXT NOT NULL,
+  drive_id TEXT NOT NULL,
+  plain_name TEXT NOT NULL DEFAULT '',
+  plain_md5 TEXT NOT NULL DEFAULT '',
+  plain_size INTEGER NOT NULL DEFAULT 0,
+  cipher_md5 TEXT NOT NULL DEFAULT '',
+  updated_at INTEGER NOT NULL,
+  PRIMARY KEY (account_id, drive_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS encrypted_files;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS folder_policies (
+  account_id TEXT NOT NULL,
+  path TEXT NOT NULL,
+  direction TEXT NOT NULL DEFAULT '',
+  ignore_patterns TEXT NOT NULL DEFAULT '',
+  export_format TEXT NOT NULL DEFAULT '',
+  bandwidth_class TEXT NOT NULL DEFAULT '',
+  updated_at INTEGER NOT NULL,
+  PRIMARY KEY (account_id, path)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS folder_policies;
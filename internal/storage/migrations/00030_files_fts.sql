@@ -0,0 +1,37 @@
+-- +goose Up
+-- Full-text index over file names and paths. The trigram tokenizer matches
+-- any substring of three or more characters, case-insensitively. Rows share
+-- the rowid of their files row and are kept in step by the triggers below;
+-- a migration that rebuilds the files table must rebuild this index too.
+CREATE VIRTUAL TABLE IF NOT EXISTS files_fts USING fts5(name, path, tokenize = 'trigram');
+
+-- The name is what follows the last "/" of the path.
+INSERT INTO files_fts (rowid, name, path)
+SELECT rowid, substr(path, length(rtrim(path, replace(path, '/', ''))) + 1), path FROM files;
+
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS files_fts_insert AFTER INSERT ON files BEGIN
+  INSERT INTO files_fts (rowid, name, path)
+  VALUES (new.rowid, substr(new.path, length(rtrim(new.path, replace(new.path, '/', ''))) + 1), new.path);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS files_fts_delete AFTER DELETE ON files BEGIN
+  DELETE FROM files_fts WHERE rowid = old.rowid;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS files_fts_update AFTER UPDATE OF path ON files BEGIN
+  DELETE FROM files_fts WHERE rowid = old.rowid;
+  INSERT INTO files_fts (rowid, name, path)
+  VALUES (new.rowid, substr(new.path, length(rtrim(new.path, replace(new.path, '/', ''))) + 1), new.path);
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER IF EXISTS files_fts_update;
+DROP TRIGGER IF EXISTS files_fts_delete;
+DROP TRIGGER IF EXISTS files_fts_insert;
+DROP TABLE IF EXISTS files_fts;
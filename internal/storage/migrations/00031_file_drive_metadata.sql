@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE files ADD COLUMN mime_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE files ADD COLUMN revision_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE files ADD COLUMN trashed INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN starred INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN shared INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN web_view_link TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE files DROP COLUMN web_view_link;
+ALTER TABLE files DROP COLUMN shared;
+ALTER TABLE files DROP COLUMN starred;
+ALTER TABLE files DROP COLUMN trashed;
+ALTER TABLE files DROP COLUMN revision_id;
+ALTER TABLE files DROP COLUMN mime_type;
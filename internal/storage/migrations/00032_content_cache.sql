@@ -0,0 +1,21 @@
+-- +goose Up
+-- Byte ranges of Drive content kept on disk for streaming reads. The blob
+-- lives under the cache dir, named by the SHA-256 in checksum; revision is
+-- the content revision the range was read from, so stale ranges never match.
+CREATE TABLE IF NOT EXISTS content_cache (
+  account_id TEXT NOT NULL,
+  drive_id TEXT NOT NULL,
+  revision TEXT NOT NULL,
+  offset INTEGER NOT NULL,
+  length INTEGER NOT NULL,
+  checksum TEXT NOT NULL,
+  last_access INTEGER NOT NULL,
+  created_at INTEGER NOT NULL,
+  PRIMARY KEY (account_id, drive_id, revision, offset)
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_cache_last_access ON content_cache(last_access);
+CREATE INDEX IF NOT EXISTS idx_content_cache_checksum ON content_cache(checksum);
+
+-- +goose Down
+DROP TABLE IF EXISTS content_cache;
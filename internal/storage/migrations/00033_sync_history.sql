@@ -0,0 +1,24 @@
+-- +goose Up
+-- One row per finished sync operation, kept as an audit trail. op is the
+-- pending op type (or "conflict" / "move_local" for work done in place),
+-- detail holds a related path such as a move source or a conflict copy, and
+-- outcome is "succeeded", "failed" or "detected".
+CREATE TABLE IF NOT EXISTS sync_history (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  account_id TEXT NOT NULL,
+  op TEXT NOT NULL,
+  path TEXT NOT NULL,
+  drive_id TEXT NOT NULL DEFAULT '',
+  detail TEXT NOT NULL DEFAULT '',
+  bytes INTEGER NOT NULL DEFAULT 0,
+  outcome TEXT NOT NULL,
+  error TEXT NOT NULL DEFAULT '',
+  started_at INTEGER NOT NULL,
+  finished_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_history_account_finished ON sync_history(account_id, finished_at);
+CREATE INDEX IF NOT EXISTS idx_sync_history_path ON sync_history(account_id, path);
+
+-- +goose Down
+DROP TABLE IF EXISTS sync_history;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- Per-account daily transfer counters. day is the local calendar date
+-- (YYYY-MM-DD) the work finished on; rows are added to as ops finish.
+CREATE TABLE IF NOT EXISTS stats (
+  account_id TEXT NOT NULL,
+  day TEXT NOT NULL,
+  bytes_up INTEGER NOT NULL DEFAULT 0,
+  bytes_down INTEGER NOT NULL DEFAULT 0,
+  files_synced INTEGER NOT NULL DEFAULT 0,
+  errors INTEGER NOT NULL DEFAULT 0,
+  updated_at INTEGER NOT NULL,
+  PRIMARY KEY (account_id, day)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS stats;
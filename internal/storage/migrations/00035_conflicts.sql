@@ -0,0 +1,23 @@
+-- +goose Up
+-- Conflicts between a local edit and a Drive change, kept until someone
+-- resolves them. base_revision is the revision the local edit started from
+-- and remote_revision the Drive content it collided with; copy_path is set
+-- when the local edit was saved aside as a conflicted copy.
+CREATE TABLE IF NOT EXISTS conflicts (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  account_id TEXT NOT NULL,
+  path TEXT NOT NULL,
+  drive_id TEXT NOT NULL DEFAULT '',
+  base_revision TEXT NOT NULL DEFAULT '',
+  remote_revision TEXT NOT NULL DEFAULT '',
+  copy_path TEXT NOT NULL DEFAULT '',
+  state TEXT NOT NULL DEFAULT 'unresolved',
+  resolution TEXT NOT NULL DEFAULT '',
+  detected_at INTEGER NOT NULL,
+  resolved_at INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_conflicts_unresolved ON conflicts(account_id, path) WHERE state = 'unresolved';
+
+-- +goose Down
+DROP TABLE IF EXISTS conflicts;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Ignore patterns added at runtime, on top of ignore_patterns in the config.
+-- root is the sync root a rule belongs to ('' for any root), and source
+-- records who added it.
+CREATE TABLE IF NOT EXISTS ignore_rules (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  account_id TEXT NOT NULL,
+  root TEXT NOT NULL DEFAULT '',
+  pattern TEXT NOT NULL,
+  source TEXT NOT NULL DEFAULT '',
+  enabled INTEGER NOT NULL DEFAULT 1,
+  created_at INTEGER NOT NULL,
+  UNIQUE (account_id, root, pattern)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS ignore_rules;
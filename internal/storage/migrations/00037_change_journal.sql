@@ -0,0 +1,26 @@
+-- +goose Up
+-- Every change read from the Drive changes feed, newest last. kind is
+-- "file", "trashed" or "removed"; version is the file's Drive version, when
+-- the change carries the file; outcome is "applied", "failed", "duplicate"
+-- or "superseded". payload is the change as received, so a failed one can
+-- be applied again.
+CREATE TABLE IF NOT EXISTS change_journal (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  account_id TEXT NOT NULL,
+  drive_id TEXT NOT NULL,
+  kind TEXT NOT NULL,
+  name TEXT NOT NULL DEFAULT '',
+  version INTEGER NOT NULL DEFAULT 0,
+  changed_at INTEGER NOT NULL DEFAULT 0,
+  received_at INTEGER NOT NULL,
+  outcome TEXT NOT NULL,
+  error TEXT NOT NULL DEFAULT '',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  payload BLOB
+);
+
+CREATE INDEX IF NOT EXISTS idx_change_journal_drive_id ON change_journal(account_id, drive_id, version);
+CREATE INDEX IF NOT EXISTS idx_change_journal_failed ON change_journal(account_id, id) WHERE outcome = 'failed';
+
+-- +goose Down
+DROP TABLE IF EXISTS change_journal;
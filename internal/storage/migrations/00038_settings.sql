@@ -0,0 +1,11 @@
+-- +goose Up
+-- Small pieces of daemon state, such as when the database was last
+-- maintained, stored as text under a dotted key.
+CREATE TABLE IF NOT EXISTS settings (
+  key TEXT PRIMARY KEY,
+  value TEXT NOT NULL,
+  updated_at INTEGER NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS settings;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Looking up the ops queued for one path happens for every local event;
+-- without this it scans all of an account's ops.
+CREATE INDEX IF NOT EXISTS idx_pending_ops_path ON pending_ops(account_id, path);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_pending_ops_path;
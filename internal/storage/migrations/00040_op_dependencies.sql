@@ -0,0 +1,10 @@
+-- +goose Up
+-- depends_on names an op that has to finish before this one may run, such
+-- as the creation of a parent folder. not_before holds a failed op back
+-- until its retry backoff has passed.
+ALTER TABLE pending_ops ADD COLUMN depends_on TEXT NOT NULL DEFAULT '';
+ALTER TABLE pending_ops ADD COLUMN not_before INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE pending_ops DROP COLUMN not_before;
+ALTER TABLE pending_ops DROP COLUMN depends_on;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- sync_root is where an account's files are mirrored; empty uses the
+-- configured sync_root.
+ALTER TABLE accounts ADD COLUMN sync_root TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE accounts DROP COLUMN sync_root;
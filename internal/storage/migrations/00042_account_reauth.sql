@@ -0,0 +1,9 @@
+-- +goose Up
+-- reauth_reason is set while an account's refresh token is revoked or
+-- expired and the account has to sign in again.
+ALTER TABLE accounts ADD COLUMN reauth_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN reauth_at INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE accounts DROP COLUMN reauth_at;
+ALTER TABLE accounts DROP COLUMN reauth_reason;
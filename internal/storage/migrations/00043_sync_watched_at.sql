@@ -0,0 +1,7 @@
+-- +goose Up
+-- watched_at is the last time the daemon had the sync root watched with
+-- nothing left to apply; files modified after it are re-checked on startup.
+ALTER TABLE sync_state ADD COLUMN watched_at INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE sync_state DROP COLUMN watched_at;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- root is the watch root an event's path is relative to; empty for the sync
+-- root.
+ALTER TABLE status_events ADD COLUMN root TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE status_events DROP COLUMN root;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- drive_id is the Drive shortcut a link was uploaded as; empty until then.
+ALTER TABLE symlinks ADD COLUMN drive_id TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE symlinks DROP COLUMN drive_id;
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// MarkPlaceholder records that the local copy of path is a zero-byte
+// placeholder. The file's metadata stays in the files table.
+func (s *Storage) MarkPlaceholder(ctx context.Context, accountID, path string) error {
+	if accountID == "" {
+		return errs.New(errs.ErrInvalidArgument, "placeholder account_id cannot be empty")
+	}
+	if path == "" {
+		return errs.New(errs.ErrInvalidArgument, "placeholder path cannot be empty")
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO placeholders (account_id, path, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(account_id, path) DO NOTHING
+	`, accountID, path, unixTime(time.Now()))
+	return err
+}
+
+// IsPlaceholder reports whether path is still an unhydrated placeholder.
+func (s *Storage) IsPlaceholder(ctx context.Context, accountID, path string) (bool, error) {
+	var n int
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT COUNT(1) FROM placeholders WHERE account_id = ? AND path = ?
+	`, accountID, path).Scan(&n)
+	return n > 0, err
+}
+
+// ClearPlaceholder forgets a placeholder once its content is local.
+func (s *Storage) ClearPlaceholder(ctx context.Context, accountID, path string) error {
+	_, err := s.DB.ExecContext(ctx, `
+		DELETE FROM placeholders WHERE account_id = ? AND path = ?
+	`, accountID, path)
+	return err
+}
+
+// ListPlaceholders returns placeholder files at or under prefix. An empty
+// prefix lists all of them.
+func (s *Storage) ListPlaceholders(ctx context.Context, accountID, prefix string) ([]FileRecord, error) {
+	query := `
+		SELECT ` + fileColumns + `
+		FROM files
+		WHERE account_id = ? AND path IN (SELECT path FROM placeholders WHERE account_id = ?)
+	`
+	args := []any{accountID, accountID}
+	if prefix != "" {
+		cond, condArgs := subtreeRange("path", prefix)
+		query += ` AND ` + cond
+		args = append(args, condArgs...)
+	}
+	query += ` ORDER BY path ASC`
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []FileRecord
+	for rows.Next() {
+		rec, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *rec)
+	}
+	return out, rows.Err()
+}
+
+// PinFolder keeps everything under path downloaded even in on-demand mode.
+func (s *Storage) PinFolder(ctx context.Context, accountID, path string) error {
+	if accountID == "" {
+		return errs.New(errs.ErrInvalidArgument, "pinned_folder account_id cannot be empty")
+	}
+	if path == "" {
+		return errs.New(errs.ErrInvalidArgument, "pinned_folder path cannot be empty")
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO pinned_folders (account_id, path, pinned_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(account_id, path) DO NOTHING
+	`, accountID, path, unixTime(time.Now()))
+	return err
+}
+
+// UnpinFolder removes a pin. It reports whether one existed.
+func (s *Storage) UnpinFolder(ctx context.Context, accountID, path string) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		DELETE FROM pinned_folders WHERE account_id = ? AND path = ?
+	`, accountID, path)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ListPinnedFolders returns pinned folder paths in order.
+func (s *Storage) ListPinnedFolders(ctx context.Context, accountID string) ([]string, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT path FROM pinned_folders WHERE account_id = ? ORDER BY path ASC
+	`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// IsPinned reports whether path is a pinned folder or lies under one.
+func (s *Storage) IsPinned(ctx context.Context, accountID, path string) (bool, error) {
+	var n int
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT COUNT(1) FROM pinned_folders
+		WHERE account_id = ? AND (path = ? OR substr(?, 1, length(path) + 1) = path || '/')
+	`, accountID, path, path).Scan(&n)
+	return n > 0, err
+}
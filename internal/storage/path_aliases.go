@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// PathAlias records a Drive file kept under a different local path because
+// its Drive path is already taken locally: a sibling has the same name, or,
+// on a case-insensitive filesystem, a name that differs only by case.
+type PathAlias struct {
+	AccountID  string
+	DriveID    string
+	RemotePath string
+	LocalPath  string
+	CreatedAt  time.Time
+}
+
+// SavePathAlias adds or replaces the alias for a Drive file.
+func (s *Storage) SavePathAlias(ctx context.Context, alias *PathAlias) error {
+	if alias == nil {
+		return nil
+	}
+	if alias.AccountID == "" || alias.DriveID == "" {
+		return errs.New(errs.ErrInvalidArgument, "case alias account_id and drive_id are required")
+	}
+	if alias.RemotePath == "" || alias.LocalPath == "" {
+		return errs.New(errs.ErrInvalidArgument, "case alias paths cannot be empty")
+	}
+	if alias.CreatedAt.IsZero() {
+		alias.CreatedAt = time.Now()
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO path_aliases (account_id, drive_id, remote_path, local_path, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(account_id, drive_id) DO UPDATE SET
+			remote_path = excluded.remote_path,
+			local_path = excluded.local_path,
+			created_at = excluded.created_at
+	`, alias.AccountID, alias.DriveID, alias.RemotePath, alias.LocalPath, unixTime(alias.CreatedAt))
+	return err
+}
+
+// GetPathAlias returns the alias for a Drive file, or nil.
+func (s *Storage) GetPathAlias(ctx context.Context, accountID, driveID string) (*PathAlias, error) {
+	row := s.DB.QueryRowContext(ctx, `
+		SELECT account_id, drive_id, remote_path, local_path, created_at
+		FROM path_aliases WHERE account_id = ? AND drive_id = ?
+	`, accountID, driveID)
+	alias, err := scanPathAlias(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return alias, err
+}
+
+// ListPathAliases returns an account's aliases ordered by local path.
+func (s *Storage) ListPathAliases(ctx context.Context, accountID string) ([]PathAlias, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT account_id, drive_id, remote_path, local_path, created_at
+		FROM path_aliases WHERE account_id = ?
+		ORDER BY local_path
+	`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []PathAlias
+	for rows.Next() {
+		alias, err := scanPathAlias(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *alias)
+	}
+	return out, rows.Err()
+}
+
+// DeletePathAlias removes the alias for a Drive file. It is not an error if
+// there is none.
+func (s *Storage) DeletePathAlias(ctx context.Context, accountID, driveID string) error {
+	_, err := s.DB.ExecContext(ctx, `
+		DELETE FROM path_aliases WHERE account_id = ? AND drive_id = ?
+	`, accountID, driveID)
+	return err
+}
+
+// FindFilesFoldingCase returns files whose path equals path ignoring ASCII
+// case.
+func (s *Storage) FindFilesFoldingCase(ctx context.Context, accountID, path string) ([]FileRecord, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+fileColumns+`
+		FROM files
+		WHERE account_id = ? AND path = ? COLLATE NOCASE
+		ORDER BY path
+	`, accountID, path)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []FileRecord
+	for rows.Next() {
+		rec, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *rec)
+	}
+	return out, rows.Err()
+}
+
+func scanPathAlias(row rowScanner) (*PathAlias, error) {
+	var alias PathAlias
+	var createdAt int64
+	if err := row.Scan(&alias.AccountID, &alias.DriveID, &alias.RemotePath, &alias.LocalPath, &createdAt); err != nil {
+		return nil, err
+	}
+	alias.CreatedAt = fromUnix(createdAt)
+	return &alias, nil
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// FolderPolicy overrides sync settings for a folder and everything below
+// it. Empty fields inherit from the enclosing folder, and ultimately from
+// the configuration of the sync root.
+type FolderPolicy struct {
+	AccountID string
+	// Path is the folder relative to the sync root; "" is the root itself.
+	Path      string
+	Direction string
+	// Ignore lists glob patterns for entries to leave unsynced. Patterns add
+	// to those of enclosing folders rather than replacing them.
+	Ignore         []string
+	ExportFormat   string
+	BandwidthClass string
+	UpdatedAt      time.Time
+}
+
+// SaveFolderPolicy adds or replaces the policy for a folder.
+func (s *Storage) SaveFolderPolicy(ctx context.Context, policy *FolderPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.AccountID == "" {
+		return errs.New(errs.ErrInvalidArgument, "folder policy account_id is required")
+	}
+	for _, pattern := range policy.Ignore {
+		if pattern == "" || strings.Contains(pattern, "\n") {
+			return errs.New(errs.ErrInvalidArgument, "invalid ignore pattern %q", pattern)
+		}
+	}
+	if policy.UpdatedAt.IsZero() {
+		policy.UpdatedAt = time.Now()
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO folder_policies (account_id, path, direction, ignore_patterns, export_format, bandwidth_class, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account_id, path) DO UPDATE SET
+			direction = excluded.direction,
+			ignore_patterns = excluded.ignore_patterns,
+			export_format = excluded.export_format,
+			bandwidth_class = excluded.bandwidth_class,
+			updated_at = excluded.updated_at
+	`, policy.AccountID, policy.Path, policy.Direction, strings.Join(policy.Ignore, "\n"), policy.ExportFormat, policy.BandwidthClass, unixTime(policy.UpdatedAt))
+	return err
+}
+
+// GetFolderPolicy returns the policy attached to exactly path, or nil.
+func (s *Storage) GetFolderPolicy(ctx context.Context, accountID, path string) (*FolderPolicy, error) {
+	row := s.DB.QueryRowContext(ctx, `
+		SELECT account_id, path, direction, ignore_patterns, export_format, bandwidth_class, updated_at
+		FROM folder_policies WHERE account_id = ? AND path = ?
+	`, accountID, path)
+	policy, err := scanFolderPolicy(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return policy, err
+}
+
+// ListFolderPolicies returns an account's policies, shallowest path first.
+func (s *Storage) ListFolderPolicies(ctx context.Context, accountID string) ([]FolderPolicy, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT account_id, path, direction, ignore_patterns, export_format, bandwidth_class, updated_at
+		FROM folder_policies WHERE account_id = ?
+		ORDER BY length(path) - length(replace(path, '/', '')), path
+	`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []FolderPolicy
+	for rows.Next() {
+		policy, err := scanFolderPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *policy)
+	}
+	return out, rows.Err()
+}
+
+// DeleteFolderPolicy removes the policy attached to path.
+func (s *Storage) DeleteFolderPolicy(ctx context.Context, accountID, path string) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM folder_policies WHERE account_id = ? AND path = ?`, accountID, path)
+	return err
+}
+
+func scanFolderPolicy(row rowScanner) (*FolderPolicy, error) {
+	var policy FolderPolicy
+	var ignore string
+	var updatedAt int64
+	if err := row.Scan(&policy.AccountID, &policy.Path, &policy.Direction, &ignore, &policy.ExportFormat, &policy.BandwidthClass, &updatedAt); err != nil {
+		return nil, err
+	}
+	if ignore != "" {
+		policy.Ignore = strings.Split(ignore, "\n")
+	}
+	policy.UpdatedAt = fromUnix(updatedAt)
+	return &policy, nil
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Quota is the last Drive storage quota seen for an account, in bytes.
+// LimitBytes is zero for unlimited storage.
+type Quota struct {
+	AccountID  string
+	LimitBytes int64
+	UsageBytes int64
+	DriveBytes int64
+	TrashBytes int64
+	CheckedAt  time.Time
+}
+
+// GetQuota returns the stored quota for an account, or nil if it was never
+// checked.
+func (s *Storage) GetQuota(ctx context.Context, accountID string) (*Quota, error) {
+	var q Quota
+	var checkedAt int64
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT account_id, limit_bytes, usage_bytes, drive_bytes, trash_bytes, checked_at
+		FROM account_quota WHERE account_id = ?
+	`, accountID).Scan(&q.AccountID, &q.LimitBytes, &q.UsageBytes, &q.DriveBytes, &q.TrashBytes, &checkedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	q.CheckedAt = fromUnix(checkedAt)
+	return &q, nil
+}
+
+// SaveQuota replaces the stored quota for an account.
+func (s *Storage) SaveQuota(ctx context.Context, quota *Quota) error {
+	if quota == nil {
+		return nil
+	}
+	if quota.AccountID == "" {
+		return errs.New(errs.ErrInvalidArgument, "quota account_id cannot be empty")
+	}
+	if quota.CheckedAt.IsZero() {
+		quota.CheckedAt = time.Now()
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO account_quota (account_id, limit_bytes, usage_bytes, drive_bytes, trash_bytes, checked_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account_id) DO UPDATE SET
+			limit_bytes = excluded.limit_bytes,
+			usage_bytes = excluded.usage_bytes,
+			drive_bytes = excluded.drive_bytes,
+			trash_bytes = excluded.trash_bytes,
+			checked_at = excluded.checked_at
+	`, quota.AccountID, quota.LimitBytes, quota.UsageBytes, quota.DriveBytes, quota.TrashBytes, unixTime(quota.CheckedAt))
+	return err
+}
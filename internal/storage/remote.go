@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// RemoteToken grants a remote admin client a role. Only a hash of the
+// token itself is stored.
+type RemoteToken struct {
+	ID        string
+	Name      string
+	Role      string
+	TokenHash string
+	CreatedAt time.Time
+}
+
+// AddRemoteToken stores a remote token.
+func (s *Storage) AddRemoteToken(ctx context.Context, tok *RemoteToken) error {
+	if tok == nil {
+		return nil
+	}
+	if tok.ID == "" || tok.Role == "" || tok.TokenHash == "" {
+		return errs.New(errs.ErrInvalidArgument, "remote token id, role and hash cannot be empty")
+	}
+	if tok.CreatedAt.IsZero() {
+		tok.CreatedAt = time.Now()
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO remote_tokens (id, name, role, token_hash, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, tok.ID, tok.Name, tok.Role, tok.TokenHash, unixTime(tok.CreatedAt))
+	return err
+}
+
+// GetRemoteTokenByHash returns the token with the given hash, or nil when
+// there is none.
+func (s *Storage) GetRemoteTokenByHash(ctx context.Context, hash string) (*RemoteToken, error) {
+	var tok RemoteToken
+	var createdAt int64
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT id, name, role, token_hash, created_at FROM remote_tokens WHERE token_hash = ?
+	`, hash).Scan(&tok.ID, &tok.Name, &tok.Role, &tok.TokenHash, &createdAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	tok.CreatedAt = fromUnix(createdAt)
+	return &tok, nil
+}
+
+// ListRemoteTokens returns all remote tokens, oldest first.
+func (s *Storage) ListRemoteTokens(ctx context.Context) ([]RemoteToken, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, name, role, token_hash, created_at FROM remote_tokens ORDER BY created_at ASC, id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []RemoteToken
+	for rows.Next() {
+		var tok RemoteToken
+		var createdAt int64
+		if err := rows.Scan(&tok.ID, &tok.Name, &tok.Role, &tok.TokenHash, &createdAt); err != nil {
+			return nil, err
+		}
+		tok.CreatedAt = fromUnix(createdAt)
+		out = append(out, tok)
+	}
+	return out, rows.Err()
+}
+
+// DeleteRemoteToken revokes a remote token.
+func (s *Storage) DeleteRemoteToken(ctx context.Context, id string) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM remote_tokens WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errs.New(errs.ErrNotFound, "remote token %s not found", id)
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"modernc.org/sqlite"
+	"modernc.org/sqlite/vfs"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/encryption"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// sealedFlushInterval is how often committed changes to an encrypted
+// database are written back to disk.
+const sealedFlushInterval = 5 * time.Second
+
+// memoryPragmas configure the in-memory connection an encrypted database is
+// loaded into. There is no file underneath, so WAL and synchronous do not
+// apply.
+const memoryPragmas = "?_pragma=busy_timeout(5000)" +
+	"&_pragma=foreign_keys(1)" +
+	"&_txlock=immediate"
+
+// sealedDB is an encrypted database. SQLite runs on an in-memory copy,
+// restored from the decrypted file when the database is opened, and the
+// copy is serialized and sealed with encryption.NewEncrypter back to disk
+// after commits. Only one process may have it open, since each would
+// otherwise overwrite the other's changes.
+type sealedDB struct {
+	path   string
+	key    []byte
+	lock   *os.File
+	logger *zap.Logger
+
+	dirty   atomic.Bool
+	flushMu sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// driverConn is the part of the sqlite driver's connection sealedDB uses.
+type driverConn interface {
+	Serialize() ([]byte, error)
+	NewBackup(dstURI string) (*sqlite.Backup, error)
+	NewRestore(srcURI string) (*sqlite.Backup, error)
+	RegisterCommitHook(sqlite.CommitHookFn)
+}
+
+// imageFS serves a decrypted database image to SQLite's read-only fs VFS
+// as the file "db", so it can be restored into memory without the
+// plaintext touching the disk.
+type imageFS []byte
+
+func (img imageFS) Open(name string) (fs.File, error) {
+	if name != "db" {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return imageFile{bytes.NewReader(img)}, nil
+}
+
+// imageFile is the open image; it is its own FileInfo.
+type imageFile struct{ *bytes.Reader }
+
+func (f imageFile) Stat() (fs.FileInfo, error) { return f, nil }
+func (f imageFile) Close() error               { return nil }
+func (f imageFile) Name() string               { return "db" }
+func (f imageFile) Mode() fs.FileMode          { return 0o400 }
+func (f imageFile) ModTime() time.Time         { return time.Time{} }
+func (f imageFile) IsDir() bool                { return false }
+func (f imageFile) Sys() any                   { return nil }
+
+// databaseSealed reports whether the database at path exists and whether it
+// is encrypted.
+func databaseSealed(path string) (exists, sealed bool, err error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	defer f.Close()
+	header := make([]byte, len(encryption.Magic))
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return true, false, err
+	}
+	return true, encryption.IsEncrypted(header[:n]), nil
+}
+
+// openDatabase opens the database cfg points at. Whether it is encrypted is
+// decided when it is created, by encrypt_database; after that the file
+// itself says which it is.
+func openDatabase(cfg *config.Config, logger *zap.Logger) (*sql.DB, *sealedDB, error) {
+	exists, sealed, err := databaseSealed(cfg.DatabasePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if exists && !sealed && cfg.EncryptDatabase {
+		logger.Warn("database was created unencrypted; encrypt_database only applies to new databases", zap.String("path", cfg.DatabasePath))
+	}
+	if !sealed && (exists || !cfg.EncryptDatabase) {
+		db, err := openDB(cfg.DatabasePath)
+		return db, nil, err
+	}
+
+	keys := encryption.NewKeys(cfg)
+	key, err := keys.DatabaseKey()
+	if errs.KindOf(err) == errs.ErrNotFound && !exists {
+		key, err = keys.NewDatabaseKey()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	lock, err := lockFile(cfg.DatabasePath + ".lock")
+	if err != nil {
+		return nil, nil, err
+	}
+	s := &sealedDB{path: cfg.DatabasePath, key: key, lock: lock, logger: logger}
+	db, err := s.load(exists)
+	if err != nil {
+		_ = lock.Close()
+		return nil, nil, err
+	}
+	return db, s, nil
+}
+
+// load opens an in-memory database holding the decrypted content of the
+// file, when there is one, and starts tracking commits.
+func (s *sealedDB) load(exists bool) (*sql.DB, error) {
+	var image []byte
+	if exists {
+		var err error
+		if image, err = readSealed(s.path, s.key); err != nil {
+			return nil, err
+		}
+	}
+	db, err := openMemory(image)
+	if err != nil {
+		return nil, err
+	}
+	err = withConn(context.Background(), db, func(c driverConn) error {
+		c.RegisterCommitHook(func() int32 {
+			s.dirty.Store(true)
+			return 0
+		})
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// readSealed decrypts the database file at path.
+func readSealed(path string, key []byte) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec, err := encryption.NewDecrypter(f, key)
+	if err != nil {
+		return nil, err
+	}
+	image, err := io.ReadAll(dec)
+	if err != nil {
+		return nil, errs.New(errs.ErrInvalidArgument, "decrypt database %s: %w", path, err)
+	}
+	return image, nil
+}
+
+// openMemory opens an in-memory database holding image, or an empty one
+// when image is empty.
+func openMemory(image []byte) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", ":memory:"+memoryPragmas)
+	if err != nil {
+		return nil, err
+	}
+	// Closing the only connection would drop the database with it.
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+	if len(image) == 0 {
+		return db, nil
+	}
+	err = withConn(context.Background(), db, func(c driverConn) error {
+		return restoreImage(c, image)
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// restoreImage copies the database in image into c.
+func restoreImage(c driverConn, image []byte) error {
+	name, fsys, err := vfs.New(imageFS(image))
+	if err != nil {
+		return err
+	}
+	defer fsys.Close()
+	restore, err := c.NewRestore("file:db?vfs=" + name)
+	if err != nil {
+		return err
+	}
+	return copyPages(restore)
+}
+
+// copyPages runs an online backup or restore to completion.
+func copyPages(b *sqlite.Backup) error {
+	_, err := b.Step(-1)
+	if ferr := b.Finish(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// withConn runs fn on the driver connection underneath db.
+func withConn(ctx context.Context, db *sql.DB, fn func(driverConn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Raw(func(raw any) error {
+		c, ok := raw.(driverConn)
+		if !ok {
+			return errors.New("sqlite driver does not support serializing or backing up databases")
+		}
+		return fn(c)
+	})
+}
+
+// start writes committed changes back every sealedFlushInterval until close.
+func (s *sealedDB) start(db *sql.DB) {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(sealedFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if err := s.flush(db); err != nil {
+					s.logger.Warn("encrypted database write failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// flush seals the database to disk if anything was committed since the
+// last flush. The file is replaced atomically, so a crash leaves either the
+// old or the new content.
+func (s *sealedDB) flush(db *sql.DB) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	if !s.dirty.Swap(false) {
+		return nil
+	}
+	var image []byte
+	err := withConn(context.Background(), db, func(c driverConn) error {
+		var err error
+		image, err = c.Serialize()
+		return err
+	})
+	if err == nil {
+		err = s.write(image)
+	}
+	if err != nil {
+		s.dirty.Store(true)
+	}
+	return err
+}
+
+func (s *sealedDB) write(image []byte) error {
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	enc, err := encryption.NewEncrypter(f, s.key)
+	if err == nil {
+		_, err = io.Copy(enc, bytes.NewReader(image))
+	}
+	if err == nil {
+		err = enc.Close()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// backup copies the sealed file as it is on disk.
+func (s *sealedDB) backup(dst string) error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0o600)
+}
+
+// close writes out the last changes, closes db and releases the lock.
+func (s *sealedDB) close(db *sql.DB) error {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+	err := s.flush(db)
+	if cerr := db.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := s.lock.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// closeDatabase closes a database returned by openDatabase.
+func closeDatabase(db *sql.DB, sealed *sealedDB) error {
+	if sealed != nil {
+		return sealed.close(db)
+	}
+	return db.Close()
+}
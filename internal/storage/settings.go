@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Keys of the settings the daemon keeps.
+const (
+	// SettingLastMaintenance is when Maintain last finished cleanly.
+	SettingLastMaintenance = "db.last_maintenance"
+)
+
+// GetSetting returns the value stored under key and whether there is one.
+func (s *Storage) GetSetting(ctx context.Context, key string) (string, bool, error) {
+	var value string
+	err := s.DB.QueryRowContext(ctx, `SELECT value FROM settings WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// SetSetting stores value under key, replacing any previous value.
+func (s *Storage) SetSetting(ctx context.Context, key, value string) error {
+	if key == "" {
+		return errs.New(errs.ErrInvalidArgument, "setting key cannot be empty")
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, key, value, unixTime(time.Now()))
+	return err
+}
+
+// DeleteSetting removes key. Removing a key that is not set is not an error.
+func (s *Storage) DeleteSetting(ctx context.Context, key string) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM settings WHERE key = ?`, key)
+	return err
+}
+
+// GetSettingInt returns the integer stored under key, or def when it is not
+// set.
+func (s *Storage) GetSettingInt(ctx context.Context, key string, def int64) (int64, error) {
+	value, ok, err := s.GetSetting(ctx, key)
+	if err != nil || !ok {
+		return def, err
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return def, errs.New(errs.ErrInvalidArgument, "setting %s is not an integer: %q", key, value)
+	}
+	return n, nil
+}
+
+// SetSettingInt stores an integer under key.
+func (s *Storage) SetSettingInt(ctx context.Context, key string, value int64) error {
+	return s.SetSetting(ctx, key, strconv.FormatInt(value, 10))
+}
+
+// GetSettingBool returns the flag stored under key, or def when it is not
+// set.
+func (s *Storage) GetSettingBool(ctx context.Context, key string, def bool) (bool, error) {
+	value, ok, err := s.GetSetting(ctx, key)
+	if err != nil || !ok {
+		return def, err
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return def, errs.New(errs.ErrInvalidArgument, "setting %s is not a boolean: %q", key, value)
+	}
+	return b, nil
+}
+
+// SetSettingBool stores a flag under key.
+func (s *Storage) SetSettingBool(ctx context.Context, key string, value bool) error {
+	return s.SetSetting(ctx, key, strconv.FormatBool(value))
+}
+
+// GetSettingTime returns the time stored under key, or the zero time when
+// it is not set.
+func (s *Storage) GetSettingTime(ctx context.Context, key string) (time.Time, error) {
+	sec, err := s.GetSettingInt(ctx, key, 0)
+	return fromUnix(sec), err
+}
+
+// SetSettingTime stores a time under key, to the second.
+func (s *Storage) SetSettingTime(ctx context.Context, key string, value time.Time) error {
+	return s.SetSettingInt(ctx, key, unixTime(value))
+}
@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Snapshot actions describe how an entry's content reaches Drive.
+const (
+	// SnapshotUpload uploads changed content into the snapshot.
+	SnapshotUpload = "upload"
+	// SnapshotLink references content an earlier snapshot already uploaded,
+	// via a server-side copy.
+	SnapshotLink = "link"
+)
+
+// Snapshot is one point-in-time backup of a sync root.
+type Snapshot struct {
+	ID        int64
+	AccountID string
+	Name      string
+	CreatedAt time.Time
+	// CompletedAt is set once every entry has reached Drive.
+	CompletedAt time.Time
+}
+
+// SnapshotEntry is one file captured in a snapshot.
+type SnapshotEntry struct {
+	SnapshotID int64
+	Path       string
+	Size       int64
+	ModifiedAt time.Time
+	Checksum   string
+	Action     string
+	// BaseSnapshotID is the snapshot whose upload holds the content; it is
+	// SnapshotID itself for uploads.
+	BaseSnapshotID int64
+	// DriveID is the file in this snapshot's Drive folder, empty until the
+	// entry has been uploaded or linked.
+	DriveID string
+}
+
+// CreateSnapshot inserts a snapshot with its entries. Entry base ids of zero
+// are set to the new snapshot id.
+func (s *Storage) CreateSnapshot(ctx context.Context, snap *Snapshot, entries []SnapshotEntry) error {
+	if snap == nil {
+		return nil
+	}
+	if snap.AccountID == "" {
+		return errs.New(errs.ErrInvalidArgument, "snapshot account_id cannot be empty")
+	}
+	if snap.Name == "" {
+		return errs.New(errs.ErrInvalidArgument, "snapshot name cannot be empty")
+	}
+	if snap.CreatedAt.IsZero() {
+		snap.CreatedAt = time.Now()
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO snapshots (account_id, name, created_at, completed_at)
+		VALUES (?, ?, ?, ?)
+	`, snap.AccountID, snap.Name, unixTime(snap.CreatedAt), unixTime(snap.CompletedAt))
+	if err != nil {
+		return conflictErr(err)
+	}
+	if snap.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	for i := range entries {
+		e := &entries[i]
+		e.SnapshotID = snap.ID
+		if e.BaseSnapshotID == 0 {
+			e.BaseSnapshotID = snap.ID
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO snapshot_entries (snapshot_id, path, size, modified_at, checksum, action, base_snapshot_id, drive_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, e.SnapshotID, e.Path, e.Size, unixTime(e.ModifiedAt), e.Checksum, e.Action, e.BaseSnapshotID, e.DriveID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetSnapshot returns a snapshot by name.
+func (s *Storage) GetSnapshot(ctx context.Context, accountID, name string) (*Snapshot, error) {
+	row := s.DB.QueryRowContext(ctx, `
+		SELECT id, account_id, name, created_at, completed_at
+		FROM snapshots WHERE account_id = ? AND name = ?
+	`, accountID, name)
+	return scanSnapshot(row)
+}
+
+// LatestSnapshot returns the newest snapshot for an account.
+func (s *Storage) LatestSnapshot(ctx context.Context, accountID string) (*Snapshot, error) {
+	row := s.DB.QueryRowContext(ctx, `
+		SELECT id, account_id, name, created_at, completed_at
+		FROM snapshots WHERE account_id = ?
+		ORDER BY id DESC LIMIT 1
+	`, accountID)
+	return scanSnapshot(row)
+}
+
+// ListSnapshots returns snapshots newest first.
+func (s *Storage) ListSnapshots(ctx context.Context, accountID string) ([]Snapshot, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, account_id, name, created_at, completed_at
+		FROM snapshots WHERE account_id = ?
+		ORDER BY id DESC
+	`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Snapshot
+	for rows.Next() {
+		snap, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *snap)
+	}
+	return out, rows.Err()
+}
+
+// ListSnapshotEntries returns a snapshot's entries at or under prefix, by
+// path. An empty prefix lists all of them.
+func (s *Storage) ListSnapshotEntries(ctx context.Context, snapshotID int64, prefix string) ([]SnapshotEntry, error) {
+	query := `
+		SELECT snapshot_id, path, size, modified_at, checksum, action, base_snapshot_id, drive_id
+		FROM snapshot_entries WHERE snapshot_id = ?
+	`
+	args := []any{snapshotID}
+	if prefix != "" {
+		cond, condArgs := subtreeRange("path", prefix)
+		query += ` AND ` + cond
+		args = append(args, condArgs...)
+	}
+	query += ` ORDER BY path ASC`
+	return s.querySnapshotEntries(ctx, query, args...)
+}
+
+// ListPendingSnapshotEntries returns entries not yet on Drive, oldest
+// snapshot first, so links always follow the upload they reference.
+func (s *Storage) ListPendingSnapshotEntries(ctx context.Context, accountID string, limit int) ([]SnapshotEntry, error) {
+	if limit <= 0 {
+		limit = 500
+	}
+	return s.querySnapshotEntries(ctx, `
+		SELECT e.snapshot_id, e.path, e.size, e.modified_at, e.checksum, e.action, e.base_snapshot_id, e.drive_id
+		FROM snapshot_entries e
+		JOIN snapshots s ON s.id = e.snapshot_id
+		WHERE s.account_id = ? AND e.drive_id = ''
+		ORDER BY e.snapshot_id ASC, e.path ASC
+		LIMIT ?
+	`, accountID, limit)
+}
+
+// CompleteSnapshotEntry records the Drive file for an entry and marks the
+// snapshot complete once no entries are pending.
+func (s *Storage) CompleteSnapshotEntry(ctx context.Context, snapshotID int64, path, driveID string) error {
+	if driveID == "" {
+		return errs.New(errs.ErrInvalidArgument, "snapshot_entry drive_id cannot be empty")
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE snapshot_entries SET drive_id = ? WHERE snapshot_id = ? AND path = ?
+	`, driveID, snapshotID, path); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE snapshots SET completed_at = ?
+		WHERE id = ? AND completed_at = 0
+			AND NOT EXISTS (SELECT 1 FROM snapshot_entries WHERE snapshot_id = ? AND drive_id = '')
+	`, unixTime(time.Now()), snapshotID, snapshotID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Storage) querySnapshotEntries(ctx context.Context, query string, args ...any) ([]SnapshotEntry, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []SnapshotEntry
+	for rows.Next() {
+		var e SnapshotEntry
+		var modifiedAt int64
+		if err := rows.Scan(&e.SnapshotID, &e.Path, &e.Size, &modifiedAt, &e.Checksum, &e.Action, &e.BaseSnapshotID, &e.DriveID); err != nil {
+			return nil, err
+		}
+		e.ModifiedAt = fromUnix(modifiedAt)
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func scanSnapshot(row rowScanner) (*Snapshot, error) {
+	var snap Snapshot
+	var createdAt, completedAt int64
+	if err := row.Scan(&snap.ID, &snap.AccountID, &snap.Name, &createdAt, &completedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	snap.CreatedAt = fromUnix(createdAt)
+	snap.CompletedAt = fromUnix(completedAt)
+	return &snap, nil
+}
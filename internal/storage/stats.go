@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// statsDayLayout is how days are keyed in the stats table.
+const statsDayLayout = "2006-01-02"
+
+// DailyStats are the transfer counters of one account for one day.
+type DailyStats struct {
+	AccountID   string
+	Day         time.Time // midnight, local time
+	BytesUp     int64
+	BytesDown   int64
+	FilesSynced int64
+	Errors      int64
+	UpdatedAt   time.Time
+}
+
+// AddDailyStats adds delta's counters to the day at falls on, creating the
+// day's row if needed.
+func (s *Storage) AddDailyStats(ctx context.Context, accountID string, at time.Time, delta DailyStats) error {
+	if accountID == "" {
+		return errs.New(errs.ErrInvalidArgument, "stats account_id cannot be empty")
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO stats (account_id, day, bytes_up, bytes_down, files_synced, errors, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account_id, day) DO UPDATE SET
+			bytes_up = bytes_up + excluded.bytes_up,
+			bytes_down = bytes_down + excluded.bytes_down,
+			files_synced = files_synced + excluded.files_synced,
+			errors = errors + excluded.errors,
+			updated_at = excluded.updated_at
+	`, accountID, at.Local().Format(statsDayLayout), delta.BytesUp, delta.BytesDown, delta.FilesSynced, delta.Errors, unixTime(time.Now()))
+	return err
+}
+
+// ListDailyStats returns the days from through to (inclusive, by local
+// date) that have counters, oldest first. Zero bounds are open, and an empty
+// accountID lists every account.
+func (s *Storage) ListDailyStats(ctx context.Context, accountID string, from, to time.Time) ([]DailyStats, error) {
+	lo, hi := "", "9999-12-31"
+	if !from.IsZero() {
+		lo = from.Local().Format(statsDayLayout)
+	}
+	if !to.IsZero() {
+		hi = to.Local().Format(statsDayLayout)
+	}
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT account_id, day, bytes_up, bytes_down, files_synced, errors, updated_at
+		FROM stats
+		WHERE (? = '' OR account_id = ?) AND day >= ? AND day <= ?
+		ORDER BY day ASC, account_id ASC
+	`, accountID, accountID, lo, hi)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []DailyStats
+	for rows.Next() {
+		var st DailyStats
+		var day string
+		var updatedAt int64
+		if err := rows.Scan(&st.AccountID, &day, &st.BytesUp, &st.BytesDown, &st.FilesSynced, &st.Errors, &updatedAt); err != nil {
+			return nil, err
+		}
+		if st.Day, err = time.ParseInLocation(statsDayLayout, day, time.Local); err != nil {
+			return nil, err
+		}
+		st.UpdatedAt = fromUnix(updatedAt)
+		out = append(out, st)
+	}
+	return out, rows.Err()
+}
+
+// SumDailyStats totals the counters ListDailyStats would return.
+func (s *Storage) SumDailyStats(ctx context.Context, accountID string, from, to time.Time) (DailyStats, error) {
+	days, err := s.ListDailyStats(ctx, accountID, from, to)
+	if err != nil {
+		return DailyStats{}, err
+	}
+	total := DailyStats{AccountID: accountID}
+	for _, day := range days {
+		total.BytesUp += day.BytesUp
+		total.BytesDown += day.BytesDown
+		total.FilesSynced += day.FilesSynced
+		total.Errors += day.Errors
+		if day.UpdatedAt.After(total.UpdatedAt) {
+			total.UpdatedAt = day.UpdatedAt
+		}
+	}
+	return total, nil
+}
@@ -6,71 +6,137 @@ import (
 	"embed"
 	"os"
 	"path/filepath"
+	"sync"
 
 	_ "modernc.org/sqlite"
 	"go.uber.org/zap"
 
-	"github.com/pressly/goose/v3"
-
 	"github.com/sandeepkv93/googlysync/internal/config"
 )
 
 //go:embed migrations/*.sql
 var migrationsFS embed.FS
 
+// connPragmas are applied to every connection the driver opens. WAL lets
+// the CLI read while the daemon writes, and NORMAL sync is durable across
+// crashes of the process in WAL mode. Transactions take the write lock up
+// front so two writers wait on busy_timeout instead of deadlocking on an
+// upgrade from a read lock. Incremental auto-vacuum only takes effect on a
+// new database; Maintain converts older ones.
+const connPragmas = "?_pragma=busy_timeout(5000)" +
+	"&_pragma=foreign_keys(1)" +
+	"&_pragma=auto_vacuum(INCREMENTAL)" +
+	"&_pragma=journal_mode(WAL)" +
+	"&_pragma=synchronous(NORMAL)" +
+	"&_txlock=immediate"
+
 // Storage wraps access to the local metadata store.
 type Storage struct {
 	DB *sql.DB
+
+	// sealed is set when the database is encrypted at rest.
+	sealed *sealedDB
+
+	stmtMu sync.Mutex
+	stmts  map[string]*sql.Stmt
 }
 
-// NewStorage opens the SQLite database for metadata.
+// NewStorage opens the SQLite database for metadata, decrypting it when it
+// was created with encrypt_database.
 func NewStorage(cfg *config.Config, logger *zap.Logger) (*Storage, error) {
 	if err := os.MkdirAll(filepath.Dir(cfg.DatabasePath), 0o700); err != nil {
 		return nil, err
 	}
 
-	db, err := sql.Open("sqlite", cfg.DatabasePath)
+	db, sealed, err := openDatabase(cfg, logger)
 	if err != nil {
 		return nil, err
 	}
-	db.SetMaxOpenConns(1)
 	db.SetMaxIdleConns(1)
-	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
-		_ = db.Close()
+
+	if err := migrate(context.Background(), db, sealed, cfg.DatabasePath, logger); err != nil {
+		_ = closeDatabase(db, sealed)
 		return nil, err
 	}
-	if _, err := db.Exec("PRAGMA busy_timeout = 5000"); err != nil {
-		_ = db.Close()
-		return nil, err
+	if sealed != nil {
+		sealed.start(db)
 	}
 
-	if err := migrate(context.Background(), db, logger); err != nil {
+	logger.Info("storage initialized", zap.String("path", cfg.DatabasePath), zap.Bool("encrypted", sealed != nil))
+	return &Storage{DB: db, sealed: sealed}, nil
+}
+
+// openDB opens the database at path with connPragmas applied.
+func openDB(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path+connPragmas)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	// Open is lazy; connect now so a bad path or pragma fails here.
+	if err := db.Ping(); err != nil {
 		_ = db.Close()
 		return nil, err
 	}
-
-	logger.Info("storage initialized", zap.String("path", cfg.DatabasePath))
-	return &Storage{DB: db}, nil
+	return db, nil
 }
 
-// Close shuts down the database connection.
-func (s *Storage) Close() error {
-	if s == nil || s.DB == nil {
-		return nil
+// prepared returns a cached prepared statement for query, preparing it on
+// first use. It is meant for the statements run once per file during a
+// crawl, where preparing the SQL every time shows up in profiles.
+func (s *Storage) prepared(ctx context.Context, query string) (*sql.Stmt, error) {
+	s.stmtMu.Lock()
+	defer s.stmtMu.Unlock()
+	if stmt, ok := s.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := s.DB.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if s.stmts == nil {
+		s.stmts = make(map[string]*sql.Stmt)
 	}
-	return s.DB.Close()
+	s.stmts[query] = stmt
+	return stmt, nil
 }
 
-func migrate(ctx context.Context, db *sql.DB, logger *zap.Logger) error {
-	goose.SetBaseFS(migrationsFS)
-	if err := goose.SetDialect("sqlite3"); err != nil {
+// execPrepared runs query through the statement cache.
+func (s *Storage) execPrepared(ctx context.Context, query string, args ...any) error {
+	stmt, err := s.prepared(ctx, query)
+	if err != nil {
 		return err
 	}
+	_, err = stmt.ExecContext(ctx, args...)
+	return err
+}
 
-	logger.Info("applying migrations")
-	if err := goose.UpContext(ctx, db, "migrations"); err != nil {
-		return err
+// queryRowPrepared runs query through the statement cache. Preparation
+// errors surface from Scan, as with QueryRowContext.
+func (s *Storage) queryRowPrepared(ctx context.Context, query string, args ...any) rowScanner {
+	stmt, err := s.prepared(ctx, query)
+	if err != nil {
+		return errRow{err}
+	}
+	return stmt.QueryRowContext(ctx, args...)
+}
+
+// errRow is a row whose Scan reports err.
+type errRow struct{ err error }
+
+func (r errRow) Scan(...any) error { return r.err }
+
+// Close shuts down the database connection. An encrypted database is
+// written out first.
+func (s *Storage) Close() error {
+	if s == nil || s.DB == nil {
+		return nil
+	}
+	s.stmtMu.Lock()
+	for query, stmt := range s.stmts {
+		_ = stmt.Close()
+		delete(s.stmts, query)
 	}
-	logger.Info("migrations complete")
-	return nil
+	s.stmtMu.Unlock()
+	return closeDatabase(s.DB, s.sealed)
 }
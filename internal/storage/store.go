@@ -3,9 +3,15 @@ package storage
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"errors"
 	"strings"
 	"time"
+	"unicode/utf8"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
 )
 
 // Account represents a Google account configured in the client.
@@ -14,8 +20,16 @@ type Account struct {
 	Email       string
 	DisplayName string
 	IsPrimary   bool
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	// SyncRoot is where the account's files are mirrored; empty uses the
+	// configured sync root.
+	SyncRoot string
+	// ReauthReason is set while the account's refresh token is revoked or
+	// expired; the account has to sign in again. UpsertAccount leaves it
+	// alone; SetAccountReauth changes it.
+	ReauthReason string
+	ReauthAt     time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
 }
 
 // TokenRef stores a reference to tokens kept in an external keyring.
@@ -35,20 +49,50 @@ type SyncState struct {
 	LastSyncAt     time.Time
 	LastError      string
 	Paused         bool
-	UpdatedAt      time.Time
+	// WatchedAt is set by SetSyncWatchedAt; UpsertSyncState leaves it alone.
+	WatchedAt time.Time
+	UpdatedAt time.Time
 }
 
-// FileRecord represents a Drive file tracked locally.
+// FileRecord represents one local projection of a Drive file. A file with
+// several parents or shortcuts has one record per local path sharing DriveID.
 type FileRecord struct {
-	ID         string
-	AccountID  string
-	Path       string
-	DriveID    string
+	ID        string
+	AccountID string
+	Path      string
+	DriveID   string
+	// ParentID is the Drive folder this projection lives under.
+	ParentID string
+	// ShortcutID is set when the projection comes from a Drive shortcut.
+	ShortcutID string
 	ETag       string
-	Checksum   string
-	Size       int64
-	ModifiedAt time.Time
-	CreatedAt  time.Time
+	// Checksum is Drive's md5Checksum for the content, lower case. Native
+	// Google files have none.
+	Checksum string
+	// FastHash is the XXH64 digest of the local content when it matched
+	// Checksum, so later checks can skip MD5. Empty when unknown.
+	FastHash string
+	// RemoteName is the file's name in Drive when it differs from the last
+	// element of Path, because it had to be encoded for the local filesystem
+	// or disambiguated. Empty means the names match.
+	RemoteName string
+	// ReadOnly is set when the account may not edit the file on Drive. The
+	// local copy is kept without write permission.
+	ReadOnly bool
+	// The fields below mirror Drive's metadata as of the last sync, so the
+	// engine can act on them without fetching the file again. RevisionID is
+	// Drive's headRevisionId and is only set for files with binary content.
+	MimeType    string
+	RevisionID  string
+	Trashed     bool
+	Starred     bool
+	Shared      bool
+	WebViewLink string
+	Size        int64
+	Device      uint64
+	Inode       uint64
+	ModifiedAt  time.Time
+	CreatedAt   time.Time
 }
 
 // Folder represents a local folder mapping to Drive.
@@ -58,6 +102,7 @@ type Folder struct {
 	Path       string
 	DriveID    string
 	ParentID   string
+	OrphanedAt time.Time
 	ModifiedAt time.Time
 	CreatedAt  time.Time
 }
@@ -70,30 +115,48 @@ type SharedDrive struct {
 	UpdatedAt time.Time
 }
 
+// Pending op states. An op is journaled as queued, claimed as in_progress
+// while it runs and deleted once it has taken effect. An op that keeps
+// failing is parked as failed until it is retried by hand.
+const (
+	OpQueued     = "queued"
+	OpInProgress = "in_progress"
+	OpFailed     = "failed"
+)
+
 // PendingOp tracks deferred sync operations.
 type PendingOp struct {
 	ID         string
+	Seq        int64 // per-account, in the order the ops were planned
 	AccountID  string
 	Path       string
 	DriveID    string
 	OpType     string
+	Size       int64 // expected bytes to transfer, when known
 	State      string
 	RetryCount int
 	LastError  string
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	// DependsOn is the id of an op that has to finish before this one may
+	// run; empty when it may run in any order.
+	DependsOn string
+	// NotBefore holds a failed op back until its retry backoff has passed.
+	NotBefore time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
+const pendingOpColumns = `id, account_id, seq, path, drive_id, op_type, size, state, retry_count, last_error, depends_on, not_before, created_at, updated_at`
+
 // UpsertAccount creates or updates an account record.
 func (s *Storage) UpsertAccount(ctx context.Context, acct *Account) error {
 	if acct == nil {
 		return nil
 	}
 	if acct.ID == "" {
-		return fmt.Errorf("account id cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "account id cannot be empty")
 	}
 	if acct.Email == "" {
-		return fmt.Errorf("account email cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "account email cannot be empty")
 	}
 	now := time.Now()
 	if acct.CreatedAt.IsZero() {
@@ -103,38 +166,88 @@ func (s *Storage) UpsertAccount(ctx context.Context, acct *Account) error {
 		acct.UpdatedAt = now
 	}
 	_, err := s.DB.ExecContext(ctx, `
-		INSERT INTO accounts (id, email, display_name, is_primary, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO accounts (id, email, display_name, is_primary, sync_root, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(id) DO UPDATE SET
 			email=excluded.email,
 			display_name=excluded.display_name,
 			is_primary=excluded.is_primary,
+			sync_root=excluded.sync_root,
 			updated_at=excluded.updated_at
-	`, acct.ID, acct.Email, acct.DisplayName, boolToInt(acct.IsPrimary), unixTime(acct.CreatedAt), unixTime(acct.UpdatedAt))
-	return err
+	`, acct.ID, acct.Email, acct.DisplayName, boolToInt(acct.IsPrimary), acct.SyncRoot, unixTime(acct.CreatedAt), unixTime(acct.UpdatedAt))
+	return conflictErr(err)
 }
 
 // GetAccount fetches an account by ID.
 func (s *Storage) GetAccount(ctx context.Context, id string) (*Account, error) {
 	row := s.DB.QueryRowContext(ctx, `
-		SELECT id, email, display_name, is_primary, created_at, updated_at
+		SELECT id, email, display_name, is_primary, sync_root, reauth_reason, reauth_at, created_at, updated_at
 		FROM accounts WHERE id = ?
 	`, id)
 	var acct Account
 	var isPrimary int
-	var createdAt, updatedAt int64
-	if err := row.Scan(&acct.ID, &acct.Email, &acct.DisplayName, &isPrimary, &createdAt, &updatedAt); err != nil {
+	var reauthAt, createdAt, updatedAt int64
+	if err := row.Scan(&acct.ID, &acct.Email, &acct.DisplayName, &isPrimary, &acct.SyncRoot, &acct.ReauthReason, &reauthAt, &createdAt, &updatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
 	acct.IsPrimary = intToBool(isPrimary)
+	acct.ReauthAt = fromUnix(reauthAt)
 	acct.CreatedAt = fromUnix(createdAt)
 	acct.UpdatedAt = fromUnix(updatedAt)
 	return &acct, nil
 }
 
+// SetPrimaryAccount makes id the primary account and clears the flag on
+// every other account.
+func (s *Storage) SetPrimaryAccount(ctx context.Context, id string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	res, err := tx.ExecContext(ctx, `UPDATE accounts SET is_primary = 1, updated_at = ? WHERE id = ?`, unixTime(time.Now()), id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errs.New(errs.ErrNotFound, "account %s not found", id)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE accounts SET is_primary = 0 WHERE id != ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetAccountReauth records that an account has to sign in again, with the
+// reason shown to the user. An empty reason clears the mark.
+func (s *Storage) SetAccountReauth(ctx context.Context, id, reason string) error {
+	at := time.Time{}
+	if reason != "" {
+		at = time.Now()
+	}
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE accounts SET reauth_reason = ?, reauth_at = ? WHERE id = ?
+	`, reason, unixTime(at), id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errs.New(errs.ErrNotFound, "account %s not found", id)
+	}
+	return nil
+}
+
 // DeleteAccount removes an account (and cascades dependent rows).
 func (s *Storage) DeleteAccount(ctx context.Context, id string) error {
 	_, err := s.DB.ExecContext(ctx, `
@@ -146,7 +259,7 @@ func (s *Storage) DeleteAccount(ctx context.Context, id string) error {
 // ListAccounts returns all configured accounts.
 func (s *Storage) ListAccounts(ctx context.Context) ([]Account, error) {
 	rows, err := s.DB.QueryContext(ctx, `
-		SELECT id, email, display_name, is_primary, created_at, updated_at
+		SELECT id, email, display_name, is_primary, sync_root, reauth_reason, reauth_at, created_at, updated_at
 		FROM accounts ORDER BY created_at ASC
 	`)
 	if err != nil {
@@ -158,11 +271,12 @@ func (s *Storage) ListAccounts(ctx context.Context) ([]Account, error) {
 	for rows.Next() {
 		var acct Account
 		var isPrimary int
-		var createdAt, updatedAt int64
-		if err := rows.Scan(&acct.ID, &acct.Email, &acct.DisplayName, &isPrimary, &createdAt, &updatedAt); err != nil {
+		var reauthAt, createdAt, updatedAt int64
+		if err := rows.Scan(&acct.ID, &acct.Email, &acct.DisplayName, &isPrimary, &acct.SyncRoot, &acct.ReauthReason, &reauthAt, &createdAt, &updatedAt); err != nil {
 			return nil, err
 		}
 		acct.IsPrimary = intToBool(isPrimary)
+		acct.ReauthAt = fromUnix(reauthAt)
 		acct.CreatedAt = fromUnix(createdAt)
 		acct.UpdatedAt = fromUnix(updatedAt)
 		out = append(out, acct)
@@ -176,10 +290,10 @@ func (s *Storage) UpsertTokenRef(ctx context.Context, ref *TokenRef) error {
 		return nil
 	}
 	if ref.AccountID == "" {
-		return fmt.Errorf("token_ref account_id cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "token_ref account_id cannot be empty")
 	}
 	if ref.KeyID == "" {
-		return fmt.Errorf("token_ref key_id cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "token_ref key_id cannot be empty")
 	}
 	now := time.Now()
 	if ref.UpdatedAt.IsZero() {
@@ -220,7 +334,7 @@ func (s *Storage) GetTokenRef(ctx context.Context, accountID string) (*TokenRef,
 // DeleteTokenRef removes a token reference for an account.
 func (s *Storage) DeleteTokenRef(ctx context.Context, accountID string) error {
 	if accountID == "" {
-		return fmt.Errorf("token_ref account_id cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "token_ref account_id cannot be empty")
 	}
 	_, err := s.DB.ExecContext(ctx, `
 		DELETE FROM token_refs WHERE account_id = ?
@@ -234,7 +348,7 @@ func (s *Storage) UpsertSyncState(ctx context.Context, state *SyncState) error {
 		return nil
 	}
 	if state.AccountID == "" {
-		return fmt.Errorf("sync_state account_id cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "sync_state account_id cannot be empty")
 	}
 	now := time.Now()
 	if state.UpdatedAt.IsZero() {
@@ -256,13 +370,13 @@ func (s *Storage) UpsertSyncState(ctx context.Context, state *SyncState) error {
 // GetSyncState returns the sync metadata for an account.
 func (s *Storage) GetSyncState(ctx context.Context, accountID string) (*SyncState, error) {
 	row := s.DB.QueryRowContext(ctx, `
-		SELECT account_id, start_page_token, last_sync_at, last_error, paused, updated_at
+		SELECT account_id, start_page_token, last_sync_at, last_error, paused, watched_at, updated_at
 		FROM sync_state WHERE account_id = ?
 	`, accountID)
 	var state SyncState
-	var lastSyncAt, updatedAt int64
+	var lastSyncAt, watchedAt, updatedAt int64
 	var paused int
-	if err := row.Scan(&state.AccountID, &state.StartPageToken, &lastSyncAt, &state.LastError, &paused, &updatedAt); err != nil {
+	if err := row.Scan(&state.AccountID, &state.StartPageToken, &lastSyncAt, &state.LastError, &paused, &watchedAt, &updatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -270,26 +384,66 @@ func (s *Storage) GetSyncState(ctx context.Context, accountID string) (*SyncStat
 	}
 	state.LastSyncAt = fromUnix(lastSyncAt)
 	state.Paused = intToBool(paused)
+	state.WatchedAt = fromUnix(watchedAt)
 	state.UpdatedAt = fromUnix(updatedAt)
 	return &state, nil
 }
 
+// SetSyncPaused flips the pause flag for an account, keeping the rest of its
+// sync state. Unknown accounts return errs.ErrNotFound.
+func (s *Storage) SetSyncPaused(ctx context.Context, accountID string, paused bool) error {
+	if accountID == "" {
+		return errs.New(errs.ErrInvalidArgument, "sync_state account_id cannot be empty")
+	}
+	acct, err := s.GetAccount(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	if acct == nil {
+		return errs.New(errs.ErrNotFound, "account %s not found", accountID)
+	}
+	_, err = s.DB.ExecContext(ctx, `
+		INSERT INTO sync_state (account_id, paused, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(account_id) DO UPDATE SET
+			paused=excluded.paused,
+			updated_at=excluded.updated_at
+	`, accountID, boolToInt(paused), unixTime(time.Now()))
+	return err
+}
+
+// SetSyncWatchedAt records when the sync root was last watched with every
+// local change applied, keeping the rest of the account's sync state.
+func (s *Storage) SetSyncWatchedAt(ctx context.Context, accountID string, at time.Time) error {
+	if accountID == "" {
+		return errs.New(errs.ErrInvalidArgument, "sync_state account_id cannot be empty")
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO sync_state (account_id, watched_at, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(account_id) DO UPDATE SET
+			watched_at=excluded.watched_at,
+			updated_at=excluded.updated_at
+	`, accountID, unixTime(at), unixTime(time.Now()))
+	return err
+}
+
 // UpsertFile creates or updates a file record.
 func (s *Storage) UpsertFile(ctx context.Context, file *FileRecord) error {
 	if file == nil {
 		return nil
 	}
 	if file.ID == "" {
-		return fmt.Errorf("file id cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "file id cannot be empty")
 	}
 	if file.AccountID == "" {
-		return fmt.Errorf("file account_id cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "file account_id cannot be empty")
 	}
 	if file.Path == "" {
-		return fmt.Errorf("file path cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "file path cannot be empty")
 	}
 	if file.DriveID == "" {
-		return fmt.Errorf("file drive_id cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "file drive_id cannot be empty")
 	}
 	now := time.Now()
 	if file.CreatedAt.IsZero() {
@@ -298,57 +452,216 @@ func (s *Storage) UpsertFile(ctx context.Context, file *FileRecord) error {
 	if file.ModifiedAt.IsZero() {
 		file.ModifiedAt = now
 	}
-	_, err := s.DB.ExecContext(ctx, `
-		INSERT INTO files (id, account_id, path, drive_id, etag, checksum, size, modified_at, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	return s.execPrepared(ctx, `
+		INSERT INTO files (id, account_id, path, drive_id, parent_id, shortcut_id, etag, checksum, fast_hash, remote_name, read_only, mime_type, revision_id, trashed, starred, shared, web_view_link, size, device, inode, modified_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(id) DO UPDATE SET
 			account_id=excluded.account_id,
 			path=excluded.path,
 			drive_id=excluded.drive_id,
+			parent_id=excluded.parent_id,
+			shortcut_id=excluded.shortcut_id,
 			etag=excluded.etag,
 			checksum=excluded.checksum,
+			fast_hash=excluded.fast_hash,
+			remote_name=excluded.remote_name,
+			read_only=excluded.read_only,
+			mime_type=excluded.mime_type,
+			revision_id=excluded.revision_id,
+			trashed=excluded.trashed,
+			starred=excluded.starred,
+			shared=excluded.shared,
+			web_view_link=excluded.web_view_link,
 			size=excluded.size,
+			device=excluded.device,
+			inode=excluded.inode,
 			modified_at=excluded.modified_at
-	`, file.ID, file.AccountID, file.Path, file.DriveID, file.ETag, file.Checksum, file.Size, unixTime(file.ModifiedAt), unixTime(file.CreatedAt))
-	return err
+	`, file.ID, file.AccountID, file.Path, file.DriveID, file.ParentID, file.ShortcutID, file.ETag, file.Checksum, file.FastHash, file.RemoteName, file.ReadOnly, file.MimeType, file.RevisionID, file.Trashed, file.Starred, file.Shared, file.WebViewLink, file.Size, int64(file.Device), int64(file.Inode), unixTime(file.ModifiedAt), unixTime(file.CreatedAt))
 }
 
 // GetFileByPath returns a file record by account and path.
 func (s *Storage) GetFileByPath(ctx context.Context, accountID, path string) (*FileRecord, error) {
-	row := s.DB.QueryRowContext(ctx, `
-		SELECT id, account_id, path, drive_id, etag, checksum, size, modified_at, created_at
+	row := s.queryRowPrepared(ctx, `
+		SELECT `+fileColumns+`
 		FROM files WHERE account_id = ? AND path = ?
 	`, accountID, path)
-	var file FileRecord
-	var modifiedAt, createdAt int64
-	if err := row.Scan(&file.ID, &file.AccountID, &file.Path, &file.DriveID, &file.ETag, &file.Checksum, &file.Size, &modifiedAt, &createdAt); err != nil {
+	file, err := scanFile(row)
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
-	file.ModifiedAt = fromUnix(modifiedAt)
-	file.CreatedAt = fromUnix(createdAt)
-	return &file, nil
+	return file, nil
 }
 
-// GetFileByDriveID returns a file record by account and Drive ID.
+// GetFileByDriveID returns the primary projection of a Drive file: a real
+// parent is preferred over shortcuts, then the oldest record.
 func (s *Storage) GetFileByDriveID(ctx context.Context, accountID, driveID string) (*FileRecord, error) {
-	row := s.DB.QueryRowContext(ctx, `
-		SELECT id, account_id, path, drive_id, etag, checksum, size, modified_at, created_at
+	row := s.queryRowPrepared(ctx, `
+		SELECT `+fileColumns+`
 		FROM files WHERE account_id = ? AND drive_id = ?
+		ORDER BY `+projectionOrder+`
+		LIMIT 1
 	`, accountID, driveID)
-	var file FileRecord
-	var modifiedAt, createdAt int64
-	if err := row.Scan(&file.ID, &file.AccountID, &file.Path, &file.DriveID, &file.ETag, &file.Checksum, &file.Size, &modifiedAt, &createdAt); err != nil {
+	file, err := scanFile(row)
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
-	file.ModifiedAt = fromUnix(modifiedAt)
-	file.CreatedAt = fromUnix(createdAt)
-	return &file, nil
+	return file, nil
+}
+
+// GetFileByInode returns a file record by account and local file identity.
+func (s *Storage) GetFileByInode(ctx context.Context, accountID string, device, inode uint64) (*FileRecord, error) {
+	if inode == 0 {
+		return nil, nil
+	}
+	row := s.DB.QueryRowContext(ctx, `
+		SELECT `+fileColumns+`
+		FROM files WHERE account_id = ? AND device = ? AND inode = ?
+	`, accountID, int64(device), int64(inode))
+	file, err := scanFile(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// ListFileProjections returns every local projection of a Drive file,
+// primary first.
+func (s *Storage) ListFileProjections(ctx context.Context, accountID, driveID string) ([]FileRecord, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+fileColumns+`
+		FROM files WHERE account_id = ? AND drive_id = ?
+		ORDER BY `+projectionOrder+`
+	`, accountID, driveID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []FileRecord
+	for rows.Next() {
+		file, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *file)
+	}
+	return out, rows.Err()
+}
+
+// ListFilesByChecksum returns files with identical content, oldest first.
+func (s *Storage) ListFilesByChecksum(ctx context.Context, accountID, checksum string, size int64) ([]FileRecord, error) {
+	if checksum == "" {
+		return nil, nil
+	}
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+fileColumns+`
+		FROM files
+		WHERE account_id = ? AND checksum = ? AND size = ?
+		ORDER BY created_at ASC, id ASC
+	`, accountID, checksum, size)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []FileRecord
+	for rows.Next() {
+		file, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *file)
+	}
+	return out, rows.Err()
+}
+
+// SearchFiles returns files whose path matches every whitespace-separated
+// term of query, in any order and case, best matches first: a term found in
+// the file name outranks one found only in a folder name. Terms of three or
+// more characters are looked up in the files_fts trigram index; shorter
+// ones, which it cannot look up, are matched with LIKE. An empty accountID
+// searches every account.
+func (s *Storage) SearchFiles(ctx context.Context, accountID, query string, limit int) ([]FileRecord, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	var match []string
+	var where []string
+	var args []any
+	for _, term := range strings.Fields(query) {
+		if utf8.RuneCountInString(term) >= 3 {
+			match = append(match, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+			continue
+		}
+		where = append(where, `files.path LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(term)+"%")
+	}
+	if accountID != "" {
+		where = append(where, "files.account_id = ?")
+		args = append(args, accountID)
+	}
+
+	sqlQuery := `SELECT ` + fileColumns + ` FROM files`
+	order := " ORDER BY account_id ASC, path ASC"
+	if len(match) > 0 {
+		// Names are weighted ten times paths in the bm25 rank.
+		sqlQuery = `
+			WITH hits AS (
+				SELECT rowid AS hit, bm25(files_fts, 10.0, 1.0) AS score
+				FROM files_fts WHERE files_fts MATCH ?
+			)
+			SELECT ` + fileColumns + `
+			FROM files JOIN hits ON files.rowid = hits.hit`
+		args = append([]any{strings.Join(match, " AND ")}, args...)
+		order = " ORDER BY hits.score ASC, path ASC"
+	}
+	if len(where) > 0 {
+		sqlQuery += " WHERE " + strings.Join(where, " AND ")
+	}
+	sqlQuery += order + " LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.DB.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []FileRecord
+	for rows.Next() {
+		file, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *file)
+	}
+	return out, rows.Err()
+}
+
+// SetFileTrashed records whether every projection of a Drive file is in the
+// Drive trash.
+func (s *Storage) SetFileTrashed(ctx context.Context, accountID, driveID string, trashed bool) error {
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE files SET trashed = ? WHERE account_id = ? AND drive_id = ?
+	`, trashed, accountID, driveID)
+	return err
+}
+
+// SetFileFastHash records the fast digest of a file's local content.
+func (s *Storage) SetFileFastHash(ctx context.Context, accountID, path, fastHash string) error {
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE files SET fast_hash = ? WHERE account_id = ? AND path = ?
+	`, fastHash, accountID, path)
+	return err
 }
 
 // DeleteFile removes a file record by account and path.
@@ -361,17 +674,25 @@ func (s *Storage) DeleteFile(ctx context.Context, accountID, path string) error
 
 // ListFilesByPrefix returns files under a path prefix.
 func (s *Storage) ListFilesByPrefix(ctx context.Context, accountID, prefix string, limit int) ([]FileRecord, error) {
+	return s.ListFilesByPrefixAfter(ctx, accountID, prefix, "", limit)
+}
+
+// ListFilesByPrefixAfter returns the next page of files under a path prefix,
+// in path order. after is the last path of the previous page, or empty for
+// the first page; paging by path stays correct while files are added or
+// removed between pages.
+func (s *Storage) ListFilesByPrefixAfter(ctx context.Context, accountID, prefix, after string, limit int) ([]FileRecord, error) {
 	if limit <= 0 {
 		limit = 500
 	}
-	pattern := escapeLike(prefix) + "%"
+	cond, args := prefixRange("path", prefix)
 	rows, err := s.DB.QueryContext(ctx, `
-		SELECT id, account_id, path, drive_id, etag, checksum, size, modified_at, created_at
+		SELECT `+fileColumns+`
 		FROM files
-		WHERE account_id = ? AND path LIKE ? ESCAPE '\'
+		WHERE account_id = ? AND `+cond+` AND path > ?
 		ORDER BY path ASC
 		LIMIT ?
-	`, accountID, pattern, limit)
+	`, append(append([]any{accountID}, args...), after, limit)...)
 	if err != nil {
 		return nil, err
 	}
@@ -379,14 +700,51 @@ func (s *Storage) ListFilesByPrefix(ctx context.Context, accountID, prefix strin
 
 	var out []FileRecord
 	for rows.Next() {
-		var file FileRecord
-		var modifiedAt, createdAt int64
-		if err := rows.Scan(&file.ID, &file.AccountID, &file.Path, &file.DriveID, &file.ETag, &file.Checksum, &file.Size, &modifiedAt, &createdAt); err != nil {
+		file, err := scanFile(rows)
+		if err != nil {
 			return nil, err
 		}
-		file.ModifiedAt = fromUnix(modifiedAt)
-		file.CreatedAt = fromUnix(createdAt)
-		out = append(out, file)
+		out = append(out, *file)
+	}
+	return out, rows.Err()
+}
+
+// CountFilesByPrefix returns how many files are under a path prefix, for
+// showing a total alongside a page.
+func (s *Storage) CountFilesByPrefix(ctx context.Context, accountID, prefix string) (int, error) {
+	cond, args := prefixRange("path", prefix)
+	var n int
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT COUNT(1) FROM files WHERE account_id = ? AND `+cond,
+		append([]any{accountID}, args...)...).Scan(&n)
+	return n, err
+}
+
+// ListFilesAfter returns up to limit files whose path sorts after the given
+// one, in path order, for paging through an account's whole index.
+func (s *Storage) ListFilesAfter(ctx context.Context, accountID, after string, limit int) ([]FileRecord, error) {
+	if limit <= 0 {
+		limit = 500
+	}
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+fileColumns+`
+		FROM files
+		WHERE account_id = ? AND path > ?
+		ORDER BY path ASC
+		LIMIT ?
+	`, accountID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []FileRecord
+	for rows.Next() {
+		file, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *file)
 	}
 	return out, rows.Err()
 }
@@ -397,16 +755,16 @@ func (s *Storage) UpsertFolder(ctx context.Context, folder *Folder) error {
 		return nil
 	}
 	if folder.ID == "" {
-		return fmt.Errorf("folder id cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "folder id cannot be empty")
 	}
 	if folder.AccountID == "" {
-		return fmt.Errorf("folder account_id cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "folder account_id cannot be empty")
 	}
 	if folder.Path == "" {
-		return fmt.Errorf("folder path cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "folder path cannot be empty")
 	}
 	if folder.DriveID == "" {
-		return fmt.Errorf("folder drive_id cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "folder drive_id cannot be empty")
 	}
 	now := time.Now()
 	if folder.CreatedAt.IsZero() {
@@ -415,32 +773,176 @@ func (s *Storage) UpsertFolder(ctx context.Context, folder *Folder) error {
 	if folder.ModifiedAt.IsZero() {
 		folder.ModifiedAt = now
 	}
-	_, err := s.DB.ExecContext(ctx, `
-		INSERT INTO folders (id, account_id, path, drive_id, parent_id, modified_at, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+	return s.execPrepared(ctx, `
+		INSERT INTO folders (id, account_id, path, drive_id, parent_id, orphaned_at, modified_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(id) DO UPDATE SET
 			account_id=excluded.account_id,
 			path=excluded.path,
 			drive_id=excluded.drive_id,
 			parent_id=excluded.parent_id,
+			orphaned_at=excluded.orphaned_at,
 			modified_at=excluded.modified_at
-	`, folder.ID, folder.AccountID, folder.Path, folder.DriveID, folder.ParentID, unixTime(folder.ModifiedAt), unixTime(folder.CreatedAt))
+	`, folder.ID, folder.AccountID, folder.Path, folder.DriveID, folder.ParentID, unixTime(folder.OrphanedAt), unixTime(folder.ModifiedAt), unixTime(folder.CreatedAt))
+}
+
+// GetFolderByPath returns a folder record by account and path.
+func (s *Storage) GetFolderByPath(ctx context.Context, accountID, path string) (*Folder, error) {
+	row := s.queryRowPrepared(ctx, `
+		SELECT `+folderColumns+`
+		FROM folders WHERE account_id = ? AND path = ?
+	`, accountID, path)
+	folder, err := scanFolder(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return folder, nil
+}
+
+// GetFolderByDriveID returns a folder record by account and Drive id.
+func (s *Storage) GetFolderByDriveID(ctx context.Context, accountID, driveID string) (*Folder, error) {
+	row := s.DB.QueryRowContext(ctx, `
+		SELECT `+folderColumns+`
+		FROM folders WHERE account_id = ? AND drive_id = ?
+		LIMIT 1
+	`, accountID, driveID)
+	folder, err := scanFolder(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return folder, nil
+}
+
+// DeleteFolder removes a folder record by account and path.
+func (s *Storage) DeleteFolder(ctx context.Context, accountID, path string) error {
+	_, err := s.DB.ExecContext(ctx, `
+		DELETE FROM folders WHERE account_id = ? AND path = ?
+	`, accountID, path)
 	return err
 }
 
+// ListOrphanedFolders returns folders whose remote access was lost.
+func (s *Storage) ListOrphanedFolders(ctx context.Context, accountID string) ([]Folder, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+folderColumns+`
+		FROM folders
+		WHERE account_id = ? AND orphaned_at > 0
+		ORDER BY path ASC
+	`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Folder
+	for rows.Next() {
+		folder, err := scanFolder(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *folder)
+	}
+	return out, rows.Err()
+}
+
+// DeleteSubtree removes file and folder records at or below path.
+func (s *Storage) DeleteSubtree(ctx context.Context, accountID, path string) error {
+	if path == "" {
+		return errs.New(errs.ErrInvalidArgument, "subtree path cannot be empty")
+	}
+	cond, args := subtreeRange("path", path)
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, table := range subtreeTables {
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM `+table+` WHERE account_id = ? AND `+cond,
+			append([]any{accountID}, args...)...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// subtreeTables are the tables keyed by path that DeleteSubtree and
+// RenamePathPrefix keep in step.
+var subtreeTables = []string{"files", "symlinks", "placeholders", "pinned_folders", "folders"}
+
+// RenamePathPrefix moves the records at or below oldPrefix to newPrefix in
+// one transaction, for a folder that was renamed or moved as a whole. It
+// fails with errs.ErrConflict, changing nothing, if a record already exists
+// at one of the new paths.
+func (s *Storage) RenamePathPrefix(ctx context.Context, accountID, oldPrefix, newPrefix string) error {
+	if oldPrefix == "" || newPrefix == "" {
+		return errs.New(errs.ErrInvalidArgument, "rename prefixes cannot be empty")
+	}
+	if newPrefix == oldPrefix || strings.HasPrefix(newPrefix, oldPrefix+"/") {
+		return errs.New(errs.ErrInvalidArgument, "cannot move %s into itself", oldPrefix)
+	}
+	cond, args := subtreeRange("path", oldPrefix)
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, table := range subtreeTables {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE `+table+` SET path = ? || substr(path, ?)
+			WHERE account_id = ? AND `+cond,
+			append([]any{newPrefix, utf8.RuneCountInString(oldPrefix) + 1, accountID}, args...)...); err != nil {
+			return conflictErr(err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ResetIndex drops an account's file and folder records and its pending
+// operations, so the index can be rebuilt from a fresh crawl. Placeholders,
+// pins and symlink records describe local state rather than sync progress and
+// are kept.
+func (s *Storage) ResetIndex(ctx context.Context, accountID string) error {
+	if accountID == "" {
+		return errs.New(errs.ErrInvalidArgument, "account id cannot be empty")
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, table := range []string{"files", "folders", "pending_ops"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE account_id = ?`, accountID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 // ListFoldersByPrefix returns folders under a path prefix.
 func (s *Storage) ListFoldersByPrefix(ctx context.Context, accountID, prefix string, limit int) ([]Folder, error) {
+	return s.ListFoldersByPrefixAfter(ctx, accountID, prefix, "", limit)
+}
+
+// ListFoldersByPrefixAfter is ListFilesByPrefixAfter for folders.
+func (s *Storage) ListFoldersByPrefixAfter(ctx context.Context, accountID, prefix, after string, limit int) ([]Folder, error) {
 	if limit <= 0 {
 		limit = 500
 	}
-	pattern := escapeLike(prefix) + "%"
+	cond, args := prefixRange("path", prefix)
 	rows, err := s.DB.QueryContext(ctx, `
-		SELECT id, account_id, path, drive_id, parent_id, modified_at, created_at
+		SELECT `+folderColumns+`
 		FROM folders
-		WHERE account_id = ? AND path LIKE ? ESCAPE '\'
+		WHERE account_id = ? AND `+cond+` AND path > ?
 		ORDER BY path ASC
 		LIMIT ?
-	`, accountID, pattern, limit)
+	`, append(append([]any{accountID}, args...), after, limit)...)
 	if err != nil {
 		return nil, err
 	}
@@ -448,28 +950,35 @@ func (s *Storage) ListFoldersByPrefix(ctx context.Context, accountID, prefix str
 
 	var out []Folder
 	for rows.Next() {
-		var folder Folder
-		var modifiedAt, createdAt int64
-		if err := rows.Scan(&folder.ID, &folder.AccountID, &folder.Path, &folder.DriveID, &folder.ParentID, &modifiedAt, &createdAt); err != nil {
+		folder, err := scanFolder(rows)
+		if err != nil {
 			return nil, err
 		}
-		folder.ModifiedAt = fromUnix(modifiedAt)
-		folder.CreatedAt = fromUnix(createdAt)
-		out = append(out, folder)
+		out = append(out, *folder)
 	}
 	return out, rows.Err()
 }
 
+// CountFoldersByPrefix returns how many folders are under a path prefix.
+func (s *Storage) CountFoldersByPrefix(ctx context.Context, accountID, prefix string) (int, error) {
+	cond, args := prefixRange("path", prefix)
+	var n int
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT COUNT(1) FROM folders WHERE account_id = ? AND `+cond,
+		append([]any{accountID}, args...)...).Scan(&n)
+	return n, err
+}
+
 // UpsertSharedDrive stores shared drive metadata.
 func (s *Storage) UpsertSharedDrive(ctx context.Context, drive *SharedDrive) error {
 	if drive == nil {
 		return nil
 	}
 	if drive.ID == "" {
-		return fmt.Errorf("shared_drive id cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "shared_drive id cannot be empty")
 	}
 	if drive.Name == "" {
-		return fmt.Errorf("shared_drive name cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "shared_drive name cannot be empty")
 	}
 	now := time.Now()
 	if drive.CreatedAt.IsZero() {
@@ -520,16 +1029,16 @@ func (s *Storage) AddPendingOp(ctx context.Context, op *PendingOp) error {
 		return nil
 	}
 	if op.ID == "" {
-		return fmt.Errorf("pending_op id cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "pending_op id cannot be empty")
 	}
 	if op.AccountID == "" {
-		return fmt.Errorf("pending_op account_id cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "pending_op account_id cannot be empty")
 	}
 	if op.Path == "" {
-		return fmt.Errorf("pending_op path cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "pending_op path cannot be empty")
 	}
 	if op.OpType == "" {
-		return fmt.Errorf("pending_op op_type cannot be empty")
+		return errs.New(errs.ErrInvalidArgument, "pending_op op_type cannot be empty")
 	}
 	now := time.Now()
 	if op.CreatedAt.IsZero() {
@@ -539,22 +1048,45 @@ func (s *Storage) AddPendingOp(ctx context.Context, op *PendingOp) error {
 		op.UpdatedAt = now
 	}
 	if op.State == "" {
-		op.State = "queued"
+		op.State = OpQueued
 	}
-	_, err := s.DB.ExecContext(ctx, `
-		INSERT INTO pending_ops (id, account_id, path, drive_id, op_type, state, retry_count, last_error, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, op.ID, op.AccountID, op.Path, op.DriveID, op.OpType, op.State, op.RetryCount, op.LastError, unixTime(op.CreatedAt), unixTime(op.UpdatedAt))
-	return err
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	// The counter outlives the ops, so a seq is never handed out twice even
+	// after the journal drains.
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO op_sequences (account_id, last_seq) VALUES (?, 1)
+		ON CONFLICT(account_id) DO UPDATE SET last_seq = last_seq + 1
+		RETURNING last_seq
+	`, op.AccountID).Scan(&op.Seq); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO pending_ops (`+pendingOpColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, op.ID, op.AccountID, op.Seq, op.Path, op.DriveID, op.OpType, op.Size, op.State, op.RetryCount, op.LastError,
+		op.DependsOn, unixTime(op.NotBefore), unixTime(op.CreatedAt), unixTime(op.UpdatedAt)); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // ListPendingOps returns pending ops for an account, optionally filtered by state.
 func (s *Storage) ListPendingOps(ctx context.Context, accountID, state string, limit int) ([]PendingOp, error) {
+	return s.ListPendingOpsByType(ctx, accountID, state, nil, limit)
+}
+
+// ListPendingOpsByType is ListPendingOps restricted to the given op types. An
+// empty opTypes lists every type.
+func (s *Storage) ListPendingOpsByType(ctx context.Context, accountID, state string, opTypes []string, limit int) ([]PendingOp, error) {
 	if limit <= 0 {
 		limit = 500
 	}
 	query := `
-		SELECT id, account_id, path, drive_id, op_type, state, retry_count, last_error, created_at, updated_at
+		SELECT ` + pendingOpColumns + `
 		FROM pending_ops
 		WHERE account_id = ?
 	`
@@ -564,7 +1096,13 @@ func (s *Storage) ListPendingOps(ctx context.Context, accountID, state string, l
 		query += " AND state = ?"
 		args = append(args, state)
 	}
-	query += " ORDER BY created_at ASC LIMIT ?"
+	if len(opTypes) > 0 {
+		query += " AND op_type IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(opTypes)), ", ") + ")"
+		for _, opType := range opTypes {
+			args = append(args, opType)
+		}
+	}
+	query += " ORDER BY seq ASC LIMIT ?"
 	args = append(args, limit)
 
 	rows, err := s.DB.QueryContext(ctx, query, args...)
@@ -575,18 +1113,28 @@ func (s *Storage) ListPendingOps(ctx context.Context, accountID, state string, l
 
 	var out []PendingOp
 	for rows.Next() {
-		var op PendingOp
-		var createdAt, updatedAt int64
-		if err := rows.Scan(&op.ID, &op.AccountID, &op.Path, &op.DriveID, &op.OpType, &op.State, &op.RetryCount, &op.LastError, &createdAt, &updatedAt); err != nil {
+		op, err := scanPendingOp(rows)
+		if err != nil {
 			return nil, err
 		}
-		op.CreatedAt = fromUnix(createdAt)
-		op.UpdatedAt = fromUnix(updatedAt)
-		out = append(out, op)
+		out = append(out, *op)
 	}
 	return out, rows.Err()
 }
 
+func scanPendingOp(row rowScanner) (*PendingOp, error) {
+	var op PendingOp
+	var notBefore, createdAt, updatedAt int64
+	if err := row.Scan(&op.ID, &op.AccountID, &op.Seq, &op.Path, &op.DriveID, &op.OpType, &op.Size, &op.State,
+		&op.RetryCount, &op.LastError, &op.DependsOn, &notBefore, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	op.NotBefore = fromUnix(notBefore)
+	op.CreatedAt = fromUnix(createdAt)
+	op.UpdatedAt = fromUnix(updatedAt)
+	return &op, nil
+}
+
 // UpdatePendingOp updates pending op state and metadata.
 func (s *Storage) UpdatePendingOp(ctx context.Context, id, state string, retryCount int, lastError string) error {
 	_, err := s.DB.ExecContext(ctx, `
@@ -597,6 +1145,174 @@ func (s *Storage) UpdatePendingOp(ctx context.Context, id, state string, retryCo
 	return err
 }
 
+// ClaimPendingOp moves a queued op to in_progress. It reports false when the
+// op is gone or already claimed.
+func (s *Storage) ClaimPendingOp(ctx context.Context, id string) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE pending_ops
+		SET state = ?, updated_at = ?
+		WHERE id = ? AND state = ?
+	`, OpInProgress, unixTime(time.Now()), id, OpQueued)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ReadyFilter narrows ClaimNextReady.
+type ReadyFilter struct {
+	// OpTypes limits the claim to the op types the caller can run. Empty
+	// matches every type.
+	OpTypes []string
+	// AfterSeq skips ops up to and including this seq, so one pass over the
+	// queue claims each op at most once.
+	AfterSeq int64
+	// Now is compared with each op's NotBefore; zero means time.Now.
+	Now time.Time
+}
+
+// ClaimNextReady moves the queued op with the lowest seq that may run now to
+// in_progress and returns it, or nil when no op is ready. An op is ready
+// once the op it depends on is gone from the journal and its retry backoff
+// has passed. A dependency that failed for good holds its dependents until
+// it is retried or removed. The op is picked and claimed in one statement, so concurrent
+// workers never claim the same one.
+func (s *Storage) ClaimNextReady(ctx context.Context, accountID string, filter ReadyFilter) (*PendingOp, error) {
+	now := filter.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	args := []any{OpInProgress, unixTime(now), accountID, OpQueued, filter.AfterSeq, unixTime(now)}
+	typeFilter := ""
+	if len(filter.OpTypes) > 0 {
+		typeFilter = ` AND p.op_type IN (` + strings.TrimSuffix(strings.Repeat("?, ", len(filter.OpTypes)), ", ") + `)`
+		for _, opType := range filter.OpTypes {
+			args = append(args, opType)
+		}
+	}
+	op, err := scanPendingOp(s.DB.QueryRowContext(ctx, `
+		UPDATE pending_ops SET state = ?, updated_at = ?
+		WHERE id = (
+			SELECT p.id FROM pending_ops p
+			WHERE p.account_id = ? AND p.state = ? AND p.seq > ? AND p.not_before <= ?`+typeFilter+`
+				AND NOT EXISTS (SELECT 1 FROM pending_ops d WHERE d.id = p.depends_on)
+			ORDER BY p.seq ASC
+			LIMIT 1
+		)
+		RETURNING `+pendingOpColumns,
+		args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return op, err
+}
+
+// RequeuePendingOp puts an op that failed back in the queue with its retry
+// count and error, held back until notBefore.
+func (s *Storage) RequeuePendingOp(ctx context.Context, id string, retryCount int, lastError string, notBefore time.Time) error {
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE pending_ops
+		SET state = ?, retry_count = ?, last_error = ?, not_before = ?, updated_at = ?
+		WHERE id = ?
+	`, OpQueued, retryCount, lastError, unixTime(notBefore), unixTime(time.Now()), id)
+	return err
+}
+
+// LastPendingOp returns the journaled op of opType at any of paths with the
+// highest seq, or nil when there is none. The planner uses it to find the op
+// a new one has to wait for.
+func (s *Storage) LastPendingOp(ctx context.Context, accountID, opType string, paths []string) (*PendingOp, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	args := []any{accountID, opType}
+	for _, p := range paths {
+		args = append(args, p)
+	}
+	op, err := scanPendingOp(s.DB.QueryRowContext(ctx, `
+		SELECT `+pendingOpColumns+` FROM pending_ops
+		WHERE account_id = ? AND op_type = ? AND path IN (`+strings.TrimSuffix(strings.Repeat("?, ", len(paths)), ", ")+`)
+		ORDER BY seq DESC
+		LIMIT 1
+	`, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return op, err
+}
+
+// RetryFailedOps moves failed ops back to queued with a fresh retry count and
+// reports how many were moved. An empty id retries every failed op of the
+// account.
+func (s *Storage) RetryFailedOps(ctx context.Context, accountID, id string) (int64, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE pending_ops
+		SET state = ?, retry_count = 0, not_before = 0, updated_at = ?
+		WHERE account_id = ? AND state = ? AND (? = '' OR id = ?)
+	`, OpQueued, unixTime(time.Now()), accountID, OpFailed, id, id)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// CountPendingOps returns how many ops of an account are in the given state.
+func (s *Storage) CountPendingOps(ctx context.Context, accountID, state string) (int, error) {
+	var count int
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT COUNT(1) FROM pending_ops WHERE account_id = ? AND state = ?
+	`, accountID, state).Scan(&count)
+	return count, err
+}
+
+// PendingOpBytes sums the expected size of an account's queued ops of one
+// type.
+func (s *Storage) PendingOpBytes(ctx context.Context, accountID, opType string) (int64, error) {
+	var total int64
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(size), 0) FROM pending_ops WHERE account_id = ? AND op_type = ? AND state = ?
+	`, accountID, opType, OpQueued).Scan(&total)
+	return total, err
+}
+
+// HasPendingOps reports whether any ops are pending for a path, limited to
+// the given op types when there are any.
+func (s *Storage) HasPendingOps(ctx context.Context, accountID, path string, opTypes ...string) (bool, error) {
+	query := `SELECT COUNT(1) FROM pending_ops WHERE account_id = ? AND path = ?`
+	args := []any{accountID, path}
+	if len(opTypes) > 0 {
+		query += ` AND op_type IN (` + strings.TrimSuffix(strings.Repeat("?, ", len(opTypes)), ", ") + `)`
+		for _, opType := range opTypes {
+			args = append(args, opType)
+		}
+	}
+	var count int
+	err := s.DB.QueryRowContext(ctx, query, args...).Scan(&count)
+	return count > 0, err
+}
+
+// DeletePendingOpsForPath removes queued ops of the given types for a path and
+// reports how many were removed.
+func (s *Storage) DeletePendingOpsForPath(ctx context.Context, accountID, path string, opTypes ...string) (int64, error) {
+	if len(opTypes) == 0 {
+		return 0, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(opTypes)), ", ")
+	args := []any{accountID, path}
+	for _, opType := range opTypes {
+		args = append(args, opType)
+	}
+	res, err := s.DB.ExecContext(ctx, `
+		DELETE FROM pending_ops
+		WHERE account_id = ? AND path = ? AND state = 'queued' AND op_type IN (`+placeholders+`)
+	`, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 // DeletePendingOp removes a pending op.
 func (s *Storage) DeletePendingOp(ctx context.Context, id string) error {
 	_, err := s.DB.ExecContext(ctx, `
@@ -605,6 +1321,56 @@ func (s *Storage) DeletePendingOp(ctx context.Context, id string) error {
 	return err
 }
 
+const fileColumns = `id, account_id, path, drive_id, parent_id, shortcut_id, etag, checksum, fast_hash, remote_name, read_only, mime_type, revision_id, trashed, starred, shared, web_view_link, size, device, inode, modified_at, created_at`
+
+const projectionOrder = `shortcut_id != '' ASC, created_at ASC, id ASC`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanFile(row rowScanner) (*FileRecord, error) {
+	var file FileRecord
+	var etag, checksum sql.NullString
+	var device, inode, modifiedAt, createdAt int64
+	if err := row.Scan(&file.ID, &file.AccountID, &file.Path, &file.DriveID, &file.ParentID, &file.ShortcutID, &etag, &checksum, &file.FastHash, &file.RemoteName, &file.ReadOnly, &file.MimeType, &file.RevisionID, &file.Trashed, &file.Starred, &file.Shared, &file.WebViewLink, &file.Size, &device, &inode, &modifiedAt, &createdAt); err != nil {
+		return nil, err
+	}
+	file.ETag = etag.String
+	file.Checksum = checksum.String
+	file.Device = uint64(device)
+	file.Inode = uint64(inode)
+	file.ModifiedAt = fromUnix(modifiedAt)
+	file.CreatedAt = fromUnix(createdAt)
+	return &file, nil
+}
+
+const folderColumns = `id, account_id, path, drive_id, parent_id, orphaned_at, modified_at, created_at`
+
+func scanFolder(row rowScanner) (*Folder, error) {
+	var folder Folder
+	var orphanedAt, modifiedAt, createdAt int64
+	if err := row.Scan(&folder.ID, &folder.AccountID, &folder.Path, &folder.DriveID, &folder.ParentID, &orphanedAt, &modifiedAt, &createdAt); err != nil {
+		return nil, err
+	}
+	folder.OrphanedAt = fromUnix(orphanedAt)
+	folder.ModifiedAt = fromUnix(modifiedAt)
+	folder.CreatedAt = fromUnix(createdAt)
+	return &folder, nil
+}
+
+// conflictErr tags unique constraint violations as errs.ErrConflict.
+func conflictErr(err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return errs.Wrap(errs.ErrConflict, err)
+		}
+	}
+	return err
+}
+
 func unixTime(t time.Time) int64 {
 	if t.IsZero() {
 		return 0
@@ -630,6 +1396,39 @@ func intToBool(val int) bool {
 	return val != 0
 }
 
+// prefixRange returns a condition matching values of column that start with
+// prefix. It is a range rather than LIKE, which SQLite matches without
+// regard to case and so cannot answer from the (account_id, path) indexes.
+func prefixRange(column, prefix string) (string, []any) {
+	end, ok := prefixEnd(prefix)
+	if !ok {
+		return column + " >= ?", []any{prefix}
+	}
+	return column + " >= ? AND " + column + " < ?", []any{prefix, end}
+}
+
+// subtreeRange returns a condition matching path and every path below it.
+// The outer range lets SQLite search the index for path and the few
+// siblings it is a name prefix of, such as "a.txt" for "a"; the inner
+// test drops those siblings. SQLite does not use an index for an OR of
+// the two in DELETE and UPDATE statements.
+func subtreeRange(column, path string) (string, []any) {
+	return column + " >= ? AND " + column + " < ? AND (" + column + " = ? OR " + column + " >= ?)",
+		[]any{path, path + "0", path, path + "/"}
+}
+
+// prefixEnd returns the smallest string that sorts after every string
+// starting with prefix, comparing bytes as SQLite's BINARY collation does.
+// There is none for an empty prefix or one made only of 0xff bytes.
+func prefixEnd(prefix string) (string, bool) {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] < 0xff {
+			return prefix[:i] + string([]byte{prefix[i] + 1}), true
+		}
+	}
+	return "", false
+}
+
 func escapeLike(value string) string {
 	replacer := strings.NewReplacer(
 		"\\", "\\\\",
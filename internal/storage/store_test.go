@@ -1,14 +1,25 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/zalando/go-keyring"
 	"go.uber.org/zap"
 
 	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/encryption"
+	"github.com/sandeepkv93/googlysync/internal/errs"
 )
 
 func newTestStorage(t *testing.T) *Storage {
@@ -129,8 +140,8 @@ func TestAccountDuplicateEmail(t *testing.T) {
 	if err := store.UpsertAccount(ctx, &Account{ID: "acct-1", Email: "user@example.com"}); err != nil {
 		t.Fatalf("UpsertAccount: %v", err)
 	}
-	if err := store.UpsertAccount(ctx, &Account{ID: "acct-2", Email: "user@example.com"}); err == nil {
-		t.Fatal("expected duplicate email constraint error")
+	if err := store.UpsertAccount(ctx, &Account{ID: "acct-2", Email: "user@example.com"}); !errors.Is(err, errs.ErrConflict) {
+		t.Fatalf("expected duplicate email conflict, got %v", err)
 	}
 }
 
@@ -138,8 +149,8 @@ func TestAccountValidation(t *testing.T) {
 	store := newTestStorage(t)
 	ctx := context.Background()
 
-	if err := store.UpsertAccount(ctx, &Account{ID: "", Email: "user@example.com"}); err == nil {
-		t.Fatal("expected error for empty account id")
+	if err := store.UpsertAccount(ctx, &Account{ID: "", Email: "user@example.com"}); !errors.Is(err, errs.ErrInvalidArgument) {
+		t.Fatalf("expected invalid argument for empty account id, got %v", err)
 	}
 	if err := store.UpsertAccount(ctx, &Account{ID: "acct-1", Email: ""}); err == nil {
 		t.Fatal("expected error for empty account email")
@@ -241,6 +252,17 @@ func TestSyncState(t *testing.T) {
 	if !got.LastSyncAt.Equal(lastSync) {
 		t.Fatalf("GetSyncState time mismatch: %#v", got)
 	}
+
+	if err := store.SetSyncPaused(ctx, "acct-1", true); err != nil {
+		t.Fatalf("SetSyncPaused: %v", err)
+	}
+	got, err = store.GetSyncState(ctx, "acct-1")
+	if err != nil || got == nil || !got.Paused || got.StartPageToken != "token-1" {
+		t.Fatalf("expected paused state with token kept, got %#v, %v", got, err)
+	}
+	if err := store.SetSyncPaused(ctx, "missing", true); !errors.Is(err, errs.ErrNotFound) {
+		t.Fatalf("expected not found for unknown account, got %v", err)
+	}
 }
 
 func TestFilesAndFolders(t *testing.T) {
@@ -253,15 +275,22 @@ func TestFilesAndFolders(t *testing.T) {
 
 	modifiedAt := time.Unix(1_700_003_000, 0)
 	file := &FileRecord{
-		ID:         "file-1",
-		AccountID:  "acct-1",
-		Path:       "docs/report.txt",
-		DriveID:    "drive-1",
-		ETag:       "etag-1",
-		Checksum:   "chk-1",
-		Size:       128,
-		ModifiedAt: modifiedAt,
-		CreatedAt:  modifiedAt,
+		ID:          "file-1",
+		AccountID:   "acct-1",
+		Path:        "docs/report.txt",
+		DriveID:     "drive-1",
+		ETag:        "etag-1",
+		Checksum:    "chk-1",
+		RemoteName:  "report?.txt",
+		ReadOnly:    true,
+		MimeType:    "text/plain",
+		RevisionID:  "rev-1",
+		Starred:     true,
+		Shared:      true,
+		WebViewLink: "https://drive.google.com/file/d/drive-1/view",
+		Size:        128,
+		ModifiedAt:  modifiedAt,
+		CreatedAt:   modifiedAt,
 	}
 	if err := store.UpsertFile(ctx, file); err != nil {
 		t.Fatalf("UpsertFile: %v", err)
@@ -271,12 +300,21 @@ func TestFilesAndFolders(t *testing.T) {
 	if err != nil {
 		t.Fatalf("GetFileByPath: %v", err)
 	}
-	if got == nil || got.ID != file.ID || got.DriveID != file.DriveID {
+	if got == nil || got.ID != file.ID || got.DriveID != file.DriveID || got.RemoteName != file.RemoteName || !got.ReadOnly {
 		t.Fatalf("GetFileByPath mismatch: %#v", got)
 	}
 	if !got.ModifiedAt.Equal(modifiedAt) {
 		t.Fatalf("GetFileByPath time mismatch: %#v", got)
 	}
+	if got.MimeType != file.MimeType || got.RevisionID != file.RevisionID || !got.Starred || !got.Shared || got.WebViewLink != file.WebViewLink || got.Trashed {
+		t.Fatalf("GetFileByPath metadata mismatch: %#v", got)
+	}
+	if err := store.SetFileTrashed(ctx, "acct-1", "drive-1", true); err != nil {
+		t.Fatalf("SetFileTrashed: %v", err)
+	}
+	if got, _ := store.GetFileByPath(ctx, "acct-1", "docs/report.txt"); got == nil || !got.Trashed {
+		t.Fatalf("expected the file marked trashed, got %#v", got)
+	}
 
 	gotByDrive, err := store.GetFileByDriveID(ctx, "acct-1", "drive-1")
 	if err != nil {
@@ -329,6 +367,30 @@ func TestFilesAndFolders(t *testing.T) {
 	if len(escaped) != 1 || escaped[0].ID != special.ID {
 		t.Fatalf("ListFilesByPrefix escaped mismatch: %#v", escaped)
 	}
+	page, err := store.ListFilesAfter(ctx, "acct-1", "", 1)
+	if err != nil || len(page) != 1 || page[0].Path != "docs/100%/file.txt" {
+		t.Fatalf("ListFilesAfter first page: %#v, %v", page, err)
+	}
+	page, err = store.ListFilesAfter(ctx, "acct-1", page[0].Path, 1)
+	if err != nil || len(page) != 1 || page[0].Path != "docs/report.txt" {
+		t.Fatalf("ListFilesAfter second page: %#v, %v", page, err)
+	}
+
+	file.Device = 42
+	file.Inode = 1001
+	if err := store.UpsertFile(ctx, file); err != nil {
+		t.Fatalf("UpsertFile identity: %v", err)
+	}
+	byInode, err := store.GetFileByInode(ctx, "acct-1", 42, 1001)
+	if err != nil {
+		t.Fatalf("GetFileByInode: %v", err)
+	}
+	if byInode == nil || byInode.ID != file.ID {
+		t.Fatalf("GetFileByInode mismatch: %#v", byInode)
+	}
+	if other, err := store.GetFileByInode(ctx, "acct-1", 43, 1001); err != nil || other != nil {
+		t.Fatalf("GetFileByInode other device: %#v, %v", other, err)
+	}
 
 	if err := store.DeleteFile(ctx, "acct-1", "docs/report.txt"); err != nil {
 		t.Fatalf("DeleteFile: %v", err)
@@ -377,6 +439,79 @@ func TestFilesAndFolders(t *testing.T) {
 	if len(escapedFolders) != 1 || escapedFolders[0].ID != specialFolder.ID {
 		t.Fatalf("ListFoldersByPrefix escaped mismatch: %#v", escapedFolders)
 	}
+
+	byDrive, err := store.GetFolderByDriveID(ctx, "acct-1", "drive-folder-2")
+	if err != nil || byDrive == nil || byDrive.Path != specialFolder.Path {
+		t.Fatalf("GetFolderByDriveID mismatch: %#v, %v", byDrive, err)
+	}
+	if err := store.DeleteFolder(ctx, "acct-1", specialFolder.Path); err != nil {
+		t.Fatalf("DeleteFolder: %v", err)
+	}
+	if gone, err := store.GetFolderByDriveID(ctx, "acct-1", "drive-folder-2"); err != nil || gone != nil {
+		t.Fatalf("expected folder deleted, got %#v, %v", gone, err)
+	}
+}
+
+func TestFileProjections(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	created := time.Unix(1_700_000_000, 0)
+	records := []*FileRecord{
+		{ID: "file-shortcut", AccountID: "default", Path: "links/a.txt", DriveID: "drive-a", ShortcutID: "sc-1", CreatedAt: created},
+		{ID: "file-second", AccountID: "default", Path: "b/a.txt", DriveID: "drive-a", ParentID: "folder-b", CreatedAt: created.Add(time.Second)},
+		{ID: "file-primary", AccountID: "default", Path: "a/a.txt", DriveID: "drive-a", ParentID: "folder-a", CreatedAt: created},
+	}
+	for _, rec := range records {
+		if err := store.UpsertFile(ctx, rec); err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+	}
+
+	projections, err := store.ListFileProjections(ctx, "default", "drive-a")
+	if err != nil {
+		t.Fatalf("ListFileProjections: %v", err)
+	}
+	var ids []string
+	for _, rec := range projections {
+		ids = append(ids, rec.ID)
+	}
+	if len(ids) != 3 || ids[0] != "file-primary" || ids[1] != "file-second" || ids[2] != "file-shortcut" {
+		t.Fatalf("unexpected projection order: %v", ids)
+	}
+	if projections[2].ShortcutID != "sc-1" || projections[0].ParentID != "folder-a" {
+		t.Fatalf("projection fields not round-tripped: %#v", projections)
+	}
+
+	primary, err := store.GetFileByDriveID(ctx, "default", "drive-a")
+	if err != nil || primary == nil || primary.ID != "file-primary" {
+		t.Fatalf("expected primary projection, got %#v, %v", primary, err)
+	}
+}
+
+func TestListFilesByChecksum(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	for _, rec := range []*FileRecord{
+		{ID: "f1", AccountID: "default", Path: "a.txt", DriveID: "d1", Checksum: "abc", Size: 3},
+		{ID: "f2", AccountID: "default", Path: "b.txt", DriveID: "d2", Checksum: "abc", Size: 4},
+		{ID: "f3", AccountID: "default", Path: "c.txt", DriveID: "d3", Checksum: "def", Size: 3},
+	} {
+		if err := store.UpsertFile(ctx, rec); err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+	}
+	got, err := store.ListFilesByChecksum(ctx, "default", "abc", 3)
+	if err != nil {
+		t.Fatalf("ListFilesByChecksum: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != "f1" {
+		t.Fatalf("expected only f1, got %#v", got)
+	}
+	if none, err := store.ListFilesByChecksum(ctx, "default", "", 3); err != nil || none != nil {
+		t.Fatalf("expected empty checksum to match nothing, got %#v, %v", none, err)
+	}
 }
 
 func TestPendingOps(t *testing.T) {
@@ -397,6 +532,12 @@ func TestPendingOps(t *testing.T) {
 	if err := store.AddPendingOp(ctx, op); err != nil {
 		t.Fatalf("AddPendingOp: %v", err)
 	}
+	if queued, err := store.HasPendingOps(ctx, "acct-1", "docs/report.txt"); err != nil || !queued {
+		t.Fatalf("HasPendingOp: %v %v", queued, err)
+	}
+	if queued, _ := store.HasPendingOps(ctx, "acct-1", "docs/other.txt"); queued {
+		t.Fatal("expected no op for other path")
+	}
 
 	list, err := store.ListPendingOps(ctx, "acct-1", "queued", 0)
 	if err != nil {
@@ -406,6 +547,33 @@ func TestPendingOps(t *testing.T) {
 		t.Fatalf("ListPendingOps mismatch: %#v", list)
 	}
 
+	if claimed, err := store.ClaimPendingOp(ctx, "op-1"); err != nil || !claimed {
+		t.Fatalf("ClaimPendingOp: %v %v", claimed, err)
+	}
+	if claimed, err := store.ClaimPendingOp(ctx, "op-1"); err != nil || claimed {
+		t.Fatalf("expected second claim to fail: %v %v", claimed, err)
+	}
+	running, err := store.ListPendingOps(ctx, "acct-1", OpInProgress, 0)
+	if err != nil || len(running) != 1 {
+		t.Fatalf("ListPendingOps in_progress: %#v, %v", running, err)
+	}
+
+	if err := store.UpdatePendingOp(ctx, "op-1", OpFailed, 5, "boom"); err != nil {
+		t.Fatalf("UpdatePendingOp failed: %v", err)
+	}
+	if n, err := store.CountPendingOps(ctx, "acct-1", OpFailed); err != nil || n != 1 {
+		t.Fatalf("CountPendingOps: %d, %v", n, err)
+	}
+	if n, err := store.RetryFailedOps(ctx, "acct-1", "op-other"); err != nil || n != 0 {
+		t.Fatalf("RetryFailedOps other: %d, %v", n, err)
+	}
+	if n, err := store.RetryFailedOps(ctx, "acct-1", ""); err != nil || n != 1 {
+		t.Fatalf("RetryFailedOps: %d, %v", n, err)
+	}
+	if list, _ := store.ListPendingOps(ctx, "acct-1", OpQueued, 0); len(list) != 1 || list[0].RetryCount != 0 || list[0].LastError != "boom" {
+		t.Fatalf("expected op requeued with fresh retries, got %#v", list)
+	}
+
 	if err := store.UpdatePendingOp(ctx, "op-1", "done", 1, ""); err != nil {
 		t.Fatalf("UpdatePendingOp: %v", err)
 	}
@@ -427,6 +595,133 @@ func TestPendingOps(t *testing.T) {
 	if len(empty) != 0 {
 		t.Fatalf("expected no pending ops, got %#v", empty)
 	}
+
+	for i, opType := range []string{"upload", "copy", "delete"} {
+		op := &PendingOp{ID: fmt.Sprintf("op-path-%d", i), AccountID: "acct-1", Path: "a.txt", OpType: opType}
+		if err := store.AddPendingOp(ctx, op); err != nil {
+			t.Fatalf("AddPendingOp: %v", err)
+		}
+		// Sequence numbers keep counting after the journal was emptied.
+		if op.Seq != int64(i+2) {
+			t.Fatalf("expected seq %d, got %d", i+2, op.Seq)
+		}
+	}
+	if ordered, _ := store.ListPendingOps(ctx, "acct-1", "", 0); len(ordered) != 3 || ordered[0].OpType != "upload" || ordered[2].OpType != "delete" {
+		t.Fatalf("expected ops in planned order, got %#v", ordered)
+	}
+	removed, err := store.DeletePendingOpsForPath(ctx, "acct-1", "a.txt", "upload", "copy")
+	if err != nil || removed != 2 {
+		t.Fatalf("DeletePendingOpsForPath: %d, %v", removed, err)
+	}
+	left, err := store.ListPendingOps(ctx, "acct-1", "", 0)
+	if err != nil || len(left) != 1 || left[0].OpType != "delete" {
+		t.Fatalf("expected only delete op left, got %#v, %v", left, err)
+	}
+	if byType, err := store.ListPendingOpsByType(ctx, "acct-1", OpQueued, []string{"upload", "download"}, 0); err != nil || len(byType) != 0 {
+		t.Fatalf("expected no upload or download ops, got %#v, %v", byType, err)
+	}
+	if byType, err := store.ListPendingOpsByType(ctx, "acct-1", OpQueued, []string{"delete"}, 0); err != nil || len(byType) != 1 {
+		t.Fatalf("expected the delete op, got %#v, %v", byType, err)
+	}
+}
+
+func TestClaimNextReady(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+
+	add := func(id, opType, path, dependsOn string, notBefore time.Time) {
+		t.Helper()
+		op := &PendingOp{ID: id, AccountID: "default", Path: path, OpType: opType, DependsOn: dependsOn, NotBefore: notBefore}
+		if err := store.AddPendingOp(ctx, op); err != nil {
+			t.Fatalf("AddPendingOp %s: %v", id, err)
+		}
+	}
+	add("op-upload", "upload", "docs/a.txt", "op-folder", time.Time{})
+	add("op-folder", "create_folder", "docs", "", time.Time{})
+	add("op-later", "download", "b.txt", "", now.Add(time.Minute))
+	add("op-other", "delete", "c.txt", "", time.Time{})
+
+	claim := func(filter ReadyFilter, want string) {
+		t.Helper()
+		filter.Now = now
+		op, err := store.ClaimNextReady(ctx, "default", filter)
+		if err != nil {
+			t.Fatalf("ClaimNextReady: %v", err)
+		}
+		got := ""
+		if op != nil {
+			got = op.ID
+			if op.State != OpInProgress {
+				t.Fatalf("claimed op in state %s", op.State)
+			}
+		}
+		if got != want {
+			t.Fatalf("ClaimNextReady = %q, want %q", got, want)
+		}
+	}
+	types := []string{"upload", "create_folder", "download"}
+	// The upload was planned first but waits for its folder.
+	claim(ReadyFilter{OpTypes: types}, "op-folder")
+	claim(ReadyFilter{OpTypes: types}, "")
+	if err := store.DeletePendingOp(ctx, "op-folder"); err != nil {
+		t.Fatalf("DeletePendingOp: %v", err)
+	}
+	claim(ReadyFilter{OpTypes: types, AfterSeq: 1}, "")
+	claim(ReadyFilter{OpTypes: types}, "op-upload")
+
+	if err := store.RequeuePendingOp(ctx, "op-upload", 1, "boom", now.Add(time.Hour)); err != nil {
+		t.Fatalf("RequeuePendingOp: %v", err)
+	}
+	claim(ReadyFilter{OpTypes: types}, "")
+	now = now.Add(time.Minute)
+	claim(ReadyFilter{OpTypes: types}, "op-later")
+	claim(ReadyFilter{}, "op-other")
+
+	last, err := store.LastPendingOp(ctx, "default", "upload", []string{"docs/a.txt", "docs"})
+	if err != nil || last == nil || last.ID != "op-upload" || last.RetryCount != 1 || !last.NotBefore.Equal(now.Add(59*time.Minute)) {
+		t.Fatalf("LastPendingOp: %#v, %v", last, err)
+	}
+	if last, err := store.LastPendingOp(ctx, "default", "move", []string{"docs/a.txt"}); err != nil || last != nil {
+		t.Fatalf("LastPendingOp move: %#v, %v", last, err)
+	}
+
+	// Workers racing for the queue each get different ops.
+	for i := 0; i < 20; i++ {
+		add(fmt.Sprintf("op-race-%d", i), "download", fmt.Sprintf("race/%d", i), "", time.Time{})
+	}
+	claimed := make(chan string, 40)
+	done := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for {
+				op, err := store.ClaimNextReady(ctx, "default", ReadyFilter{OpTypes: []string{"download"}, Now: now})
+				if err != nil {
+					t.Errorf("ClaimNextReady: %v", err)
+					return
+				}
+				if op == nil {
+					return
+				}
+				claimed <- op.ID
+			}
+		}()
+	}
+	for w := 0; w < 4; w++ {
+		<-done
+	}
+	close(claimed)
+	seen := map[string]bool{}
+	for id := range claimed {
+		if seen[id] {
+			t.Fatalf("%s claimed twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != 20 {
+		t.Fatalf("claimed %d ops, want 20", len(seen))
+	}
 }
 
 func TestSharedDrives(t *testing.T) {
@@ -450,3 +745,1422 @@ func TestSharedDrives(t *testing.T) {
 		t.Fatalf("ListSharedDrives mismatch: %#v", list)
 	}
 }
+
+func TestOrphanedFoldersAndDeleteSubtree(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	if err := store.UpsertAccount(ctx, &Account{ID: "acct-1", Email: "user@example.com"}); err != nil {
+		t.Fatalf("UpsertAccount: %v", err)
+	}
+	orphanedAt := time.Unix(1_700_005_000, 0)
+	folders := []*Folder{
+		{ID: "f-1", AccountID: "acct-1", Path: "shared", DriveID: "d-1", OrphanedAt: orphanedAt},
+		{ID: "f-2", AccountID: "acct-1", Path: "shared/sub", DriveID: "d-2"},
+		{ID: "f-3", AccountID: "acct-1", Path: "sharedother", DriveID: "d-3"},
+	}
+	for _, folder := range folders {
+		if err := store.UpsertFolder(ctx, folder); err != nil {
+			t.Fatalf("UpsertFolder: %v", err)
+		}
+	}
+	for _, file := range []*FileRecord{
+		{ID: "file-1", AccountID: "acct-1", Path: "shared/sub/a.txt", DriveID: "d-4"},
+		{ID: "file-2", AccountID: "acct-1", Path: "sharedother/b.txt", DriveID: "d-5"},
+	} {
+		if err := store.UpsertFile(ctx, file); err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+	}
+
+	orphaned, err := store.ListOrphanedFolders(ctx, "acct-1")
+	if err != nil {
+		t.Fatalf("ListOrphanedFolders: %v", err)
+	}
+	if len(orphaned) != 1 || orphaned[0].ID != "f-1" || !orphaned[0].OrphanedAt.Equal(orphanedAt) {
+		t.Fatalf("ListOrphanedFolders mismatch: %#v", orphaned)
+	}
+
+	if err := store.DeleteSubtree(ctx, "acct-1", "shared"); err != nil {
+		t.Fatalf("DeleteSubtree: %v", err)
+	}
+	if count := countRows(t, store, "SELECT COUNT(1) FROM folders WHERE account_id = ?", "acct-1"); count != 1 {
+		t.Fatalf("expected only sibling folder left, count=%d", count)
+	}
+	if count := countRows(t, store, "SELECT COUNT(1) FROM files WHERE account_id = ?", "acct-1"); count != 1 {
+		t.Fatalf("expected only sibling file left, count=%d", count)
+	}
+}
+
+func TestPrefixListingPagesByPath(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	if err := store.UpsertAccount(ctx, &Account{ID: "acct-1", Email: "user@example.com"}); err != nil {
+		t.Fatalf("UpsertAccount: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("big/%02d", i)
+		if err := store.UpsertFile(ctx, &FileRecord{ID: "file-" + name, AccountID: "acct-1", Path: name + ".txt", DriveID: "d-" + name}); err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		if err := store.UpsertFolder(ctx, &Folder{ID: "folder-" + name, AccountID: "acct-1", Path: name, DriveID: "df-" + name}); err != nil {
+			t.Fatalf("UpsertFolder: %v", err)
+		}
+	}
+	if err := store.UpsertFile(ctx, &FileRecord{ID: "file-other", AccountID: "acct-1", Path: "other.txt", DriveID: "d-other"}); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+
+	if n, err := store.CountFilesByPrefix(ctx, "acct-1", "big/"); err != nil || n != 5 {
+		t.Fatalf("CountFilesByPrefix = %d, %v", n, err)
+	}
+	if n, err := store.CountFoldersByPrefix(ctx, "acct-1", "big/"); err != nil || n != 5 {
+		t.Fatalf("CountFoldersByPrefix = %d, %v", n, err)
+	}
+
+	var paths []string
+	after := ""
+	for {
+		page, err := store.ListFilesByPrefixAfter(ctx, "acct-1", "big/", after, 2)
+		if err != nil {
+			t.Fatalf("ListFilesByPrefixAfter: %v", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, rec := range page {
+			paths = append(paths, rec.Path)
+		}
+		after = page[len(page)-1].Path
+		// A file removed behind the cursor does not shift later pages.
+		if after == "big/01.txt" {
+			if err := store.DeleteFile(ctx, "acct-1", "big/00.txt"); err != nil {
+				t.Fatalf("DeleteFile: %v", err)
+			}
+		}
+	}
+	if strings.Join(paths, ",") != "big/00.txt,big/01.txt,big/02.txt,big/03.txt,big/04.txt" {
+		t.Fatalf("unexpected pages %v", paths)
+	}
+	folders, err := store.ListFoldersByPrefixAfter(ctx, "acct-1", "big/", "big/03", 10)
+	if err != nil || len(folders) != 1 || folders[0].Path != "big/04" {
+		t.Fatalf("ListFoldersByPrefixAfter: %#v, %v", folders, err)
+	}
+}
+
+func TestPathQueriesUseIndexes(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	prefix, prefixArgs := prefixRange("path", "docs/")
+	subtree, subtreeArgs := subtreeRange("path", "docs")
+	cases := []struct {
+		query string
+		args  []any
+		want  string
+	}{
+		{`SELECT id FROM files WHERE account_id = ? AND ` + prefix + ` ORDER BY path`,
+			append([]any{"acct-1"}, prefixArgs...), "idx_files_account_path (account_id=? AND path>? AND path<?)"},
+		{`DELETE FROM folders WHERE account_id = ? AND ` + subtree,
+			append([]any{"acct-1"}, subtreeArgs...), "idx_folders_account_path (account_id=? AND path>? AND path<?)"},
+		{`SELECT id FROM files WHERE account_id = ? AND drive_id = ?`,
+			[]any{"acct-1", "d-1"}, "idx_files_account_drive_id (account_id=? AND drive_id=?)"},
+		{`SELECT COUNT(1) FROM pending_ops WHERE account_id = ? AND path = ?`,
+			[]any{"acct-1", "docs/a.txt"}, "idx_pending_ops_path (account_id=? AND path=?)"},
+	}
+	for _, tc := range cases {
+		rows, err := store.DB.QueryContext(ctx, `EXPLAIN QUERY PLAN `+tc.query, tc.args...)
+		if err != nil {
+			t.Fatalf("explain %q: %v", tc.query, err)
+		}
+		var plan []string
+		for rows.Next() {
+			var id, parent, unused int
+			var detail string
+			if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+				t.Fatalf("scan plan: %v", err)
+			}
+			plan = append(plan, detail)
+		}
+		rows.Close()
+		if !strings.Contains(strings.Join(plan, "\n"), tc.want) {
+			t.Fatalf("plan for %q = %v, want %s", tc.query, plan, tc.want)
+		}
+	}
+
+	// Ranges compare bytes, so siblings sharing the name and paths that
+	// differ only in case are left alone.
+	for _, p := range []string{"docs", "docs/a.txt", "docs.txt", "Docs/b.txt", "docs0"} {
+		if err := store.UpsertFile(ctx, &FileRecord{ID: "file-" + p, AccountID: "default", Path: p, DriveID: "d-" + p}); err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+	}
+	if n, err := store.CountFilesByPrefix(ctx, "default", "docs"); err != nil || n != 4 {
+		t.Fatalf("CountFilesByPrefix = %d, %v", n, err)
+	}
+	if err := store.DeleteSubtree(ctx, "default", "docs"); err != nil {
+		t.Fatalf("DeleteSubtree: %v", err)
+	}
+	files, err := store.ListFilesByPrefix(ctx, "default", "", 10)
+	if err != nil {
+		t.Fatalf("ListFilesByPrefix: %v", err)
+	}
+	var left []string
+	for _, f := range files {
+		left = append(left, f.Path)
+	}
+	if strings.Join(left, ",") != "Docs/b.txt,docs.txt,docs0" {
+		t.Fatalf("files left after DeleteSubtree = %v", left)
+	}
+
+	if end, ok := prefixEnd("a\xff\xff"); !ok || end != "b" {
+		t.Fatalf("prefixEnd = %q, %v", end, ok)
+	}
+	if _, ok := prefixEnd("\xff"); ok {
+		t.Fatalf("prefixEnd of 0xff has no bound")
+	}
+}
+
+func TestRenamePathPrefix(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	if err := store.UpsertAccount(ctx, &Account{ID: "acct-1", Email: "user@example.com"}); err != nil {
+		t.Fatalf("UpsertAccount: %v", err)
+	}
+	for _, folder := range []*Folder{
+		{ID: "f-1", AccountID: "acct-1", Path: "work", DriveID: "d-1"},
+		{ID: "f-2", AccountID: "acct-1", Path: "work/ünï", DriveID: "d-2"},
+		{ID: "f-3", AccountID: "acct-1", Path: "workbench", DriveID: "d-3"},
+		{ID: "f-4", AccountID: "acct-1", Path: "taken", DriveID: "d-4"},
+	} {
+		if err := store.UpsertFolder(ctx, folder); err != nil {
+			t.Fatalf("UpsertFolder: %v", err)
+		}
+	}
+	for _, file := range []*FileRecord{
+		{ID: "file-1", AccountID: "acct-1", Path: "work/ünï/a.txt", DriveID: "d-5"},
+		{ID: "file-2", AccountID: "acct-1", Path: "workbench/b.txt", DriveID: "d-6"},
+		{ID: "file-3", AccountID: "acct-1", Path: "taken/ünï/a.txt", DriveID: "d-7"},
+	} {
+		if err := store.UpsertFile(ctx, file); err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+	}
+	if err := store.UpsertSymlink(ctx, &Symlink{AccountID: "acct-1", Path: "work/ünï/link", Target: "a.txt"}); err != nil {
+		t.Fatalf("UpsertSymlink: %v", err)
+	}
+
+	if err := store.RenamePathPrefix(ctx, "acct-1", "work", "work/inner"); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected a move into itself refused, got %v", err)
+	}
+	if err := store.RenamePathPrefix(ctx, "acct-1", "work", "taken"); errs.KindOf(err) != errs.ErrConflict {
+		t.Fatalf("expected a clash refused, got %v", err)
+	}
+	if rec, _ := store.GetFileByPath(ctx, "acct-1", "work/ünï/a.txt"); rec == nil {
+		t.Fatal("expected a refused rename to change nothing")
+	}
+
+	if err := store.RenamePathPrefix(ctx, "acct-1", "work", "archive/2024"); err != nil {
+		t.Fatalf("RenamePathPrefix: %v", err)
+	}
+	if rec, err := store.GetFileByPath(ctx, "acct-1", "archive/2024/ünï/a.txt"); err != nil || rec == nil || rec.ID != "file-1" {
+		t.Fatalf("expected the file moved, got %+v, %v", rec, err)
+	}
+	if folder, err := store.GetFolderByDriveID(ctx, "acct-1", "d-2"); err != nil || folder.Path != "archive/2024/ünï" {
+		t.Fatalf("expected the folder moved, got %+v, %v", folder, err)
+	}
+	if rec, _ := store.GetFileByPath(ctx, "acct-1", "workbench/b.txt"); rec == nil {
+		t.Fatal("expected a sibling sharing the prefix left alone")
+	}
+	if link, err := store.GetSymlink(ctx, "acct-1", "archive/2024/ünï/link"); err != nil || link == nil {
+		t.Fatalf("expected the symlink moved, got %+v, %v", link, err)
+	}
+}
+
+func TestResetIndex(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	for _, acct := range []string{"acct-1", "acct-2"} {
+		if err := store.UpsertAccount(ctx, &Account{ID: acct, Email: acct + "@example.com"}); err != nil {
+			t.Fatalf("UpsertAccount: %v", err)
+		}
+		if err := store.UpsertFolder(ctx, &Folder{ID: "f-" + acct, AccountID: acct, Path: "docs", DriveID: "d-" + acct}); err != nil {
+			t.Fatalf("UpsertFolder: %v", err)
+		}
+		if err := store.UpsertFile(ctx, &FileRecord{ID: "file-" + acct, AccountID: acct, Path: "docs/a.txt", DriveID: "df-" + acct}); err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		if err := store.AddPendingOp(ctx, &PendingOp{ID: "op-" + acct, AccountID: acct, OpType: "upload", Path: "docs/a.txt"}); err != nil {
+			t.Fatalf("AddPendingOp: %v", err)
+		}
+	}
+	if err := store.MarkPlaceholder(ctx, "acct-1", "docs/b.txt"); err != nil {
+		t.Fatalf("MarkPlaceholder: %v", err)
+	}
+
+	if err := store.ResetIndex(ctx, "acct-1"); err != nil {
+		t.Fatalf("ResetIndex: %v", err)
+	}
+	for _, table := range []string{"files", "folders", "pending_ops"} {
+		if count := countRows(t, store, "SELECT COUNT(1) FROM "+table+" WHERE account_id = ?", "acct-1"); count != 0 {
+			t.Fatalf("expected %s cleared, count=%d", table, count)
+		}
+		if count := countRows(t, store, "SELECT COUNT(1) FROM "+table+" WHERE account_id = ?", "acct-2"); count != 1 {
+			t.Fatalf("expected other account's %s kept, count=%d", table, count)
+		}
+	}
+	if ok, err := store.IsPlaceholder(ctx, "acct-1", "docs/b.txt"); err != nil || !ok {
+		t.Fatalf("expected placeholder kept, got %v %v", ok, err)
+	}
+	if err := store.ResetIndex(ctx, ""); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+}
+
+func TestPathAliases(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	if got, err := store.GetPathAlias(ctx, "default", "d-1"); err != nil || got != nil {
+		t.Fatalf("expected no alias, got %+v %v", got, err)
+	}
+	alias := &PathAlias{AccountID: "default", DriveID: "d-1", RemotePath: "docs/readme.md", LocalPath: "docs/readme (d-1).md"}
+	if err := store.SavePathAlias(ctx, alias); err != nil {
+		t.Fatalf("SavePathAlias: %v", err)
+	}
+	got, err := store.GetPathAlias(ctx, "default", "d-1")
+	if err != nil || got == nil || got.LocalPath != alias.LocalPath || got.RemotePath != alias.RemotePath {
+		t.Fatalf("unexpected alias %+v %v", got, err)
+	}
+	list, err := store.ListPathAliases(ctx, "default")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListPathAliases: %+v %v", list, err)
+	}
+	if err := store.SavePathAlias(ctx, &PathAlias{AccountID: "default", DriveID: "d-2"}); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+	if err := store.DeletePathAlias(ctx, "default", "d-1"); err != nil {
+		t.Fatalf("DeletePathAlias: %v", err)
+	}
+	if got, err := store.GetPathAlias(ctx, "default", "d-1"); err != nil || got != nil {
+		t.Fatalf("expected alias deleted, got %+v %v", got, err)
+	}
+
+	if err := store.UpsertFile(ctx, &FileRecord{ID: "file-1", AccountID: "default", Path: "docs/README.md", DriveID: "d-3"}); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+	found, err := store.FindFilesFoldingCase(ctx, "default", "DOCS/readme.md")
+	if err != nil || len(found) != 1 || found[0].DriveID != "d-3" {
+		t.Fatalf("FindFilesFoldingCase: %+v %v", found, err)
+	}
+}
+
+func TestEncryptedFiles(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	if got, err := store.GetEncryptedFile(ctx, "default", "d-1"); err != nil || got != nil {
+		t.Fatalf("expected no metadata, got %+v %v", got, err)
+	}
+	file := &EncryptedFile{AccountID: "default", DriveID: "d-1", PlainName: "notes.txt", PlainMD5: "plain", PlainSize: 5, CipherMD5: "cipher"}
+	if err := store.SaveEncryptedFile(ctx, file); err != nil {
+		t.Fatalf("SaveEncryptedFile: %v", err)
+	}
+	file.CipherMD5 = "cipher-2"
+	if err := store.SaveEncryptedFile(ctx, file); err != nil {
+		t.Fatalf("SaveEncryptedFile update: %v", err)
+	}
+	got, err := store.GetEncryptedFile(ctx, "default", "d-1")
+	if err != nil || got == nil || got.PlainMD5 != "plain" || got.PlainSize != 5 || got.CipherMD5 != "cipher-2" || got.PlainName != "notes.txt" {
+		t.Fatalf("unexpected metadata %+v %v", got, err)
+	}
+	if err := store.SaveEncryptedFile(ctx, &EncryptedFile{AccountID: "default"}); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+	if err := store.DeleteEncryptedFile(ctx, "default", "d-1"); err != nil {
+		t.Fatalf("DeleteEncryptedFile: %v", err)
+	}
+	if got, err := store.GetEncryptedFile(ctx, "default", "d-1"); err != nil || got != nil {
+		t.Fatalf("expected metadata deleted, got %+v %v", got, err)
+	}
+}
+
+func TestFolderPolicies(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	for _, policy := range []*FolderPolicy{
+		{AccountID: "default", Path: "work/archive", Direction: "download-only"},
+		{AccountID: "default", Path: "", BandwidthClass: "low"},
+		{AccountID: "default", Path: "work", Ignore: []string{"*.tmp", "build"}, ExportFormat: "pdf"},
+	} {
+		if err := store.SaveFolderPolicy(ctx, policy); err != nil {
+			t.Fatalf("SaveFolderPolicy: %v", err)
+		}
+	}
+	policies, err := store.ListFolderPolicies(ctx, "default")
+	if err != nil || len(policies) != 3 {
+		t.Fatalf("ListFolderPolicies: %d, %v", len(policies), err)
+	}
+	if policies[0].Path != "" || policies[1].Path != "work" || policies[2].Path != "work/archive" {
+		t.Fatalf("expected shallowest first, got %q %q %q", policies[0].Path, policies[1].Path, policies[2].Path)
+	}
+	got, err := store.GetFolderPolicy(ctx, "default", "work")
+	if err != nil || got == nil || len(got.Ignore) != 2 || got.Ignore[1] != "build" || got.ExportFormat != "pdf" {
+		t.Fatalf("unexpected policy %+v %v", got, err)
+	}
+	if err := store.SaveFolderPolicy(ctx, &FolderPolicy{AccountID: "default", Path: "x", Ignore: []string{"a\nb"}}); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected invalid pattern rejected, got %v", err)
+	}
+	if err := store.DeleteFolderPolicy(ctx, "default", "work"); err != nil {
+		t.Fatalf("DeleteFolderPolicy: %v", err)
+	}
+	if got, err := store.GetFolderPolicy(ctx, "default", "work"); err != nil || got != nil {
+		t.Fatalf("expected policy deleted, got %+v %v", got, err)
+	}
+}
+
+func TestSymlinks(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	if err := store.UpsertSymlink(ctx, &Symlink{AccountID: "default", Path: "docs/latest", Target: "v1"}); err != nil {
+		t.Fatalf("UpsertSymlink: %v", err)
+	}
+	if err := store.UpsertSymlink(ctx, &Symlink{AccountID: "default", Path: "docs/latest", Target: "v2", DriveID: "shortcut-1"}); err != nil {
+		t.Fatalf("UpsertSymlink update: %v", err)
+	}
+	if err := store.UpsertSymlink(ctx, &Symlink{AccountID: "default", Path: "docs/empty"}); err == nil {
+		t.Fatal("expected empty target to fail")
+	}
+	link, err := store.GetSymlink(ctx, "default", "docs/latest")
+	if err != nil || link == nil || link.Target != "v2" || link.DriveID != "shortcut-1" {
+		t.Fatalf("expected updated target, got %#v, %v", link, err)
+	}
+
+	if err := store.DeleteSubtree(ctx, "default", "docs"); err != nil {
+		t.Fatalf("DeleteSubtree: %v", err)
+	}
+	links, err := store.ListSymlinks(ctx, "default")
+	if err != nil || len(links) != 0 {
+		t.Fatalf("expected subtree delete to drop links, got %#v, %v", links, err)
+	}
+	if link, err := store.GetSymlink(ctx, "default", "docs/latest"); err != nil || link != nil {
+		t.Fatalf("expected nil for missing link, got %#v, %v", link, err)
+	}
+}
+
+func TestFileWatches(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	if err := store.AddFileWatch(ctx, &FileWatch{AccountID: "default", Path: "shared/plan.doc"}); err == nil {
+		t.Fatal("expected missing drive id to fail")
+	}
+	if err := store.AddFileWatch(ctx, &FileWatch{AccountID: "default", Path: "shared/plan.doc", DriveID: "drive-plan"}); err != nil {
+		t.Fatalf("AddFileWatch: %v", err)
+	}
+	checked := time.Unix(1_700_000_000, 0)
+	if err := store.UpdateFileWatchVersion(ctx, "default", "shared/plan.doc", 7, checked); err != nil {
+		t.Fatalf("UpdateFileWatchVersion: %v", err)
+	}
+	watches, err := store.ListFileWatches(ctx)
+	if err != nil {
+		t.Fatalf("ListFileWatches: %v", err)
+	}
+	if len(watches) != 1 || watches[0].LastVersion != 7 || !watches[0].CheckedAt.Equal(checked) {
+		t.Fatalf("unexpected watches: %#v", watches)
+	}
+
+	removed, err := store.DeleteFileWatch(ctx, "default", "shared/plan.doc")
+	if err != nil || !removed {
+		t.Fatalf("DeleteFileWatch: %v %v", removed, err)
+	}
+	if removed, _ := store.DeleteFileWatch(ctx, "default", "shared/plan.doc"); removed {
+		t.Fatal("expected second delete to report nothing removed")
+	}
+}
+
+func TestPlaceholdersAndPins(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	for _, p := range []string{"docs/a.txt", "docs/sub/b.txt", "other/c.txt"} {
+		if err := store.UpsertFile(ctx, &FileRecord{ID: "file-" + p, AccountID: "default", Path: p, DriveID: "drive-" + p}); err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		if err := store.MarkPlaceholder(ctx, "default", p); err != nil {
+			t.Fatalf("MarkPlaceholder: %v", err)
+		}
+	}
+	if err := store.ClearPlaceholder(ctx, "default", "docs/a.txt"); err != nil {
+		t.Fatalf("ClearPlaceholder: %v", err)
+	}
+	if ok, err := store.IsPlaceholder(ctx, "default", "docs/a.txt"); err != nil || ok {
+		t.Fatalf("expected cleared placeholder, got %v %v", ok, err)
+	}
+	recs, err := store.ListPlaceholders(ctx, "default", "docs")
+	if err != nil || len(recs) != 1 || recs[0].Path != "docs/sub/b.txt" {
+		t.Fatalf("unexpected placeholders under docs: %#v, %v", recs, err)
+	}
+
+	if err := store.PinFolder(ctx, "default", "docs"); err != nil {
+		t.Fatalf("PinFolder: %v", err)
+	}
+	cases := map[string]bool{"docs": true, "docs/sub/b.txt": true, "docsx/a.txt": false, "other/c.txt": false}
+	for p, want := range cases {
+		if got, err := store.IsPinned(ctx, "default", p); err != nil || got != want {
+			t.Fatalf("IsPinned(%s) = %v, %v; want %v", p, got, err, want)
+		}
+	}
+	if removed, err := store.UnpinFolder(ctx, "default", "docs"); err != nil || !removed {
+		t.Fatalf("UnpinFolder: %v %v", removed, err)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	first := &Snapshot{AccountID: "default", Name: "2024-01-01T00-00-00Z"}
+	if err := store.CreateSnapshot(ctx, first, []SnapshotEntry{
+		{Path: "docs/a.txt", Size: 1, Checksum: "aaa", Action: SnapshotUpload},
+	}); err != nil {
+		t.Fatalf("CreateSnapshot: %v", err)
+	}
+	second := &Snapshot{AccountID: "default", Name: "2024-01-02T00-00-00Z"}
+	if err := store.CreateSnapshot(ctx, second, []SnapshotEntry{
+		{Path: "docs/a.txt", Size: 1, Checksum: "aaa", Action: SnapshotLink, BaseSnapshotID: first.ID},
+		{Path: "docs/b.txt", Size: 2, Checksum: "bbb", Action: SnapshotUpload},
+	}); err != nil {
+		t.Fatalf("CreateSnapshot: %v", err)
+	}
+	if err := store.CreateSnapshot(ctx, &Snapshot{AccountID: "default", Name: second.Name}, nil); !errors.Is(err, errs.ErrConflict) {
+		t.Fatalf("expected duplicate name conflict, got %v", err)
+	}
+
+	latest, err := store.LatestSnapshot(ctx, "default")
+	if err != nil || latest == nil || latest.ID != second.ID {
+		t.Fatalf("expected latest second snapshot, got %#v, %v", latest, err)
+	}
+	entries, err := store.ListSnapshotEntries(ctx, second.ID, "docs/b.txt")
+	if err != nil || len(entries) != 1 || entries[0].BaseSnapshotID != second.ID {
+		t.Fatalf("unexpected entries: %#v, %v", entries, err)
+	}
+
+	pending, err := store.ListPendingSnapshotEntries(ctx, "default", 0)
+	if err != nil || len(pending) != 3 || pending[0].SnapshotID != first.ID {
+		t.Fatalf("expected oldest snapshot first, got %#v, %v", pending, err)
+	}
+	if err := store.CompleteSnapshotEntry(ctx, first.ID, "docs/a.txt", "drive-a"); err != nil {
+		t.Fatalf("CompleteSnapshotEntry: %v", err)
+	}
+	if snap, _ := store.GetSnapshot(ctx, "default", first.Name); snap == nil || snap.CompletedAt.IsZero() {
+		t.Fatalf("expected first snapshot complete, got %#v", snap)
+	}
+	if snap, _ := store.GetSnapshot(ctx, "default", second.Name); snap == nil || !snap.CompletedAt.IsZero() {
+		t.Fatalf("expected second snapshot still pending, got %#v", snap)
+	}
+}
+
+func TestDevice(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	if d, err := store.GetDevice(ctx, "default"); err != nil || d != nil {
+		t.Fatalf("expected no device, got %#v, %v", d, err)
+	}
+	if err := store.SaveDevice(ctx, &Device{AccountID: "default", Name: "laptop"}); err == nil {
+		t.Fatal("expected missing drive id to fail")
+	}
+	if err := store.SaveDevice(ctx, &Device{AccountID: "default", Name: "laptop", DriveID: "node", RootDriveID: "root"}); err != nil {
+		t.Fatalf("SaveDevice: %v", err)
+	}
+	if err := store.SaveDevice(ctx, &Device{AccountID: "default", Name: "workstation", DriveID: "node", RootDriveID: "root"}); err != nil {
+		t.Fatalf("SaveDevice rename: %v", err)
+	}
+	d, err := store.GetDevice(ctx, "default")
+	if err != nil || d == nil || d.Name != "workstation" || d.RootDriveID != "root" || d.CreatedAt.IsZero() {
+		t.Fatalf("unexpected device: %#v, %v", d, err)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	if q, err := store.GetQuota(ctx, "default"); err != nil || q != nil {
+		t.Fatalf("expected no quota, got %#v, %v", q, err)
+	}
+	if err := store.SaveQuota(ctx, &Quota{LimitBytes: 10}); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+	for _, usage := range []int64{5, 9} {
+		if err := store.SaveQuota(ctx, &Quota{AccountID: "default", LimitBytes: 10, UsageBytes: usage, TrashBytes: 1}); err != nil {
+			t.Fatalf("SaveQuota: %v", err)
+		}
+	}
+	q, err := store.GetQuota(ctx, "default")
+	if err != nil || q == nil || q.UsageBytes != 9 || q.LimitBytes != 10 || q.TrashBytes != 1 || q.CheckedAt.IsZero() {
+		t.Fatalf("unexpected quota: %#v, %v", q, err)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	if err := store.AddWebhook(ctx, &Webhook{ID: "wh-1", AccountID: "default", URL: "https://example.com/hook"}); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected missing secret to fail, got %v", err)
+	}
+	hooks := []*Webhook{
+		{ID: "wh-1", AccountID: "default", URL: "https://example.com/a", Secret: "s1", Events: []string{"sync.completed", "auth.expired"}},
+		{ID: "wh-2", AccountID: "work", URL: "https://example.com/b", Secret: "s2"},
+	}
+	for _, hook := range hooks {
+		if err := store.AddWebhook(ctx, hook); err != nil {
+			t.Fatalf("AddWebhook %s: %v", hook.ID, err)
+		}
+	}
+	got, err := store.ListWebhooks(ctx, "default")
+	if err != nil || len(got) != 1 || got[0].Secret != "s1" || len(got[0].Events) != 2 {
+		t.Fatalf("unexpected default webhooks: %#v, %v", got, err)
+	}
+	if !got[0].Wants("auth.expired") || got[0].Wants("conflict.detected") {
+		t.Fatalf("unexpected event filter: %v", got[0].Events)
+	}
+	if all, err := store.ListWebhooks(ctx, ""); err != nil || len(all) != 2 || !all[1].Wants("anything") {
+		t.Fatalf("unexpected webhooks: %#v, %v", all, err)
+	}
+	if err := store.DeleteWebhook(ctx, "wh-1"); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+	if err := store.DeleteWebhook(ctx, "wh-1"); errs.KindOf(err) != errs.ErrNotFound {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestTransferSessions(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	up := &TransferSession{ID: "ts-up", AccountID: "default", Direction: TransferUpload, Path: "big.iso", SessionURI: "https://upload/1", Size: 100, Offset: 40}
+	down := &TransferSession{
+		ID: "ts-down", AccountID: "default", Direction: TransferDownload, Path: "video.mp4", DriveID: "drive-v",
+		Size: 30, ChunkSize: 10, Chunks: []TransferChunk{{Index: 1, Checksum: "b"}, {Index: 0, Checksum: "a"}},
+	}
+	for _, s := range []*TransferSession{up, down} {
+		if err := store.SaveTransferSession(ctx, s); err != nil {
+			t.Fatalf("SaveTransferSession %s: %v", s.ID, err)
+		}
+	}
+	if err := store.SaveTransferSession(ctx, &TransferSession{ID: "bad", AccountID: "default", Direction: "sideways", Path: "x"}); !errors.Is(err, errs.ErrInvalidArgument) {
+		t.Fatalf("expected invalid direction rejected, got %v", err)
+	}
+
+	sessions, err := store.ListTransferSessions(ctx, "")
+	if err != nil {
+		t.Fatalf("ListTransferSessions: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != "ts-up" || sessions[0].Offset != 40 || sessions[0].SessionURI != "https://upload/1" {
+		t.Fatalf("unexpected sessions: %#v", sessions)
+	}
+	if chunks := sessions[1].Chunks; len(chunks) != 2 || chunks[0].Index != 0 || chunks[1].Checksum != "b" {
+		t.Fatalf("unexpected chunks: %#v", chunks)
+	}
+
+	// Saving a new session for the same path replaces the old one.
+	replacement := &TransferSession{ID: "ts-down-2", AccountID: "default", Direction: TransferDownload, Path: "video.mp4", ChunkSize: 10}
+	if err := store.SaveTransferSession(ctx, replacement); err != nil {
+		t.Fatalf("SaveTransferSession replacement: %v", err)
+	}
+	if err := store.DeleteTransferSession(ctx, "ts-up"); err != nil {
+		t.Fatalf("DeleteTransferSession: %v", err)
+	}
+	sessions, err = store.ListTransferSessions(ctx, "default")
+	if err != nil {
+		t.Fatalf("ListTransferSessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != "ts-down-2" || len(sessions[0].Chunks) != 0 {
+		t.Fatalf("expected only the replacement left, got %#v", sessions)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	base := time.Unix(1_700_000_000, 0)
+	for i, action := range []string{"download", "move_local", "upload"} {
+		entry := &AuditEntry{AccountID: "default", Action: action, Path: fmt.Sprintf("f%d.txt", i), RecordedAt: base.Add(time.Duration(i) * time.Minute)}
+		if err := store.AddAuditEntry(ctx, entry); err != nil {
+			t.Fatalf("AddAuditEntry: %v", err)
+		}
+	}
+	if err := store.AddAuditEntry(ctx, &AuditEntry{AccountID: "default", Path: "x"}); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected invalid argument for missing action, got %v", err)
+	}
+
+	entries, err := store.ListAuditEntries(ctx, "default", base.Add(time.Minute), 0)
+	if err != nil {
+		t.Fatalf("ListAuditEntries: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Action != "move_local" || entries[1].Action != "upload" {
+		t.Fatalf("unexpected entries since: %#v", entries)
+	}
+	entries, err = store.ListAuditEntries(ctx, "default", time.Time{}, 1)
+	if err != nil || len(entries) != 1 || entries[0].Path != "f2.txt" {
+		t.Fatalf("expected newest entry only, got %#v, %v", entries, err)
+	}
+}
+
+func TestRemoteTokens(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	for _, tok := range []*RemoteToken{
+		{ID: "tok-1", Name: "laptop", Role: "read", TokenHash: "hash-1"},
+		{ID: "tok-2", Name: "desktop", Role: "admin", TokenHash: "hash-2"},
+	} {
+		if err := store.AddRemoteToken(ctx, tok); err != nil {
+			t.Fatalf("AddRemoteToken: %v", err)
+		}
+	}
+	if err := store.AddRemoteToken(ctx, &RemoteToken{ID: "tok-3", Role: "read", TokenHash: "hash-1"}); err == nil {
+		t.Fatal("expected duplicate hash to be rejected")
+	}
+	if err := store.AddRemoteToken(ctx, &RemoteToken{ID: "tok-4", TokenHash: "hash-4"}); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected invalid argument for missing role, got %v", err)
+	}
+
+	tok, err := store.GetRemoteTokenByHash(ctx, "hash-2")
+	if err != nil || tok == nil || tok.ID != "tok-2" || tok.Role != "admin" {
+		t.Fatalf("GetRemoteTokenByHash: %#v, %v", tok, err)
+	}
+	if tok, err := store.GetRemoteTokenByHash(ctx, "missing"); err != nil || tok != nil {
+		t.Fatalf("expected no token, got %#v, %v", tok, err)
+	}
+
+	if err := store.DeleteRemoteToken(ctx, "tok-1"); err != nil {
+		t.Fatalf("DeleteRemoteToken: %v", err)
+	}
+	if err := store.DeleteRemoteToken(ctx, "tok-1"); errs.KindOf(err) != errs.ErrNotFound {
+		t.Fatalf("expected not found on second revoke, got %v", err)
+	}
+	list, err := store.ListRemoteTokens(ctx)
+	if err != nil || len(list) != 1 || list[0].Name != "desktop" {
+		t.Fatalf("ListRemoteTokens: %#v, %v", list, err)
+	}
+}
+
+func TestRollBackAndMigrateAgain(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{DatabasePath: filepath.Join(t.TempDir(), "googlysync.db")}
+	store, err := NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	_ = store.Close()
+	migrations, err := SchemaStatus(ctx, cfg)
+	if err != nil {
+		t.Fatalf("SchemaStatus: %v", err)
+	}
+	latest := migrations[len(migrations)-1]
+	if !latest.Applied || latest.Version < 2 {
+		t.Fatalf("expected every migration applied, got %+v", latest)
+	}
+
+	if err := RollBack(ctx, cfg, zap.NewNop(), latest.Version); err == nil {
+		t.Fatalf("expected rolling back to the current version rejected")
+	}
+	if err := RollBack(ctx, cfg, zap.NewNop(), latest.Version-1); err != nil {
+		t.Fatalf("RollBack: %v", err)
+	}
+	backups, err := SchemaBackups(cfg.DatabasePath)
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("expected one backup, got %v (%v)", backups, err)
+	}
+	migrations, _ = SchemaStatus(ctx, cfg)
+	if migrations[len(migrations)-1].Applied {
+		t.Fatalf("expected the latest migration pending after rollback")
+	}
+
+	store, err = NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage after rollback: %v", err)
+	}
+	_ = store.Close()
+	migrations, _ = SchemaStatus(ctx, cfg)
+	if !migrations[len(migrations)-1].Applied {
+		t.Fatalf("expected the rolled back migration applied again")
+	}
+	backups, _ = SchemaBackups(cfg.DatabasePath)
+	if len(backups) != 2 || !strings.HasSuffix(backups[0], fmt.Sprintf(".v%d.bak", latest.Version)) {
+		t.Fatalf("expected a second backup taken before migrating, got %v", backups)
+	}
+}
+
+func TestConnectionTuning(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{DatabasePath: filepath.Join(t.TempDir(), "googlysync.db")}
+	store, err := NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	defer store.Close()
+
+	var mode string
+	var foreignKeys int
+	if err := store.DB.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Fatalf("expected WAL journaling, got %q (%v)", mode, err)
+	}
+	if err := store.DB.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil || foreignKeys != 1 {
+		t.Fatalf("expected foreign keys enforced, got %d (%v)", foreignKeys, err)
+	}
+
+	if err := store.UpsertAccount(ctx, &Account{ID: "acct-1", Email: "user@example.com"}); err != nil {
+		t.Fatalf("UpsertAccount: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		path := fmt.Sprintf("crawl/file-%d.txt", i)
+		file := &FileRecord{ID: fmt.Sprintf("f-%d", i), AccountID: "acct-1", Path: path, DriveID: fmt.Sprintf("d-%d", i)}
+		if err := store.UpsertFile(ctx, file); err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		if got, err := store.GetFileByPath(ctx, "acct-1", path); err != nil || got == nil {
+			t.Fatalf("GetFileByPath(%q) = %v, %v", path, got, err)
+		}
+	}
+	if len(store.stmts) != 2 {
+		t.Fatalf("expected the upsert and lookup prepared once each, got %d statements", len(store.stmts))
+	}
+
+	// A second connection, as the CLI opens, reads while a write is open.
+	other, err := NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	defer other.Close()
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM files WHERE id = 'f-0'`); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got := countRows(t, other, `SELECT COUNT(*) FROM files`); got != 50 {
+		t.Fatalf("expected the reader to see the committed rows, got %d", got)
+	}
+}
+
+func TestSearchFilesRanksNamesOverFolders(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+	if err := store.UpsertAccount(ctx, &Account{ID: "acct-1", Email: "user@example.com"}); err != nil {
+		t.Fatalf("UpsertAccount: %v", err)
+	}
+	for i, p := range []string{"reports/summary.txt", "misc/Quarterly Report.pdf", "misc/photo.jpg", "notes/q3 report draft.md"} {
+		file := &FileRecord{ID: fmt.Sprintf("f-%d", i), AccountID: "acct-1", Path: p, DriveID: fmt.Sprintf("d-%d", i)}
+		if err := store.UpsertFile(ctx, file); err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+	}
+	paths := func(query string) []string {
+		t.Helper()
+		files, err := store.SearchFiles(ctx, "acct-1", query, 10)
+		if err != nil {
+			t.Fatalf("SearchFiles(%q): %v", query, err)
+		}
+		var out []string
+		for _, f := range files {
+			out = append(out, f.Path)
+		}
+		return out
+	}
+
+	got := paths("REPORT")
+	if len(got) != 3 || got[2] != "reports/summary.txt" {
+		t.Fatalf("expected name hits ranked above the folder hit, got %v", got)
+	}
+	if got := paths("draft q3"); len(got) != 1 || got[0] != "notes/q3 report draft.md" {
+		t.Fatalf("expected terms matched in any order, short ones too, got %v", got)
+	}
+	if got := paths(`port"`); len(got) != 0 {
+		t.Fatalf("expected a quote in the query matched literally, got %v", got)
+	}
+
+	if err := store.UpsertFile(ctx, &FileRecord{ID: "f-2", AccountID: "acct-1", Path: "misc/report-photo.jpg", DriveID: "d-2"}); err != nil {
+		t.Fatalf("UpsertFile rename: %v", err)
+	}
+	if err := store.DeleteFile(ctx, "acct-1", "reports/summary.txt"); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	if got := paths("photo"); len(got) != 1 || got[0] != "misc/report-photo.jpg" {
+		t.Fatalf("expected the renamed path indexed, got %v", got)
+	}
+	if got := paths("summary"); len(got) != 0 {
+		t.Fatalf("expected the deleted file dropped from the index, got %v", got)
+	}
+}
+
+func TestConflictLifecycle(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	first := &ConflictRecord{AccountID: "acct-1", Path: "docs/a.txt", DriveID: "d-a", BaseRevision: "r1", RemoteRevision: "r2"}
+	if err := store.AddConflict(ctx, first); err != nil || first.ID == 0 {
+		t.Fatalf("AddConflict: %d, %v", first.ID, err)
+	}
+	// A second collision on the same path updates the open conflict.
+	again := &ConflictRecord{AccountID: "acct-1", Path: "docs/a.txt", RemoteRevision: "r3"}
+	if err := store.AddConflict(ctx, again); err != nil || again.ID != first.ID {
+		t.Fatalf("expected the open conflict reused, got %d, %v", again.ID, err)
+	}
+	got, err := store.GetConflict(ctx, first.ID)
+	if err != nil || got == nil || got.RemoteRevision != "r3" || got.BaseRevision != "r1" || got.DriveID != "d-a" || got.State != ConflictUnresolved {
+		t.Fatalf("unexpected conflict %#v, %v", got, err)
+	}
+	if err := store.AddConflict(ctx, &ConflictRecord{AccountID: "acct-1", Path: "b.txt"}); err != nil {
+		t.Fatalf("AddConflict: %v", err)
+	}
+	if err := store.AddConflict(ctx, &ConflictRecord{AccountID: "acct-1"}); err == nil {
+		t.Fatal("expected a conflict without a path rejected")
+	}
+
+	if err := store.ResolveConflict(ctx, first.ID, "kept_both"); err != nil {
+		t.Fatalf("ResolveConflict: %v", err)
+	}
+	if err := store.ResolveConflict(ctx, first.ID, "kept_local"); errs.KindOf(err) != errs.ErrNotFound {
+		t.Fatalf("expected resolving twice to fail, got %v", err)
+	}
+	open, err := store.ListConflicts(ctx, "acct-1", ConflictUnresolved)
+	if err != nil || len(open) != 1 || open[0].Path != "b.txt" {
+		t.Fatalf("expected only b.txt unresolved, got %#v, %v", open, err)
+	}
+	resolved, _ := store.GetConflict(ctx, first.ID)
+	if resolved.State != ConflictResolved || resolved.Resolution != "kept_both" || resolved.ResolvedAt.IsZero() {
+		t.Fatalf("expected the resolution recorded, got %#v", resolved)
+	}
+	// A new collision after resolving opens a fresh conflict.
+	if err := store.AddConflict(ctx, &ConflictRecord{AccountID: "acct-1", Path: "docs/a.txt"}); err != nil {
+		t.Fatalf("AddConflict: %v", err)
+	}
+	if all, _ := store.ListConflicts(ctx, "acct-1", ""); len(all) != 3 {
+		t.Fatalf("expected three conflicts in all, got %#v", all)
+	}
+
+	if err := store.DeleteConflict(ctx, first.ID); err != nil {
+		t.Fatalf("DeleteConflict: %v", err)
+	}
+	if gone, err := store.GetConflict(ctx, first.ID); err != nil || gone != nil {
+		t.Fatalf("expected the conflict deleted, got %#v, %v", gone, err)
+	}
+}
+
+func TestIgnoreRules(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	mine := &IgnoreRule{AccountID: "acct-1", Root: "/sync", Pattern: "*.bak", Source: "cli"}
+	if err := store.AddIgnoreRule(ctx, mine); err != nil || mine.ID == 0 {
+		t.Fatalf("AddIgnoreRule: %d, %v", mine.ID, err)
+	}
+	for _, rule := range []*IgnoreRule{
+		{AccountID: "acct-1", Pattern: "node_modules"},
+		{AccountID: "acct-1", Root: "/elsewhere", Pattern: "*.log"},
+		{AccountID: "acct-2", Root: "/sync", Pattern: "*.iso"},
+	} {
+		if err := store.AddIgnoreRule(ctx, rule); err != nil {
+			t.Fatalf("AddIgnoreRule: %v", err)
+		}
+	}
+	if err := store.AddIgnoreRule(ctx, &IgnoreRule{AccountID: "acct-1", Pattern: "a\nb"}); err == nil {
+		t.Fatal("expected a multi-line pattern rejected")
+	}
+
+	patterns := func(enabledOnly bool) []string {
+		t.Helper()
+		rules, err := store.ListIgnoreRules(ctx, "acct-1", "/sync", enabledOnly)
+		if err != nil {
+			t.Fatalf("ListIgnoreRules: %v", err)
+		}
+		var out []string
+		for _, r := range rules {
+			out = append(out, r.Pattern)
+		}
+		return out
+	}
+	if got := patterns(true); fmt.Sprint(got) != "[*.bak node_modules]" {
+		t.Fatalf("expected the root's and any-root rules, got %v", got)
+	}
+
+	if err := store.SetIgnoreRuleEnabled(ctx, "acct-1", mine.ID, false); err != nil {
+		t.Fatalf("SetIgnoreRuleEnabled: %v", err)
+	}
+	if got := patterns(true); fmt.Sprint(got) != "[node_modules]" {
+		t.Fatalf("expected the disabled rule left out, got %v", got)
+	}
+	if got := patterns(false); len(got) != 2 {
+		t.Fatalf("expected the disabled rule still listed, got %v", got)
+	}
+	// Adding the pattern again turns it back on under the same id.
+	again := &IgnoreRule{AccountID: "acct-1", Root: "/sync", Pattern: "*.bak"}
+	if err := store.AddIgnoreRule(ctx, again); err != nil || again.ID != mine.ID {
+		t.Fatalf("expected the rule re-enabled in place, got %d, %v", again.ID, err)
+	}
+	if got := patterns(true); len(got) != 2 {
+		t.Fatalf("expected the rule enabled again, got %v", got)
+	}
+
+	if err := store.RemoveIgnoreRule(ctx, "acct-2", mine.ID); errs.KindOf(err) != errs.ErrNotFound {
+		t.Fatalf("expected another account's removal to miss, got %v", err)
+	}
+	if err := store.RemoveIgnoreRule(ctx, "acct-1", mine.ID); err != nil {
+		t.Fatalf("RemoveIgnoreRule: %v", err)
+	}
+	if got := patterns(false); fmt.Sprint(got) != "[node_modules]" {
+		t.Fatalf("expected the rule removed, got %v", got)
+	}
+}
+
+func TestMaintainConvertsAndReclaimsSpace(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{DatabasePath: filepath.Join(t.TempDir(), "googlysync.db")}
+	// A database created before incremental auto-vacuum was configured.
+	legacy, err := sql.Open("sqlite", cfg.DatabasePath)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	if _, err := legacy.Exec(`CREATE TABLE legacy (v TEXT)`); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	_ = legacy.Close()
+
+	store, err := NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	defer store.Close()
+
+	report, err := store.Maintain(ctx, true)
+	if err != nil || !report.Converted || len(report.Problems) != 0 {
+		t.Fatalf("expected the database converted, got %#v, %v", report, err)
+	}
+	var mode int
+	if err := store.DB.QueryRow(`PRAGMA auto_vacuum`).Scan(&mode); err != nil || mode != autoVacuumIncremental {
+		t.Fatalf("expected incremental auto-vacuum, got %d (%v)", mode, err)
+	}
+
+	filler := strings.Repeat("x", 4000)
+	for i := 0; i < 200; i++ {
+		if _, err := store.DB.Exec(`INSERT INTO legacy (v) VALUES (?)`, filler); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	if _, err := store.DB.Exec(`DELETE FROM legacy`); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	report, err = store.Maintain(ctx, false)
+	if err != nil || report.Converted || report.FreedPages < 100 {
+		t.Fatalf("expected free pages reclaimed, got %#v, %v", report, err)
+	}
+	if n := countRows(t, store, `SELECT COUNT(1) FROM sqlite_master WHERE name = 'sqlite_stat1'`); n != 1 {
+		t.Fatalf("expected ANALYZE to have run, got %d stat tables", n)
+	}
+	if last, err := store.GetSettingTime(ctx, SettingLastMaintenance); err != nil || time.Since(last) > time.Minute {
+		t.Fatalf("expected the maintenance time recorded, got %v, %v", last, err)
+	}
+}
+
+func TestEncryptedDatabase(t *testing.T) {
+	keyring.MockInit()
+	ctx := context.Background()
+	cfg := &config.Config{AppName: "googlysync-test", DatabasePath: filepath.Join(t.TempDir(), "googlysync.db"), EncryptDatabase: true}
+
+	store, err := NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	if err := store.UpsertAccount(ctx, &Account{ID: "acct-1", Email: "secret-user@example.com"}); err != nil {
+		t.Fatalf("UpsertAccount: %v", err)
+	}
+	if err := store.UpsertFile(ctx, &FileRecord{ID: "f-1", AccountID: "acct-1", Path: "tax/returns-2024.pdf", DriveID: "d-1"}); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+	// Only one process may hold an encrypted database.
+	if _, err := NewStorage(cfg, zap.NewNop()); errs.KindOf(err) != errs.ErrConflict {
+		t.Fatalf("expected a second open refused, got %v", err)
+	}
+	if report, err := store.Maintain(ctx, false); err != nil || len(report.Problems) != 0 {
+		t.Fatalf("Maintain: %#v, %v", report, err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	raw, err := os.ReadFile(cfg.DatabasePath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !encryption.IsEncrypted(raw) || bytes.Contains(raw, []byte("secret-user")) || bytes.Contains(raw, []byte("returns-2024")) {
+		t.Fatal("expected the database file sealed")
+	}
+
+	// The file decides from now on, whatever the config says.
+	cfg.EncryptDatabase = false
+	store, err = NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer store.Close()
+	acct, err := store.GetAccount(ctx, "acct-1")
+	if err != nil || acct == nil || acct.Email != "secret-user@example.com" {
+		t.Fatalf("expected the account read back, got %#v, %v", acct, err)
+	}
+	if rec, err := store.GetFileByPath(ctx, "acct-1", "tax/returns-2024.pdf"); err != nil || rec == nil {
+		t.Fatalf("expected the file read back, got %#v, %v", rec, err)
+	}
+}
+
+func TestEncryptionOnlyAppliesToNewDatabases(t *testing.T) {
+	keyring.MockInit()
+	cfg := &config.Config{AppName: "googlysync-test", DatabasePath: filepath.Join(t.TempDir(), "googlysync.db")}
+	store, err := NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	_ = store.Close()
+
+	cfg.EncryptDatabase = true
+	store, err = NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer store.Close()
+	if store.sealed != nil {
+		t.Fatal("expected an existing plain database left unencrypted")
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := &config.Config{DatabasePath: filepath.Join(dir, "googlysync.db")}
+	store, err := NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	if err := store.UpsertAccount(ctx, &Account{ID: "acct-1", Email: "a@example.com"}); err != nil {
+		t.Fatalf("UpsertAccount: %v", err)
+	}
+	if err := store.UpsertFile(ctx, &FileRecord{ID: "f-1", AccountID: "acct-1", Path: "kept.txt", DriveID: "d-1"}); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+	backup := filepath.Join(dir, "snapshot.db")
+	if err := store.Backup(ctx, backup); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if err := store.Backup(ctx, backup); errs.KindOf(err) != errs.ErrConflict {
+		t.Fatalf("expected an existing backup kept, got %v", err)
+	}
+	if err := store.UpsertFile(ctx, &FileRecord{ID: "f-2", AccountID: "acct-1", Path: "later.txt", DriveID: "d-2"}); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	saved, err := Restore(ctx, cfg, zap.NewNop(), backup)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if _, err := os.Stat(saved); err != nil {
+		t.Fatalf("expected the replaced database saved: %v", err)
+	}
+	store, err = NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer store.Close()
+	if rec, err := store.GetFileByPath(ctx, "acct-1", "kept.txt"); err != nil || rec == nil {
+		t.Fatalf("expected kept.txt restored, got %+v, %v", rec, err)
+	}
+	if rec, err := store.GetFileByPath(ctx, "acct-1", "later.txt"); err != nil || rec != nil {
+		t.Fatalf("expected later.txt gone after restore, got %+v, %v", rec, err)
+	}
+
+	junk := filepath.Join(dir, "junk.db")
+	if err := os.WriteFile(junk, []byte("not a database"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := Restore(ctx, cfg, zap.NewNop(), junk); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected junk refused, got %v", err)
+	}
+}
+
+func TestBackupAndRestoreEncrypted(t *testing.T) {
+	keyring.MockInit()
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := &config.Config{AppName: "googlysync-test", DatabasePath: filepath.Join(dir, "googlysync.db"), EncryptDatabase: true}
+	store, err := NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	if err := store.UpsertAccount(ctx, &Account{ID: "acct-1", Email: "secret-user@example.com"}); err != nil {
+		t.Fatalf("UpsertAccount: %v", err)
+	}
+	backup := filepath.Join(dir, "snapshot.db")
+	if err := store.Backup(ctx, backup); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if err := store.DeleteAccount(ctx, "acct-1"); err != nil {
+		t.Fatalf("DeleteAccount: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	raw, err := os.ReadFile(backup)
+	if err != nil || !encryption.IsEncrypted(raw) || bytes.Contains(raw, []byte("secret-user")) {
+		t.Fatalf("expected the backup sealed, err %v", err)
+	}
+
+	if _, err := Restore(ctx, cfg, zap.NewNop(), backup); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	store, err = NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer store.Close()
+	if acct, err := store.GetAccount(ctx, "acct-1"); err != nil || acct == nil {
+		t.Fatalf("expected the account restored, got %+v, %v", acct, err)
+	}
+}
+
+func TestAccountSummary(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	for _, acct := range []string{"acct-1", "acct-2"} {
+		if err := store.UpsertAccount(ctx, &Account{ID: acct, Email: acct + "@example.com"}); err != nil {
+			t.Fatalf("UpsertAccount: %v", err)
+		}
+	}
+	empty, err := store.AccountSummary(ctx, "acct-1")
+	if err != nil || empty.Files != 0 || empty.Bytes != 0 || empty.LastError != "" || !empty.LastSyncAt.IsZero() {
+		t.Fatalf("expected an empty summary, got %+v, %v", empty, err)
+	}
+
+	for _, file := range []*FileRecord{
+		{ID: "file-1", AccountID: "acct-1", Path: "a.txt", DriveID: "d-1", Size: 100},
+		{ID: "file-2", AccountID: "acct-1", Path: "docs/b.txt", DriveID: "d-2", Size: 23},
+		{ID: "file-3", AccountID: "acct-2", Path: "c.txt", DriveID: "d-3", Size: 1000},
+	} {
+		if err := store.UpsertFile(ctx, file); err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+	}
+	if err := store.UpsertFolder(ctx, &Folder{ID: "f-1", AccountID: "acct-1", Path: "docs", DriveID: "d-4"}); err != nil {
+		t.Fatalf("UpsertFolder: %v", err)
+	}
+	for i, state := range []string{OpQueued, OpQueued, OpInProgress, OpFailed} {
+		op := &PendingOp{ID: fmt.Sprintf("op-%d", i), AccountID: "acct-1", Path: "a.txt", OpType: "upload", State: state}
+		if err := store.AddPendingOp(ctx, op); err != nil {
+			t.Fatalf("AddPendingOp: %v", err)
+		}
+	}
+	if err := store.UpdatePendingOp(ctx, "op-3", OpFailed, 5, "quota exceeded"); err != nil {
+		t.Fatalf("UpdatePendingOp: %v", err)
+	}
+
+	sum, err := store.AccountSummary(ctx, "acct-1")
+	if err != nil {
+		t.Fatalf("AccountSummary: %v", err)
+	}
+	if sum.Files != 2 || sum.Bytes != 123 || sum.Folders != 1 || sum.QueuedOps != 2 || sum.RunningOps != 1 || sum.FailedOps != 1 {
+		t.Fatalf("unexpected summary %+v", sum)
+	}
+	if sum.LastError != "quota exceeded" {
+		t.Fatalf("expected the failed op's error, got %q", sum.LastError)
+	}
+
+	syncedAt := time.Unix(1_700_000_000, 0)
+	if err := store.UpsertSyncState(ctx, &SyncState{AccountID: "acct-1", LastSyncAt: syncedAt, LastError: "token expired"}); err != nil {
+		t.Fatalf("UpsertSyncState: %v", err)
+	}
+	sum, err = store.AccountSummary(ctx, "acct-1")
+	if err != nil || sum.LastError != "token expired" || !sum.LastSyncAt.Equal(syncedAt) {
+		t.Fatalf("expected the sync state preferred, got %+v, %v", sum, err)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	if _, ok, err := store.GetSetting(ctx, "feature.x"); err != nil || ok {
+		t.Fatalf("expected an unset key, got %v, %v", ok, err)
+	}
+	if n, err := store.GetSettingInt(ctx, "feature.x", 7); err != nil || n != 7 {
+		t.Fatalf("expected the default, got %d, %v", n, err)
+	}
+	if err := store.SetSettingBool(ctx, "feature.x", true); err != nil {
+		t.Fatalf("SetSettingBool: %v", err)
+	}
+	if b, err := store.GetSettingBool(ctx, "feature.x", false); err != nil || !b {
+		t.Fatalf("GetSettingBool = %v, %v", b, err)
+	}
+	if _, err := store.GetSettingInt(ctx, "feature.x", 0); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected a non-integer refused, got %v", err)
+	}
+	at := time.Unix(1_700_000_000, 0)
+	if err := store.SetSettingTime(ctx, "sync.last", at); err != nil {
+		t.Fatalf("SetSettingTime: %v", err)
+	}
+	if got, err := store.GetSettingTime(ctx, "sync.last"); err != nil || !got.Equal(at) {
+		t.Fatalf("GetSettingTime = %v, %v", got, err)
+	}
+	if err := store.SetSetting(ctx, "sync.last", "later"); err != nil {
+		t.Fatalf("SetSetting: %v", err)
+	}
+	if v, ok, _ := store.GetSetting(ctx, "sync.last"); !ok || v != "later" {
+		t.Fatalf("expected the value replaced, got %q", v)
+	}
+	if err := store.DeleteSetting(ctx, "sync.last"); err != nil {
+		t.Fatalf("DeleteSetting: %v", err)
+	}
+	if _, ok, _ := store.GetSetting(ctx, "sync.last"); ok {
+		t.Fatal("expected the key deleted")
+	}
+	if err := store.SetSetting(ctx, "", "x"); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected an empty key refused, got %v", err)
+	}
+}
+
+// memoryStore is the kind of fake the Store interface allows: it embeds the
+// interface and implements only what a test exercises.
+type memoryStore struct {
+	Store
+	settings map[string]string
+}
+
+func (m *memoryStore) GetSetting(_ context.Context, key string) (string, bool, error) {
+	val, ok := m.settings[key]
+	return val, ok, nil
+}
+
+func (m *memoryStore) Close() error { return nil }
+
+func TestOpenSelectsBackend(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{DatabasePath: filepath.Join(dir, "googlysync.db")}
+	store, err := Open(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, ok := store.(*Storage); !ok {
+		t.Fatalf("default backend is %T, want *Storage", store)
+	}
+	_ = store.Close()
+
+	RegisterBackend("memory-test", func(*config.Config, *zap.Logger) (Store, error) {
+		return &memoryStore{settings: map[string]string{"k": "v"}}, nil
+	})
+	cfg.StorageBackend = "memory-test"
+	store, err = Open(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Open memory-test: %v", err)
+	}
+	if val, ok, err := store.GetSetting(context.Background(), "k"); err != nil || !ok || val != "v" {
+		t.Fatalf("GetSetting from fake = %q, %v, %v", val, ok, err)
+	}
+
+	cfg.StorageBackend = "nope"
+	if _, err := Open(cfg, zap.NewNop()); errs.KindOf(err) != errs.ErrInvalidArgument || !strings.Contains(err.Error(), "memory-test, sqlite") {
+		t.Fatalf("expected unknown backend error listing backends, got %v", err)
+	}
+
+	// Every exported method of the SQLite backend is part of Store, so
+	// callers never need the concrete type.
+	iface := reflect.TypeOf((*Store)(nil)).Elem()
+	impl := reflect.TypeOf(&Storage{})
+	for i := 0; i < impl.NumMethod(); i++ {
+		if _, ok := iface.MethodByName(impl.Method(i).Name); !ok {
+			t.Errorf("Store lacks %s", impl.Method(i).Name)
+		}
+	}
+}
+
+func TestExportImportIndex(t *testing.T) {
+	src := newTestStorage(t)
+	ctx := context.Background()
+	lastSync := time.Unix(1_700_000_000, 0)
+
+	if err := src.UpsertAccount(ctx, &Account{ID: "acct-1", Email: "user@example.com", DisplayName: "User"}); err != nil {
+		t.Fatalf("UpsertAccount: %v", err)
+	}
+	if err := src.UpsertSyncState(ctx, &SyncState{AccountID: "acct-1", StartPageToken: "tok-9", LastSyncAt: lastSync, LastError: "boom"}); err != nil {
+		t.Fatalf("UpsertSyncState: %v", err)
+	}
+	if err := src.UpsertFolder(ctx, &Folder{ID: "folder-1", AccountID: "acct-1", Path: "docs", DriveID: "df-1"}); err != nil {
+		t.Fatalf("UpsertFolder: %v", err)
+	}
+	file := &FileRecord{ID: "file-1", AccountID: "acct-1", Path: "docs/a.txt", DriveID: "d-1", ParentID: "df-1",
+		Checksum: "abc", Size: 3, Device: 7, Inode: 42, Starred: true}
+	if err := src.UpsertFile(ctx, file); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+	if err := src.UpsertFile(ctx, &FileRecord{ID: "file-2", AccountID: "default", Path: "b.txt", DriveID: "d-2"}); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+
+	var buf bytes.Buffer
+	counts, err := ExportIndex(ctx, src, &buf, "acct-1", time.Now())
+	if err != nil || counts.Accounts != 1 || counts.Folders != 1 || counts.Files != 1 {
+		t.Fatalf("ExportIndex: %#v, %v", counts, err)
+	}
+	if _, err := ExportIndex(ctx, src, io.Discard, "acct-missing", time.Now()); errs.KindOf(err) != errs.ErrNotFound {
+		t.Fatalf("expected not found for unknown account, got %v", err)
+	}
+	dump := buf.Bytes()
+
+	dst := newTestStorage(t)
+	if _, err := ImportIndex(ctx, dst, bytes.NewReader(dump), false); err != nil {
+		t.Fatalf("ImportIndex: %v", err)
+	}
+	got, err := dst.GetFileByPath(ctx, "acct-1", "docs/a.txt")
+	if err != nil || got == nil || got.DriveID != "d-1" || got.Checksum != "abc" || !got.Starred || got.Inode != 0 {
+		t.Fatalf("imported file %#v, %v", got, err)
+	}
+	if folder, err := dst.GetFolderByPath(ctx, "acct-1", "docs"); err != nil || folder == nil || folder.DriveID != "df-1" {
+		t.Fatalf("imported folder %#v, %v", folder, err)
+	}
+	state, err := dst.GetSyncState(ctx, "acct-1")
+	if err != nil || state == nil || state.StartPageToken != "tok-9" || !state.LastSyncAt.Equal(lastSync) || state.LastError != "" {
+		t.Fatalf("imported sync state %#v, %v", state, err)
+	}
+
+	// A second import would merge into an existing index, so it needs
+	// --replace, which drops what the account had.
+	if _, err := ImportIndex(ctx, dst, bytes.NewReader(dump), false); errs.KindOf(err) != errs.ErrConflict {
+		t.Fatalf("expected conflict importing over an index, got %v", err)
+	}
+	if err := dst.UpsertFile(ctx, &FileRecord{ID: "file-stale", AccountID: "acct-1", Path: "stale.txt", DriveID: "d-s"}); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+	if _, err := ImportIndex(ctx, dst, bytes.NewReader(dump), true); err != nil {
+		t.Fatalf("ImportIndex --replace: %v", err)
+	}
+	if n, err := dst.CountFilesByPrefix(ctx, "acct-1", ""); err != nil || n != 1 {
+		t.Fatalf("files after replace = %d, %v", n, err)
+	}
+
+	if _, err := ImportIndex(ctx, dst, strings.NewReader(`{"version": 99}`), true); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected unsupported version, got %v", err)
+	}
+}
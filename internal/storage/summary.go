@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// AccountSummary totals an account's index and queue.
+type AccountSummary struct {
+	AccountID string
+	Files     int
+	Folders   int
+	// Bytes is the size of every indexed file, counting a file once per
+	// local path it is projected to.
+	Bytes      int64
+	QueuedOps  int
+	RunningOps int
+	FailedOps  int
+	LastSyncAt time.Time
+	// LastError is the sync state's error or, when it has none, the error
+	// of the most recently failed op.
+	LastError string
+}
+
+// AccountSummary computes an account's totals in one query, so status
+// surfaces do not have to load the index to count it.
+func (s *Storage) AccountSummary(ctx context.Context, accountID string) (*AccountSummary, error) {
+	sum := &AccountSummary{AccountID: accountID}
+	var lastSyncAt int64
+	var stateError, opError string
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(1) FROM files WHERE account_id = ?1),
+			(SELECT COALESCE(SUM(size), 0) FROM files WHERE account_id = ?1),
+			(SELECT COUNT(1) FROM folders WHERE account_id = ?1),
+			(SELECT COUNT(1) FROM pending_ops WHERE account_id = ?1 AND state = ?2),
+			(SELECT COUNT(1) FROM pending_ops WHERE account_id = ?1 AND state = ?3),
+			(SELECT COUNT(1) FROM pending_ops WHERE account_id = ?1 AND state = ?4),
+			COALESCE((SELECT last_sync_at FROM sync_state WHERE account_id = ?1), 0),
+			COALESCE((SELECT last_error FROM sync_state WHERE account_id = ?1), ''),
+			COALESCE((SELECT last_error FROM pending_ops WHERE account_id = ?1 AND state = ?4
+				ORDER BY updated_at DESC LIMIT 1), '')
+	`, accountID, OpQueued, OpInProgress, OpFailed).Scan(&sum.Files, &sum.Bytes, &sum.Folders,
+		&sum.QueuedOps, &sum.RunningOps, &sum.FailedOps, &lastSyncAt, &stateError, &opError)
+	if err != nil {
+		return nil, err
+	}
+	sum.LastSyncAt = fromUnix(lastSyncAt)
+	sum.LastError = stateError
+	if sum.LastError == "" {
+		sum.LastError = opError
+	}
+	return sum, nil
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Symlink records a link inside the sync root kept as a Drive shortcut.
+type Symlink struct {
+	AccountID string
+	Path      string
+	Target    string
+	// DriveID is the shortcut the link was uploaded as, once it has been.
+	DriveID   string
+	UpdatedAt time.Time
+}
+
+// UpsertSymlink records or updates a link target.
+func (s *Storage) UpsertSymlink(ctx context.Context, link *Symlink) error {
+	if link == nil {
+		return nil
+	}
+	if link.AccountID == "" {
+		return errs.New(errs.ErrInvalidArgument, "symlink account_id cannot be empty")
+	}
+	if link.Path == "" {
+		return errs.New(errs.ErrInvalidArgument, "symlink path cannot be empty")
+	}
+	if link.Target == "" {
+		return errs.New(errs.ErrInvalidArgument, "symlink target cannot be empty")
+	}
+	if link.UpdatedAt.IsZero() {
+		link.UpdatedAt = time.Now()
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO symlinks (account_id, path, target, drive_id, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(account_id, path) DO UPDATE SET
+			target = excluded.target,
+			drive_id = excluded.drive_id,
+			updated_at = excluded.updated_at
+	`, link.AccountID, link.Path, link.Target, link.DriveID, unixTime(link.UpdatedAt))
+	return err
+}
+
+// GetSymlink returns the recorded link at path.
+func (s *Storage) GetSymlink(ctx context.Context, accountID, path string) (*Symlink, error) {
+	row := s.DB.QueryRowContext(ctx, `
+		SELECT account_id, path, target, drive_id, updated_at
+		FROM symlinks WHERE account_id = ? AND path = ?
+	`, accountID, path)
+	var link Symlink
+	var updatedAt int64
+	if err := row.Scan(&link.AccountID, &link.Path, &link.Target, &link.DriveID, &updatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	link.UpdatedAt = fromUnix(updatedAt)
+	return &link, nil
+}
+
+// ListSymlinks returns all recorded links for an account ordered by path.
+func (s *Storage) ListSymlinks(ctx context.Context, accountID string) ([]Symlink, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT account_id, path, target, drive_id, updated_at
+		FROM symlinks WHERE account_id = ?
+		ORDER BY path ASC
+	`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Symlink
+	for rows.Next() {
+		var link Symlink
+		var updatedAt int64
+		if err := rows.Scan(&link.AccountID, &link.Path, &link.Target, &link.DriveID, &updatedAt); err != nil {
+			return nil, err
+		}
+		link.UpdatedAt = fromUnix(updatedAt)
+		out = append(out, link)
+	}
+	return out, rows.Err()
+}
+
+// DeleteSymlink removes a recorded link.
+func (s *Storage) DeleteSymlink(ctx context.Context, accountID, path string) error {
+	_, err := s.DB.ExecContext(ctx, `
+		DELETE FROM symlinks WHERE account_id = ? AND path = ?
+	`, accountID, path)
+	return err
+}
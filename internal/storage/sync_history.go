@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Outcomes recorded in the sync history.
+const (
+	HistorySucceeded = "succeeded"
+	HistoryFailed    = "failed"
+	HistoryDetected  = "detected"
+)
+
+// HistoryEntry is one finished sync operation in the audit trail.
+type HistoryEntry struct {
+	ID        int64
+	AccountID string
+	Op        string
+	Path      string
+	DriveID   string
+	// Detail is a related path: the source of a move or a conflict copy.
+	Detail     string
+	Bytes      int64
+	Outcome    string
+	Error      string
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// HistoryFilter narrows ListHistory. Zero fields match everything.
+type HistoryFilter struct {
+	AccountID string
+	Op        string
+	Outcome   string
+	// Path matches the path itself and everything below it.
+	Path  string
+	Since time.Time
+	Until time.Time
+	// BeforeID continues a listing from the last entry of the previous page.
+	BeforeID int64
+	Limit    int
+}
+
+// AddHistory appends an entry to the sync history.
+func (s *Storage) AddHistory(ctx context.Context, entry *HistoryEntry) error {
+	if entry == nil {
+		return nil
+	}
+	if entry.AccountID == "" {
+		return errs.New(errs.ErrInvalidArgument, "history account_id cannot be empty")
+	}
+	if entry.Op == "" || entry.Outcome == "" {
+		return errs.New(errs.ErrInvalidArgument, "history op and outcome are required")
+	}
+	if entry.FinishedAt.IsZero() {
+		entry.FinishedAt = time.Now()
+	}
+	if entry.StartedAt.IsZero() {
+		entry.StartedAt = entry.FinishedAt
+	}
+	res, err := s.DB.ExecContext(ctx, `
+		INSERT INTO sync_history (account_id, op, path, drive_id, detail, bytes, outcome, error, started_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.AccountID, entry.Op, entry.Path, entry.DriveID, entry.Detail, entry.Bytes, entry.Outcome, entry.Error,
+		unixTime(entry.StartedAt), unixTime(entry.FinishedAt))
+	if err != nil {
+		return err
+	}
+	entry.ID, err = res.LastInsertId()
+	return err
+}
+
+// ListHistory returns the entries matching filter, newest first. Pass the ID
+// of the last entry returned as BeforeID to fetch the next page.
+func (s *Storage) ListHistory(ctx context.Context, filter HistoryFilter) ([]HistoryEntry, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	var where []string
+	var args []any
+	if filter.AccountID != "" {
+		where = append(where, "account_id = ?")
+		args = append(args, filter.AccountID)
+	}
+	if filter.Op != "" {
+		where = append(where, "op = ?")
+		args = append(args, filter.Op)
+	}
+	if filter.Outcome != "" {
+		where = append(where, "outcome = ?")
+		args = append(args, filter.Outcome)
+	}
+	if filter.Path != "" {
+		cond, condArgs := subtreeRange("path", filter.Path)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "finished_at >= ?")
+		args = append(args, unixTime(filter.Since))
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "finished_at <= ?")
+		args = append(args, unixTime(filter.Until))
+	}
+	if filter.BeforeID > 0 {
+		where = append(where, "id < ?")
+		args = append(args, filter.BeforeID)
+	}
+	query := `
+		SELECT id, account_id, op, path, drive_id, detail, bytes, outcome, error, started_at, finished_at
+		FROM sync_history`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []HistoryEntry
+	for rows.Next() {
+		var entry HistoryEntry
+		var startedAt, finishedAt int64
+		if err := rows.Scan(&entry.ID, &entry.AccountID, &entry.Op, &entry.Path, &entry.DriveID, &entry.Detail,
+			&entry.Bytes, &entry.Outcome, &entry.Error, &startedAt, &finishedAt); err != nil {
+			return nil, err
+		}
+		entry.StartedAt = fromUnix(startedAt)
+		entry.FinishedAt = fromUnix(finishedAt)
+		out = append(out, entry)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Transfer directions for resumable sessions.
+const (
+	TransferUpload   = "upload"
+	TransferDownload = "download"
+)
+
+// TransferSession is the resumable state of one large transfer, kept so an
+// interrupted transfer can continue instead of starting over.
+type TransferSession struct {
+	ID        string
+	AccountID string
+	Direction string
+	Path      string
+	DriveID   string
+	// SessionURI is the Drive resumable upload session for uploads.
+	SessionURI string
+	Size       int64
+	// Checksum is the SHA-256 of the complete content: the local file an
+	// upload was started from, or the remote file being downloaded.
+	Checksum string
+	// Offset is the number of bytes Drive acknowledged for an upload.
+	Offset int64
+	// ChunkSize and Chunks describe the ranges of a download already written
+	// to the local file.
+	ChunkSize int64
+	Chunks    []TransferChunk
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TransferChunk is a completed download range and the SHA-256 of its bytes.
+type TransferChunk struct {
+	Index    int
+	Checksum string
+}
+
+// SaveTransferSession inserts or replaces a session and its chunk list.
+func (s *Storage) SaveTransferSession(ctx context.Context, session *TransferSession) error {
+	if session == nil {
+		return nil
+	}
+	if session.ID == "" || session.AccountID == "" || session.Path == "" {
+		return errs.New(errs.ErrInvalidArgument, "transfer session id, account_id and path cannot be empty")
+	}
+	if session.Direction != TransferUpload && session.Direction != TransferDownload {
+		return errs.New(errs.ErrInvalidArgument, "unknown transfer direction %q", session.Direction)
+	}
+	now := time.Now()
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = now
+	}
+	session.UpdatedAt = now
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM transfer_chunks WHERE session_id IN (
+			SELECT id FROM transfer_sessions WHERE id = ? OR (account_id = ? AND direction = ? AND path = ?)
+		)
+	`, session.ID, session.AccountID, session.Direction, session.Path); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM transfer_sessions WHERE id = ? OR (account_id = ? AND direction = ? AND path = ?)
+	`, session.ID, session.AccountID, session.Direction, session.Path); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO transfer_sessions (id, account_id, direction, path, drive_id, session_uri, size, checksum, offset, chunk_size, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, session.ID, session.AccountID, session.Direction, session.Path, session.DriveID, session.SessionURI,
+		session.Size, session.Checksum, session.Offset, session.ChunkSize, unixTime(session.CreatedAt), unixTime(session.UpdatedAt)); err != nil {
+		return err
+	}
+	for _, c := range session.Chunks {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO transfer_chunks (session_id, chunk_index, checksum)
+			VALUES (?, ?, ?)
+		`, session.ID, c.Index, c.Checksum); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListTransferSessions returns resumable sessions ordered by path. An empty
+// accountID lists every account.
+func (s *Storage) ListTransferSessions(ctx context.Context, accountID string) ([]TransferSession, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, account_id, direction, path, drive_id, session_uri, size, checksum, offset, chunk_size, created_at, updated_at
+		FROM transfer_sessions
+		WHERE ? = '' OR account_id = ?
+		ORDER BY account_id ASC, path ASC, direction ASC
+	`, accountID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	var out []TransferSession
+	for rows.Next() {
+		var t TransferSession
+		var createdAt, updatedAt int64
+		if err := rows.Scan(&t.ID, &t.AccountID, &t.Direction, &t.Path, &t.DriveID, &t.SessionURI,
+			&t.Size, &t.Checksum, &t.Offset, &t.ChunkSize, &createdAt, &updatedAt); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		t.CreatedAt = fromUnix(createdAt)
+		t.UpdatedAt = fromUnix(updatedAt)
+		out = append(out, t)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range out {
+		chunks, err := s.listTransferChunks(ctx, out[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		out[i].Chunks = chunks
+	}
+	return out, nil
+}
+
+func (s *Storage) listTransferChunks(ctx context.Context, sessionID string) ([]TransferChunk, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT chunk_index, checksum FROM transfer_chunks
+		WHERE session_id = ?
+		ORDER BY chunk_index ASC
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []TransferChunk
+	for rows.Next() {
+		var c TransferChunk
+		if err := rows.Scan(&c.Index, &c.Checksum); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// DeleteTransferSession removes a session once its transfer has finished or
+// can no longer be resumed.
+func (s *Storage) DeleteTransferSession(ctx context.Context, id string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM transfer_chunks WHERE session_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM transfer_sessions WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
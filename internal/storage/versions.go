@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// FileVersion records a stashed copy of a local file's previous content.
+type FileVersion struct {
+	ID        int64
+	Path      string
+	Checksum  string
+	Size      int64
+	StashedAt time.Time
+}
+
+// AddFileVersion records a stashed version.
+func (s *Storage) AddFileVersion(ctx context.Context, v *FileVersion) error {
+	if v == nil {
+		return nil
+	}
+	if v.Path == "" {
+		return errs.New(errs.ErrInvalidArgument, "file_version path cannot be empty")
+	}
+	if v.Checksum == "" {
+		return errs.New(errs.ErrInvalidArgument, "file_version checksum cannot be empty")
+	}
+	if v.StashedAt.IsZero() {
+		v.StashedAt = time.Now()
+	}
+	res, err := s.DB.ExecContext(ctx, `
+		INSERT INTO file_versions (path, checksum, size, stashed_at)
+		VALUES (?, ?, ?, ?)
+	`, v.Path, v.Checksum, v.Size, unixTime(v.StashedAt))
+	if err != nil {
+		return err
+	}
+	v.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetFileVersion returns a stashed version by ID.
+func (s *Storage) GetFileVersion(ctx context.Context, id int64) (*FileVersion, error) {
+	row := s.DB.QueryRowContext(ctx, `
+		SELECT id, path, checksum, size, stashed_at
+		FROM file_versions WHERE id = ?
+	`, id)
+	var v FileVersion
+	var stashedAt int64
+	if err := row.Scan(&v.ID, &v.Path, &v.Checksum, &v.Size, &stashedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	v.StashedAt = fromUnix(stashedAt)
+	return &v, nil
+}
+
+// ListFileVersions returns versions newest first. An empty path lists all.
+func (s *Storage) ListFileVersions(ctx context.Context, path string) ([]FileVersion, error) {
+	query := `
+		SELECT id, path, checksum, size, stashed_at
+		FROM file_versions
+	`
+	var args []any
+	if path != "" {
+		query += " WHERE path = ?"
+		args = append(args, path)
+	}
+	query += " ORDER BY path ASC, stashed_at DESC, id DESC"
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []FileVersion
+	for rows.Next() {
+		var v FileVersion
+		var stashedAt int64
+		if err := rows.Scan(&v.ID, &v.Path, &v.Checksum, &v.Size, &stashedAt); err != nil {
+			return nil, err
+		}
+		v.StashedAt = fromUnix(stashedAt)
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// DeleteFileVersion removes a version record.
+func (s *Storage) DeleteFileVersion(ctx context.Context, id int64) error {
+	_, err := s.DB.ExecContext(ctx, `
+		DELETE FROM file_versions WHERE id = ?
+	`, id)
+	return err
+}
+
+// CountFileVersionsByChecksum reports how many versions share stashed content.
+func (s *Storage) CountFileVersionsByChecksum(ctx context.Context, checksum string) (int, error) {
+	var count int
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT COUNT(1) FROM file_versions WHERE checksum = ?
+	`, checksum).Scan(&count)
+	return count, err
+}
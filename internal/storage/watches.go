@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// FileWatch registers interest in remote changes to a single file.
+type FileWatch struct {
+	AccountID string
+	Path      string
+	DriveID   string
+	// LastVersion is the Drive version seen by the last check; zero until
+	// the first check records a baseline.
+	LastVersion int64
+	CheckedAt   time.Time
+	CreatedAt   time.Time
+}
+
+// AddFileWatch registers a watch, resetting its baseline if it exists.
+func (s *Storage) AddFileWatch(ctx context.Context, watch *FileWatch) error {
+	if watch == nil {
+		return nil
+	}
+	if watch.AccountID == "" {
+		return errs.New(errs.ErrInvalidArgument, "file_watch account_id cannot be empty")
+	}
+	if watch.Path == "" {
+		return errs.New(errs.ErrInvalidArgument, "file_watch path cannot be empty")
+	}
+	if watch.DriveID == "" {
+		return errs.New(errs.ErrInvalidArgument, "file_watch drive_id cannot be empty")
+	}
+	if watch.CreatedAt.IsZero() {
+		watch.CreatedAt = time.Now()
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO file_watches (account_id, path, drive_id, last_version, checked_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account_id, path) DO UPDATE SET
+			drive_id = excluded.drive_id,
+			last_version = excluded.last_version,
+			checked_at = excluded.checked_at
+	`, watch.AccountID, watch.Path, watch.DriveID, watch.LastVersion, unixTime(watch.CheckedAt), unixTime(watch.CreatedAt))
+	return err
+}
+
+// ListFileWatches returns watches for all accounts ordered by account and path.
+func (s *Storage) ListFileWatches(ctx context.Context) ([]FileWatch, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT account_id, path, drive_id, last_version, checked_at, created_at
+		FROM file_watches
+		ORDER BY account_id ASC, path ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []FileWatch
+	for rows.Next() {
+		var w FileWatch
+		var checkedAt, createdAt int64
+		if err := rows.Scan(&w.AccountID, &w.Path, &w.DriveID, &w.LastVersion, &checkedAt, &createdAt); err != nil {
+			return nil, err
+		}
+		w.CheckedAt = fromUnix(checkedAt)
+		w.CreatedAt = fromUnix(createdAt)
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
+
+// UpdateFileWatchVersion records the version seen by a check.
+func (s *Storage) UpdateFileWatchVersion(ctx context.Context, accountID, path string, version int64, checkedAt time.Time) error {
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE file_watches SET last_version = ?, checked_at = ?
+		WHERE account_id = ? AND path = ?
+	`, version, unixTime(checkedAt), accountID, path)
+	return err
+}
+
+// DeleteFileWatch removes a watch. It reports whether one existed.
+func (s *Storage) DeleteFileWatch(ctx context.Context, accountID, path string) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		DELETE FROM file_watches WHERE account_id = ? AND path = ?
+	`, accountID, path)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
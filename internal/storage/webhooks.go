@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// Webhook is an outbound HTTP endpoint notified of an account's sync
+// milestones. Deliveries are signed with Secret.
+type Webhook struct {
+	ID        string
+	AccountID string
+	URL       string
+	Secret    string
+	// Events limits deliveries to these event names; empty means all.
+	Events    []string
+	CreatedAt time.Time
+}
+
+// Wants reports whether the webhook subscribes to event.
+func (w Webhook) Wants(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// AddWebhook registers a webhook.
+func (s *Storage) AddWebhook(ctx context.Context, hook *Webhook) error {
+	if hook == nil {
+		return nil
+	}
+	if hook.ID == "" || hook.AccountID == "" || hook.URL == "" || hook.Secret == "" {
+		return errs.New(errs.ErrInvalidArgument, "webhook id, account_id, url and secret cannot be empty")
+	}
+	if hook.CreatedAt.IsZero() {
+		hook.CreatedAt = time.Now()
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO webhooks (id, account_id, url, secret, events, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, hook.ID, hook.AccountID, hook.URL, hook.Secret, strings.Join(hook.Events, ","), unixTime(hook.CreatedAt))
+	return err
+}
+
+// ListWebhooks returns the webhooks of an account, oldest first. An empty
+// accountID lists every account's.
+func (s *Storage) ListWebhooks(ctx context.Context, accountID string) ([]Webhook, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, account_id, url, secret, events, created_at FROM webhooks
+		WHERE ? = '' OR account_id = ?
+		ORDER BY created_at ASC, id ASC
+	`, accountID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Webhook
+	for rows.Next() {
+		var hook Webhook
+		var events string
+		var createdAt int64
+		if err := rows.Scan(&hook.ID, &hook.AccountID, &hook.URL, &hook.Secret, &events, &createdAt); err != nil {
+			return nil, err
+		}
+		if events != "" {
+			hook.Events = strings.Split(events, ",")
+		}
+		hook.CreatedAt = fromUnix(createdAt)
+		out = append(out, hook)
+	}
+	return out, rows.Err()
+}
+
+// DeleteWebhook removes a webhook.
+func (s *Storage) DeleteWebhook(ctx context.Context, id string) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errs.New(errs.ErrNotFound, "webhook %s not found", id)
+	}
+	return nil
+}
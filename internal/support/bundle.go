@@ -0,0 +1,224 @@
+package support
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// Options controls what goes into a support bundle.
+type Options struct {
+	Version     string
+	HashPaths   bool
+	MaxLogFiles int
+	MaxFailures int
+}
+
+type versionInfo struct {
+	Version     string `json:"version"`
+	GoVersion   string `json:"go_version"`
+	OS          string `json:"os"`
+	Arch        string `json:"arch"`
+	GeneratedAt string `json:"generated_at"`
+}
+
+type failureEntry struct {
+	AccountID  string `json:"account_id"`
+	Path       string `json:"path"`
+	Op         string `json:"op"`
+	Message    string `json:"message"`
+	OccurredAt string `json:"occurred_at"`
+}
+
+// WriteBundle writes a gzipped tarball with logs, redacted config, database
+// statistics, version info, and recent failures. A nil store is tolerated so
+// a bundle can still be produced when the database will not open.
+func WriteBundle(ctx context.Context, w io.Writer, cfg *config.Config, store storage.Store, opts Options) error {
+	if opts.MaxLogFiles <= 0 {
+		opts.MaxLogFiles = 3
+	}
+	if opts.MaxFailures <= 0 {
+		opts.MaxFailures = 50
+	}
+	redactor := NewRedactor(opts.HashPaths)
+	now := time.Now()
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	b := &bundle{tw: tw, now: now}
+
+	b.addJSON("version.json", versionInfo{
+		Version:     opts.Version,
+		GoVersion:   runtime.Version(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		GeneratedAt: now.UTC().Format(time.RFC3339),
+	})
+	b.addJSON("config.json", redactConfig(cfg, redactor))
+
+	if store == nil {
+		b.add("db_stats.txt", []byte("database unavailable\n"))
+	} else {
+		stats, err := store.Stats(ctx)
+		if err != nil {
+			b.add("db_stats.txt", []byte(redactor.Text(err.Error())+"\n"))
+		} else {
+			b.addJSON("db_stats.json", stats)
+		}
+		b.addFailures(ctx, store, redactor, opts.MaxFailures)
+	}
+
+	for _, path := range recentLogFiles(cfg.LogFilePath, opts.MaxLogFiles) {
+		data, err := readLog(path)
+		if err != nil {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".gz")
+		b.add("logs/"+name, redactLog(data, redactor))
+	}
+
+	if b.err != nil {
+		return b.err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+type bundle struct {
+	tw  *tar.Writer
+	now time.Time
+	err error
+}
+
+func (b *bundle) add(name string, data []byte) {
+	if b.err != nil {
+		return
+	}
+	hdr := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: b.now}
+	if err := b.tw.WriteHeader(hdr); err != nil {
+		b.err = err
+		return
+	}
+	_, b.err = b.tw.Write(data)
+}
+
+func (b *bundle) addJSON(name string, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		b.err = err
+		return
+	}
+	b.add(name, append(data, '\n'))
+}
+
+func (b *bundle) addFailures(ctx context.Context, store storage.Store, redactor *Redactor, limit int) {
+	recs, err := store.ListRecentErrors(ctx, limit)
+	if err != nil {
+		b.add("failures.txt", []byte(redactor.Text(err.Error())+"\n"))
+		return
+	}
+	out := make([]failureEntry, 0, len(recs))
+	for _, rec := range recs {
+		out = append(out, failureEntry{
+			AccountID:  rec.AccountID,
+			Path:       redactor.Path(rec.Path),
+			Op:         rec.Op,
+			Message:    redactor.Text(rec.Message),
+			OccurredAt: rec.OccurredAt.UTC().Format(time.RFC3339),
+		})
+	}
+	b.addJSON("failures.json", out)
+}
+
+func redactConfig(cfg *config.Config, redactor *Redactor) map[string]any {
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	return redactor.Config(fields)
+}
+
+// recentLogFiles returns the active log plus rotated backups, newest first.
+func recentLogFiles(logPath string, limit int) []string {
+	if logPath == "" {
+		return nil
+	}
+	dir := filepath.Dir(logPath)
+	base := strings.TrimSuffix(filepath.Base(logPath), filepath.Ext(logPath))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	type candidate struct {
+		path    string
+		modTime time.Time
+	}
+	var found []candidate
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), base) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		found = append(found, candidate{path: filepath.Join(dir, entry.Name()), modTime: info.ModTime()})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].modTime.After(found[j].modTime) })
+
+	var out []string
+	for i := 0; i < len(found) && i < limit; i++ {
+		out = append(out, found[i].path)
+	}
+	return out
+}
+
+func readLog(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return io.ReadAll(r)
+}
+
+func redactLog(data []byte, redactor *Redactor) []byte {
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		out.Write(redactor.LogLine(scanner.Bytes()))
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,122 @@
+package support
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// pathKeys are structured log fields that carry filesystem paths.
+var pathKeys = map[string]bool{
+	"path":   true,
+	"from":   true,
+	"to":     true,
+	"socket": true,
+	"root":   true,
+}
+
+// Redactor strips PII from bundle contents.
+type Redactor struct {
+	home      string
+	hashPaths bool
+}
+
+// NewRedactor constructs a redactor. When hashPaths is set, paths are
+// replaced by a stable digest so related entries can still be correlated.
+func NewRedactor(hashPaths bool) *Redactor {
+	home, _ := os.UserHomeDir()
+	return &Redactor{home: home, hashPaths: hashPaths}
+}
+
+// Text masks email addresses and the home directory in free-form text.
+func (r *Redactor) Text(s string) string {
+	s = emailPattern.ReplaceAllString(s, "<email>")
+	if r.home != "" && r.home != "/" {
+		s = strings.ReplaceAll(s, r.home, "~")
+	}
+	return s
+}
+
+// Path redacts a filesystem path.
+func (r *Redactor) Path(p string) string {
+	if p == "" {
+		return p
+	}
+	if r.hashPaths {
+		sum := sha256.Sum256([]byte(p))
+		return "path:" + hex.EncodeToString(sum[:6])
+	}
+	return r.Text(p)
+}
+
+// LogLine redacts one JSON log line; non-JSON lines are treated as text.
+func (r *Redactor) LogLine(line []byte) []byte {
+	var entry map[string]any
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return []byte(r.Text(string(line)))
+	}
+	for key, val := range entry {
+		str, ok := val.(string)
+		if !ok {
+			continue
+		}
+		if pathKeys[key] {
+			entry[key] = r.Path(str)
+		} else {
+			entry[key] = r.Text(str)
+		}
+	}
+	out, err := json.Marshal(entry)
+	if err != nil {
+		return []byte(r.Text(string(line)))
+	}
+	return out
+}
+
+// Config redacts a flattened config: secrets are dropped and path-valued
+// fields are passed through Path.
+func (r *Redactor) Config(fields map[string]any) map[string]any {
+	out := make(map[string]any, len(fields))
+	for key, val := range fields {
+		str, ok := val.(string)
+		switch {
+		case isSecretKey(key):
+			if ok && str != "" {
+				out[key] = "<redacted>"
+			} else {
+				out[key] = val
+			}
+		case ok && isPathKey(key):
+			out[key] = r.Path(str)
+		case ok:
+			out[key] = r.Text(str)
+		default:
+			out[key] = val
+		}
+	}
+	return out
+}
+
+func isSecretKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, marker := range []string{"secret", "token", "password", "clientid", "key"} {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPathKey(key string) bool {
+	for _, suffix := range []string{"Path", "Dir", "Root", "File"} {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package support
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRedactorMasksEmailsAndHashesPaths(t *testing.T) {
+	r := &Redactor{home: "/home/alex", hashPaths: true}
+
+	line := []byte(`{"msg":"signed in user@example.com","path":"/home/alex/Drive/secret.txt"}`)
+	var entry map[string]string
+	if err := json.Unmarshal(r.LogLine(line), &entry); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if strings.Contains(entry["msg"], "user@example.com") {
+		t.Fatalf("expected email masked, got %q", entry["msg"])
+	}
+	if !strings.HasPrefix(entry["path"], "path:") || strings.Contains(entry["path"], "secret") {
+		t.Fatalf("expected hashed path, got %q", entry["path"])
+	}
+	if r.Path("/a") != r.Path("/a") {
+		t.Fatal("expected stable path hashes")
+	}
+}
+
+func TestRedactorConfig(t *testing.T) {
+	r := &Redactor{home: "/home/alex"}
+	out := r.Config(map[string]any{
+		"OAuthClientSecret": "shh",
+		"OAuthClientID":     "client",
+		"SyncRoot":          "/home/alex/Drive",
+		"EventLogSize":      20.0,
+	})
+	if out["OAuthClientSecret"] != "<redacted>" || out["OAuthClientID"] != "<redacted>" {
+		t.Fatalf("expected secrets redacted, got %#v", out)
+	}
+	if out["SyncRoot"] != "~/Drive" {
+		t.Fatalf("expected home collapsed, got %#v", out["SyncRoot"])
+	}
+	if out["EventLogSize"] != 20.0 {
+		t.Fatalf("expected non-string values untouched, got %#v", out["EventLogSize"])
+	}
+}
@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// DriveAccess is how much of the user's Drive the account was asked for.
+type DriveAccess string
+
+const (
+	// DriveAccessFull sees the whole Drive.
+	DriveAccessFull DriveAccess = "full"
+	// DriveAccessApp sees only files googlysync created or was handed, under
+	// the drive.file scope. Everything else on Drive is invisible: it is not
+	// listed, not reported by the changes feed, and a file that drops out of
+	// view looks the same as one deleted for good.
+	DriveAccessApp DriveAccess = "app"
+)
+
+// ParseDriveAccess validates a configured access level. Empty means full.
+func ParseDriveAccess(val string) (DriveAccess, error) {
+	switch a := DriveAccess(val); a {
+	case "":
+		return DriveAccessFull, nil
+	case DriveAccessFull, DriveAccessApp:
+		return a, nil
+	default:
+		return "", errs.New(errs.ErrInvalidArgument, "unknown drive_access %q", val)
+	}
+}
+
+// lostFromView reports whether a removal may only mean the file left what the
+// account can see. With app access Drive reports a file it stops showing the
+// same way as a deleted one; a trashed file is still visible and is a real
+// removal.
+func (e *Engine) lostFromView(change RemoteChange) bool {
+	return e.access == DriveAccessApp && change.Removed && !change.Trashed
+}
+
+// untrackHidden forgets a file that dropped out of view and keeps its local
+// copy. The next local edit uploads it as a new file the account can see.
+func (e *Engine) untrackHidden(ctx context.Context, rec *storage.FileRecord) error {
+	if err := e.Store.DeletePathAlias(ctx, e.accountID, rec.DriveID); err != nil {
+		return err
+	}
+	if err := e.Store.DeleteFile(ctx, e.accountID, rec.Path); err != nil {
+		return err
+	}
+	e.Logger.Info("file no longer visible on Drive; keeping the local copy", zap.String("path", rec.Path), zap.String("drive_id", rec.DriveID))
+	if e.Status != nil {
+		e.Status.AddEvent(status.Event{Op: "UNTRACKED", Path: rec.Path})
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+func TestAppAccessKeepsFilesThatLeaveView(t *testing.T) {
+	e := newTestEngine(t)
+	e.access = DriveAccessApp
+	ctx := context.Background()
+	trackFile(t, e, "a.txt", "drive-a")
+	trackFile(t, e, "b.txt", "drive-b")
+
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "drive-a", Removed: true}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 0 {
+		t.Fatalf("expected no local delete for a file that left view, got %v", got)
+	}
+	if _, err := os.Stat(e.absPath("a.txt")); err != nil {
+		t.Fatalf("expected local copy kept: %v", err)
+	}
+	if rec, err := e.Store.GetFileByDriveID(ctx, e.accountID, "drive-a"); err != nil || rec != nil {
+		t.Fatalf("expected record dropped, got %#v, %v", rec, err)
+	}
+
+	// A trashed file is still visible, so the removal is real.
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "drive-b", Removed: true, Trashed: true}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 1 || got[0] != "delete_local b.txt" {
+		t.Fatalf("expected local delete for a trashed file, got %v", got)
+	}
+}
+
+func TestAppAccessRejectsSharedWithMe(t *testing.T) {
+	e := newTestEngine(t)
+	cfg := *e.Config
+	cfg.DriveAccess = string(DriveAccessApp)
+	cfg.SharedWithMe = string(SharedFolder)
+	if _, err := NewEngine(zap.NewNop(), &cfg, e.Store, nil, nil, nil); !errors.Is(err, errs.ErrInvalidArgument) {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+	cfg.DriveAccess = "everything"
+	cfg.SharedWithMe = ""
+	if _, err := NewEngine(zap.NewNop(), &cfg, e.Store, nil, nil, nil); !errors.Is(err, errs.ErrInvalidArgument) {
+		t.Fatalf("unknown drive_access: expected invalid argument, got %v", err)
+	}
+}
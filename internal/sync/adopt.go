@@ -0,0 +1,307 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// TreeLister lists the items directly inside a Drive folder.
+type TreeLister interface {
+	ListChildren(ctx context.Context, folderID string) ([]driveapi.File, error)
+}
+
+// AdoptReport summarizes what Adopt matched and planned.
+type AdoptReport struct {
+	// Adopted files matched Drive by path, size and checksum and were added
+	// to the index without any transfer.
+	Adopted   int
+	Uploads   int
+	Downloads int
+	// Skipped lists "path: reason" for entries that could not be matched.
+	Skipped []string
+}
+
+// Adopt indexes an existing local copy of Drive, such as an unpacked
+// "Download all" export moved into the sync root. dir must be the sync root
+// or a folder inside it and is matched against the same path under the Drive
+// folder rootID. Identical files are adopted as synced; only differences are
+// queued for upload or download.
+func (e *Engine) Adopt(ctx context.Context, lister TreeLister, rootID, dir string) (*AdoptReport, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	scope := e.relPath(abs)
+	if scope == ".." || strings.HasPrefix(scope, "../") || filepath.IsAbs(filepath.FromSlash(scope)) {
+		return nil, errs.New(errs.ErrInvalidArgument, "%s is outside the sync root %s", dir, e.Config.SyncRoot)
+	}
+	if scope == "." {
+		scope = ""
+	}
+
+	report := &AdoptReport{}
+	remote := make(map[string]driveapi.File)
+	if err := e.listRemoteTree(ctx, lister, rootID, "", scope, remote, report); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	err = filepath.WalkDir(abs, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == abs {
+			return nil
+		}
+		rel := e.relPath(p)
+		if e.ignored(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		seen[rel] = true
+		if d.IsDir() {
+			return e.adoptFolder(ctx, rel, remote)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return e.adoptFile(ctx, rel, remote, report)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for rel, f := range remote {
+		if !seen[rel] && f.MimeType != driveapi.FolderMimeType {
+			missing = append(missing, rel)
+		}
+	}
+	sort.Strings(missing)
+	for _, rel := range missing {
+		f := remote[rel]
+		if queued, err := e.Store.HasPendingOps(ctx, e.accountID, rel); err != nil || queued {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if unsyncable(f.MimeType) {
+			if err := e.applyUnsyncable(ctx, remoteChangeOf(f, rel)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if driveapi.IsGoogleNative(f.MimeType) {
+			report.Skipped = append(report.Skipped, rel+": native Google file has no downloadable content")
+			continue
+		}
+		if err := e.queueDownload(ctx, remoteChangeOf(f, rel), rel, nil); err != nil {
+			return nil, err
+		}
+		report.Downloads++
+	}
+	e.Logger.Info("adopt finished", zap.String("dir", abs), zap.Int("adopted", report.Adopted), zap.Int("uploads", report.Uploads), zap.Int("downloads", report.Downloads), zap.Int("skipped", len(report.Skipped)))
+	return report, nil
+}
+
+// listRemoteTree walks Drive from folderID, keeping entries at or under
+// scope. Folders outside scope are only descended when they lead to it.
+// When siblings share a name, the one with the lowest Drive id keeps it and
+// the others are placed under an alias path, as localPathFor does for
+// changes.
+func (e *Engine) listRemoteTree(ctx context.Context, lister TreeLister, folderID, prefix, scope string, out map[string]driveapi.File, report *AdoptReport) error {
+	children, err := lister.ListChildren(ctx, folderID)
+	if err != nil {
+		return err
+	}
+	// Names are compared as encoded, since that is what must be unique on
+	// disk.
+	owner := make(map[string]string)
+	for _, f := range children {
+		if f.MimeType == driveapi.ShortcutMimeType {
+			continue
+		}
+		name := EncodeName(f.Name, e.names)
+		if id, ok := owner[name]; !ok || f.ID < id {
+			owner[name] = f.ID
+		}
+	}
+	for _, f := range children {
+		if f.MimeType == driveapi.ShortcutMimeType {
+			continue
+		}
+		rel := e.LocalPath(prefix, f.Name)
+		remotePath := rel
+		if owner[path.Base(rel)] != f.ID {
+			rel = aliasPath(rel, f.ID)
+		}
+		inScope := scope == "" || rel == scope || strings.HasPrefix(rel, scope+"/")
+		leadsToScope := strings.HasPrefix(scope, rel+"/")
+		if !inScope && !leadsToScope {
+			continue
+		}
+		if rel != remotePath {
+			if err := e.Store.SavePathAlias(ctx, &storage.PathAlias{
+				AccountID:  e.accountID,
+				DriveID:    f.ID,
+				RemotePath: remotePath,
+				LocalPath:  rel,
+			}); err != nil {
+				return err
+			}
+		}
+		if f.MimeType == driveapi.FolderMimeType {
+			if inScope {
+				out[rel] = f
+			}
+			if err := e.listRemoteTree(ctx, lister, f.ID, rel, scope, out, report); err != nil {
+				return err
+			}
+			continue
+		}
+		if inScope {
+			out[rel] = f
+		}
+	}
+	return nil
+}
+
+func (e *Engine) adoptFolder(ctx context.Context, rel string, remote map[string]driveapi.File) error {
+	f, ok := remote[rel]
+	if !ok || f.MimeType != driveapi.FolderMimeType {
+		return nil
+	}
+	existing, err := e.Store.GetFolderByPath(ctx, e.accountID, rel)
+	if err != nil || existing != nil {
+		return err
+	}
+	id, err := newID("folder-")
+	if err != nil {
+		return err
+	}
+	folder := &storage.Folder{ID: id, AccountID: e.accountID, Path: rel, DriveID: f.ID, ModifiedAt: f.ModifiedTime}
+	if len(f.Parents) > 0 {
+		folder.ParentID = f.Parents[0]
+	}
+	return e.Store.UpsertFolder(ctx, folder)
+}
+
+func (e *Engine) adoptFile(ctx context.Context, rel string, remote map[string]driveapi.File, report *AdoptReport) error {
+	existing, err := e.Store.GetFileByPath(ctx, e.accountID, rel)
+	if err != nil || existing != nil {
+		return err
+	}
+	if queued, err := e.Store.HasPendingOps(ctx, e.accountID, rel); err != nil || queued {
+		return err
+	}
+	// A placeholder stands in for Drive's content; it must never be compared
+	// with it, or uploaded over it.
+	placeholder, err := e.Store.IsPlaceholder(ctx, e.accountID, rel)
+	if err != nil {
+		return err
+	}
+	if placeholder {
+		f, ok := remote[rel]
+		if !ok || f.MimeType == driveapi.FolderMimeType {
+			report.Skipped = append(report.Skipped, rel+": placeholder for a file no longer in Drive")
+			return nil
+		}
+		report.Adopted++
+		return e.recordRemote(ctx, remoteChangeOf(f, rel), rel, nil)
+	}
+	info, err := os.Lstat(e.absPath(rel))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if reason := e.filter.excludes(rel, info.Size(), ""); reason != "" {
+		report.Skipped = append(report.Skipped, rel+": "+reason)
+		return nil
+	}
+	f, ok := remote[rel]
+	if !ok {
+		if err := e.queueNewUpload(ctx, rel); err != nil {
+			return err
+		}
+		report.Uploads++
+		return nil
+	}
+	if f.MimeType == driveapi.FolderMimeType {
+		report.Skipped = append(report.Skipped, rel+": is a folder in Drive")
+		return nil
+	}
+
+	change, err := e.openSealedChange(ctx, remoteChangeOf(f, rel))
+	if err != nil {
+		return err
+	}
+	if change.Size == info.Size() && change.Checksum != "" {
+		checksum, fast, err := fileDigests(e.absPath(rel))
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(checksum, change.Checksum) {
+			report.Adopted++
+			if err := e.recordRemote(ctx, change, rel, nil); err != nil {
+				return err
+			}
+			return e.Store.SetFileFastHash(ctx, e.accountID, rel, fast)
+		}
+	}
+	// Content differs: the record captures Drive's side, and the newer copy
+	// wins.
+	if err := e.recordRemote(ctx, change, rel, nil); err != nil {
+		return err
+	}
+	if info.ModTime().After(f.ModifiedTime) {
+		report.Uploads++
+		return e.addOp(ctx, opUpload, rel, f.ID)
+	}
+	report.Downloads++
+	return e.addDownload(ctx, rel, f.ID, f.Size)
+}
+
+func remoteChangeOf(f driveapi.File, rel string) RemoteChange {
+	checksum, size := driveContent(&f)
+	change := RemoteChange{
+		DriveID:    f.ID,
+		Path:       rel,
+		Checksum:   checksum,
+		Size:       size,
+		MimeType:   f.MimeType,
+		ModifiedAt: f.ModifiedTime,
+		Name:       f.Name,
+		ReadOnly:   f.ReadOnly,
+	}
+	return withDriveMeta(change, &f)
+}
+
+// withDriveMeta copies the metadata kept on file records from f.
+func withDriveMeta(change RemoteChange, f *driveapi.File) RemoteChange {
+	if len(f.Parents) > 0 {
+		change.ParentID = f.Parents[0]
+	}
+	change.MimeType = f.MimeType
+	change.RevisionID = f.HeadRevisionID
+	change.Starred = f.Starred
+	change.Shared = f.Shared
+	change.WebViewLink = f.WebViewLink
+	return change
+}
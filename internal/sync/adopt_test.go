@@ -0,0 +1,113 @@
+package sync
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+)
+
+// fakeTree serves ListChildren from a parent id -> children map.
+type fakeTree map[string][]driveapi.File
+
+func (f fakeTree) ListChildren(_ context.Context, id string) ([]driveapi.File, error) {
+	return f[id], nil
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestAdoptMatchesAndPlansDifferences(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	old := time.Unix(1_600_000_000, 0)
+	newer := time.Unix(1_700_000_000, 0)
+
+	files := map[string]string{
+		"export/docs/same.txt":   "same",
+		"export/docs/edited.txt": "local edit",
+		"export/docs/stale.txt":  "old",
+		"export/local-only.txt":  "new",
+	}
+	for rel, content := range files {
+		abs := e.absPath(rel)
+		if err := os.MkdirAll(filepath.Dir(abs), 0o700); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(abs, []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	if err := os.Chtimes(e.absPath("export/docs/edited.txt"), newer.Add(time.Hour), newer.Add(time.Hour)); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	if err := os.Chtimes(e.absPath("export/docs/stale.txt"), old, old); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	tree := fakeTree{
+		"root": {
+			{ID: "f-export", Name: "export", MimeType: driveapi.FolderMimeType},
+			{ID: "f-other", Name: "other", MimeType: driveapi.FolderMimeType},
+		},
+		"f-export": {
+			{ID: "f-docs", Name: "docs", MimeType: driveapi.FolderMimeType, Parents: []string{"f-export"}},
+			{ID: "d-remote", Name: "remote-only.txt", Size: 6, MD5Checksum: md5Hex("remote"), Parents: []string{"f-export"}},
+			{ID: "d-doc", Name: "Plan", MimeType: "application/vnd.google-apps.document"},
+			{ID: "d-dup1", Name: "dup.txt", Size: 1},
+			{ID: "d-dup2", Name: "dup.txt", Size: 1},
+		},
+		"f-docs": {
+			{ID: "d-same", Name: "same.txt", Size: 4, MD5Checksum: md5Hex("same"), ModifiedTime: old, Parents: []string{"f-docs"}},
+			{ID: "d-edited", Name: "edited.txt", Size: 3, MD5Checksum: md5Hex("old"), ModifiedTime: newer},
+			{ID: "d-stale", Name: "stale.txt", Size: 5, MD5Checksum: md5Hex("fresh"), ModifiedTime: newer},
+		},
+		"f-other": {{ID: "d-unrelated", Name: "x.txt", Size: 1}},
+	}
+
+	report, err := e.Adopt(ctx, tree, "root", e.absPath("export"))
+	if err != nil {
+		t.Fatalf("Adopt: %v", err)
+	}
+	if report.Adopted != 1 || report.Uploads != 2 || report.Downloads != 4 || len(report.Skipped) != 1 {
+		t.Fatalf("unexpected report: %#v", report)
+	}
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, "export/docs/same.txt")
+	if err != nil || rec == nil || rec.DriveID != "d-same" || rec.ParentID != "f-docs" {
+		t.Fatalf("expected adopted record, got %#v, %v", rec, err)
+	}
+	folder, err := e.Store.GetFolderByPath(ctx, e.accountID, "export/docs")
+	if err != nil || folder == nil || folder.DriveID != "f-docs" {
+		t.Fatalf("expected adopted folder, got %#v, %v", folder, err)
+	}
+	got := strings.Join(opTypes(t, e), ",")
+	for _, want := range []string{"upload export/docs/edited.txt", "download export/docs/stale.txt", "upload export/local-only.txt", "download export/remote-only.txt", "download export/dup.txt", "download export/dup (d-dup2).txt"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in ops %s", want, got)
+		}
+	}
+	if strings.Contains(got, "x.txt") {
+		t.Fatalf("expected files outside the adopted folder untouched, got %s", got)
+	}
+
+	// A second run finds everything already indexed.
+	again, err := e.Adopt(ctx, tree, "root", e.absPath("export"))
+	if err != nil || again.Adopted != 0 || again.Uploads != 0 {
+		t.Fatalf("expected idempotent rerun, got %#v, %v", again, err)
+	}
+}
+
+func TestAdoptRejectsOutsideRoot(t *testing.T) {
+	e := newTestEngine(t)
+	if _, err := e.Adopt(context.Background(), fakeTree{}, "root", t.TempDir()); err == nil {
+		t.Fatal("expected folder outside the sync root to fail")
+	}
+}
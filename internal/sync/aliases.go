@@ -0,0 +1,99 @@
+package sync
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// Status event labels for files stored under a disambiguated local name.
+const (
+	statusCaseConflict = "CASE_CONFLICT"
+	statusDuplicate    = "DUPLICATE_NAME"
+)
+
+// localPathFor returns where a Drive file lives locally. Its Drive path is
+// used unless another Drive file is already indexed there: a sibling with
+// the same name, which Drive allows, or on a case-insensitive sync root one
+// whose name differs only by case. The newcomer then gets a suffixed local
+// name derived from its Drive id, so the name does not depend on the order
+// changes arrive in. The mapping is stored and reused until the file is
+// renamed in Drive.
+func (e *Engine) localPathFor(ctx context.Context, change RemoteChange) (string, error) {
+	alias, err := e.Store.GetPathAlias(ctx, e.accountID, change.DriveID)
+	if err != nil {
+		return "", err
+	}
+	if alias != nil {
+		if alias.RemotePath == change.Path {
+			return alias.LocalPath, nil
+		}
+		if err := e.Store.DeletePathAlias(ctx, e.accountID, change.DriveID); err != nil {
+			return "", err
+		}
+	}
+	label, err := e.pathTaken(ctx, change.Path, change.DriveID)
+	if err != nil || label == "" {
+		return change.Path, err
+	}
+	local := aliasPath(change.Path, change.DriveID)
+	if err := e.Store.SavePathAlias(ctx, &storage.PathAlias{
+		AccountID:  e.accountID,
+		DriveID:    change.DriveID,
+		RemotePath: change.Path,
+		LocalPath:  local,
+	}); err != nil {
+		return "", err
+	}
+	e.Logger.Warn("drive path already taken locally; storing under a different name",
+		zap.String("reason", label), zap.String("remote_path", change.Path), zap.String("local_path", local))
+	if e.Status != nil {
+		e.Status.AddEvent(status.Event{Op: label, Path: change.Path + " -> " + local})
+	}
+	return local, nil
+}
+
+// pathTaken reports why rel cannot hold driveID, as a status label, or ""
+// when it can.
+func (e *Engine) pathTaken(ctx context.Context, rel, driveID string) (string, error) {
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, rel)
+	if err != nil {
+		return "", err
+	}
+	if rec != nil && rec.DriveID != driveID {
+		return statusDuplicate, nil
+	}
+	if !e.caseFold {
+		return "", nil
+	}
+	recs, err := e.Store.FindFilesFoldingCase(ctx, e.accountID, rel)
+	if err != nil {
+		return "", err
+	}
+	for _, rec := range recs {
+		if rec.DriveID != driveID && rec.Path != rel {
+			return statusCaseConflict, nil
+		}
+	}
+	return "", nil
+}
+
+// aliasPath inserts a short form of driveID before the extension:
+// "docs/Readme.md" becomes "docs/Readme (1a2b3c4d).md".
+func aliasPath(rel, driveID string) string {
+	short := driveID
+	if len(short) > 8 {
+		short = short[:8]
+	}
+	dir, base := path.Split(rel)
+	ext := path.Ext(base)
+	if ext == base {
+		ext = "" // dotfiles such as ".env" have no extension to keep
+	}
+	return dir + strings.TrimSuffix(base, ext) + " (" + short + ")" + ext
+}
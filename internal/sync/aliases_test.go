@@ -0,0 +1,50 @@
+package sync
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAliasPath(t *testing.T) {
+	cases := map[string]string{
+		"docs/Readme.md": "docs/Readme (1a2b3c4d).md",
+		"Notes":          "Notes (1a2b3c4d)",
+		"a/.env":         "a/.env (1a2b3c4d)",
+		"archive.tar.gz": "archive.tar (1a2b3c4d).gz",
+	}
+	for in, want := range cases {
+		if got := aliasPath(in, "1a2b3c4d5e6f"); got != want {
+			t.Fatalf("aliasPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDuplicateSiblingGetsStableLocalName(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	trackFile(t, e, "docs/a.txt", "d-one")
+
+	change := RemoteChange{DriveID: "d-two", Path: "docs/a.txt", Checksum: "abc", Size: 3}
+	for i := 0; i < 2; i++ {
+		if err := e.ApplyRemoteChange(ctx, change); err != nil {
+			t.Fatalf("ApplyRemoteChange: %v", err)
+		}
+	}
+	alias, err := e.Store.GetPathAlias(ctx, e.accountID, "d-two")
+	if err != nil || alias == nil || alias.LocalPath != "docs/a (d-two).txt" {
+		t.Fatalf("unexpected alias %+v %v", alias, err)
+	}
+	for _, op := range opTypes(t, e) {
+		if op != opDownload+" docs/a (d-two).txt" {
+			t.Fatalf("expected only the aliased download, got %v", opTypes(t, e))
+		}
+	}
+
+	// The file that owns the name is unaffected.
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "d-one", Path: "docs/a.txt", Checksum: "new", Size: 3}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if alias, err := e.Store.GetPathAlias(ctx, e.accountID, "d-one"); err != nil || alias != nil {
+		t.Fatalf("expected no alias for the owner, got %+v %v", alias, err)
+	}
+}
@@ -0,0 +1,40 @@
+package sync
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// Actions recorded for local changes the engine makes directly rather than
+// through a pending op.
+const (
+	auditMoveLocal         = "move_local"
+	auditCopyLocal         = "copy_local"
+	auditPlaceholder       = "placeholder"
+	auditCreateLocalFolder = "create_local_folder"
+	auditPruneLocalFolder  = "prune_local_folder"
+)
+
+// audit appends an action to the audit log.
+func (e *Engine) audit(ctx context.Context, action, path, driveID, detail string) error {
+	e.Logger.Info("audit", zap.String("action", action), zap.String("path", path), zap.String("detail", detail))
+	return e.Store.AddAuditEntry(ctx, &storage.AuditEntry{
+		AccountID: e.accountID,
+		Action:    action,
+		Path:      path,
+		DriveID:   driveID,
+		Detail:    detail,
+	})
+}
+
+// auditLocal stands in for a local change in audit mode. It records the
+// action and reports true when the caller must not touch the sync root.
+func (e *Engine) auditLocal(ctx context.Context, action, path, driveID, detail string) (bool, error) {
+	if !e.auditOnly {
+		return false, nil
+	}
+	return true, e.audit(ctx, action, path, driveID, detail)
+}
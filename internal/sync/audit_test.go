@@ -0,0 +1,69 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+)
+
+func TestAuditModeRecordsWithoutSyncing(t *testing.T) {
+	e := newTestEngine(t)
+	e.auditOnly = true
+	ctx := context.Background()
+
+	trackFile(t, e, "a.txt", "drive-a")
+	rec, err := e.Store.GetFileByDriveID(ctx, e.accountID, "drive-a")
+	if err != nil || rec == nil {
+		t.Fatalf("GetFileByDriveID: %#v, %v", rec, err)
+	}
+	// Same content under a new name would normally be a local rename.
+	move := RemoteChange{DriveID: "drive-a", Path: "renamed.txt", Size: rec.Size, ModifiedAt: rec.ModifiedAt}
+	if err := e.ApplyRemoteChange(ctx, move); err != nil {
+		t.Fatalf("ApplyRemoteChange move: %v", err)
+	}
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "drive-b", Path: "b.txt", Size: 3}); err != nil {
+		t.Fatalf("ApplyRemoteChange new: %v", err)
+	}
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "folder-1", Path: "docs", MimeType: driveapi.FolderMimeType}); err != nil {
+		t.Fatalf("ApplyRemoteChange folder: %v", err)
+	}
+
+	if _, err := os.Lstat(e.absPath("a.txt")); err != nil {
+		t.Fatalf("expected a.txt left in place: %v", err)
+	}
+	if _, err := os.Lstat(e.absPath("docs")); !os.IsNotExist(err) {
+		t.Fatalf("expected docs not created, got %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 1 || got[0] != "download b.txt" {
+		t.Fatalf("expected the download journaled, got %v", got)
+	}
+
+	entries, err := e.Store.ListAuditEntries(ctx, e.accountID, time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("ListAuditEntries: %v", err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Action+" "+entry.Path)
+	}
+	want := []string{"move_local renamed.txt", "download b.txt", "create_local_folder docs"}
+	if len(got) != len(want) {
+		t.Fatalf("audit log = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("audit log = %v, want %v", got, want)
+		}
+	}
+
+	x := newTestExecutor(t, e, nil)
+	if done, err := x.RunOnce(ctx); err != nil || done != 0 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	if got := opTypes(t, e); len(got) != 1 {
+		t.Fatalf("expected the download still queued, got %v", got)
+	}
+}
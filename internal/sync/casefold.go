@@ -0,0 +1,59 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+// CaseSensitivity says whether the sync root tells apart names that differ
+// only by case. Drive always does.
+type CaseSensitivity string
+
+const (
+	// CaseAuto probes the sync root when the engine starts.
+	CaseAuto CaseSensitivity = "auto"
+	// CaseSensitive keeps every Drive name as is.
+	CaseSensitive CaseSensitivity = "sensitive"
+	// CaseInsensitive disambiguates Drive siblings that differ only by case.
+	CaseInsensitive CaseSensitivity = "insensitive"
+)
+
+// ParseCaseSensitivity validates a configured setting. Empty means auto.
+func ParseCaseSensitivity(val string) (CaseSensitivity, error) {
+	switch c := CaseSensitivity(val); c {
+	case "":
+		return CaseAuto, nil
+	case CaseAuto, CaseSensitive, CaseInsensitive:
+		return c, nil
+	default:
+		return "", errs.New(errs.ErrInvalidArgument, "unknown case_sensitivity %q: use auto, sensitive or insensitive", val)
+	}
+}
+
+// foldsCase resolves the setting for root. Auto creates a lowercase probe
+// file and looks it up in upper case; when the probe cannot be created the
+// root is assumed to be case-sensitive.
+func foldsCase(c CaseSensitivity, root string) bool {
+	switch c {
+	case CaseSensitive:
+		return false
+	case CaseInsensitive:
+		return true
+	}
+	if root == "" {
+		return false
+	}
+	probe, err := os.CreateTemp(root, ".googlysync-case-probe-")
+	if err != nil {
+		return false
+	}
+	name := probe.Name()
+	_ = probe.Close()
+	defer os.Remove(name)
+	base := filepath.Base(name)
+	_, err = os.Lstat(filepath.Join(filepath.Dir(name), strings.ToUpper(base)))
+	return err == nil
+}
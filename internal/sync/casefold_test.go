@@ -0,0 +1,62 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+)
+
+func TestParseCaseSensitivity(t *testing.T) {
+	if c, err := ParseCaseSensitivity(""); err != nil || c != CaseAuto {
+		t.Fatalf("expected auto by default, got %q %v", c, err)
+	}
+	if _, err := ParseCaseSensitivity("maybe"); errs.KindOf(err) != errs.ErrInvalidArgument {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+}
+
+func TestCaseCollisionGetsStableLocalName(t *testing.T) {
+	e := newTestEngine(t)
+	e.caseFold = true
+	ctx := context.Background()
+	trackFile(t, e, "docs/Readme.md", "d-one")
+
+	change := RemoteChange{DriveID: "d-two", Path: "docs/README.md", Checksum: "abc", Size: 3}
+	if err := e.ApplyRemoteChange(ctx, change); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	ops := opTypes(t, e)
+	if len(ops) != 1 || ops[0] != opDownload+" docs/README (d-two).md" {
+		t.Fatalf("expected download to the disambiguated name, got %v", ops)
+	}
+	alias, err := e.Store.GetPathAlias(ctx, e.accountID, "d-two")
+	if err != nil || alias == nil || alias.RemotePath != "docs/README.md" || alias.LocalPath != "docs/README (d-two).md" {
+		t.Fatalf("unexpected alias %+v %v", alias, err)
+	}
+
+	// Renamed in Drive to a name that no longer collides: the alias goes.
+	if _, err := e.Store.DeletePendingOpsForPath(ctx, e.accountID, "docs/README (d-two).md"); err != nil {
+		t.Fatalf("DeletePendingOpsForPath: %v", err)
+	}
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "d-two", Path: "docs/Guide.md", Checksum: "abc", Size: 3}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if alias, err := e.Store.GetPathAlias(ctx, e.accountID, "d-two"); err != nil || alias != nil {
+		t.Fatalf("expected alias dropped after rename, got %+v %v", alias, err)
+	}
+}
+
+func TestCaseSensitiveRootKeepsDriveNames(t *testing.T) {
+	e := newTestEngine(t)
+	e.caseFold = false
+	ctx := context.Background()
+	trackFile(t, e, "docs/Readme.md", "d-one")
+
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "d-two", Path: "docs/README.md", Checksum: "abc", Size: 3}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if ops := opTypes(t, e); len(ops) != 1 || ops[0] != opDownload+" docs/README.md" {
+		t.Fatalf("expected download under the Drive name, got %v", ops)
+	}
+}
@@ -0,0 +1,239 @@
+package sync
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// Defaults for the changes-feed poll interval when none is configured.
+const (
+	defaultChangesPollMin = 15 * time.Second
+	defaultChangesPollMax = 5 * time.Minute
+)
+
+// ChangeFeed reads the Drive changes feed. Drive clients returned by a
+// RemoteFunc are polled when they implement it.
+type ChangeFeed interface {
+	GetStartPageToken(ctx context.Context) (string, error)
+	ListChanges(ctx context.Context, pageToken string) (*driveapi.ChangePage, error)
+}
+
+// ChangePoller applies the Drive changes feed to the engine. It polls often
+// while things are happening and backs off while the account is idle: every
+// poll that finds nothing doubles the wait, up to a ceiling, and the wait
+// drops back to the floor as soon as a poll finds changes or a local edit
+// is seen. A ChangePush, when configured, makes it poll as notifications
+// arrive instead.
+type ChangePoller struct {
+	logger  *zap.Logger
+	engine  *Engine
+	remotes RemoteFunc
+	min     time.Duration
+	max     time.Duration
+	// rootID is the id of the My Drive root, looked up on the first poll.
+	// Files directly under it map to the top of the sync root.
+	rootID string
+	// wake asks for a poll now; see Notify.
+	wake chan struct{}
+	// pushed is set while Drive is known to deliver push notifications,
+	// which leaves the timer as a slow safety net.
+	pushed atomic.Bool
+}
+
+// NewChangePoller constructs a poller.
+func NewChangePoller(logger *zap.Logger, engine *Engine, remotes RemoteFunc) *ChangePoller {
+	minWait, maxWait := defaultChangesPollMin, defaultChangesPollMax
+	if c := engine.Config; c != nil {
+		if c.ChangesPollMinSeconds > 0 {
+			minWait = time.Duration(c.ChangesPollMinSeconds) * time.Second
+		}
+		if c.ChangesPollMaxSeconds > 0 {
+			maxWait = time.Duration(c.ChangesPollMaxSeconds) * time.Second
+		}
+	}
+	maxWait = max(maxWait, minWait)
+	return &ChangePoller{logger: logger, engine: engine, remotes: remotes, min: minWait, max: maxWait, wake: make(chan struct{}, 1)}
+}
+
+// Notify asks for a poll as soon as possible. Requests made while one is
+// pending are coalesced.
+func (p *ChangePoller) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *ChangePoller) setPushed(pushed bool) {
+	if p.pushed.Swap(pushed) != pushed {
+		p.logger.Info("drive push notifications", zap.Bool("active", pushed))
+	}
+}
+
+// Run polls until ctx is done.
+func (p *ChangePoller) Run(ctx context.Context) {
+	wait := p.min
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.engine.LocalActivity():
+			if wait == p.min || p.pushed.Load() {
+				continue
+			}
+			wait = p.min
+			timer.Reset(wait)
+		case <-p.wake:
+			timer.Reset(0)
+		case <-timer.C:
+			n, err := p.Poll(ctx)
+			if err != nil && ctx.Err() == nil {
+				p.logger.Warn("changes poll failed", zap.Error(err))
+			}
+			wait = p.nextWait(wait, n > 0 && err == nil)
+			p.logger.Debug("next changes poll", zap.Int("changes", n), zap.Duration("wait", wait))
+			timer.Reset(wait)
+		}
+	}
+}
+
+// nextWait returns the wait before the poll after one that waited wait.
+func (p *ChangePoller) nextWait(wait time.Duration, changed bool) time.Duration {
+	if p.pushed.Load() {
+		return p.max
+	}
+	if changed {
+		return p.min
+	}
+	return min(wait*2, p.max)
+}
+
+// Poll applies every change since the stored page token and returns how
+// many there were. The first poll for an account only records where the
+// feed stands, since the initial state comes from adopting or resyncing.
+// Nothing is read while signed out or paused.
+func (p *ChangePoller) Poll(ctx context.Context) (int, error) {
+	e := p.engine
+	if e.Paused() {
+		return 0, nil
+	}
+	remote := p.remotes(ctx)
+	if remote == nil {
+		return 0, nil
+	}
+	feed, ok := remote.(ChangeFeed)
+	if !ok {
+		return 0, nil
+	}
+	state, started, err := p.syncState(ctx, feed)
+	if err != nil || started {
+		return 0, err
+	}
+	if p.rootID == "" {
+		root, err := remote.GetFile(ctx, "root")
+		if err != nil {
+			return 0, err
+		}
+		p.rootID = root.ID
+	}
+
+	p.replay(ctx)
+	count := 0
+	token := state.StartPageToken
+	for {
+		page, err := feed.ListChanges(ctx, token)
+		if err != nil {
+			return count, err
+		}
+		for _, change := range page.Changes {
+			count++
+			p.consume(ctx, change)
+		}
+		if page.NewStartPageToken != "" {
+			token = page.NewStartPageToken
+			break
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		token = page.NextPageToken
+	}
+
+	now := time.Now()
+	state.StartPageToken = token
+	state.LastSyncAt = now
+	state.LastError = ""
+	state.UpdatedAt = now
+	return count, e.Store.UpsertSyncState(ctx, state)
+}
+
+// syncState returns the account's sync state, recording the feed's current
+// page token first when there is none yet. started reports that it did.
+func (p *ChangePoller) syncState(ctx context.Context, feed ChangeFeed) (state *storage.SyncState, started bool, err error) {
+	e := p.engine
+	state, err = e.Store.GetSyncState(ctx, e.accountID)
+	if err != nil {
+		return nil, false, err
+	}
+	if state == nil {
+		state = &storage.SyncState{AccountID: e.accountID}
+	}
+	if state.StartPageToken != "" {
+		return state, false, nil
+	}
+	token, err := feed.GetStartPageToken(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	state.StartPageToken = token
+	state.UpdatedAt = time.Now()
+	return state, true, e.Store.UpsertSyncState(ctx, state)
+}
+
+// applyDriveChange resolves a feed entry to a local path and applies it.
+// Files whose parent folder is not indexed are outside what is synced and
+// are skipped.
+func (e *Engine) applyDriveChange(ctx context.Context, change driveapi.Change, rootID string) error {
+	if change.Removed || change.File == nil || change.File.Trashed {
+		trashed := !change.Removed && change.File != nil
+		return e.ApplyRemoteChange(ctx, RemoteChange{DriveID: change.FileID, Removed: true, Trashed: trashed})
+	}
+	f := *change.File
+	if len(f.Parents) == 0 {
+		return nil
+	}
+	parent := ""
+	if f.Parents[0] != rootID {
+		folder, err := e.Store.GetFolderByDriveID(ctx, e.accountID, f.Parents[0])
+		if err != nil {
+			return err
+		}
+		if folder == nil {
+			e.Logger.Debug("skipping change outside synced folders", zap.String("drive_id", f.ID))
+			return nil
+		}
+		parent = folder.Path
+	}
+	return e.ApplyRemoteChange(ctx, remoteChangeOf(f, e.LocalPath(parent, f.Name)))
+}
+
+// LocalActivity signals after the engine handles a local filesystem event.
+// Signals are coalesced.
+func (e *Engine) LocalActivity() <-chan struct{} {
+	return e.activity
+}
+
+func (e *Engine) noteLocalActivity() {
+	select {
+	case e.activity <- struct{}{}:
+	default:
+	}
+}
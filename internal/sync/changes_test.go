@@ -0,0 +1,181 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// fakeFeed serves the changes feed from pages keyed by page token.
+type fakeFeed struct {
+	fakeRemote
+	start string
+	pages map[string]*driveapi.ChangePage
+	reads *int
+}
+
+func (f fakeFeed) GetStartPageToken(context.Context) (string, error) {
+	return f.start, nil
+}
+
+func (f fakeFeed) ListChanges(_ context.Context, token string) (*driveapi.ChangePage, error) {
+	*f.reads++
+	if page, ok := f.pages[token]; ok {
+		return page, nil
+	}
+	return &driveapi.ChangePage{NewStartPageToken: token}, nil
+}
+
+func TestChangePollerAppliesFeed(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	reads := 0
+	feed := fakeFeed{
+		fakeRemote: fakeRemote{files: map[string]driveapi.File{"root": {ID: "root-id"}}},
+		start:      "1",
+		reads:      &reads,
+		pages: map[string]*driveapi.ChangePage{
+			"1": {NextPageToken: "2", Changes: []driveapi.Change{
+				{FileID: "f-docs", File: &driveapi.File{ID: "f-docs", Name: "docs", MimeType: driveapi.FolderMimeType, Parents: []string{"root-id"}}},
+			}},
+			"2": {NewStartPageToken: "3", Changes: []driveapi.Change{
+				{FileID: "d-a", File: &driveapi.File{ID: "d-a", Name: "a.txt", MD5Checksum: md5Hex("a"), Size: 1, Parents: []string{"f-docs"}}},
+				{FileID: "d-x", File: &driveapi.File{ID: "d-x", Name: "x.txt", Parents: []string{"elsewhere"}}},
+			}},
+		},
+	}
+	p := NewChangePoller(zap.NewNop(), e, func(context.Context) RemoteFiles { return feed })
+
+	// The first poll only records where the feed stands.
+	if n, err := p.Poll(ctx); err != nil || n != 0 || reads != 0 {
+		t.Fatalf("first Poll: %d, %v (%d reads)", n, err, reads)
+	}
+	n, err := p.Poll(ctx)
+	if err != nil || n != 3 {
+		t.Fatalf("Poll: %d, %v", n, err)
+	}
+	if ops := opTypes(t, e); len(ops) != 1 || ops[0] != opDownload+" docs/a.txt" {
+		t.Fatalf("expected only the file under a synced folder downloaded, got %v", ops)
+	}
+	state, err := e.Store.GetSyncState(ctx, e.accountID)
+	if err != nil || state == nil || state.StartPageToken != "3" || state.LastSyncAt.IsZero() {
+		t.Fatalf("expected new start token saved, got %#v, %v", state, err)
+	}
+	if n, err := p.Poll(ctx); err != nil || n != 0 {
+		t.Fatalf("idle Poll: %d, %v", n, err)
+	}
+
+	// Paused accounts are not polled.
+	e.paused = true
+	reads = 0
+	if _, err := p.Poll(ctx); err != nil || reads != 0 {
+		t.Fatalf("expected paused account skipped, got %d reads, %v", reads, err)
+	}
+}
+
+func TestChangePollerBacksOffWhileIdle(t *testing.T) {
+	e := newTestEngine(t)
+	e.Config.ChangesPollMinSeconds = 10
+	e.Config.ChangesPollMaxSeconds = 60
+	p := NewChangePoller(zap.NewNop(), e, func(context.Context) RemoteFiles { return nil })
+
+	wait := p.min
+	var got []time.Duration
+	for range 4 {
+		wait = p.nextWait(wait, false)
+		got = append(got, wait)
+	}
+	want := []time.Duration{20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected waits %v, got %v", want, got)
+		}
+	}
+	if wait = p.nextWait(wait, true); wait != 10*time.Second {
+		t.Fatalf("expected changes to reset the wait, got %v", wait)
+	}
+}
+
+func TestLocalActivityIsCoalesced(t *testing.T) {
+	e := newTestEngine(t)
+	e.noteLocalActivity()
+	e.noteLocalActivity()
+	select {
+	case <-e.LocalActivity():
+	default:
+		t.Fatal("expected a local activity signal")
+	}
+	select {
+	case <-e.LocalActivity():
+		t.Fatal("expected signals to be coalesced")
+	default:
+	}
+}
+
+func TestChangeJournalSkipsDuplicatesAndReplaysFailures(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	reads := 0
+	changed := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	feed := fakeFeed{
+		fakeRemote: fakeRemote{files: map[string]driveapi.File{"root": {ID: "root-id"}}},
+		start:      "1",
+		reads:      &reads,
+		pages: map[string]*driveapi.ChangePage{
+			"1": {NewStartPageToken: "2", Changes: []driveapi.Change{
+				{FileID: "d-a", Time: changed, File: &driveapi.File{ID: "d-a", Name: "a.txt", MD5Checksum: md5Hex("a"), Size: 1, Version: 5, Parents: []string{"root-id"}}},
+			}},
+		},
+	}
+	p := NewChangePoller(zap.NewNop(), e, func(context.Context) RemoteFiles { return feed })
+	if _, err := p.Poll(ctx); err != nil {
+		t.Fatalf("first Poll: %v", err)
+	}
+	if n, err := p.Poll(ctx); err != nil || n != 1 {
+		t.Fatalf("Poll: %d, %v", n, err)
+	}
+
+	// Read the same page again, as after a crash before the token was saved.
+	state, _ := e.Store.GetSyncState(ctx, e.accountID)
+	state.StartPageToken = "1"
+	if err := e.Store.UpsertSyncState(ctx, state); err != nil {
+		t.Fatalf("UpsertSyncState: %v", err)
+	}
+	if n, err := p.Poll(ctx); err != nil || n != 1 {
+		t.Fatalf("repeated Poll: %d, %v", n, err)
+	}
+	entries, err := e.Store.ListJournaledChanges(ctx, storage.ChangeFilter{AccountID: e.accountID, DriveID: "d-a"})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("ListJournaledChanges: %#v, %v", entries, err)
+	}
+	if entries[0].Outcome != storage.ChangeDuplicate || entries[1].Outcome != storage.ChangeApplied ||
+		entries[1].Name != "a.txt" || entries[1].Version != 5 || !entries[1].ChangedAt.Equal(changed) {
+		t.Fatalf("unexpected journal %#v", entries)
+	}
+	if ops := opTypes(t, e); len(ops) != 1 {
+		t.Fatalf("expected one download, got %v", ops)
+	}
+
+	// A change that failed on an earlier poll is applied on the next one.
+	payload, _ := json.Marshal(driveapi.Change{FileID: "d-b", File: &driveapi.File{ID: "d-b", Name: "b.txt", Size: 1, Version: 2, Parents: []string{"root-id"}}})
+	failed := &storage.JournaledChange{AccountID: e.accountID, DriveID: "d-b", Kind: "file", Outcome: storage.ChangeFailed, Attempts: 1, Payload: payload}
+	if err := e.Store.AddJournaledChange(ctx, failed, 0); err != nil {
+		t.Fatalf("AddJournaledChange: %v", err)
+	}
+	if _, err := p.Poll(ctx); err != nil {
+		t.Fatalf("replaying Poll: %v", err)
+	}
+	if ops := opTypes(t, e); len(ops) != 2 || ops[1] != opDownload+" b.txt" {
+		t.Fatalf("expected the failed change replayed, got %v", ops)
+	}
+	entries, err = e.Store.ListJournaledChanges(ctx, storage.ChangeFilter{AccountID: e.accountID, DriveID: "d-b"})
+	if err != nil || len(entries) != 1 || entries[0].Outcome != storage.ChangeApplied || entries[0].Attempts != 2 {
+		t.Fatalf("expected the replay journaled, got %#v, %v", entries, err)
+	}
+}
@@ -0,0 +1,85 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// claimArrivedUpload links an untracked local file to a remote file with the
+// same path and md5, typically uploaded by another of the user's machines,
+// and drops the upload this machine had queued for it. It reports whether
+// the change was fully handled.
+func (e *Engine) claimArrivedUpload(ctx context.Context, change RemoteChange) (bool, error) {
+	if change.Removed || change.Path == "" || change.Checksum == "" || len(change.Projections) > 0 {
+		return false, nil
+	}
+	if rec, err := e.Store.GetFileByDriveID(ctx, e.accountID, change.DriveID); err != nil || rec != nil {
+		return false, err
+	}
+	if rec, err := e.Store.GetFileByPath(ctx, e.accountID, change.Path); err != nil || rec != nil {
+		return false, err
+	}
+
+	abs := e.absPath(change.Path)
+	info, err := os.Lstat(abs)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !info.Mode().IsRegular() || info.Size() != change.Size {
+		return false, nil
+	}
+	checksum, fast, err := fileDigests(abs)
+	if err != nil {
+		return false, err
+	}
+	if !strings.EqualFold(checksum, change.Checksum) {
+		return false, nil
+	}
+
+	id, err := newRecordID()
+	if err != nil {
+		return false, err
+	}
+	rec := &storage.FileRecord{
+		ID:          id,
+		AccountID:   e.accountID,
+		Path:        change.Path,
+		DriveID:     change.DriveID,
+		ParentID:    change.ParentID,
+		ETag:        change.ETag,
+		Checksum:    checksum,
+		FastHash:    fast,
+		MimeType:    change.MimeType,
+		RevisionID:  change.RevisionID,
+		Starred:     change.Starred,
+		Shared:      change.Shared,
+		WebViewLink: change.WebViewLink,
+		Size:        change.Size,
+		ModifiedAt:  change.ModifiedAt,
+	}
+	if device, inode, ok := fileID(info); ok {
+		rec.Device, rec.Inode = device, inode
+	}
+	if err := e.Store.UpsertFile(ctx, rec); err != nil {
+		return false, err
+	}
+	removed, err := e.Store.DeletePendingOpsForPath(ctx, e.accountID, change.Path, opUpload, opCopy)
+	if err != nil {
+		return false, err
+	}
+	e.Logger.Info("content already uploaded elsewhere; linked metadata",
+		zap.String("path", change.Path),
+		zap.String("drive_id", change.DriveID),
+		zap.Int64("dropped_ops", removed),
+	)
+	return true, nil
+}
@@ -0,0 +1,54 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestRemoteUploadOfSameContentIsClaimed(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	if err := os.WriteFile(e.absPath("new.txt"), []byte("shared"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := e.noteCreate(ctx, "new.txt"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 1 || got[0] != "upload new.txt" {
+		t.Fatalf("expected queued upload, got %v", got)
+	}
+
+	checksum, size, err := fileChecksum(e.absPath("new.txt"))
+	if err != nil {
+		t.Fatalf("fileChecksum: %v", err)
+	}
+	change := RemoteChange{DriveID: "drive-other", Path: "new.txt", Checksum: checksum, Size: size}
+	if err := e.ApplyRemoteChange(ctx, change); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 0 {
+		t.Fatalf("expected upload dropped and no download, got %v", got)
+	}
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, "new.txt")
+	if err != nil || rec == nil || rec.DriveID != "drive-other" {
+		t.Fatalf("expected linked record, got %#v, %v", rec, err)
+	}
+}
+
+func TestRemoteUploadWithDifferentContentIsNotClaimed(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	if err := os.WriteFile(e.absPath("new.txt"), []byte("local"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	change := RemoteChange{DriveID: "drive-other", Path: "new.txt", Checksum: "0123456789abcdef", Size: 5}
+	if err := e.ApplyRemoteChange(ctx, change); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 1 || got[0] != "download new.txt" {
+		t.Fatalf("expected download, got %v", got)
+	}
+}
@@ -0,0 +1,82 @@
+package sync
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+)
+
+// App properties describing compressed content on Drive.
+const (
+	// appPropCompression names the compression applied before upload.
+	appPropCompression = "googlysync_compression"
+	// appPropSize is the length of the uncompressed content.
+	appPropSize = "googlysync_size"
+	// appPropMD5 is the MD5 of the uncompressed content. It is left out for
+	// encrypted files, whose plaintext checksum stays local.
+	appPropMD5 = "googlysync_md5"
+
+	compressionGzip = "gzip"
+)
+
+// compresses reports whether rel is compressed before upload. Types listed
+// in compress_skip_types, which are usually compressed already, are sent as
+// they are; a trailing "*" matches any subtype.
+func (e *Engine) compresses(rel string) bool {
+	if !e.compressUploads {
+		return false
+	}
+	mimeType, _, _ := mime.ParseMediaType(mime.TypeByExtension(path.Ext(rel)))
+	if mimeType == "" {
+		return true
+	}
+	for _, skip := range e.compressSkip {
+		if prefix, ok := strings.CutSuffix(skip, "*"); ok {
+			if strings.HasPrefix(mimeType, prefix) {
+				return false
+			}
+		} else if strings.EqualFold(mimeType, skip) {
+			return false
+		}
+	}
+	return true
+}
+
+// compressedOn reports whether Drive holds file compressed.
+func compressedOn(file *driveapi.File) bool {
+	return file.AppProperties[appPropCompression] == compressionGzip
+}
+
+// uncompressed wraps the content of a compressed file and returns it with
+// the uncompressed size and checksum recorded on Drive; -1 and "" when they
+// are missing.
+func uncompressed(file *driveapi.File, body io.Reader) (io.Reader, int64, string, error) {
+	r, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	size, err := strconv.ParseInt(file.AppProperties[appPropSize], 10, 64)
+	if err != nil {
+		size = -1
+	}
+	return r, size, file.AppProperties[appPropMD5], nil
+}
+
+// driveContent returns the checksum and size of a Drive file's content as
+// the index records it: the uncompressed values for compressed files that
+// carry them, what Drive reports otherwise.
+func driveContent(file *driveapi.File) (string, int64) {
+	if !compressedOn(file) || file.AppProperties[appPropMD5] == "" {
+		return file.MD5Checksum, file.Size
+	}
+	size, err := strconv.ParseInt(file.AppProperties[appPropSize], 10, 64)
+	if err != nil {
+		return file.MD5Checksum, file.Size
+	}
+	return file.AppProperties[appPropMD5], size
+}
@@ -0,0 +1,194 @@
+package sync
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+)
+
+func TestCompressesSkipsListedTypes(t *testing.T) {
+	e := newTestEngine(t)
+	e.compressUploads = true
+	e.compressSkip = []string{"image/*", "application/zip"}
+
+	for rel, want := range map[string]bool{
+		"notes.txt":      true,
+		"data.csv":       true,
+		"Makefile":       true,
+		"photo.jpg":      false,
+		"archive.zip":    false,
+		"nested/pic.PNG": false,
+	} {
+		if got := e.compresses(rel); got != want {
+			t.Fatalf("compresses(%q) = %v, want %v", rel, got, want)
+		}
+	}
+	e.compressUploads = false
+	if e.compresses("notes.txt") {
+		t.Fatal("expected nothing compressed when compression is off")
+	}
+}
+
+func TestCompressedUploadRoundTrip(t *testing.T) {
+	e := newTestEngine(t)
+	e.compressUploads = true
+	ctx := context.Background()
+	content := strings.Repeat("compress me ", 1000)
+	indexFile(t, e, "log.txt", "d-log", content)
+
+	upload, err := e.UploadContent("log.txt")
+	if err != nil {
+		t.Fatalf("UploadContent: %v", err)
+	}
+	packed, err := io.ReadAll(upload)
+	_ = upload.Close()
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if upload.Size != -1 || len(packed) >= len(content) {
+		t.Fatalf("expected compressed upload of unknown size, got size %d and %d bytes", upload.Size, len(packed))
+	}
+	props := upload.AppProperties
+	if props[appPropCompression] != compressionGzip || props[appPropMD5] != md5Hex(content) || props[appPropSize] != "12000" {
+		t.Fatalf("unexpected app properties %v", props)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(packed))
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	if got, _ := io.ReadAll(r); string(got) != content {
+		t.Fatal("expected content to round trip")
+	}
+
+	// Drive's checksum is of the compressed bytes; the index compares the
+	// original ones.
+	file := driveapi.File{ID: "d-log", MD5Checksum: md5Hex(string(packed)), Size: int64(len(packed)), AppProperties: props}
+	if err := e.ApplyRemoteChange(ctx, remoteChangeOf(file, "log.txt")); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if ops := opTypes(t, e); len(ops) != 0 {
+		t.Fatalf("expected unchanged content to need nothing, got %v", ops)
+	}
+
+	remote := fakeRemote{
+		fakeContent: fakeContent{"d-log": string(packed)},
+		files:       map[string]driveapi.File{"d-log": file},
+	}
+	x := newTestExecutor(t, e, remote)
+	if err := e.addDownload(ctx, "copy.txt", "d-log", file.Size); err != nil {
+		t.Fatalf("addDownload: %v", err)
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	if data, _ := os.ReadFile(e.absPath("copy.txt")); string(data) != content {
+		t.Fatal("expected download to be decompressed")
+	}
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, "copy.txt")
+	if err != nil || rec == nil || rec.Checksum != md5Hex(content) || rec.Size != int64(len(content)) {
+		t.Fatalf("expected uncompressed digest recorded, got %#v, %v", rec, err)
+	}
+}
+
+func TestCompressedAndEncryptedUpload(t *testing.T) {
+	e, _ := newEncryptingEngine(t)
+	e.compressUploads = true
+	ctx := context.Background()
+	content := strings.Repeat("private ", 500)
+	indexFile(t, e, "diary.txt", "d-diary", content)
+
+	upload, err := e.UploadContent("diary.txt")
+	if err != nil {
+		t.Fatalf("UploadContent: %v", err)
+	}
+	sealed, err := io.ReadAll(upload)
+	_ = upload.Close()
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if _, ok := upload.AppProperties[appPropMD5]; ok {
+		t.Fatal("expected no plaintext checksum on Drive for encrypted content")
+	}
+
+	file := driveapi.File{ID: "d-diary", MD5Checksum: md5Hex(string(sealed)), Size: int64(len(sealed)), AppProperties: upload.AppProperties}
+	remote := fakeRemote{
+		fakeContent: fakeContent{"d-diary": string(sealed)},
+		files:       map[string]driveapi.File{"d-diary": file},
+	}
+	x := newTestExecutor(t, e, remote)
+	if err := e.addDownload(ctx, "copy.txt", "d-diary", file.Size); err != nil {
+		t.Fatalf("addDownload: %v", err)
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	if data, _ := os.ReadFile(e.absPath("copy.txt")); string(data) != content {
+		t.Fatal("expected download to be decrypted and decompressed")
+	}
+	info, err := e.Store.GetEncryptedFile(ctx, e.accountID, "d-diary")
+	if err != nil || info == nil || info.PlainMD5 != md5Hex(content) || info.PlainSize != int64(len(content)) {
+		t.Fatalf("expected plaintext metadata recorded, got %#v, %v", info, err)
+	}
+}
+
+func TestExecutorUploadsCompressedContent(t *testing.T) {
+	e := newTestEngine(t)
+	e.compressUploads = true
+	ctx := context.Background()
+	content := strings.Repeat("compress me ", 1000)
+	if err := os.WriteFile(e.absPath("log.txt"), []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	drive := newFakeDrive()
+	x := newTestExecutor(t, e, drive)
+	if err := e.addOp(ctx, opUpload, "log.txt", ""); err != nil {
+		t.Fatalf("addOp: %v", err)
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, "log.txt")
+	if err != nil || rec == nil || rec.Checksum != md5Hex(content) || rec.Size != int64(len(content)) {
+		t.Fatalf("expected the uncompressed digest recorded, got %#v, %v", rec, err)
+	}
+	file := drive.files[rec.DriveID]
+	if !compressedOn(&file) || file.AppProperties[appPropMD5] != md5Hex(content) || file.Size >= int64(len(content)) {
+		t.Fatalf("expected compressed content on Drive, got %#v", file)
+	}
+	r, err := gzip.NewReader(strings.NewReader(drive.content[rec.DriveID]))
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	if got, _ := io.ReadAll(r); string(got) != content {
+		t.Fatal("expected content to round trip")
+	}
+	if err := e.ApplyRemoteChange(ctx, remoteChangeOf(file, "log.txt")); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if ops := opTypes(t, e); len(ops) != 0 {
+		t.Fatalf("expected our own upload to need nothing, got %v", ops)
+	}
+
+	// With compression off, the next version is sent as it is and the file
+	// no longer reads as compressed.
+	e.compressUploads = false
+	if err := os.WriteFile(e.absPath("log.txt"), []byte("plain"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := e.addOp(ctx, opUpload, "log.txt", rec.DriveID); err != nil {
+		t.Fatalf("addOp: %v", err)
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	if file := drive.files[rec.DriveID]; compressedOn(&file) || drive.content[rec.DriveID] != "plain" {
+		t.Fatalf("expected uncompressed content, got %#v", file)
+	}
+}
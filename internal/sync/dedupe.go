@@ -0,0 +1,193 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/hashing"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// opCopy asks Drive to copy an already uploaded file into a new path instead
+// of uploading identical content again.
+const opCopy = "copy"
+
+// FileCopier copies files Drive-side. The executor copies through remotes
+// that implement it.
+type FileCopier interface {
+	Copy(ctx context.Context, id string, meta driveapi.FileMeta) (*driveapi.File, error)
+}
+
+// fileChecksum hashes a local file the way Drive reports md5Checksum.
+func fileChecksum(path string) (string, int64, error) {
+	sums, n, err := hashing.SumFile(path, hashing.MD5)
+	if err != nil {
+		return "", 0, err
+	}
+	return sums[hashing.MD5], n, nil
+}
+
+// fileDigests hashes a local file once for both its Drive checksum and the
+// fast digest used for change detection.
+func fileDigests(path string) (checksum, fast string, err error) {
+	sums, _, err := hashing.SumFile(path, hashing.MD5, hashing.XXH64)
+	if err != nil {
+		return "", "", err
+	}
+	return sums[hashing.MD5], sums[hashing.XXH64], nil
+}
+
+// fileFastHash returns the fast digest of a local file.
+func fileFastHash(path string) (string, error) {
+	sums, _, err := hashing.SumFile(path, hashing.XXH64)
+	if err != nil {
+		return "", err
+	}
+	return sums[hashing.XXH64], nil
+}
+
+// findLocalCopy returns a tracked file other than exclude whose content
+// matches and is still present on disk.
+func (e *Engine) findLocalCopy(ctx context.Context, checksum string, size int64, exclude string) (*storage.FileRecord, error) {
+	recs, err := e.Store.ListFilesByChecksum(ctx, e.accountID, checksum, size)
+	if err != nil {
+		return nil, err
+	}
+	for i := range recs {
+		rec := &recs[i]
+		if rec.Path == exclude || rec.DriveID == "" {
+			continue
+		}
+		info, err := os.Lstat(e.absPath(rec.Path))
+		if err != nil || !info.Mode().IsRegular() || info.Size() != size {
+			continue
+		}
+		return rec, nil
+	}
+	return nil, nil
+}
+
+// queueNewUpload plans an upload for an untracked file, turning it into a
+// Drive-side copy when identical content is already uploaded.
+func (e *Engine) queueNewUpload(ctx context.Context, rel string) error {
+	checksum, size, err := fileChecksum(e.absPath(rel))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if size > 0 {
+		source, err := e.findLocalCopy(ctx, checksum, size, rel)
+		if err != nil {
+			return err
+		}
+		if source != nil {
+			e.Logger.Debug("duplicate content; copying remotely", zap.String("path", rel), zap.String("source", source.Path))
+			return e.addOp(ctx, opCopy, rel, source.DriveID)
+		}
+	}
+	return e.addOp(ctx, opUpload, rel, "")
+}
+
+// queueDownload plans fetching remote content for path, reusing an identical
+// local file when one exists so the bytes are not downloaded again.
+func (e *Engine) queueDownload(ctx context.Context, change RemoteChange, path string, rec *storage.FileRecord) error {
+	if change.Checksum != "" && change.Size > 0 && e.directionFor(ctx, path).allows(opDownload) {
+		source, err := e.findLocalCopy(ctx, strings.ToLower(change.Checksum), change.Size, path)
+		if err != nil {
+			return err
+		}
+		if source != nil {
+			return e.reuseLocalContent(ctx, change, path, source, rec)
+		}
+	}
+	if created, err := e.queuePlaceholder(ctx, change, path, rec); err != nil || created {
+		return err
+	}
+	return e.addDownload(ctx, path, change.DriveID, change.Size)
+}
+
+func (e *Engine) reuseLocalContent(ctx context.Context, change RemoteChange, path string, source, rec *storage.FileRecord) error {
+	if audited, err := e.auditLocal(ctx, auditCopyLocal, path, change.DriveID, "from "+source.Path); err != nil || audited {
+		return err
+	}
+	e.suppress(path)
+	if err := copyLocal(e.absPath(source.Path), e.absPath(path)); err != nil {
+		return err
+	}
+	if err := e.recordRemote(ctx, change, path, rec); err != nil {
+		return err
+	}
+	e.Logger.Info("reused local content instead of downloading", zap.String("path", path), zap.String("source", source.Path))
+	return nil
+}
+
+// copyRemote copies the Drive file op.DriveID into the Drive folder of
+// op.Path. The content is uploaded instead when the source no longer matches
+// the local file or was sealed differently from how it would be now.
+func (x *Executor) copyRemote(ctx context.Context, op storage.PendingOp) error {
+	e := x.engine
+	remote, err := x.remote(ctx, "copy", op.Path)
+	if err != nil {
+		return err
+	}
+	copier, ok := remote.(FileCopier)
+	if !ok {
+		return errs.New(errs.ErrInvalidArgument, "drive client cannot copy %s", op.Path)
+	}
+	abs := e.absPath(op.Path)
+	info, err := os.Stat(abs)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	checksum, fast, err := fileDigests(abs)
+	if err != nil {
+		return err
+	}
+	source, err := e.Store.GetFileByDriveID(ctx, e.accountID, op.DriveID)
+	if err != nil {
+		return err
+	}
+	sealed, err := e.Store.GetEncryptedFile(ctx, e.accountID, op.DriveID)
+	if err != nil {
+		return err
+	}
+	if source == nil || source.Checksum != checksum || (sealed != nil) != e.encryptContent {
+		op.DriveID = ""
+		return x.upload(ctx, op)
+	}
+
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, op.Path)
+	if err != nil {
+		return err
+	}
+	named := rec
+	if named == nil {
+		named = &storage.FileRecord{Path: op.Path}
+	}
+	meta := driveapi.FileMeta{}
+	if meta.Name, err = e.UploadName(named); err != nil {
+		return err
+	}
+	parent, err := x.driveFolder(ctx, remote, path.Dir(op.Path))
+	if err != nil {
+		return err
+	}
+	meta.Parents = []string{parent}
+	file, err := copier.Copy(ctx, op.DriveID, meta)
+	if err != nil {
+		return err
+	}
+	return x.recordUpload(ctx, op.Path, rec, file, localFile{checksum: checksum, fast: fast, size: info.Size()}, "COPY")
+}
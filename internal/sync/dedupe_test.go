@@ -0,0 +1,176 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+)
+
+func TestDuplicateLocalFileBecomesCopy(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	trackProjection(t, e, "file-1", "a.txt", "drive-a", "", "")
+	checksum, _, err := fileChecksum(e.absPath("a.txt"))
+	if err != nil {
+		t.Fatalf("fileChecksum: %v", err)
+	}
+	rec, _ := e.Store.GetFileByPath(ctx, e.accountID, "a.txt")
+	rec.Checksum = checksum
+	if err := e.Store.UpsertFile(ctx, rec); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+
+	if err := os.WriteFile(e.absPath("copy.txt"), []byte("content"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := e.noteCreate(ctx, "copy.txt"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+	ops, err := e.Store.ListPendingOps(ctx, e.accountID, "", 0)
+	if err != nil {
+		t.Fatalf("ListPendingOps: %v", err)
+	}
+	if len(ops) != 1 || ops[0].OpType != opCopy || ops[0].DriveID != "drive-a" {
+		t.Fatalf("expected copy from drive-a, got %#v", ops)
+	}
+}
+
+func TestExecutorCopiesDuplicateOnDrive(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	trackProjection(t, e, "file-1", "a.txt", "drive-a", "", "")
+	checksum, _, err := fileChecksum(e.absPath("a.txt"))
+	if err != nil {
+		t.Fatalf("fileChecksum: %v", err)
+	}
+	rec, _ := e.Store.GetFileByPath(ctx, e.accountID, "a.txt")
+	rec.Checksum = checksum
+	if err := e.Store.UpsertFile(ctx, rec); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+	drive := newFakeDrive()
+	drive.files["drive-a"] = driveapi.File{ID: "drive-a", Name: "a.txt", MD5Checksum: checksum, Size: 7}
+	drive.content["drive-a"] = "content"
+	x := newTestExecutor(t, e, drive)
+
+	for _, name := range []string{"copy.txt", "other.txt"} {
+		if err := os.WriteFile(e.absPath(name), []byte("content"), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		if err := e.noteCreate(ctx, name); err != nil {
+			t.Fatalf("noteCreate: %v", err)
+		}
+	}
+	// Changed after the copy was planned, so it has to be uploaded.
+	if err := os.WriteFile(e.absPath("other.txt"), []byte("changed"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 2 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+
+	if drive.calls["Copy"] != 1 || drive.calls["Upload"] != 1 {
+		t.Fatalf("expected one copy and one upload, got %v", drive.calls)
+	}
+	copied, _ := e.Store.GetFileByPath(ctx, e.accountID, "copy.txt")
+	if copied == nil || copied.DriveID == "" || copied.DriveID == "drive-a" || copied.Checksum != checksum {
+		t.Fatalf("expected the copy indexed under its own Drive file, got %#v", copied)
+	}
+	if file := drive.files[copied.DriveID]; file.Name != "copy.txt" || len(file.Parents) != 1 || file.Parents[0] != "root" {
+		t.Fatalf("expected the copy named and placed on Drive, got %#v", file)
+	}
+	if other, _ := e.Store.GetFileByPath(ctx, e.accountID, "other.txt"); other == nil || drive.content[other.DriveID] != "changed" {
+		t.Fatalf("expected the changed file uploaded, got %#v", other)
+	}
+}
+
+func TestRemoteFileReusesLocalContent(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	trackProjection(t, e, "file-1", "a.txt", "drive-a", "", "")
+	checksum, size, err := fileChecksum(e.absPath("a.txt"))
+	if err != nil {
+		t.Fatalf("fileChecksum: %v", err)
+	}
+	rec, _ := e.Store.GetFileByPath(ctx, e.accountID, "a.txt")
+	rec.Checksum = checksum
+	if err := e.Store.UpsertFile(ctx, rec); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+
+	change := RemoteChange{DriveID: "drive-b", Path: "docs/b.txt", Checksum: checksum, Size: size}
+	if err := e.ApplyRemoteChange(ctx, change); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 0 {
+		t.Fatalf("expected no download, got %v", got)
+	}
+	data, err := os.ReadFile(e.absPath("docs/b.txt"))
+	if err != nil || string(data) != "content" {
+		t.Fatalf("expected content copied locally, got %q, %v", data, err)
+	}
+	created, err := e.Store.GetFileByDriveID(ctx, e.accountID, "drive-b")
+	if err != nil || created == nil || created.Path != "docs/b.txt" || created.Checksum != checksum {
+		t.Fatalf("expected record for reused content, got %#v, %v", created, err)
+	}
+}
+
+func TestLocalUnchangedRemembersFastHash(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	trackFile(t, e, "a.txt", "drive-a")
+	checksum, size, err := fileChecksum(e.absPath("a.txt"))
+	if err != nil {
+		t.Fatalf("fileChecksum: %v", err)
+	}
+	rec, _ := e.Store.GetFileByPath(ctx, e.accountID, "a.txt")
+	rec.Checksum, rec.Size = checksum, size
+	if err := e.Store.UpsertFile(ctx, rec); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+	unchanged := func() bool {
+		t.Helper()
+		rec, _ := e.Store.GetFileByPath(ctx, e.accountID, "a.txt")
+		info, err := os.Lstat(e.absPath("a.txt"))
+		if err != nil {
+			t.Fatalf("Lstat: %v", err)
+		}
+		ok, err := e.localUnchanged(ctx, rec, info)
+		if err != nil {
+			t.Fatalf("localUnchanged: %v", err)
+		}
+		return ok
+	}
+
+	if !unchanged() {
+		t.Fatal("expected matching content to be unchanged")
+	}
+	rec, _ = e.Store.GetFileByPath(ctx, e.accountID, "a.txt")
+	if fast, _ := fileFastHash(e.absPath("a.txt")); rec.FastHash == "" || rec.FastHash != fast {
+		t.Fatalf("expected fast digest recorded, got %q", rec.FastHash)
+	}
+	// With the fast digest known, MD5 is no longer consulted.
+	rec.Checksum = "stale"
+	if err := e.Store.UpsertFile(ctx, rec); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+	if !unchanged() {
+		t.Fatal("expected the fast digest to decide")
+	}
+	if err := os.WriteFile(e.absPath("a.txt"), []byte("CONTENT"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if unchanged() {
+		t.Fatal("expected same-size edit to be detected")
+	}
+
+	// New remote content invalidates the digest.
+	if err := e.recordRemote(ctx, RemoteChange{DriveID: "drive-a", Checksum: "ABC", Size: 7}, "a.txt", rec); err != nil {
+		t.Fatalf("recordRemote: %v", err)
+	}
+	if rec, _ := e.Store.GetFileByPath(ctx, e.accountID, "a.txt"); rec.FastHash != "" || rec.Checksum != "abc" {
+		t.Fatalf("expected fast digest cleared, got %#v", rec)
+	}
+}
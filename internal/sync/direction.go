@@ -0,0 +1,60 @@
+package sync
+
+import "github.com/sandeepkv93/googlysync/internal/errs"
+
+// Direction controls which side of a sync root is authoritative.
+type Direction string
+
+const (
+	// DirectionBidirectional propagates changes both ways.
+	DirectionBidirectional Direction = "bidirectional"
+	// DirectionUploadOnly backs local files up to Drive; local deletes are
+	// not propagated and remote changes are ignored.
+	DirectionUploadOnly Direction = "upload-only"
+	// DirectionDownloadOnly keeps a read-only mirror of Drive; local changes
+	// are never uploaded.
+	DirectionDownloadOnly Direction = "download-only"
+	// DirectionMirror makes Drive an exact copy of the local root, including
+	// deletes; remote changes are ignored.
+	DirectionMirror Direction = "mirror"
+	// DirectionBackup uploads periodic point-in-time snapshots instead of
+	// mirroring live changes; remote changes are ignored.
+	DirectionBackup Direction = "backup"
+)
+
+// ParseDirection validates a configured direction. Empty means bidirectional.
+func ParseDirection(val string) (Direction, error) {
+	switch d := Direction(val); d {
+	case "":
+		return DirectionBidirectional, nil
+	case DirectionBidirectional, DirectionUploadOnly, DirectionDownloadOnly, DirectionMirror, DirectionBackup:
+		return d, nil
+	default:
+		return "", errs.New(errs.ErrInvalidArgument, "unknown sync direction %q", val)
+	}
+}
+
+// pushesLocal reports whether local changes are planned as they happen.
+// Backup mode captures them in snapshots instead.
+func (d Direction) pushesLocal() bool {
+	return d != DirectionDownloadOnly && d != DirectionBackup
+}
+
+// pullsRemote reports whether remote changes are planned at all.
+func (d Direction) pullsRemote() bool {
+	return d == DirectionBidirectional || d == DirectionDownloadOnly
+}
+
+// allows reports whether the planner may enqueue an op of the given type.
+func (d Direction) allows(opType string) bool {
+	switch opType {
+	case opUpload, opMove, opCopy, opShortcut, opCreateFolder:
+		return d.pushesLocal()
+	case opDelete, opUnlink, opDeleteFolder:
+		return d == DirectionBidirectional || d == DirectionMirror
+	case opDownload, opDeleteLocal, opLinkLocal:
+		return d.pullsRemote()
+	default:
+		return true
+	}
+}
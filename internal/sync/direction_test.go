@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseDirection(t *testing.T) {
+	if d, err := ParseDirection(""); err != nil || d != DirectionBidirectional {
+		t.Fatalf("expected default bidirectional, got %q, %v", d, err)
+	}
+	if _, err := ParseDirection("sideways"); err == nil {
+		t.Fatal("expected error for unknown direction")
+	}
+}
+
+func TestDirectionAllows(t *testing.T) {
+	cases := map[Direction][]string{
+		DirectionBidirectional: {opUpload, opMove, opDelete, opDownload, opDeleteLocal},
+		DirectionUploadOnly:    {opUpload, opMove},
+		DirectionMirror:        {opUpload, opMove, opDelete},
+		DirectionDownloadOnly:  {opDownload, opDeleteLocal},
+		DirectionBackup:        {},
+	}
+	all := []string{opUpload, opMove, opDelete, opDownload, opDeleteLocal}
+	for dir, allowed := range cases {
+		want := make(map[string]bool)
+		for _, op := range allowed {
+			want[op] = true
+		}
+		for _, op := range all {
+			if got := dir.allows(op); got != want[op] {
+				t.Fatalf("%s allows(%s) = %v, want %v", dir, op, got, want[op])
+			}
+		}
+	}
+}
+
+func TestUploadOnlyKeepsRemoteOnLocalDelete(t *testing.T) {
+	e := newTestEngine(t)
+	e.direction = DirectionUploadOnly
+	ctx := context.Background()
+	trackFile(t, e, "a.txt", "drive-a")
+
+	if err := os.Remove(e.absPath("a.txt")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := e.noteRemoval(ctx, "a.txt"); err != nil {
+		t.Fatalf("noteRemoval: %v", err)
+	}
+	e.flushRemovals(ctx, time.Now().Add(2*renameWindow))
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "drive-b", Path: "b.txt", Size: 1}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 0 {
+		t.Fatalf("expected no ops in upload-only mode, got %v", got)
+	}
+}
@@ -0,0 +1,196 @@
+package sync
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/encryption"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// KeySource supplies the key content and names are sealed under.
+type KeySource interface {
+	Key(accountID string) ([]byte, error)
+}
+
+func (e *Engine) accountKey() ([]byte, error) {
+	return accountKey(e.Keys, e.accountID)
+}
+
+func accountKey(keys KeySource, accountID string) ([]byte, error) {
+	if keys == nil {
+		return nil, errs.New(errs.ErrNotFound, "no encryption key available for account %q", accountID)
+	}
+	return keys.Key(accountID)
+}
+
+// UploadName returns the name rec should have in Drive, sealed when name
+// encryption is on. Files already in Drive keep the name they have.
+func (e *Engine) UploadName(rec *storage.FileRecord) (string, error) {
+	if rec.RemoteName != "" || !e.encryptNames {
+		return DriveName(rec), nil
+	}
+	key, err := e.accountKey()
+	if err != nil {
+		return "", err
+	}
+	return encryption.EncryptName(key, DecodeName(path.Base(rec.Path)))
+}
+
+// RecordSealedUpload keeps the plaintext checksum and size of an encrypted
+// upload of rel, so the ciphertext checksum Drive reports for driveID is
+// recognized as the indexed content.
+func (e *Engine) RecordSealedUpload(ctx context.Context, rel, driveID, cipherMD5 string) error {
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, rel)
+	if err != nil {
+		return err
+	}
+	var sum string
+	var size int64
+	if rec != nil && rec.Checksum != "" {
+		sum, size = rec.Checksum, rec.Size
+	} else if sum, size, err = fileChecksum(e.absPath(rel)); err != nil {
+		return err
+	}
+	file := &storage.EncryptedFile{
+		AccountID: e.accountID,
+		DriveID:   driveID,
+		PlainMD5:  sum,
+		PlainSize: size,
+		CipherMD5: strings.ToLower(cipherMD5),
+	}
+	if e.encryptNames {
+		file.PlainName = DecodeName(path.Base(rel))
+	}
+	return e.Store.SaveEncryptedFile(ctx, file)
+}
+
+// openSealedChange rewrites a change to an encrypted file in plaintext
+// terms. When the ciphertext is the one last recorded, the recorded
+// plaintext checksum and size stand in for Drive's; otherwise only the size
+// is adjusted, so the content is seen as changed.
+func (e *Engine) openSealedChange(ctx context.Context, change RemoteChange) (RemoteChange, error) {
+	if change.Removed {
+		return change, e.Store.DeleteEncryptedFile(ctx, e.accountID, change.DriveID)
+	}
+	sealed, err := e.Store.GetEncryptedFile(ctx, e.accountID, change.DriveID)
+	if err != nil || sealed == nil {
+		return change, err
+	}
+	if change.Checksum != "" && strings.EqualFold(change.Checksum, sealed.CipherMD5) {
+		change.Checksum, change.Size = sealed.PlainMD5, sealed.PlainSize
+		return change, nil
+	}
+	if size, err := encryption.PlainSize(change.Size); err == nil {
+		change.Size = size
+	}
+	return change, nil
+}
+
+// openName returns the plaintext of a Drive name sealed under the account
+// key. Names that are not sealed, or cannot be opened, are returned as is.
+func (e *Engine) openName(name string) string {
+	if !e.encryptNames {
+		return name
+	}
+	key, err := e.accountKey()
+	if err != nil {
+		return name
+	}
+	if plain, err := encryption.DecryptName(key, name); err == nil {
+		return plain
+	}
+	return name
+}
+
+// remoteContent is Drive content ready to be written locally.
+type remoteContent struct {
+	io.Reader
+	// Size is the length of the plaintext, or -1 when it is not known ahead
+	// of the download.
+	Size int64
+	// Checksum is the MD5 of the plaintext, or empty when it is not known
+	// ahead of the download.
+	Checksum string
+	// Sealed is set when Drive holds the content encrypted.
+	Sealed bool
+}
+
+// openSealed decrypts body when it is sealed content and reports whether it
+// was. Encrypted files are recognized by their header, so they are opened
+// even after content encryption is turned off.
+func openSealed(body io.Reader, keys KeySource, accountID string) (io.Reader, bool, error) {
+	br := bufio.NewReaderSize(body, encryption.HeaderSize)
+	header, err := br.Peek(len(encryption.Magic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, false, err
+	}
+	if !encryption.IsEncrypted(header) {
+		return br, false, nil
+	}
+	key, err := accountKey(keys, accountID)
+	if err != nil {
+		return nil, false, err
+	}
+	plain, err := encryption.NewDecrypter(br, key)
+	if err != nil {
+		return nil, false, err
+	}
+	return plain, true, nil
+}
+
+// openContent prepares a download of file, decrypting and then
+// decompressing it as needed.
+func openContent(ctx context.Context, store storage.Store, keys KeySource, accountID string, file *driveapi.File, body io.Reader) (*remoteContent, error) {
+	plain, sealed, err := openSealed(body, keys, accountID)
+	if err != nil {
+		return nil, err
+	}
+	content := &remoteContent{Reader: plain, Size: file.Size, Checksum: file.MD5Checksum, Sealed: sealed}
+	if sealed {
+		if content.Size, err = encryption.PlainSize(file.Size); err != nil {
+			return nil, err
+		}
+		content.Checksum = ""
+		known, err := store.GetEncryptedFile(ctx, accountID, file.ID)
+		if err != nil {
+			return nil, err
+		}
+		if known != nil && file.MD5Checksum != "" && strings.EqualFold(known.CipherMD5, file.MD5Checksum) {
+			content.Checksum = known.PlainMD5
+		}
+	}
+	if compressedOn(file) {
+		r, size, sum, err := uncompressed(file, content.Reader)
+		if err != nil {
+			return nil, err
+		}
+		content.Reader, content.Size = r, size
+		if !sealed {
+			content.Checksum = sum
+		}
+	}
+	return content, nil
+}
+
+// recordSealedDownload keeps the plaintext checksum and size of encrypted
+// content just downloaded.
+func (e *Engine) recordSealedDownload(ctx context.Context, file *driveapi.File, content *remoteContent) error {
+	sealed := &storage.EncryptedFile{
+		AccountID: e.accountID,
+		DriveID:   file.ID,
+		PlainMD5:  content.Checksum,
+		PlainSize: content.Size,
+		CipherMD5: strings.ToLower(file.MD5Checksum),
+	}
+	if plain := e.openName(file.Name); plain != file.Name {
+		sealed.PlainName = plain
+	}
+	return e.Store.SaveEncryptedFile(ctx, sealed)
+}
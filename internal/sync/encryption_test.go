@@ -0,0 +1,189 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/encryption"
+)
+
+type staticKey []byte
+
+func (k staticKey) Key(string) ([]byte, error) { return k, nil }
+
+func newEncryptingEngine(t *testing.T) (*Engine, []byte) {
+	t.Helper()
+	e := newTestEngine(t)
+	key, err := encryption.NewKey()
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+	e.Keys = staticKey(key)
+	e.encryptContent, e.encryptNames = true, true
+	return e, key
+}
+
+func TestSealedUploadIsRecognizedOnDrive(t *testing.T) {
+	e, key := newEncryptingEngine(t)
+	ctx := context.Background()
+	indexFile(t, e, "notes.txt", "d-notes", "secret")
+
+	upload, err := e.UploadContent("notes.txt")
+	if err != nil {
+		t.Fatalf("UploadContent: %v", err)
+	}
+	sealed, err := io.ReadAll(upload)
+	_ = upload.Close()
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	size := upload.Size
+	if int64(len(sealed)) != size || !encryption.IsEncrypted(sealed) || bytes.Contains(sealed, []byte("secret")) {
+		t.Fatalf("expected %d sealed bytes, got %d", size, len(sealed))
+	}
+	plain, err := encryption.NewDecrypter(bytes.NewReader(sealed), key)
+	if err != nil {
+		t.Fatalf("NewDecrypter: %v", err)
+	}
+	if got, _ := io.ReadAll(plain); string(got) != "secret" {
+		t.Fatalf("expected content to round trip, got %q", got)
+	}
+
+	rec, _ := e.Store.GetFileByPath(ctx, e.accountID, "notes.txt")
+	name, err := e.UploadName(rec)
+	if err != nil || name == "notes.txt" {
+		t.Fatalf("expected a sealed name, got %q (%v)", name, err)
+	}
+	if got := e.LocalPath("", name); got != "notes.txt" {
+		t.Fatalf("expected sealed name to map back to notes.txt, got %q", got)
+	}
+
+	if err := e.RecordSealedUpload(ctx, "notes.txt", "d-notes", md5Hex(string(sealed))); err != nil {
+		t.Fatalf("RecordSealedUpload: %v", err)
+	}
+	change := RemoteChange{DriveID: "d-notes", Path: "notes.txt", Checksum: md5Hex(string(sealed)), Size: size}
+	if err := e.ApplyRemoteChange(ctx, change); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if ops := opTypes(t, e); len(ops) != 0 {
+		t.Fatalf("expected our own upload to need nothing, got %v", ops)
+	}
+
+	change.Checksum = md5Hex("other ciphertext")
+	if err := e.ApplyRemoteChange(ctx, change); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if ops := opTypes(t, e); len(ops) != 1 || ops[0] != opDownload+" notes.txt" {
+		t.Fatalf("expected new ciphertext to be downloaded, got %v", ops)
+	}
+}
+
+func TestExecutorUploadsSealedContent(t *testing.T) {
+	e, key := newEncryptingEngine(t)
+	ctx := context.Background()
+	if err := os.MkdirAll(e.absPath("docs"), 0o700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(e.absPath("docs/notes.txt"), []byte("secret"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	drive := newFakeDrive()
+	x := newTestExecutor(t, e, drive)
+	if err := e.addOp(ctx, opUpload, "docs/notes.txt", ""); err != nil {
+		t.Fatalf("addOp: %v", err)
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, "docs/notes.txt")
+	if err != nil || rec == nil || rec.DriveID == "" || rec.Checksum != md5Hex("secret") || rec.Size != 6 {
+		t.Fatalf("expected the upload recorded in plaintext terms, got %#v, %v", rec, err)
+	}
+	file := drive.files[rec.DriveID]
+	folder, err := e.Store.GetFolderByPath(ctx, e.accountID, "docs")
+	if err != nil || folder == nil || len(file.Parents) != 1 || file.Parents[0] != folder.DriveID {
+		t.Fatalf("expected the file in the created docs folder, got %#v under %#v, %v", file, folder, err)
+	}
+	if got := e.LocalPath("", file.Name); file.Name == "notes.txt" || got != "notes.txt" {
+		t.Fatalf("expected a sealed name for notes.txt, got %q", file.Name)
+	}
+	plain, err := encryption.NewDecrypter(strings.NewReader(drive.content[rec.DriveID]), key)
+	if err != nil {
+		t.Fatalf("NewDecrypter: %v", err)
+	}
+	if got, _ := io.ReadAll(plain); string(got) != "secret" {
+		t.Fatalf("expected sealed content on Drive, got %q", got)
+	}
+	info, err := e.Store.GetEncryptedFile(ctx, e.accountID, rec.DriveID)
+	if err != nil || info == nil || info.CipherMD5 != file.MD5Checksum || info.PlainMD5 != md5Hex("secret") {
+		t.Fatalf("expected the sealed upload recorded, got %#v, %v", info, err)
+	}
+
+	// The echo from the changes feed is recognized as the indexed content.
+	if err := e.ApplyRemoteChange(ctx, remoteChangeOf(file, "docs/notes.txt")); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if ops := opTypes(t, e); len(ops) != 0 {
+		t.Fatalf("expected our own upload to need nothing, got %v", ops)
+	}
+}
+
+func TestDownloadOpensSealedContent(t *testing.T) {
+	e, key := newEncryptingEngine(t)
+	ctx := context.Background()
+
+	var sealed bytes.Buffer
+	w, err := encryption.NewEncrypter(&sealed, key)
+	if err != nil {
+		t.Fatalf("NewEncrypter: %v", err)
+	}
+	_, _ = w.Write([]byte("hello"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	name, err := encryption.EncryptName(key, "a.txt")
+	if err != nil {
+		t.Fatalf("EncryptName: %v", err)
+	}
+	remote := fakeRemote{
+		fakeContent: fakeContent{"drive-a": sealed.String()},
+		files: map[string]driveapi.File{"drive-a": {
+			ID:          "drive-a",
+			Name:        name,
+			MD5Checksum: md5Hex(sealed.String()),
+			Size:        int64(sealed.Len()),
+		}},
+	}
+	x := newTestExecutor(t, e, remote)
+	if err := e.addDownload(ctx, "docs/a.txt", "drive-a", int64(sealed.Len())); err != nil {
+		t.Fatalf("addDownload: %v", err)
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, "docs/a.txt")
+	if err != nil || rec == nil || rec.Checksum != md5Hex("hello") || rec.Size != 5 {
+		t.Fatalf("expected plaintext recorded, got %#v, %v", rec, err)
+	}
+	info, err := e.Store.GetEncryptedFile(ctx, e.accountID, "drive-a")
+	if err != nil || info == nil || info.CipherMD5 != md5Hex(sealed.String()) || info.PlainName != "a.txt" {
+		t.Fatalf("expected encrypted metadata saved, got %#v, %v", info, err)
+	}
+
+	// Without the key the content cannot be opened.
+	e.Keys = nil
+	if err := e.addDownload(ctx, "docs/b.txt", "drive-a", int64(sealed.Len())); err != nil {
+		t.Fatalf("addDownload: %v", err)
+	}
+	_, _ = x.RunOnce(ctx)
+	if _, err := os.Lstat(e.absPath("docs/b.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing written without the key, got %v", err)
+	}
+}
@@ -0,0 +1,565 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/notify"
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+	"github.com/sandeepkv93/googlysync/internal/transfer"
+)
+
+// opPollInterval is how often the executor looks for queued ops when the
+// engine has not signalled new ones.
+const opPollInterval = 30 * time.Second
+
+// A failed op waits opRetryBase before its first retry, doubling with every
+// further failure up to opRetryMax. After the configured number of failures
+// it is parked in the failed state.
+const (
+	opRetryBase         = 30 * time.Second
+	opRetryMax          = time.Hour
+	defaultOpMaxRetries = 5
+)
+
+// RemoteFiles reads file metadata and content from Drive.
+type RemoteFiles interface {
+	ContentSource
+	GetFile(ctx context.Context, id string) (*driveapi.File, error)
+}
+
+// RemoteFunc returns a Drive client for the signed-in account, or nil when no
+// account is signed in.
+type RemoteFunc func(ctx context.Context) RemoteFiles
+
+// opHandler executes one kind of pending op. recover settles an op of this
+// kind that a previous run left in_progress: it reports true when the op's
+// effect is already complete. Without recover the op is always run again.
+type opHandler struct {
+	execute func(ctx context.Context, op storage.PendingOp) error
+	recover func(ctx context.Context, op storage.PendingOp) (bool, error)
+}
+
+// Executor carries out the ops the engine plans. Each op is claimed in the
+// pending_ops journal before it runs and deleted once it has taken effect, so
+// an op interrupted by a crash is still in_progress on the next start.
+type Executor struct {
+	logger   *zap.Logger
+	engine   *Engine
+	remotes  RemoteFunc
+	handlers map[string]opHandler
+
+	maxRetries int
+	now        func() time.Time
+	freeSpace  func(path string) (int64, error)
+	// lowSpace is set while downloads are held for lack of disk space.
+	lowSpace bool
+	// quotaFull is set while uploads are held because Drive is full.
+	quotaFull bool
+	// quotaAlerted is set while usage is above the alert threshold.
+	quotaAlerted bool
+	// completed counts ops done since the queue last drained.
+	completed int
+	// snapshotFolders caches the Drive folders of snapshots by their
+	// appPropSnapshotFolder value.
+	snapshotFolders map[string]string
+}
+
+// NewExecutor constructs an executor for the engine's account with a handler
+// for every op type the engine plans.
+func NewExecutor(logger *zap.Logger, engine *Engine, remotes RemoteFunc) *Executor {
+	x := &Executor{logger: logger, engine: engine, remotes: remotes, maxRetries: defaultOpMaxRetries, now: time.Now, freeSpace: transfer.FreeSpace, snapshotFolders: map[string]string{}}
+	if engine.Config != nil && engine.Config.OpMaxRetries > 0 {
+		x.maxRetries = engine.Config.OpMaxRetries
+	}
+	x.handlers = map[string]opHandler{
+		opDownload:     {execute: x.download},
+		opDeleteLocal:  {execute: x.deleteLocal, recover: x.recoverDeleteLocal},
+		opUpload:       {execute: x.upload},
+		opMove:         {execute: x.move},
+		opCopy:         {execute: x.copyRemote},
+		opCreateFolder: {execute: x.createFolder},
+		opDeleteFolder: {execute: x.deleteFolder},
+		opLinkLocal:    {execute: x.linkLocal},
+		opUnlink:       {execute: x.unlink},
+		opShortcut:     {execute: x.shortcut},
+		opDelete:       {execute: x.deleteRemote},
+		opRestore:      {execute: x.restore},
+	}
+	return x
+}
+
+// Run recovers interrupted ops and then executes queued ops as the engine
+// plans them, until ctx is done. The Drive quota is refreshed on start and
+// then periodically.
+func (x *Executor) Run(ctx context.Context) {
+	if err := x.Recover(ctx); err != nil {
+		x.logger.Warn("recover pending ops failed", zap.Error(err))
+	}
+	if err := x.LoadQuota(ctx); err != nil {
+		x.logger.Warn("load stored quota failed", zap.Error(err))
+	}
+	x.refreshQuota(ctx)
+	ticker := time.NewTicker(opPollInterval)
+	defer ticker.Stop()
+	quotaTicker := time.NewTicker(x.quotaPoll())
+	defer quotaTicker.Stop()
+	for {
+		if _, err := x.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			x.logger.Warn("execute pending ops failed", zap.Error(err))
+		}
+		if _, err := x.uploadSnapshots(ctx); err != nil && ctx.Err() == nil {
+			x.logger.Warn("upload snapshots failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-x.engine.opsReady:
+		case <-ticker.C:
+		case <-quotaTicker.C:
+			x.refreshQuota(ctx)
+		}
+	}
+}
+
+func (x *Executor) refreshQuota(ctx context.Context) {
+	if err := x.CheckQuota(ctx); err != nil && ctx.Err() == nil {
+		x.logger.Warn("drive quota check failed", zap.Error(err))
+	}
+}
+
+// Recover settles ops left in_progress by a previous run. An op whose effect
+// is already complete is removed from the journal; every other one is rolled
+// back to queued so it runs again. Partial files of interrupted downloads are
+// deleted first.
+func (x *Executor) Recover(ctx context.Context) error {
+	e := x.engine
+	if e.Downloads != nil {
+		n, err := e.Downloads.RemovePartials()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			x.logger.Info("removed partial downloads", zap.Int("count", n))
+		}
+	}
+	ops, err := e.Store.ListPendingOps(ctx, e.accountID, storage.OpInProgress, 0)
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		if h, ok := x.handlers[op.OpType]; ok && h.recover != nil {
+			done, err := h.recover(ctx, op)
+			if err != nil {
+				return fmt.Errorf("recover %s %s: %w", op.OpType, op.Path, err)
+			}
+			if done {
+				if err := e.Store.DeletePendingOp(ctx, op.ID); err != nil {
+					return err
+				}
+				x.logger.Info("interrupted op already applied", zap.String("op", op.OpType), zap.String("path", op.Path))
+				continue
+			}
+		}
+		if err := e.Store.UpdatePendingOp(ctx, op.ID, storage.OpQueued, op.RetryCount, "interrupted"); err != nil {
+			return err
+		}
+		x.logger.Info("interrupted op requeued", zap.String("op", op.OpType), zap.String("path", op.Path))
+	}
+	return nil
+}
+
+// RunOnce executes the queued ops it has handlers for and returns how many
+// completed. Ops are claimed one at a time, in the order they were planned,
+// as they become ready: after the op they depend on and any earlier op for
+// the same path. It stops early while the engine is paused. A failed op goes
+// back to the queue with its error and retry count recorded, and is held
+// until its backoff has passed. Downloads wait while the sync root lacks space for
+// all of them, and uploads while the Drive account is full. An op that hits
+// the Drive quota marks the account full and is queued again without using a
+// retry; one refused for a scope the account has not granted holds syncing
+// for a new consent the same way. In audit mode nothing runs.
+func (x *Executor) RunOnce(ctx context.Context) (int, error) {
+	e := x.engine
+	if e.auditOnly {
+		return 0, nil
+	}
+	if err := x.reportFailed(ctx); err != nil {
+		return 0, err
+	}
+	holdDownloads, err := x.checkSpace(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	done := 0
+	var after int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+		if e.Paused() {
+			return done, nil
+		}
+		types := x.runnableTypes(holdDownloads)
+		if len(types) == 0 {
+			break
+		}
+		op, err := e.Store.ClaimNextReady(ctx, e.accountID, storage.ReadyFilter{OpTypes: types, AfterSeq: after, Now: x.now()})
+		if err != nil {
+			return done, err
+		}
+		if op == nil {
+			break
+		}
+		after = op.Seq
+		started := x.now()
+		if err := x.handlers[op.OpType].execute(ctx, *op); err != nil {
+			if ctx.Err() != nil {
+				// Left in_progress; Recover settles it on the next start.
+				return done, ctx.Err()
+			}
+			if errs.KindOf(err) == errs.ErrQuotaExceeded {
+				x.setQuotaFull(true, "drive storage full; uploads held")
+				if err := e.Store.UpdatePendingOp(ctx, op.ID, storage.OpQueued, op.RetryCount, err.Error()); err != nil {
+					return done, err
+				}
+				continue
+			}
+			if errs.KindOf(err) == errs.ErrScopeMissing {
+				// Retrying cannot help until the account grants the scope.
+				e.HoldForConsent(err.Error())
+				if err := e.Store.UpdatePendingOp(ctx, op.ID, storage.OpQueued, op.RetryCount, err.Error()); err != nil {
+					return done, err
+				}
+				continue
+			}
+			lost, checkErr := x.noteFolderAccess(ctx, op.Path, err)
+			if checkErr != nil {
+				return done, checkErr
+			}
+			if lost {
+				// The folder's subtree is no longer synced until it is detached.
+				if err := e.Store.DeletePendingOp(ctx, op.ID); err != nil {
+					return done, err
+				}
+				continue
+			}
+			if err := x.fail(ctx, *op, started, err); err != nil {
+				return done, err
+			}
+			continue
+		}
+		if err := e.Store.DeletePendingOp(ctx, op.ID); err != nil {
+			return done, err
+		}
+		e.recordHistory(ctx, storage.HistoryEntry{Op: op.OpType, Path: op.Path, DriveID: op.DriveID, Bytes: op.Size,
+			Outcome: storage.HistorySucceeded, StartedAt: started, FinishedAt: x.now()})
+		done++
+	}
+	return done, x.noteCompleted(ctx, done)
+}
+
+// runnableTypes lists the op types the executor has handlers for, less the
+// ones held for disk space or Drive quota.
+func (x *Executor) runnableTypes(holdDownloads bool) []string {
+	types := make([]string, 0, len(x.handlers))
+	for opType := range x.handlers {
+		if opType == opDownload && holdDownloads {
+			continue
+		}
+		if quotaOpTypes[opType] && x.quotaFull {
+			continue
+		}
+		types = append(types, opType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// noteCompleted fires the sync completed webhook once the ops done since the
+// queue last drained have left nothing queued or running.
+func (x *Executor) noteCompleted(ctx context.Context, done int) error {
+	x.completed += done
+	e := x.engine
+	if x.completed == 0 || e.Webhooks == nil {
+		return nil
+	}
+	queued, err := e.Store.CountPendingOps(ctx, e.accountID, storage.OpQueued)
+	if err != nil {
+		return err
+	}
+	running, err := e.Store.CountPendingOps(ctx, e.accountID, storage.OpInProgress)
+	if err != nil {
+		return err
+	}
+	if queued+running > 0 {
+		return nil
+	}
+	failed, err := e.Store.CountPendingOps(ctx, e.accountID, storage.OpFailed)
+	if err != nil {
+		return err
+	}
+	e.Webhooks.Fire(e.accountID, notify.EventSyncCompleted, map[string]any{"ops": x.completed, "failed_ops": failed})
+	x.completed = 0
+	return nil
+}
+
+// checkSpace reports whether downloads must wait because the queued ones,
+// plus the configured reserve, would not fit in the free space on the sync
+// root. Entering and leaving that condition is reported in the status.
+func (x *Executor) checkSpace(ctx context.Context) (bool, error) {
+	e := x.engine
+	if e.Paused() {
+		return false, nil
+	}
+	need, err := e.Store.PendingOpBytes(ctx, e.accountID, opDownload)
+	if err != nil {
+		return false, err
+	}
+	if need == 0 {
+		x.setLowSpace(false, "")
+		return false, nil
+	}
+	free, err := x.freeSpace(e.Config.SyncRoot)
+	if err != nil {
+		if !errors.Is(err, transfer.ErrFreeSpaceUnsupported) {
+			x.logger.Warn("free space check failed", zap.Error(err))
+		}
+		return false, nil
+	}
+	reserve := int64(e.Config.MinFreeSpaceMB) << 20
+	if need+reserve <= free {
+		x.setLowSpace(false, "")
+		return false, nil
+	}
+	x.setLowSpace(true, fmt.Sprintf("low disk space: downloads need %d MiB plus %d MiB reserve, %d MiB free",
+		need>>20, reserve>>20, free>>20))
+	return true, nil
+}
+
+func (x *Executor) setLowSpace(low bool, msg string) {
+	if low == x.lowSpace {
+		return
+	}
+	x.lowSpace = low
+	e := x.engine
+	if low {
+		x.logger.Warn("downloads held for disk space", zap.String("reason", msg))
+	} else {
+		x.logger.Info("disk space available; downloads resumed")
+	}
+	if e.Status == nil {
+		return
+	}
+	if low {
+		e.Status.Update(status.Snapshot{State: status.StateLowDiskSpace, Message: msg})
+	} else if e.Status.Current().State == status.StateLowDiskSpace {
+		e.Status.Update(status.Snapshot{State: status.StateIdle, Message: "disk space available"})
+	}
+}
+
+// fail records a failed attempt. The op is queued for a later retry, or
+// parked as failed once it has used up its retries; only then does it go
+// into the sync history.
+func (x *Executor) fail(ctx context.Context, op storage.PendingOp, started time.Time, cause error) error {
+	e := x.engine
+	retries := op.RetryCount + 1
+	if retries < x.maxRetries {
+		backoff := retryDelay(retries)
+		x.logger.Warn("pending op failed; will retry", zap.String("op", op.OpType), zap.String("path", op.Path),
+			zap.Int("attempt", retries), zap.Duration("backoff", backoff), zap.Error(cause))
+		return e.Store.RequeuePendingOp(ctx, op.ID, retries, cause.Error(), x.now().Add(backoff))
+	}
+	x.logger.Error("pending op gave up", zap.String("op", op.OpType), zap.String("path", op.Path),
+		zap.Int("attempts", retries), zap.Error(cause))
+	if err := e.Store.UpdatePendingOp(ctx, op.ID, storage.OpFailed, retries, cause.Error()); err != nil {
+		return err
+	}
+	e.recordHistory(ctx, storage.HistoryEntry{Op: op.OpType, Path: op.Path, DriveID: op.DriveID, Outcome: storage.HistoryFailed,
+		Error: cause.Error(), StartedAt: started, FinishedAt: x.now()})
+	if e.Status != nil {
+		e.Status.AddEvent(status.Event{Op: "FAILED", Path: op.Path})
+	}
+	return x.reportFailed(ctx)
+}
+
+// reportFailed publishes the number of failed ops in the status snapshot.
+func (x *Executor) reportFailed(ctx context.Context) error {
+	e := x.engine
+	if e.Status == nil {
+		return nil
+	}
+	n, err := e.Store.CountPendingOps(ctx, e.accountID, storage.OpFailed)
+	if err != nil {
+		return err
+	}
+	e.Status.SetFailedOps(n)
+	return nil
+}
+
+// retryDelay is the backoff before the next attempt of an op that has failed
+// retries times.
+func retryDelay(retries int) time.Duration {
+	if retries <= 0 {
+		return 0
+	}
+	d := opRetryBase
+	for i := 1; i < retries; i++ {
+		d *= 2
+		if d >= opRetryMax {
+			return opRetryMax
+		}
+	}
+	return d
+}
+
+// RetryFailed queues failed ops of an account to run again with a fresh
+// retry budget and returns how many were queued. An empty opID retries every
+// failed op.
+func (e *Engine) RetryFailed(ctx context.Context, accountID, opID string) (int, error) {
+	if accountID == "" {
+		accountID = e.accountID
+	}
+	n, err := e.Store.RetryFailedOps(ctx, accountID, opID)
+	if err != nil {
+		return 0, err
+	}
+	if opID != "" && n == 0 {
+		return 0, errs.New(errs.ErrNotFound, "no failed op %s", opID)
+	}
+	if n > 0 {
+		select {
+		case e.opsReady <- struct{}{}:
+		default:
+		}
+	}
+	return int(n), nil
+}
+
+// remote returns the Drive client an op on rel goes through, or an error
+// naming what could not be done when no account is signed in.
+func (x *Executor) remote(ctx context.Context, verb, rel string) (RemoteFiles, error) {
+	var remote RemoteFiles
+	if x.remotes != nil {
+		remote = x.remotes(ctx)
+	}
+	if remote == nil {
+		return nil, errs.New(errs.ErrAuthExpired, "no signed-in account to %s %s", verb, rel)
+	}
+	return remote, nil
+}
+
+// download fetches the current content of op.DriveID into op.Path and records
+// the remote metadata it was fetched at.
+func (x *Executor) download(ctx context.Context, op storage.PendingOp) error {
+	e := x.engine
+	remote, err := x.remote(ctx, "download", op.Path)
+	if err != nil {
+		return err
+	}
+	file, err := remote.GetFile(ctx, op.DriveID)
+	if err != nil {
+		return err
+	}
+	if file.Trashed {
+		x.logger.Debug("skipping download of trashed file", zap.String("path", op.Path))
+		return nil
+	}
+	body, err := remote.Download(ctx, op.DriveID)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	content, err := openContent(ctx, e.Store, e.Keys, e.accountID, file, body)
+	if err != nil {
+		return err
+	}
+	n, fast, err := e.Downloads.Fetch(ctx, op.Path, content.Size, content.Checksum, content)
+	if err != nil {
+		return err
+	}
+	// The finished download is renamed into place; ignore that event.
+	e.suppress(op.Path)
+	if content.Size >= 0 && n != content.Size {
+		return fmt.Errorf("short download: got %d of %d bytes", n, content.Size)
+	}
+	content.Size = n
+	if content.Checksum == "" {
+		if content.Checksum, _, err = fileChecksum(e.absPath(op.Path)); err != nil {
+			return err
+		}
+	}
+	if content.Sealed {
+		if err := e.recordSealedDownload(ctx, file, content); err != nil {
+			return err
+		}
+	}
+
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, op.Path)
+	if err != nil {
+		return err
+	}
+	change := RemoteChange{
+		DriveID:    op.DriveID,
+		Path:       op.Path,
+		Name:       file.Name,
+		Checksum:   content.Checksum,
+		Size:       content.Size,
+		ModifiedAt: file.ModifiedTime,
+		ReadOnly:   file.ReadOnly,
+	}
+	change = withDriveMeta(change, file)
+	if rec != nil {
+		change.ETag = rec.ETag
+	}
+	if err := e.recordRemote(ctx, change, op.Path, rec); err != nil {
+		return err
+	}
+	if err := e.Store.SetFileFastHash(ctx, e.accountID, op.Path, fast); err != nil {
+		return err
+	}
+	if err := e.Store.ClearPlaceholder(ctx, e.accountID, op.Path); err != nil {
+		return err
+	}
+	if e.Status != nil {
+		e.Status.AddEvent(status.Event{Op: "DOWNLOAD", Path: op.Path})
+	}
+	return nil
+}
+
+// deleteLocal removes a local file whose remote copy was deleted.
+func (x *Executor) deleteLocal(ctx context.Context, op storage.PendingOp) error {
+	e := x.engine
+	e.suppress(op.Path)
+	if err := os.Remove(e.absPath(op.Path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := e.Store.DeleteFile(ctx, e.accountID, op.Path); err != nil {
+		return err
+	}
+	if e.Status != nil {
+		e.Status.AddEvent(status.Event{Op: "DELETE_LOCAL", Path: op.Path})
+	}
+	return nil
+}
+
+// recoverDeleteLocal completes an interrupted delete whose file is already
+// gone by dropping the leftover record.
+func (x *Executor) recoverDeleteLocal(ctx context.Context, op storage.PendingOp) (bool, error) {
+	e := x.engine
+	if _, err := os.Lstat(e.absPath(op.Path)); !errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return true, e.Store.DeleteFile(ctx, e.accountID, op.Path)
+}
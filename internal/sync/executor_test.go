@@ -0,0 +1,675 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/notify"
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+	"github.com/sandeepkv93/googlysync/internal/transfer"
+)
+
+type fakeRemote struct {
+	fakeContent
+	files map[string]driveapi.File
+}
+
+func (f fakeRemote) GetFile(_ context.Context, id string) (*driveapi.File, error) {
+	file := f.files[id]
+	return &file, nil
+}
+
+// fakeDrive is an in-memory Drive that takes the executor's writes.
+type fakeDrive struct {
+	files   map[string]driveapi.File
+	content map[string]string
+	// targets maps shortcuts to the file they point at.
+	targets map[string]string
+	next    int
+	// calls counts the writes made, by method.
+	calls map[string]int
+}
+
+func newFakeDrive() *fakeDrive {
+	return &fakeDrive{files: map[string]driveapi.File{}, content: map[string]string{}, targets: map[string]string{}, calls: map[string]int{}}
+}
+
+func (d *fakeDrive) add(file driveapi.File) driveapi.File {
+	d.next++
+	file.ID = fmt.Sprintf("drive-%d", d.next)
+	d.files[file.ID] = file
+	return file
+}
+
+func (d *fakeDrive) GetFile(_ context.Context, id string) (*driveapi.File, error) {
+	file, ok := d.files[id]
+	if !ok {
+		return nil, &driveapi.APIError{Status: http.StatusNotFound}
+	}
+	return &file, nil
+}
+
+func (d *fakeDrive) Download(_ context.Context, id string) (io.ReadCloser, error) {
+	if _, ok := d.files[id]; !ok {
+		return nil, &driveapi.APIError{Status: http.StatusNotFound}
+	}
+	return io.NopCloser(strings.NewReader(d.content[id])), nil
+}
+
+func (d *fakeDrive) Upload(_ context.Context, id string, meta driveapi.FileMeta, content io.Reader) (*driveapi.File, error) {
+	d.calls["Upload"]++
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+	file, ok := d.files[id]
+	if id == "" {
+		file = d.add(driveapi.File{Name: meta.Name, Parents: meta.Parents})
+	} else if !ok {
+		return nil, &driveapi.APIError{Status: http.StatusNotFound}
+	}
+	for k, v := range meta.AppProperties {
+		if file.AppProperties == nil {
+			file.AppProperties = map[string]string{}
+		}
+		file.AppProperties[k] = v
+	}
+	file.MD5Checksum, file.Size = md5Hex(string(data)), int64(len(data))
+	d.files[file.ID], d.content[file.ID] = file, string(data)
+	return &file, nil
+}
+
+func (d *fakeDrive) Move(_ context.Context, id, name, fromParent, toParent string) (*driveapi.File, error) {
+	d.calls["Move"]++
+	file, ok := d.files[id]
+	if !ok {
+		return nil, &driveapi.APIError{Status: http.StatusNotFound}
+	}
+	if name != "" {
+		file.Name = name
+	}
+	if toParent != "" && toParent != fromParent {
+		parents := []string{toParent}
+		for _, p := range file.Parents {
+			if p != fromParent {
+				parents = append(parents, p)
+			}
+		}
+		file.Parents = parents
+	}
+	d.files[id] = file
+	return &file, nil
+}
+
+func (d *fakeDrive) Copy(_ context.Context, id string, meta driveapi.FileMeta) (*driveapi.File, error) {
+	d.calls["Copy"]++
+	source, ok := d.files[id]
+	if !ok {
+		return nil, &driveapi.APIError{Status: http.StatusNotFound}
+	}
+	file := d.add(driveapi.File{Name: meta.Name, Parents: meta.Parents, MD5Checksum: source.MD5Checksum, Size: source.Size, AppProperties: source.AppProperties})
+	d.content[file.ID] = d.content[id]
+	return &file, nil
+}
+
+func (d *fakeDrive) RemoveParent(_ context.Context, id, parent string) (*driveapi.File, error) {
+	d.calls["RemoveParent"]++
+	file, ok := d.files[id]
+	if !ok {
+		return nil, &driveapi.APIError{Status: http.StatusNotFound}
+	}
+	file.Parents = slices.DeleteFunc(slices.Clone(file.Parents), func(p string) bool { return p == parent })
+	d.files[id] = file
+	return &file, nil
+}
+
+func (d *fakeDrive) Trash(_ context.Context, id string) (*driveapi.File, error) {
+	d.calls["Trash"]++
+	file, ok := d.files[id]
+	if !ok {
+		return nil, &driveapi.APIError{Status: http.StatusNotFound}
+	}
+	file.Trashed = true
+	d.files[id] = file
+	return &file, nil
+}
+
+func (d *fakeDrive) ListChildren(_ context.Context, id string) ([]driveapi.File, error) {
+	var children []driveapi.File
+	for _, file := range d.files {
+		if !file.Trashed && slices.Contains(file.Parents, id) {
+			children = append(children, file)
+		}
+	}
+	return children, nil
+}
+
+func (d *fakeDrive) CreateShortcut(_ context.Context, name string, parents []string, targetID string) (*driveapi.File, error) {
+	d.calls["CreateShortcut"]++
+	file := d.add(driveapi.File{Name: name, MimeType: driveapi.ShortcutMimeType, Parents: parents})
+	d.targets[file.ID] = targetID
+	return &file, nil
+}
+
+func (d *fakeDrive) FindByAppProperty(_ context.Context, key, value string) ([]driveapi.File, error) {
+	var found []driveapi.File
+	for _, file := range d.files {
+		if !file.Trashed && file.AppProperties[key] == value {
+			found = append(found, file)
+		}
+	}
+	return found, nil
+}
+
+func (d *fakeDrive) CreateFolder(_ context.Context, name string, parents []string, appProperties map[string]string) (*driveapi.File, error) {
+	d.calls["CreateFolder"]++
+	file := d.add(driveapi.File{Name: name, MimeType: driveapi.FolderMimeType, Parents: parents, AppProperties: appProperties})
+	return &file, nil
+}
+
+func newTestExecutor(t *testing.T, e *Engine, remote RemoteFiles) *Executor {
+	t.Helper()
+	downloads, err := transfer.NewDownloader(zap.NewNop(), e.Config, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewDownloader: %v", err)
+	}
+	e.Downloads = downloads
+	return NewExecutor(zap.NewNop(), e, func(context.Context) RemoteFiles { return remote })
+}
+
+func TestExecutorRunsPlannedOps(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	remote := fakeRemote{
+		fakeContent: fakeContent{"drive-a": "hello"},
+		// md5("hello")
+		files: map[string]driveapi.File{"drive-a": {ID: "drive-a", MD5Checksum: "5d41402abc4b2a76b9719d911017c592", Size: 5, Parents: []string{"root"}}},
+	}
+	x := newTestExecutor(t, e, remote)
+
+	trackFile(t, e, "old.txt", "drive-old")
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "drive-a", Path: "docs/a.txt", Size: 5}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "drive-old", Removed: true}); err != nil {
+		t.Fatalf("ApplyRemoteChange removed: %v", err)
+	}
+	if err := e.addOp(ctx, opUpload, "local.txt", ""); err != nil {
+		t.Fatalf("addOp: %v", err)
+	}
+
+	done, err := x.RunOnce(ctx)
+	if err != nil || done != 2 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	if data, _ := os.ReadFile(e.absPath("docs/a.txt")); string(data) != "hello" {
+		t.Fatalf("expected downloaded content, got %q", data)
+	}
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, "docs/a.txt")
+	if err != nil || rec == nil || rec.Checksum != "5d41402abc4b2a76b9719d911017c592" || rec.ParentID != "root" {
+		t.Fatalf("expected recorded download, got %#v, %v", rec, err)
+	}
+	if _, err := os.Lstat(e.absPath("old.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected old.txt removed, got %v", err)
+	}
+	if rec, _ := e.Store.GetFileByPath(ctx, e.accountID, "old.txt"); rec != nil {
+		t.Fatalf("expected old.txt record dropped, got %#v", rec)
+	}
+	// The fake remote cannot upload, so the upload goes back in the queue.
+	if got := opTypes(t, e); len(got) != 1 || got[0] != "upload local.txt" {
+		t.Fatalf("expected only the upload left, got %v", got)
+	}
+}
+
+func TestExecutorRequeuesFailedOp(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	x := newTestExecutor(t, e, nil)
+
+	if err := e.addOp(ctx, opDownload, "a.txt", "drive-a"); err != nil {
+		t.Fatalf("addOp: %v", err)
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 0 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	ops, err := e.Store.ListPendingOps(ctx, e.accountID, storage.OpQueued, 0)
+	if err != nil || len(ops) != 1 {
+		t.Fatalf("expected op requeued, got %#v, %v", ops, err)
+	}
+	if ops[0].RetryCount != 1 || ops[0].LastError == "" {
+		t.Fatalf("expected failure recorded, got %#v", ops[0])
+	}
+}
+
+func TestExecutorBacksOffAndParksFailedOps(t *testing.T) {
+	e := newTestEngine(t)
+	e.Status = status.NewStore()
+	ctx := context.Background()
+	x := newTestExecutor(t, e, nil)
+	x.maxRetries = 3
+	now := time.Now()
+	x.now = func() time.Time { return now }
+
+	if err := e.addOp(ctx, opDownload, "a.txt", "drive-a"); err != nil {
+		t.Fatalf("addOp: %v", err)
+	}
+	attempt := func(want int, state string) {
+		t.Helper()
+		if _, err := x.RunOnce(ctx); err != nil {
+			t.Fatalf("RunOnce: %v", err)
+		}
+		ops, err := e.Store.ListPendingOps(ctx, e.accountID, "", 0)
+		if err != nil || len(ops) != 1 {
+			t.Fatalf("ListPendingOps: %#v, %v", ops, err)
+		}
+		if ops[0].RetryCount != want || ops[0].State != state {
+			t.Fatalf("expected %d retries in %s, got %d in %s", want, state, ops[0].RetryCount, ops[0].State)
+		}
+	}
+	attempt(1, storage.OpQueued)
+	// Still inside the 30s backoff.
+	attempt(1, storage.OpQueued)
+	now = now.Add(31 * time.Second)
+	attempt(2, storage.OpQueued)
+	// The second backoff is a minute.
+	now = now.Add(20 * time.Second)
+	attempt(2, storage.OpQueued)
+	now = now.Add(time.Minute)
+	attempt(3, storage.OpFailed)
+	if got := e.Status.Current().FailedOps; got != 1 {
+		t.Fatalf("expected 1 failed op in status, got %d", got)
+	}
+
+	if _, err := e.RetryFailed(ctx, "", "op-missing"); errs.KindOf(err) != errs.ErrNotFound {
+		t.Fatalf("expected not found for unknown op, got %v", err)
+	}
+	n, err := e.RetryFailed(ctx, "", "")
+	if err != nil || n != 1 {
+		t.Fatalf("RetryFailed: %d, %v", n, err)
+	}
+	// The retry runs immediately with a fresh budget.
+	attempt(1, storage.OpQueued)
+	if got := e.Status.Current().FailedOps; got != 0 {
+		t.Fatalf("expected failed count cleared, got %d", got)
+	}
+}
+
+func TestExecutorWaitsForOpDependencies(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	x := newTestExecutor(t, e, nil)
+	now := time.Now()
+	x.now = func() time.Time { return now }
+	var ran []string
+	folderFails := true
+	x.handlers[opCreateFolder] = opHandler{execute: func(_ context.Context, op storage.PendingOp) error {
+		if folderFails {
+			return errors.New("drive unavailable")
+		}
+		ran = append(ran, op.OpType+" "+op.Path)
+		return nil
+	}}
+	x.handlers[opUpload] = opHandler{execute: func(_ context.Context, op storage.PendingOp) error {
+		ran = append(ran, op.OpType+" "+op.Path)
+		return nil
+	}}
+
+	for _, op := range []struct{ opType, path string }{
+		{opCreateFolder, "docs"},
+		{opCreateFolder, "docs/sub"},
+		{opUpload, "docs/sub/a.txt"},
+		{opMove, "b.txt"},
+		{opUpload, "b.txt"},
+		{opUpload, "c.txt"},
+	} {
+		if err := e.addOp(ctx, op.opType, op.path, ""); err != nil {
+			t.Fatalf("addOp: %v", err)
+		}
+	}
+	ops, err := e.Store.ListPendingOps(ctx, e.accountID, "", 0)
+	if err != nil {
+		t.Fatalf("ListPendingOps: %v", err)
+	}
+	deps := map[string]string{}
+	ids := map[string]string{}
+	for _, op := range ops {
+		ids[op.ID] = op.OpType + " " + op.Path
+		deps[op.OpType+" "+op.Path] = op.DependsOn
+	}
+	for op, want := range map[string]string{
+		"create_folder docs":     "",
+		"create_folder docs/sub": "create_folder docs",
+		"upload docs/sub/a.txt":  "create_folder docs/sub",
+		"upload b.txt":           "move b.txt",
+		"upload c.txt":           "",
+	} {
+		if got := ids[deps[op]]; got != want {
+			t.Fatalf("%s depends on %q, want %q", op, got, want)
+		}
+	}
+
+	// The folder fails, so nothing under it runs; the move fails without a
+	// signed-in account, so the upload onto its path waits too.
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	if len(ran) != 1 || ran[0] != "upload c.txt" {
+		t.Fatalf("expected only the independent upload, got %v", ran)
+	}
+	folderFails = false
+	now = now.Add(time.Minute)
+	if done, err := x.RunOnce(ctx); err != nil || done != 3 {
+		t.Fatalf("RunOnce after the folder recovered: %d, %v", done, err)
+	}
+	if got := strings.Join(ran[1:], ","); got != "create_folder docs,create_folder docs/sub,upload docs/sub/a.txt" {
+		t.Fatalf("unexpected order %s", got)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for retries, want := range map[int]time.Duration{0: 0, 1: 30 * time.Second, 2: time.Minute, 3: 2 * time.Minute, 20: time.Hour} {
+		if got := retryDelay(retries); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", retries, got, want)
+		}
+	}
+}
+
+func TestExecutorRecoversInterruptedOps(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	x := newTestExecutor(t, e, nil)
+
+	// A delete that removed the file but crashed before dropping the record.
+	trackFile(t, e, "gone.txt", "drive-gone")
+	if err := os.Remove(e.absPath("gone.txt")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	// A delete and a download that crashed before doing anything.
+	trackFile(t, e, "kept.txt", "drive-kept")
+	for _, op := range []storage.PendingOp{
+		{ID: "op-gone", OpType: opDeleteLocal, Path: "gone.txt", DriveID: "drive-gone"},
+		{ID: "op-kept", OpType: opDeleteLocal, Path: "kept.txt", DriveID: "drive-kept"},
+		{ID: "op-download", OpType: opDownload, Path: "b.txt", DriveID: "drive-b"},
+	} {
+		op.AccountID = e.accountID
+		op.State = storage.OpInProgress
+		if err := e.Store.AddPendingOp(ctx, &op); err != nil {
+			t.Fatalf("AddPendingOp: %v", err)
+		}
+	}
+
+	if err := x.Recover(ctx); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if rec, _ := e.Store.GetFileByPath(ctx, e.accountID, "gone.txt"); rec != nil {
+		t.Fatalf("expected gone.txt record dropped, got %#v", rec)
+	}
+	ops, err := e.Store.ListPendingOps(ctx, e.accountID, "", 0)
+	if err != nil {
+		t.Fatalf("ListPendingOps: %v", err)
+	}
+	got := map[string]string{}
+	for _, op := range ops {
+		got[op.ID] = op.State
+	}
+	if len(got) != 2 || got["op-kept"] != storage.OpQueued || got["op-download"] != storage.OpQueued {
+		t.Fatalf("expected interrupted ops requeued, got %v", got)
+	}
+}
+
+func TestExecutorWaitsWhilePaused(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	x := newTestExecutor(t, e, nil)
+
+	trackFile(t, e, "a.txt", "drive-a")
+	if err := e.addOp(ctx, opDeleteLocal, "a.txt", "drive-a"); err != nil {
+		t.Fatalf("addOp: %v", err)
+	}
+	e.setPaused(true)
+	if done, err := x.RunOnce(ctx); err != nil || done != 0 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	if _, err := os.Lstat(e.absPath("a.txt")); err != nil {
+		t.Fatalf("expected a.txt kept while paused: %v", err)
+	}
+}
+
+func TestExecutorHoldsDownloadsWithoutDiskSpace(t *testing.T) {
+	e := newTestEngine(t)
+	e.Status = status.NewStore()
+	e.Config.MinFreeSpaceMB = 1
+	ctx := context.Background()
+	remote := fakeRemote{
+		fakeContent: fakeContent{"drive-a": "hello"},
+		files:       map[string]driveapi.File{"drive-a": {ID: "drive-a", MD5Checksum: "5d41402abc4b2a76b9719d911017c592", Size: 5}},
+	}
+	x := newTestExecutor(t, e, remote)
+	free := int64(1 << 20)
+	x.freeSpace = func(string) (int64, error) { return free, nil }
+
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "drive-a", Path: "a.txt", Size: 5}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	trackFile(t, e, "old.txt", "drive-old")
+	if err := e.addOp(ctx, opDeleteLocal, "old.txt", "drive-old"); err != nil {
+		t.Fatalf("addOp: %v", err)
+	}
+
+	// 5 bytes plus the 1 MiB reserve do not fit; the delete still runs.
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	if got := opTypes(t, e); len(got) != 1 || got[0] != "download a.txt" {
+		t.Fatalf("expected the download held, got %v", got)
+	}
+	if snap := e.Status.Current(); snap.State != status.StateLowDiskSpace {
+		t.Fatalf("expected low disk space state, got %v: %s", snap.State, snap.Message)
+	}
+
+	free += 5
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 {
+		t.Fatalf("RunOnce after freeing space: %d, %v", done, err)
+	}
+	if snap := e.Status.Current(); snap.State != status.StateIdle {
+		t.Fatalf("expected idle once space is available, got %v", snap.State)
+	}
+}
+
+type quotaRemote struct {
+	fakeRemote
+	quota *driveapi.Quota
+}
+
+func (r *quotaRemote) GetQuota(context.Context) (*driveapi.Quota, error) {
+	q := *r.quota
+	return &q, nil
+}
+
+func TestExecutorHoldsUploadsWhileQuotaFull(t *testing.T) {
+	e := newTestEngine(t)
+	e.Status = status.NewStore()
+	ctx := context.Background()
+	remote := &quotaRemote{quota: &driveapi.Quota{Limit: 100, Usage: 100}}
+	x := newTestExecutor(t, e, remote)
+	var uploaded []string
+	x.handlers[opUpload] = opHandler{execute: func(_ context.Context, op storage.PendingOp) error {
+		uploaded = append(uploaded, op.Path)
+		return nil
+	}}
+
+	if err := e.addOp(ctx, opUpload, "a.txt", ""); err != nil {
+		t.Fatalf("addOp: %v", err)
+	}
+	if err := x.CheckQuota(ctx); err != nil {
+		t.Fatalf("CheckQuota: %v", err)
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 0 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	snap := e.Status.Current()
+	if snap.State != status.StateQuotaExceeded || snap.QuotaLimit != 100 || snap.QuotaUsage != 100 {
+		t.Fatalf("expected quota exceeded, got %#v", snap)
+	}
+	if q, err := e.Store.GetQuota(ctx, e.accountID); err != nil || q == nil || q.UsageBytes != 100 {
+		t.Fatalf("expected quota stored, got %#v, %v", q, err)
+	}
+
+	// A restarted executor holds uploads on the stored quota alone.
+	restarted := newTestExecutor(t, e, nil)
+	if err := restarted.LoadQuota(ctx); err != nil || !restarted.quotaFull {
+		t.Fatalf("LoadQuota: full=%v, %v", restarted.quotaFull, err)
+	}
+
+	remote.quota.Usage = 40
+	if err := x.CheckQuota(ctx); err != nil {
+		t.Fatalf("CheckQuota: %v", err)
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 || len(uploaded) != 1 {
+		t.Fatalf("RunOnce after freeing quota: %d, %v, %v", done, uploaded, err)
+	}
+	if snap := e.Status.Current(); snap.State != status.StateIdle || snap.QuotaUsage != 40 {
+		t.Fatalf("expected idle with new usage, got %#v", snap)
+	}
+}
+
+func TestExecutorRequeuesOpOnQuotaError(t *testing.T) {
+	e := newTestEngine(t)
+	e.Status = status.NewStore()
+	ctx := context.Background()
+	x := newTestExecutor(t, e, nil)
+	x.handlers[opUpload] = opHandler{execute: func(context.Context, storage.PendingOp) error {
+		return &driveapi.APIError{Status: 403, Reason: "storageQuotaExceeded"}
+	}}
+
+	if err := e.addOp(ctx, opUpload, "a.txt", ""); err != nil {
+		t.Fatalf("addOp: %v", err)
+	}
+	if _, err := x.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	ops, err := e.Store.ListPendingOps(ctx, e.accountID, storage.OpQueued, 0)
+	if err != nil || len(ops) != 1 || ops[0].RetryCount != 0 {
+		t.Fatalf("expected upload requeued without a retry, got %#v, %v", ops, err)
+	}
+	if got := e.Status.Current().State; got != status.StateQuotaExceeded {
+		t.Fatalf("expected quota exceeded state, got %v", got)
+	}
+}
+
+func TestExecutorHoldsForConsentOnScopeError(t *testing.T) {
+	e := newTestEngine(t)
+	e.Status = status.NewStore()
+	ctx := context.Background()
+	x := newTestExecutor(t, e, nil)
+	x.handlers[opUpload] = opHandler{execute: func(context.Context, storage.PendingOp) error {
+		return &driveapi.APIError{Status: 403, Reason: "insufficientPermissions"}
+	}}
+
+	if err := e.addOp(ctx, opUpload, "a.txt", ""); err != nil {
+		t.Fatalf("addOp: %v", err)
+	}
+	if err := e.addOp(ctx, opUpload, "b.txt", ""); err != nil {
+		t.Fatalf("addOp: %v", err)
+	}
+	if _, err := x.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	ops, err := e.Store.ListPendingOps(ctx, e.accountID, storage.OpQueued, 0)
+	if err != nil || len(ops) != 2 || ops[0].RetryCount != 0 || ops[1].RetryCount != 0 {
+		t.Fatalf("expected both uploads queued without a retry, got %#v, %v", ops, err)
+	}
+	if !e.Paused() || e.ReauthReason() == "" {
+		t.Fatalf("expected the engine held for consent")
+	}
+	if got := e.Status.Current().State; got != status.StateAuthRequired {
+		t.Fatalf("expected auth required state, got %v", got)
+	}
+	if err := e.ReleaseReauth(ctx); err != nil || e.Paused() {
+		t.Fatalf("ReleaseReauth: paused=%v, %v", e.Paused(), err)
+	}
+}
+
+// webhookEvents registers a webhook for the engine's account and returns a
+// channel of the event names it receives.
+func webhookEvents(t *testing.T, e *Engine) <-chan string {
+	t.Helper()
+	got := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- r.Header.Get(notify.HeaderEvent)
+	}))
+	t.Cleanup(srv.Close)
+	if err := e.Store.AddWebhook(context.Background(), &storage.Webhook{ID: "wh-test", AccountID: e.accountID, URL: srv.URL, Secret: "s"}); err != nil {
+		t.Fatalf("AddWebhook: %v", err)
+	}
+	e.Webhooks = notify.NewWebhooks(zap.NewNop(), e.Config, e.Store)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go e.Webhooks.Run(ctx)
+	return got
+}
+
+func expectEvent(t *testing.T, got <-chan string, want string) {
+	t.Helper()
+	select {
+	case evt := <-got:
+		if evt != want {
+			t.Fatalf("expected %s webhook, got %s", want, evt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no %s webhook", want)
+	}
+}
+
+func TestExecutorFiresMilestoneWebhooks(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	got := webhookEvents(t, e)
+	remote := &quotaRemote{quota: &driveapi.Quota{Limit: 100, Usage: 95}}
+	x := newTestExecutor(t, e, remote)
+
+	trackFile(t, e, "a.txt", "drive-a")
+	if err := e.addOp(ctx, opDeleteLocal, "a.txt", "drive-a"); err != nil {
+		t.Fatalf("addOp: %v", err)
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	expectEvent(t, got, notify.EventSyncCompleted)
+
+	// Crossing the 90% default fires once, not on every check.
+	for range 2 {
+		if err := x.CheckQuota(ctx); err != nil {
+			t.Fatalf("CheckQuota: %v", err)
+		}
+	}
+	expectEvent(t, got, notify.EventQuotaThreshold)
+
+	trackFile(t, e, "b.txt", "drive-b")
+	if err := e.addOp(ctx, opUpload, "b.txt", ""); err != nil {
+		t.Fatalf("addOp: %v", err)
+	}
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "drive-b", Path: "b.txt", Size: 99}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	expectEvent(t, got, notify.EventConflictDetected)
+	select {
+	case evt := <-got:
+		t.Fatalf("unexpected extra webhook %s", evt)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package sync
+
+import "os"
+
+// fileID is unsupported on this platform; rename detection falls back to delete+upload.
+func fileID(info os.FileInfo) (uint64, uint64, bool) {
+	_ = info
+	return 0, 0, false
+}
@@ -0,0 +1,122 @@
+package sync
+
+import (
+	"fmt"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/status"
+)
+
+// statusExcluded labels status events for files skipped by transfer filters.
+const statusExcluded = "EXCLUDED"
+
+// transferFilter skips files by size, extension or mime type at planning
+// time, in both directions.
+type transferFilter struct {
+	maxSize    int64
+	extensions map[string]bool
+	// mimeTypes holds exact types and "type/*" wildcards.
+	mimeTypes []string
+}
+
+func newTransferFilter(cfg *config.Config) (transferFilter, error) {
+	f := transferFilter{extensions: make(map[string]bool)}
+	if cfg == nil {
+		return f, nil
+	}
+	size, err := ParseSize(cfg.MaxFileSize)
+	if err != nil {
+		return f, err
+	}
+	f.maxSize = size
+	for _, ext := range cfg.ExcludeExtensions {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext != "" {
+			f.extensions[ext] = true
+		}
+	}
+	for _, mt := range cfg.ExcludeMimeTypes {
+		if mt = strings.ToLower(strings.TrimSpace(mt)); mt != "" {
+			f.mimeTypes = append(f.mimeTypes, mt)
+		}
+	}
+	return f, nil
+}
+
+// excludes returns why a file is filtered out, or "" when it may transfer.
+// mimeType may be empty; it is then guessed from the extension.
+func (f transferFilter) excludes(rel string, size int64, mimeType string) string {
+	if f.maxSize > 0 && size > f.maxSize {
+		return fmt.Sprintf("larger than max_file_size (%d bytes)", f.maxSize)
+	}
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(rel), "."))
+	if ext != "" && f.extensions[ext] {
+		return "extension ." + ext + " excluded"
+	}
+	if len(f.mimeTypes) == 0 {
+		return ""
+	}
+	if mimeType == "" && ext != "" {
+		mimeType = mime.TypeByExtension("." + ext)
+	}
+	mimeType, _, _ = strings.Cut(strings.ToLower(mimeType), ";")
+	if mimeType == "" {
+		return ""
+	}
+	for _, pat := range f.mimeTypes {
+		if pat == mimeType || (strings.HasSuffix(pat, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(pat, "*"))) {
+			return "mime type " + mimeType + " excluded"
+		}
+	}
+	return ""
+}
+
+// noteExcluded surfaces a filtered file in status instead of dropping it
+// silently.
+func (e *Engine) noteExcluded(rel, reason string) {
+	e.Logger.Info("transfer excluded", zap.String("path", rel), zap.String("reason", reason))
+	if e.Status != nil {
+		e.Status.AddEvent(status.Event{Op: statusExcluded, Path: rel})
+	}
+}
+
+// ParseSize parses a byte size such as "500MB", "2G" or "1048576". Units are
+// binary (1K = 1024). Empty means no limit.
+func ParseSize(val string) (int64, error) {
+	val = strings.ToUpper(strings.TrimSpace(val))
+	if val == "" {
+		return 0, nil
+	}
+	i := strings.IndexFunc(val, func(r rune) bool { return r < '0' || r > '9' })
+	if i < 0 {
+		i = len(val)
+	}
+	num, unit := val[:i], strings.TrimSpace(val[i:])
+	unit = strings.TrimSuffix(strings.TrimSuffix(unit, "B"), "I")
+	shift := 0
+	switch unit {
+	case "":
+	case "K":
+		shift = 10
+	case "M":
+		shift = 20
+	case "G":
+		shift = 30
+	case "T":
+		shift = 40
+	default:
+		return 0, errs.New(errs.ErrInvalidArgument, "invalid size %q", val)
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil {
+		return 0, errs.New(errs.ErrInvalidArgument, "invalid size %q", val)
+	}
+	return n << shift, nil
+}
@@ -0,0 +1,78 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/status"
+)
+
+func TestTransferFilterExcludes(t *testing.T) {
+	f, err := newTransferFilter(&config.Config{
+		MaxFileSize:       "1KB",
+		ExcludeExtensions: []string{".ISO", "tmp"},
+		ExcludeMimeTypes:  []string{"video/*", "application/zip"},
+	})
+	if err != nil {
+		t.Fatalf("newTransferFilter: %v", err)
+	}
+	cases := map[string]struct {
+		size     int64
+		mimeType string
+		excluded bool
+	}{
+		"docs/a.txt":         {10, "", false},
+		"docs/big.txt":       {2048, "", true},
+		"images/disk.iso":    {10, "", true},
+		"build/x.TMP":        {10, "", true},
+		"movies/clip.mp4":    {10, "", true},
+		"remote/recording":   {10, "video/webm", true},
+		"remote/bundle":      {10, "application/zip; charset=binary", true},
+		"remote/spreadsheet": {10, "application/vnd.google-apps.spreadsheet", false},
+	}
+	for rel, tc := range cases {
+		if got := f.excludes(rel, tc.size, tc.mimeType) != ""; got != tc.excluded {
+			t.Fatalf("%s: expected excluded=%v", rel, tc.excluded)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{"": 0, "1048576": 1 << 20, "500MB": 500 << 20, "2g": 2 << 30, "4 KiB": 4 << 10}
+	for in, want := range cases {
+		if got, err := ParseSize(in); err != nil || got != want {
+			t.Fatalf("ParseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"MB", "12XB", "-5"} {
+		if _, err := ParseSize(bad); err == nil {
+			t.Fatalf("ParseSize(%q): expected error", bad)
+		}
+	}
+}
+
+func TestExcludedFilesSurfaceInStatus(t *testing.T) {
+	e := newTestEngine(t)
+	e.Status = status.NewStore()
+	e.filter.extensions["iso"] = true
+	ctx := context.Background()
+
+	if err := os.WriteFile(e.absPath("disk.iso"), []byte("x"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := e.noteCreate(ctx, "disk.iso"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "drive-iso", Path: "remote.iso", Size: 1}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 0 {
+		t.Fatalf("expected no ops for excluded files, got %v", got)
+	}
+	events := e.Status.Current().RecentEvents
+	if len(events) != 2 || events[0].Op != statusExcluded || events[1].Path != "remote.iso" {
+		t.Fatalf("expected excluded events, got %#v", events)
+	}
+}
@@ -0,0 +1,341 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/device"
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// EmptyFolderPolicy controls how folders without synced content are mirrored.
+// It applies the same way to remote folders arriving locally and to local
+// folders going to Drive.
+type EmptyFolderPolicy string
+
+const (
+	// EmptyFoldersCreate mirrors every folder as soon as it appears on
+	// either side, empty or not.
+	EmptyFoldersCreate EmptyFolderPolicy = "create"
+	// EmptyFoldersSkip never mirrors a folder on its own; folders only come
+	// into existence as the parents of synced files.
+	EmptyFoldersSkip EmptyFolderPolicy = "skip"
+	// EmptyFoldersPrune behaves like EmptyFoldersCreate, and also removes a
+	// folder that is left empty when its counterpart on the other side is
+	// removed.
+	EmptyFoldersPrune EmptyFolderPolicy = "prune"
+)
+
+const (
+	// opCreateFolder creates the Drive folder for a local directory.
+	opCreateFolder = "create_folder"
+	// opDeleteFolder trashes a Drive folder once it has no children left.
+	opDeleteFolder = "delete_folder"
+)
+
+// FolderCreator creates Drive folders. The executor creates the folders
+// above new files through remotes that implement it.
+type FolderCreator interface {
+	CreateFolder(ctx context.Context, name string, parents []string, appProperties map[string]string) (*driveapi.File, error)
+}
+
+// FileTrasher moves Drive files to the trash. The executor removes files
+// and folders through remotes that implement it.
+type FileTrasher interface {
+	Trash(ctx context.Context, id string) (*driveapi.File, error)
+}
+
+// DriveRootID returns the Drive folder the sync root mirrors: My Drive, or
+// this machine's device folder under the computers target.
+func DriveRootID(ctx context.Context, cfg *config.Config, store storage.Store, accountID string) (string, error) {
+	if cfg.SyncTarget != device.TargetComputers {
+		return "root", nil
+	}
+	dev, err := store.GetDevice(ctx, accountID)
+	if err != nil {
+		return "", err
+	}
+	if dev == nil || dev.RootDriveID == "" {
+		return "", errs.New(errs.ErrNotFound, "device not registered yet; start the daemon once first")
+	}
+	return dev.RootDriveID, nil
+}
+
+// ParseEmptyFolderPolicy validates a configured policy. Empty means create.
+func ParseEmptyFolderPolicy(val string) (EmptyFolderPolicy, error) {
+	switch p := EmptyFolderPolicy(val); p {
+	case "":
+		return EmptyFoldersCreate, nil
+	case EmptyFoldersCreate, EmptyFoldersSkip, EmptyFoldersPrune:
+		return p, nil
+	default:
+		return "", errs.New(errs.ErrInvalidArgument, "unknown empty_folders policy %q", val)
+	}
+}
+
+// applyRemoteFolder records a new or changed Drive folder and creates it
+// locally unless the policy skips folders.
+func (e *Engine) applyRemoteFolder(ctx context.Context, change RemoteChange) error {
+	if change.Path == "" {
+		return errs.New(errs.ErrInvalidArgument, "remote change path is required")
+	}
+	folder, err := e.Store.GetFolderByDriveID(ctx, e.accountID, change.DriveID)
+	if err != nil {
+		return err
+	}
+	if folder == nil {
+		id, err := newID("folder-")
+		if err != nil {
+			return err
+		}
+		folder = &storage.Folder{ID: id, AccountID: e.accountID, DriveID: change.DriveID}
+	}
+	folder.Path = change.Path
+	if change.ParentID != "" {
+		folder.ParentID = change.ParentID
+	}
+	folder.ModifiedAt = change.ModifiedAt
+	if err := e.Store.UpsertFolder(ctx, folder); err != nil {
+		return err
+	}
+	if e.emptyFolders == EmptyFoldersSkip {
+		return nil
+	}
+	abs := e.absPath(change.Path)
+	if _, err := os.Lstat(abs); err == nil {
+		return nil
+	}
+	if audited, err := e.auditLocal(ctx, auditCreateLocalFolder, change.Path, change.DriveID, ""); err != nil || audited {
+		return err
+	}
+	e.suppress(change.Path)
+	return os.MkdirAll(abs, 0o700)
+}
+
+// applyRemoteFolderRemoval forgets a Drive folder that was removed and, when
+// pruning, deletes the local copy if nothing but empty folders is left in it.
+// It reports false when driveID is not a tracked folder. A folder that was
+// only hidden keeps its local copy.
+func (e *Engine) applyRemoteFolderRemoval(ctx context.Context, driveID string, hidden bool) (bool, error) {
+	folder, err := e.Store.GetFolderByDriveID(ctx, e.accountID, driveID)
+	if err != nil || folder == nil {
+		return false, err
+	}
+	if err := e.Store.DeleteFolder(ctx, e.accountID, folder.Path); err != nil {
+		return true, err
+	}
+	if hidden || e.emptyFolders != EmptyFoldersPrune || !e.directionFor(ctx, folder.Path).allows(opDeleteLocal) {
+		return true, nil
+	}
+	if audited, err := e.auditLocal(ctx, auditPruneLocalFolder, folder.Path, driveID, ""); err != nil || audited {
+		return true, err
+	}
+	removed, err := e.removeEmptyTree(folder.Path)
+	if err != nil {
+		return true, err
+	}
+	if removed {
+		e.Logger.Info("pruned empty folder", zap.String("path", folder.Path))
+	}
+	return true, nil
+}
+
+// noteLocalFolder plans the Drive folder for a new local directory.
+func (e *Engine) noteLocalFolder(ctx context.Context, rel string) error {
+	if e.emptyFolders == EmptyFoldersSkip || e.isSharedTopLevel(rel) {
+		return nil
+	}
+	existing, err := e.Store.GetFolderByPath(ctx, e.accountID, rel)
+	if err != nil || existing != nil {
+		return err
+	}
+	if queued, err := e.Store.HasPendingOps(ctx, e.accountID, rel); err != nil || queued {
+		return err
+	}
+	return e.addOp(ctx, opCreateFolder, rel, "")
+}
+
+// noteLocalFolderRemoval plans trashing the Drive folder of a tracked local
+// directory that was removed. Only the prune policy propagates this.
+func (e *Engine) noteLocalFolderRemoval(ctx context.Context, rel string) error {
+	if e.emptyFolders != EmptyFoldersPrune {
+		return nil
+	}
+	folder, err := e.Store.GetFolderByPath(ctx, e.accountID, rel)
+	if err != nil || folder == nil {
+		return err
+	}
+	if err := e.Store.DeleteFolder(ctx, e.accountID, rel); err != nil {
+		return err
+	}
+	return e.addOp(ctx, opDeleteFolder, rel, folder.DriveID)
+}
+
+// skipsEmptyFile reports whether a new zero-byte file should stay on its own
+// side. Native Google documents report no size and are never skipped.
+func (e *Engine) skipsEmptyFile(rel string, size int64, mimeType string) bool {
+	if !e.skipEmptyFiles || size != 0 || driveapi.IsGoogleNative(mimeType) {
+		return false
+	}
+	e.noteExcluded(rel, "empty file")
+	return true
+}
+
+// removeEmptyTree deletes rel if it holds only empty folders. It reports
+// whether rel was removed.
+func (e *Engine) removeEmptyTree(rel string) (bool, error) {
+	abs := e.absPath(rel)
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	empty := true
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			empty = false
+			continue
+		}
+		removed, err := e.removeEmptyTree(filepath.ToSlash(filepath.Join(rel, entry.Name())))
+		if err != nil {
+			return false, err
+		}
+		if !removed {
+			empty = false
+		}
+	}
+	if !empty {
+		return false, nil
+	}
+	e.suppress(rel)
+	if err := os.Remove(abs); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// driveFolder returns the Drive id of the folder at dir, creating it, and
+// any missing folders above it, on Drive and in the index first.
+func (x *Executor) driveFolder(ctx context.Context, remote RemoteFiles, dir string) (string, error) {
+	e := x.engine
+	if dir == "." || dir == "" {
+		return DriveRootID(ctx, e.Config, e.Store, e.accountID)
+	}
+	folder, err := e.Store.GetFolderByPath(ctx, e.accountID, dir)
+	if err != nil {
+		return "", err
+	}
+	if folder != nil && folder.DriveID != "" {
+		return folder.DriveID, nil
+	}
+	creator, ok := remote.(FolderCreator)
+	if !ok {
+		return "", errs.New(errs.ErrInvalidArgument, "drive client cannot create folder %s", dir)
+	}
+	parent, err := x.driveFolder(ctx, remote, path.Dir(dir))
+	if err != nil {
+		return "", err
+	}
+	name, err := e.UploadName(&storage.FileRecord{Path: dir})
+	if err != nil {
+		return "", err
+	}
+	created, err := creator.CreateFolder(ctx, name, []string{parent}, nil)
+	if err != nil {
+		return "", err
+	}
+	if folder == nil {
+		id, err := newID("folder-")
+		if err != nil {
+			return "", err
+		}
+		folder = &storage.Folder{ID: id, AccountID: e.accountID, Path: dir}
+	}
+	folder.DriveID, folder.ParentID = created.ID, parent
+	folder.ModifiedAt = created.ModifiedTime
+	return created.ID, e.Store.UpsertFolder(ctx, folder)
+}
+
+// createFolder creates the Drive folder for a local directory, along with any
+// missing folders above it. A directory removed since has nothing to create.
+func (x *Executor) createFolder(ctx context.Context, op storage.PendingOp) error {
+	remote, err := x.remote(ctx, "create folder", op.Path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(x.engine.absPath(op.Path))
+	if errors.Is(err, os.ErrNotExist) || (err == nil && !info.IsDir()) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = x.driveFolder(ctx, remote, op.Path)
+	return err
+}
+
+// deleteFolder trashes the Drive folder of a removed local directory. A
+// folder that still holds files on Drive, for example ones added there since,
+// is kept, and one already gone needs nothing more.
+func (x *Executor) deleteFolder(ctx context.Context, op storage.PendingOp) error {
+	if op.DriveID == "" {
+		return nil
+	}
+	remote, err := x.remote(ctx, "delete folder", op.Path)
+	if err != nil {
+		return err
+	}
+	trasher, ok := remote.(FileTrasher)
+	if !ok {
+		return errs.New(errs.ErrInvalidArgument, "drive client cannot delete folder %s", op.Path)
+	}
+	if lister, ok := remote.(TreeLister); ok {
+		held, err := holdsFiles(ctx, lister, op.DriveID)
+		if errors.Is(err, errs.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if held {
+			x.logger.Info("drive folder still holds files; keeping it", zap.String("path", op.Path))
+			return nil
+		}
+	}
+	if _, err := trasher.Trash(ctx, op.DriveID); err != nil && !errors.Is(err, errs.ErrNotFound) {
+		return err
+	}
+	if x.engine.Status != nil {
+		x.engine.Status.AddEvent(status.Event{Op: "DELETE", Path: op.Path})
+	}
+	return nil
+}
+
+// holdsFiles reports whether anything but folders is left inside the Drive
+// folder id.
+func holdsFiles(ctx context.Context, lister TreeLister, id string) (bool, error) {
+	children, err := lister.ListChildren(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	for _, child := range children {
+		if child.MimeType != driveapi.FolderMimeType {
+			return true, nil
+		}
+		if held, err := holdsFiles(ctx, lister, child.ID); err != nil || held {
+			return held, err
+		}
+	}
+	return false, nil
+}
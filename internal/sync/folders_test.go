@@ -0,0 +1,197 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+)
+
+func remoteFolder(id, rel string) RemoteChange {
+	return RemoteChange{DriveID: id, Path: rel, MimeType: driveapi.FolderMimeType}
+}
+
+func TestRemoteFolderPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		policy  EmptyFolderPolicy
+		created bool
+	}{
+		{EmptyFoldersCreate, true},
+		{EmptyFoldersSkip, false},
+		{EmptyFoldersPrune, true},
+	} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			e := newTestEngine(t)
+			e.emptyFolders = tc.policy
+			ctx := context.Background()
+
+			if err := e.ApplyRemoteChange(ctx, remoteFolder("folder-a", "a")); err != nil {
+				t.Fatalf("ApplyRemoteChange: %v", err)
+			}
+			_, err := os.Stat(e.absPath("a"))
+			if created := err == nil; created != tc.created {
+				t.Fatalf("expected local folder created=%v, got %v", tc.created, err)
+			}
+			folder, err := e.Store.GetFolderByPath(ctx, e.accountID, "a")
+			if err != nil || folder == nil || folder.DriveID != "folder-a" {
+				t.Fatalf("expected folder tracked, got %#v, %v", folder, err)
+			}
+			if got := opTypes(t, e); len(got) != 0 {
+				t.Fatalf("expected no ops for a folder, got %v", got)
+			}
+		})
+	}
+}
+
+func TestPruneRemovesOnlyEmptyFolders(t *testing.T) {
+	e := newTestEngine(t)
+	e.emptyFolders = EmptyFoldersPrune
+	ctx := context.Background()
+
+	for _, change := range []RemoteChange{
+		remoteFolder("folder-a", "a"),
+		remoteFolder("folder-b", "a/b"),
+		remoteFolder("folder-c", "c"),
+	} {
+		if err := e.ApplyRemoteChange(ctx, change); err != nil {
+			t.Fatalf("ApplyRemoteChange %s: %v", change.Path, err)
+		}
+	}
+	if err := os.WriteFile(e.absPath("c/keep.txt"), []byte("x"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	for _, id := range []string{"folder-a", "folder-c"} {
+		if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: id, Removed: true}); err != nil {
+			t.Fatalf("ApplyRemoteChange removal %s: %v", id, err)
+		}
+	}
+	if _, err := os.Stat(e.absPath("a")); !os.IsNotExist(err) {
+		t.Fatalf("expected empty tree pruned, got %v", err)
+	}
+	if _, err := os.Stat(e.absPath("c/keep.txt")); err != nil {
+		t.Fatalf("expected non-empty folder kept, got %v", err)
+	}
+	if folder, err := e.Store.GetFolderByPath(ctx, e.accountID, "c"); err != nil || folder != nil {
+		t.Fatalf("expected removed folder forgotten, got %#v, %v", folder, err)
+	}
+}
+
+func TestLocalFolderPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	e := newTestEngine(t)
+	if err := os.Mkdir(e.absPath("new"), 0o700); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if err := e.noteCreate(ctx, "new"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+	if err := e.noteCreate(ctx, "new"); err != nil {
+		t.Fatalf("noteCreate again: %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 1 || got[0] != "create_folder new" {
+		t.Fatalf("expected one create_folder op, got %v", got)
+	}
+
+	skip := newTestEngine(t)
+	skip.emptyFolders = EmptyFoldersSkip
+	if err := os.Mkdir(skip.absPath("new"), 0o700); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if err := skip.noteCreate(ctx, "new"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+	if got := opTypes(t, skip); len(got) != 0 {
+		t.Fatalf("expected skip policy to plan nothing, got %v", got)
+	}
+
+	prune := newTestEngine(t)
+	prune.emptyFolders = EmptyFoldersPrune
+	if err := prune.ApplyRemoteChange(ctx, remoteFolder("folder-old", "old")); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if err := os.Remove(prune.absPath("old")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := prune.noteRemoval(ctx, "old"); err != nil {
+		t.Fatalf("noteRemoval: %v", err)
+	}
+	if got := opTypes(t, prune); len(got) != 1 || got[0] != "delete_folder old" {
+		t.Fatalf("expected delete_folder op, got %v", got)
+	}
+}
+
+func TestSkipEmptyFiles(t *testing.T) {
+	e := newTestEngine(t)
+	e.skipEmptyFiles = true
+	ctx := context.Background()
+
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "drive-empty", Path: "empty.txt"}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "drive-doc", Path: "notes", MimeType: "application/vnd.google-apps.document"}); err != nil {
+		t.Fatalf("ApplyRemoteChange doc: %v", err)
+	}
+	if err := os.WriteFile(e.absPath("local-empty.txt"), nil, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := e.noteCreate(ctx, "local-empty.txt"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 1 || got[0] != "download notes" {
+		t.Fatalf("expected only the native doc planned, got %v", got)
+	}
+}
+
+func TestExecutorMirrorsLocalFolders(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEngine(t)
+	e.emptyFolders = EmptyFoldersPrune
+	drive := newFakeDrive()
+	x := newTestExecutor(t, e, drive)
+
+	for _, dir := range []string{"empty", "kept"} {
+		if err := os.Mkdir(e.absPath(dir), 0o700); err != nil {
+			t.Fatalf("Mkdir: %v", err)
+		}
+		if err := e.noteCreate(ctx, dir); err != nil {
+			t.Fatalf("noteCreate: %v", err)
+		}
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 2 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	folders := map[string]string{}
+	for _, dir := range []string{"empty", "kept"} {
+		folder, err := e.Store.GetFolderByPath(ctx, e.accountID, dir)
+		if err != nil || folder == nil || folder.DriveID == "" {
+			t.Fatalf("expected %s created on Drive, got %#v, %v", dir, folder, err)
+		}
+		if file := drive.files[folder.DriveID]; file.Name != dir || file.MimeType != driveapi.FolderMimeType {
+			t.Fatalf("expected a Drive folder named %s, got %#v", dir, file)
+		}
+		folders[dir] = folder.DriveID
+	}
+
+	// A file added to kept on Drive keeps it there.
+	drive.add(driveapi.File{Name: "remote.txt", Parents: []string{folders["kept"]}})
+	for _, dir := range []string{"empty", "kept"} {
+		if err := os.Remove(e.absPath(dir)); err != nil {
+			t.Fatalf("Remove: %v", err)
+		}
+		if err := e.noteRemoval(ctx, dir); err != nil {
+			t.Fatalf("noteRemoval: %v", err)
+		}
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 2 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	if !drive.files[folders["empty"]].Trashed {
+		t.Fatalf("expected the empty folder trashed, got %#v", drive.files[folders["empty"]])
+	}
+	if drive.files[folders["kept"]].Trashed || drive.calls["Trash"] != 1 {
+		t.Fatalf("expected the folder holding a file kept, got %v", drive.calls)
+	}
+}
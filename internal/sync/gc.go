@@ -0,0 +1,162 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// defaultGCInterval applies when no interval is configured.
+const defaultGCInterval = 24 * time.Hour
+
+// BlobCollector removes stored content that no record references any more.
+type BlobCollector interface {
+	CollectGarbage(ctx context.Context) (int, error)
+}
+
+// GCReport summarizes one garbage-collection pass.
+type GCReport struct {
+	// Pruned lists index entries dropped because their file was gone both
+	// locally and from Drive.
+	Pruned []string
+	// Blobs counts unreferenced content files removed.
+	Blobs int
+}
+
+// GarbageCollector periodically prunes local state that no longer describes
+// anything: index entries for files deleted on both sides while the daemon
+// could not see it, and stored content nothing points to.
+type GarbageCollector struct {
+	logger   *zap.Logger
+	engine   *Engine
+	remotes  RemoteFunc
+	blobs    []BlobCollector
+	interval time.Duration
+}
+
+// NewGarbageCollector constructs a collector over any number of content
+// stores; nil ones are ignored.
+func NewGarbageCollector(logger *zap.Logger, engine *Engine, remotes RemoteFunc, blobs ...BlobCollector) *GarbageCollector {
+	interval := defaultGCInterval
+	if engine.Config != nil && engine.Config.GCIntervalHours > 0 {
+		interval = time.Duration(engine.Config.GCIntervalHours) * time.Hour
+	}
+	g := &GarbageCollector{logger: logger, engine: engine, remotes: remotes, interval: interval}
+	for _, b := range blobs {
+		if b != nil {
+			g.blobs = append(g.blobs, b)
+		}
+	}
+	return g
+}
+
+// Run collects once per interval until ctx is done. The first pass waits a
+// full interval so startup is not slowed by it.
+func (g *GarbageCollector) Run(ctx context.Context) {
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := g.Collect(ctx)
+			if err != nil && ctx.Err() == nil {
+				g.logger.Warn("garbage collection failed", zap.Error(err))
+				continue
+			}
+			if report != nil && len(report.Pruned)+report.Blobs > 0 {
+				g.logger.Info("garbage collection finished", zap.Int("records", len(report.Pruned)), zap.Int("blobs", report.Blobs))
+			}
+		}
+	}
+}
+
+// Collect runs one pass. Index entries are only pruned while signed in,
+// since a file's absence from Drive cannot be confirmed otherwise, and not
+// while the account is paused.
+func (g *GarbageCollector) Collect(ctx context.Context) (*GCReport, error) {
+	report := &GCReport{}
+	var remote RemoteFiles
+	if g.remotes != nil {
+		remote = g.remotes(ctx)
+	}
+	if remote != nil && !g.engine.Paused() {
+		pruned, err := g.engine.PruneStaleRecords(ctx, remote)
+		if err != nil {
+			return nil, err
+		}
+		report.Pruned = pruned
+	}
+	for _, b := range g.blobs {
+		n, err := b.CollectGarbage(ctx)
+		report.Blobs += n
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// PruneStaleRecords drops index entries whose file is missing locally and
+// either missing or trashed on Drive. Placeholders
+// and paths with pending ops are left alone. It returns the pruned paths.
+func (e *Engine) PruneStaleRecords(ctx context.Context, remote FileLookup) ([]string, error) {
+	var pruned []string
+	after := ""
+	for {
+		recs, err := e.Store.ListFilesAfter(ctx, e.accountID, after, verifyPageSize)
+		if err != nil {
+			return pruned, err
+		}
+		for i := range recs {
+			rec := &recs[i]
+			after = rec.Path
+			stale, err := e.staleRecord(ctx, remote, rec)
+			if err != nil {
+				return pruned, err
+			}
+			if !stale {
+				continue
+			}
+			if err := e.Store.DeleteFile(ctx, e.accountID, rec.Path); err != nil {
+				return pruned, err
+			}
+			e.Logger.Info("pruned stale index entry", zap.String("path", rec.Path), zap.String("drive_id", rec.DriveID))
+			pruned = append(pruned, rec.Path)
+		}
+		if len(recs) < verifyPageSize {
+			return pruned, nil
+		}
+	}
+}
+
+func (e *Engine) staleRecord(ctx context.Context, remote FileLookup, rec *storage.FileRecord) (bool, error) {
+	if _, err := os.Lstat(e.absPath(rec.Path)); !errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if pending, err := e.Store.HasPendingOps(ctx, e.accountID, rec.Path); err != nil || pending {
+		return false, err
+	}
+	if placeholder, err := e.Store.IsPlaceholder(ctx, e.accountID, rec.Path); err != nil || placeholder {
+		return false, err
+	}
+	if rec.Trashed {
+		return true, nil
+	}
+	file, err := remote.GetFile(ctx, rec.DriveID)
+	if errs.KindOf(err) == errs.ErrNotFound {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return file.Trashed, nil
+}
@@ -0,0 +1,117 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+)
+
+// lookupRemote is a RemoteFiles whose GetFile reports unknown ids as not
+// found.
+type lookupRemote struct {
+	fakeContent
+	lookupMap
+}
+
+// fakeBlobs counts CollectGarbage calls.
+type fakeBlobs struct{ calls int }
+
+func (f *fakeBlobs) CollectGarbage(context.Context) (int, error) {
+	f.calls++
+	return 3, nil
+}
+
+func TestGarbageCollectorPrunesStaleRecords(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	indexFile(t, e, "live.txt", "d-live", "live")
+	indexFile(t, e, "deleted.txt", "d-deleted", "x")
+	indexFile(t, e, "trashed.txt", "d-trashed", "x")
+	indexFile(t, e, "remote-only.txt", "d-remote", "x")
+	indexFile(t, e, "queued.txt", "d-queued", "x")
+	for _, rel := range []string{"deleted.txt", "trashed.txt", "remote-only.txt", "queued.txt"} {
+		if err := os.Remove(e.absPath(rel)); err != nil {
+			t.Fatalf("Remove: %v", err)
+		}
+	}
+	if err := e.addDownload(ctx, "queued.txt", "d-queued", 1); err != nil {
+		t.Fatalf("addDownload: %v", err)
+	}
+	lookup := lookupMap{
+		"d-live":    {ID: "d-live"},
+		"d-trashed": {ID: "d-trashed", Trashed: true},
+		"d-remote":  {ID: "d-remote"},
+		"d-queued":  {ID: "d-queued"},
+	}
+
+	blobs := &fakeBlobs{}
+	g := NewGarbageCollector(e.Logger, e, func(context.Context) RemoteFiles { return lookupRemote{lookupMap: lookup} }, blobs)
+	report, err := g.Collect(ctx)
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if report.Blobs != 3 || blobs.calls != 1 {
+		t.Fatalf("expected blob pass, got %+v", report)
+	}
+	pruned := map[string]bool{}
+	for _, rel := range report.Pruned {
+		pruned[rel] = true
+	}
+	if len(pruned) != 2 || !pruned["deleted.txt"] || !pruned["trashed.txt"] {
+		t.Fatalf("unexpected pruned set %v", report.Pruned)
+	}
+	for _, rel := range []string{"live.txt", "remote-only.txt", "queued.txt"} {
+		if rec, err := e.Store.GetFileByPath(ctx, e.accountID, rel); err != nil || rec == nil {
+			t.Fatalf("expected %s kept, got %v %v", rel, rec, err)
+		}
+	}
+
+	// Signed out: Drive cannot be consulted, so records stay.
+	indexFile(t, e, "later.txt", "d-later", "x")
+	if err := os.Remove(e.absPath("later.txt")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	g = NewGarbageCollector(e.Logger, e, func(context.Context) RemoteFiles { return nil }, nil)
+	report, err = g.Collect(ctx)
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(report.Pruned) != 0 {
+		t.Fatalf("expected nothing pruned while signed out, got %v", report.Pruned)
+	}
+}
+
+func TestTrashedChangeLetsCollectorSkipDrive(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	indexFile(t, e, "old.txt", "d-old", "x")
+
+	trashed := driveapi.Change{FileID: "d-old", File: &driveapi.File{ID: "d-old", Trashed: true}}
+	if err := e.applyDriveChange(ctx, trashed, "root-id"); err != nil {
+		t.Fatalf("applyDriveChange: %v", err)
+	}
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, "old.txt")
+	if err != nil || rec == nil || !rec.Trashed {
+		t.Fatalf("expected the record marked trashed, got %+v, %v", rec, err)
+	}
+
+	// The local delete was lost; Drive, asked now, would not say trashed.
+	if err := os.Remove(e.absPath("old.txt")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := e.Store.DeletePendingOpsForPath(ctx, e.accountID, "old.txt", opDeleteLocal); err != nil {
+		t.Fatalf("DeletePendingOpsForPath: %v", err)
+	}
+	lookup := lookupMap{"d-old": {ID: "d-old"}}
+	g := NewGarbageCollector(e.Logger, e, func(context.Context) RemoteFiles { return lookupRemote{lookupMap: lookup} }, nil)
+	report, err := g.Collect(ctx)
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(report.Pruned) != 1 || report.Pruned[0] != "old.txt" {
+		t.Fatalf("expected the trashed record pruned from the index alone, got %v", report.Pruned)
+	}
+}
@@ -0,0 +1,58 @@
+package sync
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// History ops for work the engine does in place rather than through a
+// pending op.
+const (
+	historyConflict  = "conflict"
+	historyMoveLocal = "move_local"
+)
+
+// recordHistory appends entry to the sync history and adds it to the day's
+// transfer stats. Both are bookkeeping, so failing to write them is logged
+// rather than failing the sync. Nothing is recorded in audit mode, where no
+// change is made.
+func (e *Engine) recordHistory(ctx context.Context, entry storage.HistoryEntry) {
+	if e.Store == nil || e.auditOnly {
+		return
+	}
+	entry.AccountID = e.accountID
+	if err := e.Store.AddHistory(ctx, &entry); err != nil {
+		e.Logger.Warn("sync history write failed", zap.String("op", entry.Op), zap.String("path", entry.Path), zap.Error(err))
+	}
+	delta, ok := statsDelta(entry)
+	if !ok {
+		return
+	}
+	if err := e.Store.AddDailyStats(ctx, e.accountID, entry.FinishedAt, delta); err != nil {
+		e.Logger.Warn("transfer stats write failed", zap.String("op", entry.Op), zap.Error(err))
+	}
+}
+
+// statsDelta returns what a history entry adds to the daily counters: a
+// failed op is an error, and a finished transfer a synced file and its
+// bytes. Detected conflicts and local bookkeeping do not count.
+func statsDelta(entry storage.HistoryEntry) (storage.DailyStats, bool) {
+	switch {
+	case entry.Outcome == storage.HistoryFailed:
+		return storage.DailyStats{Errors: 1}, true
+	case entry.Outcome != storage.HistorySucceeded:
+		return storage.DailyStats{}, false
+	}
+	switch entry.Op {
+	case opUpload:
+		return storage.DailyStats{BytesUp: entry.Bytes, FilesSynced: 1}, true
+	case opDownload, opRestore:
+		return storage.DailyStats{BytesDown: entry.Bytes, FilesSynced: 1}, true
+	case opDelete, opDeleteLocal, opMove, historyMoveLocal, opCopy:
+		return storage.DailyStats{FilesSynced: 1}, true
+	}
+	return storage.DailyStats{}, false
+}
@@ -0,0 +1,75 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+func TestSyncHistoryRecordsFinishedOps(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	remote := fakeRemote{
+		fakeContent: fakeContent{"drive-a": "hello"},
+		files:       map[string]driveapi.File{"drive-a": {ID: "drive-a", MD5Checksum: md5Hex("hello"), Size: 5}},
+	}
+	x := newTestExecutor(t, e, remote)
+
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "drive-a", Path: "a.txt", Size: 5}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	trackFile(t, e, "edited.txt", "drive-e")
+	if err := e.addOp(ctx, opUpload, "edited.txt", "drive-e"); err != nil {
+		t.Fatalf("addOp: %v", err)
+	}
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "drive-e", Path: "edited.txt", Size: 99}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if _, err := x.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	// Without a remote the download fails, and with one attempt allowed it
+	// is parked at once.
+	if err := e.addOp(ctx, opDownload, "missing.txt", "drive-missing"); err != nil {
+		t.Fatalf("addOp: %v", err)
+	}
+	offline := newTestExecutor(t, e, nil)
+	offline.maxRetries = 1
+	if _, err := offline.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+
+	history, err := e.Store.ListHistory(ctx, storage.HistoryFilter{AccountID: e.accountID})
+	if err != nil {
+		t.Fatalf("ListHistory: %v", err)
+	}
+	got := map[string]storage.HistoryEntry{}
+	for _, entry := range history {
+		got[entry.Op+" "+entry.Path] = entry
+	}
+	if ok := got["download a.txt"]; ok.Outcome != storage.HistorySucceeded || ok.Bytes != 5 || ok.FinishedAt.IsZero() {
+		t.Fatalf("expected the download recorded, got %#v", history)
+	}
+	if failed := got["download missing.txt"]; failed.Outcome != storage.HistoryFailed || failed.Error == "" {
+		t.Fatalf("expected the parked download recorded with its error, got %#v", history)
+	}
+	if conflict := got[historyConflict+" edited.txt"]; conflict.Outcome != storage.HistoryDetected || conflict.DriveID != "drive-e" {
+		t.Fatalf("expected the conflict recorded, got %#v", history)
+	}
+	conflicts, err := e.Store.ListConflicts(ctx, e.accountID, storage.ConflictUnresolved)
+	if err != nil || len(conflicts) != 1 || conflicts[0].Path != "edited.txt" {
+		t.Fatalf("expected an unresolved conflict kept for edited.txt, got %#v, %v", conflicts, err)
+	}
+
+	// Two downloads finished and one failed; the conflict is not counted.
+	total, err := e.Store.SumDailyStats(ctx, e.accountID, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("SumDailyStats: %v", err)
+	}
+	if total.FilesSynced != 2 || total.BytesDown != 5+99 || total.BytesUp != 0 || total.Errors != 1 {
+		t.Fatalf("unexpected daily stats %#v", total)
+	}
+}
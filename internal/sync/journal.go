@@ -0,0 +1,97 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// maxChangeAttempts is how many times a remote change that fails to apply
+// is tried, counting the first, before it is left in the journal as failed.
+const maxChangeAttempts = 3
+
+// consume applies a change from the feed and journals it. A change carrying
+// a version of the file that was already applied is a duplicate delivery,
+// as when a page is read again after the token could not be saved, and is
+// only journaled.
+func (p *ChangePoller) consume(ctx context.Context, change driveapi.Change) {
+	e := p.engine
+	entry := journalEntry(e.accountID, change)
+	if entry.Version > 0 {
+		dup, err := e.Store.HasAppliedChange(ctx, e.accountID, entry.DriveID, entry.Version)
+		if err != nil {
+			e.Logger.Warn("change journal lookup failed", zap.String("drive_id", entry.DriveID), zap.Error(err))
+		}
+		if dup {
+			e.Logger.Debug("skipping duplicate change", zap.String("drive_id", entry.DriveID), zap.Int64("version", entry.Version))
+			entry.Outcome = storage.ChangeDuplicate
+			p.journal(ctx, entry)
+			return
+		}
+	}
+	entry.Outcome = storage.ChangeApplied
+	entry.Attempts = 1
+	if err := e.applyDriveChange(ctx, change, p.rootID); err != nil {
+		e.Logger.Warn("remote change handling failed", zap.String("drive_id", change.FileID), zap.Error(err))
+		e.recordError(ctx, change.FileID, "remote_change", err)
+		entry.Outcome, entry.Error = storage.ChangeFailed, err.Error()
+	}
+	p.journal(ctx, entry)
+}
+
+// replay applies again the journaled changes that failed on an earlier
+// poll, oldest first, so a change is not lost because the feed has moved
+// past it.
+func (p *ChangePoller) replay(ctx context.Context) {
+	e := p.engine
+	entries, err := e.Store.ListReplayableChanges(ctx, e.accountID, maxChangeAttempts, 0)
+	if err != nil {
+		e.Logger.Warn("change journal read failed", zap.Error(err))
+		return
+	}
+	for _, entry := range entries {
+		outcome, msg := storage.ChangeApplied, ""
+		var change driveapi.Change
+		if err := json.Unmarshal(entry.Payload, &change); err != nil {
+			outcome, msg = storage.ChangeFailed, err.Error()
+		} else if err := e.applyDriveChange(ctx, change, p.rootID); err != nil {
+			outcome, msg = storage.ChangeFailed, err.Error()
+		}
+		e.Logger.Info("replayed remote change", zap.String("drive_id", entry.DriveID), zap.String("outcome", outcome))
+		if err := e.Store.UpdateJournaledChange(ctx, entry.ID, outcome, msg); err != nil {
+			e.Logger.Warn("change journal write failed", zap.Error(err))
+		}
+	}
+}
+
+// journal writes entry to the change journal. A failed write is logged and
+// does not stop the change from counting as consumed.
+func (p *ChangePoller) journal(ctx context.Context, entry *storage.JournaledChange) {
+	e := p.engine
+	keep := 0
+	if e.Config != nil {
+		keep = e.Config.ChangeJournalSize
+	}
+	if err := e.Store.AddJournaledChange(ctx, entry, keep); err != nil {
+		e.Logger.Warn("change journal write failed", zap.String("drive_id", entry.DriveID), zap.Error(err))
+	}
+}
+
+func journalEntry(accountID string, change driveapi.Change) *storage.JournaledChange {
+	entry := &storage.JournaledChange{AccountID: accountID, DriveID: change.FileID, Kind: "removed", ChangedAt: change.Time}
+	if f := change.File; f != nil && !change.Removed {
+		entry.Kind = "file"
+		if f.Trashed {
+			entry.Kind = "trashed"
+		}
+		entry.Name = f.Name
+		entry.Version = f.Version
+	}
+	// The change only holds plain data, so this cannot fail.
+	entry.Payload, _ = json.Marshal(change)
+	return entry
+}
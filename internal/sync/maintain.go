@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// defaultMaintenanceInterval applies when no interval is configured.
+const defaultMaintenanceInterval = 7 * 24 * time.Hour
+
+// maintenanceStartupDelay keeps an overdue pass from competing with the
+// initial scan right after startup.
+const maintenanceStartupDelay = 10 * time.Minute
+
+// Maintainer periodically checks the database and compacts it. A failed
+// integrity check is reported in the status, so corruption is noticed
+// before it costs data.
+type Maintainer struct {
+	logger   *zap.Logger
+	engine   *Engine
+	interval time.Duration
+}
+
+// NewMaintainer constructs a maintainer for the engine's database.
+func NewMaintainer(logger *zap.Logger, engine *Engine) *Maintainer {
+	interval := defaultMaintenanceInterval
+	if engine.Config != nil && engine.Config.DBMaintenanceHours > 0 {
+		interval = time.Duration(engine.Config.DBMaintenanceHours) * time.Hour
+	}
+	return &Maintainer{logger: logger, engine: engine, interval: interval}
+}
+
+// Run maintains the database once per interval until ctx is done. The
+// first pass is due one interval after the last one recorded in the
+// database, so restarting the daemon does not put maintenance off, but it
+// never runs in the first minutes after startup.
+func (m *Maintainer) Run(ctx context.Context) {
+	timer := time.NewTimer(m.firstWait(ctx, time.Now()))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			m.maintain(ctx)
+			timer.Reset(m.interval)
+		}
+	}
+}
+
+// firstWait returns how long after now the first pass is due.
+func (m *Maintainer) firstWait(ctx context.Context, now time.Time) time.Duration {
+	last, err := m.engine.Store.GetSettingTime(ctx, storage.SettingLastMaintenance)
+	if err != nil {
+		m.logger.Warn("reading last maintenance time failed", zap.Error(err))
+	}
+	if last.IsZero() {
+		return m.interval
+	}
+	return max(last.Add(m.interval).Sub(now), maintenanceStartupDelay)
+}
+
+// maintain runs one pass with the quick integrity check; the full one reads
+// every index and is left to `googlysync db maintain --full`.
+func (m *Maintainer) maintain(ctx context.Context) {
+	e := m.engine
+	report, err := e.Store.Maintain(ctx, true)
+	if ctx.Err() != nil {
+		return
+	}
+	if report != nil && len(report.Problems) > 0 {
+		m.logger.Error("database integrity check failed", zap.Strings("problems", report.Problems))
+		if e.Status != nil {
+			e.Status.Update(status.Snapshot{State: status.StateError, Message: "database corrupt: run googlysync db maintain --full"})
+		}
+		return
+	}
+	if err != nil {
+		m.logger.Warn("database maintenance failed", zap.Error(err))
+		return
+	}
+	m.logger.Info("database maintenance finished", zap.Int64("freed_pages", report.FreedPages),
+		zap.Bool("converted", report.Converted), zap.Duration("took", report.Took))
+}
@@ -0,0 +1,35 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+func TestMaintainerSchedulesFromLastPass(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	e.Config.DBMaintenanceHours = 24
+	m := NewMaintainer(zap.NewNop(), e)
+	now := time.Unix(1_700_000_000, 0)
+
+	if wait := m.firstWait(ctx, now); wait != 24*time.Hour {
+		t.Fatalf("expected a full interval before the first pass, got %v", wait)
+	}
+	if err := e.Store.SetSettingTime(ctx, storage.SettingLastMaintenance, now.Add(-20*time.Hour)); err != nil {
+		t.Fatalf("SetSettingTime: %v", err)
+	}
+	if wait := m.firstWait(ctx, now); wait != 4*time.Hour {
+		t.Fatalf("expected the pass due 24h after the last, got %v", wait)
+	}
+	if err := e.Store.SetSettingTime(ctx, storage.SettingLastMaintenance, now.Add(-72*time.Hour)); err != nil {
+		t.Fatalf("SetSettingTime: %v", err)
+	}
+	if wait := m.firstWait(ctx, now); wait != maintenanceStartupDelay {
+		t.Fatalf("expected an overdue pass after the startup delay, got %v", wait)
+	}
+}
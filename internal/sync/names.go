@@ -0,0 +1,165 @@
+package sync
+
+import (
+	"path"
+	"strings"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// NameEncoding selects which Drive names are rewritten before they are
+// created locally.
+type NameEncoding string
+
+const (
+	// NamesPortable encodes everything Windows, macOS or Linux would reject,
+	// so the sync root can move between them.
+	NamesPortable NameEncoding = "portable"
+	// NamesMinimal encodes only what no filesystem accepts: "/" and NUL.
+	NamesMinimal NameEncoding = "minimal"
+)
+
+// ParseNameEncoding validates a configured encoding. Empty means portable.
+func ParseNameEncoding(val string) (NameEncoding, error) {
+	switch n := NameEncoding(val); n {
+	case "":
+		return NamesPortable, nil
+	case NamesPortable, NamesMinimal:
+		return n, nil
+	default:
+		return "", errs.New(errs.ErrInvalidArgument, "unknown name_encoding %q: use portable or minimal", val)
+	}
+}
+
+// Characters are replaced by look-alikes that are valid everywhere: the
+// full-width forms for punctuation, and the Unicode control pictures for
+// control characters and a trailing space.
+var nameEscapes = map[rune]rune{
+	'/':  '／',
+	'\\': '＼',
+	':':  '：',
+	'*':  '＊',
+	'?':  '？',
+	'"':  '＂',
+	'<':  '＜',
+	'>':  '＞',
+	'|':  '｜',
+}
+
+const (
+	controlPictures = 0x2400 // ␀ is U+2400, ␟ is U+241F
+	escapedSpace    = '␠'
+	escapedDot      = '．'
+	fullwidthOffset = 'Ａ' - 'A'
+)
+
+var nameUnescapes = func() map[rune]rune {
+	out := make(map[rune]rune, len(nameEscapes))
+	for from, to := range nameEscapes {
+		out[to] = from
+	}
+	return out
+}()
+
+// windowsReserved are device names Windows refuses as a file name, with or
+// without an extension.
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// EncodeName returns a Drive name as it can be created locally. Names that
+// need no change are returned as is; DecodeName reverses the rest. Because a
+// Drive name may itself contain the replacement characters, the original is
+// also kept on the file's record, which takes precedence.
+func EncodeName(name string, enc NameEncoding) string {
+	if name == "." || name == ".." {
+		return strings.Repeat(string(escapedDot), len(name))
+	}
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case r == '/':
+			runes[i] = nameEscapes[r]
+		case r < 0x20:
+			if r == 0 || enc == NamesPortable {
+				runes[i] = controlPictures + r
+			}
+		case enc == NamesPortable:
+			if to, ok := nameEscapes[r]; ok {
+				runes[i] = to
+			}
+		}
+	}
+	if enc == NamesPortable {
+		// Windows drops trailing spaces and dots.
+		for i := len(runes) - 1; i >= 0 && (runes[i] == ' ' || runes[i] == '.'); i-- {
+			if runes[i] == ' ' {
+				runes[i] = escapedSpace
+			} else {
+				runes[i] = escapedDot
+			}
+		}
+		stem, _, _ := strings.Cut(string(runes), ".")
+		if windowsReserved[strings.ToUpper(stem)] {
+			runes[0] = fullwidth(runes[0])
+		}
+	}
+	return string(runes)
+}
+
+// DecodeName reverses EncodeName.
+func DecodeName(local string) string {
+	runes := []rune(local)
+	for i, r := range runes {
+		switch {
+		case r >= controlPictures && r < controlPictures+0x20:
+			runes[i] = r - controlPictures
+		case r == escapedSpace:
+			runes[i] = ' '
+		case r == escapedDot:
+			runes[i] = '.'
+		default:
+			if from, ok := nameUnescapes[r]; ok {
+				runes[i] = from
+			}
+		}
+	}
+	if len(runes) > 0 && isFullwidthLetter(runes[0]) {
+		plain := string(runes[0]-fullwidthOffset) + string(runes[1:])
+		if stem, _, _ := strings.Cut(plain, "."); windowsReserved[strings.ToUpper(stem)] {
+			return plain
+		}
+	}
+	return string(runes)
+}
+
+func fullwidth(r rune) rune {
+	if (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') {
+		return r + fullwidthOffset
+	}
+	return r
+}
+
+func isFullwidthLetter(r rune) bool {
+	return (r >= 'Ａ' && r <= 'Ｚ') || (r >= 'ａ' && r <= 'ｚ')
+}
+
+// LocalPath joins a Drive name onto a local parent path, opening the name if
+// it was sealed and encoding it so it can be created in the sync root. Code
+// resolving Drive parents to paths builds every element with it.
+func (e *Engine) LocalPath(parent, name string) string {
+	return path.Join(parent, EncodeName(e.openName(name), e.names))
+}
+
+// DriveName returns the name a file has, or should get, in Drive.
+func DriveName(rec *storage.FileRecord) string {
+	if rec.RemoteName != "" {
+		return rec.RemoteName
+	}
+	return path.Base(rec.Path)
+}
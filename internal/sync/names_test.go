@@ -0,0 +1,78 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestEncodeNameRoundTrip(t *testing.T) {
+	cases := []struct {
+		name    string
+		want    string
+		minimal string
+	}{
+		{"report.txt", "report.txt", "report.txt"},
+		{"Q1/Q2 plan.txt", "Q1／Q2 plan.txt", "Q1／Q2 plan.txt"},
+		{"12:30 notes", "12：30 notes", "12:30 notes"},
+		{`what? "why" <a|b> *\`, `what？ ＂why＂ ＜a｜b＞ ＊＼`, `what? "why" <a|b> *\`},
+		{"draft ", "draft␠", "draft "},
+		{"end..", "end．．", "end.."},
+		{"tab\there", "tab␉here", "tab\there"},
+		{"CON", "ＣON", "CON"},
+		{"nul.tar.gz", "ｎul.tar.gz", "nul.tar.gz"},
+		{"console.txt", "console.txt", "console.txt"},
+		{"..", "．．", "．．"},
+	}
+	for _, tc := range cases {
+		got := EncodeName(tc.name, NamesPortable)
+		if got != tc.want {
+			t.Fatalf("EncodeName(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+		if back := DecodeName(got); back != tc.name {
+			t.Fatalf("DecodeName(%q) = %q, want %q", got, back, tc.name)
+		}
+		if got := EncodeName(tc.name, NamesMinimal); got != tc.minimal {
+			t.Fatalf("minimal EncodeName(%q) = %q, want %q", tc.name, got, tc.minimal)
+		}
+	}
+	if _, err := ParseNameEncoding("strict"); err == nil {
+		t.Fatalf("expected error for unknown encoding")
+	}
+}
+
+func TestAdoptKeepsDriveNameOfEncodedFiles(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	local := e.LocalPath("", "12:30 notes.txt")
+	if local != "12：30 notes.txt" {
+		t.Fatalf("unexpected local path %q", local)
+	}
+	if err := os.WriteFile(e.absPath(local), []byte("notes"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	tree := fakeTree{
+		"root": {
+			{ID: "d-notes", Name: "12:30 notes.txt", Size: 5, MD5Checksum: md5Hex("notes")},
+			{ID: "d-slash", Name: "a/b", Size: 1, MD5Checksum: md5Hex("x")},
+		},
+	}
+	report, err := e.Adopt(ctx, tree, "root", e.Config.SyncRoot)
+	if err != nil {
+		t.Fatalf("Adopt: %v", err)
+	}
+	if report.Adopted != 1 || report.Downloads != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, local)
+	if err != nil || rec == nil {
+		t.Fatalf("GetFileByPath: %+v %v", rec, err)
+	}
+	if rec.RemoteName != "12:30 notes.txt" || DriveName(rec) != "12:30 notes.txt" {
+		t.Fatalf("expected Drive name kept, got %q", rec.RemoteName)
+	}
+	if ops := opTypes(t, e); len(ops) != 1 || ops[0] != opDownload+" a／b" {
+		t.Fatalf("expected download to the encoded name, got %v", ops)
+	}
+}
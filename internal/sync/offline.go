@@ -0,0 +1,209 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/fswatch"
+	"github.com/sandeepkv93/googlysync/internal/transfer"
+)
+
+const (
+	// watchCheckpointInterval is how often the engine records that every
+	// local change up to now has been applied.
+	watchCheckpointInterval = time.Minute
+	// offlineSlack widens the startup check back from the last checkpoint,
+	// covering events still being debounced by the watcher when it was taken.
+	offlineSlack = time.Minute
+	// offlineBackoff is how long ScanOffline waits for the queue to drain
+	// before adding more events.
+	offlineBackoff = 100 * time.Millisecond
+)
+
+// ScanOffline diffs the sync root against the index and queues an event for
+// every change made while the daemon was not running: a removal for each
+// indexed file or folder gone from disk, a write for each whose size
+// differs or whose mtime is after the last watch checkpoint, and a create
+// for each file or folder with no index entry. Content is compared when the
+// event is applied, so a file touched without being changed plans nothing.
+// Paths with pending ops are left to those ops. Call it once the watcher is
+// running, so nothing changed during the scan is missed; it returns the
+// number of events queued.
+func (e *Engine) ScanOffline(ctx context.Context) (int, error) {
+	if e.Store == nil || e.Queue == nil || e.Config == nil {
+		return 0, nil
+	}
+	state, err := e.Store.GetSyncState(ctx, e.accountID)
+	if err != nil {
+		return 0, err
+	}
+	var since time.Time
+	if state != nil && !state.WatchedAt.IsZero() {
+		since = state.WatchedAt.Add(-offlineSlack)
+	}
+
+	queued := 0
+	emit := func(rel string, op fswatch.Op) error {
+		if err := e.waitForQueue(ctx); err != nil {
+			return err
+		}
+		e.Queue.EnqueuePriority(fswatch.Event{Path: e.absPath(rel), Op: op}, PriorityBulk)
+		queued++
+		return nil
+	}
+
+	indexed := make(map[string]bool)
+	after := ""
+	for {
+		recs, err := e.Store.ListFilesAfter(ctx, e.accountID, after, verifyPageSize)
+		if err != nil {
+			return queued, err
+		}
+		for i := range recs {
+			rec := &recs[i]
+			after = rec.Path
+			indexed[rec.Path] = true
+			op, err := e.offlineChange(ctx, rec.Path, rec.Size, since)
+			if err != nil {
+				return queued, err
+			}
+			if op == fswatch.OpUnknown {
+				continue
+			}
+			if err := emit(rec.Path, op); err != nil {
+				return queued, err
+			}
+		}
+		if len(recs) < verifyPageSize {
+			break
+		}
+	}
+	for after = ""; ; {
+		folders, err := e.Store.ListFoldersByPrefixAfter(ctx, e.accountID, "", after, verifyPageSize)
+		if err != nil {
+			return queued, err
+		}
+		for _, folder := range folders {
+			after = folder.Path
+			if _, err := os.Lstat(e.absPath(folder.Path)); !errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err := emit(folder.Path, fswatch.OpRemove); err != nil {
+				return queued, err
+			}
+		}
+		if len(folders) < verifyPageSize {
+			break
+		}
+	}
+
+	root := e.absPath("")
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		rel := e.relPath(p)
+		if e.ignored(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			folder, err := e.Store.GetFolderByPath(ctx, e.accountID, rel)
+			if err != nil || folder != nil {
+				return err
+			}
+			return emit(rel, fswatch.OpCreate)
+		}
+		// Symlinks are queued too; applying the event follows the
+		// symlink_policy.
+		if (!d.Type().IsRegular() && d.Type()&fs.ModeSymlink == 0) || transfer.IsPartial(p) || indexed[rel] {
+			return nil
+		}
+		pending, err := e.Store.HasPendingOps(ctx, e.accountID, rel)
+		if err != nil || pending {
+			return err
+		}
+		return emit(rel, fswatch.OpCreate)
+	})
+	if err != nil {
+		return queued, err
+	}
+
+	e.mu.Lock()
+	e.scanned = true
+	e.mu.Unlock()
+	if queued > 0 {
+		e.Logger.Info("queued changes made while offline", zap.Int("events", queued))
+	}
+	return queued, nil
+}
+
+// offlineChange returns the op that brings the indexed file at rel up to
+// date with the disk, or OpUnknown when it looks unchanged since since.
+func (e *Engine) offlineChange(ctx context.Context, rel string, size int64, since time.Time) (fswatch.Op, error) {
+	if pending, err := e.Store.HasPendingOps(ctx, e.accountID, rel); err != nil || pending {
+		return fswatch.OpUnknown, err
+	}
+	placeholder, err := e.Store.IsPlaceholder(ctx, e.accountID, rel)
+	if err != nil || placeholder {
+		return fswatch.OpUnknown, err
+	}
+	info, err := os.Lstat(e.absPath(rel))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return fswatch.OpRemove, nil
+	case err != nil:
+		return fswatch.OpUnknown, err
+	case !info.Mode().IsRegular():
+		return fswatch.OpUnknown, nil
+	case info.Size() != size, since.IsZero(), info.ModTime().After(since):
+		return fswatch.OpWrite, nil
+	}
+	return fswatch.OpUnknown, nil
+}
+
+// waitForQueue blocks while the queue is at least half full, so a large
+// scan does not push out events the watcher reports meanwhile.
+func (e *Engine) waitForQueue(ctx context.Context) error {
+	for e.Queue.Len() >= e.Queue.capacity/2 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(offlineBackoff):
+		}
+	}
+	return ctx.Err()
+}
+
+// checkpointWatch records now as the watch checkpoint once ScanOffline has
+// run and no local event is left to apply, at most every
+// watchCheckpointInterval.
+func (e *Engine) checkpointWatch(ctx context.Context, now time.Time) {
+	e.mu.Lock()
+	due := e.scanned && len(e.heldLocal) == 0 && now.Sub(e.watchedAt) >= watchCheckpointInterval
+	e.mu.Unlock()
+	if !due || (e.Queue != nil && e.Queue.Len() > 0) {
+		return
+	}
+	if err := e.Store.SetSyncWatchedAt(ctx, e.accountID, now); err != nil {
+		e.Logger.Warn("recording watch checkpoint failed", zap.Error(err))
+		return
+	}
+	e.mu.Lock()
+	e.watchedAt = now
+	e.mu.Unlock()
+}
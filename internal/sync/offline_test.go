@@ -0,0 +1,84 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/fswatch"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+func TestScanOfflineQueuesChangesMadeWhileStopped(t *testing.T) {
+	e := newTestEngine(t)
+	e.Queue = NewQueue(zap.NewNop(), 0)
+	ctx := context.Background()
+
+	checkpoint := time.Now().Add(time.Hour)
+	if err := e.Store.SetSyncWatchedAt(ctx, e.accountID, checkpoint); err != nil {
+		t.Fatalf("SetSyncWatchedAt: %v", err)
+	}
+	index := func(rel, content string, size int64) {
+		t.Helper()
+		if content != "" {
+			if err := os.WriteFile(e.absPath(rel), []byte(content), 0o600); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+		}
+		if err := e.Store.UpsertFile(ctx, &storage.FileRecord{ID: "file-" + rel, AccountID: e.accountID, Path: rel, DriveID: "drive-" + rel, Size: size}); err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+	}
+	index("same.txt", "abc", 3)
+	index("grown.txt", "abcdef", 3)
+	index("touched.txt", "abc", 3)
+	index("gone.txt", "", 3)
+	later := checkpoint.Add(time.Hour)
+	if err := os.Chtimes(e.absPath("touched.txt"), later, later); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	if err := os.WriteFile(e.absPath("new.txt"), []byte("x"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Mkdir(e.absPath("newdir"), 0o700); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+
+	n, err := e.ScanOffline(ctx)
+	if err != nil {
+		t.Fatalf("ScanOffline: %v", err)
+	}
+	got := make(map[string]fswatch.Op)
+	for {
+		evt, ok := e.Queue.Pop()
+		if !ok {
+			break
+		}
+		got[e.relPath(evt.Path)] = evt.Op
+	}
+	want := map[string]fswatch.Op{
+		"grown.txt":   fswatch.OpWrite,
+		"touched.txt": fswatch.OpWrite,
+		"gone.txt":    fswatch.OpRemove,
+		"new.txt":     fswatch.OpCreate,
+		"newdir":      fswatch.OpCreate,
+	}
+	if n != len(want) || len(got) != len(want) {
+		t.Fatalf("expected %d events, got %d: %v", len(want), n, got)
+	}
+	for rel, op := range want {
+		if got[rel] != op {
+			t.Fatalf("expected %v for %s, got %v", op, rel, got[rel])
+		}
+	}
+
+	now := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	e.checkpointWatch(ctx, now)
+	state, err := e.Store.GetSyncState(ctx, e.accountID)
+	if err != nil || state == nil || !state.WatchedAt.Equal(now) {
+		t.Fatalf("expected checkpoint %v after the scan, got %#v, %v", now, state, err)
+	}
+}
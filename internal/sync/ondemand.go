@@ -0,0 +1,235 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+	"github.com/sandeepkv93/googlysync/internal/transfer"
+)
+
+// ContentSource streams remote file content by Drive id.
+type ContentSource interface {
+	Download(ctx context.Context, id string) (io.ReadCloser, error)
+}
+
+// queuePlaceholder represents remote content as a zero-byte local file in
+// on-demand mode. It reports false when the content should be downloaded:
+// outside on-demand mode, under a pinned folder, or when the local copy is
+// already hydrated and must be kept current.
+func (e *Engine) queuePlaceholder(ctx context.Context, change RemoteChange, path string, rec *storage.FileRecord) (bool, error) {
+	if !e.onDemand || !e.directionFor(ctx, path).allows(opDownload) {
+		return false, nil
+	}
+	pinned, err := e.Store.IsPinned(ctx, e.accountID, path)
+	if err != nil || pinned {
+		return false, err
+	}
+	abs := e.absPath(path)
+	if _, err := os.Lstat(abs); err == nil {
+		placeholder, err := e.Store.IsPlaceholder(ctx, e.accountID, path)
+		if err != nil || !placeholder {
+			return false, err
+		}
+	}
+
+	if audited, err := e.auditLocal(ctx, auditPlaceholder, path, change.DriveID, ""); err != nil || audited {
+		return audited, err
+	}
+	e.suppress(path)
+	if err := os.MkdirAll(filepath.Dir(abs), 0o700); err != nil {
+		return false, err
+	}
+	// An existing read-only placeholder must be writable to be truncated;
+	// recordRemote sets the mode again below.
+	if err := e.applyPermissions(path, false); err != nil {
+		return false, err
+	}
+	f, err := os.OpenFile(abs, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return false, err
+	}
+	if err := f.Close(); err != nil {
+		return false, err
+	}
+	if err := e.recordRemote(ctx, change, path, rec); err != nil {
+		return false, err
+	}
+	if err := e.Store.MarkPlaceholder(ctx, e.accountID, path); err != nil {
+		return false, err
+	}
+	e.Logger.Debug("placeholder created", zap.String("path", path), zap.Int64("size", change.Size))
+	if e.Status != nil {
+		e.Status.AddEvent(status.Event{Op: "PLACEHOLDER", Path: path})
+	}
+	return true, nil
+}
+
+// recordRemote stores remote metadata for the local file at path.
+func (e *Engine) recordRemote(ctx context.Context, change RemoteChange, path string, rec *storage.FileRecord) error {
+	if rec == nil {
+		id, err := newRecordID()
+		if err != nil {
+			return err
+		}
+		rec = &storage.FileRecord{ID: id, AccountID: e.accountID, Path: path, ParentID: change.ParentID}
+	}
+	rec.DriveID = change.DriveID
+	rec.ETag = change.ETag
+	if checksum := strings.ToLower(change.Checksum); checksum != rec.Checksum {
+		// The fast digest described the previous content.
+		rec.Checksum, rec.FastHash = checksum, ""
+	}
+	if change.Name != "" {
+		rec.RemoteName = ""
+		if change.Name != pathpkg.Base(path) {
+			rec.RemoteName = change.Name
+		}
+	}
+	rec.ReadOnly = change.ReadOnly
+	rec.MimeType = change.MimeType
+	rec.RevisionID = change.RevisionID
+	rec.Trashed = false
+	rec.Starred, rec.Shared = change.Starred, change.Shared
+	rec.WebViewLink = change.WebViewLink
+	rec.Size = change.Size
+	rec.ModifiedAt = change.ModifiedAt
+	if info, err := os.Lstat(e.absPath(path)); err == nil {
+		if device, inode, ok := fileID(info); ok {
+			rec.Device, rec.Inode = device, inode
+		}
+	}
+	if err := e.Store.UpsertFile(ctx, rec); err != nil {
+		return err
+	}
+	return e.applyPermissions(path, change.ReadOnly)
+}
+
+// localUnchanged reports whether a local event left a tracked file's content
+// as recorded, as after hydrating a placeholder or touching a file. A
+// placeholder that is still empty also counts as unchanged.
+func (e *Engine) localUnchanged(ctx context.Context, rec *storage.FileRecord, info os.FileInfo) (bool, error) {
+	placeholder, err := e.Store.IsPlaceholder(ctx, e.accountID, rec.Path)
+	if err != nil {
+		return false, err
+	}
+	if placeholder && info.Size() == 0 {
+		return true, nil
+	}
+	if placeholder {
+		// Content arrived, either hydrated or written by the user.
+		if err := e.Store.ClearPlaceholder(ctx, e.accountID, rec.Path); err != nil {
+			return false, err
+		}
+	}
+	if rec.Checksum == "" || info.Size() != rec.Size {
+		return false, nil
+	}
+	if rec.FastHash != "" {
+		fast, err := fileFastHash(e.absPath(rec.Path))
+		if err != nil {
+			return false, err
+		}
+		return fast == rec.FastHash, nil
+	}
+	checksum, fast, err := fileDigests(e.absPath(rec.Path))
+	if err != nil || checksum != rec.Checksum {
+		return false, err
+	}
+	// Later checks of this content only need the fast digest.
+	rec.FastHash = fast
+	return true, e.Store.SetFileFastHash(ctx, e.accountID, rec.Path, fast)
+}
+
+// Pin keeps an account's folder downloaded in on-demand mode and queues
+// downloads for placeholders already under it. It returns the number of
+// queued downloads.
+func Pin(ctx context.Context, store storage.Store, accountID, rel string) (int, error) {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	if err := store.PinFolder(ctx, accountID, rel); err != nil {
+		return 0, err
+	}
+	recs, err := store.ListPlaceholders(ctx, accountID, rel)
+	if err != nil {
+		return 0, err
+	}
+	for _, rec := range recs {
+		id, err := newOpID()
+		if err != nil {
+			return 0, err
+		}
+		if err := store.AddPendingOp(ctx, &storage.PendingOp{
+			ID:        id,
+			AccountID: accountID,
+			Path:      rec.Path,
+			DriveID:   rec.DriveID,
+			OpType:    opDownload,
+			Size:      rec.Size,
+		}); err != nil {
+			return 0, err
+		}
+	}
+	return len(recs), nil
+}
+
+// Hydrate downloads the content of an account's placeholders at or under
+// rel and returns the hydrated paths. keys opens content that Drive holds
+// encrypted.
+func Hydrate(ctx context.Context, cfg *config.Config, store storage.Store, downloads *transfer.Downloader, src ContentSource, keys KeySource, accountID, rel string) ([]string, error) {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	recs, err := store.ListPlaceholders(ctx, accountID, rel)
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, errs.New(errs.ErrNotFound, "%s has no placeholders to hydrate", rel)
+	}
+	var done []string
+	for _, rec := range recs {
+		if err := hydrateOne(ctx, cfg, store, downloads, src, keys, accountID, rec); err != nil {
+			return done, fmt.Errorf("hydrate %s: %w", rec.Path, err)
+		}
+		done = append(done, rec.Path)
+	}
+	return done, nil
+}
+
+func hydrateOne(ctx context.Context, cfg *config.Config, store storage.Store, downloads *transfer.Downloader, src ContentSource, keys KeySource, accountID string, rec storage.FileRecord) error {
+	body, err := src.Download(ctx, rec.DriveID)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	// The record already holds the plaintext size and checksum.
+	content, _, err := openSealed(body, keys, accountID)
+	if err != nil {
+		return err
+	}
+	n, fast, err := downloads.Fetch(ctx, rec.Path, rec.Size, rec.Checksum, content)
+	if err != nil {
+		return err
+	}
+	if n != rec.Size {
+		return fmt.Errorf("short download: got %d of %d bytes", n, rec.Size)
+	}
+	rec.FastHash = fast
+	if info, err := os.Lstat(filepath.Join(cfg.SyncRoot, filepath.FromSlash(rec.Path))); err == nil {
+		if device, inode, ok := fileID(info); ok {
+			rec.Device, rec.Inode = device, inode
+		}
+	}
+	if err := store.UpsertFile(ctx, &rec); err != nil {
+		return err
+	}
+	return store.ClearPlaceholder(ctx, accountID, rec.Path)
+}
@@ -0,0 +1,84 @@
+package sync
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/transfer"
+)
+
+type fakeContent map[string]string
+
+func (f fakeContent) Download(_ context.Context, id string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(f[id])), nil
+}
+
+func TestOnDemandPlaceholderAndHydrate(t *testing.T) {
+	e := newTestEngine(t)
+	e.onDemand = true
+	ctx := context.Background()
+
+	// md5("hello")
+	change := RemoteChange{DriveID: "drive-a", Path: "docs/a.txt", Checksum: "5d41402abc4b2a76b9719d911017c592", Size: 5}
+	if err := e.ApplyRemoteChange(ctx, change); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 0 {
+		t.Fatalf("expected placeholder instead of download, got %v", got)
+	}
+	info, err := os.Stat(e.absPath("docs/a.txt"))
+	if err != nil || info.Size() != 0 {
+		t.Fatalf("expected zero-byte placeholder, got %v, %v", info, err)
+	}
+	// The watcher event for the placeholder itself is not an edit.
+	if err := e.noteCreate(ctx, "docs/a.txt"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 0 {
+		t.Fatalf("expected no upload for placeholder, got %v", got)
+	}
+
+	downloads, err := transfer.NewDownloader(zap.NewNop(), e.Config, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewDownloader: %v", err)
+	}
+	done, err := Hydrate(ctx, e.Config, e.Store, downloads, fakeContent{"drive-a": "hello"}, nil, e.accountID, "docs")
+	if err != nil || len(done) != 1 {
+		t.Fatalf("Hydrate: %v %v", done, err)
+	}
+	if data, _ := os.ReadFile(e.absPath("docs/a.txt")); string(data) != "hello" {
+		t.Fatalf("expected hydrated content, got %q", data)
+	}
+	if err := e.noteCreate(ctx, "docs/a.txt"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 0 {
+		t.Fatalf("expected hydration not to upload, got %v", got)
+	}
+}
+
+func TestPinnedFolderDownloads(t *testing.T) {
+	e := newTestEngine(t)
+	e.onDemand = true
+	ctx := context.Background()
+
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "drive-a", Path: "keep/a.txt", Size: 5}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	queued, err := Pin(ctx, e.Store, e.accountID, "keep")
+	if err != nil || queued != 1 {
+		t.Fatalf("Pin: %d %v", queued, err)
+	}
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "drive-b", Path: "keep/b.txt", Size: 5}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	got := opTypes(t, e)
+	if len(got) != 2 || got[0] != "download keep/a.txt" || got[1] != "download keep/b.txt" {
+		t.Fatalf("expected pinned files downloaded, got %v", got)
+	}
+}
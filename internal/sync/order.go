@@ -0,0 +1,53 @@
+package sync
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// placedOpTypes are the ops that put something at a path on Drive. They
+// have to wait for a move onto that path and for the creation of the
+// folders above it.
+var placedOpTypes = map[string]bool{
+	opUpload:       true,
+	opMove:         true,
+	opCopy:         true,
+	opShortcut:     true,
+	opCreateFolder: true,
+}
+
+// opDependency returns the id of the journaled op a new op has to wait for,
+// or "" when it may run in any order: a move onto its path, or else the
+// creation of a folder above it.
+func (e *Engine) opDependency(ctx context.Context, op *storage.PendingOp) (string, error) {
+	if !placedOpTypes[op.OpType] {
+		return "", nil
+	}
+	if op.OpType != opMove {
+		move, err := e.Store.LastPendingOp(ctx, e.accountID, opMove, []string{op.Path})
+		if err != nil || move != nil {
+			return opID(move), err
+		}
+	}
+	folder, err := e.Store.LastPendingOp(ctx, e.accountID, opCreateFolder, parentPaths(op.Path))
+	return opID(folder), err
+}
+
+func opID(op *storage.PendingOp) string {
+	if op == nil {
+		return ""
+	}
+	return op.ID
+}
+
+// parentPaths lists the folders above rel, nearest first.
+func parentPaths(rel string) []string {
+	var out []string
+	for i := strings.LastIndex(rel, "/"); i > 0; i = strings.LastIndex(rel, "/") {
+		rel = rel[:i]
+		out = append(out, rel)
+	}
+	return out
+}
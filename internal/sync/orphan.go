@@ -0,0 +1,184 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// MarkFolderAccessLost reacts to a 403/404 for a synced remote folder: the
+// local subtree is made read-only, excluded from further sync, and surfaced
+// in status until the user detaches it.
+func (e *Engine) MarkFolderAccessLost(ctx context.Context, rel, driveID string, code int) error {
+	if code != http.StatusForbidden && code != http.StatusNotFound {
+		return nil
+	}
+	folder, err := e.Store.GetFolderByPath(ctx, e.accountID, rel)
+	if err != nil {
+		return err
+	}
+	if folder == nil {
+		folder = &storage.Folder{ID: driveID, AccountID: e.accountID, Path: rel, DriveID: driveID}
+	}
+	if !folder.OrphanedAt.IsZero() {
+		return nil
+	}
+	folder.OrphanedAt = time.Now()
+	if err := e.Store.UpsertFolder(ctx, folder); err != nil {
+		return err
+	}
+	e.addOrphan(rel)
+
+	if err := setTreeWritable(e.absPath(rel), false); err != nil {
+		e.Logger.Warn("orphaned subtree chmod failed", zap.String("path", rel), zap.Error(err))
+	}
+	e.Logger.Warn("remote folder access lost", zap.String("path", rel), zap.Int("code", code))
+	if e.Status != nil {
+		e.Status.AddEvent(status.Event{Op: "ORPHANED", Path: rel})
+		e.Status.Update(status.Snapshot{
+			State:   status.StateError,
+			Message: fmt.Sprintf("lost access to %s; run `googlysync detach %s` to keep or delete the local copy", rel, rel),
+		})
+	}
+	return nil
+}
+
+// noteFolderAccess looks at the synced folders above rel after an op on it
+// failed with a 403 or 404, and marks the topmost one the account can no
+// longer reach as orphaned. It reports whether one was.
+func (x *Executor) noteFolderAccess(ctx context.Context, rel string, opErr error) (bool, error) {
+	var apiErr *driveapi.APIError
+	if !errors.As(opErr, &apiErr) || (apiErr.Status != http.StatusForbidden && apiErr.Status != http.StatusNotFound) {
+		return false, nil
+	}
+	remote, err := x.remote(ctx, "check folders above", rel)
+	if err != nil {
+		return false, nil
+	}
+	e := x.engine
+	dirs := parentPaths(rel)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		folder, err := e.Store.GetFolderByPath(ctx, e.accountID, dirs[i])
+		if err != nil {
+			return false, err
+		}
+		if folder == nil || folder.DriveID == "" || !folder.OrphanedAt.IsZero() {
+			continue
+		}
+		_, err = remote.GetFile(ctx, folder.DriveID)
+		var lost *driveapi.APIError
+		if errors.As(err, &lost) && (lost.Status == http.StatusForbidden || lost.Status == http.StatusNotFound) {
+			return true, e.MarkFolderAccessLost(ctx, folder.Path, folder.DriveID, lost.Status)
+		}
+	}
+	return false, nil
+}
+
+// Detach releases an orphaned folder of accountID. The local copy is moved
+// out of the sync root into the data dir, or removed when deleteLocal is set.
+// It returns the new location of the kept copy.
+func Detach(ctx context.Context, cfg *config.Config, store storage.Store, accountID, rel string, deleteLocal bool) (string, error) {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	folder, err := store.GetFolderByPath(ctx, accountID, rel)
+	if err != nil {
+		return "", err
+	}
+	if folder == nil || folder.OrphanedAt.IsZero() {
+		return "", errs.New(errs.ErrNotFound, "%s is not an orphaned folder", rel)
+	}
+
+	src := filepath.Join(cfg.SyncRoot, filepath.FromSlash(rel))
+	if err := setTreeWritable(src, true); err != nil {
+		return "", err
+	}
+
+	dest := ""
+	if deleteLocal {
+		if err := os.RemoveAll(src); err != nil {
+			return "", err
+		}
+	} else if _, err := os.Lstat(src); err == nil {
+		name := fmt.Sprintf("%s-%s", filepath.Base(src), time.Now().Format("20060102-150405"))
+		dest = filepath.Join(cfg.DataDir, "detached", name)
+		if err := os.MkdirAll(filepath.Dir(dest), 0o700); err != nil {
+			return "", err
+		}
+		if err := os.Rename(src, dest); err != nil {
+			return "", err
+		}
+	}
+
+	if err := store.DeleteSubtree(ctx, accountID, rel); err != nil {
+		return dest, err
+	}
+	return dest, nil
+}
+
+func (e *Engine) loadOrphans(ctx context.Context) error {
+	folders, err := e.Store.ListOrphanedFolders(ctx, e.accountID)
+	if err != nil {
+		return err
+	}
+	for _, folder := range folders {
+		e.addOrphan(folder.Path)
+	}
+	return nil
+}
+
+func (e *Engine) addOrphan(rel string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.orphans = append(e.orphans, rel)
+}
+
+func (e *Engine) isOrphaned(rel string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, root := range e.orphans {
+		if rel == root || strings.HasPrefix(rel, root+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// setTreeWritable toggles owner write permission across a subtree.
+func setTreeWritable(root string, writable bool) error {
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mode := info.Mode().Perm()
+		if writable {
+			mode |= 0o200
+		} else {
+			mode &^= 0o222
+		}
+		return os.Chmod(path, mode)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
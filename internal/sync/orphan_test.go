@@ -0,0 +1,80 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestFolderAccessLostAndDetach(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	trackFile(t, e, "shared/report.txt", "drive-report")
+
+	if err := e.MarkFolderAccessLost(ctx, "shared", "drive-shared", http.StatusInternalServerError); err != nil {
+		t.Fatalf("MarkFolderAccessLost 500: %v", err)
+	}
+	if e.isOrphaned("shared/report.txt") {
+		t.Fatal("expected non-access errors to be ignored")
+	}
+
+	if err := e.MarkFolderAccessLost(ctx, "shared", "drive-shared", http.StatusNotFound); err != nil {
+		t.Fatalf("MarkFolderAccessLost: %v", err)
+	}
+	if !e.isOrphaned("shared/report.txt") || e.isOrphaned("sharedother/x") {
+		t.Fatal("expected subtree orphaned")
+	}
+	info, err := os.Stat(e.absPath("shared/report.txt"))
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Mode().Perm()&0o222 != 0 {
+		t.Fatalf("expected read-only file, got %v", info.Mode())
+	}
+
+	if _, err := Detach(ctx, e.Config, e.Store, e.accountID, "docs", false); err == nil {
+		t.Fatal("expected detach of non-orphaned folder to fail")
+	}
+	dest, err := Detach(ctx, e.Config, e.Store, e.accountID, "shared", false)
+	if err != nil {
+		t.Fatalf("Detach: %v", err)
+	}
+	if _, err := os.Stat(e.absPath("shared")); !os.IsNotExist(err) {
+		t.Fatalf("expected local copy moved out of sync root, got %v", err)
+	}
+	if _, err := os.Stat(dest + "/report.txt"); err != nil {
+		t.Fatalf("expected kept copy at %s: %v", dest, err)
+	}
+	rec, err := e.Store.GetFileByDriveID(ctx, e.accountID, "drive-report")
+	if err != nil || rec != nil {
+		t.Fatalf("expected file record removed, got %#v, %v", rec, err)
+	}
+}
+
+func TestExecutorOrphansUnreachableFolder(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	if err := e.ApplyRemoteChange(ctx, remoteFolder("drive-shared", "shared")); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	trackFile(t, e, "shared/report.txt", "drive-report")
+	// Neither the folder nor the file is visible to the account any more.
+	x := newTestExecutor(t, e, newFakeDrive())
+
+	if err := os.WriteFile(e.absPath("shared/report.txt"), []byte("edited"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := e.noteCreate(ctx, "shared/report.txt"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+	if _, err := x.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if !e.isOrphaned("shared/report.txt") {
+		t.Fatal("expected the unreachable folder orphaned")
+	}
+	if ops := opTypes(t, e); len(ops) != 0 {
+		t.Fatalf("expected the upload dropped, got %v", ops)
+	}
+}
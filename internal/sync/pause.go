@@ -0,0 +1,160 @@
+package sync
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/fswatch"
+	"github.com/sandeepkv93/googlysync/internal/status"
+)
+
+// Pause stops planning for the engine's account and persists the flag so it
+// survives restarts. Local and remote changes that arrive while paused are
+// held and replayed on resume, and Pause returns once in-flight downloads
+// have finished or ctx is done. Any other account is not synced here and
+// fails with ErrNotFound.
+func (e *Engine) Pause(ctx context.Context, accountID string) (string, error) {
+	accountID, err := e.syncedAccount(accountID)
+	if err != nil {
+		return accountID, err
+	}
+	if err := e.Store.SetSyncPaused(ctx, accountID, true); err != nil {
+		return accountID, err
+	}
+	e.setPaused(true)
+	e.Logger.Info("sync paused", zap.String("account", accountID))
+	if e.Status != nil {
+		e.Status.Update(status.Snapshot{State: status.StatePaused, Message: "paused"})
+	}
+	if e.Downloads != nil {
+		if err := e.Downloads.Drain(ctx); err != nil {
+			return accountID, err
+		}
+	}
+	return accountID, nil
+}
+
+// Resume clears the pause flag. Changes held while paused are replayed by
+// the engine loop. While the account is held for a new sign-in, the flag is
+// still cleared but the engine only runs again after ReleaseReauth. Like
+// Pause, it only accepts the engine's account.
+func (e *Engine) Resume(ctx context.Context, accountID string) (string, error) {
+	accountID, err := e.syncedAccount(accountID)
+	if err != nil {
+		return accountID, err
+	}
+	if err := e.Store.SetSyncPaused(ctx, accountID, false); err != nil {
+		return accountID, err
+	}
+	if reason := e.ReauthReason(); reason != "" {
+		return accountID, errs.New(errs.ErrAuthExpired, "sign in again before syncing resumes: %s", reason)
+	}
+	e.setPaused(false)
+	e.Logger.Info("sync resumed", zap.String("account", accountID))
+	if e.Status != nil {
+		e.Status.Update(status.Snapshot{State: status.StateIdle, Message: "resumed"})
+	}
+	select {
+	case e.resumed <- struct{}{}:
+	default:
+	}
+	return accountID, nil
+}
+
+// AccountID returns the account the engine syncs.
+func (e *Engine) AccountID() string {
+	return e.accountID
+}
+
+// SetAccount makes the engine sync a signed-in account instead of the
+// placeholder one. Call it before the engine runs; an empty id is ignored.
+func (e *Engine) SetAccount(accountID string) {
+	if accountID != "" {
+		e.accountID = accountID
+	}
+}
+
+// syncedAccount resolves the account a control request names: empty means
+// the engine's own, and any other account is not synced here.
+func (e *Engine) syncedAccount(accountID string) (string, error) {
+	if accountID == "" || accountID == e.accountID {
+		return e.accountID, nil
+	}
+	return accountID, errs.New(errs.ErrNotFound, "account %s is not synced by this daemon; it syncs %s", accountID, e.accountID)
+}
+
+// Paused reports whether the engine's account is paused.
+func (e *Engine) Paused() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.paused
+}
+
+func (e *Engine) setPaused(paused bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.paused = paused
+}
+
+// loadPaused restores the persisted pause flag on startup.
+func (e *Engine) loadPaused(ctx context.Context) error {
+	state, err := e.Store.GetSyncState(ctx, e.accountID)
+	if err != nil || state == nil || !state.Paused {
+		return err
+	}
+	e.setPaused(true)
+	if e.Status != nil {
+		e.Status.Update(status.Snapshot{State: status.StatePaused, Message: "paused"})
+	}
+	return nil
+}
+
+// holdLocal keeps the latest local event per path while paused. It reports
+// false when the engine is running and the event should be applied now.
+func (e *Engine) holdLocal(evt fswatch.Event) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.paused {
+		return false
+	}
+	e.heldLocal[evt.Path] = evt
+	return true
+}
+
+// holdRemote keeps the latest remote change per Drive file while paused.
+func (e *Engine) holdRemote(change RemoteChange) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.paused {
+		return false
+	}
+	e.heldRemote[change.DriveID] = change
+	return true
+}
+
+// replayHeld applies changes that arrived while paused.
+func (e *Engine) replayHeld(ctx context.Context) {
+	e.mu.Lock()
+	if e.paused {
+		e.mu.Unlock()
+		return
+	}
+	local, remote := e.heldLocal, e.heldRemote
+	e.heldLocal = make(map[string]fswatch.Event)
+	e.heldRemote = make(map[string]RemoteChange)
+	e.mu.Unlock()
+
+	if len(local)+len(remote) > 0 {
+		e.Logger.Info("replaying changes held while paused", zap.Int("local", len(local)), zap.Int("remote", len(remote)))
+	}
+	for _, change := range remote {
+		if err := e.ApplyRemoteChange(ctx, change); err != nil {
+			e.Logger.Warn("held remote change failed", zap.String("drive_id", change.DriveID), zap.Error(err))
+		}
+	}
+	for _, evt := range local {
+		e.handleEvent(ctx, evt)
+	}
+}
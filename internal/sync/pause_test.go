@@ -0,0 +1,126 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/fswatch"
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+func TestPauseHoldsChangesUntilResume(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	if _, err := e.Pause(ctx, ""); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	state, err := e.Store.GetSyncState(ctx, e.accountID)
+	if err != nil || state == nil || !state.Paused {
+		t.Fatalf("expected persisted pause, got %#v, %v", state, err)
+	}
+
+	if err := os.WriteFile(e.absPath("new.txt"), []byte("x"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if !e.holdLocal(fswatch.Event{Path: e.absPath("new.txt"), Op: fswatch.OpCreate}) {
+		t.Fatal("expected local event held while paused")
+	}
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "drive-r", Path: "remote.txt", Size: 3}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 0 {
+		t.Fatalf("expected nothing planned while paused, got %v", got)
+	}
+
+	if _, err := e.Resume(ctx, ""); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	<-e.resumed
+	e.replayHeld(ctx)
+	got := opTypes(t, e)
+	sort.Strings(got)
+	if len(got) != 2 || got[0] != "download remote.txt" || got[1] != "upload new.txt" {
+		t.Fatalf("expected held changes replayed, got %v", got)
+	}
+	if e.holdLocal(fswatch.Event{Path: e.absPath("new.txt"), Op: fswatch.OpWrite}) {
+		t.Fatal("expected events applied directly after resume")
+	}
+}
+
+func TestPauseRestoredOnStartup(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	if err := e.Store.SetSyncPaused(ctx, e.accountID, true); err != nil {
+		t.Fatalf("SetSyncPaused: %v", err)
+	}
+	if err := e.loadPaused(ctx); err != nil || !e.Paused() {
+		t.Fatalf("expected paused after load, got %v, %v", e.Paused(), err)
+	}
+	if _, err := e.Pause(ctx, "nobody"); !errors.Is(err, errs.ErrNotFound) {
+		t.Fatalf("expected unknown account to fail, got %v", err)
+	}
+}
+
+func TestPauseRefusesAccountsNotSynced(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	if err := e.Store.UpsertAccount(ctx, &storage.Account{ID: "sub-other", Email: "other@example.com"}); err != nil {
+		t.Fatalf("UpsertAccount: %v", err)
+	}
+	if _, err := e.Pause(ctx, "sub-other"); !errors.Is(err, errs.ErrNotFound) {
+		t.Fatalf("expected pausing another account to fail, got %v", err)
+	}
+	if _, err := e.Resume(ctx, "sub-other"); !errors.Is(err, errs.ErrNotFound) {
+		t.Fatalf("expected resuming another account to fail, got %v", err)
+	}
+	if state, err := e.Store.GetSyncState(ctx, "sub-other"); err != nil || state != nil {
+		t.Fatalf("expected nothing persisted for the other account, got %#v, %v", state, err)
+	}
+
+	// Once the engine syncs that account, it is the one paused.
+	e.SetAccount("sub-other")
+	if got, err := e.Pause(ctx, ""); err != nil || got != "sub-other" || !e.Paused() {
+		t.Fatalf("Pause: %q, %v, paused=%v", got, err, e.Paused())
+	}
+	if state, err := e.Store.GetSyncState(ctx, "sub-other"); err != nil || state == nil || !state.Paused {
+		t.Fatalf("expected the pause persisted for sub-other, got %#v, %v", state, err)
+	}
+}
+
+func TestReauthHoldOutlastsResume(t *testing.T) {
+	e := newTestEngine(t)
+	e.Status = status.NewStore()
+	ctx := context.Background()
+
+	e.HoldForReauth("invalid_grant")
+	if !e.Paused() || e.Status.Current().State != status.StateAuthRequired {
+		t.Fatalf("expected paused with auth required, got paused=%v state=%v", e.Paused(), e.Status.Current().State)
+	}
+	if _, err := e.Resume(ctx, ""); !errors.Is(err, errs.ErrAuthExpired) || !e.Paused() {
+		t.Fatalf("resume while held: expected auth expired and still paused, got %v, paused=%v", err, e.Paused())
+	}
+	if err := e.ReleaseReauth(ctx); err != nil {
+		t.Fatalf("ReleaseReauth: %v", err)
+	}
+	if e.Paused() || e.Status.Current().State != status.StateIdle {
+		t.Fatalf("expected running after sign-in, got paused=%v state=%v", e.Paused(), e.Status.Current().State)
+	}
+
+	// A pause the user asked for survives the sign-in.
+	if _, err := e.Pause(ctx, ""); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	e.HoldForReauth("invalid_grant")
+	if err := e.ReleaseReauth(ctx); err != nil {
+		t.Fatalf("ReleaseReauth: %v", err)
+	}
+	if !e.Paused() || e.ReauthReason() != "" {
+		t.Fatalf("expected the user's pause kept, got paused=%v reauth=%q", e.Paused(), e.ReauthReason())
+	}
+}
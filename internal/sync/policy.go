@@ -0,0 +1,189 @@
+package sync
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/ignore"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// BandwidthClass ranks transfers under a folder against each other.
+type BandwidthClass string
+
+const (
+	BandwidthLow    BandwidthClass = "low"
+	BandwidthNormal BandwidthClass = "normal"
+	BandwidthHigh   BandwidthClass = "high"
+)
+
+// exportFormatPattern accepts the file extension an export is saved as.
+var exportFormatPattern = regexp.MustCompile(`^[a-z0-9]{1,10}$`)
+
+// FolderSettings are the settings in force for one path once the policies
+// of every enclosing folder are merged over the sync root's configuration.
+type FolderSettings struct {
+	Direction      Direction
+	Ignore         []IgnoreRule
+	ExportFormat   string
+	BandwidthClass BandwidthClass
+	// Invalid lists the rules in Ignore that no longer parse; they are left
+	// out of matching.
+	Invalid []IgnoreRule
+
+	matcher *ignore.Matcher
+}
+
+// IgnoreRule is an ignore pattern and the folder it was attached to. The
+// pattern follows .gitignore rules, as if it were in a .gitignore in Dir.
+type IgnoreRule struct {
+	Dir     string
+	Pattern string
+}
+
+// Ignores returns the pattern that leaves rel unsynced, or "". isDir tells
+// whether rel is a folder. Rules are applied in order, so a deeper folder's
+// "!" pattern can re-include what an enclosing folder ignored. Settings from
+// ResolveFolderSettings carry the rules already parsed.
+func (s FolderSettings) Ignores(rel string, isDir bool) string {
+	if s.matcher == nil && len(s.Ignore) > 0 {
+		s = s.compiled()
+	}
+	return s.matcher.Matching(rel, isDir)
+}
+
+// compiled returns s with its ignore rules built into one matcher, so
+// resolved settings match without parsing the rules again.
+func (s FolderSettings) compiled() FolderSettings {
+	s.matcher, s.Invalid = new(ignore.Matcher), nil
+	for _, rule := range s.Ignore {
+		if err := s.matcher.Add(rule.Dir, rule.Pattern); err != nil {
+			s.Invalid = append(s.Invalid, rule)
+		}
+	}
+	return s
+}
+
+// ValidateFolderPolicy checks the values of a policy before it is stored.
+// Backup is not available per folder, since snapshots cover the whole root.
+func ValidateFolderPolicy(policy *storage.FolderPolicy) error {
+	if policy.Path != path.Clean("/" + policy.Path)[1:] {
+		return errs.New(errs.ErrInvalidArgument, "folder policy path %q must be relative to the sync root", policy.Path)
+	}
+	if policy.Direction != "" {
+		d, err := ParseDirection(policy.Direction)
+		if err != nil {
+			return err
+		}
+		if d == DirectionBackup {
+			return errs.New(errs.ErrInvalidArgument, "backup applies to the whole sync root, not a folder")
+		}
+	}
+	for _, pattern := range policy.Ignore {
+		if err := ValidateIgnorePattern(pattern); err != nil {
+			return err
+		}
+	}
+	if policy.ExportFormat != "" && !exportFormatPattern.MatchString(policy.ExportFormat) {
+		return errs.New(errs.ErrInvalidArgument, "export format %q should be a file extension such as pdf", policy.ExportFormat)
+	}
+	switch BandwidthClass(policy.BandwidthClass) {
+	case "", BandwidthLow, BandwidthNormal, BandwidthHigh:
+	default:
+		return errs.New(errs.ErrInvalidArgument, "unknown bandwidth class %q", policy.BandwidthClass)
+	}
+	return nil
+}
+
+// ValidateIgnorePattern checks that pattern is a usable .gitignore pattern.
+func ValidateIgnorePattern(pattern string) error {
+	return ignore.Validate(pattern)
+}
+
+// ResolveFolderSettings merges the policies that enclose rel over base.
+// policies must be ordered shallowest first, as ListFolderPolicies returns
+// them, so deeper folders win; ignore patterns accumulate.
+func ResolveFolderSettings(base FolderSettings, policies []storage.FolderPolicy, rel string) (FolderSettings, error) {
+	out := base
+	out.Ignore = append([]IgnoreRule(nil), base.Ignore...)
+	for _, policy := range policies {
+		if policy.Path != "" && rel != policy.Path && !strings.HasPrefix(rel, policy.Path+"/") {
+			continue
+		}
+		if policy.Direction != "" {
+			d, err := ParseDirection(policy.Direction)
+			if err != nil {
+				return base.compiled(), err
+			}
+			out.Direction = d
+		}
+		for _, pattern := range policy.Ignore {
+			out.Ignore = append(out.Ignore, IgnoreRule{Dir: policy.Path, Pattern: pattern})
+		}
+		if policy.ExportFormat != "" {
+			out.ExportFormat = policy.ExportFormat
+		}
+		if policy.BandwidthClass != "" {
+			out.BandwidthClass = BandwidthClass(policy.BandwidthClass)
+		}
+	}
+	return out.compiled(), nil
+}
+
+// FolderSettings returns the settings in force for rel. Ignore rules stored
+// for the sync root apply to it as if attached to the root's policy.
+func (e *Engine) FolderSettings(ctx context.Context, rel string) (FolderSettings, error) {
+	base := FolderSettings{Direction: e.direction, BandwidthClass: BandwidthNormal}
+	if e.Store == nil {
+		return base, nil
+	}
+	rules, err := e.Store.ListIgnoreRules(ctx, e.accountID, e.Config.SyncRoot, true)
+	if err != nil {
+		return base, err
+	}
+	for _, rule := range rules {
+		base.Ignore = append(base.Ignore, IgnoreRule{Pattern: rule.Pattern})
+	}
+	policies, err := e.Store.ListFolderPolicies(ctx, e.accountID)
+	if err != nil {
+		return base.compiled(), err
+	}
+	settings, err := ResolveFolderSettings(base, policies, rel)
+	for _, rule := range settings.Invalid {
+		// Patterns are validated before they are stored, so this only
+		// happens after the pattern syntax changes.
+		e.Logger.Warn("stored ignore pattern no longer parses", zap.String("folder", rule.Dir), zap.String("pattern", rule.Pattern))
+	}
+	return settings, err
+}
+
+// directionFor returns the sync direction in force for rel. When the
+// policies cannot be read the sync root's direction applies.
+func (e *Engine) directionFor(ctx context.Context, rel string) Direction {
+	settings, err := e.FolderSettings(ctx, rel)
+	if err != nil {
+		e.Logger.Warn("folder policy lookup failed", zap.String("path", rel), zap.Error(err))
+		return e.direction
+	}
+	return settings.Direction
+}
+
+// ignoredByPolicy reports whether a folder policy leaves rel unsynced, and
+// notes it when one does.
+func (e *Engine) ignoredByPolicy(ctx context.Context, rel string, isDir bool) bool {
+	settings, err := e.FolderSettings(ctx, rel)
+	if err != nil {
+		e.Logger.Warn("folder policy lookup failed", zap.String("path", rel), zap.Error(err))
+		return false
+	}
+	if pattern := settings.Ignores(rel, isDir); pattern != "" {
+		e.noteExcluded(rel, "ignored by folder policy ("+pattern+")")
+		return true
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandeepkv93/googlysync/internal/fswatch"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+func savePolicy(t *testing.T, e *Engine, policy storage.FolderPolicy) {
+	t.Helper()
+	policy.AccountID = e.accountID
+	if err := ValidateFolderPolicy(&policy); err != nil {
+		t.Fatalf("ValidateFolderPolicy: %v", err)
+	}
+	if err := e.Store.SaveFolderPolicy(context.Background(), &policy); err != nil {
+		t.Fatalf("SaveFolderPolicy: %v", err)
+	}
+}
+
+func TestFolderSettingsMergeHierarchically(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	savePolicy(t, e, storage.FolderPolicy{Path: "", Ignore: []string{"*.tmp"}})
+	savePolicy(t, e, storage.FolderPolicy{Path: "work", Direction: string(DirectionUploadOnly), Ignore: []string{"build"}, ExportFormat: "pdf", BandwidthClass: "low"})
+	savePolicy(t, e, storage.FolderPolicy{Path: "work/shared", Direction: string(DirectionBidirectional), ExportFormat: "odt", Ignore: []string{"!keep.tmp"}})
+
+	s, err := e.FolderSettings(ctx, "work/shared/plan.txt")
+	if err != nil {
+		t.Fatalf("FolderSettings: %v", err)
+	}
+	if s.Direction != DirectionBidirectional || s.ExportFormat != "odt" || s.BandwidthClass != BandwidthLow {
+		t.Fatalf("unexpected settings %+v", s)
+	}
+	for rel, want := range map[string]string{
+		"notes.tmp":                 "*.tmp",
+		"work/shared/build/out.bin": "build",
+		"workbench/build/out.bin":   "",
+		"build/out.bin":             "",
+		"work/plan.txt":             "",
+		"work/shared/keep.tmp":      "",
+		"work/keep.tmp":             "*.tmp",
+	} {
+		settings, _ := e.FolderSettings(ctx, rel)
+		if got := settings.Ignores(rel, false); got != want {
+			t.Fatalf("Ignores(%q) = %q, want %q", rel, got, want)
+		}
+	}
+	if s, _ := e.FolderSettings(ctx, "workbench/a.txt"); s.Direction != DirectionBidirectional || s.ExportFormat != "" {
+		t.Fatalf("expected sibling with a shared prefix unaffected, got %+v", s)
+	}
+}
+
+func TestFolderPolicyDirectsPlanner(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	savePolicy(t, e, storage.FolderPolicy{Path: "archive", Direction: string(DirectionDownloadOnly)})
+	savePolicy(t, e, storage.FolderPolicy{Path: "scratch", Ignore: []string{"*"}})
+
+	for _, rel := range []string{"archive/a.txt", "scratch/b.txt", "c.txt"} {
+		if err := os.MkdirAll(filepath.Dir(e.absPath(rel)), 0o700); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(e.absPath(rel), []byte("x"), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		e.applyLocalEvent(ctx, fswatch.Event{Path: e.absPath(rel), Op: fswatch.OpCreate})
+	}
+	if ops := opTypes(t, e); len(ops) != 1 || ops[0] != opUpload+" c.txt" {
+		t.Fatalf("expected only the unrestricted file uploaded, got %v", ops)
+	}
+
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "d-a", Path: "archive/new.txt", Size: 1}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "d-b", Path: "scratch/new.txt", Size: 1}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	ops := opTypes(t, e)
+	if len(ops) != 2 || ops[1] != opDownload+" archive/new.txt" {
+		t.Fatalf("expected the download-only folder to still pull, got %v", ops)
+	}
+}
+
+func TestValidateFolderPolicy(t *testing.T) {
+	for _, policy := range []storage.FolderPolicy{
+		{Path: "/abs"},
+		{Path: "work/"},
+		{Path: "work", Direction: string(DirectionBackup)},
+		{Path: "work", Direction: "sideways"},
+		{Path: "work", Ignore: []string{"["}},
+		{Path: "work", ExportFormat: "P D F"},
+		{Path: "work", BandwidthClass: "turbo"},
+	} {
+		if err := ValidateFolderPolicy(&policy); err == nil {
+			t.Fatalf("expected %+v rejected", policy)
+		}
+	}
+}
+
+func TestStoredIgnoreRulesApply(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	rule := &storage.IgnoreRule{AccountID: e.accountID, Root: e.Config.SyncRoot, Pattern: "*.bak"}
+	if err := e.Store.AddIgnoreRule(ctx, rule); err != nil {
+		t.Fatalf("AddIgnoreRule: %v", err)
+	}
+	if err := e.Store.AddIgnoreRule(ctx, &storage.IgnoreRule{AccountID: e.accountID, Root: "/other", Pattern: "*.txt"}); err != nil {
+		t.Fatalf("AddIgnoreRule: %v", err)
+	}
+
+	for _, rel := range []string{"notes.bak", "docs/old.bak", "keep.txt"} {
+		if err := os.MkdirAll(filepath.Dir(e.absPath(rel)), 0o700); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(e.absPath(rel), []byte("x"), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		e.applyLocalEvent(ctx, fswatch.Event{Path: e.absPath(rel), Op: fswatch.OpCreate})
+	}
+	if ops := opTypes(t, e); len(ops) != 1 || ops[0] != opUpload+" keep.txt" {
+		t.Fatalf("expected only keep.txt uploaded, got %v", ops)
+	}
+
+	if err := e.Store.SetIgnoreRuleEnabled(ctx, e.accountID, rule.ID, false); err != nil {
+		t.Fatalf("SetIgnoreRuleEnabled: %v", err)
+	}
+	if s, _ := e.FolderSettings(ctx, "notes.bak"); s.Ignores("notes.bak", false) != "" {
+		t.Fatalf("expected a disabled rule to stop applying, got %+v", s)
+	}
+}
+
+func TestRemovedIgnoredFolderPlansNothing(t *testing.T) {
+	e := newTestEngine(t)
+	e.emptyFolders = EmptyFoldersPrune
+	ctx := context.Background()
+	savePolicy(t, e, storage.FolderPolicy{Path: "work", Ignore: []string{"build/"}})
+	for _, rel := range []string{"work/build", "work/docs"} {
+		folder := &storage.Folder{ID: rel, AccountID: e.accountID, Path: rel, DriveID: "drive-" + rel}
+		if err := e.Store.UpsertFolder(ctx, folder); err != nil {
+			t.Fatalf("UpsertFolder: %v", err)
+		}
+		// The folder is already gone, so its type cannot be read from disk.
+		e.applyLocalEvent(ctx, fswatch.Event{Path: e.absPath(rel), Op: fswatch.OpRemove})
+	}
+	if ops := opTypes(t, e); len(ops) != 1 || ops[0] != opDeleteFolder+" work/docs" {
+		t.Fatalf("expected only the synced folder's removal planned, got %v", ops)
+	}
+}
+
+func TestResolveFolderSettingsSkipsUnparsableRules(t *testing.T) {
+	policies := []storage.FolderPolicy{{Path: "work", Ignore: []string{"[", "*.tmp"}}}
+	s, err := ResolveFolderSettings(FolderSettings{Direction: DirectionBidirectional}, policies, "work/a.tmp")
+	if err != nil {
+		t.Fatalf("ResolveFolderSettings: %v", err)
+	}
+	if len(s.Invalid) != 1 || s.Invalid[0].Pattern != "[" {
+		t.Fatalf("expected the broken pattern reported, got %+v", s.Invalid)
+	}
+	if got := s.Ignores("work/a.tmp", false); got != "*.tmp" {
+		t.Fatalf("expected the remaining rules applied, got %q", got)
+	}
+}
@@ -0,0 +1,360 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+const (
+	// opLinkLocal fills a local projection from content already present for
+	// another projection of the same Drive file.
+	opLinkLocal = "link_local"
+	// opUnlink removes one parent or shortcut of a Drive file while its
+	// content stays reachable elsewhere. Its DriveID is the shortcut itself
+	// when the projection came from one.
+	opUnlink = "unlink"
+)
+
+// ParentRemover takes Drive files out of one of their parent folders. The
+// executor unlinks projections through remotes that implement it.
+type ParentRemover interface {
+	RemoveParent(ctx context.Context, id, parent string) (*driveapi.File, error)
+}
+
+// Projection is one place a Drive file appears under the sync root: a
+// parent folder (legacy multi-parent files have several) or a shortcut.
+type Projection struct {
+	Path       string
+	ParentID   string
+	ShortcutID string
+}
+
+func (p Projection) key() string {
+	return p.ParentID + "\x00" + p.ShortcutID
+}
+
+func projectionOf(rec storage.FileRecord) Projection {
+	return Projection{Path: rec.Path, ParentID: rec.ParentID, ShortcutID: rec.ShortcutID}
+}
+
+// projections returns the local placements a change describes, primary first.
+func (c RemoteChange) projections() []Projection {
+	if len(c.Projections) > 0 {
+		return c.Projections
+	}
+	return []Projection{{Path: c.Path, ParentID: c.ParentID}}
+}
+
+// applyProjectedChange reconciles a change for a file with several local
+// projections. Content is fetched once; each projection is moved, added or
+// removed on its own.
+func (e *Engine) applyProjectedChange(ctx context.Context, change RemoteChange, recs []storage.FileRecord) error {
+	if change.Removed {
+		for _, rec := range recs {
+			if err := e.addOp(ctx, opDeleteLocal, rec.Path, rec.DriveID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	wanted := change.projections()
+	for _, p := range wanted {
+		if p.Path == "" {
+			return errs.New(errs.ErrInvalidArgument, "remote change path is required")
+		}
+	}
+
+	// Pair records with projections by parent or shortcut first; leftovers
+	// are paired in order, which covers a reparent of one projection.
+	matched := make([]*storage.FileRecord, len(wanted))
+	used := make([]bool, len(recs))
+	for i, p := range wanted {
+		for j := range recs {
+			if !used[j] && projectionOf(recs[j]).key() == p.key() {
+				matched[i], used[j] = &recs[j], true
+				break
+			}
+		}
+	}
+	for i := range wanted {
+		if matched[i] != nil {
+			continue
+		}
+		for j := range recs {
+			if !used[j] {
+				matched[i], used[j] = &recs[j], true
+				break
+			}
+		}
+	}
+	for j, rec := range recs {
+		if used[j] {
+			continue
+		}
+		if err := e.addOp(ctx, opDeleteLocal, rec.Path, rec.DriveID); err != nil {
+			return err
+		}
+	}
+
+	if len(recs) == 0 || !sameContent(&recs[0], change) {
+		if err := e.addDownload(ctx, wanted[0].Path, change.DriveID, change.Size); err != nil {
+			return err
+		}
+		for _, p := range wanted[1:] {
+			if err := e.addOp(ctx, opLinkLocal, p.Path, change.DriveID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i, p := range wanted {
+		rec := matched[i]
+		if rec == nil {
+			if err := e.addOp(ctx, opLinkLocal, p.Path, change.DriveID); err != nil {
+				return err
+			}
+			continue
+		}
+		if rec.Path != p.Path {
+			moved, err := e.moveLocal(ctx, rec.Path, p.Path)
+			if err != nil {
+				return err
+			}
+			if !moved {
+				if err := e.addOp(ctx, opLinkLocal, p.Path, change.DriveID); err != nil {
+					return err
+				}
+				continue
+			}
+			e.Logger.Info("remote move applied locally", zap.String("from", rec.Path), zap.String("to", p.Path))
+		}
+		if projectionOf(*rec) == p && (change.ETag == "" || rec.ETag == change.ETag) {
+			continue
+		}
+		rec.Path, rec.ParentID, rec.ShortcutID = p.Path, p.ParentID, p.ShortcutID
+		if change.ETag != "" {
+			rec.ETag = change.ETag
+		}
+		if err := e.Store.UpsertFile(ctx, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// propagateLocalEdit copies an edited projection over its siblings so a
+// single upload covers every local path of the file.
+func (e *Engine) propagateLocalEdit(ctx context.Context, rec *storage.FileRecord) error {
+	if rec.DriveID == "" {
+		return nil
+	}
+	recs, err := e.Store.ListFileProjections(ctx, e.accountID, rec.DriveID)
+	if err != nil {
+		return err
+	}
+	for _, sibling := range recs {
+		if sibling.Path == rec.Path {
+			continue
+		}
+		audited, err := e.auditLocal(ctx, auditCopyLocal, sibling.Path, rec.DriveID, "from "+rec.Path)
+		if err != nil {
+			return err
+		}
+		if audited {
+			continue
+		}
+		e.suppress(sibling.Path)
+		if err := copyLocal(e.absPath(rec.Path), e.absPath(sibling.Path)); err != nil {
+			return err
+		}
+		e.Logger.Debug("local edit propagated", zap.String("from", rec.Path), zap.String("to", sibling.Path))
+	}
+	return nil
+}
+
+// queueLocalDelete plans the remote effect of a tracked file disappearing
+// locally. Removing one of several projections only unlinks that one.
+func (e *Engine) queueLocalDelete(ctx context.Context, rec *storage.FileRecord) error {
+	if rec.DriveID != "" {
+		recs, err := e.Store.ListFileProjections(ctx, e.accountID, rec.DriveID)
+		if err != nil {
+			return err
+		}
+		if len(recs) > 1 {
+			if !e.directionFor(ctx, rec.Path).allows(opUnlink) {
+				return nil
+			}
+			driveID := rec.DriveID
+			if rec.ShortcutID != "" {
+				driveID = rec.ShortcutID
+			}
+			if err := e.addOp(ctx, opUnlink, rec.Path, driveID); err != nil {
+				return err
+			}
+			return e.Store.DeleteFile(ctx, e.accountID, rec.Path)
+		}
+	}
+	return e.addOp(ctx, opDelete, rec.Path, rec.DriveID)
+}
+
+// copyLocal replaces dst with the content of src via a temp file and rename.
+func copyLocal(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".googlysync-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := io.Copy(tmp, in); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// linkLocal fills op.Path with the content of another local projection of
+// the same Drive file. Without one to copy from, the content is downloaded.
+func (x *Executor) linkLocal(ctx context.Context, op storage.PendingOp) error {
+	e := x.engine
+	recs, err := e.Store.ListFileProjections(ctx, e.accountID, op.DriveID)
+	if err != nil {
+		return err
+	}
+	var source *storage.FileRecord
+	for i := range recs {
+		if recs[i].Path == op.Path {
+			continue
+		}
+		if info, err := os.Lstat(e.absPath(recs[i].Path)); err == nil && info.Mode().IsRegular() {
+			source = &recs[i]
+			break
+		}
+	}
+	if source == nil {
+		return x.download(ctx, op)
+	}
+	e.suppress(op.Path)
+	if err := copyLocal(e.absPath(source.Path), e.absPath(op.Path)); err != nil {
+		return err
+	}
+	fast, err := fileFastHash(e.absPath(op.Path))
+	if err != nil {
+		return err
+	}
+
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, op.Path)
+	if err != nil {
+		return err
+	}
+	change := RemoteChange{
+		DriveID:     op.DriveID,
+		Path:        op.Path,
+		ETag:        source.ETag,
+		Checksum:    source.Checksum,
+		Size:        source.Size,
+		ModifiedAt:  source.ModifiedAt,
+		ReadOnly:    source.ReadOnly,
+		MimeType:    source.MimeType,
+		RevisionID:  source.RevisionID,
+		Starred:     source.Starred,
+		Shared:      source.Shared,
+		WebViewLink: source.WebViewLink,
+	}
+	if folder, err := e.Store.GetFolderByPath(ctx, e.accountID, path.Dir(op.Path)); err != nil {
+		return err
+	} else if folder != nil {
+		change.ParentID = folder.DriveID
+	}
+	if err := e.recordRemote(ctx, change, op.Path, rec); err != nil {
+		return err
+	}
+	if err := e.Store.SetFileFastHash(ctx, e.accountID, op.Path, fast); err != nil {
+		return err
+	}
+	e.Logger.Debug("projection filled from local content", zap.String("path", op.Path), zap.String("source", source.Path))
+	return nil
+}
+
+// unlink removes the projection at op.Path from Drive: the shortcut it came
+// from is trashed, or the file is taken out of the parent folder no other
+// projection accounts for. The file itself stays in its other places.
+func (x *Executor) unlink(ctx context.Context, op storage.PendingOp) error {
+	e := x.engine
+	remote, err := x.remote(ctx, "unlink", op.Path)
+	if err != nil {
+		return err
+	}
+	file, err := remote.GetFile(ctx, op.DriveID)
+	if errors.Is(err, errs.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if file.Trashed {
+		return nil
+	}
+	if file.MimeType == driveapi.ShortcutMimeType {
+		trasher, ok := remote.(FileTrasher)
+		if !ok {
+			return errs.New(errs.ErrInvalidArgument, "drive client cannot unlink %s", op.Path)
+		}
+		if _, err := trasher.Trash(ctx, file.ID); err != nil && !errors.Is(err, errs.ErrNotFound) {
+			return err
+		}
+		return nil
+	}
+	remover, ok := remote.(ParentRemover)
+	if !ok {
+		return errs.New(errs.ErrInvalidArgument, "drive client cannot unlink %s", op.Path)
+	}
+	recs, err := e.Store.ListFileProjections(ctx, e.accountID, op.DriveID)
+	if err != nil {
+		return err
+	}
+	var stale []string
+	for _, parent := range file.Parents {
+		if !slices.ContainsFunc(recs, func(rec storage.FileRecord) bool { return rec.ParentID == parent }) {
+			stale = append(stale, parent)
+		}
+	}
+	parent := ""
+	folder, err := e.Store.GetFolderByPath(ctx, e.accountID, path.Dir(op.Path))
+	if err != nil {
+		return err
+	}
+	if folder != nil && slices.Contains(stale, folder.DriveID) {
+		parent = folder.DriveID
+	} else if len(stale) == 1 {
+		parent = stale[0]
+	}
+	// Drive files need a parent; the last one is left alone.
+	if parent == "" || len(file.Parents) < 2 {
+		x.logger.Info("no drive parent left to unlink", zap.String("path", op.Path))
+		return nil
+	}
+	_, err = remover.RemoveParent(ctx, op.DriveID, parent)
+	return err
+}
@@ -0,0 +1,186 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+func trackProjection(t *testing.T, e *Engine, id, rel, driveID, parentID, shortcutID string) {
+	t.Helper()
+	abs := e.absPath(rel)
+	if err := os.MkdirAll(filepath.Dir(abs), 0o700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(abs, []byte("content"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	rec := &storage.FileRecord{
+		ID:         id,
+		AccountID:  e.accountID,
+		Path:       rel,
+		DriveID:    driveID,
+		ParentID:   parentID,
+		ShortcutID: shortcutID,
+		Size:       int64(len("content")),
+		Checksum:   "chk",
+	}
+	if err := e.Store.UpsertFile(context.Background(), rec); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+}
+
+func TestRemoteChangeDownloadsOnceForProjections(t *testing.T) {
+	e := newTestEngine(t)
+	change := RemoteChange{
+		DriveID:  "drive-a",
+		Checksum: "chk-new",
+		Size:     10,
+		Projections: []Projection{
+			{Path: "a/report.txt", ParentID: "folder-a"},
+			{Path: "b/report.txt", ParentID: "folder-b"},
+			{Path: "links/report.txt", ShortcutID: "shortcut-1"},
+		},
+	}
+	if err := e.ApplyRemoteChange(context.Background(), change); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	got := opTypes(t, e)
+	want := []string{"download a/report.txt", "link_local b/report.txt", "link_local links/report.txt"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestRemoteProjectionRemovedOnlyDeletesThatPath(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	trackProjection(t, e, "file-1", "a/report.txt", "drive-a", "folder-a", "")
+	trackProjection(t, e, "file-2", "b/report.txt", "drive-a", "folder-b", "")
+
+	change := RemoteChange{DriveID: "drive-a", Path: "a/report.txt", ParentID: "folder-a", Checksum: "chk", Size: int64(len("content"))}
+	if err := e.ApplyRemoteChange(ctx, change); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	got := opTypes(t, e)
+	if len(got) != 1 || got[0] != "delete_local b/report.txt" {
+		t.Fatalf("expected only the dropped projection deleted, got %v", got)
+	}
+}
+
+func TestLocalEditPropagatesToProjections(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	trackProjection(t, e, "file-1", "a/report.txt", "drive-a", "folder-a", "")
+	trackProjection(t, e, "file-2", "links/report.txt", "drive-a", "", "shortcut-1")
+
+	if err := os.WriteFile(e.absPath("a/report.txt"), []byte("edited"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := e.noteCreate(ctx, "a/report.txt"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+	data, err := os.ReadFile(e.absPath("links/report.txt"))
+	if err != nil || string(data) != "edited" {
+		t.Fatalf("expected sibling updated, got %q, %v", data, err)
+	}
+	if !e.isSuppressed("links/report.txt", time.Now()) {
+		t.Fatal("expected sibling write to be suppressed")
+	}
+	got := opTypes(t, e)
+	if len(got) != 1 || got[0] != "upload a/report.txt" {
+		t.Fatalf("expected a single upload, got %v", got)
+	}
+}
+
+func TestLocalDeleteOfProjectionUnlinks(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	trackProjection(t, e, "file-1", "a/report.txt", "drive-a", "folder-a", "")
+	trackProjection(t, e, "file-2", "b/report.txt", "drive-a", "folder-b", "")
+
+	if err := os.Remove(e.absPath("b/report.txt")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := e.noteRemoval(ctx, "b/report.txt"); err != nil {
+		t.Fatalf("noteRemoval: %v", err)
+	}
+	e.flushRemovals(ctx, time.Now().Add(2*renameWindow))
+
+	got := opTypes(t, e)
+	if len(got) != 1 || got[0] != "unlink b/report.txt" {
+		t.Fatalf("expected unlink op, got %v", got)
+	}
+	if rec, err := e.Store.GetFileByPath(ctx, e.accountID, "b/report.txt"); err != nil || rec != nil {
+		t.Fatalf("expected projection record removed, got %#v, %v", rec, err)
+	}
+	if rec, err := e.Store.GetFileByPath(ctx, e.accountID, "a/report.txt"); err != nil || rec == nil {
+		t.Fatalf("expected other projection kept, got %#v, %v", rec, err)
+	}
+}
+
+func TestExecutorLinksAndUnlinksProjections(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	trackProjection(t, e, "file-1", "a/report.txt", "drive-a", "folder-a", "")
+	trackProjection(t, e, "file-2", "links/report.txt", "drive-a", "", "shortcut-1")
+	if err := e.ApplyRemoteChange(ctx, remoteFolder("folder-b", "b")); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	drive := newFakeDrive()
+	drive.files["drive-a"] = driveapi.File{ID: "drive-a", Name: "report.txt", Parents: []string{"folder-a", "folder-b"}}
+	drive.files["shortcut-1"] = driveapi.File{ID: "shortcut-1", Name: "report.txt", MimeType: driveapi.ShortcutMimeType}
+	x := newTestExecutor(t, e, drive)
+
+	change := RemoteChange{
+		DriveID:  "drive-a",
+		Checksum: "chk",
+		Size:     int64(len("content")),
+		Projections: []Projection{
+			{Path: "a/report.txt", ParentID: "folder-a"},
+			{Path: "b/report.txt", ParentID: "folder-b"},
+			{Path: "links/report.txt", ShortcutID: "shortcut-1"},
+		},
+	}
+	if err := e.ApplyRemoteChange(ctx, change); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	if data, err := os.ReadFile(e.absPath("b/report.txt")); err != nil || string(data) != "content" {
+		t.Fatalf("expected the projection filled locally, got %q, %v", data, err)
+	}
+	if rec, _ := e.Store.GetFileByPath(ctx, e.accountID, "b/report.txt"); rec == nil || rec.DriveID != "drive-a" || rec.ParentID != "folder-b" {
+		t.Fatalf("expected the projection indexed, got %#v", rec)
+	}
+
+	for _, rel := range []string{"b/report.txt", "links/report.txt"} {
+		if err := os.Remove(e.absPath(rel)); err != nil {
+			t.Fatalf("Remove: %v", err)
+		}
+		if err := e.noteRemoval(ctx, rel); err != nil {
+			t.Fatalf("noteRemoval: %v", err)
+		}
+	}
+	e.flushRemovals(ctx, time.Now().Add(2*renameWindow))
+	if done, err := x.RunOnce(ctx); err != nil || done != 2 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	if file := drive.files["drive-a"]; file.Trashed || len(file.Parents) != 1 || file.Parents[0] != "folder-a" {
+		t.Fatalf("expected the file taken out of folder-b only, got %#v", file)
+	}
+	if !drive.files["shortcut-1"].Trashed || drive.calls["Trash"] != 1 {
+		t.Fatalf("expected the shortcut trashed, got %#v", drive.files["shortcut-1"])
+	}
+}
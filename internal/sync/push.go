@@ -0,0 +1,219 @@
+package sync
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	gosync "sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+)
+
+const (
+	// pushChannelTTL is the lifetime asked for each watch channel.
+	pushChannelTTL = 24 * time.Hour
+	// pushRenewMargin is how long before expiry a channel is replaced.
+	pushRenewMargin = 10 * time.Minute
+	// pushRetryWait is how long to wait before trying again when a channel
+	// cannot be opened.
+	pushRetryWait = time.Minute
+)
+
+// ChangeWatcher opens and stops Drive push notification channels for the
+// changes feed.
+type ChangeWatcher interface {
+	ChangeFeed
+	WatchChanges(ctx context.Context, pageToken string, ch driveapi.Channel) (*driveapi.Channel, error)
+	StopChannel(ctx context.Context, ch driveapi.Channel) error
+}
+
+// ChangePush receives Drive push notifications for the changes feed and
+// has the poller read the feed as soon as one arrives. Drive posts to
+// address, which is either this receiver behind an HTTPS proxy or a relay
+// that forwards the requests to listen. Until Drive's first message on a
+// channel is seen, or whenever a channel cannot be kept open, the poller
+// keeps its own schedule.
+type ChangePush struct {
+	logger  *zap.Logger
+	poller  *ChangePoller
+	remotes RemoteFunc
+	address string
+	listen  string
+
+	mu gosync.Mutex
+	// channels holds the token of every channel notifications are accepted
+	// on. The previous channel stays until its replacement is open.
+	channels map[string]string
+	current  *driveapi.Channel
+}
+
+// NewChangePush constructs a receiver. It does nothing unless both
+// changes_push_url and changes_push_listen are configured.
+func NewChangePush(logger *zap.Logger, poller *ChangePoller, remotes RemoteFunc) *ChangePush {
+	push := &ChangePush{logger: logger, poller: poller, remotes: remotes, channels: make(map[string]string)}
+	if c := poller.engine.Config; c != nil {
+		push.address, push.listen = c.ChangesPushURL, c.ChangesPushListen
+	}
+	return push
+}
+
+// Run serves notifications and keeps a channel open until ctx is done, then
+// stops the channel.
+func (p *ChangePush) Run(ctx context.Context) {
+	if p.address == "" {
+		return
+	}
+	if p.listen == "" {
+		p.logger.Warn("changes_push_url is set without changes_push_listen; polling only")
+		return
+	}
+	ln, err := net.Listen("tcp", p.listen)
+	if err != nil {
+		p.logger.Warn("push receiver listen failed; polling only", zap.Error(err))
+		return
+	}
+	server := &http.Server{Handler: p, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			p.logger.Warn("push receiver stopped", zap.Error(err))
+		}
+	}()
+	p.logger.Info("push receiver listening", zap.String("addr", ln.Addr().String()))
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			p.poller.setPushed(false)
+			stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			p.stop(stopCtx)
+			_ = server.Shutdown(stopCtx)
+			cancel()
+			return
+		case <-timer.C:
+			timer.Reset(p.renew(ctx))
+		}
+	}
+}
+
+// renew replaces the current channel with a fresh one and returns how long
+// to wait before the next renewal.
+func (p *ChangePush) renew(ctx context.Context) time.Duration {
+	ch, err := p.open(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			p.logger.Warn("open push channel failed; polling instead", zap.Error(err))
+		}
+		p.poller.setPushed(false)
+		return pushRetryWait
+	}
+	if ch == nil {
+		p.poller.setPushed(false)
+		return pushRetryWait
+	}
+	return max(time.Until(ch.Expiration)-pushRenewMargin, pushRetryWait)
+}
+
+// open asks Drive for a new channel from the stored page token. It returns
+// nil while signed out.
+func (p *ChangePush) open(ctx context.Context) (*driveapi.Channel, error) {
+	remote := p.remotes(ctx)
+	if remote == nil {
+		return nil, nil
+	}
+	watcher, ok := remote.(ChangeWatcher)
+	if !ok {
+		return nil, nil
+	}
+	state, _, err := p.poller.syncState(ctx, watcher)
+	if err != nil {
+		return nil, err
+	}
+	id, err := newID("channel-")
+	if err != nil {
+		return nil, err
+	}
+	token, err := newID("")
+	if err != nil {
+		return nil, err
+	}
+	// Drive confirms a channel with a message that can arrive before the
+	// watch call returns, so the channel is accepted from the start.
+	p.mu.Lock()
+	p.channels[id] = token
+	p.mu.Unlock()
+	req := driveapi.Channel{ID: id, Token: token, Address: p.address, Expiration: time.Now().Add(pushChannelTTL)}
+	ch, err := watcher.WatchChanges(ctx, state.StartPageToken, req)
+	if err != nil {
+		p.mu.Lock()
+		delete(p.channels, id)
+		p.mu.Unlock()
+		return nil, err
+	}
+	if ch.Expiration.IsZero() {
+		ch.Expiration = req.Expiration
+	}
+
+	p.mu.Lock()
+	previous := p.current
+	p.current = ch
+	if previous != nil {
+		delete(p.channels, previous.ID)
+	}
+	p.mu.Unlock()
+	if previous != nil {
+		if err := watcher.StopChannel(ctx, *previous); err != nil {
+			p.logger.Debug("stop replaced push channel failed", zap.Error(err))
+		}
+	}
+	p.logger.Info("push channel open", zap.String("channel", ch.ID), zap.Time("expires", ch.Expiration))
+	return ch, nil
+}
+
+// stop closes the current channel, if any.
+func (p *ChangePush) stop(ctx context.Context) {
+	p.mu.Lock()
+	ch := p.current
+	p.current = nil
+	clear(p.channels)
+	p.mu.Unlock()
+	if ch == nil {
+		return
+	}
+	remote := p.remotes(ctx)
+	if watcher, ok := remote.(ChangeWatcher); ok {
+		if err := watcher.StopChannel(ctx, *ch); err != nil {
+			p.logger.Debug("stop push channel failed", zap.Error(err))
+		}
+	}
+}
+
+// ServeHTTP handles one notification. Drive's first message on a channel
+// has resource state "sync" and shows the receiver is reachable; every
+// later one means the feed has something new.
+func (p *ChangePush) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.Header.Get("X-Goog-Channel-ID")
+	p.mu.Lock()
+	token, ok := p.channels[id]
+	p.mu.Unlock()
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(r.Header.Get("X-Goog-Channel-Token"))) != 1 {
+		http.Error(w, "unknown channel", http.StatusForbidden)
+		return
+	}
+	if r.Header.Get("X-Goog-Resource-State") == "sync" {
+		p.poller.setPushed(true)
+	} else {
+		p.poller.Notify()
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,105 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+)
+
+type fakeWatcher struct {
+	fakeFeed
+	opened  *[]driveapi.Channel
+	stopped *[]string
+}
+
+func (f fakeWatcher) WatchChanges(_ context.Context, pageToken string, ch driveapi.Channel) (*driveapi.Channel, error) {
+	if pageToken != f.start {
+		return nil, context.Canceled
+	}
+	ch.ResourceID = "res-" + ch.ID
+	*f.opened = append(*f.opened, ch)
+	return &ch, nil
+}
+
+func (f fakeWatcher) StopChannel(_ context.Context, ch driveapi.Channel) error {
+	*f.stopped = append(*f.stopped, ch.ID)
+	return nil
+}
+
+func notification(ch driveapi.Channel, state string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("X-Goog-Channel-ID", ch.ID)
+	r.Header.Set("X-Goog-Channel-Token", ch.Token)
+	r.Header.Set("X-Goog-Resource-State", state)
+	return r
+}
+
+func TestChangePushWakesPoller(t *testing.T) {
+	e := newTestEngine(t)
+	e.Config.ChangesPushURL = "https://relay.example/hook"
+	ctx := context.Background()
+	var opened []driveapi.Channel
+	var stopped []string
+	reads := 0
+	remote := fakeWatcher{fakeFeed: fakeFeed{start: "7", reads: &reads}, opened: &opened, stopped: &stopped}
+	poller := NewChangePoller(zap.NewNop(), e, func(context.Context) RemoteFiles { return remote })
+	push := NewChangePush(zap.NewNop(), poller, func(context.Context) RemoteFiles { return remote })
+
+	if wait := push.renew(ctx); wait < pushChannelTTL-pushRenewMargin-time.Minute {
+		t.Fatalf("expected renewal near expiry, got %v", wait)
+	}
+	if len(opened) != 1 || opened[0].Address != "https://relay.example/hook" || opened[0].Token == "" {
+		t.Fatalf("unexpected channels %#v", opened)
+	}
+	first := opened[0]
+
+	forged := first
+	forged.Token = "guess"
+	rec := httptest.NewRecorder()
+	push.ServeHTTP(rec, notification(forged, "change"))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected forged notification rejected, got %d", rec.Code)
+	}
+
+	// Until Drive confirms the channel the poller keeps its own schedule.
+	if poller.nextWait(poller.min, false) == poller.max {
+		t.Fatal("expected backoff while the channel is unconfirmed")
+	}
+	rec = httptest.NewRecorder()
+	push.ServeHTTP(rec, notification(first, "sync"))
+	if rec.Code != http.StatusOK || !poller.pushed.Load() {
+		t.Fatalf("expected sync message to confirm push, got %d", rec.Code)
+	}
+	if got := poller.nextWait(poller.min, true); got != poller.max {
+		t.Fatalf("expected slow polling while pushed, got %v", got)
+	}
+	push.ServeHTTP(httptest.NewRecorder(), notification(first, "change"))
+	select {
+	case <-poller.wake:
+	default:
+		t.Fatal("expected change notification to wake the poller")
+	}
+
+	// Renewal replaces the channel and stops the old one.
+	push.renew(ctx)
+	if len(opened) != 2 || len(stopped) != 1 || stopped[0] != first.ID {
+		t.Fatalf("expected old channel stopped, got opened %d, stopped %v", len(opened), stopped)
+	}
+	rec = httptest.NewRecorder()
+	push.ServeHTTP(rec, notification(first, "change"))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected replaced channel rejected, got %d", rec.Code)
+	}
+
+	// Losing the account falls back to polling.
+	push.remotes = func(context.Context) RemoteFiles { return nil }
+	if wait := push.renew(ctx); wait != pushRetryWait || poller.pushed.Load() {
+		t.Fatalf("expected fallback to polling, got wait %v", wait)
+	}
+}
@@ -1,15 +1,51 @@
 package sync
 
 import (
+	"container/heap"
+	"os"
+	gosync "sync"
+
 	"go.uber.org/zap"
 
 	"github.com/sandeepkv93/googlysync/internal/fswatch"
 )
 
-// Queue buffers filesystem events for processing.
+// Priority orders queued events. Lower values are processed first.
+type Priority int
+
+const (
+	// PriorityUser is for actions the user asked for explicitly.
+	PriorityUser Priority = iota
+	// PrioritySmall is for removals and changes to small files, which are
+	// cheap enough to clear ahead of larger work.
+	PrioritySmall
+	// PriorityNormal is for changes to files larger than smallFileSize.
+	PriorityNormal
+	// PriorityBulk is for initial sync and other background sweeps.
+	PriorityBulk
+)
+
+// smallFileSize is the largest file whose changes get PrioritySmall.
+const smallFileSize = 1 << 20
+
+// Queue buffers filesystem events for processing in priority order. Events
+// of equal priority keep their arrival order.
 type Queue struct {
-	logger *zap.Logger
-	ch     chan fswatch.Event
+	logger   *zap.Logger
+	capacity int
+	ready    chan struct{}
+
+	mu     gosync.Mutex
+	items  queueHeap
+	writes map[string]*queueItem
+	seq    uint64
+}
+
+type queueItem struct {
+	evt      fswatch.Event
+	priority Priority
+	seq      uint64
+	index    int
 }
 
 // NewQueue constructs a queue with the given capacity.
@@ -17,19 +53,160 @@ func NewQueue(logger *zap.Logger, capacity int) *Queue {
 	if capacity <= 0 {
 		capacity = 1024
 	}
-	return &Queue{logger: logger, ch: make(chan fswatch.Event, capacity)}
+	return &Queue{
+		logger:   logger,
+		capacity: capacity,
+		ready:    make(chan struct{}, 1),
+		writes:   make(map[string]*queueItem),
+	}
 }
 
-// Enqueue adds an event to the queue.
+// Enqueue adds an event with a priority derived from its operation and the
+// current size of the file.
 func (q *Queue) Enqueue(evt fswatch.Event) {
+	q.EnqueuePriority(evt, classify(evt))
+}
+
+// EnqueuePriority adds an event with an explicit priority.
+//
+// A create or write for a path that is already queued is merged into the
+// queued event, since processing reads the file's current state anyway. A
+// removal drops any queued create or write for the path, so a file deleted
+// before it was picked up is never uploaded.
+func (q *Queue) EnqueuePriority(evt fswatch.Event, priority Priority) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	isWrite := evt.Op == fswatch.OpCreate || evt.Op == fswatch.OpWrite
+	if queued, ok := q.writes[evt.Path]; ok {
+		if isWrite {
+			if priority < queued.priority {
+				queued.priority = priority
+				heap.Fix(&q.items, queued.index)
+			}
+			return
+		}
+		if evt.Op == fswatch.OpRemove || evt.Op == fswatch.OpRename {
+			heap.Remove(&q.items, queued.index)
+			delete(q.writes, evt.Path)
+		}
+	}
+
+	if len(q.items) >= q.capacity {
+		worst := q.items.worst()
+		if worst == nil || worst.priority <= priority {
+			q.logger.Warn("sync queue full; dropping event", zap.String("path", evt.Path))
+			return
+		}
+		q.logger.Warn("sync queue full; dropping lower priority event", zap.String("path", worst.evt.Path))
+		q.remove(worst)
+	}
+
+	q.seq++
+	item := &queueItem{evt: evt, priority: priority, seq: q.seq}
+	heap.Push(&q.items, item)
+	if isWrite {
+		q.writes[evt.Path] = item
+	}
+	q.signal()
+}
+
+// Ready returns a channel that receives a value when events are waiting.
+// Drain it with Pop.
+func (q *Queue) Ready() <-chan struct{} {
+	return q.ready
+}
+
+// Pop removes and returns the highest priority event.
+func (q *Queue) Pop() (fswatch.Event, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return fswatch.Event{}, false
+	}
+	item := heap.Pop(&q.items).(*queueItem)
+	if q.writes[item.evt.Path] == item {
+		delete(q.writes, item.evt.Path)
+	}
+	if len(q.items) > 0 {
+		q.signal()
+	}
+	return item.evt, true
+}
+
+// Len returns the number of queued events.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+func (q *Queue) remove(item *queueItem) {
+	heap.Remove(&q.items, item.index)
+	if q.writes[item.evt.Path] == item {
+		delete(q.writes, item.evt.Path)
+	}
+}
+
+func (q *Queue) signal() {
 	select {
-	case q.ch <- evt:
+	case q.ready <- struct{}{}:
 	default:
-		q.logger.Warn("sync queue full; dropping event", zap.String("path", evt.Path))
 	}
 }
 
-// Channel returns a receive-only channel for events.
-func (q *Queue) Channel() <-chan fswatch.Event {
-	return q.ch
+func classify(evt fswatch.Event) Priority {
+	if evt.Op != fswatch.OpCreate && evt.Op != fswatch.OpWrite {
+		return PrioritySmall
+	}
+	info, err := os.Stat(evt.Path)
+	if err != nil || info.IsDir() || info.Size() <= smallFileSize {
+		return PrioritySmall
+	}
+	return PriorityNormal
+}
+
+// queueHeap implements heap.Interface ordered by priority, then arrival.
+type queueHeap []*queueItem
+
+func (h queueHeap) Len() int { return len(h) }
+
+func (h queueHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority < h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h queueHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *queueHeap) Push(x any) {
+	item := x.(*queueItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *queueHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+// worst returns the item that would be processed last. The maximum of a
+// min-heap is always among its leaves.
+func (h queueHeap) worst() *queueItem {
+	var worst *queueItem
+	for i := len(h) / 2; i < len(h); i++ {
+		if worst == nil || h.Less(worst.index, i) {
+			worst = h[i]
+		}
+	}
+	return worst
 }
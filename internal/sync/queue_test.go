@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/fswatch"
+)
+
+func popAll(q *Queue) []string {
+	var out []string
+	for {
+		evt, ok := q.Pop()
+		if !ok {
+			return out
+		}
+		out = append(out, filepath.Base(evt.Path)+":"+fswatch.OpString(evt.Op))
+	}
+}
+
+func TestQueuePriorityOrder(t *testing.T) {
+	dir := t.TempDir()
+	big := filepath.Join(dir, "big.bin")
+	if err := os.WriteFile(big, make([]byte, smallFileSize+1), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	small := filepath.Join(dir, "small.txt")
+	if err := os.WriteFile(small, []byte("x"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	q := NewQueue(zap.NewNop(), 0)
+	q.EnqueuePriority(fswatch.Event{Path: filepath.Join(dir, "initial.txt"), Op: fswatch.OpCreate}, PriorityBulk)
+	q.Enqueue(fswatch.Event{Path: big, Op: fswatch.OpWrite})
+	q.Enqueue(fswatch.Event{Path: small, Op: fswatch.OpWrite})
+	q.Enqueue(fswatch.Event{Path: filepath.Join(dir, "gone.txt"), Op: fswatch.OpRemove})
+	q.EnqueuePriority(fswatch.Event{Path: filepath.Join(dir, "asked.txt"), Op: fswatch.OpWrite}, PriorityUser)
+
+	select {
+	case <-q.Ready():
+	default:
+		t.Fatal("expected ready signal")
+	}
+	got := popAll(q)
+	want := []string{"asked.txt:WRITE", "small.txt:WRITE", "gone.txt:REMOVE", "big.bin:WRITE", "initial.txt:CREATE"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestQueueRemoveCancelsQueuedWrite(t *testing.T) {
+	q := NewQueue(zap.NewNop(), 0)
+	q.EnqueuePriority(fswatch.Event{Path: "/root/a.iso", Op: fswatch.OpCreate}, PriorityNormal)
+	q.EnqueuePriority(fswatch.Event{Path: "/root/a.iso", Op: fswatch.OpWrite}, PriorityUser)
+	if q.Len() != 1 {
+		t.Fatalf("expected writes merged, got %d queued", q.Len())
+	}
+	q.EnqueuePriority(fswatch.Event{Path: "/root/b.txt", Op: fswatch.OpWrite}, PrioritySmall)
+	if got := popAll(q); len(got) != 2 || got[0] != "a.iso:CREATE" {
+		t.Fatalf("expected merged write to keep its op and gain priority, got %v", got)
+	}
+
+	q.EnqueuePriority(fswatch.Event{Path: "/root/a.iso", Op: fswatch.OpCreate}, PriorityBulk)
+	q.EnqueuePriority(fswatch.Event{Path: "/root/a.iso", Op: fswatch.OpRemove}, PrioritySmall)
+	if got := popAll(q); len(got) != 1 || got[0] != "a.iso:REMOVE" {
+		t.Fatalf("expected only the removal left, got %v", got)
+	}
+}
+
+func TestQueueFullEvictsLowerPriority(t *testing.T) {
+	q := NewQueue(zap.NewNop(), 2)
+	q.EnqueuePriority(fswatch.Event{Path: "/root/bulk", Op: fswatch.OpCreate}, PriorityBulk)
+	q.EnqueuePriority(fswatch.Event{Path: "/root/normal", Op: fswatch.OpCreate}, PriorityNormal)
+	q.EnqueuePriority(fswatch.Event{Path: "/root/late-bulk", Op: fswatch.OpCreate}, PriorityBulk)
+	q.EnqueuePriority(fswatch.Event{Path: "/root/user", Op: fswatch.OpCreate}, PriorityUser)
+
+	got := popAll(q)
+	if len(got) != 2 || got[0] != "user:CREATE" || got[1] != "normal:CREATE" {
+		t.Fatalf("expected bulk work evicted first, got %v", got)
+	}
+}
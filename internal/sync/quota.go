@@ -0,0 +1,135 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/notify"
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// defaultQuotaPoll is how often the executor refreshes the Drive quota when
+// the config does not say.
+const defaultQuotaPoll = 15 * time.Minute
+
+// defaultQuotaAlertPercent is the usage that fires the quota webhook when the
+// config does not say.
+const defaultQuotaAlertPercent = 90
+
+// QuotaSource reports the Drive storage quota. Remotes that implement it are
+// polled for quota usage.
+type QuotaSource interface {
+	GetQuota(ctx context.Context) (*driveapi.Quota, error)
+}
+
+// quotaOpTypes are the ops that add to Drive storage use. They are held while
+// the account is full.
+var quotaOpTypes = map[string]bool{
+	opUpload: true,
+	opCopy:   true,
+}
+
+// quotaPoll returns the configured quota refresh interval.
+func (x *Executor) quotaPoll() time.Duration {
+	if c := x.engine.Config; c != nil && c.QuotaPollSeconds > 0 {
+		return time.Duration(c.QuotaPollSeconds) * time.Second
+	}
+	return defaultQuotaPoll
+}
+
+// quotaAlertPercent returns the usage percentage that fires the quota webhook.
+func (x *Executor) quotaAlertPercent() int {
+	if c := x.engine.Config; c != nil && c.QuotaAlertPercent > 0 {
+		return c.QuotaAlertPercent
+	}
+	return defaultQuotaAlertPercent
+}
+
+// LoadQuota publishes the quota stored by a previous run, so uploads stay held
+// until a fresh check says the account has room again.
+func (x *Executor) LoadQuota(ctx context.Context) error {
+	e := x.engine
+	q, err := e.Store.GetQuota(ctx, e.accountID)
+	if err != nil || q == nil {
+		return err
+	}
+	x.applyQuota(driveapi.Quota{Limit: q.LimitBytes, Usage: q.UsageBytes, UsageInDrive: q.DriveBytes, UsageInTrash: q.TrashBytes}, false)
+	return nil
+}
+
+// CheckQuota fetches the account's Drive quota, stores it and holds or
+// releases uploads depending on whether the account is full. It does nothing
+// when no account is signed in.
+func (x *Executor) CheckQuota(ctx context.Context) error {
+	if x.remotes == nil {
+		return nil
+	}
+	src, ok := x.remotes(ctx).(QuotaSource)
+	if !ok {
+		return nil
+	}
+	q, err := src.GetQuota(ctx)
+	if err != nil {
+		return err
+	}
+	e := x.engine
+	if err := e.Store.SaveQuota(ctx, &storage.Quota{
+		AccountID:  e.accountID,
+		LimitBytes: q.Limit,
+		UsageBytes: q.Usage,
+		DriveBytes: q.UsageInDrive,
+		TrashBytes: q.UsageInTrash,
+		CheckedAt:  x.now(),
+	}); err != nil {
+		return err
+	}
+	x.applyQuota(*q, true)
+	return nil
+}
+
+// applyQuota publishes q. With alert set, crossing the configured usage
+// threshold upwards fires the quota webhook; a stored quota only primes the
+// threshold so a restart does not repeat the alert.
+func (x *Executor) applyQuota(q driveapi.Quota, alert bool) {
+	e := x.engine
+	if e.Status != nil {
+		e.Status.SetQuota(q.Limit, q.Usage)
+	}
+	above := q.Limit > 0 && q.Usage*100 >= q.Limit*int64(x.quotaAlertPercent())
+	if above && !x.quotaAlerted && alert {
+		e.Webhooks.Fire(e.accountID, notify.EventQuotaThreshold, map[string]any{
+			"limit_bytes": q.Limit,
+			"usage_bytes": q.Usage,
+			"percent":     q.Usage * 100 / q.Limit,
+		})
+	}
+	x.quotaAlerted = above
+	x.setQuotaFull(q.Full(), fmt.Sprintf("drive storage full: %d of %d MiB used (%d MiB in trash); uploads held",
+		q.Usage>>20, q.Limit>>20, q.UsageInTrash>>20))
+}
+
+func (x *Executor) setQuotaFull(full bool, msg string) {
+	if full == x.quotaFull {
+		return
+	}
+	x.quotaFull = full
+	e := x.engine
+	if full {
+		x.logger.Warn("uploads held for drive quota", zap.String("reason", msg))
+	} else {
+		x.logger.Info("drive quota available; uploads resumed")
+	}
+	if e.Status == nil {
+		return
+	}
+	if full {
+		e.Status.Update(status.Snapshot{State: status.StateQuotaExceeded, Message: msg})
+	} else if e.Status.Current().State == status.StateQuotaExceeded {
+		e.Status.Update(status.Snapshot{State: status.StateIdle, Message: "drive quota available"})
+	}
+}
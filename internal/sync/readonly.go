@@ -0,0 +1,145 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/notify"
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// statusReadOnly labels a local edit to a file the account may not change on
+// Drive.
+const statusReadOnly = "READ_ONLY"
+
+// applyPermissions makes the local copy at rel match its Drive permission:
+// read-only files lose their write bits and files that became editable get
+// owner write back. Missing files and anything but regular files are left
+// alone, as is the sync root in audit mode.
+func (e *Engine) applyPermissions(rel string, readOnly bool) error {
+	if e.auditOnly {
+		return nil
+	}
+	abs := e.absPath(rel)
+	info, err := os.Lstat(abs)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	perm := info.Mode().Perm()
+	want := perm | 0o200
+	if readOnly {
+		want = perm &^ 0o222
+	}
+	if want == perm {
+		return nil
+	}
+	return os.Chmod(abs, want)
+}
+
+// notePermissionChange records a change in edit rights for a file whose
+// content and path are unchanged.
+func (e *Engine) notePermissionChange(ctx context.Context, rec *storage.FileRecord, readOnly bool) error {
+	rec.ReadOnly = readOnly
+	if err := e.Store.UpsertFile(ctx, rec); err != nil {
+		return err
+	}
+	e.Logger.Info("drive edit permission changed", zap.String("path", rec.Path), zap.Bool("read_only", readOnly))
+	return e.applyPermissions(rec.Path, readOnly)
+}
+
+// divertReadOnlyEdit handles a local edit to a file the account cannot
+// change on Drive. Uploading it would only fail with 403 until the retries
+// ran out, so the edited content is copied aside as a new file and the Drive
+// version is downloaded back in its place.
+func (e *Engine) divertReadOnlyEdit(ctx context.Context, rec *storage.FileRecord) error {
+	copyRel, err := e.conflictCopyPath(ctx, rec.Path, time.Now())
+	if err != nil {
+		return err
+	}
+	if audited, err := e.auditLocal(ctx, auditCopyLocal, copyRel, "", "read-only edit of "+rec.Path); err != nil || audited {
+		return err
+	}
+	e.suppress(copyRel)
+	if err := copyLocal(e.absPath(rec.Path), e.absPath(copyRel)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if err := e.queueNewUpload(ctx, copyRel); err != nil {
+		return err
+	}
+	if err := e.addDownload(ctx, rec.Path, rec.DriveID, rec.Size); err != nil {
+		return err
+	}
+	e.Logger.Warn("edit to read-only file saved as a copy",
+		zap.String("path", rec.Path), zap.String("copy", copyRel))
+	if err := e.Store.AddConflict(ctx, &storage.ConflictRecord{
+		AccountID:      e.accountID,
+		Path:           rec.Path,
+		DriveID:        rec.DriveID,
+		BaseRevision:   revisionOf(rec.RevisionID, rec.Checksum),
+		RemoteRevision: revisionOf(rec.RevisionID, rec.Checksum),
+		CopyPath:       copyRel,
+	}); err != nil {
+		return err
+	}
+	e.recordHistory(ctx, storage.HistoryEntry{Op: historyConflict, Path: rec.Path, DriveID: rec.DriveID, Detail: copyRel, Outcome: storage.HistoryDetected})
+	if e.Status != nil {
+		e.Status.AddEvent(status.Event{Op: statusReadOnly, Path: rec.Path + " -> " + copyRel})
+	}
+	if e.Webhooks != nil {
+		e.Webhooks.Fire(e.accountID, notify.EventConflictDetected, map[string]any{
+			"path":     rec.Path,
+			"drive_id": rec.DriveID,
+			"copy":     copyRel,
+		})
+	}
+	return nil
+}
+
+// conflictCopyPath picks a free sibling path such as
+// "report (conflicted copy 2024-05-01).txt" for rel, numbering it when that
+// name is already taken on disk or in the index.
+func (e *Engine) conflictCopyPath(ctx context.Context, rel string, now time.Time) (string, error) {
+	dir, base := path.Split(rel)
+	ext := path.Ext(base)
+	if ext == base {
+		ext = ""
+	}
+	stem := strings.TrimSuffix(base, ext) + " (conflicted copy " + now.Format("2006-01-02")
+	for n := 1; ; n++ {
+		suffix := ")"
+		if n > 1 {
+			suffix = fmt.Sprintf(" %d)", n)
+		}
+		candidate := dir + stem + suffix + ext
+		if _, err := os.Lstat(e.absPath(candidate)); err == nil {
+			continue
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		rec, err := e.Store.GetFileByPath(ctx, e.accountID, candidate)
+		if err != nil {
+			return "", err
+		}
+		if rec == nil {
+			return candidate, nil
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+func TestRemotePermissionAppliedLocally(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	indexFile(t, e, "shared.txt", "d-shared", "view only")
+
+	change := RemoteChange{
+		DriveID:  "d-shared",
+		Path:     "shared.txt",
+		Checksum: md5Hex("view only"),
+		Size:     int64(len("view only")),
+		ReadOnly: true,
+	}
+	if err := e.ApplyRemoteChange(ctx, change); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, "shared.txt")
+	if err != nil || rec == nil || !rec.ReadOnly {
+		t.Fatalf("expected record marked read-only, got %#v (%v)", rec, err)
+	}
+	info, err := os.Stat(e.absPath("shared.txt"))
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Mode().Perm()&0o222 != 0 {
+		t.Fatalf("expected no write bits, got %v", info.Mode().Perm())
+	}
+	if ops := opTypes(t, e); len(ops) != 0 {
+		t.Fatalf("expected a permission change to queue nothing, got %v", ops)
+	}
+
+	change.ReadOnly = false
+	if err := e.ApplyRemoteChange(ctx, change); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	info, err = os.Stat(e.absPath("shared.txt"))
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Mode().Perm()&0o200 == 0 {
+		t.Fatalf("expected owner write restored, got %v", info.Mode().Perm())
+	}
+}
+
+func TestReadOnlyEditBecomesConflictCopy(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	indexFile(t, e, "docs/plan.txt", "d-plan", "original")
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, "docs/plan.txt")
+	if err != nil {
+		t.Fatalf("GetFileByPath: %v", err)
+	}
+	rec.ReadOnly = true
+	if err := e.Store.UpsertFile(ctx, rec); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+
+	if err := os.WriteFile(e.absPath("docs/plan.txt"), []byte("my edits"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := e.noteCreate(ctx, "docs/plan.txt"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+
+	copyRel := "docs/plan (conflicted copy " + time.Now().Format("2006-01-02") + ").txt"
+	data, err := os.ReadFile(e.absPath(copyRel))
+	if err != nil || string(data) != "my edits" {
+		t.Fatalf("expected edit saved to %s, got %q (%v)", copyRel, data, err)
+	}
+	ops := map[string]bool{}
+	for _, op := range opTypes(t, e) {
+		ops[op] = true
+	}
+	if len(ops) != 2 || !ops[opDownload+" docs/plan.txt"] || !ops[opUpload+" "+copyRel] {
+		t.Fatalf("expected restore download and copy upload, got %v", ops)
+	}
+	conflicts, err := e.Store.ListConflicts(ctx, e.accountID, storage.ConflictUnresolved)
+	if err != nil || len(conflicts) != 1 || conflicts[0].CopyPath != copyRel || conflicts[0].BaseRevision != md5Hex("original") {
+		t.Fatalf("expected the diverted edit kept as a conflict, got %#v, %v", conflicts, err)
+	}
+
+	// A second edit before the copy is indexed gets a numbered name.
+	second, err := e.conflictCopyPath(ctx, "docs/plan.txt", time.Now())
+	if err != nil {
+		t.Fatalf("conflictCopyPath: %v", err)
+	}
+	if want := "docs/plan (conflicted copy " + time.Now().Format("2006-01-02") + " 2).txt"; second != want {
+		t.Fatalf("expected %q, got %q", want, second)
+	}
+}
@@ -0,0 +1,69 @@
+package sync
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/status"
+)
+
+// HoldForReauth pauses the engine because its account's sign-in was revoked
+// or expired. Unlike Pause, the hold is not persisted: the account's reauth
+// mark in storage brings it back after a restart. Changes are held as when
+// paused.
+func (e *Engine) HoldForReauth(reason string) {
+	e.hold(reason, "sign-in expired; run googlysync login: "+reason)
+}
+
+// HoldForConsent pauses the engine like HoldForReauth because the account
+// has not granted a scope syncing needs. Signing in again with the scope
+// lifts it the same way.
+func (e *Engine) HoldForConsent(reason string) {
+	e.hold(reason, "Drive access not granted; run googlysync login --account to grant it: "+reason)
+}
+
+func (e *Engine) hold(reason, message string) {
+	e.mu.Lock()
+	e.reauth = reason
+	e.paused = true
+	e.mu.Unlock()
+	e.Logger.Warn("sync held until the account signs in again", zap.String("account", e.accountID), zap.String("reason", reason))
+	if e.Status != nil {
+		e.Status.Update(status.Snapshot{State: status.StateAuthRequired, Message: message})
+	}
+}
+
+// ReleaseReauth lifts HoldForReauth or HoldForConsent once the account has
+// signed in again.
+// The engine stays paused when the user paused it too.
+func (e *Engine) ReleaseReauth(ctx context.Context) error {
+	e.mu.Lock()
+	held := e.reauth != ""
+	e.reauth = ""
+	e.mu.Unlock()
+	if !held {
+		return nil
+	}
+	state, err := e.Store.GetSyncState(ctx, e.accountID)
+	if err != nil {
+		return err
+	}
+	if state != nil && state.Paused {
+		e.Logger.Info("account signed in again; sync stays paused", zap.String("account", e.accountID))
+		if e.Status != nil {
+			e.Status.Update(status.Snapshot{State: status.StatePaused, Message: "paused"})
+		}
+		return nil
+	}
+	e.Logger.Info("account signed in again", zap.String("account", e.accountID))
+	_, err = e.Resume(ctx, e.accountID)
+	return err
+}
+
+// ReauthReason returns why the engine is held for a new sign-in, or "".
+func (e *Engine) ReauthReason() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.reauth
+}
@@ -0,0 +1,288 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/notify"
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+const (
+	opDownload    = "download"
+	opDeleteLocal = "delete_local"
+)
+
+// suppressWindow is how long watcher events for a path the engine just
+// touched are ignored, so local effects of remote changes are not echoed back.
+const suppressWindow = 5 * time.Second
+
+// RemoteChange describes one entry from the Drive changes feed, with the
+// file's parents already resolved to a path relative to the sync root.
+type RemoteChange struct {
+	DriveID string
+	// Path is built with LocalPath, so names Drive allows but the local
+	// filesystem does not are already encoded.
+	Path     string
+	ParentID string
+	// Name is the file's Drive name. It is kept on the record when it
+	// differs from the last element of Path; empty leaves the record as is.
+	Name string
+	// Projections lists every local placement when the file has more than
+	// one parent or is reachable through shortcuts. Path and ParentID are
+	// ignored when it is set.
+	Projections []Projection
+	Checksum    string
+	ETag        string
+	Size        int64
+	MimeType    string
+	ModifiedAt  time.Time
+	Removed     bool
+	// SharedWithMe marks an item shared with the user that has no place in
+	// their My Drive. Only shortcut projections are trusted for it; see
+	// routeShared.
+	SharedWithMe bool
+	// ReadOnly is set when the account may not edit the file on Drive.
+	ReadOnly bool
+	// Trashed qualifies Removed: the file is in the Drive trash rather than
+	// gone for good.
+	Trashed bool
+	// RevisionID, Starred, Shared and WebViewLink are copied onto the
+	// record as reported by Drive.
+	RevisionID  string
+	Starred     bool
+	Shared      bool
+	WebViewLink string
+}
+
+// ApplyRemoteChange reconciles a remote change with local state. Changes that
+// only rename or reparent a file move the existing local copy instead of
+// downloading the content again.
+func (e *Engine) ApplyRemoteChange(ctx context.Context, change RemoteChange) error {
+	if change.DriveID == "" {
+		return errs.New(errs.ErrInvalidArgument, "remote change drive id is required")
+	}
+	if e.holdRemote(change) {
+		return nil
+	}
+	change, err := e.openSealedChange(ctx, change)
+	if err != nil {
+		return err
+	}
+	if claimed, err := e.claimArrivedUpload(ctx, change); err != nil || claimed {
+		return err
+	}
+	change = e.routeShared(change)
+	if !e.directionFor(ctx, change.Path).pullsRemote() {
+		return nil
+	}
+	if !change.Removed {
+		if reason := e.filter.excludes(change.Path, change.Size, change.MimeType); reason != "" {
+			e.noteExcluded(change.Path, reason)
+			return nil
+		}
+		if e.ignoredByPolicy(ctx, change.Path, change.MimeType == driveapi.FolderMimeType) {
+			return nil
+		}
+	}
+	if change.MimeType == driveapi.FolderMimeType && !change.Removed {
+		return e.applyRemoteFolder(ctx, change)
+	}
+	if unsyncable(change.MimeType) && !change.Removed {
+		return e.applyUnsyncable(ctx, change)
+	}
+	recs, err := e.Store.ListFileProjections(ctx, e.accountID, change.DriveID)
+	if err != nil {
+		return err
+	}
+	if len(change.Projections) > 0 || len(recs) > 1 {
+		return e.applyProjectedChange(ctx, change, recs)
+	}
+	var rec *storage.FileRecord
+	if len(recs) > 0 {
+		rec = &recs[0]
+	}
+
+	if change.Removed {
+		if rec == nil {
+			_, err := e.applyRemoteFolderRemoval(ctx, change.DriveID, e.lostFromView(change))
+			return err
+		}
+		if e.lostFromView(change) {
+			return e.untrackHidden(ctx, rec)
+		}
+		if err := e.Store.DeletePathAlias(ctx, e.accountID, rec.DriveID); err != nil {
+			return err
+		}
+		if change.Trashed {
+			// Lets garbage collection drop the record without asking
+			// Drive again if the local delete never runs.
+			if err := e.Store.SetFileTrashed(ctx, e.accountID, rec.DriveID, true); err != nil {
+				return err
+			}
+		}
+		return e.addOp(ctx, opDeleteLocal, rec.Path, rec.DriveID)
+	}
+	if change.Path == "" {
+		return errs.New(errs.ErrInvalidArgument, "remote change path is required")
+	}
+	if change.Path, err = e.localPathFor(ctx, change); err != nil {
+		return err
+	}
+	if rec == nil {
+		if e.skipsEmptyFile(change.Path, change.Size, change.MimeType) {
+			return nil
+		}
+		return e.queueDownload(ctx, change, change.Path, nil)
+	}
+	if !sameContent(rec, change) {
+		if rec.Path == change.Path {
+			if err := e.noteConflict(ctx, rec, change); err != nil {
+				return err
+			}
+			return e.queueDownload(ctx, change, change.Path, rec)
+		}
+		return e.addDownload(ctx, change.Path, change.DriveID, change.Size)
+	}
+	if rec.ReadOnly != change.ReadOnly {
+		if err := e.notePermissionChange(ctx, rec, change.ReadOnly); err != nil {
+			return err
+		}
+	}
+	if rec.Path == change.Path {
+		return nil
+	}
+
+	moved, err := e.moveLocal(ctx, rec.Path, change.Path)
+	if err != nil {
+		return err
+	}
+	if !moved {
+		return e.addDownload(ctx, change.Path, change.DriveID, change.Size)
+	}
+
+	oldPath := rec.Path
+	rec.Path = change.Path
+	if change.ParentID != "" {
+		rec.ParentID = change.ParentID
+	}
+	if change.ETag != "" {
+		rec.ETag = change.ETag
+	}
+	if err := e.Store.UpsertFile(ctx, rec); err != nil {
+		return err
+	}
+	e.Logger.Info("remote move applied locally", zap.String("from", oldPath), zap.String("to", change.Path))
+	e.recordHistory(ctx, storage.HistoryEntry{Op: historyMoveLocal, Path: change.Path, DriveID: rec.DriveID, Detail: oldPath, Outcome: storage.HistorySucceeded})
+	if e.Status != nil {
+		e.Status.AddEvent(status.Event{Op: "MOVE", Path: oldPath + " -> " + change.Path})
+	}
+	return nil
+}
+
+// moveLocal renames a tracked file. It reports false when the source is gone
+// or the destination is occupied, in which case the caller downloads instead.
+// In audit mode the move is only recorded.
+func (e *Engine) moveLocal(ctx context.Context, from, to string) (bool, error) {
+	src := e.absPath(from)
+	dst := e.absPath(to)
+	if _, err := os.Lstat(src); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return false, nil
+	}
+	if audited, err := e.auditLocal(ctx, auditMoveLocal, to, "", "from "+from); err != nil || audited {
+		return audited, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return false, err
+	}
+	e.suppress(from, to)
+	if err := os.Rename(src, dst); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (e *Engine) suppress(paths ...string) {
+	deadline := time.Now().Add(suppressWindow)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, p := range paths {
+		e.suppressed[p] = deadline
+	}
+}
+
+func (e *Engine) isSuppressed(rel string, now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	deadline, ok := e.suppressed[rel]
+	if !ok {
+		return false
+	}
+	if now.After(deadline) {
+		delete(e.suppressed, rel)
+		return false
+	}
+	return true
+}
+
+// revisionOf names a version of a file's content by its Drive revision, or
+// its md5 when the revision is unknown.
+func revisionOf(revisionID, checksum string) string {
+	if revisionID != "" {
+		return revisionID
+	}
+	return checksum
+}
+
+func sameContent(rec *storage.FileRecord, change RemoteChange) bool {
+	if rec.Size != change.Size {
+		return false
+	}
+	if rec.RevisionID != "" && rec.RevisionID == change.RevisionID {
+		return true
+	}
+	if rec.Checksum != "" && change.Checksum != "" {
+		return rec.Checksum == change.Checksum
+	}
+	return !change.ModifiedAt.IsZero() && rec.ModifiedAt.Equal(change.ModifiedAt)
+}
+
+// noteConflict records a conflict, and fires the conflict webhook, when
+// Drive content changed while a local edit of the same file is still
+// waiting to upload.
+func (e *Engine) noteConflict(ctx context.Context, rec *storage.FileRecord, change RemoteChange) error {
+	pending, err := e.Store.HasPendingOps(ctx, e.accountID, change.Path, opUpload)
+	if err != nil || !pending {
+		return err
+	}
+	e.Logger.Warn("remote change conflicts with a pending upload", zap.String("path", change.Path))
+	if err := e.Store.AddConflict(ctx, &storage.ConflictRecord{
+		AccountID:      e.accountID,
+		Path:           change.Path,
+		DriveID:        change.DriveID,
+		BaseRevision:   revisionOf(rec.RevisionID, rec.Checksum),
+		RemoteRevision: revisionOf(change.RevisionID, change.Checksum),
+	}); err != nil {
+		return err
+	}
+	e.recordHistory(ctx, storage.HistoryEntry{Op: historyConflict, Path: change.Path, DriveID: change.DriveID, Outcome: storage.HistoryDetected})
+	if e.Webhooks == nil {
+		return nil
+	}
+	e.Webhooks.Fire(e.accountID, notify.EventConflictDetected, map[string]any{"path": change.Path, "drive_id": change.DriveID})
+	return nil
+}
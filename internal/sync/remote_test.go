@@ -0,0 +1,96 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+)
+
+func TestRemoteRenameMovesLocalFile(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	trackFile(t, e, "docs/a.txt", "drive-a")
+
+	rec, err := e.Store.GetFileByDriveID(ctx, e.accountID, "drive-a")
+	if err != nil || rec == nil {
+		t.Fatalf("GetFileByDriveID: %#v, %v", rec, err)
+	}
+	change := RemoteChange{DriveID: "drive-a", Path: "archive/b.txt", Size: rec.Size, ModifiedAt: rec.ModifiedAt}
+	if err := e.ApplyRemoteChange(ctx, change); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+
+	if _, err := os.Stat(e.absPath("archive/b.txt")); err != nil {
+		t.Fatalf("expected file moved: %v", err)
+	}
+	if _, err := os.Stat(e.absPath("docs/a.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected old path gone, got %v", err)
+	}
+	updated, err := e.Store.GetFileByDriveID(ctx, e.accountID, "drive-a")
+	if err != nil || updated == nil || updated.Path != "archive/b.txt" {
+		t.Fatalf("expected record path updated, got %#v, %v", updated, err)
+	}
+	if got := opTypes(t, e); len(got) != 0 {
+		t.Fatalf("expected no download queued, got %v", got)
+	}
+	if !e.isSuppressed("archive/b.txt", time.Now()) {
+		t.Fatal("expected watcher echo for moved path to be suppressed")
+	}
+}
+
+func TestRemoteContentChangeDownloads(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	trackFile(t, e, "a.txt", "drive-a")
+
+	change := RemoteChange{DriveID: "drive-a", Path: "b.txt", Size: 999}
+	if err := e.ApplyRemoteChange(ctx, change); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	got := opTypes(t, e)
+	if len(got) != 1 || got[0] != "download b.txt" {
+		t.Fatalf("expected download op, got %v", got)
+	}
+}
+
+func TestRecordKeepsDriveMetadata(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	f := driveapi.File{
+		ID:             "d-a",
+		Name:           "a.pdf",
+		MimeType:       "application/pdf",
+		MD5Checksum:    md5Hex("a"),
+		Size:           1,
+		Parents:        []string{"p-1"},
+		HeadRevisionID: "rev-1",
+		Starred:        true,
+		Shared:         true,
+		WebViewLink:    "https://drive.google.com/file/d/d-a/view",
+	}
+	if err := e.recordRemote(ctx, remoteChangeOf(f, "a.pdf"), "a.pdf", nil); err != nil {
+		t.Fatalf("recordRemote: %v", err)
+	}
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, "a.pdf")
+	if err != nil || rec == nil {
+		t.Fatalf("GetFileByPath: %v, %v", rec, err)
+	}
+	if rec.MimeType != f.MimeType || rec.RevisionID != "rev-1" || !rec.Starred || !rec.Shared || rec.WebViewLink != f.WebViewLink || rec.ParentID != "p-1" || rec.Trashed {
+		t.Fatalf("unexpected record %+v", rec)
+	}
+
+	// An unchanged revision is the same content even when the modified
+	// time moved, as when only metadata was edited.
+	change := remoteChangeOf(f, "a.pdf")
+	change.Checksum, change.ModifiedAt = "", time.Now()
+	if !sameContent(rec, change) {
+		t.Fatalf("expected the same revision treated as unchanged content")
+	}
+	change.RevisionID = "rev-2"
+	if sameContent(rec, change) {
+		t.Fatalf("expected a new revision without a checksum treated as changed")
+	}
+}
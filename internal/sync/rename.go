@@ -0,0 +1,346 @@
+package sync
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// renameWindow bounds how long a vanished path waits for a matching CREATE
+// before it is treated as a delete.
+const renameWindow = 2 * time.Second
+
+const (
+	opUpload = "upload"
+	opDelete = "delete"
+	opMove   = "move"
+)
+
+// FileMover renames and moves Drive files. The executor applies local moves
+// through remotes that implement it.
+type FileMover interface {
+	Move(ctx context.Context, id, name, fromParent, toParent string) (*driveapi.File, error)
+}
+
+type pendingRemoval struct {
+	record   storage.FileRecord
+	deadline time.Time
+}
+
+// noteRemoval parks a tracked path that disappeared so a CREATE for the same
+// inode can claim it as a rename.
+func (e *Engine) noteRemoval(ctx context.Context, rel string) error {
+	if _, err := os.Lstat(e.absPath(rel)); err == nil {
+		return e.noteCreate(ctx, rel)
+	}
+	if wasLink, err := e.forgetSymlink(ctx, rel); err != nil || wasLink {
+		return err
+	}
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, rel)
+	if err != nil {
+		return err
+	}
+	if rec == nil {
+		if err := e.cancelUploads(ctx, rel); err != nil {
+			return err
+		}
+		return e.noteLocalFolderRemoval(ctx, rel)
+	}
+	e.removals[rel] = pendingRemoval{record: *rec, deadline: time.Now().Add(renameWindow)}
+	return nil
+}
+
+// noteCreate records a new or modified local file, turning it into a move when
+// its inode matches a tracked record whose old path no longer exists.
+func (e *Engine) noteCreate(ctx context.Context, rel string) error {
+	info, err := os.Lstat(e.absPath(rel))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if info.IsDir() {
+		return e.noteLocalFolder(ctx, rel)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		if info, err = e.resolveSymlink(ctx, rel); err != nil || info == nil {
+			return err
+		}
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	if reason := e.filter.excludes(rel, info.Size(), ""); reason != "" {
+		e.noteExcluded(rel, reason)
+		return nil
+	}
+	device, inode, hasID := fileID(info)
+
+	existing, err := e.Store.GetFileByPath(ctx, e.accountID, rel)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		delete(e.removals, rel)
+		unchanged, err := e.localUnchanged(ctx, existing, info)
+		if err != nil {
+			return err
+		}
+		if hasID && (existing.Device != device || existing.Inode != inode) {
+			existing.Device = device
+			existing.Inode = inode
+			if err := e.Store.UpsertFile(ctx, existing); err != nil {
+				return err
+			}
+		}
+		if unchanged {
+			return nil
+		}
+		if existing.ReadOnly {
+			return e.divertReadOnlyEdit(ctx, existing)
+		}
+		if err := e.addOp(ctx, opUpload, rel, existing.DriveID); err != nil {
+			return err
+		}
+		return e.propagateLocalEdit(ctx, existing)
+	}
+	if e.isSharedTopLevel(rel) {
+		e.Logger.Warn("new file in shared folder has no Drive parent; not uploading", zap.String("path", rel))
+		return nil
+	}
+
+	if hasID {
+		moved, err := e.Store.GetFileByInode(ctx, e.accountID, device, inode)
+		if err != nil {
+			return err
+		}
+		if moved != nil && moved.Path != rel {
+			if _, err := os.Lstat(e.absPath(moved.Path)); errors.Is(err, os.ErrNotExist) {
+				return e.commitMove(ctx, moved, rel)
+			}
+		}
+	}
+	if e.skipsEmptyFile(rel, info.Size(), "") {
+		return nil
+	}
+	return e.queueNewUpload(ctx, rel)
+}
+
+func (e *Engine) commitMove(ctx context.Context, rec *storage.FileRecord, rel string) error {
+	oldPath := rec.Path
+	delete(e.removals, oldPath)
+	rec.Path = rel
+	if err := e.Store.UpsertFile(ctx, rec); err != nil {
+		return err
+	}
+	e.Logger.Info("local move detected", zap.String("from", oldPath), zap.String("to", rel))
+	if e.Status != nil {
+		e.Status.AddEvent(status.Event{Op: "MOVE", Path: oldPath + " -> " + rel})
+	}
+	return e.addOp(ctx, opMove, rel, rec.DriveID)
+}
+
+// move gives the Drive file of a locally moved record the name and folder of
+// its new path, in one call. A record that has moved on again since is left
+// to the move queued for its newer path.
+func (x *Executor) move(ctx context.Context, op storage.PendingOp) error {
+	e := x.engine
+	remote, err := x.remote(ctx, "move", op.Path)
+	if err != nil {
+		return err
+	}
+	mover, ok := remote.(FileMover)
+	if !ok {
+		return errs.New(errs.ErrInvalidArgument, "drive client cannot move %s", op.Path)
+	}
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, op.Path)
+	if err != nil || rec == nil || rec.DriveID == "" {
+		return err
+	}
+	file, err := remote.GetFile(ctx, rec.DriveID)
+	if err != nil {
+		return err
+	}
+	named := *rec
+	if named.RemoteName != "" && e.LocalPath("", named.RemoteName) != path.Base(rec.Path) {
+		// Renamed locally; the Drive name follows the new one.
+		named.RemoteName = ""
+	}
+	name, err := e.UploadName(&named)
+	if err != nil {
+		return err
+	}
+	parent, err := x.driveFolder(ctx, remote, path.Dir(rec.Path))
+	if err != nil {
+		return err
+	}
+	var from string
+	if len(file.Parents) > 0 {
+		from = file.Parents[0]
+	}
+	if name != file.Name || parent != from {
+		if file, err = mover.Move(ctx, rec.DriveID, name, from, parent); err != nil {
+			return err
+		}
+	}
+	rec.RemoteName = ""
+	if file.Name != path.Base(rec.Path) {
+		rec.RemoteName = file.Name
+	}
+	rec.ParentID = parent
+	return e.Store.UpsertFile(ctx, rec)
+}
+
+// flushRemovals converts removals that outlived the rename window into deletes.
+func (e *Engine) flushRemovals(ctx context.Context, now time.Time) {
+	for rel, removal := range e.removals {
+		if now.Before(removal.deadline) {
+			continue
+		}
+		delete(e.removals, rel)
+		rec, err := e.Store.GetFileByPath(ctx, e.accountID, rel)
+		if err != nil {
+			e.Logger.Warn("removal lookup failed", zap.String("path", rel), zap.Error(err))
+			continue
+		}
+		if rec == nil || rec.ID != removal.record.ID {
+			continue
+		}
+		if _, err := os.Lstat(e.absPath(rel)); err == nil {
+			continue
+		}
+		if err := e.cancelUploads(ctx, rel); err != nil {
+			e.Logger.Warn("cancel uploads failed", zap.String("path", rel), zap.Error(err))
+		}
+		if err := e.queueLocalDelete(ctx, rec); err != nil {
+			e.Logger.Warn("queue delete failed", zap.String("path", rel), zap.Error(err))
+		}
+	}
+}
+
+// cancelUploads drops queued uploads for a path whose local file is gone.
+func (e *Engine) cancelUploads(ctx context.Context, rel string) error {
+	removed, err := e.Store.DeletePendingOpsForPath(ctx, e.accountID, rel, opUpload, opCopy)
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		e.Logger.Info("cancelled pending upload of deleted file", zap.String("path", rel))
+	}
+	return nil
+}
+
+func (e *Engine) addOp(ctx context.Context, opType, rel, driveID string) error {
+	return e.queueOp(ctx, &storage.PendingOp{Path: rel, DriveID: driveID, OpType: opType})
+}
+
+// addDownload plans a download of size bytes, which the executor counts
+// against free disk space before it starts downloading.
+func (e *Engine) addDownload(ctx context.Context, rel, driveID string, size int64) error {
+	return e.queueOp(ctx, &storage.PendingOp{Path: rel, DriveID: driveID, OpType: opDownload, Size: size})
+}
+
+func (e *Engine) queueOp(ctx context.Context, op *storage.PendingOp) error {
+	if direction := e.directionFor(ctx, op.Path); !direction.allows(op.OpType) {
+		e.Logger.Debug("op skipped by sync direction", zap.String("op", op.OpType), zap.String("path", op.Path), zap.String("direction", string(direction)))
+		return nil
+	}
+	id, err := newOpID()
+	if err != nil {
+		return err
+	}
+	op.ID = id
+	op.AccountID = e.accountID
+	if op.DependsOn == "" {
+		if op.DependsOn, err = e.opDependency(ctx, op); err != nil {
+			return err
+		}
+	}
+	if err := e.Store.AddPendingOp(ctx, op); err != nil {
+		return err
+	}
+	if e.auditOnly {
+		return e.audit(ctx, op.OpType, op.Path, op.DriveID, "")
+	}
+	select {
+	case e.opsReady <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (e *Engine) absPath(rel string) string {
+	return filepath.Join(e.Config.SyncRoot, filepath.FromSlash(rel))
+}
+
+func (e *Engine) relPath(path string) string {
+	if rel, err := filepath.Rel(e.Config.SyncRoot, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(path)
+}
+
+func newOpID() (string, error) {
+	return newID("op-")
+}
+
+func newRecordID() (string, error) {
+	return newID("file-")
+}
+
+func newID(prefix string) (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(buf), nil
+}
+
+// deleteRemote trashes the Drive file of a local file that was removed, or
+// the shortcut a removed link was uploaded as. A path that exists again by
+// the time the op runs keeps its Drive file.
+func (x *Executor) deleteRemote(ctx context.Context, op storage.PendingOp) error {
+	e := x.engine
+	if _, err := os.Lstat(e.absPath(op.Path)); err == nil {
+		return nil
+	}
+	if op.DriveID != "" {
+		remote, err := x.remote(ctx, "delete", op.Path)
+		if err != nil {
+			return err
+		}
+		trasher, ok := remote.(FileTrasher)
+		if !ok {
+			return errs.New(errs.ErrInvalidArgument, "drive client cannot delete %s", op.Path)
+		}
+		if _, err := trasher.Trash(ctx, op.DriveID); err != nil && !errors.Is(err, errs.ErrNotFound) {
+			return err
+		}
+	}
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, op.Path)
+	if err != nil {
+		return err
+	}
+	if rec != nil && rec.DriveID == op.DriveID {
+		if err := e.Store.DeleteFile(ctx, e.accountID, op.Path); err != nil {
+			return err
+		}
+	}
+	if e.Status != nil {
+		e.Status.AddEvent(status.Event{Op: "DELETE", Path: op.Path})
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+func newTestEngine(t *testing.T) *Engine {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &config.Config{
+		DataDir:      dir,
+		SyncRoot:     filepath.Join(dir, "sync"),
+		DatabasePath: filepath.Join(dir, "googlysync.db"),
+	}
+	if err := os.MkdirAll(cfg.SyncRoot, 0o700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	store, err := storage.NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	engine, err := NewEngine(zap.NewNop(), cfg, store, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	return engine
+}
+
+func trackFile(t *testing.T, e *Engine, rel, driveID string) {
+	t.Helper()
+	abs := e.absPath(rel)
+	if err := os.MkdirAll(filepath.Dir(abs), 0o700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(abs, []byte("content"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	info, err := os.Lstat(abs)
+	if err != nil {
+		t.Fatalf("Lstat: %v", err)
+	}
+	device, inode, ok := fileID(info)
+	if !ok {
+		t.Skip("file identity not supported on this platform")
+	}
+	rec := &storage.FileRecord{
+		ID:        "file-" + driveID,
+		AccountID: e.accountID,
+		Path:      rel,
+		DriveID:   driveID,
+		Device:    device,
+		Inode:     inode,
+	}
+	if err := e.Store.UpsertFile(context.Background(), rec); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+}
+
+func opTypes(t *testing.T, e *Engine) []string {
+	t.Helper()
+	ops, err := e.Store.ListPendingOps(context.Background(), e.accountID, "", 0)
+	if err != nil {
+		t.Fatalf("ListPendingOps: %v", err)
+	}
+	var out []string
+	for _, op := range ops {
+		out = append(out, op.OpType+" "+op.Path)
+	}
+	return out
+}
+
+func TestRenameBecomesMove(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	trackFile(t, e, "docs/a.txt", "drive-a")
+
+	if err := os.Rename(e.absPath("docs/a.txt"), e.absPath("docs/b.txt")); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if err := e.noteRemoval(ctx, "docs/a.txt"); err != nil {
+		t.Fatalf("noteRemoval: %v", err)
+	}
+	if err := e.noteCreate(ctx, "docs/b.txt"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+	e.flushRemovals(ctx, time.Now().Add(2*renameWindow))
+
+	got := opTypes(t, e)
+	if len(got) != 1 || got[0] != "move docs/b.txt" {
+		t.Fatalf("expected single move op, got %v", got)
+	}
+	rec, err := e.Store.GetFileByDriveID(ctx, e.accountID, "drive-a")
+	if err != nil {
+		t.Fatalf("GetFileByDriveID: %v", err)
+	}
+	if rec == nil || rec.Path != "docs/b.txt" {
+		t.Fatalf("expected record path updated, got %#v", rec)
+	}
+}
+
+func TestExecutorMovesRenamedFile(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	trackFile(t, e, "docs/a.txt", "drive-a")
+	drive := newFakeDrive()
+	drive.files["drive-a"] = driveapi.File{ID: "drive-a", Name: "a.txt", Parents: []string{"drive-docs"}}
+	x := newTestExecutor(t, e, drive)
+
+	if err := os.MkdirAll(e.absPath("archive"), 0o700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.Rename(e.absPath("docs/a.txt"), e.absPath("archive/b.txt")); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if err := e.noteRemoval(ctx, "docs/a.txt"); err != nil {
+		t.Fatalf("noteRemoval: %v", err)
+	}
+	if err := e.noteCreate(ctx, "archive/b.txt"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+
+	folder, err := e.Store.GetFolderByPath(ctx, e.accountID, "archive")
+	if err != nil || folder == nil {
+		t.Fatalf("expected the archive folder created, got %#v, %v", folder, err)
+	}
+	file := drive.files["drive-a"]
+	if file.Name != "b.txt" || len(file.Parents) != 1 || file.Parents[0] != folder.DriveID {
+		t.Fatalf("expected the Drive file renamed into archive, got %#v", file)
+	}
+	if drive.calls["Move"] != 1 {
+		t.Fatalf("expected one call for the rename and move, got %d", drive.calls["Move"])
+	}
+	if rec, _ := e.Store.GetFileByPath(ctx, e.accountID, "archive/b.txt"); rec == nil || rec.ParentID != folder.DriveID || rec.RemoteName != "" {
+		t.Fatalf("expected the record under the new folder, got %#v", rec)
+	}
+}
+
+func TestExecutorTrashesDeletedFile(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	trackFile(t, e, "a.txt", "drive-a")
+	drive := newFakeDrive()
+	drive.files["drive-a"] = driveapi.File{ID: "drive-a", Name: "a.txt"}
+	x := newTestExecutor(t, e, drive)
+
+	if err := os.Remove(e.absPath("a.txt")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := e.noteRemoval(ctx, "a.txt"); err != nil {
+		t.Fatalf("noteRemoval: %v", err)
+	}
+	e.flushRemovals(ctx, time.Now().Add(2*renameWindow))
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	if !drive.files["drive-a"].Trashed {
+		t.Fatalf("expected the Drive file trashed, got %#v", drive.files["drive-a"])
+	}
+	if rec, _ := e.Store.GetFileByPath(ctx, e.accountID, "a.txt"); rec != nil {
+		t.Fatalf("expected the record dropped, got %#v", rec)
+	}
+}
+
+func TestCreateBeforeRemoveBecomesMove(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	trackFile(t, e, "a.txt", "drive-a")
+
+	if err := os.Rename(e.absPath("a.txt"), e.absPath("b.txt")); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if err := e.noteCreate(ctx, "b.txt"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+	if err := e.noteRemoval(ctx, "a.txt"); err != nil {
+		t.Fatalf("noteRemoval: %v", err)
+	}
+	e.flushRemovals(ctx, time.Now().Add(2*renameWindow))
+
+	got := opTypes(t, e)
+	if len(got) != 1 || got[0] != "move b.txt" {
+		t.Fatalf("expected single move op, got %v", got)
+	}
+}
+
+func TestRemoveWithoutCreateBecomesDelete(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	trackFile(t, e, "a.txt", "drive-a")
+
+	if err := os.Remove(e.absPath("a.txt")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := e.noteRemoval(ctx, "a.txt"); err != nil {
+		t.Fatalf("noteRemoval: %v", err)
+	}
+	e.flushRemovals(ctx, time.Now())
+	if got := opTypes(t, e); len(got) != 0 {
+		t.Fatalf("expected removal to wait for rename window, got %v", got)
+	}
+	e.flushRemovals(ctx, time.Now().Add(2*renameWindow))
+	got := opTypes(t, e)
+	if len(got) != 1 || got[0] != "delete a.txt" {
+		t.Fatalf("expected delete op, got %v", got)
+	}
+}
+
+func TestRemoveCancelsPendingUpload(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	if err := os.WriteFile(e.absPath("new.iso"), []byte("image"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := e.noteCreate(ctx, "new.iso"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 1 || got[0] != "upload new.iso" {
+		t.Fatalf("expected upload op, got %v", got)
+	}
+	if err := os.Remove(e.absPath("new.iso")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := e.noteRemoval(ctx, "new.iso"); err != nil {
+		t.Fatalf("noteRemoval: %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 0 {
+		t.Fatalf("expected upload cancelled, got %v", got)
+	}
+}
@@ -0,0 +1,51 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// Resync rebuilds the index from scratch: it drops the account's file and
+// folder records and pending operations, then crawls Drive from rootID and
+// the whole sync root and reconciles them as Adopt does. Files whose size
+// and checksum already match Drive are indexed without a transfer. Use it
+// when the database was lost or no longer reflects the sync root.
+func (e *Engine) Resync(ctx context.Context, lister TreeLister, rootID string) (*AdoptReport, error) {
+	e.Logger.Info("rebuilding index", zap.String("account", e.accountID), zap.String("root", rootID))
+	if e.Status != nil {
+		e.Status.Update(status.Snapshot{State: status.StateSyncing, Message: "rebuilding index"})
+	}
+	if err := e.Store.ResetIndex(ctx, e.accountID); err != nil {
+		return nil, fmt.Errorf("reset index: %w", err)
+	}
+	report, err := e.Adopt(ctx, lister, rootID, e.Config.SyncRoot)
+	if e.Status != nil {
+		if err != nil {
+			e.Status.Update(status.Snapshot{State: status.StateError, Message: "index rebuild failed: " + err.Error()})
+		} else {
+			e.Status.Update(status.Snapshot{State: status.StateIdle, Message: "index rebuilt"})
+		}
+	}
+	return report, err
+}
+
+// DropIndex forgets the index and changes-feed position kept for an account,
+// so they are not applied to an account that takes over the sync root after
+// it is signed out. Nothing is crawled; run Resync afterwards.
+func DropIndex(ctx context.Context, store storage.Store, accountID string) error {
+	if err := store.ResetIndex(ctx, accountID); err != nil {
+		return err
+	}
+	state, err := store.GetSyncState(ctx, accountID)
+	if err != nil || state == nil {
+		return err
+	}
+	state.StartPageToken = ""
+	state.LastError = ""
+	return store.UpsertSyncState(ctx, state)
+}
@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+func TestResyncRebuildsIndexWithoutRetransfer(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	for rel, content := range map[string]string{"same.txt": "same", "local.txt": "local", "lazy.txt": ""} {
+		if err := os.WriteFile(e.absPath(rel), []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	if err := e.Store.MarkPlaceholder(ctx, e.accountID, "lazy.txt"); err != nil {
+		t.Fatalf("MarkPlaceholder: %v", err)
+	}
+	// Stale state the rebuild must discard.
+	if err := e.Store.UpsertFile(ctx, &storage.FileRecord{ID: "file-stale", AccountID: e.accountID, Path: "gone.txt", DriveID: "d-gone"}); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+	if err := e.addOp(ctx, opDelete, "same.txt", "d-same"); err != nil {
+		t.Fatalf("addOp: %v", err)
+	}
+
+	tree := fakeTree{
+		"root": {
+			{ID: "d-same", Name: "same.txt", Size: 4, MD5Checksum: md5Hex("same")},
+			{ID: "d-lazy", Name: "lazy.txt", Size: 9, MD5Checksum: md5Hex("remote!!!")},
+			{ID: "d-remote", Name: "remote.txt", Size: 6, MD5Checksum: md5Hex("remote")},
+		},
+	}
+	report, err := e.Resync(ctx, tree, "root")
+	if err != nil {
+		t.Fatalf("Resync: %v", err)
+	}
+	if report.Adopted != 2 || report.Uploads != 1 || report.Downloads != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if rec, err := e.Store.GetFileByPath(ctx, e.accountID, "gone.txt"); err != nil || rec != nil {
+		t.Fatalf("expected stale record dropped, got %+v %v", rec, err)
+	}
+	if rec, err := e.Store.GetFileByPath(ctx, e.accountID, "lazy.txt"); err != nil || rec == nil || rec.DriveID != "d-lazy" {
+		t.Fatalf("expected placeholder indexed from Drive, got %+v %v", rec, err)
+	}
+	ops := opTypes(t, e)
+	want := map[string]bool{opUpload + " local.txt": true, opDownload + " remote.txt": true}
+	if len(ops) != len(want) {
+		t.Fatalf("expected ops %v, got %v", want, ops)
+	}
+	for _, op := range ops {
+		if !want[op] {
+			t.Fatalf("unexpected op %q in %v", op, ops)
+		}
+	}
+}
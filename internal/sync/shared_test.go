@@ -0,0 +1,86 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSharedWithMeModes(t *testing.T) {
+	shared := RemoteChange{DriveID: "drive-s", Path: "Team/plan.txt", ParentID: "team", Size: 5, SharedWithMe: true}
+	viaShortcut := shared
+	viaShortcut.Projections = []Projection{{Path: "links/plan.txt", ParentID: "links", ShortcutID: "sc-1"}}
+
+	cases := []struct {
+		name   string
+		mode   SharedMode
+		change RemoteChange
+		want   []string
+	}{
+		{"off ignores", SharedOff, viaShortcut, nil},
+		{"shortcuts without shortcut", SharedShortcuts, shared, nil},
+		{"shortcuts follows shortcut", SharedShortcuts, viaShortcut, []string{"download links/plan.txt"}},
+		{"folder maps to dedicated dir", SharedFolder, shared, []string{"download Shared with me/Team/plan.txt"}},
+		{"folder keeps shortcut too", SharedFolder, viaShortcut, []string{"download Shared with me/Team/plan.txt", "link_local links/plan.txt"}},
+	}
+	for _, tc := range cases {
+		e := newTestEngine(t)
+		e.shared = tc.mode
+		if err := e.ApplyRemoteChange(context.Background(), tc.change); err != nil {
+			t.Fatalf("%s: ApplyRemoteChange: %v", tc.name, err)
+		}
+		got := opTypes(t, e)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, got)
+			}
+		}
+	}
+}
+
+func TestSharedWithMeOffRemovesEarlierCopies(t *testing.T) {
+	e := newTestEngine(t)
+	trackFile(t, e, "Shared with me/plan.txt", "drive-s")
+
+	change := RemoteChange{DriveID: "drive-s", Path: "plan.txt", Size: 5, SharedWithMe: true}
+	if err := e.ApplyRemoteChange(context.Background(), change); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if got := opTypes(t, e); len(got) != 1 || got[0] != "delete_local Shared with me/plan.txt" {
+		t.Fatalf("expected local delete, got %v", got)
+	}
+}
+
+func TestSharedTopLevelNotUploaded(t *testing.T) {
+	e := newTestEngine(t)
+	e.shared = SharedFolder
+	ctx := context.Background()
+
+	for _, rel := range []string{"Shared with me/new.txt", "Shared with me/Team/new.txt"} {
+		if err := os.MkdirAll(filepath.Dir(e.absPath(rel)), 0o700); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(e.absPath(rel), []byte("x"), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		if err := e.noteCreate(ctx, rel); err != nil {
+			t.Fatalf("noteCreate %s: %v", rel, err)
+		}
+	}
+	if got := opTypes(t, e); len(got) != 1 || got[0] != "upload Shared with me/Team/new.txt" {
+		t.Fatalf("expected only the nested file uploaded, got %v", got)
+	}
+}
+
+func TestParseSharedMode(t *testing.T) {
+	if m, err := ParseSharedMode(""); err != nil || m != SharedOff {
+		t.Fatalf("expected off default, got %q, %v", m, err)
+	}
+	if _, err := ParseSharedMode("everything"); err == nil {
+		t.Fatal("expected unknown mode to fail")
+	}
+}
@@ -0,0 +1,394 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+	"github.com/sandeepkv93/googlysync/internal/transfer"
+)
+
+// opRestore downloads a snapshot entry to a local path. It is allowed in
+// every direction since the user asked for it explicitly.
+const opRestore = "restore"
+
+// snapshotNameLayout names snapshots by their UTC capture time.
+const snapshotNameLayout = "2006-01-02T15-04-05Z"
+
+// defaultBackupInterval applies when backup mode has no interval configured.
+const defaultBackupInterval = time.Hour
+
+// appPropSnapshotFolder tags each Drive folder of a snapshot with the
+// snapshot name and the folder's path inside it, so an upload interrupted by
+// a restart finds the folders it already created.
+const appPropSnapshotFolder = "googlysync_snapshot_folder"
+
+// SnapshotFolders finds and creates the Drive folders snapshots are uploaded
+// into. The executor uploads snapshots through remotes that implement it.
+type SnapshotFolders interface {
+	FolderCreator
+	FindByAppProperty(ctx context.Context, key, value string) ([]driveapi.File, error)
+}
+
+// Snapshot captures the sync root as a new backup snapshot. Changed files are
+// planned as uploads into the snapshot's dated folder; unchanged files link to
+// the snapshot that already holds their content. It returns nil when nothing
+// changed since the previous snapshot.
+func (e *Engine) Snapshot(ctx context.Context, now time.Time) (*storage.Snapshot, error) {
+	prev, err := e.Store.LatestSnapshot(ctx, e.accountID)
+	if err != nil {
+		return nil, err
+	}
+	prevEntries := make(map[string]storage.SnapshotEntry)
+	if prev != nil {
+		entries, err := e.Store.ListSnapshotEntries(ctx, prev.ID, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			prevEntries[entry.Path] = entry
+		}
+	}
+
+	var entries []storage.SnapshotEntry
+	changed := false
+	err = filepath.WalkDir(e.Config.SyncRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel := e.relPath(path)
+		if !d.Type().IsRegular() || e.ignored(rel, false) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if reason := e.filter.excludes(rel, info.Size(), ""); reason != "" {
+			e.noteExcluded(rel, reason)
+			return nil
+		}
+		entry, err := e.snapshotEntry(rel, info, prevEntries)
+		if err != nil {
+			return err
+		}
+		if entry.Action == storage.SnapshotUpload {
+			changed = true
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if prev != nil && !changed && len(entries) == len(prevEntries) {
+		return nil, nil
+	}
+
+	snap := &storage.Snapshot{AccountID: e.accountID, Name: now.UTC().Format(snapshotNameLayout), CreatedAt: now}
+	if err := e.Store.CreateSnapshot(ctx, snap, entries); err != nil {
+		return nil, err
+	}
+	e.Logger.Info("backup snapshot planned", zap.String("name", snap.Name), zap.Int("files", len(entries)))
+	if e.Status != nil {
+		e.Status.AddEvent(status.Event{Op: "SNAPSHOT", Path: snap.Name})
+	}
+	return snap, nil
+}
+
+// snapshotEntry compares a file against the previous snapshot. Size and
+// mtime matches skip rehashing.
+func (e *Engine) snapshotEntry(rel string, info fs.FileInfo, prevEntries map[string]storage.SnapshotEntry) (storage.SnapshotEntry, error) {
+	entry := storage.SnapshotEntry{Path: rel, Size: info.Size(), ModifiedAt: info.ModTime(), Action: storage.SnapshotUpload}
+	prev, ok := prevEntries[rel]
+	if ok && prev.Size == entry.Size && prev.ModifiedAt.Equal(entry.ModifiedAt.Truncate(time.Second)) {
+		entry.Checksum = prev.Checksum
+	} else {
+		checksum, _, err := fileChecksum(e.absPath(rel))
+		if err != nil {
+			return entry, err
+		}
+		entry.Checksum = checksum
+	}
+	if ok && prev.Checksum == entry.Checksum {
+		entry.Action = storage.SnapshotLink
+		entry.BaseSnapshotID = prev.BaseSnapshotID
+	}
+	return entry, nil
+}
+
+// ignored reports whether ignore_patterns leave rel alone. isDir tells
+// whether rel is a folder.
+func (e *Engine) ignored(rel string, isDir bool) bool {
+	return e.ignores.Match(rel, isDir)
+}
+
+func (e *Engine) backupInterval() time.Duration {
+	if e.Config == nil || e.Config.BackupIntervalMin <= 0 {
+		return defaultBackupInterval
+	}
+	return time.Duration(e.Config.BackupIntervalMin) * time.Minute
+}
+
+// RestoreSnapshot queues downloads of a snapshot of accountID's files at or
+// under prefix. Files land at their original paths, or under dest when it is
+// set. It returns the number of queued restores.
+func RestoreSnapshot(ctx context.Context, store storage.Store, accountID, name, prefix, dest string) (int, error) {
+	snap, err := store.GetSnapshot(ctx, accountID, name)
+	if err != nil {
+		return 0, err
+	}
+	if snap == nil {
+		return 0, errs.New(errs.ErrNotFound, "snapshot %s not found", name)
+	}
+	if prefix != "" {
+		prefix = filepath.ToSlash(filepath.Clean(prefix))
+	}
+	entries, err := store.ListSnapshotEntries(ctx, snap.ID, prefix)
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, errs.New(errs.ErrNotFound, "snapshot %s has no files under %q", name, prefix)
+	}
+	for _, entry := range entries {
+		if entry.DriveID == "" {
+			return 0, errs.New(errs.ErrConflict, "snapshot %s is still uploading %s", name, entry.Path)
+		}
+	}
+	for _, entry := range entries {
+		target := entry.Path
+		if dest != "" {
+			target = filepath.ToSlash(filepath.Join(dest, entry.Path))
+		}
+		id, err := newOpID()
+		if err != nil {
+			return 0, err
+		}
+		if err := store.AddPendingOp(ctx, &storage.PendingOp{
+			ID:        id,
+			AccountID: accountID,
+			Path:      target,
+			DriveID:   entry.DriveID,
+			OpType:    opRestore,
+		}); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+// uploadSnapshots sends the pending entries of every snapshot to Drive. A
+// changed file is uploaded into the snapshot's folder as it is on disk when
+// the upload runs; an unchanged one is copied Drive-side from the snapshot
+// holding its content. Entries whose file is gone, or whose base snapshot is
+// still uploading, are retried on a later pass. It returns the number of
+// entries completed.
+func (x *Executor) uploadSnapshots(ctx context.Context) (int, error) {
+	e := x.engine
+	if e.auditOnly || e.Paused() || x.quotaFull {
+		return 0, nil
+	}
+	entries, err := e.Store.ListPendingSnapshotEntries(ctx, e.accountID, 0)
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+	var remote RemoteFiles
+	if x.remotes != nil {
+		remote = x.remotes(ctx)
+	}
+	if remote == nil {
+		// Picked up again once an account is signed in.
+		return 0, nil
+	}
+	folders, ok := remote.(SnapshotFolders)
+	if !ok {
+		return 0, errs.New(errs.ErrInvalidArgument, "drive client cannot upload snapshots")
+	}
+	snaps, err := e.Store.ListSnapshots(ctx, e.accountID)
+	if err != nil {
+		return 0, err
+	}
+	names := make(map[int64]string, len(snaps))
+	for _, snap := range snaps {
+		names[snap.ID] = snap.Name
+	}
+
+	done := 0
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+		driveID, err := x.uploadSnapshotEntry(ctx, remote, folders, names[entry.SnapshotID], entry)
+		if errs.KindOf(err) == errs.ErrQuotaExceeded {
+			x.setQuotaFull(true, "drive storage full; uploads held")
+			return done, nil
+		}
+		if err != nil {
+			x.logger.Warn("snapshot upload failed", zap.String("snapshot", names[entry.SnapshotID]), zap.String("path", entry.Path), zap.Error(err))
+			continue
+		}
+		if driveID == "" {
+			continue
+		}
+		if err := e.Store.CompleteSnapshotEntry(ctx, entry.SnapshotID, entry.Path, driveID); err != nil {
+			return done, err
+		}
+		done++
+	}
+	return done, nil
+}
+
+// uploadSnapshotEntry puts one entry into the Drive folder of snapshot name
+// and returns the Drive file made for it, or "" when it has to wait.
+func (x *Executor) uploadSnapshotEntry(ctx context.Context, remote RemoteFiles, folders SnapshotFolders, name string, entry storage.SnapshotEntry) (string, error) {
+	e := x.engine
+	if name == "" {
+		return "", errs.New(errs.ErrNotFound, "snapshot %d not found", entry.SnapshotID)
+	}
+	parent, err := x.snapshotFolder(ctx, folders, name, path.Dir(entry.Path))
+	if err != nil {
+		return "", err
+	}
+	fileName, err := e.UploadName(&storage.FileRecord{Path: entry.Path})
+	if err != nil {
+		return "", err
+	}
+	meta := driveapi.FileMeta{Name: fileName, Parents: []string{parent}}
+
+	if entry.Action == storage.SnapshotLink {
+		copier, ok := remote.(FileCopier)
+		if !ok {
+			return "", errs.New(errs.ErrInvalidArgument, "drive client cannot copy %s", entry.Path)
+		}
+		base, err := e.Store.ListSnapshotEntries(ctx, entry.BaseSnapshotID, entry.Path)
+		if err != nil {
+			return "", err
+		}
+		for _, b := range base {
+			if b.Path == entry.Path && b.DriveID != "" {
+				file, err := copier.Copy(ctx, b.DriveID, meta)
+				if err != nil {
+					return "", err
+				}
+				return file.ID, nil
+			}
+		}
+		return "", nil
+	}
+
+	uploader, ok := remote.(Uploader)
+	if !ok {
+		return "", errs.New(errs.ErrInvalidArgument, "drive client cannot upload %s", entry.Path)
+	}
+	content, err := e.UploadContent(entry.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		x.logger.Debug("snapshot file gone before upload", zap.String("path", entry.Path))
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+	tracker := e.Progress.Start(transfer.DirectionUpload, entry.Path, max(content.Size, 0))
+	defer tracker.Done()
+	meta.AppProperties = content.AppProperties
+	file, err := uploader.Upload(ctx, "", meta, io.TeeReader(content, tracker))
+	if err != nil {
+		return "", err
+	}
+	return file.ID, nil
+}
+
+// snapshotFolder returns the Drive id of the folder at dir inside snapshot
+// name, creating it and the folders above it when missing.
+func (x *Executor) snapshotFolder(ctx context.Context, folders SnapshotFolders, name, dir string) (string, error) {
+	e := x.engine
+	key := name
+	if dir != "." {
+		key = name + "/" + dir
+	}
+	if id, ok := x.snapshotFolders[key]; ok {
+		return id, nil
+	}
+	found, err := folders.FindByAppProperty(ctx, appPropSnapshotFolder, key)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range found {
+		if f.MimeType == driveapi.FolderMimeType {
+			x.snapshotFolders[key] = f.ID
+			return f.ID, nil
+		}
+	}
+
+	var parent, folderName string
+	if dir == "." {
+		if parent, err = DriveRootID(ctx, e.Config, e.Store, e.accountID); err != nil {
+			return "", err
+		}
+		folderName = name
+	} else {
+		if parent, err = x.snapshotFolder(ctx, folders, name, path.Dir(dir)); err != nil {
+			return "", err
+		}
+		if folderName, err = e.UploadName(&storage.FileRecord{Path: dir}); err != nil {
+			return "", err
+		}
+	}
+	created, err := folders.CreateFolder(ctx, folderName, []string{parent}, map[string]string{appPropSnapshotFolder: key})
+	if err != nil {
+		return "", err
+	}
+	x.snapshotFolders[key] = created.ID
+	return created.ID, nil
+}
+
+// restore downloads a snapshot entry over op.Path. The restored content is
+// not recorded as synced, so the watcher picks it up like a local edit.
+func (x *Executor) restore(ctx context.Context, op storage.PendingOp) error {
+	e := x.engine
+	remote, err := x.remote(ctx, "restore", op.Path)
+	if err != nil {
+		return err
+	}
+	file, err := remote.GetFile(ctx, op.DriveID)
+	if err != nil {
+		return err
+	}
+	body, err := remote.Download(ctx, op.DriveID)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	content, err := openContent(ctx, e.Store, e.Keys, e.accountID, file, body)
+	if err != nil {
+		return err
+	}
+	n, _, err := e.Downloads.Fetch(ctx, op.Path, content.Size, content.Checksum, content)
+	if err != nil {
+		return err
+	}
+	if content.Size >= 0 && n != content.Size {
+		return fmt.Errorf("short download: got %d of %d bytes", n, content.Size)
+	}
+	if e.Status != nil {
+		e.Status.AddEvent(status.Event{Op: "RESTORE", Path: op.Path})
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/fswatch"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+func TestSnapshotLinksUnchangedFiles(t *testing.T) {
+	e := newTestEngine(t)
+	e.direction = DirectionBackup
+	ctx := context.Background()
+	for name, data := range map[string]string{"a.txt": "alpha", "b.txt": "beta"} {
+		if err := os.WriteFile(e.absPath(name), []byte(data), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	first, err := e.Snapshot(ctx, now)
+	if err != nil || first == nil || first.Name != "2024-01-01T00-00-00Z" {
+		t.Fatalf("Snapshot: %#v, %v", first, err)
+	}
+	if again, err := e.Snapshot(ctx, now.Add(time.Hour)); err != nil || again != nil {
+		t.Fatalf("expected no snapshot without changes, got %#v, %v", again, err)
+	}
+
+	if err := os.WriteFile(e.absPath("b.txt"), []byte("beta v2"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	second, err := e.Snapshot(ctx, now.Add(2*time.Hour))
+	if err != nil || second == nil {
+		t.Fatalf("Snapshot: %#v, %v", second, err)
+	}
+	entries, err := e.Store.ListSnapshotEntries(ctx, second.ID, "")
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("ListSnapshotEntries: %#v, %v", entries, err)
+	}
+	if entries[0].Action != storage.SnapshotLink || entries[0].BaseSnapshotID != first.ID {
+		t.Fatalf("expected unchanged a.txt linked to first snapshot, got %#v", entries[0])
+	}
+	if entries[1].Action != storage.SnapshotUpload || entries[1].BaseSnapshotID != second.ID {
+		t.Fatalf("expected changed b.txt uploaded, got %#v", entries[1])
+	}
+	// Live events do not plan mirror ops in backup mode.
+	e.applyLocalEvent(ctx, fswatch.Event{Path: e.absPath("b.txt"), Op: fswatch.OpWrite})
+	if ops := opTypes(t, e); len(ops) != 0 {
+		t.Fatalf("expected no live ops in backup mode, got %v", ops)
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	snap := &storage.Snapshot{AccountID: e.accountID, Name: "2024-01-01T00-00-00Z"}
+	if err := e.Store.CreateSnapshot(ctx, snap, []storage.SnapshotEntry{
+		{Path: "docs/a.txt", Checksum: "aaa", Action: storage.SnapshotUpload},
+	}); err != nil {
+		t.Fatalf("CreateSnapshot: %v", err)
+	}
+	if _, err := RestoreSnapshot(ctx, e.Store, e.accountID, snap.Name, "", ""); !errors.Is(err, errs.ErrConflict) {
+		t.Fatalf("expected incomplete snapshot to be refused, got %v", err)
+	}
+	if err := e.Store.CompleteSnapshotEntry(ctx, snap.ID, "docs/a.txt", "drive-a"); err != nil {
+		t.Fatalf("CompleteSnapshotEntry: %v", err)
+	}
+	n, err := RestoreSnapshot(ctx, e.Store, e.accountID, snap.Name, "docs", "restored")
+	if err != nil || n != 1 {
+		t.Fatalf("RestoreSnapshot: %d %v", n, err)
+	}
+	if ops := opTypes(t, e); len(ops) != 1 || ops[0] != "restore restored/docs/a.txt" {
+		t.Fatalf("unexpected restore ops: %v", ops)
+	}
+	if _, err := RestoreSnapshot(ctx, e.Store, e.accountID, "missing", "", ""); !errors.Is(err, errs.ErrNotFound) {
+		t.Fatalf("expected missing snapshot not found, got %v", err)
+	}
+}
+
+func TestExecutorUploadsAndRestoresSnapshots(t *testing.T) {
+	e := newTestEngine(t)
+	e.direction = DirectionBackup
+	ctx := context.Background()
+	if err := os.MkdirAll(e.absPath("docs"), 0o700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	for name, data := range map[string]string{"a.txt": "alpha", "docs/b.txt": "beta"} {
+		if err := os.WriteFile(e.absPath(name), []byte(data), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	drive := newFakeDrive()
+	x := newTestExecutor(t, e, drive)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	first, err := e.Snapshot(ctx, now)
+	if err != nil || first == nil {
+		t.Fatalf("Snapshot: %#v, %v", first, err)
+	}
+	if done, err := x.uploadSnapshots(ctx); err != nil || done != 2 {
+		t.Fatalf("uploadSnapshots: %d, %v", done, err)
+	}
+	if snap, _ := e.Store.GetSnapshot(ctx, e.accountID, first.Name); snap == nil || snap.CompletedAt.IsZero() {
+		t.Fatalf("expected the snapshot complete, got %#v", snap)
+	}
+	folders, _ := drive.FindByAppProperty(ctx, appPropSnapshotFolder, first.Name+"/docs")
+	if len(folders) != 1 || folders[0].Name != "docs" {
+		t.Fatalf("expected the docs folder inside the snapshot, got %#v", folders)
+	}
+
+	if err := os.WriteFile(e.absPath("docs/b.txt"), []byte("beta v2"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	second, err := e.Snapshot(ctx, now.Add(time.Hour))
+	if err != nil || second == nil {
+		t.Fatalf("Snapshot: %#v, %v", second, err)
+	}
+	if done, err := x.uploadSnapshots(ctx); err != nil || done != 2 {
+		t.Fatalf("uploadSnapshots: %d, %v", done, err)
+	}
+	if drive.calls["Copy"] != 1 || drive.calls["Upload"] != 3 {
+		t.Fatalf("expected the unchanged file copied, got %v", drive.calls)
+	}
+
+	if n, err := RestoreSnapshot(ctx, e.Store, e.accountID, first.Name, "docs", "restored"); err != nil || n != 1 {
+		t.Fatalf("RestoreSnapshot: %d, %v", n, err)
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	if data, err := os.ReadFile(e.absPath("restored/docs/b.txt")); err != nil || string(data) != "beta" {
+		t.Fatalf("expected the first version restored, got %q, %v", data, err)
+	}
+}
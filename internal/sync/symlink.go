@@ -0,0 +1,173 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/fswatch"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// opShortcut uploads a local symlink as a Drive shortcut to its target.
+const opShortcut = "shortcut"
+
+// ShortcutCreator creates Drive shortcuts. The executor uploads links through
+// remotes that implement it.
+type ShortcutCreator interface {
+	CreateShortcut(ctx context.Context, name string, parents []string, targetID string) (*driveapi.File, error)
+}
+
+// resolveSymlink applies the symlink policy to a link at rel. It returns the
+// info to sync as a file, or nil when the link was handled or skipped.
+func (e *Engine) resolveSymlink(ctx context.Context, rel string) (os.FileInfo, error) {
+	switch e.symlinks {
+	case fswatch.SymlinkFollow:
+		info, err := os.Stat(e.absPath(rel))
+		if err != nil {
+			// Dangling links have nothing to sync.
+			return nil, nil
+		}
+		return info, nil
+	case fswatch.SymlinkShortcut:
+		return nil, e.noteSymlink(ctx, rel)
+	default:
+		return nil, nil
+	}
+}
+
+// noteSymlink records the link target and queues a shortcut when it changed.
+func (e *Engine) noteSymlink(ctx context.Context, rel string) error {
+	target, err := os.Readlink(e.absPath(rel))
+	if err != nil {
+		return err
+	}
+	existing, err := e.Store.GetSymlink(ctx, e.accountID, rel)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Target == target {
+		return nil
+	}
+	link := &storage.Symlink{AccountID: e.accountID, Path: rel, Target: target}
+	if existing != nil {
+		// The shortcut of the old target is replaced once the new one exists.
+		link.DriveID = existing.DriveID
+	}
+	if err := e.Store.UpsertSymlink(ctx, link); err != nil {
+		return err
+	}
+	e.Logger.Debug("symlink recorded", zap.String("path", rel), zap.String("target", target))
+	return e.addOp(ctx, opShortcut, rel, "")
+}
+
+// forgetSymlink drops a recorded link that vanished and queues the delete of
+// its shortcut. It reports whether rel was a recorded link.
+func (e *Engine) forgetSymlink(ctx context.Context, rel string) (bool, error) {
+	link, err := e.Store.GetSymlink(ctx, e.accountID, rel)
+	if err != nil || link == nil {
+		return false, err
+	}
+	if err := e.Store.DeleteSymlink(ctx, e.accountID, rel); err != nil {
+		return true, err
+	}
+	return true, e.addOp(ctx, opDelete, rel, link.DriveID)
+}
+
+// shortcut uploads the link at op.Path as a Drive shortcut to the Drive copy
+// of its target, then trashes the shortcut of an earlier target. A link that
+// points outside the sync root has nothing on Drive to point at.
+func (x *Executor) shortcut(ctx context.Context, op storage.PendingOp) error {
+	e := x.engine
+	link, err := e.Store.GetSymlink(ctx, e.accountID, op.Path)
+	if err != nil || link == nil {
+		return err
+	}
+	remote, err := x.remote(ctx, "create shortcut", op.Path)
+	if err != nil {
+		return err
+	}
+	creator, ok := remote.(ShortcutCreator)
+	if !ok {
+		return errs.New(errs.ErrInvalidArgument, "drive client cannot create shortcut %s", op.Path)
+	}
+	target, ok := e.linkTarget(op.Path, link.Target)
+	if !ok {
+		x.logger.Info("link points outside the sync root; not uploaded", zap.String("path", op.Path), zap.String("target", link.Target))
+		return nil
+	}
+	targetID, err := x.driveIDAt(ctx, target)
+	if err != nil {
+		return err
+	}
+	if targetID == "" {
+		return errs.New(errs.ErrNotFound, "shortcut target %s is not on Drive yet", target)
+	}
+	name, err := e.UploadName(&storage.FileRecord{Path: op.Path})
+	if err != nil {
+		return err
+	}
+	parent, err := x.driveFolder(ctx, remote, path.Dir(op.Path))
+	if err != nil {
+		return err
+	}
+	file, err := creator.CreateShortcut(ctx, name, []string{parent}, targetID)
+	if err != nil {
+		return err
+	}
+	if link.DriveID != "" {
+		if trasher, ok := remote.(FileTrasher); ok {
+			if _, err := trasher.Trash(ctx, link.DriveID); err != nil && !errors.Is(err, errs.ErrNotFound) {
+				x.logger.Warn("trash replaced shortcut failed", zap.String("path", op.Path), zap.Error(err))
+			}
+		}
+	}
+	// Re-read the link so a target recorded meanwhile is kept.
+	if link, err = e.Store.GetSymlink(ctx, e.accountID, op.Path); err != nil || link == nil {
+		return err
+	}
+	link.DriveID = file.ID
+	return e.Store.UpsertSymlink(ctx, link)
+}
+
+// linkTarget returns the path under the sync root a link at rel points to.
+// It reports false for targets outside the sync root.
+func (e *Engine) linkTarget(rel, target string) (string, bool) {
+	abs := target
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(filepath.Dir(e.absPath(rel)), target)
+	}
+	out, err := filepath.Rel(e.Config.SyncRoot, abs)
+	if err != nil || out == ".." || strings.HasPrefix(out, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(out), true
+}
+
+// driveIDAt returns the Drive id of the file or folder synced at rel, or ""
+// when nothing there is on Drive yet.
+func (x *Executor) driveIDAt(ctx context.Context, rel string) (string, error) {
+	e := x.engine
+	if rel == "." {
+		return DriveRootID(ctx, e.Config, e.Store, e.accountID)
+	}
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, rel)
+	if err != nil {
+		return "", err
+	}
+	if rec != nil && rec.DriveID != "" {
+		return rec.DriveID, nil
+	}
+	folder, err := e.Store.GetFolderByPath(ctx, e.accountID, rel)
+	if err != nil || folder == nil {
+		return "", err
+	}
+	return folder.DriveID, nil
+}
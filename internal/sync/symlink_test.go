@@ -0,0 +1,115 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/fswatch"
+)
+
+func TestSymlinkPolicies(t *testing.T) {
+	ctx := context.Background()
+	setup := func(policy fswatch.SymlinkPolicy) *Engine {
+		e := newTestEngine(t)
+		e.symlinks = policy
+		if err := os.WriteFile(e.absPath("real.txt"), []byte("data"), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		if err := os.Symlink("real.txt", e.absPath("link.txt")); err != nil {
+			t.Fatalf("Symlink: %v", err)
+		}
+		if err := e.noteCreate(ctx, "link.txt"); err != nil {
+			t.Fatalf("noteCreate: %v", err)
+		}
+		return e
+	}
+
+	if ops := opTypes(t, setup(fswatch.SymlinkSkip)); len(ops) != 0 {
+		t.Fatalf("expected skip to queue nothing, got %v", ops)
+	}
+	if ops := opTypes(t, setup(fswatch.SymlinkFollow)); len(ops) != 1 || ops[0] != "upload link.txt" {
+		t.Fatalf("expected follow to upload the target content, got %v", ops)
+	}
+
+	e := setup(fswatch.SymlinkShortcut)
+	link, err := e.Store.GetSymlink(ctx, e.accountID, "link.txt")
+	if err != nil || link == nil || link.Target != "real.txt" {
+		t.Fatalf("expected recorded target, got %#v, %v", link, err)
+	}
+	// An unchanged link is not queued twice.
+	if err := e.noteCreate(ctx, "link.txt"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+	if err := os.Remove(e.absPath("link.txt")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := e.noteRemoval(ctx, "link.txt"); err != nil {
+		t.Fatalf("noteRemoval: %v", err)
+	}
+	ops := opTypes(t, e)
+	if len(ops) != 2 || ops[0] != "shortcut link.txt" || ops[1] != "delete link.txt" {
+		t.Fatalf("unexpected shortcut ops: %v", ops)
+	}
+	if link, _ := e.Store.GetSymlink(ctx, e.accountID, "link.txt"); link != nil {
+		t.Fatalf("expected link record dropped, got %#v", link)
+	}
+}
+
+func TestExecutorUploadsLinksAsShortcuts(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEngine(t)
+	e.symlinks = fswatch.SymlinkShortcut
+	trackFile(t, e, "real.txt", "drive-real")
+	trackFile(t, e, "other.txt", "drive-other")
+	drive := newFakeDrive()
+	drive.files["drive-real"] = driveapi.File{ID: "drive-real", Name: "real.txt"}
+	x := newTestExecutor(t, e, drive)
+
+	if err := os.Symlink("real.txt", e.absPath("link.txt")); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	if err := e.noteCreate(ctx, "link.txt"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	link, _ := e.Store.GetSymlink(ctx, e.accountID, "link.txt")
+	if link == nil || link.DriveID == "" || drive.targets[link.DriveID] != "drive-real" {
+		t.Fatalf("expected a shortcut to real.txt, got %#v", link)
+	}
+	first := link.DriveID
+
+	// Pointing the link elsewhere replaces the shortcut.
+	if err := os.Remove(e.absPath("link.txt")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := os.Symlink("other.txt", e.absPath("link.txt")); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	if err := e.noteCreate(ctx, "link.txt"); err != nil {
+		t.Fatalf("noteCreate: %v", err)
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	link, _ = e.Store.GetSymlink(ctx, e.accountID, "link.txt")
+	if link == nil || drive.targets[link.DriveID] != "drive-other" || !drive.files[first].Trashed {
+		t.Fatalf("expected the old shortcut replaced, got %#v", link)
+	}
+
+	if err := os.Remove(e.absPath("link.txt")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := e.noteRemoval(ctx, "link.txt"); err != nil {
+		t.Fatalf("noteRemoval: %v", err)
+	}
+	if done, err := x.RunOnce(ctx); err != nil || done != 1 {
+		t.Fatalf("RunOnce: %d, %v", done, err)
+	}
+	if !drive.files[link.DriveID].Trashed {
+		t.Fatalf("expected the shortcut trashed, got %#v", drive.files[link.DriveID])
+	}
+}
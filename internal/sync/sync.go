@@ -2,27 +2,195 @@ package sync
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	gosync "sync"
 	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
 	"github.com/sandeepkv93/googlysync/internal/fswatch"
+	"github.com/sandeepkv93/googlysync/internal/ignore"
+	"github.com/sandeepkv93/googlysync/internal/notify"
 	"github.com/sandeepkv93/googlysync/internal/status"
 	"github.com/sandeepkv93/googlysync/internal/storage"
+	"github.com/sandeepkv93/googlysync/internal/transfer"
 )
 
+// DefaultAccountID is the placeholder account seeded by the storage
+// migrations. An engine syncs it until it is given a signed-in account.
+const DefaultAccountID = "default"
+
 // Engine coordinates sync operations.
 type Engine struct {
 	Logger *zap.Logger
-	Store  *storage.Storage
+	Config *config.Config
+	Store  storage.Store
 	Status *status.Store
 	Queue  *Queue
+	// Downloads prepares local targets for remote content.
+	Downloads *transfer.Downloader
+	// Progress, when set, tracks uploads the executor sends.
+	Progress *transfer.Progress
+	// Webhooks, when set, is told about sync milestones.
+	Webhooks *notify.Webhooks
+	// Keys, when set, supplies the account key encrypted content and names
+	// are sealed under.
+	Keys KeySource
+
+	accountID string
+	direction Direction
+	symlinks  fswatch.SymlinkPolicy
+	onDemand  bool
+	shared    SharedMode
+	sharedDir string
+	filter    transferFilter
+	// ignores holds ignore_patterns; folder policies and stored rules are
+	// applied on top by FolderSettings.
+	ignores  *ignore.Matcher
+	removals map[string]pendingRemoval
+	// access is how much of Drive the account can see.
+	access DriveAccess
+
+	emptyFolders   EmptyFolderPolicy
+	skipEmptyFiles bool
+	// caseFold is set when the sync root cannot hold names that differ only
+	// by case.
+	caseFold bool
+	names    NameEncoding
+	// unsyncable decides what native files without an export format become.
+	unsyncable UnsyncablePolicy
+	// encryptContent and encryptNames seal what is uploaded under the
+	// account key.
+	encryptContent bool
+	encryptNames   bool
+	// compressUploads gzips uploads whose type is not in compressSkip.
+	compressUploads bool
+	compressSkip    []string
+	// auditOnly records planned work without touching the sync root or
+	// running ops.
+	auditOnly bool
+
+	mu         gosync.Mutex
+	suppressed map[string]time.Time
+	orphans    []string
+	paused     bool
+	// reauth is why the account has to sign in again; the engine stays
+	// paused while it is set.
+	reauth     string
+	heldLocal  map[string]fswatch.Event
+	heldRemote map[string]RemoteChange
+	resumed    chan struct{}
+	// opsReady wakes the executor when a new op is planned.
+	opsReady chan struct{}
+	// activity is signalled after each local event; see LocalActivity.
+	activity chan struct{}
+	// scanned is set once ScanOffline has queued the changes made while the
+	// daemon was stopped; watchedAt is the last checkpoint recorded since.
+	scanned   bool
+	watchedAt time.Time
 }
 
 // NewEngine constructs a sync engine.
-func NewEngine(logger *zap.Logger, store *storage.Storage, statusStore *status.Store, queue *Queue) (*Engine, error) {
-	logger.Info("sync engine initialized")
-	return &Engine{Logger: logger, Store: store, Status: statusStore, Queue: queue}, nil
+func NewEngine(
+	logger *zap.Logger,
+	cfg *config.Config,
+	store storage.Store,
+	statusStore *status.Store,
+	queue *Queue,
+	downloads *transfer.Downloader,
+) (*Engine, error) {
+	direction := DirectionBidirectional
+	symlinks := fswatch.SymlinkSkip
+	shared := SharedOff
+	access := DriveAccessFull
+	sharedDir := defaultSharedDir
+	emptyFolders := EmptyFoldersCreate
+	caseFold := false
+	names := NamesPortable
+	unsyncable, _ := ParseUnsyncablePolicy("")
+	var compressSkip []string
+	if cfg != nil {
+		var err error
+		if direction, err = ParseDirection(cfg.SyncDirection); err != nil {
+			return nil, err
+		}
+		if symlinks, err = fswatch.ParseSymlinkPolicy(cfg.SymlinkPolicy); err != nil {
+			return nil, err
+		}
+		if shared, err = ParseSharedMode(cfg.SharedWithMe); err != nil {
+			return nil, err
+		}
+		sharedDir = sharedDirOf(cfg.SharedWithMeDir)
+		if access, err = ParseDriveAccess(cfg.DriveAccess); err != nil {
+			return nil, err
+		}
+		if access == DriveAccessApp && shared != SharedOff {
+			return nil, errs.New(errs.ErrInvalidArgument, "shared_with_me needs drive_access %q; files shared with the account are not visible to it otherwise", DriveAccessFull)
+		}
+		if emptyFolders, err = ParseEmptyFolderPolicy(cfg.EmptyFolders); err != nil {
+			return nil, err
+		}
+		sensitivity, err := ParseCaseSensitivity(cfg.CaseSensitivity)
+		if err != nil {
+			return nil, err
+		}
+		caseFold = foldsCase(sensitivity, cfg.SyncRoot)
+		if names, err = ParseNameEncoding(cfg.NameEncoding); err != nil {
+			return nil, err
+		}
+		if unsyncable, err = ParseUnsyncablePolicy(cfg.UnsyncableTypes); err != nil {
+			return nil, err
+		}
+		compressSkip = cfg.CompressSkipTypes
+	}
+	filter, err := newTransferFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var ignores *ignore.Matcher
+	if cfg != nil {
+		if ignores, err = ignore.New(cfg.IgnorePatterns...); err != nil {
+			return nil, err
+		}
+	}
+	logger.Info("sync engine initialized", zap.String("direction", string(direction)), zap.Bool("audit_only", cfg != nil && cfg.AuditOnly), zap.Bool("case_insensitive", caseFold))
+	return &Engine{
+		Logger:          logger,
+		Config:          cfg,
+		Store:           store,
+		Status:          statusStore,
+		Queue:           queue,
+		Downloads:       downloads,
+		accountID:       DefaultAccountID,
+		direction:       direction,
+		symlinks:        symlinks,
+		onDemand:        cfg != nil && cfg.OnDemand,
+		encryptContent:  cfg != nil && cfg.EncryptContent,
+		encryptNames:    cfg != nil && cfg.EncryptNames,
+		compressUploads: cfg != nil && cfg.CompressUploads,
+		compressSkip:    compressSkip,
+		shared:          shared,
+		sharedDir:       sharedDir,
+		access:          access,
+		filter:          filter,
+		ignores:         ignores,
+		removals:        make(map[string]pendingRemoval),
+		suppressed:      make(map[string]time.Time),
+		heldLocal:       make(map[string]fswatch.Event),
+		heldRemote:      make(map[string]RemoteChange),
+		resumed:         make(chan struct{}, 1),
+		opsReady:        make(chan struct{}, 1),
+		activity:        make(chan struct{}, 1),
+		emptyFolders:    emptyFolders,
+		skipEmptyFiles:  cfg != nil && cfg.SkipEmptyFiles,
+		caseFold:        caseFold,
+		names:           names,
+		unsyncable:      unsyncable,
+		auditOnly:       cfg != nil && cfg.AuditOnly,
+	}, nil
 }
 
 // Run runs a stub sync loop that updates status periodically.
@@ -30,9 +198,25 @@ func (e *Engine) Run(ctx context.Context) {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
-	var queueCh <-chan fswatch.Event
+	if e.Store != nil {
+		if err := e.loadOrphans(ctx); err != nil {
+			e.Logger.Warn("load orphaned folders failed", zap.Error(err))
+		}
+		if err := e.loadPaused(ctx); err != nil {
+			e.Logger.Warn("load pause state failed", zap.Error(err))
+		}
+	}
+
+	var readyCh <-chan struct{}
 	if e.Queue != nil {
-		queueCh = e.Queue.Channel()
+		readyCh = e.Queue.Ready()
+	}
+
+	var backupCh <-chan time.Time
+	if e.direction == DirectionBackup && e.Store != nil && e.Config != nil {
+		backupTicker := time.NewTicker(e.backupInterval())
+		defer backupTicker.Stop()
+		backupCh = backupTicker.C
 	}
 
 	for {
@@ -42,13 +226,34 @@ func (e *Engine) Run(ctx context.Context) {
 				e.Status.Update(status.Snapshot{State: status.StateIdle, Message: "idle"})
 			}
 			return
-		case evt := <-queueCh:
-			e.handleEvent(evt)
-		case <-ticker.C:
+		case <-readyCh:
+			evt, ok := e.Queue.Pop()
+			if !ok || e.holdLocal(evt) {
+				continue
+			}
+			e.handleEvent(ctx, evt)
+			e.noteLocalActivity()
+		case <-e.resumed:
+			e.replayHeld(ctx)
+		case now := <-backupCh:
+			if e.Paused() {
+				continue
+			}
+			if _, err := e.Snapshot(ctx, now); err != nil {
+				e.Logger.Warn("backup snapshot failed", zap.Error(err))
+			}
+		case now := <-ticker.C:
+			if e.Paused() {
+				continue
+			}
 			if e.Status != nil {
 				e.Status.Update(status.Snapshot{State: status.StateSyncing, Message: "sync tick"})
 			}
 			e.Logger.Info("sync tick")
+			e.flushRemovals(ctx, now)
+			if e.Store != nil {
+				e.checkpointWatch(ctx, now)
+			}
 			if e.Status != nil {
 				e.Status.Update(status.Snapshot{State: status.StateIdle, Message: "idle"})
 			}
@@ -56,12 +261,66 @@ func (e *Engine) Run(ctx context.Context) {
 	}
 }
 
-func (e *Engine) handleEvent(evt fswatch.Event) {
+func (e *Engine) handleEvent(ctx context.Context, evt fswatch.Event) {
 	if e.Status != nil {
 		e.Status.Update(status.Snapshot{State: status.StateSyncing, Message: "processing event"})
 	}
 	e.Logger.Info("fs event", zap.String("path", evt.Path))
+	if e.Store != nil && e.Config != nil {
+		e.applyLocalEvent(ctx, evt)
+	}
 	if e.Status != nil {
 		e.Status.Update(status.Snapshot{State: status.StateIdle, Message: "idle"})
 	}
 }
+
+func (e *Engine) applyLocalEvent(ctx context.Context, evt fswatch.Event) {
+	// Events from watch_roots besides the sync root are only reported in
+	// status; nothing maps them to Drive.
+	if evt.Root != "" && evt.Root != filepath.Clean(e.Config.SyncRoot) {
+		return
+	}
+	rel := e.relPath(evt.Path)
+	settings, err := e.FolderSettings(ctx, rel)
+	if err != nil {
+		e.Logger.Warn("folder policy lookup failed", zap.String("path", rel), zap.Error(err))
+	}
+	if !settings.Direction.pushesLocal() {
+		return
+	}
+	if e.isSuppressed(rel, time.Now()) {
+		e.Logger.Debug("ignoring self-initiated fs event", zap.String("path", rel))
+		return
+	}
+	if e.isOrphaned(rel) {
+		e.Logger.Debug("ignoring fs event under orphaned folder", zap.String("path", rel))
+		return
+	}
+	info, err := os.Stat(evt.Path)
+	pattern := settings.Ignores(rel, err == nil && info.IsDir())
+	if pattern == "" && err != nil {
+		// A path that is gone may have been a folder.
+		pattern = settings.Ignores(rel, true)
+	}
+	if pattern != "" {
+		e.noteExcluded(rel, "ignored by folder policy ("+pattern+")")
+		return
+	}
+	switch evt.Op {
+	case fswatch.OpRemove, fswatch.OpRename:
+		err = e.noteRemoval(ctx, rel)
+	case fswatch.OpCreate, fswatch.OpWrite:
+		err = e.noteCreate(ctx, rel)
+	}
+	if err != nil {
+		e.Logger.Warn("fs event handling failed", zap.String("path", rel), zap.Error(err))
+		e.recordError(ctx, rel, fswatch.OpString(evt.Op), err)
+	}
+}
+
+func (e *Engine) recordError(ctx context.Context, rel, op string, err error) {
+	rec := &storage.ErrorRecord{AccountID: e.accountID, Path: rel, Op: op, Message: err.Error()}
+	if err := e.Store.AddError(ctx, rec); err != nil {
+		e.Logger.Warn("error history write failed", zap.Error(err))
+	}
+}
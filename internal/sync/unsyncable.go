@@ -0,0 +1,141 @@
+package sync
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// UnsyncablePolicy controls what happens to native Google files that have
+// no export format, such as Forms, Sites and My Maps.
+type UnsyncablePolicy string
+
+const (
+	// UnsyncableDesktop creates a freedesktop.org link file that opens the
+	// item in the browser.
+	UnsyncableDesktop UnsyncablePolicy = "desktop"
+	// UnsyncableURL creates an Internet Shortcut (.url) file instead, which
+	// Windows and macOS open directly.
+	UnsyncableURL UnsyncablePolicy = "url"
+	// UnsyncableSkip leaves the item out of the sync root and reports it.
+	UnsyncableSkip UnsyncablePolicy = "skip"
+)
+
+// statusUnsyncable marks items skipped by UnsyncableSkip.
+const statusUnsyncable = "UNSYNCABLE"
+
+// auditWriteLink is the audit action for creating a link stub.
+const auditWriteLink = "write_link"
+
+// ParseUnsyncablePolicy validates a configured policy. "link", and empty,
+// pick the link format native to the platform.
+func ParseUnsyncablePolicy(val string) (UnsyncablePolicy, error) {
+	switch p := UnsyncablePolicy(val); p {
+	case "", "link":
+		if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+			return UnsyncableURL, nil
+		}
+		return UnsyncableDesktop, nil
+	case UnsyncableDesktop, UnsyncableURL, UnsyncableSkip:
+		return p, nil
+	default:
+		return "", errs.New(errs.ErrInvalidArgument, "unknown unsyncable_types policy %q", val)
+	}
+}
+
+// unsyncable reports whether a Drive type has no content to download or
+// export.
+func unsyncable(mimeType string) bool {
+	return driveapi.IsGoogleNative(mimeType) && !driveapi.IsExportable(mimeType)
+}
+
+// linkStub returns the file extension and content of the stub for change.
+func (p UnsyncablePolicy) linkStub(change RemoteChange) (string, []byte) {
+	target := driveapi.OpenURL(change.DriveID)
+	if p == UnsyncableURL {
+		return ".url", []byte("[InternetShortcut]\r\nURL=" + target + "\r\n")
+	}
+	name := change.Name
+	if name == "" {
+		name = filepath.Base(change.Path)
+	}
+	escape := strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return ".desktop", fmt.Appendf(nil, "[Desktop Entry]\nType=Link\nName=%s\nURL=%s\nIcon=text-html\n", escape.Replace(name), target)
+}
+
+// applyUnsyncable mirrors a Drive item that has no content as a link stub
+// next to where the file would be, or skips it. A stub is recorded as a
+// read-only projection of the item, so renames and removals in Drive carry
+// over to it and local edits are not uploaded over the original.
+func (e *Engine) applyUnsyncable(ctx context.Context, change RemoteChange) error {
+	if e.unsyncable == UnsyncableSkip {
+		e.Logger.Info("skipping item with no downloadable content", zap.String("path", change.Path), zap.String("mime_type", change.MimeType))
+		if e.Status != nil {
+			e.Status.AddEvent(status.Event{Op: statusUnsyncable, Path: change.Path})
+		}
+		return nil
+	}
+	ext, stub := e.unsyncable.linkStub(change)
+	sum := md5.Sum(stub)
+	change.Path += ext
+	change.Checksum = hex.EncodeToString(sum[:])
+	change.Size = int64(len(stub))
+	change.ReadOnly = true
+	var err error
+	if change.Path, err = e.localPathFor(ctx, change); err != nil {
+		return err
+	}
+
+	recs, err := e.Store.ListFileProjections(ctx, e.accountID, change.DriveID)
+	if err != nil {
+		return err
+	}
+	var rec *storage.FileRecord
+	if len(recs) > 0 {
+		rec = &recs[0]
+	}
+	if rec != nil && rec.Path == change.Path && sameContent(rec, change) {
+		if _, err := os.Lstat(e.absPath(rec.Path)); err == nil {
+			return nil
+		}
+	}
+	if audited, err := e.auditLocal(ctx, auditWriteLink, change.Path, change.DriveID, ""); err != nil || audited {
+		return err
+	}
+	if rec != nil && rec.Path != change.Path {
+		e.suppress(rec.Path)
+		if err := os.Remove(e.absPath(rec.Path)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := e.Store.DeleteFile(ctx, e.accountID, rec.Path); err != nil {
+			return err
+		}
+		rec = nil
+	}
+
+	abs := e.absPath(change.Path)
+	e.suppress(change.Path)
+	if err := os.MkdirAll(filepath.Dir(abs), 0o700); err != nil {
+		return err
+	}
+	if err := e.applyPermissions(change.Path, false); err != nil {
+		return err
+	}
+	if err := os.WriteFile(abs, stub, 0o600); err != nil {
+		return err
+	}
+	e.Logger.Info("wrote link for item with no downloadable content", zap.String("path", change.Path))
+	return e.recordRemote(ctx, change, change.Path, rec)
+}
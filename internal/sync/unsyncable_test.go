@@ -0,0 +1,91 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sandeepkv93/googlysync/internal/status"
+)
+
+const formMimeType = "application/vnd.google-apps.form"
+
+func TestUnsyncableItemBecomesLinkStub(t *testing.T) {
+	e := newTestEngine(t)
+	e.unsyncable = UnsyncableDesktop
+	ctx := context.Background()
+
+	change := RemoteChange{DriveID: "d-form", Path: "surveys/Feedback", Name: "Feedback", MimeType: formMimeType}
+	if err := e.ApplyRemoteChange(ctx, change); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	data, err := os.ReadFile(e.absPath("surveys/Feedback.desktop"))
+	if err != nil {
+		t.Fatalf("expected link stub: %v", err)
+	}
+	if !strings.Contains(string(data), "Type=Link") || !strings.Contains(string(data), "URL=https://drive.google.com/open?id=d-form") {
+		t.Fatalf("unexpected stub %q", data)
+	}
+	if ops := opTypes(t, e); len(ops) != 0 {
+		t.Fatalf("expected nothing downloaded, got %v", ops)
+	}
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, "surveys/Feedback.desktop")
+	if err != nil || rec == nil || !rec.ReadOnly || rec.RemoteName != "Feedback" {
+		t.Fatalf("expected read-only record keeping the Drive name, got %#v, %v", rec, err)
+	}
+
+	// A rename in Drive moves the stub.
+	change.Path, change.Name = "surveys/Exit survey", "Exit survey"
+	if err := e.ApplyRemoteChange(ctx, change); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if _, err := os.Stat(e.absPath("surveys/Feedback.desktop")); !os.IsNotExist(err) {
+		t.Fatalf("expected old stub removed, got %v", err)
+	}
+	if _, err := os.Stat(e.absPath("surveys/Exit survey.desktop")); err != nil {
+		t.Fatalf("expected renamed stub: %v", err)
+	}
+
+	// Removal goes through the usual local delete.
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "d-form", Removed: true}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if ops := opTypes(t, e); len(ops) != 1 || ops[0] != opDeleteLocal+" surveys/Exit survey.desktop" {
+		t.Fatalf("expected stub deletion, got %v", ops)
+	}
+}
+
+func TestUnsyncableURLAndSkip(t *testing.T) {
+	e := newTestEngine(t)
+	e.unsyncable = UnsyncableURL
+	ctx := context.Background()
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "d-site", Path: "Team site", MimeType: "application/vnd.google-apps.site"}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	if data, err := os.ReadFile(e.absPath("Team site.url")); err != nil || !strings.HasPrefix(string(data), "[InternetShortcut]") {
+		t.Fatalf("expected internet shortcut, got %q, %v", data, err)
+	}
+
+	e = newTestEngine(t)
+	e.unsyncable = UnsyncableSkip
+	e.Status = status.NewStore()
+	if err := e.ApplyRemoteChange(ctx, RemoteChange{DriveID: "d-map", Path: "Trip", MimeType: "application/vnd.google-apps.map"}); err != nil {
+		t.Fatalf("ApplyRemoteChange: %v", err)
+	}
+	entries, _ := os.ReadDir(e.Config.SyncRoot)
+	if len(entries) != 0 || len(opTypes(t, e)) != 0 {
+		t.Fatalf("expected map skipped, got %d entries", len(entries))
+	}
+	if events := e.Status.Current().RecentEvents; len(events) != 1 || events[0].Op != statusUnsyncable || events[0].Path != "Trip" {
+		t.Fatalf("expected unsyncable status note, got %v", events)
+	}
+
+	// Exportable types are left to the normal path.
+	if unsyncable("application/vnd.google-apps.document") || !unsyncable(formMimeType) {
+		t.Fatal("unexpected unsyncable classification")
+	}
+	if _, err := ParseUnsyncablePolicy("bogus"); err == nil {
+		t.Fatal("expected unknown policy rejected")
+	}
+}
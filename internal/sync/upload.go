@@ -0,0 +1,227 @@
+package sync
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/encryption"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+	"github.com/sandeepkv93/googlysync/internal/transfer"
+)
+
+// Uploader sends file content to Drive. The executor uploads through
+// remotes that implement it.
+type Uploader interface {
+	Upload(ctx context.Context, id string, meta driveapi.FileMeta, content io.Reader) (*driveapi.File, error)
+}
+
+// Upload is local content prepared for sending to Drive.
+type Upload struct {
+	io.ReadCloser
+	// Size is the number of bytes that will be read, or -1 when compression
+	// makes it unknown ahead of time.
+	Size int64
+	// AppProperties are set on the Drive file along with the content.
+	AppProperties map[string]string
+}
+
+// UploadContent opens what should be sent to Drive for rel. The file is
+// compressed when compression is on and its type is eligible, then sealed
+// under the account key when content encryption is on. Once an encrypted
+// upload finishes, record it with RecordSealedUpload.
+func (e *Engine) UploadContent(rel string) (*Upload, error) {
+	abs := e.absPath(rel)
+	f, err := os.Open(abs)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	compress := e.compresses(rel)
+	if !compress && !e.encryptContent {
+		return &Upload{ReadCloser: f, Size: info.Size()}, nil
+	}
+
+	upload := &Upload{Size: info.Size()}
+	var key []byte
+	if e.encryptContent {
+		if key, err = e.accountKey(); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		upload.Size = encryption.CipherSize(info.Size())
+	}
+	if compress {
+		upload.Size = -1
+		upload.AppProperties = map[string]string{
+			appPropCompression: compressionGzip,
+			appPropSize:        strconv.FormatInt(info.Size(), 10),
+		}
+		// Encrypted files keep their plaintext checksum locally instead; see
+		// RecordSealedUpload.
+		if !e.encryptContent {
+			sum, _, err := fileChecksum(abs)
+			if err != nil {
+				_ = f.Close()
+				return nil, err
+			}
+			upload.AppProperties[appPropMD5] = sum
+		}
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer f.Close()
+		_ = pw.CloseWithError(writeUpload(pw, f, key, compress))
+	}()
+	upload.ReadCloser = pr
+	return upload, nil
+}
+
+// writeUpload copies src to dst, compressing and then encrypting it as asked.
+func writeUpload(dst io.Writer, src io.Reader, key []byte, compress bool) error {
+	w := dst
+	var closers []io.Closer
+	if key != nil {
+		enc, err := encryption.NewEncrypter(dst, key)
+		if err != nil {
+			return err
+		}
+		w = enc
+		closers = append(closers, enc)
+	}
+	if compress {
+		gz := gzip.NewWriter(w)
+		w = gz
+		// The gzip trailer must be written before the final chunk is sealed.
+		closers = append([]io.Closer{gz}, closers...)
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		return err
+	}
+	for _, c := range closers {
+		if err := c.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upload sends the content of op.Path to Drive: as new content of the file
+// it is linked to, or as a new file in the Drive folder of its parent, which
+// is created first when missing. A file removed since the upload was planned
+// has nothing left to send.
+func (x *Executor) upload(ctx context.Context, op storage.PendingOp) error {
+	e := x.engine
+	remote, err := x.remote(ctx, "upload", op.Path)
+	if err != nil {
+		return err
+	}
+	uploader, ok := remote.(Uploader)
+	if !ok {
+		return errs.New(errs.ErrInvalidArgument, "drive client cannot upload %s", op.Path)
+	}
+	abs := e.absPath(op.Path)
+	info, err := os.Stat(abs)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	checksum, fast, err := fileDigests(abs)
+	if err != nil {
+		return err
+	}
+
+	rec, err := e.Store.GetFileByPath(ctx, e.accountID, op.Path)
+	if err != nil {
+		return err
+	}
+	driveID := op.DriveID
+	if rec != nil && rec.DriveID != "" {
+		driveID = rec.DriveID
+	}
+	var meta driveapi.FileMeta
+	if driveID == "" {
+		named := rec
+		if named == nil {
+			named = &storage.FileRecord{Path: op.Path}
+		}
+		if meta.Name, err = e.UploadName(named); err != nil {
+			return err
+		}
+		parent, err := x.driveFolder(ctx, remote, path.Dir(op.Path))
+		if err != nil {
+			return err
+		}
+		meta.Parents = []string{parent}
+	}
+	content, err := e.UploadContent(op.Path)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	tracker := e.Progress.Start(transfer.DirectionUpload, op.Path, max(content.Size, 0))
+	defer tracker.Done()
+	meta.AppProperties = content.AppProperties
+	if driveID != "" && meta.AppProperties == nil {
+		// An earlier version may have been sent compressed.
+		meta.AppProperties = map[string]string{appPropCompression: ""}
+	}
+	file, err := uploader.Upload(ctx, driveID, meta, io.TeeReader(content, tracker))
+	if err != nil {
+		return err
+	}
+
+	return x.recordUpload(ctx, op.Path, rec, file, localFile{checksum: checksum, fast: fast, size: info.Size()}, "UPLOAD")
+}
+
+// localFile is the local content a Drive file was made from.
+type localFile struct {
+	checksum string
+	fast     string
+	size     int64
+}
+
+// recordUpload indexes file as the Drive copy of rel's local content and
+// reports it with a status event.
+func (x *Executor) recordUpload(ctx context.Context, rel string, rec *storage.FileRecord, file *driveapi.File, local localFile, event string) error {
+	e := x.engine
+	change := RemoteChange{
+		DriveID:    file.ID,
+		Path:       rel,
+		Name:       file.Name,
+		Checksum:   local.checksum,
+		Size:       local.size,
+		ModifiedAt: file.ModifiedTime,
+		ReadOnly:   file.ReadOnly,
+	}
+	if err := e.recordRemote(ctx, withDriveMeta(change, file), rel, rec); err != nil {
+		return err
+	}
+	if err := e.Store.SetFileFastHash(ctx, e.accountID, rel, local.fast); err != nil {
+		return err
+	}
+	if e.encryptContent {
+		// Drive reports the checksum of the ciphertext.
+		if err := e.RecordSealedUpload(ctx, rel, file.ID, file.MD5Checksum); err != nil {
+			return err
+		}
+	}
+	if e.Status != nil {
+		e.Status.AddEvent(status.Event{Op: event, Path: rel})
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+	"github.com/sandeepkv93/googlysync/internal/transfer"
+)
+
+// Kinds of problems Verify reports.
+const (
+	// VerifyLocalMissing is an indexed file that is gone from disk.
+	VerifyLocalMissing = "local_missing"
+	// VerifyLocalChanged is a file whose content no longer matches the index.
+	VerifyLocalChanged = "local_changed"
+	// VerifyRemoteMissing is an indexed file that is gone or trashed on Drive.
+	VerifyRemoteMissing = "remote_missing"
+	// VerifyRemoteChanged is a file whose Drive checksum no longer matches
+	// the index.
+	VerifyRemoteChanged = "remote_changed"
+	// VerifyUntracked is a local file with no index entry.
+	VerifyUntracked = "untracked"
+)
+
+// verifyPageSize is how many index entries Verify reads at a time.
+var verifyPageSize = 500
+
+// FileLookup fetches Drive metadata for a file.
+type FileLookup interface {
+	GetFile(ctx context.Context, id string) (*driveapi.File, error)
+}
+
+// VerifyIssue is one mismatch found by Verify.
+type VerifyIssue struct {
+	Kind   string
+	Path   string
+	Detail string
+	// Repaired is set when a repair op was queued for the issue.
+	Repaired bool
+}
+
+// VerifyReport summarizes a Verify run.
+type VerifyReport struct {
+	Checked int
+	// Skipped counts indexed files left alone because ops are still pending
+	// for them.
+	Skipped int
+	Issues  []VerifyIssue
+}
+
+// Verify checks the index against the sync root and, when remote is set,
+// against Drive. Every indexed file under prefix is re-hashed and compared
+// with its recorded checksum and with the checksum Drive reports now; local
+// files with no index entry are reported as untracked. With repair set, the
+// op that brings each side back in line is queued: a download for content
+// missing or changed only on Drive, an upload for content missing or changed
+// only locally. Files changed on both sides are reported but not repaired.
+func (e *Engine) Verify(ctx context.Context, remote FileLookup, prefix string, repair bool) (*VerifyReport, error) {
+	prefix = strings.Trim(filepath.ToSlash(prefix), "/")
+	report := &VerifyReport{}
+	indexed := make(map[string]bool)
+	after := ""
+	for {
+		recs, err := e.Store.ListFilesAfter(ctx, e.accountID, after, verifyPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for i := range recs {
+			rec := &recs[i]
+			after = rec.Path
+			indexed[rec.Path] = true
+			if !underPrefix(rec.Path, prefix) {
+				continue
+			}
+			if err := e.verifyFile(ctx, remote, rec, repair, report); err != nil {
+				return nil, fmt.Errorf("verify %s: %w", rec.Path, err)
+			}
+		}
+		if len(recs) < verifyPageSize {
+			break
+		}
+	}
+	if err := e.findUntracked(ctx, prefix, indexed, repair, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (e *Engine) verifyFile(ctx context.Context, remote FileLookup, rec *storage.FileRecord, repair bool, report *VerifyReport) error {
+	if pending, err := e.Store.HasPendingOps(ctx, e.accountID, rec.Path); err != nil || pending {
+		report.Skipped++
+		return err
+	}
+	placeholder, err := e.Store.IsPlaceholder(ctx, e.accountID, rec.Path)
+	if err != nil {
+		return err
+	}
+	report.Checked++
+
+	localKind, localDetail := "", ""
+	if !placeholder {
+		localKind, localDetail, err = e.verifyLocal(rec)
+		if err != nil {
+			return err
+		}
+	}
+	remoteKind, remoteDetail := "", ""
+	if remote != nil && rec.DriveID != "" {
+		remoteKind, remoteDetail, err = e.verifyRemote(ctx, remote, rec)
+		if err != nil {
+			return err
+		}
+	}
+
+	both := localKind != "" && remoteKind != ""
+	if localKind != "" {
+		issue := VerifyIssue{Kind: localKind, Path: rec.Path, Detail: localDetail}
+		if repair && !both {
+			if localKind == VerifyLocalMissing {
+				err = e.addDownload(ctx, rec.Path, rec.DriveID, rec.Size)
+			} else {
+				err = e.addOp(ctx, opUpload, rec.Path, rec.DriveID)
+			}
+			issue.Repaired = err == nil
+		}
+		report.Issues = append(report.Issues, issue)
+		if err != nil {
+			return err
+		}
+	}
+	if remoteKind != "" {
+		issue := VerifyIssue{Kind: remoteKind, Path: rec.Path, Detail: remoteDetail}
+		if repair && !both {
+			if remoteKind == VerifyRemoteMissing {
+				err = e.addOp(ctx, opUpload, rec.Path, "")
+			} else {
+				err = e.addDownload(ctx, rec.Path, rec.DriveID, rec.Size)
+			}
+			issue.Repaired = err == nil
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+	return err
+}
+
+// verifyLocal compares the file on disk with the index, using the fast hash
+// when one is recorded and MD5 otherwise.
+func (e *Engine) verifyLocal(rec *storage.FileRecord) (string, string, error) {
+	abs := e.absPath(rec.Path)
+	info, err := os.Stat(abs)
+	if errors.Is(err, fs.ErrNotExist) {
+		return VerifyLocalMissing, "not on disk", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if info.Size() != rec.Size {
+		return VerifyLocalChanged, fmt.Sprintf("size %d, index has %d", info.Size(), rec.Size), nil
+	}
+	if rec.FastHash != "" {
+		fast, err := fileFastHash(abs)
+		if err != nil {
+			return "", "", err
+		}
+		if fast != rec.FastHash {
+			return VerifyLocalChanged, "content differs from index", nil
+		}
+		return "", "", nil
+	}
+	if rec.Checksum == "" {
+		return "", "", nil
+	}
+	sum, _, err := fileChecksum(abs)
+	if err != nil {
+		return "", "", err
+	}
+	if !strings.EqualFold(sum, rec.Checksum) {
+		return VerifyLocalChanged, fmt.Sprintf("md5 %s, index has %s", sum, rec.Checksum), nil
+	}
+	return "", "", nil
+}
+
+func (e *Engine) verifyRemote(ctx context.Context, remote FileLookup, rec *storage.FileRecord) (string, string, error) {
+	file, err := remote.GetFile(ctx, rec.DriveID)
+	if errs.KindOf(err) == errs.ErrNotFound {
+		return VerifyRemoteMissing, "not found on Drive", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if file.Trashed {
+		return VerifyRemoteMissing, "in Drive trash", nil
+	}
+	// Native Google files have no checksum to compare.
+	if file.MD5Checksum == "" || rec.Checksum == "" {
+		return "", "", nil
+	}
+	// Compressed and encrypted content is compared through its plaintext
+	// checksum.
+	checksum, size := driveContent(file)
+	change, err := e.openSealedChange(ctx, RemoteChange{DriveID: rec.DriveID, Checksum: checksum, Size: size})
+	if err != nil {
+		return "", "", err
+	}
+	if !strings.EqualFold(change.Checksum, rec.Checksum) {
+		return VerifyRemoteChanged, fmt.Sprintf("drive md5 %s, index has %s", change.Checksum, rec.Checksum), nil
+	}
+	return "", "", nil
+}
+
+// findUntracked reports regular files under prefix that have no index entry.
+func (e *Engine) findUntracked(ctx context.Context, prefix string, indexed map[string]bool, repair bool, report *VerifyReport) error {
+	root := e.absPath(prefix)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		rel := e.relPath(p)
+		if e.ignored(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || transfer.IsPartial(p) {
+			return nil
+		}
+		if indexed[rel] {
+			return nil
+		}
+		pending, err := e.Store.HasPendingOps(ctx, e.accountID, rel)
+		if err != nil || pending {
+			return err
+		}
+		issue := VerifyIssue{Kind: VerifyUntracked, Path: rel, Detail: "not in index"}
+		if repair {
+			if err := e.addOp(ctx, opUpload, rel, ""); err != nil {
+				return err
+			}
+			issue.Repaired = true
+		}
+		report.Issues = append(report.Issues, issue)
+		return nil
+	})
+	return err
+}
+
+func underPrefix(rel, prefix string) bool {
+	return prefix == "" || rel == prefix || strings.HasPrefix(rel, prefix+"/")
+}
@@ -0,0 +1,187 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandeepkv93/googlysync/internal/driveapi"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// lookupMap serves GetFile from an id -> file map; missing ids are not found.
+type lookupMap map[string]driveapi.File
+
+func (m lookupMap) GetFile(_ context.Context, id string) (*driveapi.File, error) {
+	file, ok := m[id]
+	if !ok {
+		return nil, errs.New(errs.ErrNotFound, "file %s", id)
+	}
+	return &file, nil
+}
+
+func indexFile(t *testing.T, e *Engine, rel, driveID, content string) {
+	t.Helper()
+	abs := e.absPath(rel)
+	if err := os.MkdirAll(filepath.Dir(abs), 0o700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(abs, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	rec := &storage.FileRecord{
+		ID:        "file-" + driveID,
+		AccountID: e.accountID,
+		Path:      rel,
+		DriveID:   driveID,
+		Size:      int64(len(content)),
+		Checksum:  md5Hex(content),
+	}
+	if err := e.Store.UpsertFile(context.Background(), rec); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+}
+
+func issueKinds(report *VerifyReport) map[string]string {
+	out := make(map[string]string)
+	for _, issue := range report.Issues {
+		out[issue.Path] = issue.Kind
+	}
+	return out
+}
+
+func TestVerifyReportsMismatches(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	defer func(n int) { verifyPageSize = n }(verifyPageSize)
+	verifyPageSize = 2
+
+	indexFile(t, e, "ok.txt", "d-ok", "same")
+	indexFile(t, e, "edited.txt", "d-edited", "before")
+	indexFile(t, e, "gone.txt", "d-gone", "gone")
+	indexFile(t, e, "deleted.txt", "d-deleted", "deleted")
+	indexFile(t, e, "trashed.txt", "d-trashed", "trashed")
+	indexFile(t, e, "newer.txt", "d-newer", "old")
+	if err := os.WriteFile(e.absPath("edited.txt"), []byte("after!"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Remove(e.absPath("gone.txt")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := os.WriteFile(e.absPath("stray.txt"), []byte("stray"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	remote := lookupMap{
+		"d-ok":      {ID: "d-ok", MD5Checksum: md5Hex("same")},
+		"d-edited":  {ID: "d-edited", MD5Checksum: md5Hex("before")},
+		"d-gone":    {ID: "d-gone", MD5Checksum: md5Hex("gone")},
+		"d-trashed": {ID: "d-trashed", MD5Checksum: md5Hex("trashed"), Trashed: true},
+		"d-newer":   {ID: "d-newer", MD5Checksum: md5Hex("new")},
+	}
+
+	report, err := e.Verify(ctx, remote, "", false)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if report.Checked != 6 {
+		t.Fatalf("expected 6 checked, got %d", report.Checked)
+	}
+	want := map[string]string{
+		"edited.txt":  VerifyLocalChanged,
+		"gone.txt":    VerifyLocalMissing,
+		"deleted.txt": VerifyRemoteMissing,
+		"trashed.txt": VerifyRemoteMissing,
+		"newer.txt":   VerifyRemoteChanged,
+		"stray.txt":   VerifyUntracked,
+	}
+	got := issueKinds(report)
+	if len(got) != len(want) {
+		t.Fatalf("expected issues %v, got %v", want, got)
+	}
+	for path, kind := range want {
+		if got[path] != kind {
+			t.Fatalf("expected %s to be %s, got %q", path, kind, got[path])
+		}
+	}
+	if ops := opTypes(t, e); len(ops) != 0 {
+		t.Fatalf("expected no ops without repair, got %v", ops)
+	}
+}
+
+func TestVerifyRepairQueuesOps(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	indexFile(t, e, "gone.txt", "d-gone", "gone")
+	indexFile(t, e, "newer.txt", "d-newer", "old")
+	indexFile(t, e, "both.txt", "d-both", "base")
+	if err := os.Remove(e.absPath("gone.txt")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := os.WriteFile(e.absPath("both.txt"), []byte("local"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(e.absPath("stray.txt"), []byte("stray"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	remote := lookupMap{
+		"d-gone":  {ID: "d-gone", MD5Checksum: md5Hex("gone")},
+		"d-newer": {ID: "d-newer", MD5Checksum: md5Hex("new")},
+		"d-both":  {ID: "d-both", MD5Checksum: md5Hex("remote")},
+	}
+
+	report, err := e.Verify(ctx, remote, "", true)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	for _, issue := range report.Issues {
+		if issue.Path == "both.txt" && issue.Repaired {
+			t.Fatalf("expected file changed on both sides to be left alone: %+v", issue)
+		}
+	}
+	ops := map[string]bool{}
+	for _, op := range opTypes(t, e) {
+		ops[op] = true
+	}
+	for _, want := range []string{opDownload + " gone.txt", opDownload + " newer.txt", opUpload + " stray.txt"} {
+		if !ops[want] {
+			t.Fatalf("expected %q queued, got %v", want, ops)
+		}
+	}
+	if len(ops) != 3 {
+		t.Fatalf("expected 3 ops, got %v", ops)
+	}
+
+	// Files with pending ops are skipped on the next pass.
+	again, err := e.Verify(ctx, remote, "", false)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if again.Skipped != 2 {
+		t.Fatalf("expected 2 skipped, got %d", again.Skipped)
+	}
+}
+
+func TestVerifyLocalOnlyWithPrefix(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	indexFile(t, e, "docs/a.txt", "d-a", "a")
+	indexFile(t, e, "other/b.txt", "d-b", "b")
+	for _, rel := range []string{"docs/a.txt", "other/b.txt"} {
+		if err := os.WriteFile(e.absPath(rel), []byte("changed"), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	report, err := e.Verify(ctx, nil, "docs", false)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	got := issueKinds(report)
+	if len(got) != 1 || got["docs/a.txt"] != VerifyLocalChanged {
+		t.Fatalf("expected only docs/a.txt changed, got %v", got)
+	}
+}
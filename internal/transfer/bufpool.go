@@ -0,0 +1,132 @@
+package transfer
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+)
+
+// BufferStats reports buffer pool usage.
+type BufferStats struct {
+	BufferSize  int
+	BudgetBytes int64
+	InUse       int
+	PeakInUse   int
+	Acquired    uint64
+	Waits       uint64
+}
+
+// BufferPool hands out fixed-size transfer buffers under a global memory
+// budget. Transfers block for a free buffer instead of allocating more, so
+// large files stream through a bounded amount of memory.
+type BufferPool struct {
+	size  int
+	slots chan struct{}
+	pool  sync.Pool
+
+	mu    sync.Mutex
+	stats BufferStats
+}
+
+// NewBufferPool constructs a pool from the configured buffer size and budget.
+func NewBufferPool(cfg *config.Config) *BufferPool {
+	size := cfg.TransferBufferKB << 10
+	if size <= 0 {
+		size = 256 << 10
+	}
+	budget := int64(cfg.TransferMemoryMB) << 20
+	count := int(budget / int64(size))
+	if count < 1 {
+		count = 1
+	}
+	p := &BufferPool{
+		size:  size,
+		slots: make(chan struct{}, count),
+	}
+	p.pool.New = func() any {
+		buf := make([]byte, size)
+		return &buf
+	}
+	p.stats.BufferSize = size
+	p.stats.BudgetBytes = int64(count) * int64(size)
+	return p
+}
+
+// Get waits for a buffer within the memory budget.
+func (p *BufferPool) Get(ctx context.Context) (*[]byte, error) {
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		p.mu.Lock()
+		p.stats.Waits++
+		p.mu.Unlock()
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	p.mu.Lock()
+	p.stats.Acquired++
+	p.stats.InUse++
+	if p.stats.InUse > p.stats.PeakInUse {
+		p.stats.PeakInUse = p.stats.InUse
+	}
+	p.mu.Unlock()
+	return p.pool.Get().(*[]byte), nil
+}
+
+// Put returns a buffer obtained from Get.
+func (p *BufferPool) Put(buf *[]byte) {
+	if buf == nil {
+		return
+	}
+	p.pool.Put(buf)
+	p.mu.Lock()
+	p.stats.InUse--
+	p.mu.Unlock()
+	<-p.slots
+}
+
+// Stats returns a snapshot of pool usage.
+func (p *BufferPool) Stats() BufferStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+// Copy streams src to dst through one pooled buffer.
+func (p *BufferPool) Copy(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
+	buf, err := p.Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer p.Put(buf)
+
+	var written int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		n, rerr := src.Read(*buf)
+		if n > 0 {
+			m, werr := dst.Write((*buf)[:n])
+			written += int64(m)
+			if werr != nil {
+				return written, werr
+			}
+			if m != n {
+				return written, io.ErrShortWrite
+			}
+		}
+		if rerr == io.EOF {
+			return written, nil
+		}
+		if rerr != nil {
+			return written, rerr
+		}
+	}
+}
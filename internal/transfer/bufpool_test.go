@@ -0,0 +1,71 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/status"
+)
+
+func TestBufferPoolEnforcesBudget(t *testing.T) {
+	p := NewBufferPool(&config.Config{TransferBufferKB: 1, TransferMemoryMB: 0})
+	ctx := context.Background()
+
+	first, err := p.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(waitCtx); err == nil {
+		t.Fatal("expected Get to block past the budget")
+	}
+	p.Put(first)
+
+	stats := p.Stats()
+	if stats.InUse != 0 || stats.PeakInUse != 1 || stats.Waits != 1 || stats.BudgetBytes != 1<<10 {
+		t.Fatalf("unexpected stats: %#v", stats)
+	}
+}
+
+func TestBufferPoolCopy(t *testing.T) {
+	p := NewBufferPool(&config.Config{TransferBufferKB: 1, TransferMemoryMB: 1})
+	src := bytes.Repeat([]byte("x"), 5000)
+	var dst bytes.Buffer
+	n, err := p.Copy(context.Background(), &dst, bytes.NewReader(src))
+	if err != nil || n != int64(len(src)) || !bytes.Equal(dst.Bytes(), src) {
+		t.Fatalf("Copy: %d, %v", n, err)
+	}
+	if stats := p.Stats(); stats.InUse != 0 || stats.Acquired != 1 {
+		t.Fatalf("unexpected stats: %#v", stats)
+	}
+}
+
+func TestDownloaderFetchStreamsThroughPool(t *testing.T) {
+	cfg := &config.Config{SyncRoot: t.TempDir(), PreallocateMinMB: 1, TransferBufferKB: 4, TransferMemoryMB: 1}
+	pool := NewBufferPool(cfg)
+	d, err := NewDownloader(zap.NewNop(), cfg, status.NewStore(), nil, pool, nil)
+	if err != nil {
+		t.Fatalf("NewDownloader: %v", err)
+	}
+
+	body := bytes.Repeat([]byte("y"), 10_000)
+	n, _, err := d.Fetch(context.Background(), "big.bin", 2<<20, "", bytes.NewReader(body))
+	if err != nil || n != int64(len(body)) {
+		t.Fatalf("Fetch: %d, %v", n, err)
+	}
+	data, err := os.ReadFile(filepath.Join(cfg.SyncRoot, "big.bin"))
+	if err != nil || !bytes.Equal(data, body) {
+		t.Fatalf("expected fetched content truncated to body, got %d bytes, %v", len(data), err)
+	}
+	if stats := pool.Stats(); stats.Acquired != 1 || stats.InUse != 0 {
+		t.Fatalf("unexpected pool stats: %#v", stats)
+	}
+}
@@ -0,0 +1,242 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/hashing"
+	"github.com/sandeepkv93/googlysync/internal/status"
+	"github.com/sandeepkv93/googlysync/internal/versions"
+)
+
+// PartialSuffix marks a download in progress. Content is written to
+// "<name>.googlysync.partial" next to the target and renamed over it once
+// complete, so the target never holds a half-written file.
+const PartialSuffix = ".googlysync.partial"
+
+// ErrChecksumMismatch reports downloaded content that does not match the
+// checksum Drive advertised.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// IsPartial reports whether path is an in-progress download file.
+func IsPartial(path string) bool {
+	return strings.HasSuffix(path, PartialSuffix)
+}
+
+// Downloader prepares local targets for incoming file content.
+type Downloader struct {
+	logger      *zap.Logger
+	cfg         *config.Config
+	status      *status.Store
+	versions    *versions.Store
+	buffers     *BufferPool
+	progress    *Progress
+	preallocMin int64
+
+	// active counts Fetch calls in progress so a pause can wait for them.
+	mu     sync.Mutex
+	active int
+	idle   chan struct{}
+}
+
+// NewDownloader constructs a downloader rooted at the configured sync root.
+func NewDownloader(
+	logger *zap.Logger,
+	cfg *config.Config,
+	statusStore *status.Store,
+	versionStore *versions.Store,
+	buffers *BufferPool,
+	progress *Progress,
+) (*Downloader, error) {
+	return &Downloader{
+		logger:      logger,
+		cfg:         cfg,
+		status:      statusStore,
+		versions:    versionStore,
+		buffers:     buffers,
+		progress:    progress,
+		preallocMin: int64(cfg.PreallocateMinMB) << 20,
+	}, nil
+}
+
+// Create opens the partial file for the root-relative path. Downloads at or
+// above the configured threshold have their full size reserved before any
+// bytes arrive.
+func (d *Downloader) Create(ctx context.Context, rel string, size int64) (*os.File, error) {
+	_ = ctx
+	path := d.path(rel) + PartialSuffix
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if d.preallocMin <= 0 || size < d.preallocMin {
+		return f, nil
+	}
+
+	alloc, err := Preallocate(f, size)
+	switch {
+	case err == nil:
+		if alloc == AllocSparse {
+			d.logger.Debug("preallocation unsupported; using sparse file", zap.String("path", rel))
+		}
+		return f, nil
+	case errors.Is(err, ErrInsufficientSpace):
+		_ = f.Close()
+		_ = os.Remove(path)
+		d.report(fmt.Sprintf("not enough disk space to download %s (%d bytes); free space and retry", rel, size))
+		return nil, fmt.Errorf("download %s: %w", rel, err)
+	default:
+		d.logger.Warn("preallocation failed", zap.String("path", rel), zap.Int64("size", size), zap.Error(err))
+		d.report(fmt.Sprintf("could not reserve space for %s: %v; continuing without preallocation", rel, err))
+		return f, nil
+	}
+}
+
+// Fetch streams body into the root-relative path through a pooled buffer so
+// large downloads stay within the transfer memory budget. The content lands
+// in a partial file that replaces the target only once it is complete and,
+// when checksum is set, matches that hex MD5. On failure the target is left
+// as it was. Existing content is stashed as a local version before it is
+// replaced. Its progress is visible in the registry while it runs. Fetch
+// returns the bytes written and the XXH64 digest of the content for later
+// change detection.
+func (d *Downloader) Fetch(ctx context.Context, rel string, size int64, checksum string, body io.Reader) (int64, string, error) {
+	d.begin()
+	defer d.end()
+	h, err := hashing.NewHasher(hashing.MD5, hashing.XXH64)
+	if err != nil {
+		return 0, "", err
+	}
+	f, err := d.Create(ctx, rel, size)
+	if err != nil {
+		return 0, "", err
+	}
+	partial := f.Name()
+	tracker := d.progress.Start(DirectionDownload, rel, size)
+	defer tracker.Done()
+	w := io.MultiWriter(f, h, tracker)
+	var n int64
+	if d.buffers != nil {
+		n, err = d.buffers.Copy(ctx, w, body)
+	} else {
+		n, err = io.Copy(w, body)
+	}
+	if err == nil && n < size {
+		// Preallocated files are already full length; drop the unused tail.
+		err = f.Truncate(n)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	sums := h.Sums()
+	if err == nil && checksum != "" {
+		if got := sums[hashing.MD5]; got != strings.ToLower(checksum) {
+			err = fmt.Errorf("%w: got md5 %s, want %s", ErrChecksumMismatch, got, strings.ToLower(checksum))
+		}
+	}
+	if err == nil && d.versions != nil {
+		if _, stashErr := d.versions.Stash(ctx, rel); stashErr != nil {
+			err = fmt.Errorf("stash previous version: %w", stashErr)
+		}
+	}
+	if err == nil {
+		err = os.Rename(partial, d.path(rel))
+	}
+	if err != nil {
+		_ = os.Remove(partial)
+		return n, "", fmt.Errorf("download %s: %w", rel, err)
+	}
+	return n, sums[hashing.XXH64], nil
+}
+
+// RemovePartials deletes partial files left under the sync root by downloads
+// that never finished, e.g. after a crash. Call it only while no download is
+// running. It returns how many were removed.
+func (d *Downloader) RemovePartials() (int, error) {
+	removed := 0
+	err := filepath.WalkDir(d.cfg.SyncRoot, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.Type().IsRegular() && IsPartial(path) {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+func (d *Downloader) path(rel string) string {
+	return filepath.Join(d.cfg.SyncRoot, filepath.FromSlash(rel))
+}
+
+// Drain waits until no download is in progress or ctx is done. Callers stop
+// starting new downloads first, e.g. when sync is paused.
+func (d *Downloader) Drain(ctx context.Context) error {
+	d.mu.Lock()
+	if d.active == 0 {
+		d.mu.Unlock()
+		return nil
+	}
+	if d.idle == nil {
+		d.idle = make(chan struct{})
+	}
+	idle := d.idle
+	d.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// InFlight returns the number of downloads in progress.
+func (d *Downloader) InFlight() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.active
+}
+
+func (d *Downloader) begin() {
+	d.mu.Lock()
+	d.active++
+	d.mu.Unlock()
+}
+
+func (d *Downloader) end() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.active--
+	if d.active == 0 && d.idle != nil {
+		close(d.idle)
+		d.idle = nil
+	}
+}
+
+func (d *Downloader) report(msg string) {
+	if d.status == nil {
+		return
+	}
+	d.status.Update(status.Snapshot{State: status.StateError, Message: msg})
+}
@@ -0,0 +1,120 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+)
+
+func TestDrainWaitsForInFlightDownloads(t *testing.T) {
+	cfg := &config.Config{SyncRoot: t.TempDir()}
+	d, err := NewDownloader(zap.NewNop(), cfg, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewDownloader: %v", err)
+	}
+	ctx := context.Background()
+	if err := d.Drain(ctx); err != nil {
+		t.Fatalf("Drain with nothing in flight: %v", err)
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := d.Fetch(ctx, "big.bin", 0, "", pr)
+		done <- err
+	}()
+	for d.InFlight() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := d.Drain(short); err == nil {
+		t.Fatal("expected drain to time out while a download is running")
+	}
+
+	drained := make(chan error, 1)
+	go func() { drained <- d.Drain(ctx) }()
+	_, _ = pw.Write([]byte("data"))
+	_ = pw.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if err := <-drained; err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+}
+
+func TestFetchReplacesTargetAtomically(t *testing.T) {
+	cfg := &config.Config{SyncRoot: t.TempDir()}
+	d, err := NewDownloader(zap.NewNop(), cfg, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewDownloader: %v", err)
+	}
+	ctx := context.Background()
+	target := filepath.Join(cfg.SyncRoot, "docs", "a.txt")
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(target, []byte("old"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	// md5("hello")
+	const sum = "5d41402abc4b2a76b9719d911017c592"
+	if _, _, err := d.Fetch(ctx, "docs/a.txt", 5, sum, strings.NewReader("jello")); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "old" {
+		t.Fatalf("expected target untouched after a bad download, got %q", data)
+	}
+	if _, err := os.Lstat(target + PartialSuffix); !os.IsNotExist(err) {
+		t.Fatalf("expected partial file removed, got %v", err)
+	}
+
+	// xxh64("hello")
+	if n, fast, err := d.Fetch(ctx, "docs/a.txt", 5, strings.ToUpper(sum), strings.NewReader("hello")); err != nil || n != 5 || fast != "26c7827d889f6da3" {
+		t.Fatalf("Fetch: %d, %s, %v", n, fast, err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "hello" {
+		t.Fatalf("expected new content, got %q", data)
+	}
+	if _, err := os.Lstat(target + PartialSuffix); !os.IsNotExist(err) {
+		t.Fatalf("expected no partial file left, got %v", err)
+	}
+}
+
+func TestRemovePartials(t *testing.T) {
+	cfg := &config.Config{SyncRoot: t.TempDir()}
+	d, err := NewDownloader(zap.NewNop(), cfg, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewDownloader: %v", err)
+	}
+	for _, name := range []string{"a.bin" + PartialSuffix, "docs/b.bin" + PartialSuffix, "docs/c.bin"} {
+		path := filepath.Join(cfg.SyncRoot, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	if n, err := d.RemovePartials(); err != nil || n != 2 {
+		t.Fatalf("RemovePartials: %d, %v", n, err)
+	}
+	if _, err := os.Lstat(filepath.Join(cfg.SyncRoot, "docs", "c.bin")); err != nil {
+		t.Fatalf("expected regular file kept: %v", err)
+	}
+	if !IsPartial("x/y.txt"+PartialSuffix) || IsPartial("x/y.txt") {
+		t.Fatal("IsPartial mismatch")
+	}
+}
@@ -0,0 +1,7 @@
+package transfer
+
+import "errors"
+
+// ErrFreeSpaceUnsupported is returned by FreeSpace on platforms where free
+// space cannot be queried.
+var ErrFreeSpaceUnsupported = errors.New("free space query not supported")
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package transfer
+
+// FreeSpace returns the bytes available on the filesystem holding path.
+func FreeSpace(path string) (int64, error) {
+	_ = path
+	return 0, ErrFreeSpaceUnsupported
+}
@@ -0,0 +1,19 @@
+package transfer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFreeSpace(t *testing.T) {
+	free, err := FreeSpace(t.TempDir())
+	if errors.Is(err, ErrFreeSpaceUnsupported) {
+		t.Skip("free space not supported on this platform")
+	}
+	if err != nil || free <= 0 {
+		t.Fatalf("FreeSpace: %d, %v", free, err)
+	}
+	if _, err := FreeSpace("/does/not/exist"); err == nil {
+		t.Fatal("expected error for missing path")
+	}
+}
@@ -0,0 +1,253 @@
+package transfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// ManifestVersion is the format version written by ExportManifest.
+const ManifestVersion = 1
+
+// uploadSessionTTL is how long Drive keeps a resumable upload session open.
+const uploadSessionTTL = 7 * 24 * time.Hour
+
+// Manifest is a portable list of resumable transfers. Paths are relative to
+// the sync root, so a manifest can be imported on a machine whose sync root
+// lives elsewhere.
+type Manifest struct {
+	Version    int               `json:"version"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Sessions   []ManifestSession `json:"sessions"`
+}
+
+// ManifestSession is one resumable transfer in a manifest.
+type ManifestSession struct {
+	ID         string          `json:"id"`
+	AccountID  string          `json:"account_id"`
+	Direction  string          `json:"direction"`
+	Path       string          `json:"path"`
+	DriveID    string          `json:"drive_id,omitempty"`
+	SessionURI string          `json:"session_uri,omitempty"`
+	Size       int64           `json:"size"`
+	Checksum   string          `json:"sha256,omitempty"`
+	Offset     int64           `json:"offset,omitempty"`
+	ChunkSize  int64           `json:"chunk_size,omitempty"`
+	Chunks     []ManifestChunk `json:"chunks,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// ManifestChunk is a completed download range.
+type ManifestChunk struct {
+	Index    int    `json:"index"`
+	Checksum string `json:"sha256"`
+}
+
+// ImportReport summarizes what ImportManifest restored.
+type ImportReport struct {
+	Imported int
+	// Skipped lists "path: reason" for sessions that cannot be resumed here.
+	Skipped []string
+}
+
+// ExportManifest writes every stored resumable session as JSON and returns
+// how many were written.
+func ExportManifest(ctx context.Context, store storage.Store, w io.Writer, now time.Time) (int, error) {
+	sessions, err := store.ListTransferSessions(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	m := Manifest{Version: ManifestVersion, ExportedAt: now.UTC(), Sessions: make([]ManifestSession, 0, len(sessions))}
+	for _, s := range sessions {
+		ms := ManifestSession{
+			ID:         s.ID,
+			AccountID:  s.AccountID,
+			Direction:  s.Direction,
+			Path:       s.Path,
+			DriveID:    s.DriveID,
+			SessionURI: s.SessionURI,
+			Size:       s.Size,
+			Checksum:   s.Checksum,
+			Offset:     s.Offset,
+			ChunkSize:  s.ChunkSize,
+			CreatedAt:  s.CreatedAt.UTC(),
+		}
+		for _, c := range s.Chunks {
+			ms.Chunks = append(ms.Chunks, ManifestChunk{Index: c.Index, Checksum: c.Checksum})
+		}
+		m.Sessions = append(m.Sessions, ms)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return 0, err
+	}
+	return len(m.Sessions), nil
+}
+
+// ImportManifest restores sessions from a manifest after checking them
+// against the local sync root. An upload is kept only while its Drive session
+// is still open and the local file still has the content it was started
+// from. A download keeps only the chunks whose bytes in the local file still
+// match, so the file must have been copied along with the manifest.
+func ImportManifest(ctx context.Context, cfg *config.Config, store storage.Store, r io.Reader, now time.Time) (*ImportReport, error) {
+	var m Manifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, errs.New(errs.ErrInvalidArgument, "decode transfer manifest: %v", err)
+	}
+	if m.Version != ManifestVersion {
+		return nil, errs.New(errs.ErrInvalidArgument, "unsupported transfer manifest version %d", m.Version)
+	}
+
+	report := &ImportReport{}
+	for _, ms := range m.Sessions {
+		reason, err := importSession(ctx, cfg, store, ms, now)
+		if err != nil {
+			return report, fmt.Errorf("import %s: %w", ms.Path, err)
+		}
+		if reason != "" {
+			report.Skipped = append(report.Skipped, ms.Path+": "+reason)
+			continue
+		}
+		report.Imported++
+	}
+	return report, nil
+}
+
+func importSession(ctx context.Context, cfg *config.Config, store storage.Store, ms ManifestSession, now time.Time) (string, error) {
+	account, err := store.GetAccount(ctx, ms.AccountID)
+	if err != nil {
+		return "", err
+	}
+	if account == nil {
+		return "unknown account " + ms.AccountID, nil
+	}
+	path := filepath.Join(cfg.SyncRoot, filepath.FromSlash(ms.Path))
+	session := &storage.TransferSession{
+		ID:         ms.ID,
+		AccountID:  ms.AccountID,
+		Direction:  ms.Direction,
+		Path:       ms.Path,
+		DriveID:    ms.DriveID,
+		SessionURI: ms.SessionURI,
+		Size:       ms.Size,
+		Checksum:   ms.Checksum,
+		Offset:     ms.Offset,
+		ChunkSize:  ms.ChunkSize,
+		CreatedAt:  ms.CreatedAt,
+	}
+
+	switch ms.Direction {
+	case storage.TransferUpload:
+		if ms.SessionURI == "" {
+			return "no upload session", nil
+		}
+		if now.Sub(ms.CreatedAt) > uploadSessionTTL {
+			return "upload session expired", nil
+		}
+		sum, size, err := fileSHA256(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return "local file missing", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if size != ms.Size || (ms.Checksum != "" && sum != ms.Checksum) {
+			return "local file changed since the upload started", nil
+		}
+	case storage.TransferDownload:
+		chunks, err := verifiedChunks(path, ms.Size, ms.ChunkSize, ms.Chunks)
+		if err != nil {
+			return "", err
+		}
+		if len(chunks) == 0 {
+			return "no downloaded chunks match the local file", nil
+		}
+		session.Chunks = chunks
+	default:
+		return "unknown direction " + ms.Direction, nil
+	}
+	return "", store.SaveTransferSession(ctx, session)
+}
+
+// verifiedChunks hashes the local file in chunkSize ranges and returns the
+// listed chunks whose bytes still match. size is the length of the complete
+// download, which bounds the last chunk.
+func verifiedChunks(path string, size, chunkSize int64, listed []ManifestChunk) ([]storage.TransferChunk, error) {
+	if chunkSize <= 0 || len(listed) == 0 {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	local, err := hashChunks(f, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	var out []storage.TransferChunk
+	for _, c := range listed {
+		if c.Index < 0 || c.Index >= len(local) {
+			continue
+		}
+		want := min(chunkSize, size-int64(c.Index)*chunkSize)
+		if b := local[c.Index]; b.Size == want && b.Checksum == c.Checksum {
+			out = append(out, storage.TransferChunk{Index: c.Index, Checksum: c.Checksum})
+		}
+	}
+	return out, nil
+}
+
+// chunkSum is the length and SHA-256 of one chunk of a local file.
+type chunkSum struct {
+	Size     int64
+	Checksum string
+}
+
+// hashChunks hashes r in chunkSize ranges; only the last may be shorter.
+func hashChunks(r io.Reader, chunkSize int64) ([]chunkSum, error) {
+	var out []chunkSum
+	for {
+		h := sha256.New()
+		n, err := io.CopyN(h, r, chunkSize)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if n > 0 {
+			out = append(out, chunkSum{Size: n, Checksum: hex.EncodeToString(h.Sum(nil))})
+		}
+		if n < chunkSize {
+			return out, nil
+		}
+	}
+}
+
+func fileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
@@ -0,0 +1,113 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+func newManifestEnv(t *testing.T) (*config.Config, *storage.Storage) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &config.Config{
+		SyncRoot:     filepath.Join(dir, "sync"),
+		DatabasePath: filepath.Join(dir, "googlysync.db"),
+	}
+	if err := os.MkdirAll(cfg.SyncRoot, 0o700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	store, err := storage.NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return cfg, store
+}
+
+func sha(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	srcCfg, src := newManifestEnv(t)
+	dstCfg, dst := newManifestEnv(t)
+
+	upload := []byte("upload content")
+	download := []byte("aaaabbbbcc")
+	for _, cfg := range []*config.Config{srcCfg, dstCfg} {
+		if err := os.WriteFile(filepath.Join(cfg.SyncRoot, "up.bin"), upload, 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	// The copied download has a damaged middle chunk.
+	if err := os.WriteFile(filepath.Join(dstCfg.SyncRoot, "down.bin"), []byte("aaaaXbbbcc"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	sessions := []*storage.TransferSession{
+		{ID: "s-up", AccountID: "default", Direction: storage.TransferUpload, Path: "up.bin", SessionURI: "https://upload/1",
+			Size: int64(len(upload)), Checksum: sha(upload), Offset: 4, CreatedAt: now.Add(-time.Hour)},
+		{ID: "s-old", AccountID: "default", Direction: storage.TransferUpload, Path: "old.bin", SessionURI: "https://upload/2",
+			Size: 1, CreatedAt: now.Add(-8 * 24 * time.Hour)},
+		{ID: "s-down", AccountID: "default", Direction: storage.TransferDownload, Path: "down.bin", DriveID: "drive-d",
+			Size: int64(len(download)), ChunkSize: 4, Chunks: []storage.TransferChunk{
+				{Index: 0, Checksum: sha(download[0:4])},
+				{Index: 1, Checksum: sha(download[4:8])},
+				{Index: 2, Checksum: sha(download[8:10])},
+			}},
+		{ID: "s-other", AccountID: "someone-else", Direction: storage.TransferUpload, Path: "x.bin", SessionURI: "https://upload/3", CreatedAt: now},
+	}
+	for _, s := range sessions {
+		if err := src.SaveTransferSession(ctx, s); err != nil {
+			t.Fatalf("SaveTransferSession: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	n, err := ExportManifest(ctx, src, &buf, now)
+	if err != nil || n != len(sessions) {
+		t.Fatalf("ExportManifest: %d, %v", n, err)
+	}
+	report, err := ImportManifest(ctx, dstCfg, dst, &buf, now)
+	if err != nil {
+		t.Fatalf("ImportManifest: %v", err)
+	}
+	if report.Imported != 2 || len(report.Skipped) != 2 {
+		t.Fatalf("unexpected report: %#v", report)
+	}
+
+	got, err := dst.ListTransferSessions(ctx, "")
+	if err != nil {
+		t.Fatalf("ListTransferSessions: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 sessions, got %#v", got)
+	}
+	down, up := got[0], got[1]
+	if up.SessionURI != "https://upload/1" || up.Offset != 4 {
+		t.Fatalf("unexpected upload session: %#v", up)
+	}
+	if len(down.Chunks) != 2 || down.Chunks[0].Index != 0 || down.Chunks[1].Index != 2 {
+		t.Fatalf("expected only intact chunks kept, got %#v", down.Chunks)
+	}
+}
+
+func TestImportRejectsUnknownVersion(t *testing.T) {
+	cfg, store := newManifestEnv(t)
+	if _, err := ImportManifest(context.Background(), cfg, store, bytes.NewBufferString(`{"version": 99}`), time.Now()); err == nil {
+		t.Fatal("expected unsupported version error")
+	}
+}
@@ -0,0 +1,46 @@
+package transfer
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrInsufficientSpace is returned when the filesystem cannot hold a download.
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+// errAllocUnsupported marks filesystems or platforms without fallocate support.
+var errAllocUnsupported = errors.New("preallocation not supported")
+
+// Allocation describes how space for a download was reserved.
+type Allocation int
+
+const (
+	// AllocNone means no space was reserved up front.
+	AllocNone Allocation = iota
+	// AllocReserved means blocks were reserved with fallocate.
+	AllocReserved
+	// AllocSparse means the file was extended without reserving blocks.
+	AllocSparse
+)
+
+// Preallocate reserves size bytes for f. Filesystems without fallocate support
+// get a sparse file of the target length instead.
+func Preallocate(f *os.File, size int64) (Allocation, error) {
+	if size <= 0 {
+		return AllocNone, nil
+	}
+	err := allocate(f, size)
+	switch {
+	case err == nil:
+		return AllocReserved, nil
+	case isNoSpace(err):
+		return AllocNone, ErrInsufficientSpace
+	case errors.Is(err, errAllocUnsupported):
+		if err := f.Truncate(size); err != nil {
+			return AllocNone, err
+		}
+		return AllocSparse, nil
+	default:
+		return AllocNone, err
+	}
+}
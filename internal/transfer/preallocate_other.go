@@ -0,0 +1,15 @@
+//go:build !linux
+
+package transfer
+
+import "os"
+
+func allocate(f *os.File, size int64) error {
+	_, _ = f, size
+	return errAllocUnsupported
+}
+
+func isNoSpace(err error) bool {
+	_ = err
+	return false
+}
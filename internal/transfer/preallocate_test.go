@@ -0,0 +1,62 @@
+package transfer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/status"
+)
+
+func TestPreallocateExtendsFile(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "blob"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer f.Close()
+
+	alloc, err := Preallocate(f, 1<<20)
+	if err != nil {
+		t.Fatalf("Preallocate: %v", err)
+	}
+	if alloc == AllocNone {
+		t.Fatal("expected space to be reserved or sparse")
+	}
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Size() != 1<<20 {
+		t.Fatalf("expected size %d, got %d", 1<<20, info.Size())
+	}
+}
+
+func TestDownloaderSkipsSmallFiles(t *testing.T) {
+	cfg := &config.Config{SyncRoot: t.TempDir(), PreallocateMinMB: 1}
+	d, err := NewDownloader(zap.NewNop(), cfg, status.NewStore(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewDownloader: %v", err)
+	}
+
+	small, err := d.Create(context.Background(), "docs/small.bin", 10)
+	if err != nil {
+		t.Fatalf("Create small: %v", err)
+	}
+	defer small.Close()
+	if info, _ := small.Stat(); info.Size() != 0 {
+		t.Fatalf("expected small file untouched, got size %d", info.Size())
+	}
+
+	large, err := d.Create(context.Background(), "docs/large.bin", 2<<20)
+	if err != nil {
+		t.Fatalf("Create large: %v", err)
+	}
+	defer large.Close()
+	if info, _ := large.Stat(); info.Size() != 2<<20 {
+		t.Fatalf("expected large file preallocated, got size %d", info.Size())
+	}
+}
@@ -0,0 +1,116 @@
+package transfer
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Transfer directions reported in progress.
+const (
+	DirectionDownload = "download"
+	DirectionUpload   = "upload"
+)
+
+// TransferProgress is a point-in-time view of one active transfer.
+type TransferProgress struct {
+	Path      string
+	Direction string
+	Done      int64
+	// Total is zero when the size is not known in advance.
+	Total     int64
+	StartedAt time.Time
+	// BytesPerSecond is the average rate since the transfer started.
+	BytesPerSecond float64
+	// ETA is zero until the rate and total are known.
+	ETA time.Duration
+}
+
+// Progress is a registry of active transfers. Callers Start a tracker, feed
+// it the bytes they move and call Done when finished. A nil *Progress
+// tracks nothing.
+type Progress struct {
+	mu     sync.Mutex
+	next   uint64
+	active map[uint64]*Tracker
+	now    func() time.Time
+}
+
+// NewProgress constructs an empty registry.
+func NewProgress() *Progress {
+	return &Progress{active: make(map[uint64]*Tracker), now: time.Now}
+}
+
+// Tracker counts the bytes of one transfer. It is an io.Writer so it can sit
+// in a MultiWriter next to the destination.
+type Tracker struct {
+	p     *Progress
+	id    uint64
+	path  string
+	dir   string
+	total int64
+	start time.Time
+	done  int64
+}
+
+// Start registers a transfer of total bytes, zero when unknown.
+func (p *Progress) Start(direction, path string, total int64) *Tracker {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.next++
+	t := &Tracker{p: p, id: p.next, path: path, dir: direction, total: total, start: p.now()}
+	p.active[t.id] = t
+	return t
+}
+
+// Write counts len(b) bytes as transferred.
+func (t *Tracker) Write(b []byte) (int, error) {
+	if t == nil {
+		return len(b), nil
+	}
+	t.p.mu.Lock()
+	t.done += int64(len(b))
+	t.p.mu.Unlock()
+	return len(b), nil
+}
+
+// Done removes the transfer from the registry.
+func (t *Tracker) Done() {
+	if t == nil {
+		return
+	}
+	t.p.mu.Lock()
+	delete(t.p.active, t.id)
+	t.p.mu.Unlock()
+}
+
+// Active returns the transfers in progress, oldest first.
+func (p *Progress) Active() []TransferProgress {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	out := make([]TransferProgress, 0, len(p.active))
+	for _, t := range p.active {
+		tp := TransferProgress{Path: t.path, Direction: t.dir, Done: t.done, Total: t.total, StartedAt: t.start}
+		if elapsed := now.Sub(t.start).Seconds(); elapsed > 0 {
+			tp.BytesPerSecond = float64(t.done) / elapsed
+		}
+		if tp.BytesPerSecond > 0 && t.total > t.done {
+			tp.ETA = time.Duration(float64(t.total-t.done) / tp.BytesPerSecond * float64(time.Second))
+		}
+		out = append(out, tp)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].StartedAt.Equal(out[j].StartedAt) {
+			return out[i].StartedAt.Before(out[j].StartedAt)
+		}
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
@@ -0,0 +1,81 @@
+package transfer
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+)
+
+func TestProgressRateAndETA(t *testing.T) {
+	p := NewProgress()
+	now := time.Unix(1000, 0)
+	p.now = func() time.Time { return now }
+
+	a := p.Start(DirectionDownload, "a.bin", 1000)
+	now = now.Add(time.Second)
+	b := p.Start(DirectionUpload, "b.bin", 0)
+	_, _ = a.Write(make([]byte, 250))
+	_, _ = b.Write(make([]byte, 10))
+	now = now.Add(time.Second)
+
+	got := p.Active()
+	if len(got) != 2 || got[0].Path != "a.bin" || got[1].Direction != DirectionUpload {
+		t.Fatalf("unexpected transfers: %#v", got)
+	}
+	// 250 bytes in 2s leaves 750 bytes at 125 B/s.
+	if got[0].Done != 250 || got[0].BytesPerSecond != 125 || got[0].ETA != 6*time.Second {
+		t.Fatalf("unexpected download progress: %#v", got[0])
+	}
+	if got[1].ETA != 0 {
+		t.Fatalf("expected no ETA without a total, got %v", got[1].ETA)
+	}
+	a.Done()
+	b.Done()
+	if got := p.Active(); len(got) != 0 {
+		t.Fatalf("expected finished transfers removed, got %#v", got)
+	}
+
+	var nilProgress *Progress
+	tracker := nilProgress.Start(DirectionDownload, "x", 1)
+	_, _ = tracker.Write([]byte("x"))
+	tracker.Done()
+}
+
+func TestFetchReportsProgress(t *testing.T) {
+	cfg := &config.Config{SyncRoot: t.TempDir()}
+	progress := NewProgress()
+	d, err := NewDownloader(zap.NewNop(), cfg, nil, nil, nil, progress)
+	if err != nil {
+		t.Fatalf("NewDownloader: %v", err)
+	}
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := d.Fetch(context.Background(), "big.bin", 8, "", pr)
+		done <- err
+	}()
+	_, _ = pw.Write([]byte("data"))
+	// The write returns once read; the tracker counts it just after.
+	var got []TransferProgress
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if got = progress.Active(); len(got) == 1 && got[0].Done == 4 {
+			break
+		}
+	}
+	if len(got) != 1 || got[0].Path != "big.bin" || got[0].Done != 4 || got[0].Total != 8 {
+		t.Fatalf("unexpected progress mid-download: %#v", got)
+	}
+	_, _ = pw.Write([]byte("more"))
+	_ = pw.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if got := progress.Active(); len(got) != 0 {
+		t.Fatalf("expected no active transfers, got %#v", got)
+	}
+}
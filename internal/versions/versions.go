@@ -0,0 +1,251 @@
+package versions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/errs"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+// Store keeps previous local file contents before they are overwritten.
+// Content is stored once per checksum under the data dir.
+type Store struct {
+	logger  *zap.Logger
+	cfg     *config.Config
+	store   storage.Store
+	dir     string
+	keep    int
+	maxAge  time.Duration
+	nowFunc func() time.Time
+}
+
+// NewStore constructs a version store rooted at <data dir>/versions.
+func NewStore(logger *zap.Logger, cfg *config.Config, store storage.Store) (*Store, error) {
+	return &Store{
+		logger:  logger,
+		cfg:     cfg,
+		store:   store,
+		dir:     filepath.Join(cfg.DataDir, "versions"),
+		keep:    cfg.VersionsKeep,
+		maxAge:  time.Duration(cfg.VersionsMaxAgeDays) * 24 * time.Hour,
+		nowFunc: time.Now,
+	}, nil
+}
+
+// Stash saves the current content of the root-relative path, if it exists,
+// and applies retention for that path. It returns nil when there is nothing
+// to stash.
+func (s *Store) Stash(ctx context.Context, rel string) (*storage.FileVersion, error) {
+	src := filepath.Join(s.cfg.SyncRoot, filepath.FromSlash(rel))
+	info, err := os.Lstat(src)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil
+	}
+
+	checksum, size, err := s.storeObject(src)
+	if err != nil {
+		return nil, err
+	}
+	v := &storage.FileVersion{Path: rel, Checksum: checksum, Size: size, StashedAt: s.nowFunc()}
+	if err := s.store.AddFileVersion(ctx, v); err != nil {
+		return nil, err
+	}
+	if err := s.Prune(ctx, rel); err != nil {
+		s.logger.Warn("version prune failed", zap.String("path", rel), zap.Error(err))
+	}
+	return v, nil
+}
+
+// List returns stashed versions newest first. An empty path lists all.
+func (s *Store) List(ctx context.Context, rel string) ([]storage.FileVersion, error) {
+	return s.store.ListFileVersions(ctx, rel)
+}
+
+// Restore writes a stashed version back to dest (root-relative), or to its
+// original path when dest is empty. The content being replaced is stashed first.
+func (s *Store) Restore(ctx context.Context, id int64, dest string) (string, error) {
+	v, err := s.store.GetFileVersion(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if v == nil {
+		return "", errs.New(errs.ErrNotFound, "version %d not found", id)
+	}
+	if dest == "" {
+		dest = v.Path
+	}
+	if _, err := s.Stash(ctx, dest); err != nil {
+		return "", err
+	}
+
+	target := filepath.Join(s.cfg.SyncRoot, filepath.FromSlash(dest))
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		return "", err
+	}
+	if err := copyFile(s.objectPath(v.Checksum), target); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// Prune enforces retention by count and age. An empty path prunes everything.
+func (s *Store) Prune(ctx context.Context, rel string) error {
+	list, err := s.store.ListFileVersions(ctx, rel)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Time{}
+	if s.maxAge > 0 {
+		cutoff = s.nowFunc().Add(-s.maxAge)
+	}
+
+	seen := make(map[string]int)
+	for _, v := range list {
+		seen[v.Path]++
+		expired := !cutoff.IsZero() && v.StashedAt.Before(cutoff)
+		overCount := s.keep > 0 && seen[v.Path] > s.keep
+		if !expired && !overCount {
+			continue
+		}
+		if err := s.store.DeleteFileVersion(ctx, v.ID); err != nil {
+			return err
+		}
+		refs, err := s.store.CountFileVersionsByChecksum(ctx, v.Checksum)
+		if err != nil {
+			return err
+		}
+		if refs == 0 {
+			if err := os.Remove(s.objectPath(v.Checksum)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// gcGrace is how old an unreferenced object or stash temp file must be before
+// CollectGarbage removes it, so content being stashed right now is spared.
+const gcGrace = time.Hour
+
+// CollectGarbage removes stored objects that no version references any more,
+// such as ones left behind when a prune was interrupted, along with temp
+// files from stashes that never finished. It returns how many files it
+// removed.
+func (s *Store) CollectGarbage(ctx context.Context) (int, error) {
+	cutoff := s.nowFunc().Add(-gcGrace)
+	removed := 0
+	err := filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			return err
+		}
+		name := d.Name()
+		if !strings.HasPrefix(name, ".stash-") {
+			refs, err := s.store.CountFileVersionsByChecksum(ctx, name)
+			if err != nil || refs > 0 {
+				return err
+			}
+		}
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if removed > 0 {
+		s.logger.Info("removed unreferenced version content", zap.Int("files", removed))
+	}
+	return removed, err
+}
+
+func (s *Store) objectPath(checksum string) string {
+	return filepath.Join(s.dir, checksum[:2], checksum)
+}
+
+// storeObject copies src into the object store, keyed by its SHA-256.
+func (s *Store) storeObject(src string) (string, int64, error) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return "", 0, err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return "", 0, err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(s.dir, ".stash-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), in)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	checksum := hex.EncodeToString(h.Sum(nil))
+	dst := s.objectPath(checksum)
+	if _, err := os.Stat(dst); err == nil {
+		// Refresh the timestamp so CollectGarbage leaves the object alone
+		// until the new version row referencing it is written.
+		now := s.nowFunc()
+		_ = os.Chtimes(dst, now, now)
+		return checksum, size, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", 0, err
+	}
+	return checksum, size, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
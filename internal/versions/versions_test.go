@@ -0,0 +1,163 @@
+package versions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sandeepkv93/googlysync/internal/config"
+	"github.com/sandeepkv93/googlysync/internal/storage"
+)
+
+func newTestStore(t *testing.T, keep int) *Store {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &config.Config{
+		DataDir:            dir,
+		SyncRoot:           filepath.Join(dir, "sync"),
+		DatabasePath:       filepath.Join(dir, "googlysync.db"),
+		VersionsKeep:       keep,
+		VersionsMaxAgeDays: 30,
+	}
+	db, err := storage.NewStorage(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	s, err := NewStore(zap.NewNop(), cfg, db)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	return s
+}
+
+func writeRel(t *testing.T, s *Store, rel, content string) {
+	t.Helper()
+	path := filepath.Join(s.cfg.SyncRoot, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+func TestStashAndRestore(t *testing.T) {
+	s := newTestStore(t, 10)
+	ctx := context.Background()
+
+	if v, err := s.Stash(ctx, "missing.txt"); err != nil || v != nil {
+		t.Fatalf("expected nothing to stash, got %#v, %v", v, err)
+	}
+
+	writeRel(t, s, "docs/a.txt", "v1")
+	v1, err := s.Stash(ctx, "docs/a.txt")
+	if err != nil || v1 == nil {
+		t.Fatalf("Stash: %#v, %v", v1, err)
+	}
+	writeRel(t, s, "docs/a.txt", "v2")
+
+	if _, err := s.Restore(ctx, v1.ID, ""); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(s.cfg.SyncRoot, "docs/a.txt"))
+	if err != nil || string(data) != "v1" {
+		t.Fatalf("expected restored content v1, got %q, %v", data, err)
+	}
+
+	list, err := s.List(ctx, "docs/a.txt")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected v1 and stashed v2, got %#v", list)
+	}
+}
+
+func TestPruneByCountAndAge(t *testing.T) {
+	s := newTestStore(t, 2)
+	ctx := context.Background()
+
+	base := time.Unix(1_700_000_000, 0)
+	for i, content := range []string{"a", "b", "c"} {
+		s.nowFunc = func() time.Time { return base.Add(time.Duration(i) * time.Hour) }
+		writeRel(t, s, "f.txt", content)
+		if _, err := s.Stash(ctx, "f.txt"); err != nil {
+			t.Fatalf("Stash: %v", err)
+		}
+	}
+	list, err := s.List(ctx, "f.txt")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected count retention to keep 2, got %d", len(list))
+	}
+	if _, err := os.Stat(s.objectPath(list[0].Checksum)); err != nil {
+		t.Fatalf("expected retained object on disk: %v", err)
+	}
+
+	s.nowFunc = func() time.Time { return base.Add(60 * 24 * time.Hour) }
+	if err := s.Prune(ctx, ""); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	list, err = s.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("expected age retention to drop all, got %#v", list)
+	}
+}
+
+func TestCollectGarbageRemovesUnreferencedObjects(t *testing.T) {
+	s := newTestStore(t, 10)
+	ctx := context.Background()
+
+	writeRel(t, s, "kept.txt", "kept")
+	kept, err := s.Stash(ctx, "kept.txt")
+	if err != nil {
+		t.Fatalf("Stash: %v", err)
+	}
+	orphan := s.objectPath("ab" + kept.Checksum[2:])
+	stray := filepath.Join(s.dir, ".stash-123")
+	fresh := s.objectPath("cd" + kept.Checksum[2:])
+	for _, p := range []string{orphan, stray, fresh} {
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(p, []byte("x"), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	old := time.Now().Add(-2 * gcGrace)
+	for _, p := range []string{s.objectPath(kept.Checksum), orphan, stray} {
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatalf("Chtimes: %v", err)
+		}
+	}
+
+	removed, err := s.CollectGarbage(ctx)
+	if err != nil {
+		t.Fatalf("CollectGarbage: %v", err)
+	}
+	if removed != 2 {
+		t.Fatalf("expected 2 removed, got %d", removed)
+	}
+	for _, p := range []string{orphan, stray} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Fatalf("expected %s removed, got %v", p, err)
+		}
+	}
+	for _, p := range []string{s.objectPath(kept.Checksum), fresh} {
+		if _, err := os.Stat(p); err != nil {
+			t.Fatalf("expected %s kept: %v", p, err)
+		}
+	}
+}